@@ -0,0 +1,141 @@
+package aao
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	awsv1alpha1 "github.com/openshift/aws-account-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// poolForecast is the forecast of the unclaimed accounts of the pool, based on the claims and creations in the window
+type poolForecast struct {
+	WindowDays         float64  `json:"windowDays"`
+	Accounts           int      `json:"accounts"`
+	Available          int      `json:"available"`
+	ClaimsInWindow     int      `json:"claimsInWindow"`
+	CreatedInWindow    int      `json:"createdInWindow"`
+	ClaimRatePerDay    float64  `json:"claimRatePerDay"`
+	CreationRatePerDay float64  `json:"creationRatePerDay"`
+	DaysToExhaustion   *float64 `json:"daysToExhaustion"`
+	Threshold          int      `json:"threshold"`
+	DaysToThreshold    *float64 `json:"daysToThreshold"`
+	Failed             int      `json:"failed"`
+	FailedRatio        float64  `json:"failedRatio"`
+	Reused             int      `json:"reused"`
+	ReusedRatio        float64  `json:"reusedRatio"`
+	Warning            string   `json:"warning,omitempty"`
+}
+
+// isAvailable returns whether an account is an unclaimed account of the pool, ready to be claimed by a new legal entity
+func isAvailable(account awsv1alpha1.Account) bool {
+	return !account.Status.Claimed && account.Status.State == string(awsv1alpha1.AccountReady) && account.Spec.LegalEntity.ID == "" && !account.Spec.BYOC
+}
+
+// claimTime returns when the account was last claimed, zero if it isn't claimed
+func claimTime(account awsv1alpha1.Account) time.Time {
+	condition := account.GetCondition(awsv1alpha1.AccountIsClaimed)
+	if !account.Status.Claimed || condition == nil || condition.Status != corev1.ConditionTrue {
+		return time.Time{}
+	}
+	return condition.LastTransitionTime.Time
+}
+
+// forecastPool computes the claim and creation rate of the non-BYOC accounts over the window before now,
+// and when the available accounts run out or drop below the threshold at the net rate they're drained.
+// Claims of reused accounts don't count towards the claim rate as they don't take an account from the pool.
+// A warning is set when the pool is below the threshold or is forecast to drop below it within the horizon.
+func forecastPool(accounts []awsv1alpha1.Account, now time.Time, window, horizon time.Duration, threshold int) poolForecast {
+	since := now.Add(-window)
+	forecast := poolForecast{
+		WindowDays: window.Hours() / 24,
+		Threshold:  threshold,
+	}
+
+	for _, account := range accounts {
+		if account.Spec.BYOC {
+			continue
+		}
+		forecast.Accounts++
+
+		if isAvailable(account) {
+			forecast.Available++
+		}
+		if account.Status.State == string(awsv1alpha1.AccountFailed) {
+			forecast.Failed++
+		}
+		if account.Status.Reused {
+			forecast.Reused++
+		}
+		if account.CreationTimestamp.After(since) && !account.CreationTimestamp.After(now) {
+			forecast.CreatedInWindow++
+		}
+		if claimed := claimTime(account); claimed.After(since) && !claimed.After(now) && !account.Status.Reused {
+			forecast.ClaimsInWindow++
+		}
+	}
+
+	if forecast.Accounts > 0 {
+		forecast.FailedRatio = float64(forecast.Failed) / float64(forecast.Accounts)
+		forecast.ReusedRatio = float64(forecast.Reused) / float64(forecast.Accounts)
+	}
+	if forecast.WindowDays > 0 {
+		forecast.ClaimRatePerDay = float64(forecast.ClaimsInWindow) / forecast.WindowDays
+		forecast.CreationRatePerDay = float64(forecast.CreatedInWindow) / forecast.WindowDays
+	}
+
+	// The pool only runs out if it's drained faster than accounts are created
+	drain := forecast.ClaimRatePerDay - forecast.CreationRatePerDay
+	if drain > 0 {
+		forecast.DaysToExhaustion = roundedDays(float64(forecast.Available) / drain)
+		forecast.DaysToThreshold = roundedDays(math.Max(0, float64(forecast.Available-threshold)/drain))
+	}
+
+	switch {
+	case forecast.Available < threshold:
+		forecast.Warning = fmt.Sprintf("%d available accounts is below the threshold of %d", forecast.Available, threshold)
+	case forecast.DaysToThreshold != nil && *forecast.DaysToThreshold <= horizon.Hours()/24:
+		forecast.Warning = fmt.Sprintf("available accounts are forecast to drop below the threshold of %d in %.1f days", threshold, *forecast.DaysToThreshold)
+	}
+
+	return forecast
+}
+
+func roundedDays(days float64) *float64 {
+	rounded := math.Round(days*10) / 10
+	return &rounded
+}
+
+func formatDays(days *float64) string {
+	if days == nil {
+		return "never (not draining)"
+	}
+	return fmt.Sprintf("%.1f days", *days)
+}
+
+func printForecast(forecast poolForecast, output string, out, errOut io.Writer) error {
+	if output == "json" {
+		data, err := json.MarshalIndent(forecast, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+	} else {
+		fmt.Fprintf(out, "Accounts (non-BYOC): %d\n", forecast.Accounts)
+		fmt.Fprintf(out, "Available Accounts: %d\n", forecast.Available)
+		fmt.Fprintf(out, "Claims in the last %.0f days: %d (%.2f per day)\n", forecast.WindowDays, forecast.ClaimsInWindow, forecast.ClaimRatePerDay)
+		fmt.Fprintf(out, "Created in the last %.0f days: %d (%.2f per day)\n", forecast.WindowDays, forecast.CreatedInWindow, forecast.CreationRatePerDay)
+		fmt.Fprintf(out, "Time to exhaustion: %s\n", formatDays(forecast.DaysToExhaustion))
+		fmt.Fprintf(out, "Time to threshold (%d): %s\n", forecast.Threshold, formatDays(forecast.DaysToThreshold))
+		fmt.Fprintf(out, "Failed Accounts: %d (%.1f%%)\n", forecast.Failed, forecast.FailedRatio*100)
+		fmt.Fprintf(out, "Reused Accounts: %d (%.1f%%)\n", forecast.Reused, forecast.ReusedRatio*100)
+	}
+
+	if forecast.Warning != "" {
+		fmt.Fprintf(errOut, "WARNING: %s\n", forecast.Warning)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package aao
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	v1alpha1 "github.com/openshift/aws-account-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var forecastNow = time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+type testAccount struct {
+	name      string
+	createdAt time.Duration
+	claimedAt time.Duration
+	state     string
+	reused    bool
+	byoc      bool
+}
+
+// newTestAccount returns an account created and claimed the given time before forecastNow, unclaimed for a zero claimedAt
+func newTestAccount(a testAccount) v1alpha1.Account {
+	account := v1alpha1.Account{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              a.name,
+			Namespace:         "aws-account-operator",
+			CreationTimestamp: metav1.NewTime(forecastNow.Add(-a.createdAt)),
+		},
+		Spec:   v1alpha1.AccountSpec{BYOC: a.byoc},
+		Status: v1alpha1.AccountStatus{State: a.state, Reused: a.reused},
+	}
+	if account.Status.State == "" {
+		account.Status.State = string(v1alpha1.AccountReady)
+	}
+	if a.claimedAt > 0 {
+		account.Status.Claimed = true
+		account.Spec.LegalEntity = v1alpha1.LegalEntity{ID: "le-" + a.name, Name: a.name}
+		account.Status.Conditions = []v1alpha1.AccountCondition{{
+			Type:               v1alpha1.AccountIsClaimed,
+			Status:             corev1.ConditionTrue,
+			LastTransitionTime: metav1.NewTime(forecastNow.Add(-a.claimedAt)),
+		}}
+	}
+	return account
+}
+
+const day = 24 * time.Hour
+
+// forecastAccounts has 12 available accounts, 10 claims and 3 creations in the last 10 days
+func forecastAccounts() []v1alpha1.Account {
+	var accounts []v1alpha1.Account
+	for i := 0; i < 12; i++ {
+		accounts = append(accounts, newTestAccount(testAccount{name: "available", createdAt: 30 * day}))
+	}
+	for i := 0; i < 10; i++ {
+		accounts = append(accounts, newTestAccount(testAccount{name: "claimed", createdAt: 60 * day, claimedAt: time.Duration(i+1) * day / 2}))
+	}
+	for i := 0; i < 3; i++ {
+		accounts = append(accounts, newTestAccount(testAccount{name: "new", createdAt: day / 2, state: string(v1alpha1.AccountCreating)}))
+	}
+	return append(accounts,
+		// Claimed before the window
+		newTestAccount(testAccount{name: "old-claim", createdAt: 60 * day, claimedAt: 20 * day}),
+		// Reused claims don't drain the pool
+		newTestAccount(testAccount{name: "reused", createdAt: 60 * day, claimedAt: day, reused: true}),
+		newTestAccount(testAccount{name: "failed", createdAt: 60 * day, state: string(v1alpha1.AccountFailed)}),
+		// BYOC accounts aren't part of the pool
+		newTestAccount(testAccount{name: "byoc", createdAt: day, claimedAt: day, byoc: true}),
+	)
+}
+
+func TestForecastPool(t *testing.T) {
+	forecast := forecastPool(forecastAccounts(), forecastNow, 10*day, 7*day, 5)
+
+	assert.Equal(t, 28, forecast.Accounts)
+	assert.Equal(t, 12, forecast.Available)
+	assert.Equal(t, 10, forecast.ClaimsInWindow)
+	assert.Equal(t, 3, forecast.CreatedInWindow)
+	assert.InDelta(t, 1.0, forecast.ClaimRatePerDay, 0.001)
+	assert.InDelta(t, 0.3, forecast.CreationRatePerDay, 0.001)
+	// 12 accounts at 0.7 per day, 7 accounts above the threshold
+	require.NotNil(t, forecast.DaysToExhaustion)
+	assert.Equal(t, 17.1, *forecast.DaysToExhaustion)
+	assert.Equal(t, 10.0, *forecast.DaysToThreshold)
+	assert.Equal(t, 1, forecast.Failed)
+	assert.InDelta(t, 1.0/28, forecast.FailedRatio, 0.0001)
+	assert.Equal(t, 1, forecast.Reused)
+	assert.Empty(t, forecast.Warning)
+
+	forecast = forecastPool(forecastAccounts(), forecastNow, 10*day, 14*day, 5)
+	assert.Equal(t, "available accounts are forecast to drop below the threshold of 5 in 10.0 days", forecast.Warning)
+
+	forecast = forecastPool(forecastAccounts(), forecastNow, 10*day, 7*day, 20)
+	assert.Equal(t, 0.0, *forecast.DaysToThreshold)
+	assert.Equal(t, "12 available accounts is below the threshold of 20", forecast.Warning)
+
+	// More accounts are created than claimed over the last day
+	forecast = forecastPool(forecastAccounts(), forecastNow, day, 7*day, 5)
+	assert.Equal(t, 1, forecast.ClaimsInWindow)
+	assert.Nil(t, forecast.DaysToExhaustion)
+	assert.Nil(t, forecast.DaysToThreshold)
+	assert.Empty(t, forecast.Warning)
+}
+
+func TestRunForecast(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	var objects []client.Object
+	for i, account := range forecastAccounts() {
+		account.Name = account.Name + "-" + string(rune('a'+i))
+		objects = append(objects, account.DeepCopy())
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	o := &poolOptions{
+		window:    10 * day,
+		horizon:   14 * day,
+		threshold: 5,
+		output:    "json",
+		kubeCli:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+		IOStreams: genericclioptions.IOStreams{Out: stdout, ErrOut: stderr},
+	}
+	require.NoError(t, o.runForecast(forecastNow))
+
+	var forecast poolForecast
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &forecast))
+	assert.Equal(t, 12, forecast.Available)
+	assert.Equal(t, 17.1, *forecast.DaysToExhaustion)
+	assert.NotEmpty(t, forecast.Warning)
+	assert.Contains(t, stderr.String(), "WARNING: available accounts are forecast")
+
+	stdout.Reset()
+	o.output = ""
+	require.NoError(t, o.runForecast(forecastNow))
+	assert.Contains(t, stdout.String(), "Claims in the last 10 days: 10 (1.00 per day)")
+	assert.Contains(t, stdout.String(), "Time to exhaustion: 17.1 days")
+	assert.Contains(t, stdout.String(), "Failed Accounts: 1 (3.6%)")
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"sort"
 	"strconv"
+	"time"
 
 	awsv1alpha1 "github.com/openshift/aws-account-operator/api/v1alpha1"
 	"github.com/openshift/osdctl/pkg/printer"
@@ -20,32 +21,68 @@ import (
 func newCmdPool(client client.Client) *cobra.Command {
 	ops := newPoolOptions(client)
 	poolCmd := &cobra.Command{
-		Use:               "pool",
-		Short:             "Get the status of the AWS Account Operator AccountPool",
+		Use:   "pool",
+		Short: "Get the status of the AWS Account Operator AccountPool",
+		Long: `Get the status of the AWS Account Operator AccountPool.
+
+With --forecast, the claim and creation rate of the accounts over the --window are used to forecast when the
+available accounts run out and drop below the --threshold. Claims of reused accounts aren't counted, as they
+don't take an account from the pool. A warning is printed to stderr when the available accounts are below the
+threshold or are forecast to drop below it within the --horizon. Use -o json for alerting scripts.`,
+		Example: `  # Warn when less than 20 accounts are forecast to be available in the next 3 days
+  osdctl aao pool --forecast --threshold 20 --horizon 72h -o json`,
 		Args:              cobra.NoArgs,
 		DisableAutoGenTag: true,
 		Run: func(cmd *cobra.Command, args []string) {
 			cmdutil.CheckErr(ops.complete(cmd))
+			if ops.forecast {
+				cmdutil.CheckErr(ops.runForecast(time.Now()))
+				return
+			}
 			cmdutil.CheckErr(ops.run())
 		},
 	}
+	poolCmd.Flags().BoolVar(&ops.forecast, "forecast", false, "forecast the exhaustion of the available accounts from the recent claims")
+	poolCmd.Flags().DurationVar(&ops.window, "window", 14*24*time.Hour, "period of the claims and creations the forecast is based on")
+	poolCmd.Flags().DurationVar(&ops.horizon, "horizon", 7*24*time.Hour, "warn when the available accounts are forecast to drop below the threshold within this period")
+	poolCmd.Flags().IntVar(&ops.threshold, "threshold", 10, "minimum number of available accounts")
 
 	return poolCmd
 }
 
 // poolOptions defines the struct for running the pool command
 type poolOptions struct {
+	forecast  bool
+	window    time.Duration
+	horizon   time.Duration
+	threshold int
+	output    string
+
 	genericclioptions.IOStreams
 	kubeCli client.Client
 }
 
 func newPoolOptions(client client.Client) *poolOptions {
 	return &poolOptions{
-		kubeCli: client,
+		kubeCli:   client,
+		IOStreams: genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr},
 	}
 }
 
 func (o *poolOptions) complete(cmd *cobra.Command) error {
+	if !o.forecast {
+		return nil
+	}
+	if o.window <= 0 {
+		return cmdutil.UsageErrorf(cmd, "--window must be positive")
+	}
+	if o.threshold < 0 {
+		return cmdutil.UsageErrorf(cmd, "--threshold can't be negative")
+	}
+	output, err := cmd.Flags().GetString("output")
+	if err == nil {
+		o.output = output
+	}
 	return nil
 }
 
@@ -57,12 +94,28 @@ type legalEntityStats struct {
 	totalCount   int
 }
 
-func (o *poolOptions) run() error {
-	ctx := context.TODO()
+func (o *poolOptions) listAccounts() (*awsv1alpha1.AccountList, error) {
 	var accounts awsv1alpha1.AccountList
-	if err := o.kubeCli.List(ctx, &accounts, &client.ListOptions{
+	if err := o.kubeCli.List(context.TODO(), &accounts, &client.ListOptions{
 		Namespace: "aws-account-operator",
 	}); err != nil {
+		return nil, err
+	}
+	return &accounts, nil
+}
+
+func (o *poolOptions) runForecast(now time.Time) error {
+	accounts, err := o.listAccounts()
+	if err != nil {
+		return err
+	}
+	forecast := forecastPool(accounts.Items, now, o.window, o.horizon, o.threshold)
+	return printForecast(forecast, o.output, o.Out, o.ErrOut)
+}
+
+func (o *poolOptions) run() error {
+	accounts, err := o.listAccounts()
+	if err != nil {
 		return err
 	}
 
@@ -73,7 +126,7 @@ func (o *poolOptions) run() error {
 
 	for _, account := range accounts.Items {
 
-		if !account.Status.Claimed && account.Status.State == "Ready" && account.Spec.LegalEntity.ID == "" && !account.Spec.BYOC {
+		if isAvailable(account) {
 			availabilityCount += 1
 		}
 
@@ -58,6 +58,8 @@ type rotateSecretOptions struct {
 	awsAccountTimeout       *int32
 	reason                  string
 	osdManagedAdminUsername string
+	// accountNamespace is the namespace of the Account CR and its IAM user secret
+	accountNamespace string
 
 	genericclioptions.IOStreams
 	kubeCli *k8s.LazyClient
@@ -65,8 +67,9 @@ type rotateSecretOptions struct {
 
 func newRotateSecretOptions(streams genericclioptions.IOStreams, client *k8s.LazyClient) *rotateSecretOptions {
 	return &rotateSecretOptions{
-		IOStreams: streams,
-		kubeCli:   client,
+		IOStreams:        streams,
+		kubeCli:          client,
+		accountNamespace: common.AWSAccountNamespace,
 	}
 }
 
@@ -103,7 +106,7 @@ func (o *rotateSecretOptions) run() error {
 
 	// Get the associated Account CR from the provided name
 	var accountID string
-	account, err := k8s.GetAWSAccount(ctx, o.kubeCli, common.AWSAccountNamespace, o.accountCRName)
+	account, err := k8s.GetAWSAccount(ctx, o.kubeCli, o.accountNamespace, o.accountCRName)
 	if err != nil {
 		return err
 	}
@@ -244,7 +247,7 @@ func (o *rotateSecretOptions) run() error {
 	}
 
 	// Update existing osdManagedAdmin secret
-	err = common.UpdateSecret(o.kubeCli, o.accountCRName+"-secret", common.AWSAccountNamespace, newOsdManagedAdminSecretData)
+	err = common.UpdateSecret(o.kubeCli, o.accountCRName+"-secret", o.accountNamespace, newOsdManagedAdminSecretData)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,179 @@
+package servicequotas
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/openshift/osdctl/pkg/printer"
+	awsprovider "github.com/openshift/osdctl/pkg/provider/aws"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+// newCmdAudit implements servicequotas audit
+func newCmdAudit(streams genericclioptions.IOStreams) *cobra.Command {
+	ops := newAuditOptions(streams)
+	auditCmd := &cobra.Command{
+		Use:   "audit",
+		Short: "List the accounts of an OU with service-quotas below the OSD/ROSA baseline",
+		Long: `List the accounts below an OU, recursively, which have service-quotas below the quotas required by
+OSD/ROSA. The profile needs access to the organization of the OU.`,
+		Example: `  # Audit the accounts of an OU in us-east-1
+  osdctl account servicequotas audit --ou ou-abcd-efghijkl --profile osd-staging-2`,
+		Args:              cobra.NoArgs,
+		DisableAutoGenTag: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(ops.complete(cmd))
+			cmdutil.CheckErr(ops.run())
+		},
+	}
+
+	auditCmd.Flags().StringVar(&ops.ou, "ou", "", "OU whose accounts are audited")
+	auditCmd.Flags().StringVarP(&ops.awsProfile, "profile", "p", "", "AWS Profile")
+	auditCmd.Flags().StringVarP(&ops.region, "region", "r", "us-east-1", "AWS region of the quotas")
+	auditCmd.Flags().StringVar(&ops.baselineFile, "baseline", "", "YAML file with the required quotas, defaults to the OSD/ROSA baseline")
+	auditCmd.Flags().IntVar(&ops.workers, "workers", 5, "Number of accounts audited concurrently")
+	_ = auditCmd.MarkFlagRequired("ou")
+
+	return auditCmd
+}
+
+// auditOptions defines the struct for running servicequotas audit
+type auditOptions struct {
+	ou           string
+	awsProfile   string
+	region       string
+	baselineFile string
+	workers      int
+
+	genericclioptions.IOStreams
+	orgClient awsprovider.Client
+	clientFor func(accountID string) (awsprovider.Client, error)
+}
+
+func newAuditOptions(streams genericclioptions.IOStreams) *auditOptions {
+	return &auditOptions{IOStreams: streams}
+}
+
+func (o *auditOptions) complete(cmd *cobra.Command) error {
+	if o.ou == "" {
+		return cmdutil.UsageErrorf(cmd, "--ou is required")
+	}
+	if o.clientFor == nil {
+		clients, err := newAccountClients(o.awsProfile, o.region)
+		if err != nil {
+			return err
+		}
+		o.orgClient = clients.orgClient
+		o.clientFor = clients.forAccount
+	}
+	return nil
+}
+
+// accountAudit is the result of auditing the quotas of an account
+type accountAudit struct {
+	accountID string
+	below     []quotaComparison
+	err       error
+}
+
+func (o *auditOptions) run() error {
+	baseline, err := loadBaseline(o.baselineFile)
+	if err != nil {
+		return err
+	}
+	accountIDs, err := awsprovider.GetAccountsRecursive(o.orgClient, o.ou)
+	if err != nil {
+		return err
+	}
+	if len(accountIDs) == 0 {
+		fmt.Fprintf(o.Out, "No accounts found below %s\n", o.ou)
+		return nil
+	}
+
+	audits := o.auditAccounts(baseline, accountIDs)
+	printAudits(o.Out, audits)
+	return nil
+}
+
+// auditAccounts compares the quotas of the accounts with the baseline concurrently. Failing accounts are
+// reported in their audit instead of stopping the others.
+func (o *auditOptions) auditAccounts(baseline []requiredQuota, accountIDs []string) []accountAudit {
+	var (
+		mutex  sync.Mutex
+		audits []accountAudit
+	)
+	g, _ := errgroup.WithContext(context.Background())
+	g.SetLimit(max(o.workers, 1))
+	for _, accountID := range accountIDs {
+		g.Go(func() error {
+			audit := accountAudit{accountID: accountID}
+			awsClient, err := o.clientFor(accountID)
+			if err == nil {
+				var comparisons []quotaComparison
+				comparisons, err = compareQuotas(baseline, []awsprovider.Client{awsClient})
+				for _, comparison := range comparisons {
+					if len(comparison.below()) > 0 {
+						audit.below = append(audit.below, comparison)
+					}
+				}
+			}
+			audit.err = err
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			audits = append(audits, audit)
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	sort.Slice(audits, func(i, j int) bool { return audits[i].accountID < audits[j].accountID })
+	return audits
+}
+
+func printAudits(out io.Writer, audits []accountAudit) {
+	var belowCount int
+	var failed []accountAudit
+	table := printer.NewTablePrinter(out, 20, 1, 3, ' ')
+	table.AddRow([]string{"ACCOUNT", "SERVICE", "QUOTA", "NAME", "VALUE", "REQUIRED"})
+	for _, audit := range audits {
+		if audit.err != nil {
+			failed = append(failed, audit)
+			continue
+		}
+		if len(audit.below) > 0 {
+			belowCount++
+		}
+		for _, comparison := range audit.below {
+			table.AddRow([]string{
+				audit.accountID,
+				comparison.quota.ServiceCode,
+				comparison.quota.QuotaCode,
+				comparison.quota.Name,
+				formatQuota(comparison.values[0]),
+				formatQuota(comparison.quota.Minimum),
+			})
+		}
+	}
+	if belowCount > 0 {
+		if err := table.Flush(); err != nil {
+			fmt.Fprintln(out, "error while flushing table: ", err.Error())
+		}
+		fmt.Fprintln(out)
+	}
+
+	for _, audit := range failed {
+		fmt.Fprintf(out, "Failed to audit account %s: %v\n", audit.accountID, audit.err)
+	}
+	fmt.Fprintf(out, "%d of %d accounts are below the baseline", belowCount, len(audits))
+	if len(failed) > 0 {
+		fmt.Fprintf(out, ", %d could not be audited", len(failed))
+	}
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,94 @@
+package servicequotas
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas/types"
+	awsprovider "github.com/openshift/osdctl/pkg/provider/aws"
+	"sigs.k8s.io/yaml"
+)
+
+// requiredQuota is a quota an account needs for OSD/ROSA
+type requiredQuota struct {
+	ServiceCode string  `json:"serviceCode"`
+	QuotaCode   string  `json:"quotaCode"`
+	Name        string  `json:"name"`
+	Minimum     float64 `json:"minimum"`
+}
+
+// defaultBaseline is the minimum of the service quotas required to install OSD/ROSA clusters in a region
+var defaultBaseline = []requiredQuota{
+	{ServiceCode: "ec2", QuotaCode: "L-1216C47A", Name: "Running On-Demand Standard instances (vCPUs)", Minimum: 100},
+	{ServiceCode: "ec2", QuotaCode: "L-0263D0A3", Name: "EC2-VPC Elastic IPs", Minimum: 5},
+	{ServiceCode: "vpc", QuotaCode: "L-F678F1CE", Name: "VPCs per Region", Minimum: 5},
+	{ServiceCode: "vpc", QuotaCode: "L-A4707A72", Name: "Internet gateways per Region", Minimum: 5},
+	{ServiceCode: "vpc", QuotaCode: "L-DF5E4CA3", Name: "Network interfaces per Region", Minimum: 5000},
+	{ServiceCode: "elasticloadbalancing", QuotaCode: "L-69A177A2", Name: "Network Load Balancers per Region", Minimum: 50},
+	{ServiceCode: "elasticloadbalancing", QuotaCode: "L-E9E9831D", Name: "Classic Load Balancers per Region", Minimum: 20},
+}
+
+// loadBaseline returns the baseline of a YAML file with a list of required quotas, the default baseline without file
+func loadBaseline(path string) ([]requiredQuota, error) {
+	if path == "" {
+		return defaultBaseline, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %v", err)
+	}
+	var baseline []requiredQuota
+	if err := yaml.UnmarshalStrict(data, &baseline); err != nil {
+		return nil, fmt.Errorf("failed to parse baseline %s: %v", path, err)
+	}
+	for _, quota := range baseline {
+		if quota.ServiceCode == "" || quota.QuotaCode == "" {
+			return nil, fmt.Errorf("invalid baseline %s: every quota needs a serviceCode and a quotaCode", path)
+		}
+	}
+	return baseline, nil
+}
+
+// quotaValue is the value of a quota in an account
+type quotaValue struct {
+	Name  string
+	Value float64
+	// Default is set when the account uses the AWS default value of the quota
+	Default bool
+}
+
+// getQuotaValue returns the value of a quota applied to the account, or the AWS default when none is applied
+func getQuotaValue(awsClient awsprovider.Client, serviceCode, quotaCode string) (*quotaValue, error) {
+	applied, err := awsClient.GetServiceQuota(&servicequotas.GetServiceQuotaInput{
+		ServiceCode: aws.String(serviceCode),
+		QuotaCode:   aws.String(quotaCode),
+	})
+	if err == nil && applied.Quota != nil && applied.Quota.Value != nil {
+		return &quotaValue{Name: aws.ToString(applied.Quota.QuotaName), Value: *applied.Quota.Value}, nil
+	}
+	var notFound *types.NoSuchResourceException
+	if err != nil && !errors.As(err, &notFound) {
+		return nil, fmt.Errorf("failed to get quota %s/%s: %w", serviceCode, quotaCode, err)
+	}
+
+	defaultQuota, err := awsClient.GetAWSDefaultServiceQuota(&servicequotas.GetAWSDefaultServiceQuotaInput{
+		ServiceCode: aws.String(serviceCode),
+		QuotaCode:   aws.String(quotaCode),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get default quota %s/%s: %w", serviceCode, quotaCode, err)
+	}
+	if defaultQuota.Quota == nil || defaultQuota.Quota.Value == nil {
+		return nil, fmt.Errorf("quota %s/%s has no value", serviceCode, quotaCode)
+	}
+	return &quotaValue{Name: aws.ToString(defaultQuota.Quota.QuotaName), Value: *defaultQuota.Quota.Value, Default: true}, nil
+}
+
+// formatQuota formats a quota value without needless decimals
+func formatQuota(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
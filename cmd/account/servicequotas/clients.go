@@ -0,0 +1,47 @@
+package servicequotas
+
+import (
+	"fmt"
+
+	"github.com/openshift/osdctl/pkg/osdCloud"
+	awsprovider "github.com/openshift/osdctl/pkg/provider/aws"
+)
+
+// accountClients creates AWS clients for the accounts of the organization by assuming their
+// OrganizationAccountAccessRole with the credentials of the profile
+type accountClients struct {
+	orgClient   awsprovider.Client
+	region      string
+	partition   string
+	sessionName string
+}
+
+func newAccountClients(profile, region string) (*accountClients, error) {
+	awsClient, err := awsprovider.NewAwsClient(profile, region, "")
+	if err != nil {
+		return nil, fmt.Errorf("could not build AWS Client: %w", err)
+	}
+	partition, err := awsprovider.GetAwsPartition(awsClient)
+	if err != nil {
+		return nil, fmt.Errorf("could not get AWS partition: %w", err)
+	}
+	sessionName, err := osdCloud.GenerateRoleSessionName(awsClient)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate Session Name: %w", err)
+	}
+	return &accountClients{orgClient: awsClient, region: region, partition: partition, sessionName: sessionName}, nil
+}
+
+// forAccount returns a client for the AWS account
+func (c *accountClients) forAccount(accountID string) (awsprovider.Client, error) {
+	credentials, err := osdCloud.GenerateOrganizationAccountAccessCredentials(c.orgClient, accountID, c.sessionName, c.partition)
+	if err != nil {
+		return nil, fmt.Errorf("could not assume OrganizationAccountAccessRole of account %s: %w", accountID, err)
+	}
+	return awsprovider.NewAwsClientWithInput(&awsprovider.ClientInput{
+		AccessKeyID:     *credentials.AccessKeyId,
+		SecretAccessKey: *credentials.SecretAccessKey,
+		SessionToken:    *credentials.SessionToken,
+		Region:          c.region,
+	})
+}
@@ -18,6 +18,10 @@ func NewCmdServiceQuotas(streams genericclioptions.IOStreams) *cobra.Command {
 	}
 
 	baseCmd.AddCommand(newCmdDescribe())
+	baseCmd.AddCommand(newCmdCompare(streams))
+	baseCmd.AddCommand(newCmdRequest(streams))
+	baseCmd.AddCommand(newCmdRequests(streams))
+	baseCmd.AddCommand(newCmdAudit(streams))
 
 	return baseCmd
 }
@@ -0,0 +1,172 @@
+package servicequotas
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/openshift/osdctl/pkg/printer"
+	awsprovider "github.com/openshift/osdctl/pkg/provider/aws"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+// newCmdCompare implements servicequotas compare
+func newCmdCompare(streams genericclioptions.IOStreams) *cobra.Command {
+	ops := newCompareOptions(streams)
+	compareCmd := &cobra.Command{
+		Use:   "compare <account-id> [<other-account-id>]",
+		Short: "Compare the service-quotas of an account with the OSD/ROSA baseline or another account",
+		Long: `Compare the service-quotas of an account with the quotas required by OSD/ROSA, and with the quotas of
+another account when given. The accounts are accessed through their OrganizationAccountAccessRole, so the
+profile needs access to the organization of the accounts.
+
+The baseline can be replaced with a YAML file listing the required quotas:
+
+  - serviceCode: ec2
+    quotaCode: L-1216C47A
+    name: Running On-Demand Standard instances (vCPUs)
+    minimum: 200`,
+		Example: `  # Compare the quotas of two accounts in us-east-2
+  osdctl account servicequotas compare 111111111111 222222222222 --region us-east-2`,
+		Args:              cobra.RangeArgs(1, 2),
+		DisableAutoGenTag: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(ops.complete(args))
+			cmdutil.CheckErr(ops.run())
+		},
+	}
+
+	compareCmd.Flags().StringVarP(&ops.awsProfile, "profile", "p", "", "AWS Profile")
+	compareCmd.Flags().StringVarP(&ops.region, "region", "r", "us-east-1", "AWS region of the quotas")
+	compareCmd.Flags().StringVar(&ops.baselineFile, "baseline", "", "YAML file with the required quotas, defaults to the OSD/ROSA baseline")
+
+	return compareCmd
+}
+
+// compareOptions defines the struct for running servicequotas compare
+type compareOptions struct {
+	accountIDs   []string
+	awsProfile   string
+	region       string
+	baselineFile string
+
+	genericclioptions.IOStreams
+	clientFor func(accountID string) (awsprovider.Client, error)
+}
+
+func newCompareOptions(streams genericclioptions.IOStreams) *compareOptions {
+	return &compareOptions{IOStreams: streams}
+}
+
+func (o *compareOptions) complete(args []string) error {
+	o.accountIDs = args
+	if o.clientFor == nil {
+		clients, err := newAccountClients(o.awsProfile, o.region)
+		if err != nil {
+			return err
+		}
+		o.clientFor = clients.forAccount
+	}
+	return nil
+}
+
+// quotaComparison is the value of a required quota in each compared account
+type quotaComparison struct {
+	quota  requiredQuota
+	values []float64
+}
+
+// below returns the indexes of the accounts below the minimum of the quota
+func (c quotaComparison) below() []int {
+	var below []int
+	for i, value := range c.values {
+		if value < c.quota.Minimum {
+			below = append(below, i)
+		}
+	}
+	return below
+}
+
+func (c quotaComparison) differs() bool {
+	for _, value := range c.values {
+		if value != c.values[0] {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *compareOptions) run() error {
+	baseline, err := loadBaseline(o.baselineFile)
+	if err != nil {
+		return err
+	}
+
+	var clients []awsprovider.Client
+	for _, accountID := range o.accountIDs {
+		awsClient, err := o.clientFor(accountID)
+		if err != nil {
+			return err
+		}
+		clients = append(clients, awsClient)
+	}
+
+	comparisons, err := compareQuotas(baseline, clients)
+	if err != nil {
+		return err
+	}
+	printComparisons(o.Out, o.accountIDs, comparisons)
+	return nil
+}
+
+// compareQuotas gets the value of every quota of the baseline in every account
+func compareQuotas(baseline []requiredQuota, clients []awsprovider.Client) ([]quotaComparison, error) {
+	var comparisons []quotaComparison
+	for _, quota := range baseline {
+		comparison := quotaComparison{quota: quota}
+		for _, awsClient := range clients {
+			value, err := getQuotaValue(awsClient, quota.ServiceCode, quota.QuotaCode)
+			if err != nil {
+				return nil, err
+			}
+			if comparison.quota.Name == "" {
+				comparison.quota.Name = value.Name
+			}
+			comparison.values = append(comparison.values, value.Value)
+		}
+		comparisons = append(comparisons, comparison)
+	}
+	return comparisons, nil
+}
+
+func printComparisons(out io.Writer, accountIDs []string, comparisons []quotaComparison) {
+	table := printer.NewTablePrinter(out, 20, 1, 3, ' ')
+	header := []string{"SERVICE", "QUOTA", "NAME"}
+	header = append(header, accountIDs...)
+	table.AddRow(append(header, "REQUIRED", "STATUS"))
+
+	for _, comparison := range comparisons {
+		row := []string{comparison.quota.ServiceCode, comparison.quota.QuotaCode, comparison.quota.Name}
+		for _, value := range comparison.values {
+			row = append(row, formatQuota(value))
+		}
+
+		var status []string
+		for _, i := range comparison.below() {
+			status = append(status, "BELOW "+accountIDs[i])
+		}
+		if comparison.differs() {
+			status = append(status, "DIFFERENT")
+		}
+		if len(status) == 0 {
+			status = append(status, "OK")
+		}
+		table.AddRow(append(row, formatQuota(comparison.quota.Minimum), strings.Join(status, ", ")))
+	}
+
+	if err := table.Flush(); err != nil {
+		fmt.Fprintln(out, "error while flushing table: ", err.Error())
+	}
+}
@@ -0,0 +1,288 @@
+package servicequotas
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas/types"
+	"github.com/openshift/osdctl/pkg/printer"
+	awsprovider "github.com/openshift/osdctl/pkg/provider/aws"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+// openRequestStatuses are the statuses of quota increase requests which are still being processed
+var openRequestStatuses = []types.RequestStatus{types.RequestStatusPending, types.RequestStatusCaseOpened}
+
+// newCmdRequest implements servicequotas request
+func newCmdRequest(streams genericclioptions.IOStreams) *cobra.Command {
+	ops := newRequestOptions(streams)
+	requestCmd := &cobra.Command{
+		Use:   "request <account-id>",
+		Short: "Request a service-quota increase for an account",
+		Long: `Request a service-quota increase for an account. The request is refused when the quota already has the
+value. It is also refused when an increase of the quota is still open, unless --force is given.
+
+Use 'osdctl account servicequotas requests' to track the request.`,
+		Example: `  # Request 200 On-Demand Standard vCPUs in us-east-2
+  osdctl account servicequotas request 111111111111 --service-code ec2 --quota-code L-1216C47A --value 200 --region us-east-2`,
+		Args:              cobra.ExactArgs(1),
+		DisableAutoGenTag: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(ops.complete(cmd, args))
+			cmdutil.CheckErr(ops.run())
+		},
+	}
+
+	requestCmd.Flags().StringVarP(&ops.awsProfile, "profile", "p", "", "AWS Profile")
+	requestCmd.Flags().StringVarP(&ops.region, "region", "r", "us-east-1", "AWS region of the quota")
+	requestCmd.Flags().StringVar(&ops.serviceCode, "service-code", "ec2", "ServiceCode of the quota")
+	requestCmd.Flags().StringVarP(&ops.quotaCode, "quota-code", "q", "", "QuotaCode of the quota")
+	requestCmd.Flags().Float64Var(&ops.value, "value", 0, "Requested value of the quota")
+	requestCmd.Flags().BoolVar(&ops.force, "force", false, "Request the increase even if an increase of the quota is still open")
+	_ = requestCmd.MarkFlagRequired("quota-code")
+	_ = requestCmd.MarkFlagRequired("value")
+
+	return requestCmd
+}
+
+// requestOptions defines the struct for running servicequotas request
+type requestOptions struct {
+	accountID   string
+	awsProfile  string
+	region      string
+	serviceCode string
+	quotaCode   string
+	value       float64
+	force       bool
+
+	genericclioptions.IOStreams
+	clientFor func(accountID string) (awsprovider.Client, error)
+}
+
+func newRequestOptions(streams genericclioptions.IOStreams) *requestOptions {
+	return &requestOptions{IOStreams: streams}
+}
+
+func (o *requestOptions) complete(cmd *cobra.Command, args []string) error {
+	o.accountID = args[0]
+	if o.value <= 0 {
+		return cmdutil.UsageErrorf(cmd, "--value must be positive")
+	}
+	if o.clientFor == nil {
+		clients, err := newAccountClients(o.awsProfile, o.region)
+		if err != nil {
+			return err
+		}
+		o.clientFor = clients.forAccount
+	}
+	return nil
+}
+
+func (o *requestOptions) run() error {
+	awsClient, err := o.clientFor(o.accountID)
+	if err != nil {
+		return err
+	}
+
+	current, err := getQuotaValue(awsClient, o.serviceCode, o.quotaCode)
+	if err != nil {
+		return err
+	}
+	if current.Value >= o.value {
+		return fmt.Errorf("quota %s/%s (%s) of account %s is already %s", o.serviceCode, o.quotaCode, current.Name, o.accountID, formatQuota(current.Value))
+	}
+
+	if !o.force {
+		open, err := openRequests(awsClient, o.serviceCode, o.quotaCode)
+		if err != nil {
+			return err
+		}
+		if len(open) > 0 {
+			return fmt.Errorf("request %s to increase quota %s/%s to %s is still %s, use --force to request another increase",
+				aws.ToString(open[0].Id), o.serviceCode, o.quotaCode, formatQuota(aws.ToFloat64(open[0].DesiredValue)), open[0].Status)
+		}
+	}
+
+	output, err := awsClient.RequestServiceQuotaIncrease(&servicequotas.RequestServiceQuotaIncreaseInput{
+		ServiceCode:  aws.String(o.serviceCode),
+		QuotaCode:    aws.String(o.quotaCode),
+		DesiredValue: aws.Float64(o.value),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to request the increase of quota %s/%s: %w", o.serviceCode, o.quotaCode, err)
+	}
+
+	fmt.Fprintf(o.Out, "Requested the increase of %s (%s/%s) of account %s from %s to %s\n",
+		current.Name, o.serviceCode, o.quotaCode, o.accountID, formatQuota(current.Value), formatQuota(o.value))
+	if output.RequestedQuota != nil {
+		printRequests(o.Out, []types.RequestedServiceQuotaChange{*output.RequestedQuota})
+		fmt.Fprintf(o.Out, "\nTrack the request with: osdctl account servicequotas requests %s --request-id %s --region %s\n",
+			o.accountID, aws.ToString(output.RequestedQuota.Id), o.region)
+	}
+	return nil
+}
+
+// openRequests returns the increase requests of a quota which are still being processed
+func openRequests(awsClient awsprovider.Client, serviceCode, quotaCode string) ([]types.RequestedServiceQuotaChange, error) {
+	var open []types.RequestedServiceQuotaChange
+	for _, status := range openRequestStatuses {
+		input := &servicequotas.ListRequestedServiceQuotaChangeHistoryByQuotaInput{
+			ServiceCode: aws.String(serviceCode),
+			QuotaCode:   aws.String(quotaCode),
+			Status:      status,
+		}
+		for {
+			history, err := awsClient.ListRequestedServiceQuotaChangeHistoryByQuota(input)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list the requests of quota %s/%s: %w", serviceCode, quotaCode, err)
+			}
+			open = append(open, history.RequestedQuotas...)
+			if history.NextToken == nil {
+				break
+			}
+			input.NextToken = history.NextToken
+		}
+	}
+	return open, nil
+}
+
+// newCmdRequests implements servicequotas requests
+func newCmdRequests(streams genericclioptions.IOStreams) *cobra.Command {
+	ops := newRequestsOptions(streams)
+	requestsCmd := &cobra.Command{
+		Use:   "requests <account-id>",
+		Short: "Track the service-quota increase requests of an account",
+		Example: `  # Show the status of a request
+  osdctl account servicequotas requests 111111111111 --request-id 0123456789abcdef
+
+  # List the open requests for EC2 quotas
+  osdctl account servicequotas requests 111111111111 --service-code ec2 --status CASE_OPENED`,
+		Args:              cobra.ExactArgs(1),
+		DisableAutoGenTag: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(ops.complete(args))
+			cmdutil.CheckErr(ops.run())
+		},
+	}
+
+	requestsCmd.Flags().StringVarP(&ops.awsProfile, "profile", "p", "", "AWS Profile")
+	requestsCmd.Flags().StringVarP(&ops.region, "region", "r", "us-east-1", "AWS region of the requests")
+	requestsCmd.Flags().StringVar(&ops.requestID, "request-id", "", "Only show this request")
+	requestsCmd.Flags().StringVar(&ops.serviceCode, "service-code", "", "Only list the requests of this ServiceCode")
+	requestsCmd.Flags().StringVar(&ops.status, "status", "", "Only list the requests with this status, e.g. PENDING, CASE_OPENED, APPROVED, DENIED, CASE_CLOSED")
+
+	return requestsCmd
+}
+
+// requestsOptions defines the struct for running servicequotas requests
+type requestsOptions struct {
+	accountID   string
+	awsProfile  string
+	region      string
+	requestID   string
+	serviceCode string
+	status      string
+
+	genericclioptions.IOStreams
+	clientFor func(accountID string) (awsprovider.Client, error)
+}
+
+func newRequestsOptions(streams genericclioptions.IOStreams) *requestsOptions {
+	return &requestsOptions{IOStreams: streams}
+}
+
+func (o *requestsOptions) complete(args []string) error {
+	o.accountID = args[0]
+	if o.clientFor == nil {
+		clients, err := newAccountClients(o.awsProfile, o.region)
+		if err != nil {
+			return err
+		}
+		o.clientFor = clients.forAccount
+	}
+	return nil
+}
+
+func (o *requestsOptions) run() error {
+	awsClient, err := o.clientFor(o.accountID)
+	if err != nil {
+		return err
+	}
+
+	if o.requestID != "" {
+		output, err := awsClient.GetRequestedServiceQuotaChange(&servicequotas.GetRequestedServiceQuotaChangeInput{
+			RequestId: aws.String(o.requestID),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get request %s: %w", o.requestID, err)
+		}
+		if output.RequestedQuota == nil {
+			return fmt.Errorf("request %s not found", o.requestID)
+		}
+		printRequests(o.Out, []types.RequestedServiceQuotaChange{*output.RequestedQuota})
+		return nil
+	}
+
+	input := &servicequotas.ListRequestedServiceQuotaChangeHistoryInput{
+		Status: types.RequestStatus(o.status),
+	}
+	if o.serviceCode != "" {
+		input.ServiceCode = aws.String(o.serviceCode)
+	}
+	var requests []types.RequestedServiceQuotaChange
+	for {
+		history, err := awsClient.ListRequestedServiceQuotaChangeHistory(input)
+		if err != nil {
+			return fmt.Errorf("failed to list the requests of account %s: %w", o.accountID, err)
+		}
+		requests = append(requests, history.RequestedQuotas...)
+		if history.NextToken == nil {
+			break
+		}
+		input.NextToken = history.NextToken
+	}
+
+	if len(requests) == 0 {
+		fmt.Fprintln(o.Out, "No service-quota increase requests found")
+		return nil
+	}
+	printRequests(o.Out, requests)
+	return nil
+}
+
+func printRequests(out io.Writer, requests []types.RequestedServiceQuotaChange) {
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return t.UTC().Format(time.DateTime)
+	}
+
+	table := printer.NewTablePrinter(out, 20, 1, 3, ' ')
+	table.AddRow([]string{"REQUEST ID", "SERVICE", "QUOTA", "NAME", "DESIRED", "STATUS", "CASE", "CREATED", "UPDATED"})
+	for _, request := range requests {
+		caseID := aws.ToString(request.CaseId)
+		if caseID == "" {
+			caseID = "-"
+		}
+		table.AddRow([]string{
+			aws.ToString(request.Id),
+			aws.ToString(request.ServiceCode),
+			aws.ToString(request.QuotaCode),
+			aws.ToString(request.QuotaName),
+			formatQuota(aws.ToFloat64(request.DesiredValue)),
+			string(request.Status),
+			caseID,
+			formatTime(request.Created),
+			formatTime(request.LastUpdated),
+		})
+	}
+	if err := table.Flush(); err != nil {
+		fmt.Fprintln(out, "error while flushing table: ", err.Error())
+	}
+}
@@ -0,0 +1,252 @@
+package servicequotas
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas/types"
+	awsprovider "github.com/openshift/osdctl/pkg/provider/aws"
+	"github.com/openshift/osdctl/pkg/provider/aws/mock"
+	"go.uber.org/mock/gomock"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/yaml"
+)
+
+var testBaseline = []requiredQuota{
+	{ServiceCode: "ec2", QuotaCode: "L-1216C47A", Name: "vCPUs", Minimum: 100},
+	{ServiceCode: "vpc", QuotaCode: "L-F678F1CE", Name: "VPCs", Minimum: 5},
+}
+
+// expectQuotas makes the client return the applied values of the quotas, or the AWS default for missing quotas
+func expectQuotas(client *mock.MockClient, applied map[string]float64, defaults map[string]float64) {
+	client.EXPECT().GetServiceQuota(gomock.Any()).DoAndReturn(
+		func(input *servicequotas.GetServiceQuotaInput) (*servicequotas.GetServiceQuotaOutput, error) {
+			value, ok := applied[*input.QuotaCode]
+			if !ok {
+				return nil, &types.NoSuchResourceException{Message: aws.String("not found")}
+			}
+			return &servicequotas.GetServiceQuotaOutput{Quota: &types.ServiceQuota{Value: aws.Float64(value)}}, nil
+		}).AnyTimes()
+	client.EXPECT().GetAWSDefaultServiceQuota(gomock.Any()).DoAndReturn(
+		func(input *servicequotas.GetAWSDefaultServiceQuotaInput) (*servicequotas.GetAWSDefaultServiceQuotaOutput, error) {
+			return &servicequotas.GetAWSDefaultServiceQuotaOutput{Quota: &types.ServiceQuota{Value: aws.Float64(defaults[*input.QuotaCode])}}, nil
+		}).AnyTimes()
+}
+
+func writeBaseline(t *testing.T, baseline []requiredQuota) string {
+	data, err := yaml.Marshal(baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "baseline.yaml")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func clientsFor(clients map[string]awsprovider.Client) func(string) (awsprovider.Client, error) {
+	return func(accountID string) (awsprovider.Client, error) {
+		client, ok := clients[accountID]
+		if !ok {
+			return nil, fmt.Errorf("could not assume OrganizationAccountAccessRole of account %s", accountID)
+		}
+		return client, nil
+	}
+}
+
+func TestLoadBaseline(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.yaml")
+	if err := os.WriteFile(valid, []byte("- serviceCode: ec2\n  quotaCode: L-1216C47A\n  minimum: 200\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := os.WriteFile(invalid, []byte("- serviceCode: ec2\n  minimum: 200\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	unknown := filepath.Join(dir, "unknown.yaml")
+	if err := os.WriteFile(unknown, []byte("- serviceCode: ec2\n  quotaCode: L-1216C47A\n  maximum: 200\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	baseline, err := loadBaseline("")
+	if err != nil || len(baseline) != len(defaultBaseline) {
+		t.Errorf("expected the default baseline, got %v, %v", baseline, err)
+	}
+	baseline, err = loadBaseline(valid)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(baseline) != 1 || baseline[0].Minimum != 200 {
+		t.Errorf("unexpected baseline %v", baseline)
+	}
+	if _, err := loadBaseline(invalid); err == nil {
+		t.Error("expected an error for a quota without quotaCode")
+	}
+	if _, err := loadBaseline(unknown); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	first := mock.NewMockClient(ctrl)
+	second := mock.NewMockClient(ctrl)
+	expectQuotas(first, map[string]float64{"L-1216C47A": 200}, map[string]float64{"L-F678F1CE": 5})
+	expectQuotas(second, map[string]float64{}, map[string]float64{"L-1216C47A": 32, "L-F678F1CE": 5})
+
+	comparisons, err := compareQuotas(testBaseline, []awsprovider.Client{first, second})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if below := comparisons[0].below(); len(below) != 1 || below[0] != 1 {
+		t.Errorf("expected only the second account below the vCPU quota, got %v", below)
+	}
+	if !comparisons[0].differs() || comparisons[1].differs() {
+		t.Errorf("expected only the vCPU quota to differ")
+	}
+
+	out := &bytes.Buffer{}
+	printComparisons(out, []string{"111111111111", "222222222222"}, comparisons)
+	for _, expected := range []string{"BELOW 222222222222, DIFFERENT", "OK"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in output:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestAudit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	orgClient := mock.NewMockClient(ctrl)
+	orgClient.EXPECT().ListAccountsForParent(&organizations.ListAccountsForParentInput{ParentId: aws.String("ou-root")}).
+		Return(&organizations.ListAccountsForParentOutput{Accounts: []orgtypes.Account{{Id: aws.String("111111111111")}}}, nil)
+	orgClient.EXPECT().ListOrganizationalUnitsForParent(&organizations.ListOrganizationalUnitsForParentInput{ParentId: aws.String("ou-root")}).
+		Return(&organizations.ListOrganizationalUnitsForParentOutput{OrganizationalUnits: []orgtypes.OrganizationalUnit{{Id: aws.String("ou-child")}}}, nil)
+	orgClient.EXPECT().ListAccountsForParent(&organizations.ListAccountsForParentInput{ParentId: aws.String("ou-child")}).
+		Return(&organizations.ListAccountsForParentOutput{Accounts: []orgtypes.Account{{Id: aws.String("222222222222")}, {Id: aws.String("333333333333")}}}, nil)
+	orgClient.EXPECT().ListOrganizationalUnitsForParent(&organizations.ListOrganizationalUnitsForParentInput{ParentId: aws.String("ou-child")}).
+		Return(&organizations.ListOrganizationalUnitsForParentOutput{}, nil)
+
+	compliant := mock.NewMockClient(ctrl)
+	expectQuotas(compliant, map[string]float64{"L-1216C47A": 200, "L-F678F1CE": 10}, nil)
+	below := mock.NewMockClient(ctrl)
+	expectQuotas(below, map[string]float64{"L-1216C47A": 64, "L-F678F1CE": 10}, nil)
+
+	out := &bytes.Buffer{}
+	o := &auditOptions{
+		ou:           "ou-root",
+		baselineFile: writeBaseline(t, testBaseline),
+		workers:      2,
+		IOStreams:    genericclioptions.IOStreams{Out: out},
+		orgClient:    orgClient,
+		clientFor:    clientsFor(map[string]awsprovider.Client{"111111111111": compliant, "222222222222": below}),
+	}
+	if err := o.run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, expected := range []string{
+		"222222222222",
+		"Failed to audit account 333333333333",
+		"1 of 3 accounts are below the baseline, 1 could not be audited",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in output:\n%s", expected, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "111111111111") {
+		t.Errorf("expected the compliant account not to be listed:\n%s", out.String())
+	}
+}
+
+func TestRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       float64
+		force       bool
+		open        []types.RequestedServiceQuotaChange
+		expectCall  bool
+		errContains string
+	}{
+		{
+			name:       "requests the increase",
+			value:      200,
+			expectCall: true,
+		},
+		{
+			name:        "refuses a value which is not an increase",
+			value:       64,
+			errContains: "already 64",
+		},
+		{
+			name:        "refuses while a request is open",
+			value:       200,
+			open:        []types.RequestedServiceQuotaChange{{Id: aws.String("req-1"), DesiredValue: aws.Float64(150), Status: types.RequestStatusCaseOpened}},
+			errContains: "request req-1",
+		},
+		{
+			name:       "forces the request while a request is open",
+			value:      200,
+			force:      true,
+			open:       []types.RequestedServiceQuotaChange{{Id: aws.String("req-1"), DesiredValue: aws.Float64(150), Status: types.RequestStatusCaseOpened}},
+			expectCall: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			client := mock.NewMockClient(ctrl)
+			expectQuotas(client, map[string]float64{"L-1216C47A": 64}, nil)
+			client.EXPECT().ListRequestedServiceQuotaChangeHistoryByQuota(gomock.Any()).DoAndReturn(
+				func(input *servicequotas.ListRequestedServiceQuotaChangeHistoryByQuotaInput) (*servicequotas.ListRequestedServiceQuotaChangeHistoryByQuotaOutput, error) {
+					if input.Status == types.RequestStatusCaseOpened {
+						return &servicequotas.ListRequestedServiceQuotaChangeHistoryByQuotaOutput{RequestedQuotas: tt.open}, nil
+					}
+					return &servicequotas.ListRequestedServiceQuotaChangeHistoryByQuotaOutput{}, nil
+				}).AnyTimes()
+			if tt.expectCall {
+				client.EXPECT().RequestServiceQuotaIncrease(&servicequotas.RequestServiceQuotaIncreaseInput{
+					ServiceCode:  aws.String("ec2"),
+					QuotaCode:    aws.String("L-1216C47A"),
+					DesiredValue: aws.Float64(tt.value),
+				}).Return(&servicequotas.RequestServiceQuotaIncreaseOutput{
+					RequestedQuota: &types.RequestedServiceQuotaChange{Id: aws.String("req-2"), Status: types.RequestStatusPending},
+				}, nil)
+			}
+
+			out := &bytes.Buffer{}
+			o := &requestOptions{
+				accountID:   "111111111111",
+				serviceCode: "ec2",
+				quotaCode:   "L-1216C47A",
+				value:       tt.value,
+				force:       tt.force,
+				IOStreams:   genericclioptions.IOStreams{Out: out},
+				clientFor:   clientsFor(map[string]awsprovider.Client{"111111111111": client}),
+			}
+			err := o.run()
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("expected error containing %q, got %v", tt.errContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.Contains(out.String(), "--request-id req-2") {
+				t.Errorf("expected a hint to track the request:\n%s", out.String())
+			}
+		})
+	}
+}
@@ -4,13 +4,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	awsv1alpha1 "github.com/openshift/aws-account-operator/api/v1alpha1"
 	"github.com/openshift/osdctl/cmd/common"
 	"github.com/openshift/osdctl/pkg/k8s"
+	"github.com/openshift/osdctl/pkg/printer"
 	awsprovider "github.com/openshift/osdctl/pkg/provider/aws"
+	"github.com/openshift/osdctl/pkg/utils"
 	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
@@ -26,8 +35,18 @@ const (
 func newCmdVerifySecrets(streams genericclioptions.IOStreams, client client.Client) *cobra.Command {
 	ops := newVerifySecretsOptions(streams, client)
 	verifySecretsCmd := &cobra.Command{
-		Use:               "verify-secrets [<account name>]",
-		Short:             "Verify AWS Account CR IAM User credentials",
+		Use:   "verify-secrets [<account name>]",
+		Short: "Verify AWS Account CR IAM User credentials",
+		Long: `Verify AWS Account CR IAM User credentials.
+
+For every credential secret, reports whether it works, whether it belongs to the AWS account of the
+Account CR, the age of its access key and when the key was last used. With --all, the Account CRs are
+verified concurrently by up to --workers workers.
+
+With --remediate, the credentials which are failing or older than --max-key-age are rotated with the
+rotate-secret flow after confirmation, which requires --reason and the --aws-profile used by rotate-secret.`,
+		Example: `  # Verify the credentials of all Account CRs and rotate the failing or stale ones
+  osdctl account verify-secrets --all --remediate --reason OHSS-1234`,
 		DisableAutoGenTag: true,
 		Run: func(cmd *cobra.Command, args []string) {
 			cmdutil.CheckErr(ops.complete(cmd, args))
@@ -39,7 +58,12 @@ func newCmdVerifySecrets(streams genericclioptions.IOStreams, client client.Clie
 	verifySecretsCmd.Flags().StringVar(&ops.accountNamespace, "account-namespace", common.AWSAccountNamespace,
 		"The namespace to keep AWS accounts. The default value is aws-account-operator.")
 	verifySecretsCmd.Flags().BoolVarP(&ops.verbose, "verbose", "", false, "Verbose output")
-	verifySecretsCmd.Flags().BoolVarP(&ops.all, "all", "A", false, "Verify all Account CRs")
+	verifySecretsCmd.Flags().BoolVarP(&ops.all, "all", "A", false, "Verify all Account CRs, accounts whose IAM user secret is missing are reported without failing the verification")
+	verifySecretsCmd.Flags().IntVar(&ops.workers, "workers", 10, "Number of Account CRs verified concurrently")
+	verifySecretsCmd.Flags().DurationVar(&ops.maxKeyAge, "max-key-age", 90*24*time.Hour, "Access keys older than this are reported as stale")
+	verifySecretsCmd.Flags().BoolVar(&ops.remediate, "remediate", false, "Rotate failing or stale credentials after confirmation")
+	verifySecretsCmd.Flags().StringVar(&ops.reason, "reason", "", "The reason for the rotation, which requires elevation (usually an OHSS or PD ticket)")
+	verifySecretsCmd.Flags().StringVarP(&ops.profile, "aws-profile", "p", "", "specify AWS profile used for the rotation")
 
 	return verifySecretsCmd
 }
@@ -49,18 +73,37 @@ type verifySecretsOptions struct {
 	accountName      string
 	accountNamespace string
 
-	verbose bool
-	all     bool
+	verbose   bool
+	all       bool
+	workers   int
+	maxKeyAge time.Duration
+	remediate bool
+	reason    string
+	profile   string
 
 	genericclioptions.IOStreams
 	kubeCli client.Client
+
+	// newAwsClient creates the AWS client of a credential, rotate rotates the credentials of an Account CR
+	newAwsClient func(*awsprovider.ClientInput) (awsprovider.Client, error)
+	rotate       func(accountName string, ccs bool) error
+	confirm      func() bool
+	now          func() time.Time
+	outputMutex  sync.Mutex
 }
 
 func newVerifySecretsOptions(streams genericclioptions.IOStreams, client client.Client) *verifySecretsOptions {
-	return &verifySecretsOptions{
-		IOStreams: streams,
-		kubeCli:   client,
+	o := &verifySecretsOptions{
+		IOStreams:    streams,
+		kubeCli:      client,
+		workers:      10,
+		maxKeyAge:    90 * 24 * time.Hour,
+		newAwsClient: awsprovider.NewAwsClientWithInput,
+		confirm:      utils.ConfirmPrompt,
+		now:          time.Now,
 	}
+	o.rotate = o.rotateSecret
+	return o
 }
 
 func (o *verifySecretsOptions) complete(cmd *cobra.Command, args []string) error {
@@ -72,48 +115,43 @@ func (o *verifySecretsOptions) complete(cmd *cobra.Command, args []string) error
 		o.accountName = args[0]
 	}
 
+	if o.remediate && o.reason == "" {
+		return cmdutil.UsageErrorf(cmd, "--reason is required to remediate credentials")
+	}
+
 	return nil
 }
 
+// secretCheck is the result of verifying a credential secret of an Account CR
+type secretCheck struct {
+	account     string
+	secret      string
+	byoc        bool
+	accessKeyID string
+	valid       bool
+	matchesCR   bool
+	keyAge      *time.Duration
+	lastUsed    *time.Time
+	stale       bool
+	err         error
+}
+
+// failing returns whether the credentials don't work or don't belong to the account of the CR
+func (c *secretCheck) failing() bool {
+	return c.err != nil || !c.valid || !c.matchesCR
+}
+
 func (o *verifySecretsOptions) run() error {
 	ctx := context.TODO()
-	var (
-		awsClient   awsprovider.Client
-		credentials []*awsSecret
-		err         error
-		allErr      bool
-	)
+	var accounts []awsv1alpha1.Account
 	if o.all {
-		var accounts awsv1alpha1.AccountList
-		if err := o.kubeCli.List(ctx, &accounts, &client.ListOptions{
+		var accountList awsv1alpha1.AccountList
+		if err := o.kubeCli.List(ctx, &accountList, &client.ListOptions{
 			Namespace: o.accountNamespace,
 		}); err != nil {
 			return err
 		}
-
-		for _, account := range accounts.Items {
-			if account.Spec.IAMUserSecret == "" {
-				continue
-			}
-			if o.verbose {
-				fmt.Fprintln(o.IOStreams.Out, "Getting AWS Credentials for account "+account.Name)
-			}
-			creds, err := k8s.GetAWSAccountCredentials(ctx, o.kubeCli, o.accountNamespace, account.Spec.IAMUserSecret)
-			if err != nil {
-				if apierrors.IsNotFound(err) && account.Status.State != "Creating" {
-					fmt.Fprintf(o.IOStreams.Out, "Account %s doesn't have associate credentials, state %s",
-						account.Name, account.Status.State)
-				}
-				continue
-			}
-			credentials = append(credentials, &awsSecret{
-				secret: account.Spec.IAMUserSecret,
-				awsCreds: &awsprovider.ClientInput{
-					AccessKeyID:     creds.AccessKeyID,
-					SecretAccessKey: creds.SecretAccessKey,
-				},
-			})
-		}
+		accounts = accountList.Items
 	} else {
 		if o.accountName == "" {
 			return fmt.Errorf("Please provide an account CR name")
@@ -125,76 +163,243 @@ func (o *verifySecretsOptions) run() error {
 		if account.Spec.IAMUserSecret == "" {
 			return fmt.Errorf("account %s doesn't have associate credentials", account.Name)
 		}
-		if o.verbose {
-			fmt.Fprintln(o.IOStreams.Out, "Getting AWS Credentials for account "+account.Name)
+		accounts = append(accounts, *account)
+	}
+
+	var (
+		checks []*secretCheck
+		mutex  sync.Mutex
+	)
+	group, _ := errgroup.WithContext(ctx)
+	group.SetLimit(max(o.workers, 1))
+	for i := range accounts {
+		account := accounts[i]
+		if account.Spec.IAMUserSecret == "" {
+			continue
 		}
-		creds, err := k8s.GetAWSAccountCredentials(ctx, o.kubeCli, o.accountNamespace, account.Spec.IAMUserSecret)
-		if err != nil {
+		group.Go(func() error {
+			accountChecks := o.checkAccount(ctx, account)
+			mutex.Lock()
+			defer mutex.Unlock()
+			checks = append(checks, accountChecks...)
+			return nil
+		})
+	}
+	_ = group.Wait()
+
+	sort.Slice(checks, func(i, j int) bool {
+		if checks[i].account != checks[j].account {
+			return checks[i].account < checks[j].account
+		}
+		return checks[i].secret < checks[j].secret
+	})
+	o.printChecks(checks)
+
+	if o.remediate {
+		if err := o.remediateChecks(checks); err != nil {
 			return err
 		}
-		credentials = append(credentials, &awsSecret{
-			secret: account.Spec.IAMUserSecret,
-			awsCreds: &awsprovider.ClientInput{
-				AccessKeyID:     creds.AccessKeyID,
-				SecretAccessKey: creds.SecretAccessKey,
-			},
-		})
+	}
 
-		// Add osdCcsAdmin credentials to be validated for CCS accounts
-		if account.Spec.BYOC {
-			creds, err := k8s.GetAWSAccountCredentials(ctx, o.kubeCli, account.Spec.ClaimLinkNamespace, "byoc")
-			if err != nil {
-				return err
-			}
-			credentials = append(credentials, &awsSecret{
-				secret: "byoc",
-				awsCreds: &awsprovider.ClientInput{
-					AccessKeyID:     creds.AccessKeyID,
-					SecretAccessKey: creds.SecretAccessKey,
-				},
-			})
+	for _, check := range checks {
+		if check.failing() {
+			fmt.Fprintf(o.IOStreams.Out, "Some credentials are invalid\n")
+			return errors.New("AccountCredentialError")
 		}
 	}
 
-	for _, cred := range credentials {
-		if o.verbose {
-			fmt.Fprintln(o.IOStreams.Out, "Start validating secret "+cred.secret)
+	if !o.all {
+		fmt.Fprintf(o.IOStreams.Out, "Credentials valid for %s\n", o.accountName)
+	} else {
+		fmt.Fprintf(o.IOStreams.Out, "Credentials valid for all Account CRs\n")
+	}
+
+	return nil
+}
+
+func (o *verifySecretsOptions) logf(format string, args ...interface{}) {
+	if !o.verbose {
+		return
+	}
+	o.outputMutex.Lock()
+	defer o.outputMutex.Unlock()
+	fmt.Fprintf(o.IOStreams.Out, format+"\n", args...)
+}
+
+// checkAccount verifies the IAM user secret of an Account CR, and the osdCcsAdmin secret of a CCS account when
+// verifying a single account
+func (o *verifySecretsOptions) checkAccount(ctx context.Context, account awsv1alpha1.Account) []*secretCheck {
+	o.logf("Getting AWS Credentials for account %s", account.Name)
+	creds, err := k8s.GetAWSAccountCredentials(ctx, o.kubeCli, o.accountNamespace, account.Spec.IAMUserSecret)
+	if err != nil {
+		// Credentials of accounts which are being created may not exist yet
+		if apierrors.IsNotFound(err) && account.Status.State == "Creating" {
+			return nil
 		}
-		awsClient, err = awsprovider.NewAwsClientWithInput(cred.awsCreds)
-		if err != nil {
-			fmt.Fprintf(o.IOStreams.Out, "Failed to create AWS client with secret %s\n", cred.secret)
+		if apierrors.IsNotFound(err) {
+			// With --all a missing secret is only reported, it doesn't fail the verification
 			if o.all {
-				allErr = true
-				continue
+				o.outputMutex.Lock()
+				defer o.outputMutex.Unlock()
+				fmt.Fprintf(o.IOStreams.Out, "Account %s doesn't have associate credentials, state %s\n", account.Name, account.Status.State)
+				return nil
 			}
-			return err
+			err = fmt.Errorf("account %s doesn't have associate credentials, state %s", account.Name, account.Status.State)
 		}
-		if _, err := awsClient.GetCallerIdentity(
-			&sts.GetCallerIdentityInput{}); err != nil {
-			fmt.Fprintf(o.IOStreams.Out, "Failed to get caller identity with secret %s\n", cred.secret)
-			if o.all {
-				allErr = true
-				continue
-			}
-			return err
+		return []*secretCheck{{account: account.Name, secret: account.Spec.IAMUserSecret, err: err}}
+	}
+	checks := []*secretCheck{o.checkSecret(account, account.Spec.IAMUserSecret, &awsprovider.ClientInput{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+	})}
+
+	// Add osdCcsAdmin credentials to be validated for CCS accounts
+	if account.Spec.BYOC && !o.all {
+		check := &secretCheck{account: account.Name, secret: "byoc", byoc: true}
+		creds, err := k8s.GetAWSAccountCredentials(ctx, o.kubeCli, account.Spec.ClaimLinkNamespace, "byoc")
+		if err != nil {
+			check.err = err
+		} else {
+			check = o.checkSecret(account, "byoc", &awsprovider.ClientInput{
+				AccessKeyID:     creds.AccessKeyID,
+				SecretAccessKey: creds.SecretAccessKey,
+			})
+			check.byoc = true
 		}
+		checks = append(checks, check)
 	}
 
-	if allErr {
-		fmt.Fprintf(o.IOStreams.Out, "Some credentials are invalid\n")
-		return errors.New("AccountCredentialError")
+	return checks
+}
+
+// checkSecret verifies a credential works and belongs to the AWS account of the CR, then looks up the age
+// and last use of its access key
+func (o *verifySecretsOptions) checkSecret(account awsv1alpha1.Account, secret string, creds *awsprovider.ClientInput) *secretCheck {
+	check := &secretCheck{account: account.Name, secret: secret, accessKeyID: creds.AccessKeyID}
+	o.logf("Start validating secret %s of account %s", secret, account.Name)
+
+	awsClient, err := o.newAwsClient(creds)
+	if err != nil {
+		check.err = fmt.Errorf("failed to create AWS client with secret %s: %w", secret, err)
+		return check
+	}
+	identity, err := awsClient.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		check.err = fmt.Errorf("failed to get caller identity with secret %s: %w", secret, err)
+		return check
 	}
+	check.valid = true
+	check.matchesCR = awsSdk.ToString(identity.Account) == account.Spec.AwsAccountID
 
-	if !o.all {
-		fmt.Fprintf(o.IOStreams.Out, "Credentials valid for %s\n", o.accountName)
+	// The access keys are listed with the credentials themselves, as their IAM user
+	arn := awsSdk.ToString(identity.Arn)
+	userName := arn[strings.LastIndex(arn, "/")+1:]
+	keys, err := awsClient.ListAccessKeys(&iam.ListAccessKeysInput{UserName: &userName})
+	if err != nil {
+		o.logf("Failed to list access keys of %s: %v", userName, err)
 	} else {
-		fmt.Fprintf(o.IOStreams.Out, "Credentials valid for all Account CRs\n")
+		for _, key := range keys.AccessKeyMetadata {
+			if awsSdk.ToString(key.AccessKeyId) == creds.AccessKeyID && key.CreateDate != nil {
+				age := o.now().Sub(*key.CreateDate)
+				check.keyAge = &age
+				check.stale = age > o.maxKeyAge
+			}
+		}
+	}
+
+	lastUsed, err := awsClient.GetAccessKeyLastUsed(&iam.GetAccessKeyLastUsedInput{AccessKeyId: &creds.AccessKeyID})
+	if err != nil {
+		o.logf("Failed to get the last use of access key %s: %v", creds.AccessKeyID, err)
+	} else if lastUsed.AccessKeyLastUsed != nil {
+		check.lastUsed = lastUsed.AccessKeyLastUsed.LastUsedDate
 	}
 
+	return check
+}
+
+func (o *verifySecretsOptions) printChecks(checks []*secretCheck) {
+	table := printer.NewTablePrinter(o.IOStreams.Out, 20, 1, 3, ' ')
+	table.AddRow([]string{"ACCOUNT", "SECRET", "VALID", "MATCHES CR", "KEY AGE", "LAST USED", "ERROR"})
+	for _, check := range checks {
+		keyAge := "-"
+		if check.keyAge != nil {
+			keyAge = fmt.Sprintf("%dd", int(check.keyAge.Hours()/24))
+			if check.stale {
+				keyAge += " (stale)"
+			}
+		}
+		lastUsed := "-"
+		if check.lastUsed != nil {
+			lastUsed = check.lastUsed.UTC().Format(time.DateOnly)
+		}
+		errMessage := ""
+		if check.err != nil {
+			errMessage = check.err.Error()
+		}
+		table.AddRow([]string{check.account, check.secret, fmt.Sprint(check.valid), fmt.Sprint(check.matchesCR), keyAge, lastUsed, errMessage})
+	}
+	if err := table.Flush(); err != nil {
+		fmt.Fprintln(o.IOStreams.ErrOut, "error while flushing table: ", err.Error())
+	}
+}
+
+// remediateChecks rotates the credentials of every account with failing or stale credentials after confirmation.
+// The osdCcsAdmin credentials are only rotated when they need it.
+func (o *verifySecretsOptions) remediateChecks(checks []*secretCheck) error {
+	var names []string
+	ccs := map[string]bool{}
+	for _, check := range checks {
+		if !check.failing() && !check.stale {
+			continue
+		}
+		if _, ok := ccs[check.account]; !ok {
+			names = append(names, check.account)
+		}
+		ccs[check.account] = ccs[check.account] || check.byoc
+	}
+
+	for _, name := range names {
+		fmt.Fprintf(o.IOStreams.Out, "Rotating the credentials of account %s\n", name)
+		if !o.confirm() {
+			continue
+		}
+		if err := o.rotate(name, ccs[name]); err != nil {
+			return fmt.Errorf("failed to rotate the credentials of account %s: %w", name, err)
+		}
+		// Verify the rotated credentials
+		account, err := k8s.GetAWSAccount(context.TODO(), o.kubeCli, o.accountNamespace, name)
+		if err != nil {
+			return err
+		}
+		rotated := o.checkAccount(context.TODO(), *account)
+		for i, check := range checks {
+			for _, after := range rotated {
+				if check.account == after.account && check.secret == after.secret {
+					checks[i] = after
+				}
+			}
+		}
+	}
+
+	if len(names) > 0 {
+		o.printChecks(checks)
+	}
 	return nil
 }
 
-type awsSecret struct {
-	awsCreds *awsprovider.ClientInput
-	secret   string
+// rotateSecret runs the rotate-secret command for an Account CR. The client used to verify the credentials
+// is already initialized and can't impersonate anymore, so the rotation uses a new one.
+func (o *verifySecretsOptions) rotateSecret(accountName string, ccs bool) error {
+	lazyClient, ok := o.kubeCli.(*k8s.LazyClient)
+	if !ok {
+		return fmt.Errorf("rotating credentials requires a hive client")
+	}
+	rotate := newRotateSecretOptions(o.IOStreams, lazyClient.Clone())
+	rotate.accountCRName = accountName
+	rotate.accountNamespace = o.accountNamespace
+	rotate.profile = o.profile
+	rotate.reason = o.reason
+	rotate.updateCcsCreds = ccs
+	rotate.awsAccountTimeout = awsSdk.Int32(900)
+	return rotate.run()
 }
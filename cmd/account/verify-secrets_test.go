@@ -1,14 +1,31 @@
 package account
 
 import (
+	"bytes"
+	"errors"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamTypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	. "github.com/onsi/gomega"
+	awsv1alpha1 "github.com/openshift/aws-account-operator/api/v1alpha1"
 	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	"github.com/openshift/osdctl/cmd/common"
 	mockk8s "github.com/openshift/osdctl/cmd/hive/clusterdeployment/mock/k8s"
+	"github.com/openshift/osdctl/pkg/k8s"
+	awsprovider "github.com/openshift/osdctl/pkg/provider/aws"
+	"github.com/openshift/osdctl/pkg/provider/aws/mock"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 )
 
@@ -60,3 +77,187 @@ func TestCheckSecretsCmdComplete(t *testing.T) {
 		})
 	}
 }
+
+// newVerifySecretsTestOptions returns options with a fake client with the objects, and an AWS mock client per access key
+func newVerifySecretsTestOptions(t *testing.T, mockCtrl *gomock.Controller, objects ...client.Object) (*verifySecretsOptions, map[string]*mock.MockClient, *bytes.Buffer) {
+	scheme := runtime.NewScheme()
+	if err := awsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	ops := newVerifySecretsOptions(genericclioptions.IOStreams{Out: out, ErrOut: out},
+		fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build())
+	ops.accountNamespace = common.AWSAccountNamespace
+	ops.now = func() time.Time { return time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC) }
+
+	mocks := map[string]*mock.MockClient{}
+	for _, object := range objects {
+		if secret, ok := object.(*corev1.Secret); ok {
+			mocks[string(secret.Data["aws_access_key_id"])] = mock.NewMockClient(mockCtrl)
+		}
+	}
+	ops.newAwsClient = func(input *awsprovider.ClientInput) (awsprovider.Client, error) {
+		if input.AccessKeyID == "AKIAinvalid" {
+			return nil, errors.New("invalid credentials")
+		}
+		return mocks[input.AccessKeyID], nil
+	}
+	return ops, mocks, out
+}
+
+func testAccount(name, awsAccountID string) (*awsv1alpha1.Account, *corev1.Secret) {
+	return &awsv1alpha1.Account{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: common.AWSAccountNamespace},
+		Spec:       awsv1alpha1.AccountSpec{AwsAccountID: awsAccountID, IAMUserSecret: name + "-secret"},
+		Status:     awsv1alpha1.AccountStatus{State: "Ready"},
+	}, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name + "-secret", Namespace: common.AWSAccountNamespace},
+		Data: map[string][]byte{
+			"aws_access_key_id":     []byte("AKIA" + name),
+			"aws_secret_access_key": []byte("secret"),
+		},
+	}
+}
+
+// expectIdentity mocks the caller identity, access key and last use of the credentials of an account
+func expectIdentity(mockAWS *mock.MockClient, name, awsAccountID string, created time.Time) {
+	r := mockAWS.EXPECT()
+	r.GetCallerIdentity(gomock.Any()).Return(&sts.GetCallerIdentityOutput{
+		Account: awsSdk.String(awsAccountID),
+		Arn:     awsSdk.String("arn:aws:iam::" + awsAccountID + ":user/osdManagedAdmin-" + name),
+	}, nil)
+	r.ListAccessKeys(&iam.ListAccessKeysInput{UserName: awsSdk.String("osdManagedAdmin-" + name)}).Return(&iam.ListAccessKeysOutput{
+		AccessKeyMetadata: []iamTypes.AccessKeyMetadata{
+			{AccessKeyId: awsSdk.String("AKIAother"), CreateDate: awsSdk.Time(created.Add(-time.Hour))},
+			{AccessKeyId: awsSdk.String("AKIA" + name), CreateDate: awsSdk.Time(created)},
+		},
+	}, nil)
+	r.GetAccessKeyLastUsed(&iam.GetAccessKeyLastUsedInput{AccessKeyId: awsSdk.String("AKIA" + name)}).Return(&iam.GetAccessKeyLastUsedOutput{
+		AccessKeyLastUsed: &iamTypes.AccessKeyLastUsed{LastUsedDate: awsSdk.Time(time.Date(2025, 5, 30, 0, 0, 0, 0, time.UTC))},
+	}, nil)
+}
+
+var keyCreated = time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+
+func TestVerifySecretsRun(t *testing.T) {
+	g := NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+
+	account, secret := testAccount("foo", "111111111111")
+	ops, mocks, out := newVerifySecretsTestOptions(t, mockCtrl, account, secret)
+	ops.accountName = "foo"
+	expectIdentity(mocks["AKIAfoo"], "foo", "111111111111", keyCreated)
+
+	g.Expect(ops.run()).To(Succeed())
+	g.Expect(out.String()).To(MatchRegexp(`foo\s+foo-secret\s+true\s+true\s+31d\s+2025-05-30`))
+	g.Expect(out.String()).To(ContainSubstring("Credentials valid for foo"))
+}
+
+func TestVerifySecretsRunAll(t *testing.T) {
+	g := NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+
+	var objects []client.Object
+	for _, name := range []string{"a", "b", "c", "invalid"} {
+		account, secret := testAccount(name, "111111111111")
+		objects = append(objects, account, secret)
+	}
+	// Account without a secret yet
+	creating, _ := testAccount("new", "111111111111")
+	creating.Status.State = "Creating"
+	objects = append(objects, creating)
+	// Account whose secret is missing, which is reported without failing the verification
+	missing, _ := testAccount("missing", "111111111111")
+	objects = append(objects, missing)
+
+	ops, mocks, out := newVerifySecretsTestOptions(t, mockCtrl, objects...)
+	ops.all = true
+	ops.workers = 2
+	// b is stale, c belongs to another AWS account
+	expectIdentity(mocks["AKIAa"], "a", "111111111111", keyCreated)
+	expectIdentity(mocks["AKIAb"], "b", "111111111111", keyCreated.AddDate(-1, 0, 0))
+	expectIdentity(mocks["AKIAc"], "c", "222222222222", keyCreated)
+
+	g.Expect(ops.run()).To(MatchError("AccountCredentialError"))
+	output := out.String()
+	g.Expect(output).To(MatchRegexp(`a\s+a-secret\s+true\s+true\s+31d\s`))
+	g.Expect(output).To(MatchRegexp(`b\s+b-secret\s+true\s+true\s+396d \(stale\)`))
+	g.Expect(output).To(MatchRegexp(`c\s+c-secret\s+true\s+false`))
+	g.Expect(output).To(MatchRegexp(`invalid\s+invalid-secret\s+false\s+false\s+-\s+-\s+failed to create AWS client`))
+	g.Expect(output).NotTo(ContainSubstring("new-secret"))
+	g.Expect(output).To(ContainSubstring("Account missing doesn't have associate credentials, state Ready\n"))
+	g.Expect(output).NotTo(ContainSubstring("missing-secret"))
+	g.Expect(output).To(ContainSubstring("Some credentials are invalid"))
+}
+
+func TestVerifySecretsRemediate(t *testing.T) {
+	g := NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+
+	var objects []client.Object
+	for _, name := range []string{"a", "b", "c"} {
+		account, secret := testAccount(name, "111111111111")
+		objects = append(objects, account, secret)
+	}
+	ops, mocks, out := newVerifySecretsTestOptions(t, mockCtrl, objects...)
+	ops.all = true
+	ops.remediate = true
+
+	expectIdentity(mocks["AKIAa"], "a", "111111111111", keyCreated)
+	expectIdentity(mocks["AKIAb"], "b", "111111111111", keyCreated.AddDate(-1, 0, 0))
+	expectIdentity(mocks["AKIAc"], "c", "222222222222", keyCreated)
+	// The rotated credentials of b are verified again
+	expectIdentity(mocks["AKIAb"], "b", "111111111111", keyCreated)
+
+	// Only the rotation of b is confirmed
+	prompts := 0
+	ops.confirm = func() bool {
+		prompts++
+		return prompts == 1
+	}
+	var rotated []string
+	ops.rotate = func(accountName string, ccs bool) error {
+		g.Expect(ccs).To(BeFalse())
+		rotated = append(rotated, accountName)
+		return nil
+	}
+
+	g.Expect(ops.run()).To(MatchError("AccountCredentialError"))
+	g.Expect(prompts).To(Equal(2))
+	g.Expect(rotated).To(Equal([]string{"b"}))
+	g.Expect(out.String()).To(ContainSubstring("Rotating the credentials of account b"))
+	g.Expect(out.String()).To(ContainSubstring("Rotating the credentials of account c"))
+	g.Expect(out.String()).To(MatchRegexp(`b\s+b-secret\s+true\s+true\s+31d\s`))
+}
+
+func TestVerifySecretsRemediateRotateSecret(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(awsv1alpha1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	// The rotation reads the Account CR from the namespace the credentials were verified in
+	account, secret := testAccount("sts", "111111111111")
+	account.Namespace = "custom-namespace"
+	account.Spec.ManualSTSMode = true
+	secret.Namespace = "custom-namespace"
+	secret.Data["aws_access_key_id"] = []byte("AKIAinvalid")
+
+	out := &bytes.Buffer{}
+	ops := newVerifySecretsOptions(genericclioptions.IOStreams{Out: out, ErrOut: out},
+		k8s.NewFakeClient(fake.NewClientBuilder().WithScheme(scheme).WithObjects(account, secret)))
+	ops.accountNamespace = "custom-namespace"
+	ops.accountName = "sts"
+	ops.remediate = true
+	ops.reason = "OHSS-1"
+	ops.newAwsClient = func(*awsprovider.ClientInput) (awsprovider.Client, error) {
+		return nil, errors.New("invalid credentials")
+	}
+	ops.confirm = func() bool { return true }
+
+	// The client used for the verification is initialized, the rotation impersonates with a new one
+	g.Expect(ops.run()).To(MatchError(ContainSubstring("failed to rotate the credentials of account sts: Account sts is STS")))
+}
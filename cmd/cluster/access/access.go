@@ -9,6 +9,7 @@ import (
 	"os/exec"
 	fpath "path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	clustersmgmtv1 "github.com/openshift-online/ocm-sdk-go/clustersmgmt/v1"
@@ -40,8 +41,11 @@ const (
 	jumpContainerName = "jump"
 	jumpPodLabelKey   = "automated-break-glass-access/cluster"
 
-	// Lifespan for jump pods in seconds. Currently, PrivateLink jump pods will expire after 8 hours
-	jumpPodLifespan = 28800
+	// Kubeconfig secrets in the hosted control plane namespace of HCP clusters. The admin kubeconfig points to the
+	// public API of the cluster while the service network one points to the kube-apiserver service in the namespace,
+	// which is the only way to reach the API of PrivateLink clusters.
+	hcpAdminKubeconfigSecret          = "admin-kubeconfig"
+	hcpServiceNetworkKubeconfigSecret = "service-network-admin-kubeconfig"
 )
 
 var (
@@ -53,19 +57,25 @@ var (
 func NewCmdAccess(streams genericclioptions.IOStreams, client *k8s.LazyClient) *cobra.Command {
 	ops := newClusterAccessOptions(streams)
 	accessCmd := &cobra.Command{
-		Use:               "break-glass --cluster-id <cluster-identifier>",
-		Short:             "Emergency access to a cluster",
-		Long:              "Obtain emergency credentials to access the given cluster. You must be logged into the cluster's hive shard",
+		Use:   "break-glass --cluster-id <cluster-identifier>",
+		Short: "Emergency access to a cluster",
+		Long: "Obtain emergency credentials to access the given cluster from its hive shard, or from its management cluster for\n" +
+			"HCP clusters. PrivateLink clusters are only reachable through a jump pod created next to the kubeconfig secret.\n\n" +
+			"Every access is recorded as a session which expires after --ttl: jump pods stop on their own, a spawned shell is\n" +
+			"closed and the local kubeconfig is removed by the next osdctl command. See 'break-glass sessions'.",
 		Args:              cobra.NoArgs,
 		DisableAutoGenTag: true,
 		Run: func(_ *cobra.Command, _ []string) {
+			removeExpiredSessions(streams.ErrOut)
 			cmdutil.CheckErr(ops.accessCmdComplete())
 			cmdutil.CheckErr(ops.Run(context.Background()))
 		},
 	}
 	accessCmd.AddCommand(newCmdCleanup(client, streams))
+	accessCmd.AddCommand(newCmdSessions(streams))
 	accessCmd.Flags().StringVar(&ops.reason, "reason", "", "The reason for this command, which requires elevation, to be run (usualy an OHSS or PD ticket)")
 	accessCmd.Flags().StringVarP(&ops.clusterID, "cluster-id", "C", "", "Provide the internal ID of the cluster")
+	accessCmd.Flags().DurationVar(&ops.ttl, "ttl", defaultSessionTTL, "How long the access lasts before it expires and is cleaned up")
 	_ = accessCmd.MarkFlagRequired("reason")
 	_ = accessCmd.MarkFlagRequired("cluster-id")
 
@@ -76,6 +86,12 @@ func NewCmdAccess(streams genericclioptions.IOStreams, client *k8s.LazyClient) *
 type clusterAccessOptions struct {
 	reason    string
 	clusterID string
+	ttl       time.Duration
+
+	// hostClusterID is the hive shard or, for HCP clusters, the management cluster the kubeconfig secret was retrieved from
+	hostClusterID string
+	// sessions is the registry the access is recorded in, it is not recorded when empty
+	sessions string
 
 	genericclioptions.IOStreams
 }
@@ -83,6 +99,7 @@ type clusterAccessOptions struct {
 // newClusterAccessOptions creates a clusterAccessOptions object
 func newClusterAccessOptions(streams genericclioptions.IOStreams) clusterAccessOptions {
 	a := clusterAccessOptions{
+		ttl:       defaultSessionTTL,
 		IOStreams: streams,
 	}
 	return a
@@ -112,22 +129,30 @@ func (c *clusterAccessOptions) Readln() (string, error) {
 
 // accessCmdComplete verifies the command's invocation, returning an error if the usage is invalid
 func (c *clusterAccessOptions) accessCmdComplete() error {
-	return osdctlutil.IsValidClusterKey(c.clusterID)
-}
-
-// Run executes the 'break-glass' access subcommand
-func (c *clusterAccessOptions) Run(ctx context.Context) error {
-	// Login to hive shard
-	hive, err := osdctlutil.GetHiveCluster(c.clusterID)
+	if c.ttl <= 0 {
+		return fmt.Errorf("--ttl must be positive, got %s", c.ttl)
+	}
+	sessions, err := defaultSessionRegistryPath()
 	if err != nil {
-		return fmt.Errorf("failed to retrieve hive shard for %q: %w", c.clusterID, err)
+		return err
 	}
+	c.sessions = sessions
+	return osdctlutil.IsValidClusterKey(c.clusterID)
+}
 
-	hiveClient, err := k8s.NewAsBackplaneClusterAdmin(hive.ID(), kclient.Options{Scheme: scheme.Scheme}, c.reason, fmt.Sprintf("Elevation required to break-glass on %q cluster", c.clusterID))
-	if err != nil {
-		return fmt.Errorf("failed to login to hive shard %q: %w", hive.Name(), err)
+// recordSession records the access to the cluster in the session registry. Failing to record it is only
+// reported, as the access has already been granted at this point.
+func (c *clusterAccessOptions) recordSession(s *session) {
+	if err := recordSession(c.sessions, s); err != nil {
+		c.Errorln(fmt.Sprintf("Failed to record break-glass session: %v", err))
+		c.Errorln(fmt.Sprintf("Remember to drop the access manually: %s", s.resource()))
+		return
 	}
+	c.Println(fmt.Sprintf("Break-glass session '%s' expires at %s", s.ID, s.Expires.Local().Format(time.RFC3339)))
+}
 
+// Run executes the 'break-glass' access subcommand
+func (c *clusterAccessOptions) Run(ctx context.Context) error {
 	c.Println(fmt.Sprintf("Retrieving Kubeconfig for cluster '%s'", c.clusterID))
 
 	// Connect to ocm and grab cluster definition: user-provided cluster identifier could be any one of name, internal ID, or UUID
@@ -146,6 +171,22 @@ func (c *clusterAccessOptions) Run(ctx context.Context) error {
 	}
 	c.Println(fmt.Sprintf("Internal Cluster ID: %s", cluster.ID()))
 
+	if cluster.Hypershift().Enabled() {
+		return c.runHCP(ctx, cluster)
+	}
+
+	// Login to hive shard
+	hive, err := osdctlutil.GetHiveCluster(cluster.ID())
+	if err != nil {
+		return fmt.Errorf("failed to retrieve hive shard for %q: %w", c.clusterID, err)
+	}
+	c.hostClusterID = hive.ID()
+
+	hiveClient, err := k8s.NewAsBackplaneClusterAdmin(hive.ID(), kclient.Options{Scheme: scheme.Scheme}, c.reason, fmt.Sprintf("Elevation required to break-glass on %q cluster", c.clusterID))
+	if err != nil {
+		return fmt.Errorf("failed to login to hive shard %q: %w", hive.Name(), err)
+	}
+
 	// Retrieve the kubeconfig secret from the cluster's namespace on hive
 	ns, err := getClusterNamespace(hiveClient, cluster.ID())
 	if err != nil {
@@ -174,6 +215,52 @@ func (c *clusterAccessOptions) Run(ctx context.Context) error {
 	return c.createLocalKubeconfigAccess(cluster, kubeconfigSecret)
 }
 
+// runHCP grants access to an HCP cluster from the hosted control plane namespace on its management cluster
+func (c *clusterAccessOptions) runHCP(ctx context.Context, cluster *clustersmgmtv1.Cluster) error {
+	mc, err := osdctlutil.GetManagementCluster(cluster.ID())
+	if err != nil {
+		return fmt.Errorf("failed to retrieve management cluster for %q: %w", c.clusterID, err)
+	}
+	c.hostClusterID = mc.ID()
+
+	mcClient, err := k8s.NewAsBackplaneClusterAdmin(mc.ID(), kclient.Options{Scheme: scheme.Scheme}, c.reason, fmt.Sprintf("Elevation required to break-glass on %q cluster", c.clusterID))
+	if err != nil {
+		return fmt.Errorf("failed to login to management cluster %q: %w", mc.Name(), err)
+	}
+
+	ns, err := osdctlutil.GetHCPNamespace(cluster.ID())
+	if err != nil {
+		return err
+	}
+	return c.createHCPAccess(ctx, mcClient, cluster, ns)
+}
+
+// createHCPAccess grants access to an HCP cluster using the kubeconfig secrets of its hosted control plane namespace
+func (c *clusterAccessOptions) createHCPAccess(ctx context.Context, mcClient kclient.Client, cluster *clustersmgmtv1.Cluster, ns string) error {
+	c.Println(fmt.Sprintf("Hosted control plane namespace: %s", ns))
+
+	// The API of PrivateLink clusters is only reachable from the management cluster
+	if cluster.AWS().PrivateLink() {
+		kubeconfigSecret, err := getNamedKubeconfigSecret(mcClient, ns, hcpServiceNetworkKubeconfigSecret)
+		if err != nil {
+			return err
+		}
+		c.Println(fmt.Sprintf("Kubeconfig Secret: %s", kubeconfigSecret.Name))
+		c.Println("")
+		c.Println("Cluster is PrivateLink, and is only accessible via a jump pod on the management cluster")
+		return c.createJumpPodAccess(ctx, mcClient, cluster, kubeconfigSecret)
+	}
+
+	kubeconfigSecret, err := getNamedKubeconfigSecret(mcClient, ns, hcpAdminKubeconfigSecret)
+	if err != nil {
+		return err
+	}
+	c.Println(fmt.Sprintf("Kubeconfig Secret: %s", kubeconfigSecret.Name))
+	c.Println("")
+	c.Println("Cluster is accessible via a local Kubeconfig file")
+	return c.createLocalKubeconfigAccess(cluster, kubeconfigSecret)
+}
+
 // createJumpPodAccess grants access to a cluster by creating a pod for users to exec into
 func (c *clusterAccessOptions) createJumpPodAccess(ctx context.Context, kubeCli kclient.Client, cluster *clustersmgmtv1.Cluster, kubeconfigSecret corev1.Secret) error {
 	c.Println("Attempting to spin up a pod to use for access")
@@ -184,7 +271,11 @@ func (c *clusterAccessOptions) createJumpPodAccess(ctx context.Context, kubeCli
 		return err
 	}
 
-	c.Println(fmt.Sprintf("Jump pod created. Waiting for it to start"))
+	s := newSession(cluster.ID(), cluster.Name(), sessionMethodJumpPod, c.reason, c.ttl)
+	s.HostClusterID, s.Namespace, s.Pod = c.hostClusterID, pod.Namespace, pod.Name
+	c.recordSession(s)
+
+	c.Println("Jump pod created. Waiting for it to start")
 	c.Println("")
 
 	err = waitForJumpPod(ctx, kubeCli, pod, jumpPodPollInterval, jumpPodPollTimeout)
@@ -201,9 +292,9 @@ func (c *clusterAccessOptions) createJumpPodAccess(ctx context.Context, kubeCli
 
 // createLocalKubeconfigAccess grants access to a cluster by writing the cluster's kubeconfig file to the local filesystem and (optionally) updating the user's cli environment
 func (c *clusterAccessOptions) createLocalKubeconfigAccess(cluster *clustersmgmtv1.Cluster, kubeconfigSecret corev1.Secret) error {
-	c.Println("Retrieving kubeconfig secret from Hive")
+	c.Println(fmt.Sprintf("Retrieving kubeconfig from secret '%s'", kubeconfigSecret.Name))
 
-	kubeconfigFilePath := fpath.Join(os.TempDir(), kubeconfigSecret.Name)
+	kubeconfigFilePath := fpath.Join(os.TempDir(), kubeconfigFileName(cluster, kubeconfigSecret))
 	rawKubeconfig, found := kubeconfigSecret.Data[kubeconfigSecretKey]
 	if !found {
 		// Kubeconfig secret doesn't contain the expected key - write the obtained secret to a temp location so the user can troubleshoot or manually parse
@@ -234,7 +325,7 @@ func (c *clusterAccessOptions) createLocalKubeconfigAccess(cluster *clustersmgmt
 		c.Errorln("\nFailed to determine if the cluster is private.\nIf you're not able to access the cluster, try modifying the resulting kubeconfig according to the SOP: https://github.com/openshift/ops-sop/blob/master/v4/howto/break-glass-kubeadmin.md#for-clusters-with-private-api")
 	} else if listening == clustersmgmtv1.ListeningMethodInternal {
 		// If the cluster has a private API, it must be accessed using a special API url from one of the bastions
-		return c.createPrivateAPIAccess(rawKubeconfig, kubeconfigFilePath)
+		return c.createPrivateAPIAccess(cluster, rawKubeconfig, kubeconfigFilePath)
 	}
 
 	// Write the kubeconfig to the temp filesystem
@@ -247,6 +338,7 @@ func (c *clusterAccessOptions) createLocalKubeconfigAccess(cluster *clustersmgmt
 
 	c.Println("")
 	c.Println(fmt.Sprintf("Kubeconfig successfully written to '%s'", kubeconfigFilePath))
+	s := c.kubeconfigSession(cluster, kubeconfigFilePath)
 	c.Println("")
 
 	c.Print(fmt.Sprintf("Would you like to open a new shell that uses 'KUBECONFIG=%s'? [y/N] ", kubeconfigFilePath))
@@ -276,16 +368,30 @@ func (c *clusterAccessOptions) createLocalKubeconfigAccess(cluster *clustersmgmt
 		c.Println(fmt.Sprintf("To add this capability to other terminals, run\n\n    export KUBECONFIG=%s\n\nwherever you'd like to execute commands against this cluster", kubeconfigFilePath))
 		c.Println("When you are done, type 'exit' (or use ctl-D) to return to the original terminal")
 
-		// Spawn a new shell
-		cmd := exec.Command(shell)
+		c.Println(fmt.Sprintf("The shell is closed when the session expires at %s", s.Expires.Local().Format(time.RFC3339)))
+
+		// Spawn a new shell, hanging it up when the session expires
+		ctx, cancel := context.WithDeadline(context.Background(), s.Expires)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, shell)
+		cmd.Cancel = func() error {
+			return cmd.Process.Signal(syscall.SIGHUP)
+		}
 		cmd.Stdout = os.Stdout
 		cmd.Stdin = os.Stdin
 		cmd.Stderr = os.Stderr
 		err = cmd.Run()
-		if err != nil {
+		if ctx.Err() != nil {
+			c.Errorln(fmt.Sprintf("Break-glass session '%s' expired, the shell was closed", s.ID))
+			c.endExpiredSession(s)
+		} else if err != nil {
 			c.Errorln(fmt.Sprintf("Error while running in shell: %v", err))
 		}
 		c.Println(fmt.Sprintf("Finished executing against cluster '%s'", cluster.Name()))
+		if ctx.Err() == nil {
+			c.Errorln(fmt.Sprintf("WARNING: break-glass session '%s' is still active until %s and '%s' still grants access to the cluster.", s.ID, s.Expires.Local().Format(time.RFC3339), kubeconfigFilePath))
+			c.Errorln(fmt.Sprintf("Once you are done, drop the access with\n\n    osdctl cluster break-glass sessions cleanup --session-id %s\n", s.ID))
+		}
 	} else {
 		c.Println("Shell not updated")
 		c.Println(fmt.Sprintf("Run\n\n    export KUBECONFIG=%s\n\nin the terminal you would like to use for executing commands against '%s'", kubeconfigFilePath, cluster.Name()))
@@ -295,7 +401,7 @@ func (c *clusterAccessOptions) createLocalKubeconfigAccess(cluster *clustersmgmt
 }
 
 // createPrivateAPIAccess provides the necessary changes to access clusters with Private APIs
-func (c *clusterAccessOptions) createPrivateAPIAccess(rawKubeconfig []byte, kubeconfigFilePath string) error {
+func (c *clusterAccessOptions) createPrivateAPIAccess(cluster *clustersmgmtv1.Cluster, rawKubeconfig []byte, kubeconfigFilePath string) error {
 	c.Println("Cluster is private. Updating kubeconfig to execute commands against the rh-api")
 
 	formattedKubeconfig := clientcmdapiv1.Config{}
@@ -335,6 +441,7 @@ func (c *clusterAccessOptions) createPrivateAPIAccess(rawKubeconfig []byte, kube
 
 	c.Println("")
 	c.Println(fmt.Sprintf("Kubeconfig successfully written to '%s'", kubeconfigFilePath))
+	c.kubeconfigSession(cluster, kubeconfigFilePath)
 	c.Println("")
 	c.Println("Next steps are detailed in the Private API SOP: https://github.com/openshift/ops-sop/blob/master/v4/howto/break-glass-kubeadmin.md#for-clusters-with-private-api")
 	c.Println("")
@@ -347,6 +454,30 @@ func (c *clusterAccessOptions) createPrivateAPIAccess(rawKubeconfig []byte, kube
 	return nil
 }
 
+// kubeconfigSession records the access to the cluster through the given local kubeconfig file
+func (c *clusterAccessOptions) kubeconfigSession(cluster *clustersmgmtv1.Cluster, kubeconfigFilePath string) *session {
+	s := newSession(cluster.ID(), cluster.Name(), sessionMethodKubeconfig, c.reason, c.ttl)
+	s.Kubeconfig = kubeconfigFilePath
+	c.recordSession(s)
+	return s
+}
+
+// endExpiredSession removes the kubeconfig of an expired session and drops it from the registry
+func (c *clusterAccessOptions) endExpiredSession(s *session) {
+	registry, err := loadSessionRegistry(c.sessions)
+	if err == nil {
+		err = s.removeKubeconfig()
+	}
+	if err == nil {
+		err = registry.remove(registry.filter(func(existing *session) bool { return existing.ID == s.ID })...)
+	}
+	if err != nil {
+		c.Errorln(fmt.Sprintf("Failed to clean up break-glass session '%s': %v", s.ID, err))
+		return
+	}
+	c.Println(fmt.Sprintf("Removed '%s'", s.Kubeconfig))
+}
+
 // getKubeConfigSecret returns the first secret in the given namespace which contains the "hive.openshift.io/secret-type: kubeconfig" label
 func (c *clusterAccessOptions) getKubeConfigSecret(kubeCli kclient.Client, ns corev1.Namespace) (corev1.Secret, error) {
 	secretList := corev1.SecretList{}
@@ -368,16 +499,40 @@ func (c *clusterAccessOptions) getKubeConfigSecret(kubeCli kclient.Client, ns co
 	return secretList.Items[0], nil
 }
 
+// getNamedKubeconfigSecret returns the kubeconfig secret with the given name, making sure it holds a kubeconfig
+func getNamedKubeconfigSecret(kubeCli kclient.Client, ns, name string) (corev1.Secret, error) {
+	secret := corev1.Secret{}
+	if err := kubeCli.Get(context.TODO(), types.NamespacedName{Namespace: ns, Name: name}, &secret); err != nil {
+		return corev1.Secret{}, fmt.Errorf("failed to get kubeconfig secret '%s/%s': %w", ns, name, err)
+	}
+	if _, found := secret.Data[kubeconfigSecretKey]; !found {
+		return corev1.Secret{}, fmt.Errorf("secret '%s/%s' has no '%s' key", ns, name, kubeconfigSecretKey)
+	}
+	return secret, nil
+}
+
+// kubeconfigFileName returns the name of the local kubeconfig file of the cluster. Hive secrets are named after the
+// cluster already, while the secrets of hosted control planes have the same name for every cluster and get prefixed
+// with the cluster name, which 'cleanup' relies on.
+func kubeconfigFileName(cluster *clustersmgmtv1.Cluster, kubeconfigSecret corev1.Secret) string {
+	if kubeconfigSecret.Name == hcpAdminKubeconfigSecret || kubeconfigSecret.Name == hcpServiceNetworkKubeconfigSecret {
+		return fmt.Sprintf("%s-%s", cluster.Name(), kubeconfigSecret.Name)
+	}
+	return kubeconfigSecret.Name
+}
+
 // saveAsLocalFile writes data as a file on the local filesystem with mode 0600
 func saveAsLocalFile(data []byte, path string) error {
 	return os.WriteFile(path, data, os.FileMode(0600))
 }
 
-// createJumpPod creates a deployment on hive to access a PrivateLink cluster from.
+// createJumpPod creates a pod next to the kubeconfig secret, on hive or the management cluster, to access a PrivateLink cluster from.
 func (c *clusterAccessOptions) createJumpPod(ctx context.Context, kubeCli kclient.Client, kubeconfigSecret corev1.Secret, clusterid string) (corev1.Pod, error) {
 	name := fmt.Sprintf("jumphost-%s-%d", time.Now().Format("20060102-150405-"), (time.Now().Nanosecond() / 1000000))
 	ns := kubeconfigSecret.Namespace
 	label := map[string]string{jumpPodLabelKey: clusterid}
+	// The pod stops once the session expires, whether or not it is cleaned up
+	lifespan := int64(c.ttl.Seconds())
 
 	deploy := corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
@@ -396,13 +551,14 @@ func (c *clusterAccessOptions) createJumpPod(ctx context.Context, kubeCli kclien
 					},
 				},
 			},
-			RestartPolicy: corev1.RestartPolicyOnFailure,
+			RestartPolicy:         corev1.RestartPolicyOnFailure,
+			ActiveDeadlineSeconds: &lifespan,
 			Containers: []corev1.Container{
 				{
 					Name:    jumpContainerName,
 					Image:   jumpImage,
 					Command: []string{"/bin/sh"},
-					Args:    []string{"-c", fmt.Sprintf("sleep %d", jumpPodLifespan)},
+					Args:    []string{"-c", fmt.Sprintf("sleep %d", lifespan)},
 					Env: []corev1.EnvVar{
 						{
 							Name:  "KUBECONFIG",
@@ -15,6 +15,7 @@ import (
 	clustersmgmtv1 "github.com/openshift-online/ocm-sdk-go/clustersmgmt/v1"
 	"github.com/openshift/osdctl/pkg/k8s"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -257,6 +258,11 @@ func TestClusterAccessOptions_createJumpPod(t *testing.T) {
 			t.Errorf("Unexpected number of containers in pod: expected 1, got %d", len(pod.Spec.Containers))
 		}
 
+		// Verify the pod stops once the session expires
+		if pod.Spec.ActiveDeadlineSeconds == nil || *pod.Spec.ActiveDeadlineSeconds != int64(defaultSessionTTL.Seconds()) {
+			t.Errorf("Unexpected activeDeadlineSeconds: expected %d, got %v", int64(defaultSessionTTL.Seconds()), pod.Spec.ActiveDeadlineSeconds)
+		}
+
 		container := pod.Spec.Containers[0]
 		expectedMountPath := "/tmp"
 		if len(container.VolumeMounts) != 1 {
@@ -438,3 +444,101 @@ func TestGetKubeConfigSecret(t *testing.T) {
 		})
 	}
 }
+
+func TestClusterAccessOptions_createHCPAccess(t *testing.T) {
+	const hcpNamespace = "ocm-production-fake-cluster-id-hcp-cluster"
+
+	tests := []struct {
+		name         string
+		privateLink  bool
+		secrets      []string
+		expectErr    string
+		expectPod    bool
+		expectSecret string
+	}{
+		{
+			name:         "PrivateLink cluster through a jump pod on the management cluster",
+			privateLink:  true,
+			secrets:      []string{hcpAdminKubeconfigSecret, hcpServiceNetworkKubeconfigSecret},
+			expectPod:    true,
+			expectSecret: hcpServiceNetworkKubeconfigSecret,
+		},
+		{
+			name:         "public cluster through a local kubeconfig",
+			secrets:      []string{hcpAdminKubeconfigSecret, hcpServiceNetworkKubeconfigSecret},
+			expectSecret: hcpAdminKubeconfigSecret,
+		},
+		{
+			name:      "missing kubeconfig secret",
+			secrets:   []string{hcpServiceNetworkKubeconfigSecret},
+			expectErr: "failed to get kubeconfig secret 'ocm-production-fake-cluster-id-hcp-cluster/admin-kubeconfig'",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			require.NoError(t, corev1.AddToScheme(scheme))
+			builder := fake.NewClientBuilder().WithScheme(scheme)
+			for _, name := range test.secrets {
+				secret, _ := generateKubeconfigSecretObjectForTesting(name, hcpNamespace, kubeconfigSecretKey, "https://api.hcp-cluster.fakedomain.devshift.org:443")
+				builder = builder.WithObjects(&secret)
+			}
+			mcClient := builder.Build()
+
+			streams := genericclioptions.IOStreams{In: strings.NewReader("n\n"), Out: &bytes.Buffer{}, ErrOut: &bytes.Buffer{}}
+			access := newClusterAccessOptions(streams)
+			access.hostClusterID = "mc-1"
+			access.sessions = fpath.Join(t.TempDir(), "sessions.json")
+			oldTimeout, oldInterval := jumpPodPollTimeout, jumpPodPollInterval
+			jumpPodPollTimeout, jumpPodPollInterval = 10*time.Millisecond, time.Millisecond
+			defer func() { jumpPodPollTimeout, jumpPodPollInterval = oldTimeout, oldInterval }()
+
+			cluster := generateClusterObjectForTesting("hcp-cluster-"+time.Now().Format("150405.000000"), "fake-cluster-id", test.privateLink, false)
+			err := access.createHCPAccess(context.TODO(), mcClient, &cluster, hcpNamespace)
+			if test.expectErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectErr)
+				return
+			}
+
+			registry, regErr := loadSessionRegistry(access.sessions)
+			require.NoError(t, regErr)
+			require.Len(t, registry.Sessions, 1)
+			session := registry.Sessions[0]
+
+			pods := corev1.PodList{}
+			require.NoError(t, mcClient.List(context.TODO(), &pods))
+			if test.expectPod {
+				// The pod never starts on the fake client
+				assert.Error(t, err)
+				require.Len(t, pods.Items, 1)
+				pod := pods.Items[0]
+				assert.Equal(t, hcpNamespace, pod.Namespace)
+				assert.Equal(t, test.expectSecret, pod.Spec.Volumes[0].Secret.SecretName)
+				assert.Equal(t, sessionMethodJumpPod, session.Method)
+				assert.Equal(t, "mc-1", session.HostClusterID)
+				assert.Equal(t, pod.Name, session.Pod)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Empty(t, pods.Items)
+			expectedPath := fpath.Join(os.TempDir(), cluster.Name()+"-"+test.expectSecret)
+			defer os.Remove(expectedPath)
+			assert.FileExists(t, expectedPath)
+			assert.Equal(t, sessionMethodKubeconfig, session.Method)
+			assert.Equal(t, expectedPath, session.Kubeconfig)
+		})
+	}
+}
+
+func TestKubeconfigFileName(t *testing.T) {
+	cluster := generateClusterObjectForTesting("my-cluster", "fake-cluster-id", false, false)
+
+	hive := corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "my-cluster-0-abcde-admin-kubeconfig"}}
+	assert.Equal(t, "my-cluster-0-abcde-admin-kubeconfig", kubeconfigFileName(&cluster, hive))
+
+	hcp := corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: hcpAdminKubeconfigSecret}}
+	assert.Equal(t, "my-cluster-admin-kubeconfig", kubeconfigFileName(&cluster, hcp))
+}
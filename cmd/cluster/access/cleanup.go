@@ -16,6 +16,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes/scheme"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
 	kclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -25,10 +26,11 @@ func newCmdCleanup(client *k8s.LazyClient, streams genericclioptions.IOStreams)
 	cleanupCmd := &cobra.Command{
 		Use:               "cleanup --cluster-id <cluster-identifier>",
 		Short:             "Drop emergency access to a cluster",
-		Long:              "Relinquish emergency access from the given cluster. If the cluster is PrivateLink, it deletes\nall jump pods in the cluster's namespace (because of this, you must be logged into the hive shard\nwhen dropping access for PrivateLink clusters). For non-PrivateLink clusters, the $KUBECONFIG\nenvironment variable is unset, if applicable.",
+		Long:              "Relinquish emergency access from the given cluster. If the cluster is PrivateLink, it deletes\nall jump pods in the cluster's namespace (because of this, you must be logged into the hive shard\nwhen dropping access for classic PrivateLink clusters, HCP clusters use the management cluster).\nFor non-PrivateLink clusters, the kubeconfig files written by break-glass are removed and the\n$KUBECONFIG environment variable is unset, if applicable.",
 		Args:              cobra.NoArgs,
 		DisableAutoGenTag: true,
 		Run: func(cmd *cobra.Command, args []string) {
+			removeExpiredSessions(streams.ErrOut)
 			cmdutil.CheckErr(cleanupCmdComplete(cmd))
 			cmdutil.CheckErr(ops.Run(cmd))
 		},
@@ -54,6 +56,9 @@ type cleanupAccessOptions struct {
 	reason    string
 	clusterID string
 
+	// sessions is the registry the dropped sessions are removed from, it is not updated when empty
+	sessions string
+
 	genericclioptions.IOStreams
 	kubeCli *k8s.LazyClient
 }
@@ -91,6 +96,12 @@ func (c *cleanupAccessOptions) Readln() (string, error) {
 
 // Run executes the 'cleanup' access subcommand
 func (c *cleanupAccessOptions) Run(cmd *cobra.Command) error {
+	sessions, err := defaultSessionRegistryPath()
+	if err != nil {
+		return err
+	}
+	c.sessions = sessions
+
 	conn, err := osdctlutil.CreateConnection()
 	if err != nil {
 		return err
@@ -104,6 +115,9 @@ func (c *cleanupAccessOptions) Run(cmd *cobra.Command) error {
 		return err
 	}
 	c.Println(fmt.Sprintf("Dropping access to cluster '%s'", cluster.Name()))
+	if cluster.Hypershift().Enabled() && cluster.AWS().PrivateLink() {
+		return c.dropHCPPrivateLinkAccess(cluster)
+	}
 	if cluster.AWS().PrivateLink() {
 		return c.dropPrivateLinkAccess(cluster)
 	} else {
@@ -126,7 +140,37 @@ func (c *cleanupAccessOptions) dropPrivateLinkAccess(cluster *clustersmgmtv1.Clu
 		c.Errorln("Failed to retrieve cluster namespace")
 		return err
 	}
+	return c.deleteJumpPods(c.kubeCli, cluster, ns.Name)
+}
+
+// dropHCPPrivateLinkAccess removes access to an HCP PrivateLink cluster by deleting the jump pods in the hosted
+// control plane namespace on its management cluster.
+func (c *cleanupAccessOptions) dropHCPPrivateLinkAccess(cluster *clustersmgmtv1.Cluster) error {
+	if c.reason == "" {
+		c.Errorln("flag \"reason\" not set and is required when Cluster is PrivateLink")
+		return fmt.Errorf("flag \"reason\" not set and is required when Cluster is PrivateLink")
+	}
+
+	mc, err := osdctlutil.GetManagementCluster(cluster.ID())
+	if err != nil {
+		return fmt.Errorf("failed to retrieve management cluster for %q: %w", cluster.ID(), err)
+	}
+	mcClient, err := k8s.NewAsBackplaneClusterAdmin(mc.ID(), kclient.Options{Scheme: scheme.Scheme}, c.reason, "Elevation required to clean break-glass on PrivateLink Clusters")
+	if err != nil {
+		return fmt.Errorf("failed to login to management cluster %q: %w", mc.Name(), err)
+	}
+
+	c.Println("Cluster is an HCP PrivateLink cluster - removing jump pods in the hosted control plane namespace.")
+	ns, err := osdctlutil.GetHCPNamespace(cluster.ID())
+	if err != nil {
+		c.Errorln("Failed to retrieve hosted control plane namespace")
+		return err
+	}
+	return c.deleteJumpPods(mcClient, cluster, ns)
+}
 
+// deleteJumpPods deletes the jump pods to the cluster found in the given namespace, after confirmation
+func (c *cleanupAccessOptions) deleteJumpPods(kubeCli kclient.Client, cluster *clustersmgmtv1.Cluster, namespace string) error {
 	// Generate label selector to only target pods w/ matching jump pod label
 	labelSelector := metav1.LabelSelector{MatchLabels: map[string]string{jumpPodLabelKey: cluster.ID()}}
 	selector, err := metav1.LabelSelectorAsSelector(&labelSelector)
@@ -135,23 +179,23 @@ func (c *cleanupAccessOptions) dropPrivateLinkAccess(cluster *clustersmgmtv1.Clu
 		return err
 	}
 
-	listOpts := kclient.ListOptions{Namespace: ns.Name, LabelSelector: selector}
+	listOpts := kclient.ListOptions{Namespace: namespace, LabelSelector: selector}
 	pods := corev1.PodList{}
-	err = c.kubeCli.List(context.TODO(), &pods, &listOpts)
+	err = kubeCli.List(context.TODO(), &pods, &listOpts)
 	if err != nil {
-		c.Errorln(fmt.Sprintf("Failed to list pods in cluster namespace '%s'", ns.Name))
+		c.Errorln(fmt.Sprintf("Failed to list pods in cluster namespace '%s'", namespace))
 		return err
 	}
 
 	numPods := len(pods.Items)
 	if numPods == 0 {
-		c.Println(fmt.Sprintf("No jump pods found running in namespace '%s'.", ns.Name))
+		c.Println(fmt.Sprintf("No jump pods found running in namespace '%s'.", namespace))
 		c.Println("Access has been dropped.")
-		return nil
+		return forgetSessions(c.sessions, cluster.ID(), sessionMethodJumpPod)
 	}
 
 	c.Println("")
-	c.Println(fmt.Sprintf("This will delete %d pods in the namespace '%s'", numPods, ns.Name))
+	c.Println(fmt.Sprintf("This will delete %d pods in the namespace '%s'", numPods, namespace))
 	for _, pod := range pods.Items {
 		c.Println(fmt.Sprintf("- %s", pod.Name))
 	}
@@ -164,7 +208,7 @@ func (c *cleanupAccessOptions) dropPrivateLinkAccess(cluster *clustersmgmtv1.Clu
 	}
 	if isAffirmative(input) {
 		pod := corev1.Pod{}
-		err = c.kubeCli.DeleteAllOf(context.TODO(), &pod, &kclient.DeleteAllOfOptions{ListOptions: listOpts})
+		err = kubeCli.DeleteAllOf(context.TODO(), &pod, &kclient.DeleteAllOfOptions{ListOptions: listOpts})
 		if err != nil {
 			c.Errorln("Failed to delete pod(s)")
 			return err
@@ -176,7 +220,7 @@ func (c *cleanupAccessOptions) dropPrivateLinkAccess(cluster *clustersmgmtv1.Clu
 			// and we end up waiting for irrelevant pods. I've tried reproducing this bug in other places, but I haven't been able to
 			// figure it out. If someone does, please fix it.
 			pods := corev1.PodList{}
-			err = c.kubeCli.List(context.TODO(), &pods, &listOpts)
+			err = kubeCli.List(context.TODO(), &pods, &listOpts)
 			if err != nil || len(pods.Items) != 0 {
 				return false, err
 			}
@@ -187,6 +231,7 @@ func (c *cleanupAccessOptions) dropPrivateLinkAccess(cluster *clustersmgmtv1.Clu
 			return err
 		}
 		c.Println("Access has been dropped.")
+		return forgetSessions(c.sessions, cluster.ID(), sessionMethodJumpPod)
 	} else {
 		c.Println("Access has not been dropped.")
 	}
@@ -194,9 +239,14 @@ func (c *cleanupAccessOptions) dropPrivateLinkAccess(cluster *clustersmgmtv1.Clu
 }
 
 // dropLocalAccess removes access to a non-PrivateLink cluster.
-// Basically it just unsets KUBECONFIG if it appears to be set to the given cluster, since we can't make assumptions
-// around local files.
+// It removes the kubeconfig files recorded for the cluster by break-glass and unsets KUBECONFIG if it appears to
+// be set to the given cluster, since we can't make assumptions around other local files.
 func (c *cleanupAccessOptions) dropLocalAccess(cluster *clustersmgmtv1.Cluster) error {
+	if err := forgetSessions(c.sessions, cluster.ID(), sessionMethodKubeconfig); err != nil {
+		c.Errorln("Failed to remove the kubeconfig files of the cluster's break-glass sessions")
+		return err
+	}
+
 	c.Println("Unsetting $KUBECONFIG for cluster")
 	kubeconfigPath, found := os.LookupEnv("KUBECONFIG")
 	if !found {
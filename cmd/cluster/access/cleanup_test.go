@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"os"
+	fpath "path/filepath"
 	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/openshift/osdctl/pkg/k8s"
 	corev1 "k8s.io/api/core/v1"
@@ -135,3 +137,51 @@ func TestCleanupAccessOptions_dropPrivateLinkAccess(t *testing.T) {
 		}
 	}
 }
+
+func TestCleanupAccessOptions_deleteJumpPods(t *testing.T) {
+	const (
+		clusterid    = "fake-cluster-uuid-12345"
+		hcpNamespace = "ocm-production-fake-cluster-uuid-12345-fake-cluster"
+	)
+
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed to add corev1 to scheme: %v", err)
+	}
+	mcClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "jump", Namespace: hcpNamespace, Labels: map[string]string{jumpPodLabelKey: clusterid}}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "kube-apiserver", Namespace: hcpNamespace}},
+	).Build()
+
+	sessions := fpath.Join(t.TempDir(), "sessions.json")
+	session := newSession(clusterid, "fake-cluster", sessionMethodJumpPod, "testing", time.Hour)
+	session.HostClusterID, session.Namespace, session.Pod = "mc-1", hcpNamespace, "jump"
+	if err := recordSession(sessions, session); err != nil {
+		t.Fatalf("Failed to record session: %v", err)
+	}
+
+	streams := genericclioptions.IOStreams{In: strings.NewReader("y\n"), Out: os.Stdout, ErrOut: os.Stderr}
+	cleanupAccess := newCleanupAccessOptions(nil, streams)
+	cleanupAccess.sessions = sessions
+	cluster := generateClusterObjectForTesting("fake-cluster", clusterid, true, false)
+
+	if err := cleanupAccess.deleteJumpPods(mcClient, &cluster, hcpNamespace); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	pods := corev1.PodList{}
+	if err := mcClient.List(context.TODO(), &pods); err != nil {
+		t.Fatalf("Failed to list pods: %v", err)
+	}
+	if len(pods.Items) != 1 || pods.Items[0].Name != "kube-apiserver" {
+		t.Errorf("Expected only the kube-apiserver pod to remain, got %v", pods.Items)
+	}
+
+	registry, err := loadSessionRegistry(sessions)
+	if err != nil {
+		t.Fatalf("Failed to load sessions: %v", err)
+	}
+	if len(registry.Sessions) != 0 {
+		t.Errorf("Expected the jump pod session to be forgotten, got %d sessions", len(registry.Sessions))
+	}
+}
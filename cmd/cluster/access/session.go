@@ -0,0 +1,282 @@
+package access
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	fpath "path/filepath"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// sessionMethodKubeconfig is a session backed by a kubeconfig file on the local filesystem
+	sessionMethodKubeconfig = "kubeconfig"
+	// sessionMethodJumpPod is a session backed by a jump pod on the hive shard or the management cluster
+	sessionMethodJumpPod = "jump-pod"
+
+	// defaultSessionTTL is how long a break-glass session lasts unless --ttl is given
+	defaultSessionTTL = 8 * time.Hour
+)
+
+// session is a break-glass access to a cluster along with the resources it created
+type session struct {
+	ID          string    `json:"id"`
+	ClusterID   string    `json:"clusterID"`
+	ClusterName string    `json:"clusterName"`
+	Method      string    `json:"method"`
+	Reason      string    `json:"reason"`
+	Created     time.Time `json:"created"`
+	Expires     time.Time `json:"expires"`
+
+	// Kubeconfig is the local kubeconfig file of a kubeconfig session
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+
+	// HostClusterID, Namespace and Pod locate the jump pod of a jump-pod session. The host is the hive
+	// shard for classic clusters and the management cluster for HCP clusters.
+	HostClusterID string `json:"hostClusterID,omitempty"`
+	Namespace     string `json:"namespace,omitempty"`
+	Pod           string `json:"pod,omitempty"`
+}
+
+// UnmarshalJSON reads sessions recorded before the jump pod host was renamed, which stored it as hiveID
+func (s *session) UnmarshalJSON(data []byte) error {
+	type plainSession session
+	recorded := struct {
+		*plainSession
+		HiveID string `json:"hiveID,omitempty"`
+	}{plainSession: (*plainSession)(s)}
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return err
+	}
+	if s.HostClusterID == "" {
+		s.HostClusterID = recorded.HiveID
+	}
+	return nil
+}
+
+// newSession returns a session to the given cluster starting now and lasting ttl
+func newSession(clusterID, clusterName, method, reason string, ttl time.Duration) *session {
+	now := time.Now()
+	return &session{
+		ID:          fmt.Sprintf("%s-%s", clusterID, now.Format("20060102-150405")),
+		ClusterID:   clusterID,
+		ClusterName: clusterName,
+		Method:      method,
+		Reason:      reason,
+		Created:     now,
+		Expires:     now.Add(ttl),
+	}
+}
+
+// expired returns whether the session expired at the given time
+func (s *session) expired(now time.Time) bool {
+	return !now.Before(s.Expires)
+}
+
+// resource describes what the session created
+func (s *session) resource() string {
+	if s.Method == sessionMethodJumpPod {
+		return fmt.Sprintf("pod %s/%s on %s", s.Namespace, s.Pod, s.HostClusterID)
+	}
+	return s.Kubeconfig
+}
+
+// removeKubeconfig deletes the local kubeconfig file of the session, if any
+func (s *session) removeKubeconfig() error {
+	if s.Kubeconfig == "" {
+		return nil
+	}
+	if err := os.Remove(s.Kubeconfig); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove kubeconfig %s: %w", s.Kubeconfig, err)
+	}
+	return nil
+}
+
+// deleteJumpPod deletes the jump pod of the session from its host cluster
+func (s *session) deleteJumpPod(ctx context.Context, hostClient kclient.Client) error {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: s.Pod, Namespace: s.Namespace}}
+	if err := hostClient.Delete(ctx, pod); err != nil && !kerr.IsNotFound(err) {
+		return fmt.Errorf("failed to delete jump pod %s/%s: %w", s.Namespace, s.Pod, err)
+	}
+	return nil
+}
+
+// sessionRegistry records the break-glass sessions opened from this machine, so forgotten kubeconfigs
+// and jump pods can be found and cleaned up
+type sessionRegistry struct {
+	path string
+
+	Sessions []*session `json:"sessions"`
+}
+
+// defaultSessionRegistryPath returns the file the break-glass sessions are recorded in
+func defaultSessionRegistryPath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return fpath.Join(cacheDir, "osdctl", "break-glass", "sessions.json"), nil
+}
+
+// loadSessionRegistry reads the registry at path. A missing file is an empty registry, and an empty path
+// is a registry that is never persisted.
+func loadSessionRegistry(path string) (*sessionRegistry, error) {
+	registry := &sessionRegistry{path: path}
+	if path == "" {
+		return registry, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return registry, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read break-glass sessions %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, registry); err != nil {
+		return nil, fmt.Errorf("failed to parse break-glass sessions %s: %w", path, err)
+	}
+	return registry, nil
+}
+
+func (r *sessionRegistry) save() error {
+	if r.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(fpath.Dir(r.path), 0700); err != nil {
+		return fmt.Errorf("failed to create break-glass sessions directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal break-glass sessions: %w", err)
+	}
+
+	// Write to a temporary file first so an interrupted write never corrupts the registry
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write break-glass sessions: %w", err)
+	}
+	return os.Rename(tmp, r.path)
+}
+
+// add records a session, replacing an older session of the same cluster backed by the same resource
+func (r *sessionRegistry) add(s *session) error {
+	r.Sessions = slices.DeleteFunc(r.Sessions, func(existing *session) bool {
+		return existing.ClusterID == s.ClusterID && existing.Method == s.Method && existing.resource() == s.resource()
+	})
+	r.Sessions = append(r.Sessions, s)
+	return r.save()
+}
+
+// remove drops the given sessions from the registry
+func (r *sessionRegistry) remove(sessions ...*session) error {
+	r.Sessions = slices.DeleteFunc(r.Sessions, func(existing *session) bool {
+		return slices.Contains(sessions, existing)
+	})
+	return r.save()
+}
+
+// filter returns the sessions matching keep
+func (r *sessionRegistry) filter(keep func(*session) bool) []*session {
+	var sessions []*session
+	for _, s := range r.Sessions {
+		if keep(s) {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions
+}
+
+// recordSession adds a session to the registry at path
+func recordSession(path string, s *session) error {
+	registry, err := loadSessionRegistry(path)
+	if err != nil {
+		return err
+	}
+	return registry.add(s)
+}
+
+// forgetSessions removes the sessions of the given cluster and method from the registry at path, deleting
+// their local kubeconfig files
+func forgetSessions(path, clusterID, method string) error {
+	registry, err := loadSessionRegistry(path)
+	if err != nil {
+		return err
+	}
+	sessions := registry.filter(func(s *session) bool {
+		return s.ClusterID == clusterID && s.Method == method
+	})
+	for _, s := range sessions {
+		if err := s.removeKubeconfig(); err != nil {
+			return err
+		}
+	}
+	return registry.remove(sessions...)
+}
+
+// RemoveExpiredKubeconfigs deletes the local kubeconfig files of expired break-glass sessions. It runs before every
+// osdctl command, so it only touches local files and reports problems to errOut without failing the command.
+func RemoveExpiredKubeconfigs(errOut io.Writer) {
+	registry, ok := loadDefaultSessionRegistry(errOut)
+	if ok {
+		removeExpiredKubeconfigs(registry, errOut, time.Now())
+	}
+}
+
+// removeExpiredSessions is RemoveExpiredKubeconfigs, reminding about expired jump pods as well, which need an
+// elevated login to their host cluster to be deleted. It is run by break-glass and break-glass cleanup.
+func removeExpiredSessions(out io.Writer) {
+	registry, ok := loadDefaultSessionRegistry(out)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	removeExpiredKubeconfigs(registry, out, now)
+
+	jumpPods := registry.filter(func(s *session) bool {
+		return s.expired(now) && s.Method == sessionMethodJumpPod
+	})
+	if len(jumpPods) > 0 {
+		_, _ = fmt.Fprintf(out, "WARN: %d expired break-glass jump pod(s) are left, delete them with 'osdctl cluster break-glass sessions cleanup --reason <reason>'\n", len(jumpPods))
+	}
+}
+
+// loadDefaultSessionRegistry loads the registry at the default path, reporting a failure to out
+func loadDefaultSessionRegistry(out io.Writer) (*sessionRegistry, bool) {
+	path, err := defaultSessionRegistryPath()
+	if err != nil {
+		return nil, false
+	}
+	registry, err := loadSessionRegistry(path)
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "WARN: %v\n", err)
+		return nil, false
+	}
+	return registry, true
+}
+
+func removeExpiredKubeconfigs(registry *sessionRegistry, out io.Writer, now time.Time) {
+	expired := registry.filter(func(s *session) bool {
+		return s.expired(now) && s.Method == sessionMethodKubeconfig
+	})
+	for _, s := range expired {
+		if err := s.removeKubeconfig(); err != nil {
+			_, _ = fmt.Fprintf(out, "WARN: failed to clean up expired break-glass session %s: %v\n", s.ID, err)
+		}
+	}
+	if len(expired) > 0 {
+		if err := registry.remove(expired...); err != nil {
+			_, _ = fmt.Fprintf(out, "WARN: %v\n", err)
+		}
+	}
+}
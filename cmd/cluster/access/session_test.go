@@ -0,0 +1,289 @@
+package access
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	fpath "path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	kclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// writeKubeconfigSession returns a kubeconfig session whose kubeconfig file exists in dir
+func writeKubeconfigSession(t *testing.T, dir, clusterID string, expires time.Time) *session {
+	s := newSession(clusterID, clusterID+"-name", sessionMethodKubeconfig, "testing", time.Hour)
+	s.ID = clusterID + "-session"
+	s.Expires = expires
+	s.Kubeconfig = fpath.Join(dir, clusterID+"-kubeconfig")
+	require.NoError(t, os.WriteFile(s.Kubeconfig, []byte("kubeconfig"), 0600))
+	return s
+}
+
+func jumpPodSession(clusterID string, expires time.Time) *session {
+	s := newSession(clusterID, clusterID+"-name", sessionMethodJumpPod, "testing", time.Hour)
+	s.ID = clusterID + "-session"
+	s.Expires = expires
+	s.HostClusterID, s.Namespace, s.Pod = "hive-1", "uhc-production-"+clusterID, "jumphost-"+clusterID
+	return s
+}
+
+func TestSessionRegistry(t *testing.T) {
+	path := fpath.Join(t.TempDir(), "break-glass", "sessions.json")
+
+	registry, err := loadSessionRegistry(path)
+	require.NoError(t, err)
+	assert.Empty(t, registry.Sessions, "a missing registry is empty")
+
+	first := newSession("cluster-1", "one", sessionMethodKubeconfig, "OHSS-1", time.Hour)
+	first.Kubeconfig = "/tmp/one-kubeconfig"
+	require.NoError(t, recordSession(path, first))
+	require.NoError(t, recordSession(path, jumpPodSession("cluster-2", time.Now().Add(time.Hour))))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// A new session through the same kubeconfig replaces the previous one
+	second := newSession("cluster-1", "one", sessionMethodKubeconfig, "OHSS-2", 2*time.Hour)
+	second.Kubeconfig = "/tmp/one-kubeconfig"
+	require.NoError(t, recordSession(path, second))
+
+	registry, err = loadSessionRegistry(path)
+	require.NoError(t, err)
+	require.Len(t, registry.Sessions, 2)
+	assert.Equal(t, "cluster-2", registry.Sessions[0].ClusterID)
+	assert.Equal(t, "OHSS-2", registry.Sessions[1].Reason)
+	assert.WithinDuration(t, second.Expires, registry.Sessions[1].Expires, time.Second)
+
+	// A registry without a path is never persisted
+	require.NoError(t, recordSession("", first))
+}
+
+func TestSessionExpired(t *testing.T) {
+	s := newSession("cluster-1", "one", sessionMethodKubeconfig, "", time.Hour)
+	assert.False(t, s.expired(s.Created))
+	assert.False(t, s.expired(s.Created.Add(59*time.Minute)))
+	assert.True(t, s.expired(s.Created.Add(time.Hour)))
+}
+
+func TestForgetSessions(t *testing.T) {
+	dir := t.TempDir()
+	path := fpath.Join(dir, "sessions.json")
+	kept := writeKubeconfigSession(t, dir, "cluster-2", time.Now().Add(time.Hour))
+	dropped := writeKubeconfigSession(t, dir, "cluster-1", time.Now().Add(time.Hour))
+	for _, s := range []*session{kept, dropped, jumpPodSession("cluster-1", time.Now().Add(time.Hour))} {
+		require.NoError(t, recordSession(path, s))
+	}
+
+	require.NoError(t, forgetSessions(path, "cluster-1", sessionMethodKubeconfig))
+
+	assert.NoFileExists(t, dropped.Kubeconfig)
+	assert.FileExists(t, kept.Kubeconfig)
+	registry, err := loadSessionRegistry(path)
+	require.NoError(t, err)
+	require.Len(t, registry.Sessions, 2)
+	assert.Equal(t, "cluster-2", registry.Sessions[0].ClusterID)
+	assert.Equal(t, sessionMethodJumpPod, registry.Sessions[1].Method)
+}
+
+func TestRemoveExpiredSessions(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	path, err := defaultSessionRegistryPath()
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	active := writeKubeconfigSession(t, dir, "cluster-1", time.Now().Add(time.Hour))
+	expired := writeKubeconfigSession(t, dir, "cluster-2", time.Now().Add(-time.Minute))
+	for _, s := range []*session{active, expired, jumpPodSession("cluster-3", time.Now().Add(-time.Minute))} {
+		require.NoError(t, recordSession(path, s))
+	}
+
+	out := &bytes.Buffer{}
+	removeExpiredSessions(out)
+
+	assert.FileExists(t, active.Kubeconfig)
+	assert.NoFileExists(t, expired.Kubeconfig)
+	assert.Contains(t, out.String(), "1 expired break-glass jump pod(s) are left")
+
+	registry, err := loadSessionRegistry(path)
+	require.NoError(t, err)
+	var clusters []string
+	for _, s := range registry.Sessions {
+		clusters = append(clusters, s.ClusterID)
+	}
+	assert.Equal(t, []string{"cluster-1", "cluster-3"}, clusters, "expired jump pods stay recorded until they are deleted")
+}
+
+func TestRemoveExpiredKubeconfigs(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	path, err := defaultSessionRegistryPath()
+	require.NoError(t, err)
+
+	// Without any session nothing is reported
+	out := &bytes.Buffer{}
+	RemoveExpiredKubeconfigs(out)
+	assert.Empty(t, out.String())
+	assert.NoFileExists(t, path)
+
+	dir := t.TempDir()
+	active := writeKubeconfigSession(t, dir, "cluster-1", time.Now().Add(time.Hour))
+	expired := writeKubeconfigSession(t, dir, "cluster-2", time.Now().Add(-time.Minute))
+	for _, s := range []*session{active, expired, jumpPodSession("cluster-3", time.Now().Add(-time.Minute))} {
+		require.NoError(t, recordSession(path, s))
+	}
+
+	RemoveExpiredKubeconfigs(out)
+
+	assert.FileExists(t, active.Kubeconfig)
+	assert.NoFileExists(t, expired.Kubeconfig)
+	assert.Empty(t, out.String(), "jump pods are only reminded about by the break-glass commands")
+
+	registry, err := loadSessionRegistry(path)
+	require.NoError(t, err)
+	assert.Len(t, registry.Sessions, 2)
+}
+
+func TestSessionsList(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	ops := newSessionsOptions(genericclioptions.IOStreams{Out: &bytes.Buffer{}, ErrOut: &bytes.Buffer{}})
+	ops.sessions = fpath.Join(dir, "sessions.json")
+	ops.now = func() time.Time { return now }
+
+	require.NoError(t, ops.list())
+	assert.Contains(t, ops.Out.(*bytes.Buffer).String(), "No break-glass sessions found")
+
+	require.NoError(t, recordSession(ops.sessions, writeKubeconfigSession(t, dir, "cluster-1", now.Add(90*time.Minute))))
+	require.NoError(t, recordSession(ops.sessions, jumpPodSession("cluster-2", now.Add(-time.Minute))))
+	ops.Out = &bytes.Buffer{}
+	require.NoError(t, ops.list())
+
+	lines := strings.Split(strings.TrimSpace(ops.Out.(*bytes.Buffer).String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[1], "cluster-1-session")
+	assert.Contains(t, lines[1], "active (1h30m0s left)")
+	assert.Contains(t, lines[2], "pod uhc-production-cluster-2/jumphost-cluster-2 on hive-1")
+	assert.Contains(t, lines[2], "expired")
+}
+
+func TestSessionsCleanup(t *testing.T) {
+	tests := []struct {
+		name            string
+		all             bool
+		sessionID       string
+		reason          string
+		hiveErr         error
+		expectErr       string
+		expectRemaining []string
+		expectPods      []string
+	}{
+		{
+			name:            "expired sessions",
+			reason:          "testing",
+			expectRemaining: []string{"active-session"},
+			expectPods:      []string{"jumphost-active"},
+		},
+		{
+			name:       "all sessions",
+			all:        true,
+			reason:     "testing",
+			expectPods: []string{},
+		},
+		{
+			name:            "single session",
+			sessionID:       "expired-kubeconfig-session",
+			expectRemaining: []string{"active-session", "expired-session"},
+			expectPods:      []string{"jumphost-active", "jumphost-expired"},
+		},
+		{
+			name:            "unknown session",
+			sessionID:       "unknown",
+			expectErr:       `no break-glass session "unknown" found`,
+			expectRemaining: []string{"active-session", "expired-session", "expired-kubeconfig-session"},
+			expectPods:      []string{"jumphost-active", "jumphost-expired"},
+		},
+		{
+			name:            "jump pods need a reason",
+			expectErr:       "--reason is required",
+			expectRemaining: []string{"active-session", "expired-session", "expired-kubeconfig-session"},
+			expectPods:      []string{"jumphost-active", "jumphost-expired"},
+		},
+		{
+			name:            "hive login fails",
+			reason:          "testing",
+			hiveErr:         fmt.Errorf("not logged in"),
+			expectErr:       "1 of 2 sessions could not be cleaned up",
+			expectRemaining: []string{"active-session", "expired-session"},
+			expectPods:      []string{"jumphost-active", "jumphost-expired"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			now := time.Now()
+			active := jumpPodSession("active", now.Add(time.Hour))
+			expired := jumpPodSession("expired", now.Add(-time.Hour))
+			expiredKubeconfig := writeKubeconfigSession(t, dir, "expired-kubeconfig", now.Add(-time.Hour))
+
+			scheme := runtime.NewScheme()
+			require.NoError(t, corev1.AddToScheme(scheme))
+			hiveClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+				&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: active.Pod, Namespace: active.Namespace}},
+				&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: expired.Pod, Namespace: expired.Namespace}},
+			).Build()
+
+			ops := newSessionsOptions(genericclioptions.IOStreams{Out: &bytes.Buffer{}, ErrOut: &bytes.Buffer{}})
+			ops.sessions = fpath.Join(dir, "sessions.json")
+			ops.all, ops.sessionID, ops.reason = tt.all, tt.sessionID, tt.reason
+			ops.hostClient = func(hostClusterID string) (kclient.Client, error) {
+				assert.Equal(t, "hive-1", hostClusterID)
+				return hiveClient, tt.hiveErr
+			}
+			for _, s := range []*session{active, expired, expiredKubeconfig} {
+				require.NoError(t, recordSession(ops.sessions, s))
+			}
+
+			err := ops.cleanup(context.Background())
+			if tt.expectErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			registry, err := loadSessionRegistry(ops.sessions)
+			require.NoError(t, err)
+			remaining := []string{}
+			for _, s := range registry.Sessions {
+				remaining = append(remaining, s.ID)
+			}
+			assert.ElementsMatch(t, tt.expectRemaining, remaining)
+
+			pods := &corev1.PodList{}
+			require.NoError(t, hiveClient.List(context.Background(), pods))
+			podNames := []string{}
+			for _, pod := range pods.Items {
+				podNames = append(podNames, pod.Name)
+			}
+			assert.ElementsMatch(t, tt.expectPods, podNames)
+
+			_, statErr := os.Stat(expiredKubeconfig.Kubeconfig)
+			assert.Equal(t, slices.Contains(remaining, expiredKubeconfig.ID), statErr == nil, "the kubeconfig is removed with its session")
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package access
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/openshift/osdctl/pkg/k8s"
+	"github.com/openshift/osdctl/pkg/printer"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes/scheme"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	kclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// newCmdSessions implements the 'break-glass sessions' subcommand
+func newCmdSessions(streams genericclioptions.IOStreams) *cobra.Command {
+	sessionsCmd := &cobra.Command{
+		Use:               "sessions",
+		Short:             "Manage the break-glass sessions opened from this machine",
+		Long:              "Every break-glass access is recorded as a session with an expiry (see --ttl). Expired kubeconfig files\nare removed by the next osdctl command, jump pods on hive or the management cluster need 'sessions cleanup'.",
+		Args:              cobra.NoArgs,
+		DisableAutoGenTag: true,
+	}
+	sessionsCmd.AddCommand(newCmdSessionsList(streams))
+	sessionsCmd.AddCommand(newCmdSessionsCleanup(streams))
+	return sessionsCmd
+}
+
+// sessionsOptions contains the objects and information required to manage break-glass sessions
+type sessionsOptions struct {
+	all       bool
+	sessionID string
+	reason    string
+
+	sessions   string
+	now        func() time.Time
+	hostClient func(hostClusterID string) (kclient.Client, error)
+
+	genericclioptions.IOStreams
+}
+
+func newSessionsOptions(streams genericclioptions.IOStreams) *sessionsOptions {
+	ops := &sessionsOptions{
+		IOStreams: streams,
+		now:       time.Now,
+	}
+	ops.hostClient = func(hostClusterID string) (kclient.Client, error) {
+		return k8s.NewAsBackplaneClusterAdmin(hostClusterID, kclient.Options{Scheme: scheme.Scheme}, ops.reason, "Elevation required to clean up break-glass jump pods")
+	}
+	return ops
+}
+
+func (o *sessionsOptions) complete() error {
+	path, err := defaultSessionRegistryPath()
+	if err != nil {
+		return err
+	}
+	o.sessions = path
+	return nil
+}
+
+func newCmdSessionsList(streams genericclioptions.IOStreams) *cobra.Command {
+	ops := newSessionsOptions(streams)
+	return &cobra.Command{
+		Use:               "list",
+		Short:             "List the break-glass sessions and whether they expired",
+		Args:              cobra.NoArgs,
+		DisableAutoGenTag: true,
+		Run: func(_ *cobra.Command, _ []string) {
+			cmdutil.CheckErr(ops.complete())
+			cmdutil.CheckErr(ops.list())
+		},
+	}
+}
+
+func newCmdSessionsCleanup(streams genericclioptions.IOStreams) *cobra.Command {
+	ops := newSessionsOptions(streams)
+	cleanupCmd := &cobra.Command{
+		Use:   "cleanup",
+		Short: "Clean up expired break-glass sessions",
+		Long: "Remove the kubeconfig files and delete the jump pods of expired break-glass sessions. Use --all to also end\n" +
+			"active sessions, or --session-id to end a single one. Deleting jump pods requires you to be logged into\nOCM and a --reason to elevate on the cluster hosting them.",
+		Args:              cobra.NoArgs,
+		DisableAutoGenTag: true,
+		Run: func(_ *cobra.Command, _ []string) {
+			cmdutil.CheckErr(ops.complete())
+			cmdutil.CheckErr(ops.cleanup(context.Background()))
+		},
+	}
+	cleanupCmd.Flags().BoolVar(&ops.all, "all", false, "Also clean up sessions which have not expired yet")
+	cleanupCmd.Flags().StringVar(&ops.sessionID, "session-id", "", "Only clean up the given session, whether it expired or not")
+	cleanupCmd.Flags().StringVar(&ops.reason, "reason", "", "The reason for elevating on hive or the management cluster to delete jump pods (usualy an OHSS or PD ticket)")
+	cleanupCmd.MarkFlagsMutuallyExclusive("all", "session-id")
+	return cleanupCmd
+}
+
+// list prints every recorded session
+func (o *sessionsOptions) list() error {
+	registry, err := loadSessionRegistry(o.sessions)
+	if err != nil {
+		return err
+	}
+	if len(registry.Sessions) == 0 {
+		_, _ = fmt.Fprintln(o.Out, "No break-glass sessions found")
+		return nil
+	}
+	printSessions(o.Out, registry.Sessions, o.now())
+	return nil
+}
+
+func printSessions(out io.Writer, sessions []*session, now time.Time) {
+	p := printer.NewTablePrinter(out, 20, 1, 3, ' ')
+	p.AddRow([]string{"ID", "CLUSTER", "METHOD", "RESOURCE", "EXPIRES", "STATUS"})
+	for _, s := range sessions {
+		status := fmt.Sprintf("active (%s left)", s.Expires.Sub(now).Round(time.Minute))
+		if s.expired(now) {
+			status = "expired"
+		}
+		p.AddRow([]string{s.ID, s.ClusterName, s.Method, s.resource(), s.Expires.Local().Format(time.RFC3339), status})
+	}
+	_ = p.Flush()
+}
+
+// cleanup ends the selected sessions, removing them from the registry once their resources are gone
+func (o *sessionsOptions) cleanup(ctx context.Context) error {
+	registry, err := loadSessionRegistry(o.sessions)
+	if err != nil {
+		return err
+	}
+
+	now := o.now()
+	selected := registry.filter(func(s *session) bool {
+		if o.sessionID != "" {
+			return s.ID == o.sessionID
+		}
+		return o.all || s.expired(now)
+	})
+	if o.sessionID != "" && len(selected) == 0 {
+		return fmt.Errorf("no break-glass session %q found", o.sessionID)
+	}
+	if len(selected) == 0 {
+		_, _ = fmt.Fprintln(o.Out, "No break-glass sessions to clean up")
+		return nil
+	}
+
+	for _, s := range selected {
+		if s.Method == sessionMethodJumpPod && o.reason == "" {
+			return fmt.Errorf("session %s has a jump pod, --reason is required to delete it", s.ID)
+		}
+	}
+
+	hostClients := map[string]kclient.Client{}
+	var cleaned []*session
+	var failed int
+	for _, s := range selected {
+		if err := o.endSession(ctx, s, hostClients); err != nil {
+			_, _ = fmt.Fprintf(o.ErrOut, "Failed to clean up session %s: %v\n", s.ID, err)
+			failed++
+			continue
+		}
+		_, _ = fmt.Fprintf(o.Out, "Cleaned up session %s (%s)\n", s.ID, s.resource())
+		cleaned = append(cleaned, s)
+	}
+	if err := registry.remove(cleaned...); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d sessions could not be cleaned up", failed, len(selected))
+	}
+	return nil
+}
+
+// endSession removes the resources of a session, reusing the host cluster clients across sessions
+func (o *sessionsOptions) endSession(ctx context.Context, s *session, hostClients map[string]kclient.Client) error {
+	if s.Method != sessionMethodJumpPod {
+		return s.removeKubeconfig()
+	}
+
+	hostClient, found := hostClients[s.HostClusterID]
+	if !found {
+		var err error
+		hostClient, err = o.hostClient(s.HostClusterID)
+		if err != nil {
+			return fmt.Errorf("failed to login to cluster %q: %w", s.HostClusterID, err)
+		}
+		hostClients[s.HostClusterID] = hostClient
+	}
+	return s.deleteJumpPod(ctx, hostClient)
+}
@@ -23,6 +23,7 @@ import (
 	cmv1 "github.com/openshift-online/ocm-sdk-go/clustersmgmt/v1"
 	v1 "github.com/openshift-online/ocm-sdk-go/servicelogs/v1"
 	backplaneapi "github.com/openshift/backplane-api/pkg/client"
+	"github.com/openshift/osdctl/cmd/common"
 	"github.com/openshift/osdctl/cmd/dynatrace"
 	"github.com/openshift/osdctl/cmd/servicelog"
 	"github.com/openshift/osdctl/pkg/backplane"
@@ -67,6 +68,7 @@ type contextOptions struct {
 	jiratoken         string
 	teamIds           []string
 	regionID          string
+	reason            string
 }
 
 type contextData struct {
@@ -126,6 +128,9 @@ type contextData struct {
 	MigrationStateValue cmv1.ClusterMigrationStateValue
 
 	clusterReports *backplaneapi.ListReports
+
+	// etcd health, only collected for the long output when an elevation reason is given
+	EtcdHealth *etcdHealthReport `json:",omitempty"`
 }
 
 // newCmdContext implements the context command to show the current context of a cluster
@@ -158,6 +163,7 @@ func newCmdContext() *cobra.Command {
 	contextCmd.Flags().StringVar(&options.oauthtoken, "oauthtoken", "", fmt.Sprintf("Pass in PD oauthtoken directly. If not passed in, by default will read `pd_oauth_token` from ~/.config/%s.\nPD OAuth tokens can be generated by visiting %s", osdctlConfig.ConfigFileName, PagerDutyTokenRegistrationUrl))
 	contextCmd.Flags().StringVar(&options.usertoken, "usertoken", "", fmt.Sprintf("Pass in PD usertoken directly. If not passed in, by default will read `pd_user_token` from ~/config/%s", osdctlConfig.ConfigFileName))
 	contextCmd.Flags().StringVar(&options.jiratoken, "jiratoken", "", fmt.Sprintf("Pass in the Jira access token directly. If not passed in, by default will read `jira_token` from ~/.config/%s.\nJira access tokens can be registered by visiting %s/%s", osdctlConfig.ConfigFileName, JiraBaseURL, JiraTokenRegistrationPath))
+	contextCmd.Flags().StringVar(&options.reason, "reason", "", "Elevation reason (usually an OHSS or PD ticket). When given, the long output also shows the etcd health of classic clusters")
 	contextCmd.Flags().StringArrayVarP(&options.teamIds, "team-ids", "t", []string{}, fmt.Sprintf("Pass in PD team IDs directly to filter the PD Alerts by team. Can also be defined as `teamIds` in ~/.config/%s\nWill show all PD Alerts for all PD service IDs if none is defined", osdctlConfig.ConfigFileName))
 	return contextCmd
 }
@@ -284,6 +290,9 @@ func (o *contextOptions) printLongOutput(data *contextData, w io.Writer) {
 
 	// Print SDNtoOVN Migration Status
 	printSDNtoOVNMigrationStatus(data, w)
+
+	// Print etcd Health
+	printEtcdHealth(data, w)
 }
 
 func (o *contextOptions) printShortOutput(data *contextData, w io.Writer) {
@@ -596,6 +605,24 @@ func (o *contextOptions) generateContextData() (*contextData, []error) {
 		}
 	}
 
+	GetEtcdHealth := func() {
+		defer wg.Done()
+		defer utils.StartDelayTracker(o.verbose, "etcd Health").End()
+
+		kubeCli, kconfig, clientset, err := common.GetKubeConfigAndClient(o.clusterID, o.reason)
+		if err != nil {
+			dataErrors = append(dataErrors, fmt.Errorf("error while logging into the cluster for the etcd health: %v", err))
+			return
+		}
+		run := func(pod string, command string) (string, error) {
+			return Etcdctlhealth(kconfig, clientset, command, pod)
+		}
+		data.EtcdHealth, err = etcdHealth(kubeCli, run, etcdDefaultDefragThreshold)
+		if err != nil {
+			dataErrors = append(dataErrors, fmt.Errorf("error while getting the etcd health: %v", err))
+		}
+	}
+
 	var retrievers []func()
 
 	retrievers = append(
@@ -613,6 +640,11 @@ func (o *contextOptions) generateContextData() (*contextData, []error) {
 	)
 
 	if o.output == longOutputConfigValue {
+		// Only the long output shows the etcd health, which needs an elevated login.
+		// The etcd of HCP clusters runs on the management cluster.
+		if o.reason != "" && !o.cluster.Hypershift().Enabled() {
+			retrievers = append(retrievers, GetEtcdHealth)
+		}
 
 		GetDescription := func() {
 			defer wg.Done()
@@ -941,6 +973,15 @@ func (data *contextData) printClusterHeader(w io.Writer) {
 	fmt.Fprintln(w, strings.Repeat("=", len(clusterHeader)))
 }
 
+func printEtcdHealth(data *contextData, w io.Writer) {
+	if data.EtcdHealth == nil {
+		return
+	}
+	name := "etcd Health"
+	fmt.Fprintln(w, "\n"+delimiter+name)
+	printEtcdHealthReport(w, data.EtcdHealth)
+}
+
 func printSDNtoOVNMigrationStatus(data *contextData, w io.Writer) {
 	name := "SDN to OVN Migration Status"
 	fmt.Fprintln(w, "\n"+delimiter+name)
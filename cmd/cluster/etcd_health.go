@@ -3,6 +3,7 @@ package cluster
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -30,12 +31,6 @@ const (
 	EtcdLabelSelector       = "k8s-app=etcd"
 )
 
-var etcdctlCmd = []string{
-	"etcdctl endpoint status -w table",
-	"etcdctl endpoint health -w table",
-	"etcdctl member list -w table",
-}
-
 type LogCapture struct {
 	buffer bytes.Buffer
 }
@@ -51,16 +46,23 @@ func (capture *LogCapture) Write(p []byte) (n int, err error) {
 }
 
 type etcdHealthCheckOptions struct {
-	reason    string
-	clusterID string
+	reason          string
+	clusterID       string
+	output          string
+	defragThreshold int
 }
 
 func newCmdEtcdHealthCheck() *cobra.Command {
 	opts := etcdHealthCheckOptions{}
 	cmd := &cobra.Command{
-		Use:               "etcd-health-check --cluster-id <cluster-id> --reason <reason for escalation>",
-		Short:             "Checks the etcd components and member health",
-		Long:              `Checks etcd component health status for member replacement`,
+		Use:   "etcd-health-check --cluster-id <cluster-id> --reason <reason for escalation>",
+		Short: "Checks the etcd components and member health",
+		Long: `Checks etcd component health status for member replacement.
+
+Reports for every member its database size and how much of it is in use, whether it leads the cluster, how far
+its raft index lags behind the leader, its alarms and the WAL fsync and backend commit latency quantiles scraped
+from its metrics. Members fragmented above --defrag-threshold are recommended for a defragmentation.
+Use -o json to feed the report to other tools.`,
 		Args:              cobra.NoArgs,
 		DisableAutoGenTag: true,
 		Run: func(cmd *cobra.Command, args []string) {
@@ -70,6 +72,8 @@ func newCmdEtcdHealthCheck() *cobra.Command {
 
 	cmd.Flags().StringVarP(&opts.clusterID, "cluster-id", "C", "", "Provide the internal Cluster ID or name to perform health check on")
 	cmd.Flags().StringVar(&opts.reason, "reason", "", "Specify a reason for privilege escalation")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "", "Output format. Valid formats are ['', 'json']")
+	cmd.Flags().IntVar(&opts.defragThreshold, "defrag-threshold", etcdDefaultDefragThreshold, "Percentage of unused database space above which a defragmentation is recommended")
 
 	err := cmd.MarkFlagRequired("cluster-id")
 
@@ -88,10 +92,30 @@ func EtcdHealthCheck(opts etcdHealthCheckOptions) error {
 		}
 	}()
 
+	if opts.output != "" && opts.output != "json" {
+		return fmt.Errorf("unknown output format %q, valid formats are ['', 'json']", opts.output)
+	}
+
 	kubeCli, kconfig, clientset, err := common.GetKubeConfigAndClient(opts.clusterID, opts.reason)
 	if err != nil {
 		return err
 	}
+	run := func(pod string, command string) (string, error) {
+		return Etcdctlhealth(kconfig, clientset, command, pod)
+	}
+
+	if opts.output == "json" {
+		report, err := etcdHealth(kubeCli, run, opts.defragThreshold)
+		if err != nil {
+			return err
+		}
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
 
 	err = ControlplaneNodeStatus(kubeCli)
 	if err != nil {
@@ -108,14 +132,10 @@ func EtcdHealthCheck(opts etcdHealthCheckOptions) error {
 		return err
 	}
 
-	for _, v := range etcdctlCmd {
-		output, err := Etcdctlhealth(kconfig, clientset, v, podlist.Items[0].Name)
-		if err != nil {
-			fmt.Println(err)
-		}
-		fmt.Printf("$ %s\n", v)
-		fmt.Println(output)
-	}
+	fmt.Println("+---------------------------------------------------------------+")
+	fmt.Println("|               ETCD MEMBER METRICS                             |")
+	fmt.Println("+---------------------------------------------------------------+")
+	printEtcdHealthReport(os.Stdout, collectEtcdHealth(podlist.Items, run, opts.defragThreshold))
 
 	if unhealthyMember != "" {
 		fmt.Printf("[INFO] %s is unhealthy.\nRun \"osdctl cluster etcd-member-replace --cluster-id %s --node %s\" to replace the member \n", unhealthyMember, opts.clusterID, unhealthyMember)
@@ -123,6 +143,18 @@ func EtcdHealthCheck(opts etcdHealthCheckOptions) error {
 	return nil
 }
 
+// etcdHealth collects the health report of etcd without printing anything, for machine readable output
+func etcdHealth(kubeCli client.Client, run etcdctlRunner, defragThreshold int) (*etcdHealthReport, error) {
+	pods := &corev1.PodList{}
+	if err := kubeCli.List(context.TODO(), pods, client.InNamespace(EtcdNamespaceName), client.MatchingLabels{EtcdPodMatchLabelName: EtcdPodMatchValueName}); err != nil {
+		return nil, err
+	}
+	if len(pods.Items) == 0 {
+		return nil, fmt.Errorf("no etcd pods found in %s", EtcdNamespaceName)
+	}
+	return collectEtcdHealth(pods.Items, run, defragThreshold), nil
+}
+
 func ControlplaneNodeStatus(kubeCli client.Client) error {
 	nodeList := &corev1.NodeList{}
 	if err := kubeCli.List(context.TODO(), nodeList, client.MatchingLabels{MasterNodeLabel: ""}); err != nil {
@@ -0,0 +1,262 @@
+package cluster
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/openshift/osdctl/pkg/printer"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	etcdWALFsyncMetric      = "etcd_disk_wal_fsync_duration_seconds_bucket"
+	etcdBackendCommitMetric = "etcd_disk_backend_commit_duration_seconds_bucket"
+
+	// etcdDefaultDefragThreshold is the percentage of unused database space above which a member is defragmented
+	etcdDefaultDefragThreshold = 45
+
+	// Above these 99th percentiles etcd is known to suffer from slow disks
+	etcdWALFsyncP99Limit      = 10.0
+	etcdBackendCommitP99Limit = 25.0
+)
+
+// etcdMetricsCmd scrapes the disk latency histograms of the local member through its client endpoint,
+// authenticating with the certificates etcdctl is configured with
+const etcdMetricsCmd = `curl -s --max-time 10 --cacert "$ETCDCTL_CACERT" --cert "$ETCDCTL_CERT" --key "$ETCDCTL_KEY" %s/metrics | grep -E '^(` + etcdWALFsyncMetric + `|` + etcdBackendCommitMetric + `)'`
+
+// etcdLatency are quantiles of a latency histogram in milliseconds
+type etcdLatency struct {
+	P50 float64 `json:"p50Ms"`
+	P90 float64 `json:"p90Ms"`
+	P99 float64 `json:"p99Ms"`
+}
+
+// etcdMemberHealth is the health of an etcd member, as reported by 'etcd-health-check -o json'
+type etcdMemberHealth struct {
+	Name             string       `json:"name"`
+	ID               string       `json:"id,omitempty"`
+	Pod              string       `json:"pod"`
+	Endpoint         string       `json:"endpoint,omitempty"`
+	Healthy          bool         `json:"healthy"`
+	Leader           bool         `json:"leader"`
+	Version          string       `json:"version,omitempty"`
+	DbSize           int64        `json:"dbSize"`
+	DbSizeInUse      int64        `json:"dbSizeInUse"`
+	Fragmentation    float64      `json:"fragmentationPercent"`
+	RaftIndex        uint64       `json:"raftIndex"`
+	RaftAppliedIndex uint64       `json:"raftAppliedIndex"`
+	RaftIndexLag     uint64       `json:"raftIndexLag"`
+	Alarms           []string     `json:"alarms,omitempty"`
+	Errors           []string     `json:"errors,omitempty"`
+	WALFsync         *etcdLatency `json:"walFsyncLatency,omitempty"`
+	BackendCommit    *etcdLatency `json:"backendCommitLatency,omitempty"`
+}
+
+// etcdHealthReport is the parsed health of the etcd cluster, as reported by 'etcd-health-check -o json'
+type etcdHealthReport struct {
+	Quorum          string             `json:"quorum"`
+	QuorumSize      int                `json:"quorumSize"`
+	Healthy         int                `json:"healthyMembers"`
+	Leader          string             `json:"leader,omitempty"`
+	DefragThreshold int                `json:"defragThresholdPercent"`
+	Members         []etcdMemberHealth `json:"members"`
+	Recommendations []string           `json:"recommendations,omitempty"`
+}
+
+// collectEtcdHealth diagnoses etcd from all its pods, scrapes the disk latencies of every reachable member and
+// recommends what to do about the members which need attention
+func collectEtcdHealth(pods []corev1.Pod, run etcdctlRunner, defragThreshold int) *etcdHealthReport {
+	diagnosis := diagnoseEtcd(pods, run)
+	report := &etcdHealthReport{
+		Quorum:          diagnosis.Quorum,
+		QuorumSize:      diagnosis.quorumSize(),
+		Healthy:         len(diagnosis.healthy()),
+		DefragThreshold: defragThreshold,
+	}
+
+	leader := diagnosis.leader()
+	if leader != nil {
+		report.Leader = leader.Member.Name
+	}
+
+	var defrag []string
+	for _, m := range diagnosis.Members {
+		member := etcdMemberHealth{
+			Name:     m.Member.Name,
+			Pod:      m.Pod,
+			Endpoint: m.Endpoint,
+			Healthy:  m.healthy(),
+			Leader:   leader != nil && m == leader,
+			Alarms:   m.Alarms,
+		}
+		if m.Member.ID != 0 {
+			member.ID = strconv.FormatUint(m.Member.ID, 16)
+		}
+		if m.Status != nil {
+			member.Version = m.Status.Version
+			member.DbSize = m.Status.DbSize
+			member.DbSizeInUse = m.Status.DbSizeInUse
+			member.Fragmentation = math.Round(m.fragmentation()*1000) / 10
+			member.RaftIndex = m.Status.RaftIndex
+			member.RaftAppliedIndex = m.Status.RaftAppliedIndex
+			member.Errors = m.Status.Errors
+			if leader != nil && leader.Status.RaftIndex > m.Status.RaftIndex {
+				member.RaftIndexLag = leader.Status.RaftIndex - m.Status.RaftIndex
+			}
+			if m.Endpoint != "" {
+				if output, err := run(m.Pod, fmt.Sprintf(etcdMetricsCmd, m.Endpoint)); err == nil {
+					buckets := parseEtcdHistograms(output)
+					member.WALFsync = etcdLatencyQuantiles(buckets[etcdWALFsyncMetric])
+					member.BackendCommit = etcdLatencyQuantiles(buckets[etcdBackendCommitMetric])
+				}
+			}
+		}
+		if m.needsDefrag(float64(defragThreshold) / 100) {
+			defrag = append(defrag, m.Member.Name)
+		}
+		report.Members = append(report.Members, member)
+	}
+
+	if report.Quorum != etcdQuorumHealthy {
+		report.Recommendations = append(report.Recommendations, fmt.Sprintf("etcd is %s, run 'osdctl cluster etcd-recover' to recover it", report.Quorum))
+	}
+	if len(defrag) > 0 {
+		report.Recommendations = append(report.Recommendations, fmt.Sprintf("%s fragmented above %d%%, defragment with 'osdctl cluster etcd-recover'", strings.Join(defrag, ", "), defragThreshold))
+	}
+	for _, member := range report.Members {
+		for _, alarm := range member.Alarms {
+			report.Recommendations = append(report.Recommendations, fmt.Sprintf("%s raised the %s alarm", member.Name, alarm))
+		}
+		if member.WALFsync != nil && member.WALFsync.P99 > etcdWALFsyncP99Limit {
+			report.Recommendations = append(report.Recommendations, fmt.Sprintf("%s has a WAL fsync p99 of %.1fms (above %.0fms), its disk is too slow", member.Name, member.WALFsync.P99, etcdWALFsyncP99Limit))
+		}
+		if member.BackendCommit != nil && member.BackendCommit.P99 > etcdBackendCommitP99Limit {
+			report.Recommendations = append(report.Recommendations, fmt.Sprintf("%s has a backend commit p99 of %.1fms (above %.0fms), its disk is too slow", member.Name, member.BackendCommit.P99, etcdBackendCommitP99Limit))
+		}
+	}
+	return report
+}
+
+// etcdBucket is a cumulative bucket of a Prometheus histogram
+type etcdBucket struct {
+	upperBound float64
+	count      float64
+}
+
+// parseEtcdHistograms parses the '<name>_bucket{le="<bound>"} <count>' lines of Prometheus metrics into
+// histograms by name, summing the buckets of series with other labels
+func parseEtcdHistograms(output string) map[string][]etcdBucket {
+	counts := map[string]map[float64]float64{}
+	for _, line := range strings.Split(output, "\n") {
+		labelsStart, labelsEnd := strings.Index(line, "{"), strings.LastIndex(line, "}")
+		if labelsStart < 0 || labelsEnd < labelsStart {
+			continue
+		}
+		name := line[:labelsStart]
+		value, err := strconv.ParseFloat(strings.TrimSpace(line[labelsEnd+1:]), 64)
+		if err != nil {
+			continue
+		}
+		for _, label := range strings.Split(line[labelsStart+1:labelsEnd], ",") {
+			key, bound, found := strings.Cut(label, "=")
+			if !found || strings.TrimSpace(key) != "le" {
+				continue
+			}
+			upperBound, err := strconv.ParseFloat(strings.Trim(bound, `"`), 64)
+			if err != nil {
+				continue
+			}
+			if counts[name] == nil {
+				counts[name] = map[float64]float64{}
+			}
+			counts[name][upperBound] += value
+		}
+	}
+
+	histograms := map[string][]etcdBucket{}
+	for name, buckets := range counts {
+		for upperBound, count := range buckets {
+			histograms[name] = append(histograms[name], etcdBucket{upperBound: upperBound, count: count})
+		}
+		sort.Slice(histograms[name], func(i, j int) bool {
+			return histograms[name][i].upperBound < histograms[name][j].upperBound
+		})
+	}
+	return histograms
+}
+
+// etcdHistogramQuantile estimates a quantile from cumulative buckets like Prometheus' histogram_quantile does,
+// interpolating linearly within the bucket the quantile falls into
+func etcdHistogramQuantile(q float64, buckets []etcdBucket) float64 {
+	if len(buckets) == 0 || buckets[len(buckets)-1].count == 0 {
+		return math.NaN()
+	}
+	rank := q * buckets[len(buckets)-1].count
+	lowerBound, lowerCount := 0.0, 0.0
+	for _, bucket := range buckets {
+		if bucket.count >= rank {
+			if math.IsInf(bucket.upperBound, 1) {
+				return lowerBound
+			}
+			if bucket.count == lowerCount {
+				return bucket.upperBound
+			}
+			return lowerBound + (bucket.upperBound-lowerBound)*(rank-lowerCount)/(bucket.count-lowerCount)
+		}
+		lowerBound, lowerCount = bucket.upperBound, bucket.count
+	}
+	return lowerBound
+}
+
+// etcdLatencyQuantiles returns the quantiles of a latency histogram in seconds as milliseconds
+func etcdLatencyQuantiles(buckets []etcdBucket) *etcdLatency {
+	p50 := etcdHistogramQuantile(0.5, buckets)
+	if math.IsNaN(p50) {
+		return nil
+	}
+	toMs := func(seconds float64) float64 {
+		return math.Round(seconds*1000*100) / 100
+	}
+	return &etcdLatency{
+		P50: toMs(p50),
+		P90: toMs(etcdHistogramQuantile(0.9, buckets)),
+		P99: toMs(etcdHistogramQuantile(0.99, buckets)),
+	}
+}
+
+func formatEtcdLatency(latency *etcdLatency) string {
+	if latency == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f/%.1f/%.1f", latency.P50, latency.P90, latency.P99)
+}
+
+// printEtcdHealthReport prints the metrics of every member and the recommendations
+func printEtcdHealthReport(out io.Writer, report *etcdHealthReport) {
+	p := printer.NewTablePrinter(out, 20, 1, 3, ' ')
+	p.AddRow([]string{"MEMBER", "HEALTHY", "LEADER", "DB SIZE", "IN USE", "FRAGMENTED", "RAFT LAG", "FSYNC P50/P90/P99 MS", "COMMIT P50/P90/P99 MS", "ALARMS"})
+	for _, m := range report.Members {
+		dbSize, inUse, fragmented, lag := "-", "-", "-", "-"
+		if m.DbSize > 0 {
+			dbSize = formatEtcdBytes(m.DbSize)
+			inUse = formatEtcdBytes(m.DbSizeInUse)
+			fragmented = fmt.Sprintf("%.1f%%", m.Fragmentation)
+			lag = strconv.FormatUint(m.RaftIndexLag, 10)
+		}
+		alarms := "-"
+		if len(m.Alarms) > 0 {
+			alarms = strings.Join(m.Alarms, ",")
+		}
+		p.AddRow([]string{m.Name, strconv.FormatBool(m.Healthy), strconv.FormatBool(m.Leader), dbSize, inUse, fragmented, lag, formatEtcdLatency(m.WALFsync), formatEtcdLatency(m.BackendCommit), alarms})
+	}
+	_ = p.Flush()
+
+	_, _ = fmt.Fprintf(out, "\netcd is %s: %d of %d members healthy, %d needed for quorum\n", report.Quorum, report.Healthy, len(report.Members), report.QuorumSize)
+	for _, recommendation := range report.Recommendations {
+		_, _ = fmt.Fprintf(out, "[RECOMMENDATION] %s\n", recommendation)
+	}
+}
@@ -0,0 +1,115 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fakeEtcdMetrics = `etcd_disk_wal_fsync_duration_seconds_bucket{le="0.001"} 0
+etcd_disk_wal_fsync_duration_seconds_bucket{le="0.002"} 50
+etcd_disk_wal_fsync_duration_seconds_bucket{le="0.004"} 90
+etcd_disk_wal_fsync_duration_seconds_bucket{le="0.008"} 99
+etcd_disk_wal_fsync_duration_seconds_bucket{le="0.016"} 100
+etcd_disk_wal_fsync_duration_seconds_bucket{le="+Inf"} 100
+etcd_disk_backend_commit_duration_seconds_bucket{le="0.008"} 20
+etcd_disk_backend_commit_duration_seconds_bucket{le="0.016"} 100
+etcd_disk_backend_commit_duration_seconds_bucket{le="0.032"} 180
+etcd_disk_backend_commit_duration_seconds_bucket{le="0.064"} 200
+etcd_disk_backend_commit_duration_seconds_bucket{le="+Inf"} 200
+`
+
+func TestParseEtcdHistograms(t *testing.T) {
+	histograms := parseEtcdHistograms(fakeEtcdMetrics + `etcd_disk_wal_fsync_duration_seconds_bucket{instance="b",le="0.002"} 10
+# HELP etcd_disk_wal_fsync_duration_seconds The latency distributions of fsync called by WAL.
+etcd_disk_wal_fsync_duration_seconds_sum 0.5
+`)
+
+	require.Len(t, histograms, 2)
+	fsync := histograms[etcdWALFsyncMetric]
+	require.Len(t, fsync, 6)
+	assert.Equal(t, etcdBucket{upperBound: 0.001, count: 0}, fsync[0])
+	assert.Equal(t, etcdBucket{upperBound: 0.002, count: 60}, fsync[1], "the buckets of all series are summed")
+	assert.True(t, math.IsInf(fsync[5].upperBound, 1))
+}
+
+func TestEtcdHistogramQuantile(t *testing.T) {
+	buckets := []etcdBucket{{0.001, 0}, {0.002, 50}, {0.004, 90}, {0.008, 99}, {0.016, 100}, {math.Inf(1), 100}}
+
+	assert.InDelta(t, 0.002, etcdHistogramQuantile(0.5, buckets), 1e-9)
+	assert.InDelta(t, 0.004, etcdHistogramQuantile(0.9, buckets), 1e-9)
+	assert.InDelta(t, 0.003, etcdHistogramQuantile(0.7, buckets), 1e-9)
+	assert.InDelta(t, 0.008, etcdHistogramQuantile(0.99, buckets), 1e-9)
+
+	// Values in the +Inf bucket are reported at the highest finite bound
+	assert.InDelta(t, 0.016, etcdHistogramQuantile(0.99, []etcdBucket{{0.016, 10}, {math.Inf(1), 100}}), 1e-9)
+	assert.True(t, math.IsNaN(etcdHistogramQuantile(0.99, nil)))
+	assert.Nil(t, etcdLatencyQuantiles([]etcdBucket{{0.001, 0}, {math.Inf(1), 0}}))
+}
+
+func TestCollectEtcdHealth(t *testing.T) {
+	etcd := newFakeEtcd("master-2")
+	etcd.dbSize, etcd.dbSizeInUse = 200*1024*1024, 50*1024*1024
+	etcd.alarms = "memberID:2 alarm:NOSPACE\n"
+	etcd.metrics = fakeEtcdMetrics
+
+	report := collectEtcdHealth(etcd.pods(), etcd.run, etcdDefaultDefragThreshold)
+
+	assert.Equal(t, etcdQuorumDegraded, report.Quorum)
+	assert.Equal(t, 2, report.Healthy)
+	assert.Equal(t, 2, report.QuorumSize)
+	assert.Equal(t, "master-0", report.Leader)
+	require.Len(t, report.Members, 3)
+
+	leader, follower, down := report.Members[0], report.Members[1], report.Members[2]
+	assert.True(t, leader.Leader)
+	assert.Equal(t, "1", leader.ID)
+	assert.Equal(t, 75.0, leader.Fragmentation)
+	assert.Equal(t, uint64(0), leader.RaftIndexLag)
+	assert.Equal(t, uint64(101), leader.RaftIndex)
+	assert.Equal(t, &etcdLatency{P50: 2, P90: 4, P99: 8}, leader.WALFsync)
+	assert.Equal(t, &etcdLatency{P50: 16, P90: 32, P99: 60.8}, leader.BackendCommit)
+
+	assert.False(t, follower.Leader)
+	assert.Equal(t, []string{"NOSPACE"}, follower.Alarms)
+
+	assert.False(t, down.Healthy)
+	assert.Nil(t, down.WALFsync)
+	assert.Zero(t, down.DbSize)
+
+	assert.Equal(t, []string{
+		"etcd is degraded, run 'osdctl cluster etcd-recover' to recover it",
+		"master-0, master-1 fragmented above 45%, defragment with 'osdctl cluster etcd-recover'",
+		"master-0 has a backend commit p99 of 60.8ms (above 25ms), its disk is too slow",
+		"master-1 raised the NOSPACE alarm",
+		"master-1 has a backend commit p99 of 60.8ms (above 25ms), its disk is too slow",
+	}, report.Recommendations)
+
+	out := &bytes.Buffer{}
+	printEtcdHealthReport(out, report)
+	assert.Contains(t, out.String(), "2.0/4.0/8.0")
+	assert.Contains(t, out.String(), "etcd is degraded: 2 of 3 members healthy, 2 needed for quorum")
+	assert.Contains(t, out.String(), "[RECOMMENDATION] master-1 raised the NOSPACE alarm")
+
+	data, err := json.Marshal(report)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"walFsyncLatency":{"p50Ms":2,"p90Ms":4,"p99Ms":8}`)
+	assert.Contains(t, string(data), `"fragmentationPercent":75`)
+}
+
+func TestCollectEtcdHealthRaftLag(t *testing.T) {
+	etcd := newFakeEtcd()
+	report := collectEtcdHealth(etcd.pods(), etcd.run, etcdDefaultDefragThreshold)
+
+	require.Len(t, report.Members, 3)
+	assert.Equal(t, etcdQuorumHealthy, report.Quorum)
+	assert.Empty(t, report.Recommendations)
+	// The fake members are ahead of the leader, which is never reported as a negative lag
+	for _, m := range report.Members {
+		assert.Zero(t, m.RaftIndexLag)
+	}
+}
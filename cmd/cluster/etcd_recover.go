@@ -0,0 +1,472 @@
+package cluster
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/osdctl/cmd/common"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	etcdUnsupportedOverrideKey = "useUnsupportedUnsafeNonHANonProductionUnstableEtcd"
+	etcdBackupDir              = "/var/lib/etcd"
+
+	etcdReplaceMemberDocs = "https://docs.openshift.com/container-platform/latest/backup_and_restore/control_plane_backup_and_restore/replacing-unhealthy-etcd-member.html"
+	etcdRestoreDocs       = "https://docs.openshift.com/container-platform/latest/backup_and_restore/control_plane_backup_and_restore/disaster_recovery/scenario-2-restoring-cluster-state.html"
+)
+
+var etcdRejoinPollInterval = 30 * time.Second
+
+// etcdSnapshotStatus is the output of 'etcdctl snapshot status -w json'
+type etcdSnapshotStatus struct {
+	Hash      uint32 `json:"hash"`
+	Revision  int64  `json:"revision"`
+	TotalKey  int    `json:"totalKey"`
+	TotalSize int64  `json:"totalSize"`
+}
+
+type etcdRecoverOptions struct {
+	clusterID       string
+	reason          string
+	transcriptPath  string
+	defragThreshold int
+	rejoinTimeout   time.Duration
+
+	out        io.Writer
+	transcript io.Writer
+	in         *bufio.Reader
+	kubeCli    client.Client
+	clientset  kubernetes.Interface
+	run        etcdctlRunner
+	now        func() time.Time
+}
+
+func newCmdEtcdRecover() *cobra.Command {
+	opts := &etcdRecoverOptions{now: time.Now}
+	recoverCmd := &cobra.Command{
+		Use:   "etcd-recover --cluster-id <cluster-identifier> --reason <reason for escalation>",
+		Short: "Guided recovery of an unhealthy etcd cluster",
+		Long: `Diagnoses the etcd quorum from every etcd pod and walks through the documented procedure for it:
+
+  healthy      defragment bloated members (followers first, the leader last) and disarm NOSPACE alarms
+  degraded     take and verify a snapshot, then replace the unhealthy members one at a time, disabling the
+               quorum guard through unsupportedConfigOverrides for the duration of each replacement
+  lost quorum  take and verify a snapshot from a reachable member and print the restore procedure, which has
+               to be run on the control plane nodes
+
+Every change is confirmed first and the whole session is written to a transcript file.`,
+		Example: `  # Diagnose etcd and recover it, writing the transcript to the current directory
+  osdctl cluster etcd-recover --cluster-id ${CLUSTER_ID} --reason OHSS-1234`,
+		Args:              cobra.NoArgs,
+		DisableAutoGenTag: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(opts.complete())
+			cmdutil.CheckErr(opts.Run())
+		},
+	}
+	recoverCmd.Flags().StringVarP(&opts.clusterID, "cluster-id", "C", "", "Provide internal Cluster ID")
+	recoverCmd.Flags().StringVar(&opts.reason, "reason", "", "The reason for this command, which requires elevation, to be run (usually an OHSS or PD ticket)")
+	recoverCmd.Flags().StringVar(&opts.transcriptPath, "transcript", "", "File the transcript of the recovery is written to (default etcd-recover-<cluster-id>-<timestamp>.log)")
+	recoverCmd.Flags().IntVar(&opts.defragThreshold, "defrag-threshold", etcdDefaultDefragThreshold, "Percentage of unused database space above which a member is defragmented")
+	recoverCmd.Flags().DurationVar(&opts.rejoinTimeout, "rejoin-timeout", 20*time.Minute, "How long to wait for a replaced member to rejoin the cluster")
+	_ = recoverCmd.MarkFlagRequired("cluster-id")
+	_ = recoverCmd.MarkFlagRequired("reason")
+	return recoverCmd
+}
+
+func (o *etcdRecoverOptions) complete() error {
+	if o.defragThreshold <= 0 || o.defragThreshold > 100 {
+		return fmt.Errorf("--defrag-threshold must be a percentage between 1 and 100, got %d", o.defragThreshold)
+	}
+	if o.transcriptPath == "" {
+		o.transcriptPath = fmt.Sprintf("etcd-recover-%s-%s.log", o.clusterID, o.now().Format("20060102-150405"))
+	}
+	return nil
+}
+
+func (o *etcdRecoverOptions) Run() error {
+	transcript, err := os.OpenFile(o.transcriptPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open transcript: %w", err)
+	}
+	defer transcript.Close()
+	o.transcript = transcript
+	o.out = io.MultiWriter(os.Stdout, transcript)
+	o.in = bufio.NewReader(os.Stdin)
+
+	o.printf("etcd recovery of cluster %s started at %s (reason: %s)\n", o.clusterID, o.now().Format(time.RFC3339), o.reason)
+	kubeCli, kconfig, clientset, err := common.GetKubeConfigAndClient(o.clusterID, o.reason, "Recovering etcd using osdctl")
+	if err != nil {
+		return err
+	}
+	o.kubeCli, o.clientset = kubeCli, clientset
+	o.run = func(pod string, command string) (string, error) {
+		return Etcdctlhealth(kconfig, clientset, command, pod)
+	}
+
+	err = o.recover(context.Background())
+	if err != nil {
+		o.printf("etcd recovery failed at %s: %v\n", o.now().Format(time.RFC3339), err)
+	} else {
+		o.printf("etcd recovery finished at %s\n", o.now().Format(time.RFC3339))
+	}
+	fmt.Printf("The transcript was written to %s\n", o.transcriptPath)
+	return err
+}
+
+func (o *etcdRecoverOptions) printf(format string, args ...interface{}) {
+	_, _ = fmt.Fprintf(o.out, format, args...)
+}
+
+// confirm asks the question and records the answer in the transcript
+func (o *etcdRecoverOptions) confirm(question string) bool {
+	o.printf("%s [y/N] ", question)
+	answer, _ := o.in.ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if o.transcript != nil {
+		_, _ = fmt.Fprintln(o.transcript, answer)
+	}
+	return strings.EqualFold(answer, "y") || strings.EqualFold(answer, "yes")
+}
+
+// exec runs a command which changes etcd, recording it and its output in the transcript
+func (o *etcdRecoverOptions) exec(pod string, command string) (string, error) {
+	o.printf("$ %s  # in pod %s\n", command, pod)
+	output, err := o.run(pod, command)
+	if output != "" {
+		o.printf("%s\n", strings.TrimRight(output, "\n"))
+	}
+	if err != nil {
+		o.printf("[ERROR] %v\n", err)
+	}
+	return output, err
+}
+
+// diagnose collects and prints the state of etcd from all etcd pods
+func (o *etcdRecoverOptions) diagnose(ctx context.Context) (*etcdDiagnosis, error) {
+	pods, err := o.clientset.CoreV1().Pods(EtcdNamespaceName).List(ctx, metav1.ListOptions{LabelSelector: EtcdLabelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the etcd pods: %w", err)
+	}
+	if len(pods.Items) == 0 {
+		return nil, fmt.Errorf("no etcd pods found in %s", EtcdNamespaceName)
+	}
+	diagnosis := diagnoseEtcd(pods.Items, o.run)
+	o.printf("\n")
+	printEtcdDiagnosis(o.out, diagnosis)
+	o.printf("\n")
+	return diagnosis, nil
+}
+
+// recover runs the procedure matching the quorum state of etcd
+func (o *etcdRecoverOptions) recover(ctx context.Context) error {
+	diagnosis, err := o.diagnose(ctx)
+	if err != nil {
+		return err
+	}
+
+	if diagnosis.Quorum == etcdQuorumLost {
+		return o.recoverLostQuorum(diagnosis)
+	}
+
+	if err := o.checkQuorumGuard(diagnosis); err != nil {
+		return err
+	}
+
+	if diagnosis.Quorum == etcdQuorumDegraded {
+		if diagnosis, err = o.replaceUnhealthyMembers(ctx, diagnosis); err != nil {
+			return err
+		}
+	}
+	if diagnosis.Quorum != etcdQuorumHealthy {
+		return fmt.Errorf("etcd is still %s", diagnosis.Quorum)
+	}
+	return o.defragment(ctx, diagnosis)
+}
+
+// quorumGuardDisabled returns whether the unsupported override disabling the etcd quorum guard is set
+func quorumGuardDisabled(etcd *operatorv1.Etcd) bool {
+	if len(etcd.Spec.UnsupportedConfigOverrides.Raw) == 0 {
+		return false
+	}
+	overrides := map[string]interface{}{}
+	if err := json.Unmarshal(etcd.Spec.UnsupportedConfigOverrides.Raw, &overrides); err != nil {
+		return false
+	}
+	disabled, _ := overrides[etcdUnsupportedOverrideKey].(bool)
+	return disabled
+}
+
+// checkQuorumGuard offers to re-enable a quorum guard left disabled, e.g. by an interrupted member replacement
+func (o *etcdRecoverOptions) checkQuorumGuard(diagnosis *etcdDiagnosis) error {
+	etcd := &operatorv1.Etcd{}
+	if err := o.kubeCli.Get(context.TODO(), client.ObjectKey{Name: "cluster"}, etcd); err != nil {
+		return fmt.Errorf("failed to get the etcd operator configuration: %w", err)
+	}
+	if !quorumGuardDisabled(etcd) {
+		return nil
+	}
+
+	o.printf("[WARNING] The etcd quorum guard is disabled through unsupportedConfigOverrides.%s\n", etcdUnsupportedOverrideKey)
+	if diagnosis.Quorum != etcdQuorumHealthy {
+		o.printf("[INFO] It is re-enabled once the unhealthy members are replaced\n")
+		return nil
+	}
+	if o.confirm("etcd is healthy, re-enable the quorum guard?") {
+		return o.setQuorumGuard(true)
+	}
+	return nil
+}
+
+// setQuorumGuard enables or disables the etcd quorum guard through unsupportedConfigOverrides
+func (o *etcdRecoverOptions) setQuorumGuard(enabled bool) error {
+	patch := EtcdQuorumTurnOffPatch
+	if enabled {
+		patch = EtcdQuorumTurnOnPatch
+	}
+	o.printf("$ oc patch etcd cluster --type=merge -p '%s'\n", patch)
+	if err := patchEtcd(o.kubeCli, patch); err != nil {
+		return fmt.Errorf("failed to patch the etcd operator configuration: %w", err)
+	}
+	return nil
+}
+
+// backup takes a snapshot from the given member and verifies it can be read back
+func (o *etcdRecoverOptions) backup(member *etcdMemberState) error {
+	path := fmt.Sprintf("%s/osdctl-recover-snapshot-%s.db", etcdBackupDir, o.now().Format("20060102-150405"))
+	o.printf("A verified snapshot is required before changing etcd. It is written to %s on node %s.\n", path, member.Member.Name)
+	if !o.confirm(fmt.Sprintf("Take a snapshot of member %s?", member.Member.Name)) {
+		return fmt.Errorf("recovery cancelled: a verified snapshot is required before changing etcd")
+	}
+
+	if _, err := o.exec(member.Pod, fmt.Sprintf("etcdctl snapshot save %s --endpoints=%s", path, member.Endpoint)); err != nil {
+		return fmt.Errorf("failed to take a snapshot of member %s: %w", member.Member.Name, err)
+	}
+	output, err := o.exec(member.Pod, fmt.Sprintf("etcdctl snapshot status %s -w json 2>/dev/null", path))
+	if err != nil {
+		return fmt.Errorf("failed to verify the snapshot %s: %w", path, err)
+	}
+	status := etcdSnapshotStatus{}
+	if err := json.Unmarshal([]byte(output), &status); err != nil {
+		return fmt.Errorf("failed to verify the snapshot %s: %w", path, err)
+	}
+	if status.TotalKey == 0 || status.Revision == 0 {
+		return fmt.Errorf("the snapshot %s is empty (revision %d, %d keys)", path, status.Revision, status.TotalKey)
+	}
+	o.printf("[INFO] Snapshot %s verified: revision %d, %d keys, %s, hash %x\n", path, status.Revision, status.TotalKey, formatEtcdBytes(status.TotalSize), status.Hash)
+	return nil
+}
+
+// recoverLostQuorum secures a snapshot if any member is reachable and prints the documented restore procedure.
+// Restoring runs scripts on the control plane nodes, which is not possible through backplane.
+func (o *etcdRecoverOptions) recoverLostQuorum(diagnosis *etcdDiagnosis) error {
+	o.printf("[ERROR] etcd lost quorum: the cluster has to be restored from a snapshot\n")
+
+	// The reachable member with the most recent data is the best recovery host
+	var recovery *etcdMemberState
+	for _, m := range diagnosis.Members {
+		if m.Status != nil && (recovery == nil || m.Status.RaftIndex > recovery.Status.RaftIndex) {
+			recovery = m
+		}
+	}
+	recoveryHost := "<recovery control plane node>"
+	if recovery != nil {
+		recoveryHost = recovery.Member.Name
+		if err := o.backup(recovery); err != nil {
+			o.printf("[WARNING] %v\n", err)
+			o.printf("[WARNING] Use the latest backup taken by cluster-backup.sh instead\n")
+		}
+	} else {
+		o.printf("[WARNING] No member is reachable to take a snapshot from, use the latest backup taken by cluster-backup.sh\n")
+	}
+
+	o.printf(`
+Restore procedure (%s):
+  1. Copy the snapshot and the matching static_kuberesources archive to /home/core/assets/backup on %s
+  2. On every other control plane node, stop the static pods and move the etcd data away:
+       sudo mv -v /etc/kubernetes/manifests/etcd-pod.yaml /tmp
+       sudo mv -v /etc/kubernetes/manifests/kube-apiserver-pod.yaml /tmp
+       sudo mv -v /etc/kubernetes/manifests/kube-controller-manager-pod.yaml /tmp
+       sudo mv -v /etc/kubernetes/manifests/kube-scheduler-pod.yaml /tmp
+       sudo mv -v /var/lib/etcd/ /tmp
+  3. On %s, restore the snapshot:
+       sudo -E /usr/local/bin/cluster-restore.sh /home/core/assets/backup
+  4. Restart the kubelet on every control plane node and approve the pending CSRs
+  5. Force the redeployment of etcd and of the control plane:
+       oc patch etcd cluster --type=merge -p '{"spec": {"forceRedeploymentReason": "recovery-'"$(date --rfc-3339=ns)"'"}}'
+       oc patch kubeapiserver cluster --type=merge -p '{"spec": {"forceRedeploymentReason": "recovery-'"$(date --rfc-3339=ns)"'"}}'
+       oc patch kubecontrollermanager cluster --type=merge -p '{"spec": {"forceRedeploymentReason": "recovery-'"$(date --rfc-3339=ns)"'"}}'
+       oc patch kubescheduler cluster --type=merge -p '{"spec": {"forceRedeploymentReason": "recovery-'"$(date --rfc-3339=ns)"'"}}'
+  6. Run this command again to verify etcd is healthy
+`, etcdRestoreDocs, recoveryHost, recoveryHost)
+	return fmt.Errorf("etcd lost quorum and has to be restored following the procedure above")
+}
+
+// replaceUnhealthyMembers replaces the unhealthy members one at a time, as long as etcd keeps its quorum
+func (o *etcdRecoverOptions) replaceUnhealthyMembers(ctx context.Context, diagnosis *etcdDiagnosis) (*etcdDiagnosis, error) {
+	if err := o.backup(diagnosis.leader()); err != nil {
+		return nil, err
+	}
+
+	replaced := map[string]bool{}
+	for diagnosis.Quorum == etcdQuorumDegraded {
+		member := diagnosis.unhealthy()[0]
+		if replaced[member.Member.Name] {
+			return nil, fmt.Errorf("member %s is still unhealthy after its replacement", member.Member.Name)
+		}
+		replaced[member.Member.Name] = true
+
+		if err := o.replaceMember(ctx, diagnosis, member); err != nil {
+			return nil, err
+		}
+		var err error
+		if diagnosis, err = o.diagnose(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return diagnosis, nil
+}
+
+// replaceMember removes an unhealthy member and has the etcd operator redeploy it on its node
+func (o *etcdRecoverOptions) replaceMember(ctx context.Context, diagnosis *etcdDiagnosis, member *etcdMemberState) error {
+	node, err := o.clientset.CoreV1().Nodes().Get(ctx, member.Member.Name, metav1.GetOptions{})
+	if err != nil || !nodeReady(node) {
+		o.printf("[ERROR] The node of member %s is missing or not ready, so the member cannot be redeployed on it.\n", member.Member.Name)
+		o.printf("Replace the control plane machine instead, see %s\n", etcdReplaceMemberDocs)
+		return fmt.Errorf("member %s needs its control plane machine replaced", member.Member.Name)
+	}
+
+	memberID := strconv.FormatUint(member.Member.ID, 16)
+	healthy := diagnosis.leader()
+	o.printf("Member %s (%s) will be replaced:\n", member.Member.Name, memberID)
+	o.printf("  - remove the member from the etcd cluster\n")
+	o.printf("  - disable the quorum guard through unsupportedConfigOverrides\n")
+	o.printf("  - delete the etcd-peer, etcd-serving and etcd-serving-metrics secrets of node %s\n", member.Member.Name)
+	o.printf("  - force the redeployment of etcd and wait up to %s for the member to rejoin\n", o.rejoinTimeout)
+	o.printf("  - re-enable the quorum guard\n")
+	if !o.confirm(fmt.Sprintf("Replace member %s?", member.Member.Name)) {
+		return fmt.Errorf("recovery cancelled: member %s was not replaced", member.Member.Name)
+	}
+
+	if _, err := o.exec(healthy.Pod, "etcdctl member remove "+memberID); err != nil {
+		return fmt.Errorf("failed to remove member %s: %w", member.Member.Name, err)
+	}
+
+	if err := o.setQuorumGuard(false); err != nil {
+		return err
+	}
+	// Never leave the quorum guard disabled, whatever happens next
+	defer func() {
+		if err := o.setQuorumGuard(true); err != nil {
+			o.printf("[ERROR] Failed to re-enable the quorum guard, run: oc patch etcd cluster --type=merge -p '%s'\n", EtcdQuorumTurnOnPatch)
+		}
+	}()
+
+	for _, prefix := range secrets {
+		name := prefix + member.Member.Name
+		o.printf("$ oc delete secret -n %s %s\n", EtcdNamespaceName, name)
+		err := o.clientset.CoreV1().Secrets(EtcdNamespaceName).Delete(ctx, name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete secret %s: %w", name, err)
+		}
+	}
+
+	patch := fmt.Sprintf(EtcdForceRedeployPatch, o.now().Format(time.RFC3339Nano))
+	o.printf("$ oc patch etcd cluster --type=merge -p '%s'\n", patch)
+	if err := patchEtcd(o.kubeCli, patch); err != nil {
+		return fmt.Errorf("failed to force the redeployment of etcd: %w", err)
+	}
+
+	o.printf("[INFO] Waiting for member %s to rejoin\n", member.Member.Name)
+	return wait.PollUntilContextTimeout(ctx, etcdRejoinPollInterval, o.rejoinTimeout, false, func(ctx context.Context) (bool, error) {
+		pods, err := o.clientset.CoreV1().Pods(EtcdNamespaceName).List(ctx, metav1.ListOptions{LabelSelector: EtcdLabelSelector})
+		if err != nil {
+			return false, nil
+		}
+		for _, m := range diagnoseEtcd(pods.Items, o.run).Members {
+			if m.Member.Name == member.Member.Name && m.healthy() {
+				o.printf("[INFO] Member %s rejoined the cluster\n", member.Member.Name)
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+}
+
+func nodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// defragment defragments the bloated members one at a time, the leader last, and disarms the NOSPACE alarms
+func (o *etcdRecoverOptions) defragment(ctx context.Context, diagnosis *etcdDiagnosis) error {
+	threshold := float64(o.defragThreshold) / 100
+	var members []*etcdMemberState
+	var leader *etcdMemberState
+	for _, m := range diagnosis.Members {
+		if !m.needsDefrag(threshold) {
+			continue
+		}
+		if m.Member.ID == diagnosis.Leader {
+			leader = m
+			continue
+		}
+		members = append(members, m)
+	}
+	if leader != nil {
+		members = append(members, leader)
+	}
+	alarms := diagnosis.alarms()
+
+	if len(members) == 0 && len(alarms) == 0 {
+		o.printf("[INFO] etcd is healthy and no member is fragmented above %d%%, nothing to do\n", o.defragThreshold)
+		return nil
+	}
+
+	if len(members) > 0 {
+		o.printf("The following members are fragmented above %d%% and are defragmented one at a time, the leader last:\n", o.defragThreshold)
+		for _, m := range members {
+			o.printf("  - %s: %s in use of %s\n", m.Member.Name, formatEtcdBytes(m.Status.DbSizeInUse), formatEtcdBytes(m.Status.DbSize))
+		}
+		if !o.confirm("Defragment these members?") {
+			return fmt.Errorf("recovery cancelled: the members were not defragmented")
+		}
+		for _, m := range members {
+			if _, err := o.exec(m.Pod, fmt.Sprintf("etcdctl defrag --endpoints=%s --command-timeout=60s", m.Endpoint)); err != nil {
+				return fmt.Errorf("failed to defragment member %s: %w", m.Member.Name, err)
+			}
+		}
+	}
+
+	if len(alarms) > 0 {
+		o.printf("Alarms raised: %s\n", strings.Join(alarms, ", "))
+		if o.confirm("Disarm the alarms?") {
+			if _, err := o.exec(diagnosis.leader().Pod, "etcdctl alarm disarm"); err != nil {
+				return fmt.Errorf("failed to disarm the alarms: %w", err)
+			}
+		}
+	}
+
+	_, err := o.diagnose(ctx)
+	return err
+}
@@ -0,0 +1,218 @@
+package cluster
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestEtcdRecover(t *testing.T) {
+	etcdRejoinPollInterval = 10 * time.Millisecond
+
+	tests := []struct {
+		name              string
+		etcd              *fakeEtcd
+		answers           string
+		notReady          string
+		quorumGuardOff    bool
+		expectErr         string
+		expectExecuted    []string
+		expectOutput      []string
+		expectRedeploy    bool
+		expectGuardOff    bool
+		expectSecretsGone bool
+	}{
+		{
+			name:         "healthy",
+			etcd:         newFakeEtcd(),
+			expectOutput: []string{"etcd is healthy: 3 of 3 members healthy", "nothing to do"},
+		},
+		{
+			name: "healthy but fragmented with alarms",
+			etcd: func() *fakeEtcd {
+				f := newFakeEtcd()
+				f.dbSize, f.dbSizeInUse = 200*1024*1024, 50*1024*1024
+				f.alarms = "memberID:1 alarm:NOSPACE\n"
+				return f
+			}(),
+			answers: "y\ny\n",
+			expectExecuted: []string{
+				"etcd-master-1: etcdctl defrag --endpoints=https://10.0.0.2:2379 --command-timeout=60s",
+				"etcd-master-2: etcdctl defrag --endpoints=https://10.0.0.3:2379 --command-timeout=60s",
+				"etcd-master-0: etcdctl defrag --endpoints=https://10.0.0.1:2379 --command-timeout=60s",
+				"etcd-master-0: etcdctl alarm disarm",
+			},
+			expectOutput: []string{"Defragment these members? [y/N]", "Disarm the alarms? [y/N]"},
+		},
+		{
+			name: "defragmentation declined",
+			etcd: func() *fakeEtcd {
+				f := newFakeEtcd()
+				f.dbSize, f.dbSizeInUse = 200*1024*1024, 50*1024*1024
+				return f
+			}(),
+			answers:   "n\n",
+			expectErr: "the members were not defragmented",
+		},
+		{
+			name:    "degraded",
+			etcd:    newFakeEtcd("master-1"),
+			answers: "y\ny\n",
+			expectExecuted: []string{
+				"etcd-master-0: etcdctl snapshot save /var/lib/etcd/osdctl-recover-snapshot-20261018-120000.db --endpoints=https://10.0.0.1:2379",
+				"etcd-master-0: etcdctl snapshot status /var/lib/etcd/osdctl-recover-snapshot-20261018-120000.db -w json 2>/dev/null",
+				"etcd-master-0: etcdctl member remove 2",
+			},
+			expectOutput: []string{
+				"revision 1234, 567 keys",
+				"Replace member master-1? [y/N]",
+				"$ etcdctl member remove 2  # in pod etcd-master-0",
+				"Member master-1 rejoined the cluster",
+				"etcd is healthy: 3 of 3 members healthy",
+			},
+			expectRedeploy:    true,
+			expectSecretsGone: true,
+		},
+		{
+			name:      "degraded without a snapshot",
+			etcd:      newFakeEtcd("master-1"),
+			answers:   "n\n",
+			expectErr: "a verified snapshot is required",
+		},
+		{
+			name:     "degraded on a node which is not ready",
+			etcd:     newFakeEtcd("master-1"),
+			answers:  "y\n",
+			notReady: "master-1",
+			expectExecuted: []string{
+				"etcd-master-0: etcdctl snapshot save /var/lib/etcd/osdctl-recover-snapshot-20261018-120000.db --endpoints=https://10.0.0.1:2379",
+				"etcd-master-0: etcdctl snapshot status /var/lib/etcd/osdctl-recover-snapshot-20261018-120000.db -w json 2>/dev/null",
+			},
+			expectErr:    "member master-1 needs its control plane machine replaced",
+			expectOutput: []string{etcdReplaceMemberDocs},
+		},
+		{
+			name:    "lost quorum",
+			etcd:    newFakeEtcd("master-1", "master-2"),
+			answers: "y\n",
+			expectExecuted: []string{
+				"etcd-master-0: etcdctl snapshot save /var/lib/etcd/osdctl-recover-snapshot-20261018-120000.db --endpoints=https://10.0.0.1:2379",
+				"etcd-master-0: etcdctl snapshot status /var/lib/etcd/osdctl-recover-snapshot-20261018-120000.db -w json 2>/dev/null",
+			},
+			expectErr:    "etcd lost quorum",
+			expectOutput: []string{"etcd is lost quorum: 0 of 3 members healthy", "On master-0, restore the snapshot", "cluster-restore.sh"},
+		},
+		{
+			name:           "quorum guard left disabled",
+			etcd:           newFakeEtcd(),
+			answers:        "y\n",
+			quorumGuardOff: true,
+			expectOutput:   []string{"The etcd quorum guard is disabled", "nothing to do"},
+		},
+		{
+			name:           "quorum guard left disabled and kept",
+			etcd:           newFakeEtcd(),
+			answers:        "n\n",
+			quorumGuardOff: true,
+			expectGuardOff: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := kubefake.NewSimpleClientset()
+			for _, pod := range tt.etcd.pods() {
+				_, err := clientset.CoreV1().Pods(EtcdNamespaceName).Create(context.Background(), &pod, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
+			for _, member := range fakeEtcdMembers {
+				ready := corev1.ConditionTrue
+				if member == tt.notReady {
+					ready = corev1.ConditionFalse
+				}
+				_, err := clientset.CoreV1().Nodes().Create(context.Background(), &corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{Name: member},
+					Status:     corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}}},
+				}, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
+			for _, prefix := range secrets {
+				_, err := clientset.CoreV1().Secrets(EtcdNamespaceName).Create(context.Background(), &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: prefix + "master-1", Namespace: EtcdNamespaceName},
+				}, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
+
+			scheme := runtime.NewScheme()
+			require.NoError(t, operatorv1.AddToScheme(scheme))
+			etcdCR := &operatorv1.Etcd{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+			if tt.quorumGuardOff {
+				etcdCR.Spec.UnsupportedConfigOverrides.Raw = []byte(`{"useUnsupportedUnsafeNonHANonProductionUnstableEtcd":true}`)
+			}
+			kubeCli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(etcdCR).Build()
+
+			out, transcript := &bytes.Buffer{}, &bytes.Buffer{}
+			opts := &etcdRecoverOptions{
+				clusterID:       "cluster-1",
+				defragThreshold: 45,
+				rejoinTimeout:   time.Second,
+				out:             out,
+				transcript:      transcript,
+				in:              bufio.NewReader(strings.NewReader(tt.answers)),
+				kubeCli:         kubeCli,
+				clientset:       clientset,
+				run:             tt.etcd.run,
+				now:             func() time.Time { return time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC) },
+			}
+
+			err := opts.recover(context.Background())
+			if tt.expectErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.expectExecuted, tt.etcd.executed)
+			for _, expected := range tt.expectOutput {
+				assert.Contains(t, out.String(), expected)
+			}
+			// Everything printed reaches the transcript through the output, while the answers are only written to it
+			assert.Equal(t, strings.Fields(tt.answers), strings.Fields(transcript.String()), "every answer is recorded")
+
+			require.NoError(t, kubeCli.Get(context.Background(), client.ObjectKey{Name: "cluster"}, etcdCR))
+			assert.Equal(t, tt.expectGuardOff, quorumGuardDisabled(etcdCR), "the quorum guard is re-enabled")
+			assert.Equal(t, tt.expectRedeploy, strings.HasPrefix(etcdCR.Spec.ForceRedeploymentReason, "single-master-recovery-"))
+
+			remaining, err := clientset.CoreV1().Secrets(EtcdNamespaceName).List(context.Background(), metav1.ListOptions{})
+			require.NoError(t, err)
+			if tt.expectSecretsGone {
+				assert.Empty(t, remaining.Items)
+			} else {
+				assert.Len(t, remaining.Items, len(secrets))
+			}
+		})
+	}
+}
+
+func TestQuorumGuardDisabled(t *testing.T) {
+	etcd := &operatorv1.Etcd{}
+	assert.False(t, quorumGuardDisabled(etcd))
+	etcd.Spec.UnsupportedConfigOverrides.Raw = []byte(`{"useUnsupportedUnsafeNonHANonProductionUnstableEtcd":false}`)
+	assert.False(t, quorumGuardDisabled(etcd))
+	etcd.Spec.UnsupportedConfigOverrides.Raw = []byte(`{"useUnsupportedUnsafeNonHANonProductionUnstableEtcd":true}`)
+	assert.True(t, quorumGuardDisabled(etcd))
+}
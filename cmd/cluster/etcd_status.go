@@ -0,0 +1,343 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/openshift/osdctl/pkg/printer"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	etcdQuorumHealthy  = "healthy"
+	etcdQuorumDegraded = "degraded"
+	etcdQuorumLost     = "lost quorum"
+
+	etcdMemberListCmd     = "etcdctl member list -w json"
+	etcdEndpointStatusCmd = "etcdctl endpoint status -w json 2>/dev/null || true"
+	etcdAlarmListCmd      = "etcdctl alarm list"
+
+	// Below this size a fragmented database is not worth a defragmentation, like the cluster-etcd-operator does
+	etcdDefragMinDBSize = 100 * 1024 * 1024
+)
+
+// etcdctlRunner runs an etcdctl command in the etcdctl container of the given etcd pod
+type etcdctlRunner func(pod string, command string) (string, error)
+
+type etcdHeader struct {
+	ClusterID uint64 `json:"cluster_id"`
+	MemberID  uint64 `json:"member_id"`
+	Revision  int64  `json:"revision"`
+	RaftTerm  uint64 `json:"raft_term"`
+}
+
+// etcdMember is a member as listed by 'etcdctl member list -w json'
+type etcdMember struct {
+	ID         uint64   `json:"ID"`
+	Name       string   `json:"name"`
+	PeerURLs   []string `json:"peerURLs"`
+	ClientURLs []string `json:"clientURLs"`
+	IsLearner  bool     `json:"isLearner"`
+}
+
+type etcdMemberList struct {
+	Header  etcdHeader   `json:"header"`
+	Members []etcdMember `json:"members"`
+}
+
+// etcdStatus is the status of an endpoint as shown by 'etcdctl endpoint status -w json'
+type etcdStatus struct {
+	Header           etcdHeader `json:"header"`
+	Version          string     `json:"version"`
+	DbSize           int64      `json:"dbSize"`
+	DbSizeInUse      int64      `json:"dbSizeInUse"`
+	Leader           uint64     `json:"leader"`
+	RaftIndex        uint64     `json:"raftIndex"`
+	RaftTerm         uint64     `json:"raftTerm"`
+	RaftAppliedIndex uint64     `json:"raftAppliedIndex"`
+	Errors           []string   `json:"errors"`
+	IsLearner        bool       `json:"isLearner"`
+}
+
+type etcdEndpointStatus struct {
+	Endpoint string     `json:"Endpoint"`
+	Status   etcdStatus `json:"Status"`
+}
+
+// etcdMemberState is everything known about a member of the etcd cluster
+type etcdMemberState struct {
+	Member   etcdMember
+	Pod      string
+	Endpoint string
+	Status   *etcdStatus
+	Alarms   []string
+}
+
+// healthy returns whether the member answered its status without errors and knows the leader
+func (m *etcdMemberState) healthy() bool {
+	return m.Status != nil && len(m.Status.Errors) == 0 && m.Status.Leader != 0
+}
+
+// fragmentation returns the share of the database which is not in use
+func (m *etcdMemberState) fragmentation() float64 {
+	if m.Status == nil || m.Status.DbSize == 0 {
+		return 0
+	}
+	return float64(m.Status.DbSize-m.Status.DbSizeInUse) / float64(m.Status.DbSize)
+}
+
+// needsDefrag returns whether the database of the member is bloated past the given fragmentation threshold
+func (m *etcdMemberState) needsDefrag(threshold float64) bool {
+	return m.Status != nil && m.Status.DbSize >= etcdDefragMinDBSize && m.fragmentation() >= threshold
+}
+
+// etcdDiagnosis is the state of the etcd cluster as seen from all etcd pods
+type etcdDiagnosis struct {
+	Members []*etcdMemberState
+	Leader  uint64
+	Quorum  string
+	// MemberListErr is set when no pod could list the members, which requires quorum
+	MemberListErr error
+}
+
+// quorumSize returns how many members must be healthy for the cluster to have quorum
+func (d *etcdDiagnosis) quorumSize() int {
+	return len(d.Members)/2 + 1
+}
+
+func (d *etcdDiagnosis) healthy() []*etcdMemberState {
+	var members []*etcdMemberState
+	for _, m := range d.Members {
+		if m.healthy() {
+			members = append(members, m)
+		}
+	}
+	return members
+}
+
+func (d *etcdDiagnosis) unhealthy() []*etcdMemberState {
+	var members []*etcdMemberState
+	for _, m := range d.Members {
+		if !m.healthy() {
+			members = append(members, m)
+		}
+	}
+	return members
+}
+
+// leader returns the member which leads the cluster, if any
+func (d *etcdDiagnosis) leader() *etcdMemberState {
+	for _, m := range d.Members {
+		if d.Leader != 0 && m.Member.ID == d.Leader {
+			return m
+		}
+	}
+	return nil
+}
+
+// alarms returns the alarms raised on any member
+func (d *etcdDiagnosis) alarms() []string {
+	var alarms []string
+	for _, m := range d.Members {
+		for _, alarm := range m.Alarms {
+			alarms = append(alarms, fmt.Sprintf("%s on %s", alarm, m.Member.Name))
+		}
+	}
+	return alarms
+}
+
+// etcdPodName returns the etcd pod running on the node a member is named after
+func etcdPodName(memberName string) string {
+	return "etcd-" + memberName
+}
+
+// diagnoseEtcd collects the member list, endpoint status and alarms from every etcd pod and decides whether the
+// cluster is healthy, degraded or lost quorum. A single pod is enough for the member list, while the endpoint
+// statuses of all pods are merged so a member counts as healthy when any pod could reach it.
+func diagnoseEtcd(pods []corev1.Pod, run etcdctlRunner) *etcdDiagnosis {
+	diagnosis := &etcdDiagnosis{}
+	podNames := make([]string, 0, len(pods))
+	podIPs := map[string]string{}
+	for _, pod := range pods {
+		podNames = append(podNames, pod.Name)
+		podIPs[pod.Name] = pod.Status.PodIP
+	}
+	sort.Strings(podNames)
+
+	var memberList *etcdMemberList
+	statuses := map[uint64]etcdEndpointStatus{}
+	var alarms map[uint64][]string
+	for _, pod := range podNames {
+		if memberList == nil {
+			output, err := run(pod, etcdMemberListCmd)
+			if err == nil {
+				memberList, err = parseEtcdMemberList(output)
+			}
+			if err != nil {
+				diagnosis.MemberListErr = fmt.Errorf("%s: %w", pod, err)
+			}
+		}
+
+		output, err := run(pod, etcdEndpointStatusCmd)
+		if err == nil {
+			endpoints, err := parseEtcdEndpointStatus(output)
+			if err == nil {
+				for _, endpoint := range endpoints {
+					if _, found := statuses[endpoint.Status.Header.MemberID]; !found {
+						statuses[endpoint.Status.Header.MemberID] = endpoint
+					}
+				}
+			}
+		}
+
+		if alarms == nil {
+			if output, err := run(pod, etcdAlarmListCmd); err == nil {
+				alarms = parseEtcdAlarms(output)
+			}
+		}
+	}
+
+	if memberList != nil {
+		diagnosis.MemberListErr = nil
+		for _, member := range memberList.Members {
+			state := &etcdMemberState{Member: member, Pod: etcdPodName(member.Name), Alarms: alarms[member.ID]}
+			if len(member.ClientURLs) > 0 {
+				state.Endpoint = member.ClientURLs[0]
+			}
+			if status, found := statuses[member.ID]; found {
+				state.Status = &status.Status
+				state.Endpoint = status.Endpoint
+			}
+			diagnosis.Members = append(diagnosis.Members, state)
+		}
+	} else {
+		// Without quorum the members are only known through their pods. etcd runs on the host network, so the
+		// status of a member is the one of the endpoint on the IP of its pod.
+		for _, pod := range podNames {
+			state := &etcdMemberState{Member: etcdMember{Name: strings.TrimPrefix(pod, "etcd-")}, Pod: pod}
+			for _, status := range statuses {
+				if podIPs[pod] != "" && strings.Contains(status.Endpoint, "://"+podIPs[pod]+":") {
+					state.Member.ID = status.Status.Header.MemberID
+					state.Endpoint = status.Endpoint
+					state.Status = &status.Status
+				}
+			}
+			diagnosis.Members = append(diagnosis.Members, state)
+		}
+	}
+	sort.Slice(diagnosis.Members, func(i, j int) bool {
+		return diagnosis.Members[i].Member.Name < diagnosis.Members[j].Member.Name
+	})
+
+	// The leader is the one the healthy members agree on
+	leaders := map[uint64]int{}
+	for _, m := range diagnosis.healthy() {
+		leaders[m.Status.Leader]++
+	}
+	for leader, votes := range leaders {
+		if votes >= diagnosis.quorumSize() {
+			diagnosis.Leader = leader
+		}
+	}
+
+	healthy := len(diagnosis.healthy())
+	switch {
+	case memberList == nil || diagnosis.Leader == 0 || healthy < diagnosis.quorumSize():
+		diagnosis.Quorum = etcdQuorumLost
+	case healthy < len(diagnosis.Members):
+		diagnosis.Quorum = etcdQuorumDegraded
+	default:
+		diagnosis.Quorum = etcdQuorumHealthy
+	}
+	return diagnosis
+}
+
+func parseEtcdMemberList(output string) (*etcdMemberList, error) {
+	memberList := &etcdMemberList{}
+	if err := json.Unmarshal([]byte(output), memberList); err != nil {
+		return nil, fmt.Errorf("failed to parse the etcd member list: %w", err)
+	}
+	return memberList, nil
+}
+
+func parseEtcdEndpointStatus(output string) ([]etcdEndpointStatus, error) {
+	var statuses []etcdEndpointStatus
+	output = strings.TrimSpace(output)
+	if output == "" {
+		return nil, nil
+	}
+	if err := json.Unmarshal([]byte(output), &statuses); err != nil {
+		return nil, fmt.Errorf("failed to parse the etcd endpoint status: %w", err)
+	}
+	return statuses, nil
+}
+
+// parseEtcdAlarms parses the 'memberID:<id> alarm:<alarm>' lines of 'etcdctl alarm list'
+func parseEtcdAlarms(output string) map[uint64][]string {
+	alarms := map[uint64][]string{}
+	for _, line := range strings.Split(output, "\n") {
+		var memberID uint64
+		var alarm string
+		for _, field := range strings.Fields(line) {
+			key, value, found := strings.Cut(field, ":")
+			if !found {
+				continue
+			}
+			switch key {
+			case "memberID":
+				memberID, _ = strconv.ParseUint(value, 10, 64)
+			case "alarm":
+				alarm = value
+			}
+		}
+		if memberID != 0 && alarm != "" {
+			alarms[memberID] = append(alarms[memberID], alarm)
+		}
+	}
+	return alarms
+}
+
+// formatEtcdBytes formats a database size
+func formatEtcdBytes(size int64) string {
+	return fmt.Sprintf("%.1f MiB", float64(size)/1024/1024)
+}
+
+// printEtcdDiagnosis prints the state of every member and the quorum verdict
+func printEtcdDiagnosis(out io.Writer, diagnosis *etcdDiagnosis) {
+	p := printer.NewTablePrinter(out, 20, 1, 3, ' ')
+	p.AddRow([]string{"MEMBER", "ID", "ENDPOINT", "LEADER", "DB SIZE", "IN USE", "FRAGMENTED", "RAFT INDEX", "STATUS"})
+	for _, m := range diagnosis.Members {
+		id, leader, dbSize, inUse, fragmented, raftIndex := "-", "-", "-", "-", "-", "-"
+		if m.Member.ID != 0 {
+			id = strconv.FormatUint(m.Member.ID, 16)
+		}
+		status := "unreachable"
+		if m.Status != nil {
+			leader = strconv.FormatBool(diagnosis.Leader != 0 && m.Member.ID == diagnosis.Leader)
+			dbSize = formatEtcdBytes(m.Status.DbSize)
+			inUse = formatEtcdBytes(m.Status.DbSizeInUse)
+			fragmented = fmt.Sprintf("%.0f%%", m.fragmentation()*100)
+			raftIndex = strconv.FormatUint(m.Status.RaftIndex, 10)
+			status = "healthy"
+			if len(m.Status.Errors) > 0 {
+				status = strings.Join(m.Status.Errors, "; ")
+			} else if m.Status.Leader == 0 {
+				status = "no leader"
+			}
+		}
+		p.AddRow([]string{m.Member.Name, id, m.Endpoint, leader, dbSize, inUse, fragmented, raftIndex, status})
+	}
+	_ = p.Flush()
+
+	if alarms := diagnosis.alarms(); len(alarms) > 0 {
+		_, _ = fmt.Fprintf(out, "Alarms: %s\n", strings.Join(alarms, ", "))
+	}
+	if diagnosis.MemberListErr != nil {
+		_, _ = fmt.Fprintf(out, "No pod could list the members: %v\n", diagnosis.MemberListErr)
+	}
+	_, _ = fmt.Fprintf(out, "etcd is %s: %d of %d members healthy, %d needed for quorum\n", diagnosis.Quorum, len(diagnosis.healthy()), len(diagnosis.Members), diagnosis.quorumSize())
+}
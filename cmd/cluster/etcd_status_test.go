@@ -0,0 +1,237 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var fakeEtcdMembers = []string{"master-0", "master-1", "master-2"}
+
+// fakeEtcd answers etcdctl commands like a three member etcd cluster led by master-0
+type fakeEtcd struct {
+	down        map[string]bool
+	leaders     map[string]uint64
+	dbSize      int64
+	dbSizeInUse int64
+	alarms      string
+	metrics     string
+	// executed are the commands which are not part of the diagnosis
+	executed []string
+}
+
+func newFakeEtcd(down ...string) *fakeEtcd {
+	f := &fakeEtcd{down: map[string]bool{}, dbSize: 50 * 1024 * 1024, dbSizeInUse: 40 * 1024 * 1024}
+	for _, member := range down {
+		f.down[member] = true
+	}
+	return f
+}
+
+func fakeEtcdMemberID(name string) uint64 {
+	for i, member := range fakeEtcdMembers {
+		if member == name {
+			return uint64(i + 1)
+		}
+	}
+	return 0
+}
+
+func fakeEtcdEndpoint(name string) string {
+	return fmt.Sprintf("https://10.0.0.%d:2379", fakeEtcdMemberID(name))
+}
+
+func (f *fakeEtcd) quorum() bool {
+	return len(f.down) < 2
+}
+
+func (f *fakeEtcd) pods() []corev1.Pod {
+	var pods []corev1.Pod
+	for _, member := range fakeEtcdMembers {
+		pods = append(pods, corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:      etcdPodName(member),
+			Namespace: EtcdNamespaceName,
+			Labels:    map[string]string{"k8s-app": "etcd"},
+		}, Status: corev1.PodStatus{PodIP: fmt.Sprintf("10.0.0.%d", fakeEtcdMemberID(member))}})
+	}
+	return pods
+}
+
+func (f *fakeEtcd) run(pod string, command string) (string, error) {
+	member := strings.TrimPrefix(pod, "etcd-")
+	if f.down[member] {
+		return "", fmt.Errorf("container etcdctl is not running")
+	}
+
+	switch {
+	case command == etcdMemberListCmd:
+		if !f.quorum() {
+			return "", fmt.Errorf("context deadline exceeded")
+		}
+		list := etcdMemberList{}
+		for _, name := range fakeEtcdMembers {
+			list.Members = append(list.Members, etcdMember{ID: fakeEtcdMemberID(name), Name: name, ClientURLs: []string{fakeEtcdEndpoint(name)}})
+		}
+		output, err := json.Marshal(list)
+		return string(output), err
+	case command == etcdEndpointStatusCmd:
+		var statuses []etcdEndpointStatus
+		for _, name := range fakeEtcdMembers {
+			if f.down[name] {
+				continue
+			}
+			leader := fakeEtcdMemberID("master-0")
+			if !f.quorum() {
+				leader = 0
+			}
+			if l, found := f.leaders[name]; found {
+				leader = l
+			}
+			statuses = append(statuses, etcdEndpointStatus{Endpoint: fakeEtcdEndpoint(name), Status: etcdStatus{
+				Header:      etcdHeader{MemberID: fakeEtcdMemberID(name)},
+				DbSize:      f.dbSize,
+				DbSizeInUse: f.dbSizeInUse,
+				Leader:      leader,
+				RaftIndex:   100 + fakeEtcdMemberID(name),
+			}})
+		}
+		output, err := json.Marshal(statuses)
+		return string(output), err
+	case command == etcdAlarmListCmd:
+		return f.alarms, nil
+	case strings.HasPrefix(command, "curl "):
+		return f.metrics, nil
+	}
+
+	f.executed = append(f.executed, pod+": "+command)
+	switch {
+	case strings.HasPrefix(command, "etcdctl snapshot save"):
+		return "Snapshot saved", nil
+	case strings.HasPrefix(command, "etcdctl snapshot status"):
+		return `{"hash":3735928559,"revision":1234,"totalKey":567,"totalSize":52428800}`, nil
+	case strings.HasPrefix(command, "etcdctl member remove "):
+		id, err := strconv.ParseUint(strings.TrimPrefix(command, "etcdctl member remove "), 16, 64)
+		if err != nil {
+			return "", err
+		}
+		// The member is redeployed and rejoins right away
+		delete(f.down, fakeEtcdMembers[id-1])
+		return fmt.Sprintf("Member %x removed from cluster", id), nil
+	}
+	return "", nil
+}
+
+func TestDiagnoseEtcd(t *testing.T) {
+	tests := []struct {
+		name          string
+		etcd          *fakeEtcd
+		expectQuorum  string
+		expectLeader  uint64
+		expectHealthy []string
+	}{
+		{
+			name:          "healthy",
+			etcd:          newFakeEtcd(),
+			expectQuorum:  etcdQuorumHealthy,
+			expectLeader:  1,
+			expectHealthy: []string{"master-0", "master-1", "master-2"},
+		},
+		{
+			name:          "one member down",
+			etcd:          newFakeEtcd("master-1"),
+			expectQuorum:  etcdQuorumDegraded,
+			expectLeader:  1,
+			expectHealthy: []string{"master-0", "master-2"},
+		},
+		{
+			name:          "two members down",
+			etcd:          newFakeEtcd("master-1", "master-2"),
+			expectQuorum:  etcdQuorumLost,
+			expectHealthy: nil,
+		},
+		{
+			name: "members disagree on the leader",
+			etcd: func() *fakeEtcd {
+				f := newFakeEtcd()
+				f.leaders = map[string]uint64{"master-1": 2, "master-2": 3}
+				return f
+			}(),
+			expectQuorum:  etcdQuorumLost,
+			expectHealthy: []string{"master-0", "master-1", "master-2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnosis := diagnoseEtcd(tt.etcd.pods(), tt.etcd.run)
+
+			assert.Equal(t, tt.expectQuorum, diagnosis.Quorum)
+			assert.Equal(t, tt.expectLeader, diagnosis.Leader)
+			require.Len(t, diagnosis.Members, 3)
+			var healthy []string
+			for _, m := range diagnosis.healthy() {
+				healthy = append(healthy, m.Member.Name)
+			}
+			assert.Equal(t, tt.expectHealthy, healthy)
+			for _, m := range diagnosis.Members {
+				assert.Equal(t, etcdPodName(m.Member.Name), m.Pod)
+			}
+		})
+	}
+}
+
+func TestDiagnoseEtcdAlarmsAndFragmentation(t *testing.T) {
+	etcd := newFakeEtcd()
+	etcd.dbSize, etcd.dbSizeInUse = 200*1024*1024, 50*1024*1024
+	etcd.alarms = "memberID:2 alarm:NOSPACE\n"
+
+	diagnosis := diagnoseEtcd(etcd.pods(), etcd.run)
+
+	assert.Equal(t, []string{"NOSPACE on master-1"}, diagnosis.alarms())
+	for _, m := range diagnosis.Members {
+		assert.InDelta(t, 0.75, m.fragmentation(), 0.001)
+		assert.True(t, m.needsDefrag(0.45))
+		assert.False(t, m.needsDefrag(0.8))
+	}
+
+	out := &bytes.Buffer{}
+	printEtcdDiagnosis(out, diagnosis)
+	assert.Contains(t, out.String(), "200.0 MiB")
+	assert.Contains(t, out.String(), "75%")
+	assert.Contains(t, out.String(), "Alarms: NOSPACE on master-1")
+	assert.Contains(t, out.String(), "etcd is healthy: 3 of 3 members healthy, 2 needed for quorum")
+}
+
+func TestNeedsDefragIgnoresSmallDatabases(t *testing.T) {
+	m := &etcdMemberState{Status: &etcdStatus{DbSize: 10 * 1024 * 1024, DbSizeInUse: 1024 * 1024}}
+	assert.False(t, m.needsDefrag(0.45))
+}
+
+func TestParseEtcdAlarms(t *testing.T) {
+	alarms := parseEtcdAlarms("memberID:2 alarm:NOSPACE\nmemberID:3 alarm:CORRUPT\nmemberID:2 alarm:CORRUPT\n\n")
+	assert.Equal(t, map[uint64][]string{2: {"NOSPACE", "CORRUPT"}, 3: {"CORRUPT"}}, alarms)
+	assert.Empty(t, parseEtcdAlarms(""))
+}
+
+func TestParseEtcdEndpointStatus(t *testing.T) {
+	statuses, err := parseEtcdEndpointStatus(`[{"Endpoint":"https://10.0.0.1:2379","Status":{"header":{"member_id":1},"dbSize":2048,"dbSizeInUse":1024,"leader":1,"raftIndex":42}}]`)
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	assert.Equal(t, uint64(1), statuses[0].Status.Header.MemberID)
+	assert.Equal(t, uint64(42), statuses[0].Status.RaftIndex)
+
+	statuses, err = parseEtcdEndpointStatus("\n")
+	require.NoError(t, err)
+	assert.Empty(t, statuses)
+
+	_, err = parseEtcdEndpointStatus("Error: context deadline exceeded")
+	assert.Error(t, err)
+}
@@ -0,0 +1,222 @@
+package resize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/smithy-go"
+	sdk "github.com/openshift-online/ocm-sdk-go"
+	cmv1 "github.com/openshift-online/ocm-sdk-go/clustersmgmt/v1"
+	"github.com/openshift/osdctl/pkg/osdCloud"
+	"github.com/openshift/osdctl/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// cloudResizer abstracts the cloud provider specific parts of resizing nodes
+type cloudResizer interface {
+	// terminateInstances requests the termination of the cloud instances backing the nodes
+	terminateInstances(ctx context.Context, nodes []corev1.Node) error
+	// close releases the cloud provider clients
+	close() error
+}
+
+// newGCPInstancesClient creates the GCP compute client, it authenticates with the application default credentials
+// as there isn't a way to programmatically retrieve backplane credentials for GCP
+var newGCPInstancesClient = osdCloud.GenerateGCPComputeInstancesClient
+
+// newCloudResizer returns the cloudResizer for the cloud provider of the cluster
+func newCloudResizer(cluster *cmv1.Cluster) (cloudResizer, error) {
+	switch cluster.CloudProvider().ID() {
+	case "aws":
+		return &awsResizer{cluster: cluster}, nil
+	case "gcp":
+		computeClient, err := newGCPInstancesClient()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the GCP compute client, login with 'gcloud auth application-default login': %v", err)
+		}
+		return &gcpResizer{
+			deleteInstance: func(ctx context.Context, projectID, zone, name string) error {
+				return osdCloud.DeleteInstance(ctx, computeClient, projectID, zone, name)
+			},
+			closeClient: computeClient.Close,
+		}, nil
+	default:
+		return nil, fmt.Errorf("cloud provider not supported: %s, only AWS and GCP are supported", cluster.CloudProvider().ID())
+	}
+}
+
+type awsResizer struct {
+	cluster *cmv1.Cluster
+}
+
+func (a *awsResizer) terminateInstances(ctx context.Context, nodes []corev1.Node) error {
+	var instanceIDs []string
+	for _, node := range nodes {
+		instanceIDs = append(instanceIDs, convertProviderIDtoInstanceID(node.Spec.ProviderID))
+	}
+
+	var ocmClient interface{}
+	if mOCM, ok := ctx.Value("ocm").(interface{ ClustersMgmt() interface{} }); ok {
+		ocmClient = mOCM
+	} else {
+		var err error
+		ocmClient, err = utils.CreateConnection()
+		if err != nil {
+			return err
+		}
+		defer ocmClient.(*sdk.Connection).Close()
+	}
+
+	var (
+		cfg awssdk.Config
+		err error
+	)
+	if mBuilder, ok := ctx.Value("aws_builder").(interface {
+		CreateAWSV2Config(interface{}, *cmv1.Cluster) (awssdk.Config, error)
+	}); ok {
+		cfg, err = mBuilder.CreateAWSV2Config(ocmClient, a.cluster)
+		if err != nil {
+			return err
+		}
+		cfg.Region = a.cluster.Region().ID()
+	} else {
+		cfg, err = osdCloud.CreateAWSV2Config(ocmClient.(*sdk.Connection), a.cluster)
+		if err != nil {
+			return err
+		}
+	}
+
+	awsClient := ec2.NewFromConfig(cfg)
+	_, err = awsClient.TerminateInstances(ctx, &ec2.TerminateInstancesInput{
+		InstanceIds: instanceIDs,
+	})
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) {
+			code := apiErr.ErrorCode()
+			message := apiErr.ErrorMessage()
+			log.Printf("AWS ERROR: %v - %v\n", code, message)
+		} else {
+			log.Printf("ERROR: %v\n", err.Error())
+		}
+		return err
+	}
+
+	log.Printf("requested termination of instances: %v", strings.Join(instanceIDs, ", "))
+	return nil
+}
+
+func (a *awsResizer) close() error {
+	return nil
+}
+
+type gcpResizer struct {
+	// deleteInstance requests the deletion of an instance of a project in a zone
+	deleteInstance func(ctx context.Context, projectID, zone, name string) error
+	// closeClient closes the compute client shared by the deletions
+	closeClient func() error
+}
+
+func (g *gcpResizer) close() error {
+	if g.closeClient == nil {
+		return nil
+	}
+	return g.closeClient()
+}
+
+func (g *gcpResizer) terminateInstances(ctx context.Context, nodes []corev1.Node) error {
+	var instances, failed []string
+	for _, node := range nodes {
+		projectID, zone, name, err := parseGCEProviderID(node.Spec.ProviderID)
+		if err != nil {
+			return err
+		}
+		instances = append(instances, name)
+
+		if err := g.deleteInstance(ctx, projectID, zone, name); err != nil {
+			log.Printf("GCP ERROR: failed to delete instance %s in %s/%s: %v", name, projectID, zone, err)
+			failed = append(failed, name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to terminate instances %s, use backplane to login and terminate them manually", strings.Join(failed, ", "))
+	}
+	log.Printf("requested termination of instances: %v", strings.Join(instances, ", "))
+	return nil
+}
+
+// parseGCEProviderID returns the project, zone and instance name of a GCP provider ID,
+// which come in the format: gce://my-project/europe-west4-a/my-cluster-name-n65hp-infra-a-4fbrd
+func parseGCEProviderID(providerID string) (string, string, string, error) {
+	parts := strings.Split(strings.TrimPrefix(providerID, "gce://"), "/")
+	if !strings.HasPrefix(providerID, "gce://") || len(parts) != 3 || slices.Contains(parts, "") {
+		return "", "", "", fmt.Errorf("invalid GCP provider ID: %q", providerID)
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// gcpMachineType is a parsed GCP machine type, either predefined like n2-highmem-8 (family n2-highmem)
+// or custom like custom-8-65536-ext (family custom-ext)
+type gcpMachineType struct {
+	family   string
+	vCPUs    int
+	memoryMB int
+}
+
+// parseGCPMachineType parses a GCP machine type, returning false if it isn't one
+func parseGCPMachineType(machineType string) (gcpMachineType, bool) {
+	parts := strings.Split(machineType, "-")
+
+	if parts[0] == "custom" {
+		family := "custom"
+		if parts[len(parts)-1] == "ext" {
+			family = "custom-ext"
+			parts = parts[:len(parts)-1]
+		}
+		if len(parts) != 3 {
+			return gcpMachineType{}, false
+		}
+		vCPUs, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return gcpMachineType{}, false
+		}
+		memoryMB, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return gcpMachineType{}, false
+		}
+		return gcpMachineType{family: family, vCPUs: vCPUs, memoryMB: memoryMB}, true
+	}
+
+	if len(parts) != 3 {
+		return gcpMachineType{}, false
+	}
+	vCPUs, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return gcpMachineType{}, false
+	}
+	return gcpMachineType{family: parts[0] + "-" + parts[1], vCPUs: vCPUs}, true
+}
+
+// validateGCPMachineType returns an error if the machine type isn't of a family supported for the node type,
+// with one of the vCPU counts and, for custom machine types, the memory per vCPU of the family
+func validateGCPMachineType(machineType gcpMachineType, nodeType string) error {
+	family, found := supportedGCPMachineFamilies[nodeType][machineType.family]
+	if !found {
+		return fmt.Errorf("GCP machine family %s not supported for %s nodes", machineType.family, nodeType)
+	}
+	if !slices.Contains(family.vCPUs, machineType.vCPUs) {
+		return fmt.Errorf("%d vCPUs not supported for %s nodes of GCP machine family %s, supported vCPUs are %v", machineType.vCPUs, nodeType, machineType.family, family.vCPUs)
+	}
+	if family.memoryPerVCPU != 0 && machineType.memoryMB != machineType.vCPUs*family.memoryPerVCPU {
+		return fmt.Errorf("%s nodes of GCP machine family %s need %d MB of memory per vCPU", nodeType, machineType.family, family.memoryPerVCPU)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package resize
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	cmv1 "github.com/openshift-online/ocm-sdk-go/clustersmgmt/v1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/option"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewCloudResizer(t *testing.T) {
+	resizer, err := newCloudResizer(newTestCluster(t, cmv1.NewCluster().CloudProvider(cmv1.NewCloudProvider().ID("aws"))))
+	assert.NoError(t, err)
+	assert.IsType(t, &awsResizer{}, resizer)
+
+	assert.NoError(t, resizer.close())
+
+	defer func(original func() (*compute.InstancesClient, error)) { newGCPInstancesClient = original }(newGCPInstancesClient)
+	newGCPInstancesClient = func() (*compute.InstancesClient, error) {
+		return compute.NewInstancesRESTClient(context.Background(), option.WithoutAuthentication())
+	}
+	resizer, err = newCloudResizer(newTestCluster(t, cmv1.NewCluster().CloudProvider(cmv1.NewCloudProvider().ID("gcp"))))
+	assert.NoError(t, err)
+	assert.IsType(t, &gcpResizer{}, resizer)
+	assert.NoError(t, resizer.close())
+
+	newGCPInstancesClient = func() (*compute.InstancesClient, error) {
+		return nil, errors.New("could not find default credentials")
+	}
+	_, err = newCloudResizer(newTestCluster(t, cmv1.NewCluster().CloudProvider(cmv1.NewCloudProvider().ID("gcp"))))
+	assert.ErrorContains(t, err, "gcloud auth application-default login")
+
+	_, err = newCloudResizer(newTestCluster(t, cmv1.NewCluster().CloudProvider(cmv1.NewCloudProvider().ID("azure"))))
+	assert.Error(t, err)
+}
+
+func TestParseGCEProviderID(t *testing.T) {
+	tests := []struct {
+		providerID    string
+		expectProject string
+		expectZone    string
+		expectName    string
+		expectErr     bool
+	}{
+		{
+			providerID:    "gce://my-project/europe-west4-a/my-cluster-name-n65hp-infra-a-4fbrd",
+			expectProject: "my-project",
+			expectZone:    "europe-west4-a",
+			expectName:    "my-cluster-name-n65hp-infra-a-4fbrd",
+		},
+		{
+			providerID: "aws:///us-east-1a/i-0a1b2c3d4e5f6g7h8",
+			expectErr:  true,
+		},
+		{
+			providerID: "gce://my-project//my-cluster-name-n65hp-infra-a-4fbrd",
+			expectErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.providerID, func(t *testing.T) {
+			project, zone, name, err := parseGCEProviderID(test.providerID)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectProject, project)
+			assert.Equal(t, test.expectZone, zone)
+			assert.Equal(t, test.expectName, name)
+		})
+	}
+}
+
+func TestParseGCPMachineType(t *testing.T) {
+	tests := []struct {
+		machineType string
+		expected    gcpMachineType
+		expectOk    bool
+	}{
+		{
+			machineType: "n2-highmem-8",
+			expected:    gcpMachineType{family: "n2-highmem", vCPUs: 8},
+			expectOk:    true,
+		},
+		{
+			machineType: "custom-8-32768",
+			expected:    gcpMachineType{family: "custom", vCPUs: 8, memoryMB: 32768},
+			expectOk:    true,
+		},
+		{
+			machineType: "custom-4-32768-ext",
+			expected:    gcpMachineType{family: "custom-ext", vCPUs: 4, memoryMB: 32768},
+			expectOk:    true,
+		},
+		{
+			machineType: "r5.xlarge",
+		},
+		{
+			machineType: "custom-ext",
+		},
+		{
+			machineType: "n2-highmem-large",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.machineType, func(t *testing.T) {
+			actual, ok := parseGCPMachineType(test.machineType)
+			assert.Equal(t, test.expectOk, ok)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestGCPResizerTerminateInstances(t *testing.T) {
+	nodes := []corev1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "infra-a"}, Spec: corev1.NodeSpec{ProviderID: "gce://my-project/europe-west4-a/my-cluster-infra-a-4fbrd"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "infra-b"}, Spec: corev1.NodeSpec{ProviderID: "gce://my-project/europe-west4-b/my-cluster-infra-b-x7k2p"}},
+	}
+
+	var deleted []string
+	g := &gcpResizer{deleteInstance: func(_ context.Context, projectID, zone, name string) error {
+		deleted = append(deleted, projectID+"/"+zone+"/"+name)
+		if name == "my-cluster-infra-b-x7k2p" {
+			return errors.New("permission denied")
+		}
+		return nil
+	}}
+
+	err := g.terminateInstances(context.Background(), nodes)
+	assert.EqualError(t, err, "failed to terminate instances my-cluster-infra-b-x7k2p, use backplane to login and terminate them manually")
+	// A failure doesn't prevent the remaining instances from being deleted
+	assert.Equal(t, []string{
+		"my-project/europe-west4-a/my-cluster-infra-a-4fbrd",
+		"my-project/europe-west4-b/my-cluster-infra-b-x7k2p",
+	}, deleted)
+
+	deleted = nil
+	assert.NoError(t, g.terminateInstances(context.Background(), nodes[:1]))
+	assert.Equal(t, []string{"my-project/europe-west4-a/my-cluster-infra-a-4fbrd"}, deleted)
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// supportedInstanceTypes are the AWS instance types each node type can be resized to
 var supportedInstanceTypes = map[string][]string{
 	"controlplane": {
 		"m5.2xlarge",
@@ -19,12 +20,6 @@ var supportedInstanceTypes = map[string][]string{
 		"m6i.16xlarge",
 		"m6i.24xlarge",
 		"m6i.32xlarge",
-		"custom-8-32768",
-		"custom-16-65536",
-		"custom-32-131072",
-		"n2-standard-8",
-		"n2-standard-16",
-		"n2-standard-32",
 	},
 	"infra": {
 		"r5.xlarge",
@@ -42,12 +37,27 @@ var supportedInstanceTypes = map[string][]string{
 		"r6i.16xlarge",
 		"r6i.24xlarge",
 		"r6i.32xlarge",
-		"custom-4-32768-ext",
-		"custom-8-65536-ext",
-		"custom-16-131072-ext",
-		"n2-highmem-4",
-		"n2-highmem-8",
-		"n2-highmem-16",
+	},
+}
+
+// gcpMachineFamily describes the GCP machine types of a family a node type can be resized to
+type gcpMachineFamily struct {
+	vCPUs []int
+
+	// memoryPerVCPU is the memory in MB per vCPU of custom machine types
+	memoryPerVCPU int
+}
+
+// supportedGCPMachineFamilies are the GCP machine families each node type can be resized to, matching the memory
+// per vCPU of the supported AWS instance types
+var supportedGCPMachineFamilies = map[string]map[string]gcpMachineFamily{
+	"controlplane": {
+		"n2-standard": {vCPUs: []int{8, 16, 32, 48, 64, 80, 96, 128}},
+		"custom":      {vCPUs: []int{8, 16, 32, 48, 64, 80, 96}, memoryPerVCPU: 4096},
+	},
+	"infra": {
+		"n2-highmem": {vCPUs: []int{4, 8, 16, 32, 48, 64, 80, 96, 128}},
+		"custom-ext": {vCPUs: []int{4, 8, 16, 32, 48, 64, 80, 96}, memoryPerVCPU: 8192},
 	},
 }
 
@@ -3,27 +3,31 @@ package resize
 import (
 	"bufio"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
 	cmv1 "github.com/openshift-online/ocm-sdk-go/clustersmgmt/v1"
 	machinev1 "github.com/openshift/api/machine/v1"
 	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	operatorv1 "github.com/openshift/api/operator/v1"
 	bpelevate "github.com/openshift/backplane-cli/pkg/elevate"
 	"github.com/openshift/osdctl/cmd/servicelog"
 	"github.com/openshift/osdctl/pkg/k8s"
+	"github.com/openshift/osdctl/pkg/osdCloud"
 	"github.com/openshift/osdctl/pkg/printer"
 	"github.com/openshift/osdctl/pkg/utils"
 	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -48,6 +52,13 @@ type controlPlane struct {
 
 	// reason to provide for elevation (eg: OHSS/PG ticket)
 	reason string
+
+	// awsClient and quotaClient are used by the pre-flight checks on AWS
+	awsClient   resizeControlPlanePreflightAWSClient
+	quotaClient resizeControlPlaneQuotaClient
+
+	// rejoinTimeout is how long a replacement machine has to rejoin before the resize is reverted
+	rejoinTimeout time.Duration
 }
 
 // This command requires to previously be logged in via `ocm login`
@@ -59,8 +70,15 @@ func newCmdResizeControlPlane() *cobra.Command {
 		Long: `Resize an OSD/ROSA cluster's control plane nodes
 
   Requires previous login to the api server via "ocm backplane login".
-  The user will be prompted to send a service log after initiating the resize. The resize process runs asynchronously,
-  and this command exits immediately after sending the service log. Any issues with the resize will be reported via PagerDuty.`,
+  Before anything is changed, pre-flight checks ensure the new instance type is offered in the availability zone of
+  every control plane machine and the vCPU quota fits the rollout (AWS only), and that etcd is healthy.
+
+  The control plane machine set is switched to the OnDelete strategy and the control plane machines are replaced one
+  at a time. Each replacement must run a Ready node and etcd must report all members available before the next
+  machine is replaced. If a replacement does not rejoin within --rejoin-timeout, or the resize fails or is interrupted,
+  the instance type is reverted and the original strategy restored. With the RollingUpdate strategy the control plane
+  machine set then rolls the resized machines back, with the OnDelete strategy they keep the new instance type.
+  The user will be prompted to send a service log once the resize has completed.`,
 		Example: `
   # Resize all control plane instances to m5.4xlarge using control plane machine sets
   osdctl cluster resize control-plane -c "${CLUSTER_ID}" --machine-type m5.4xlarge --reason "${OHSS}"`,
@@ -76,6 +94,7 @@ func newCmdResizeControlPlane() *cobra.Command {
 	resizeControlPlaneNodeCmd.Flags().StringVarP(&ops.clusterID, "cluster-id", "C", "", "The internal ID of the cluster to perform actions on")
 	resizeControlPlaneNodeCmd.Flags().StringVar(&ops.newMachineType, "machine-type", "", "The target AWS machine type to resize to (e.g. m5.2xlarge)")
 	resizeControlPlaneNodeCmd.Flags().StringVar(&ops.reason, "reason", "", "The reason for this command, which requires elevation, to be run (usually an OHSS or PD ticket)")
+	resizeControlPlaneNodeCmd.Flags().DurationVar(&ops.rejoinTimeout, "rejoin-timeout", defaultControlPlaneRejoinTimeout, "How long to wait for each replacement machine to rejoin before reverting the resize")
 	_ = resizeControlPlaneNodeCmd.MarkFlagRequired("cluster-id")
 	_ = resizeControlPlaneNodeCmd.MarkFlagRequired("machine-type")
 	_ = resizeControlPlaneNodeCmd.MarkFlagRequired("reason")
@@ -118,6 +137,16 @@ func (o *controlPlane) New() error {
 	if err := machinev1.Install(scheme); err != nil {
 		return err
 	}
+	// Register machinev1beta1 for Machines, corev1 for Nodes and operatorv1 for the etcd operator status
+	if err := machinev1beta1.Install(scheme); err != nil {
+		return err
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		return err
+	}
+	if err := operatorv1.Install(scheme); err != nil {
+		return err
+	}
 
 	c, err := k8s.New(o.clusterID, client.Options{Scheme: scheme})
 	if err != nil {
@@ -134,6 +163,15 @@ func (o *controlPlane) New() error {
 
 	o.client = c
 	o.clientAdmin = cAdmin
+
+	if cluster.CloudProvider().ID() == "aws" {
+		cfg, err := osdCloud.CreateAWSV2Config(connection, cluster)
+		if err != nil {
+			return err
+		}
+		o.awsClient = ec2.NewFromConfig(cfg)
+		o.quotaClient = servicequotas.NewFromConfig(cfg)
+	}
 	return nil
 }
 
@@ -450,60 +488,49 @@ func (o *controlPlane) run(ctx context.Context) error {
 		return fmt.Errorf("control plane machine set is unexpectedly in %s state, must be %s - check for service logs, support exceptions, ask for a second opinion", cpms.Spec.State, machinev1.ControlPlaneMachineSetStateActive)
 	}
 
-	patch := client.MergeFrom(cpms.DeepCopy())
-
-	var (
-		rawBytes []byte
-		err      error
-	)
-	switch o.cluster.CloudProvider().ID() {
-	case "aws":
-		awsSpec := &machinev1beta1.AWSMachineProviderConfig{}
-		if err := json.Unmarshal(cpms.Spec.Template.OpenShiftMachineV1Beta1Machine.Spec.ProviderSpec.Value.Raw, &awsSpec); err != nil {
-			return fmt.Errorf("error unmarshalling providerSpec: %v", err)
-		}
-		awsSpec.InstanceType = o.newMachineType
+	currentInstanceType, err := providerSpecInstanceType(o.cluster.CloudProvider().ID(), cpms.Spec.Template.OpenShiftMachineV1Beta1Machine.Spec.ProviderSpec)
+	if err != nil {
+		return err
+	}
+	if currentInstanceType == o.newMachineType {
+		return fmt.Errorf("the control plane already uses instance type %s", o.newMachineType)
+	}
 
-		rawBytes, err = json.Marshal(awsSpec)
-		if err != nil {
-			return fmt.Errorf("error marshalling awsSpec: %v", err)
-		}
-	case "gcp":
-		gcpSpec := &machinev1beta1.GCPMachineProviderSpec{}
-		if err := json.Unmarshal(cpms.Spec.Template.OpenShiftMachineV1Beta1Machine.Spec.ProviderSpec.Value.Raw, gcpSpec); err != nil {
-			return fmt.Errorf("error unmarshalling providerSpec: %v", err)
-		}
+	machines, err := listMasterMachines(ctx, o.client)
+	if err != nil {
+		return err
+	}
+	if len(machines) == 0 {
+		return errors.New("no control plane machines found")
+	}
 
-		gcpSpec.MachineType = o.newMachineType
-		rawBytes, err = json.Marshal(gcpSpec)
-		if err != nil {
-			return fmt.Errorf("error marshalling gcpSpec: %v", err)
-		}
-	default:
-		return fmt.Errorf("cloud provider not supported: %s, only AWS and GCP are supported", o.cluster.CloudProvider().ID())
+	if err := o.preflight(ctx, machines, currentInstanceType); err != nil {
+		return err
 	}
 
-	log.Printf("Initiating control plane node resize for cluster %s/%s to %s using control plane machine sets. This process runs asynchronously.", o.cluster.Name(), o.cluster.ID(), o.newMachineType)
+	log.Printf("Initiating control plane node resize for cluster %s/%s from %s to %s, replacing %d machines one at a time.", o.cluster.Name(), o.cluster.ID(), currentInstanceType, o.newMachineType, len(machines))
 	if !utils.ConfirmPrompt() {
 		return errors.New("aborting control plane resize")
 	}
 
-	// Patch the ControlPlaneMachineSet
-	cpms.Spec.Template.OpenShiftMachineV1Beta1Machine.Spec.ProviderSpec.Value = &runtime.RawExtension{Raw: rawBytes}
-	if err := o.clientAdmin.Patch(ctx, cpms, patch); err != nil {
-		return fmt.Errorf("failed patching control plane machine set: %v", err)
+	// Interrupting the rollout cancels its context, which reverts the resize
+	rolloutCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	err = o.rollout(rolloutCtx, machines, currentInstanceType, cpms.Spec.Strategy.Type)
+	stop()
+	if err != nil {
+		return err
 	}
 
-	log.Println("Control plane machine set patched successfully. The resize is now in progress and will complete asynchronously. This command will exit after sending a service log, and any issues will be reported via PagerDuty.")
+	log.Printf("All control plane machines were replaced with instance type %s and etcd is healthy.", o.newMachineType)
 
 	return promptGenerateResizeSL(o.clusterID, o.newMachineType)
 }
 
 func promptGenerateResizeSL(clusterID string, newMachineType string) error {
-	fmt.Println("The resize operation is in progress and will complete asynchronously. A service log will now be sent to document this action. Any issues with the resize will be reported via PagerDuty.")
+	fmt.Println("The resize operation completed. A service log will now be sent to document this action.")
 	fmt.Println("Would you like to proceed with sending the service log?")
 	if !utils.ConfirmPrompt() {
-		fmt.Println("Service log not sent. The resize is still in progress, and this command will now exit. Monitor PagerDuty for any issues.")
+		fmt.Println("Service log not sent, this command will now exit.")
 		return nil
 	}
 
@@ -539,9 +566,7 @@ func promptGenerateResizeSL(clusterID string, newMachineType string) error {
 		return fmt.Errorf("failed to send service log: %v", err)
 	}
 
-	fmt.Println("Service log sent successfully. Use the following command to track progress of the resize:")
-	fmt.Println()
-	fmt.Println(`watch -d 'oc get machines -n openshift-machine-api -l machine.openshift.io/cluster-api-machine-role=master && oc get nodes -l node-role.kubernetes.io/master'`)
+	fmt.Println("Service log sent successfully.")
 
 	return nil
 }
@@ -0,0 +1,268 @@
+package resize
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	masterMachineRoleLabel = "machine.openshift.io/cluster-api-machine-role"
+	machineZoneLabel       = "machine.openshift.io/zone"
+
+	// standardVCPUQuotaCode is the "Running On-Demand Standard (A, C, D, H, I, M, R, T, Z) instances" quota,
+	// which counts vCPUs
+	standardVCPUQuotaCode = "L-1216C47A"
+	standardInstanceTypes = "acdhimrtz"
+
+	etcdMembersAvailableCondition   = "EtcdMembersAvailable"
+	etcdMembersDegradedCondition    = "EtcdMembersDegraded"
+	etcdMembersProgressingCondition = "EtcdMembersProgressing"
+)
+
+// resizeControlPlanePreflightAWSClient is what the control plane resize pre-flight checks need from AWS
+type resizeControlPlanePreflightAWSClient interface {
+	ec2.DescribeInstancesAPIClient
+	DescribeInstanceTypeOfferings(ctx context.Context, params *ec2.DescribeInstanceTypeOfferingsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceTypeOfferingsOutput, error)
+	DescribeInstanceTypes(ctx context.Context, params *ec2.DescribeInstanceTypesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceTypesOutput, error)
+}
+
+type resizeControlPlaneQuotaClient interface {
+	GetServiceQuota(ctx context.Context, params *servicequotas.GetServiceQuotaInput, optFns ...func(*servicequotas.Options)) (*servicequotas.GetServiceQuotaOutput, error)
+}
+
+// listMasterMachines returns the control plane machines sorted by name
+func listMasterMachines(ctx context.Context, c client.Client) ([]machinev1beta1.Machine, error) {
+	machines := &machinev1beta1.MachineList{}
+	if err := c.List(ctx, machines, client.InNamespace(cpmsNamespace), client.MatchingLabels{masterMachineRoleLabel: "master"}); err != nil {
+		return nil, fmt.Errorf("failed to list the control plane machines: %v", err)
+	}
+	sort.Slice(machines.Items, func(i, j int) bool {
+		return machines.Items[i].Name < machines.Items[j].Name
+	})
+	return machines.Items, nil
+}
+
+// providerSpecInstanceType returns the instance type of an AWS or GCP machine provider spec
+func providerSpecInstanceType(cloudProvider string, spec machinev1beta1.ProviderSpec) (string, error) {
+	if spec.Value == nil {
+		return "", errors.New("machine has no providerSpec")
+	}
+	switch cloudProvider {
+	case "aws":
+		awsSpec := &machinev1beta1.AWSMachineProviderConfig{}
+		if err := json.Unmarshal(spec.Value.Raw, awsSpec); err != nil {
+			return "", fmt.Errorf("error unmarshalling providerSpec: %v", err)
+		}
+		return awsSpec.InstanceType, nil
+	case "gcp":
+		gcpSpec := &machinev1beta1.GCPMachineProviderSpec{}
+		if err := json.Unmarshal(spec.Value.Raw, gcpSpec); err != nil {
+			return "", fmt.Errorf("error unmarshalling providerSpec: %v", err)
+		}
+		return gcpSpec.MachineType, nil
+	default:
+		return "", fmt.Errorf("cloud provider not supported: %s, only AWS and GCP are supported", cloudProvider)
+	}
+}
+
+// withProviderSpecInstanceType returns the raw AWS or GCP machine provider spec with its instance type replaced
+func withProviderSpecInstanceType(cloudProvider string, spec machinev1beta1.ProviderSpec, instanceType string) ([]byte, error) {
+	if spec.Value == nil {
+		return nil, errors.New("machine has no providerSpec")
+	}
+	switch cloudProvider {
+	case "aws":
+		awsSpec := &machinev1beta1.AWSMachineProviderConfig{}
+		if err := json.Unmarshal(spec.Value.Raw, awsSpec); err != nil {
+			return nil, fmt.Errorf("error unmarshalling providerSpec: %v", err)
+		}
+		awsSpec.InstanceType = instanceType
+		return json.Marshal(awsSpec)
+	case "gcp":
+		gcpSpec := &machinev1beta1.GCPMachineProviderSpec{}
+		if err := json.Unmarshal(spec.Value.Raw, gcpSpec); err != nil {
+			return nil, fmt.Errorf("error unmarshalling providerSpec: %v", err)
+		}
+		gcpSpec.MachineType = instanceType
+		return json.Marshal(gcpSpec)
+	default:
+		return nil, fmt.Errorf("cloud provider not supported: %s, only AWS and GCP are supported", cloudProvider)
+	}
+}
+
+// preflight checks the resize can succeed before anything is changed: the new instance type must be offered in
+// the availability zone of every control plane machine, the vCPU quota must fit the machines created during the
+// rollout and etcd must be healthy
+func (o *controlPlane) preflight(ctx context.Context, machines []machinev1beta1.Machine, currentInstanceType string) error {
+	if o.cluster.CloudProvider().ID() == "aws" {
+		if err := checkInstanceTypeOffered(ctx, o.awsClient, o.newMachineType, machines); err != nil {
+			return err
+		}
+		if err := checkVCPUQuota(ctx, o.awsClient, o.quotaClient, currentInstanceType, o.newMachineType, len(machines)); err != nil {
+			return err
+		}
+	} else {
+		log.Printf("pre-flight: skipping the instance type availability and quota checks, they are only supported on AWS")
+	}
+
+	if err := checkEtcdHealthy(ctx, o.client); err != nil {
+		return fmt.Errorf("pre-flight: %v", err)
+	}
+	log.Printf("pre-flight: etcd is healthy")
+	return nil
+}
+
+// checkInstanceTypeOffered ensures the instance type is offered in the availability zone of every machine
+func checkInstanceTypeOffered(ctx context.Context, awsClient resizeControlPlanePreflightAWSClient, instanceType string, machines []machinev1beta1.Machine) error {
+	var zones []string
+	for _, machine := range machines {
+		zone := machine.Labels[machineZoneLabel]
+		if zone == "" {
+			return fmt.Errorf("pre-flight: machine %s has no %s label", machine.Name, machineZoneLabel)
+		}
+		if !slices.Contains(zones, zone) {
+			zones = append(zones, zone)
+		}
+	}
+
+	out, err := awsClient.DescribeInstanceTypeOfferings(ctx, &ec2.DescribeInstanceTypeOfferingsInput{
+		LocationType: types.LocationTypeAvailabilityZone,
+		Filters: []types.Filter{
+			{Name: aws.String("instance-type"), Values: []string{instanceType}},
+			{Name: aws.String("location"), Values: zones},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("pre-flight: failed to describe the instance type offerings: %v", err)
+	}
+
+	offered := map[string]bool{}
+	for _, offering := range out.InstanceTypeOfferings {
+		offered[aws.ToString(offering.Location)] = true
+	}
+	var missing []string
+	for _, zone := range zones {
+		if !offered[zone] {
+			missing = append(missing, zone)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("pre-flight: instance type %s is not offered in availability zone(s) %s", instanceType, strings.Join(missing, ", "))
+	}
+	log.Printf("pre-flight: instance type %s is offered in %s", instanceType, strings.Join(zones, ", "))
+	return nil
+}
+
+// requiredVCPUs returns the additional vCPUs needed at the peak of a rollout replacing the given number of machines
+// one at a time. Each replacement machine is created before the machine it replaces is removed.
+func requiredVCPUs(currentVCPUs, newVCPUs int32, machines int) int32 {
+	required := newVCPUs
+	if delta := newVCPUs - currentVCPUs; delta > 0 && machines > 1 {
+		required += int32(machines-1) * delta
+	}
+	return required
+}
+
+// checkVCPUQuota ensures the running On-Demand standard instances vCPU quota leaves room for the replacement machines
+func checkVCPUQuota(ctx context.Context, awsClient resizeControlPlanePreflightAWSClient, quotaClient resizeControlPlaneQuotaClient, currentInstanceType, newInstanceType string, machines int) error {
+	if !strings.ContainsRune(standardInstanceTypes, rune(newInstanceType[0])) {
+		log.Printf("pre-flight: skipping the vCPU quota check, %s is not a standard instance type", newInstanceType)
+		return nil
+	}
+
+	typesOut, err := awsClient.DescribeInstanceTypes(ctx, &ec2.DescribeInstanceTypesInput{
+		InstanceTypes: []types.InstanceType{types.InstanceType(currentInstanceType), types.InstanceType(newInstanceType)},
+	})
+	if err != nil {
+		return fmt.Errorf("pre-flight: failed to describe instance types: %v", err)
+	}
+	vcpus := map[string]int32{}
+	for _, instanceType := range typesOut.InstanceTypes {
+		if instanceType.VCpuInfo != nil {
+			vcpus[string(instanceType.InstanceType)] = aws.ToInt32(instanceType.VCpuInfo.DefaultVCpus)
+		}
+	}
+	if vcpus[newInstanceType] == 0 {
+		return fmt.Errorf("pre-flight: could not determine the vCPUs of instance type %s", newInstanceType)
+	}
+
+	quota, err := quotaClient.GetServiceQuota(ctx, &servicequotas.GetServiceQuotaInput{
+		ServiceCode: aws.String("ec2"),
+		QuotaCode:   aws.String(standardVCPUQuotaCode),
+	})
+	if err != nil {
+		return fmt.Errorf("pre-flight: failed to get the vCPU quota: %v", err)
+	}
+	if quota.Quota == nil || quota.Quota.Value == nil {
+		return errors.New("pre-flight: the vCPU quota has no value")
+	}
+
+	var used int32
+	paginator := ec2.NewDescribeInstancesPaginator(awsClient, &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{{Name: aws.String("instance-state-name"), Values: []string{"pending", "running"}}},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("pre-flight: failed to describe the running instances: %v", err)
+		}
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				if instance.CpuOptions == nil || !strings.ContainsRune(standardInstanceTypes, rune(string(instance.InstanceType)[0])) {
+					continue
+				}
+				used += aws.ToInt32(instance.CpuOptions.CoreCount) * aws.ToInt32(instance.CpuOptions.ThreadsPerCore)
+			}
+		}
+	}
+
+	limit := int32(*quota.Quota.Value)
+	required := requiredVCPUs(vcpus[currentInstanceType], vcpus[newInstanceType], machines)
+	if used+required > limit {
+		return fmt.Errorf("pre-flight: the resize needs %d more vCPUs but only %d of the %d vCPUs of quota %s are left, request a quota increase first",
+			required, limit-used, limit, standardVCPUQuotaCode)
+	}
+	log.Printf("pre-flight: the resize needs up to %d more vCPUs, %d of the %d vCPUs of quota %s are left", required, limit-used, limit, standardVCPUQuotaCode)
+	return nil
+}
+
+// checkEtcdHealthy returns an error unless the etcd operator reports all members available, none of them
+// degraded or still being added, and no other degraded condition
+func checkEtcdHealthy(ctx context.Context, c client.Client) error {
+	etcd := &operatorv1.Etcd{}
+	if err := c.Get(ctx, client.ObjectKey{Name: "cluster"}, etcd); err != nil {
+		return fmt.Errorf("failed to get the etcd operator status: %v", err)
+	}
+
+	statuses := map[string]operatorv1.ConditionStatus{}
+	for _, condition := range etcd.Status.Conditions {
+		statuses[condition.Type] = condition.Status
+		if strings.HasSuffix(condition.Type, "Degraded") && condition.Status == operatorv1.ConditionTrue {
+			return fmt.Errorf("etcd is degraded: %s: %s", condition.Type, condition.Message)
+		}
+		if condition.Type == etcdMembersProgressingCondition && condition.Status == operatorv1.ConditionTrue {
+			return fmt.Errorf("etcd members are still being added: %s", condition.Message)
+		}
+	}
+	if statuses[etcdMembersAvailableCondition] != operatorv1.ConditionTrue {
+		return fmt.Errorf("etcd does not report its members as available")
+	}
+	if statuses[etcdMembersDegradedCondition] != operatorv1.ConditionFalse {
+		return fmt.Errorf("etcd does not report its members as not degraded")
+	}
+	return nil
+}
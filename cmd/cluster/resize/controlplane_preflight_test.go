@@ -0,0 +1,233 @@
+package resize
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	sqtypes "github.com/aws/aws-sdk-go-v2/service/servicequotas/types"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakePreflightAWSClient offers instance types in the given zones, with the given instances running
+type fakePreflightAWSClient struct {
+	offeredZones []string
+	vcpus        map[string]int32
+	running      []types.Instance
+}
+
+func (f *fakePreflightAWSClient) DescribeInstances(_ context.Context, _ *ec2.DescribeInstancesInput, _ ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	return &ec2.DescribeInstancesOutput{Reservations: []types.Reservation{{Instances: f.running}}}, nil
+}
+
+func (f *fakePreflightAWSClient) DescribeInstanceTypeOfferings(_ context.Context, params *ec2.DescribeInstanceTypeOfferingsInput, _ ...func(*ec2.Options)) (*ec2.DescribeInstanceTypeOfferingsOutput, error) {
+	out := &ec2.DescribeInstanceTypeOfferingsOutput{}
+	for _, zone := range f.offeredZones {
+		out.InstanceTypeOfferings = append(out.InstanceTypeOfferings, types.InstanceTypeOffering{
+			InstanceType: types.InstanceType(params.Filters[0].Values[0]),
+			Location:     aws.String(zone),
+			LocationType: types.LocationTypeAvailabilityZone,
+		})
+	}
+	return out, nil
+}
+
+func (f *fakePreflightAWSClient) DescribeInstanceTypes(_ context.Context, params *ec2.DescribeInstanceTypesInput, _ ...func(*ec2.Options)) (*ec2.DescribeInstanceTypesOutput, error) {
+	out := &ec2.DescribeInstanceTypesOutput{}
+	for _, instanceType := range params.InstanceTypes {
+		out.InstanceTypes = append(out.InstanceTypes, types.InstanceTypeInfo{
+			InstanceType: instanceType,
+			VCpuInfo:     &types.VCpuInfo{DefaultVCpus: aws.Int32(f.vcpus[string(instanceType)])},
+		})
+	}
+	return out, nil
+}
+
+type fakeQuotaClient struct {
+	limit float64
+}
+
+func (f *fakeQuotaClient) GetServiceQuota(_ context.Context, _ *servicequotas.GetServiceQuotaInput, _ ...func(*servicequotas.Options)) (*servicequotas.GetServiceQuotaOutput, error) {
+	return &servicequotas.GetServiceQuotaOutput{Quota: &sqtypes.ServiceQuota{Value: aws.Float64(f.limit)}}, nil
+}
+
+func newTestMasterMachines(zones ...string) []machinev1beta1.Machine {
+	var machines []machinev1beta1.Machine
+	for i, zone := range zones {
+		machines = append(machines, machinev1beta1.Machine{ObjectMeta: metav1.ObjectMeta{
+			Name:   "master-" + string(rune('0'+i)),
+			Labels: map[string]string{machineZoneLabel: zone},
+		}})
+	}
+	return machines
+}
+
+func TestCheckInstanceTypeOffered(t *testing.T) {
+	machines := newTestMasterMachines("us-east-1a", "us-east-1b", "us-east-1c")
+
+	tests := []struct {
+		name      string
+		offered   []string
+		expectErr string
+	}{
+		{
+			name:    "offered in every zone",
+			offered: []string{"us-east-1a", "us-east-1b", "us-east-1c"},
+		},
+		{
+			name:      "missing in a zone",
+			offered:   []string{"us-east-1a", "us-east-1c"},
+			expectErr: "instance type m5.4xlarge is not offered in availability zone(s) us-east-1b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkInstanceTypeOffered(context.Background(), &fakePreflightAWSClient{offeredZones: tt.offered}, "m5.4xlarge", machines)
+			if tt.expectErr != "" {
+				assert.EqualError(t, err, "pre-flight: "+tt.expectErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRequiredVCPUs(t *testing.T) {
+	// The first replacement needs all its vCPUs, the following ones only the difference to the machine they replace
+	assert.Equal(t, int32(32), requiredVCPUs(8, 16, 3))
+	assert.Equal(t, int32(8), requiredVCPUs(16, 8, 3))
+	assert.Equal(t, int32(16), requiredVCPUs(8, 16, 1))
+}
+
+func TestCheckVCPUQuota(t *testing.T) {
+	awsClient := &fakePreflightAWSClient{
+		vcpus: map[string]int32{"m5.2xlarge": 8, "m5.4xlarge": 16},
+		running: []types.Instance{
+			{InstanceType: "m5.2xlarge", CpuOptions: &types.CpuOptions{CoreCount: aws.Int32(4), ThreadsPerCore: aws.Int32(2)}},
+			{InstanceType: "m5.2xlarge", CpuOptions: &types.CpuOptions{CoreCount: aws.Int32(4), ThreadsPerCore: aws.Int32(2)}},
+			{InstanceType: "m5.2xlarge", CpuOptions: &types.CpuOptions{CoreCount: aws.Int32(4), ThreadsPerCore: aws.Int32(2)}},
+			// GPU instances are not counted against the standard quota
+			{InstanceType: "p3.2xlarge", CpuOptions: &types.CpuOptions{CoreCount: aws.Int32(4), ThreadsPerCore: aws.Int32(2)}},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		newType   string
+		limit     float64
+		expectErr string
+	}{
+		{
+			name:    "enough quota",
+			newType: "m5.4xlarge",
+			limit:   56,
+		},
+		{
+			name:      "not enough quota",
+			newType:   "m5.4xlarge",
+			limit:     48,
+			expectErr: "pre-flight: the resize needs 32 more vCPUs but only 24 of the 48 vCPUs of quota L-1216C47A are left, request a quota increase first",
+		},
+		{
+			name:    "not a standard instance type",
+			newType: "p3.8xlarge",
+			limit:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkVCPUQuota(context.Background(), awsClient, &fakeQuotaClient{limit: tt.limit}, "m5.2xlarge", tt.newType, 3)
+			if tt.expectErr != "" {
+				assert.EqualError(t, err, tt.expectErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func newTestEtcd(conditions ...operatorv1.OperatorCondition) *operatorv1.Etcd {
+	etcd := &operatorv1.Etcd{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+	etcd.Status.Conditions = conditions
+	return etcd
+}
+
+func TestCheckEtcdHealthy(t *testing.T) {
+	tests := []struct {
+		name      string
+		etcd      *operatorv1.Etcd
+		expectErr string
+	}{
+		{
+			name: "healthy",
+			etcd: newTestEtcd(
+				operatorv1.OperatorCondition{Type: etcdMembersAvailableCondition, Status: operatorv1.ConditionTrue, Message: "3 members are available"},
+				operatorv1.OperatorCondition{Type: etcdMembersDegradedCondition, Status: operatorv1.ConditionFalse},
+				operatorv1.OperatorCondition{Type: etcdMembersProgressingCondition, Status: operatorv1.ConditionFalse},
+				operatorv1.OperatorCondition{Type: "NodeControllerDegraded", Status: operatorv1.ConditionFalse},
+			),
+		},
+		{
+			name: "member unhealthy",
+			etcd: newTestEtcd(
+				operatorv1.OperatorCondition{Type: etcdMembersAvailableCondition, Status: operatorv1.ConditionTrue},
+				operatorv1.OperatorCondition{Type: etcdMembersDegradedCondition, Status: operatorv1.ConditionTrue, Message: "master-1 members are unhealthy"},
+			),
+			expectErr: "etcd is degraded: EtcdMembersDegraded: master-1 members are unhealthy",
+		},
+		{
+			name: "member being added",
+			etcd: newTestEtcd(
+				operatorv1.OperatorCondition{Type: etcdMembersAvailableCondition, Status: operatorv1.ConditionTrue},
+				operatorv1.OperatorCondition{Type: etcdMembersDegradedCondition, Status: operatorv1.ConditionFalse},
+				operatorv1.OperatorCondition{Type: etcdMembersProgressingCondition, Status: operatorv1.ConditionTrue, Message: "master-3 is a learner"},
+			),
+			expectErr: "etcd members are still being added: master-3 is a learner",
+		},
+		{
+			name: "members not available",
+			etcd: newTestEtcd(
+				operatorv1.OperatorCondition{Type: etcdMembersAvailableCondition, Status: operatorv1.ConditionFalse},
+				operatorv1.OperatorCondition{Type: etcdMembersDegradedCondition, Status: operatorv1.ConditionFalse},
+			),
+			expectErr: "etcd does not report its members as available",
+		},
+		{
+			name: "no degraded status",
+			etcd: newTestEtcd(
+				operatorv1.OperatorCondition{Type: etcdMembersAvailableCondition, Status: operatorv1.ConditionTrue},
+			),
+			expectErr: "etcd does not report its members as not degraded",
+		},
+		{
+			name:      "no status",
+			etcd:      newTestEtcd(),
+			expectErr: "etcd does not report its members as available",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			assert.NoError(t, operatorv1.Install(scheme))
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.etcd).Build()
+
+			err := checkEtcdHealthy(context.Background(), c)
+			if tt.expectErr != "" {
+				assert.EqualError(t, err, tt.expectErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
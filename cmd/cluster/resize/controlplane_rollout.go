@@ -0,0 +1,204 @@
+package resize
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	machinev1 "github.com/openshift/api/machine/v1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	defaultControlPlaneRejoinTimeout = 30 * time.Minute
+
+	// controlPlaneRevertTimeout bounds the API calls reverting or restoring the control plane machine set
+	controlPlaneRevertTimeout = 2 * time.Minute
+)
+
+var controlPlaneRejoinPollInterval = 30 * time.Second
+
+// patchControlPlaneMachineSet sets the instance type and update strategy of the control plane machine set
+func (o *controlPlane) patchControlPlaneMachineSet(ctx context.Context, instanceType string, strategy machinev1.ControlPlaneMachineSetStrategyType) error {
+	cpms := &machinev1.ControlPlaneMachineSet{}
+	if err := o.clientAdmin.Get(ctx, client.ObjectKey{Namespace: cpmsNamespace, Name: cpmsName}, cpms); err != nil {
+		return fmt.Errorf("error retrieving control plane machine set: %v", err)
+	}
+	patch := client.MergeFrom(cpms.DeepCopy())
+
+	providerSpec := cpms.Spec.Template.OpenShiftMachineV1Beta1Machine.Spec.ProviderSpec
+	rawBytes, err := withProviderSpecInstanceType(o.cluster.CloudProvider().ID(), providerSpec, instanceType)
+	if err != nil {
+		return err
+	}
+	cpms.Spec.Template.OpenShiftMachineV1Beta1Machine.Spec.ProviderSpec.Value = &runtime.RawExtension{Raw: rawBytes}
+	cpms.Spec.Strategy.Type = strategy
+
+	log.Printf("patching control plane machine set to instance type %s with the %s strategy", instanceType, strategy)
+	if err := o.clientAdmin.Patch(ctx, cpms, patch); err != nil {
+		return fmt.Errorf("failed patching control plane machine set: %v", err)
+	}
+	return nil
+}
+
+// rollout replaces the control plane machines one at a time. The control plane machine set is switched to the
+// OnDelete strategy so it only replaces the machines deleted here, which lets the rollout wait for every replacement
+// to rejoin with etcd healthy before moving to the next machine. Once the control plane machine set was patched,
+// every failure reverts the instance type.
+func (o *controlPlane) rollout(ctx context.Context, machines []machinev1beta1.Machine, originalInstanceType string, originalStrategy machinev1.ControlPlaneMachineSetStrategyType) error {
+	if err := o.patchControlPlaneMachineSet(ctx, o.newMachineType, machinev1.OnDelete); err != nil {
+		return err
+	}
+
+	original := map[string]bool{}
+	for _, machine := range machines {
+		original[machine.Name] = true
+	}
+	// replacements are the machines which replaced an original machine and rejoined
+	var replacements []string
+
+	for i, machine := range machines {
+		instanceType, err := providerSpecInstanceType(o.cluster.CloudProvider().ID(), machine.Spec.ProviderSpec)
+		if err != nil {
+			return o.revert(originalInstanceType, originalStrategy, original, replacements, err)
+		}
+		if instanceType == o.newMachineType {
+			log.Printf("machine %s already has instance type %s, skipping", machine.Name, o.newMachineType)
+			continue
+		}
+
+		log.Printf("[%d/%d] replacing machine %s", i+1, len(machines), machine.Name)
+		if err := o.clientAdmin.Delete(ctx, &machine); err != nil && !apierrors.IsNotFound(err) {
+			return o.revert(originalInstanceType, originalStrategy, original, replacements, fmt.Errorf("failed to delete machine %s: %v", machine.Name, err))
+		}
+
+		replacement, err := o.waitForReplacement(ctx, machine.Name, func(m machinev1beta1.Machine) bool {
+			return !original[m.Name] && !slices.Contains(replacements, m.Name)
+		})
+		if err != nil {
+			return o.revert(originalInstanceType, originalStrategy, original, replacements, fmt.Errorf("machine %s was not replaced within %s: %v", machine.Name, o.rejoinTimeout, err))
+		}
+		replacements = append(replacements, replacement)
+		log.Printf("[%d/%d] machine %s was replaced by %s and etcd is healthy", i+1, len(machines), machine.Name, replacement)
+	}
+
+	// Every machine is up to date, so restoring the original strategy doesn't replace any machine
+	restoreCtx, cancel := context.WithTimeout(context.Background(), controlPlaneRevertTimeout)
+	defer cancel()
+	if err := o.patchControlPlaneMachineSet(restoreCtx, o.newMachineType, originalStrategy); err != nil {
+		return fmt.Errorf("all machines were resized but the %s strategy of the control plane machine set must be restored manually: %v", originalStrategy, err)
+	}
+	return nil
+}
+
+// waitForReplacement waits for the deleted machine to be gone and for a machine matching isReplacement to run a
+// Ready node with the new instance type, with etcd reporting all members available
+func (o *controlPlane) waitForReplacement(ctx context.Context, deleted string, isReplacement func(machinev1beta1.Machine) bool) (string, error) {
+	var replacement string
+	err := wait.PollUntilContextTimeout(ctx, controlPlaneRejoinPollInterval, o.rejoinTimeout, false, func(ctx context.Context) (bool, error) {
+		machines, err := listMasterMachines(ctx, o.client)
+		if err != nil {
+			log.Printf("%v, continuing to wait", err)
+			return false, nil
+		}
+
+		replacement = ""
+		for _, machine := range machines {
+			if machine.Name == deleted {
+				log.Printf("machine %s is still being deleted, continuing to wait", deleted)
+				return false, nil
+			}
+			if !isReplacement(machine) {
+				continue
+			}
+			instanceType, err := providerSpecInstanceType(o.cluster.CloudProvider().ID(), machine.Spec.ProviderSpec)
+			if err != nil || instanceType != o.newMachineType {
+				continue
+			}
+			replacement = machine.Name
+			if !o.machineNodeReady(ctx, machine) {
+				log.Printf("machine %s does not have a Ready node yet, continuing to wait", machine.Name)
+				return false, nil
+			}
+		}
+		if replacement == "" {
+			log.Printf("no replacement machine for %s yet, continuing to wait", deleted)
+			return false, nil
+		}
+
+		// Gate on etcd before replacing the next machine, as the replacement must have joined the etcd cluster
+		if err := checkEtcdHealthy(ctx, o.client); err != nil {
+			log.Printf("%v, continuing to wait", err)
+			return false, nil
+		}
+		return true, nil
+	})
+	return replacement, err
+}
+
+// machineNodeReady returns whether the machine is running and its node is Ready
+func (o *controlPlane) machineNodeReady(ctx context.Context, machine machinev1beta1.Machine) bool {
+	if machine.Status.Phase == nil || *machine.Status.Phase != machinev1beta1.PhaseRunning || machine.Status.NodeRef == nil {
+		return false
+	}
+	node := &corev1.Node{}
+	if err := o.client.Get(ctx, client.ObjectKey{Name: machine.Status.NodeRef.Name}, node); err != nil {
+		return false
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// revert puts the original instance type back in the control plane machine set and deletes the replacement machines
+// which did not rejoin, then restores the original strategy. With the RollingUpdate strategy the control plane machine
+// set then replaces the machines already resized one at a time. The revert runs on its own context, so it still
+// completes when the rollout was interrupted.
+func (o *controlPlane) revert(originalInstanceType string, originalStrategy machinev1.ControlPlaneMachineSetStrategyType, original map[string]bool, replacements []string, cause error) error {
+	log.Printf("resize failed: %v", cause)
+	ctx, cancel := context.WithTimeout(context.Background(), controlPlaneRevertTimeout)
+	defer cancel()
+
+	log.Printf("reverting the control plane machine set to instance type %s", originalInstanceType)
+	if err := o.patchControlPlaneMachineSet(ctx, originalInstanceType, machinev1.OnDelete); err != nil {
+		return fmt.Errorf("%v. Failed to revert the resize, revert the control plane machine set to %s and the %s strategy manually: %v", cause, originalInstanceType, originalStrategy, err)
+	}
+
+	machines, err := listMasterMachines(ctx, o.client)
+	if err != nil {
+		return fmt.Errorf("%v. Failed to revert the resize, delete the machines which did not rejoin and restore the %s strategy manually: %v", cause, originalStrategy, err)
+	}
+	for _, machine := range machines {
+		if original[machine.Name] || slices.Contains(replacements, machine.Name) {
+			continue
+		}
+		log.Printf("deleting machine %s, which did not rejoin, to recreate it as %s", machine.Name, originalInstanceType)
+		if err := o.clientAdmin.Delete(ctx, &machine); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("%v. Failed to revert the resize, delete machine %s and restore the %s strategy manually: %v", cause, machine.Name, originalStrategy, err)
+		}
+	}
+
+	if err := o.patchControlPlaneMachineSet(ctx, originalInstanceType, originalStrategy); err != nil {
+		return fmt.Errorf("%v. Failed to restore the %s strategy of the control plane machine set: %v", cause, originalStrategy, err)
+	}
+	if len(replacements) > 0 {
+		if originalStrategy == machinev1.RollingUpdate {
+			log.Printf("the control plane machine set rolls machines %v back to %s", replacements, originalInstanceType)
+		} else {
+			log.Printf("WARNING: the control plane machine set uses the %s strategy, machines %v keep instance type %s and the control plane has mixed instance types until they are deleted",
+				originalStrategy, replacements, o.newMachineType)
+		}
+	}
+	return fmt.Errorf("%v, the resize was reverted. Watch the rollback with: "+
+		"oc get machines -n openshift-machine-api -l machine.openshift.io/cluster-api-machine-role=master", cause)
+}
@@ -0,0 +1,161 @@
+package resize
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	cmv1 "github.com/openshift-online/ocm-sdk-go/clustersmgmt/v1"
+	machinev1 "github.com/openshift/api/machine/v1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func newTestAWSProviderSpec(t *testing.T, instanceType string) machinev1beta1.ProviderSpec {
+	raw, err := json.Marshal(&machinev1beta1.AWSMachineProviderConfig{InstanceType: instanceType})
+	require.NoError(t, err)
+	return machinev1beta1.ProviderSpec{Value: &runtime.RawExtension{Raw: raw}}
+}
+
+func newTestMasterMachine(t *testing.T, name, instanceType string, ready bool) (*machinev1beta1.Machine, *corev1.Node) {
+	phase := machinev1beta1.PhaseRunning
+	machine := &machinev1beta1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: cpmsNamespace, Labels: map[string]string{masterMachineRoleLabel: "master"}},
+		Spec:       machinev1beta1.MachineSpec{ProviderSpec: newTestAWSProviderSpec(t, instanceType)},
+		Status:     machinev1beta1.MachineStatus{Phase: &phase, NodeRef: &corev1.ObjectReference{Name: name}},
+	}
+	status := corev1.ConditionTrue
+	if !ready {
+		status = corev1.ConditionFalse
+	}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}}},
+	}
+	return machine, node
+}
+
+func TestControlPlaneRollout(t *testing.T) {
+	controlPlaneRejoinPollInterval = 10 * time.Millisecond
+
+	tests := []struct {
+		name string
+		// notReady is the original machine whose replacement never gets a Ready node
+		notReady string
+		// deleteErr is the original machine which fails to be deleted
+		deleteErr      string
+		expectErr      string
+		expectMachines []string
+		expectType     string
+	}{
+		{
+			name:           "every machine rejoins",
+			expectMachines: []string{"master-0-new", "master-1-new", "master-2-new"},
+			expectType:     "m5.4xlarge",
+		},
+		{
+			name:      "a replacement does not rejoin",
+			notReady:  "master-1",
+			expectErr: "machine master-1 was not replaced within 200ms: context deadline exceeded, the resize was reverted",
+			// The replacement which did not rejoin is deleted, the CPMS rolls master-0-new back
+			expectMachines: []string{"master-0-new", "master-2"},
+			expectType:     "m5.2xlarge",
+		},
+		{
+			name:           "a machine fails to be deleted",
+			deleteErr:      "master-1",
+			expectErr:      "failed to delete machine master-1: forbidden, the resize was reverted",
+			expectMachines: []string{"master-0-new", "master-1", "master-2"},
+			expectType:     "m5.2xlarge",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			require.NoError(t, machinev1.Install(scheme))
+			require.NoError(t, machinev1beta1.Install(scheme))
+			require.NoError(t, corev1.AddToScheme(scheme))
+			require.NoError(t, operatorv1.Install(scheme))
+
+			cpms := &machinev1.ControlPlaneMachineSet{ObjectMeta: metav1.ObjectMeta{Name: cpmsName, Namespace: cpmsNamespace}}
+			cpms.Spec.State = machinev1.ControlPlaneMachineSetStateActive
+			cpms.Spec.Strategy.Type = machinev1.RollingUpdate
+			cpms.Spec.Template.OpenShiftMachineV1Beta1Machine = &machinev1.OpenShiftMachineV1Beta1MachineTemplate{
+				Spec: machinev1beta1.MachineSpec{ProviderSpec: newTestAWSProviderSpec(t, "m5.2xlarge")},
+			}
+			objects := []client.Object{cpms, newTestEtcd(
+				operatorv1.OperatorCondition{Type: etcdMembersAvailableCondition, Status: operatorv1.ConditionTrue},
+				operatorv1.OperatorCondition{Type: etcdMembersDegradedCondition, Status: operatorv1.ConditionFalse},
+			)}
+			original := map[string]bool{}
+			for _, name := range []string{"master-0", "master-1", "master-2"} {
+				machine, node := newTestMasterMachine(t, name, "m5.2xlarge", true)
+				objects = append(objects, machine, node)
+				original[name] = true
+			}
+
+			// Deleting an original machine creates its replacement like the control plane machine set would
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).WithInterceptorFuncs(interceptor.Funcs{
+				Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+					if obj.GetName() == tt.deleteErr {
+						return errors.New("forbidden")
+					}
+					if err := c.Delete(ctx, obj, opts...); err != nil {
+						return err
+					}
+					if _, isMachine := obj.(*machinev1beta1.Machine); !isMachine || !original[obj.GetName()] {
+						return nil
+					}
+					machine, node := newTestMasterMachine(t, obj.GetName()+"-new", "m5.4xlarge", obj.GetName() != tt.notReady)
+					if err := c.Create(ctx, machine); err != nil {
+						return err
+					}
+					return c.Create(ctx, node)
+				},
+			}).Build()
+
+			o := &controlPlane{
+				newMachineType: "m5.4xlarge",
+				cluster:        newTestCluster(t, cmv1.NewCluster().CloudProvider(cmv1.NewCloudProvider().ID("aws"))),
+				client:         c,
+				clientAdmin:    c,
+				rejoinTimeout:  200 * time.Millisecond,
+			}
+
+			machines, err := listMasterMachines(context.Background(), c)
+			require.NoError(t, err)
+			err = o.rollout(context.Background(), machines, "m5.2xlarge", machinev1.RollingUpdate)
+			if tt.expectErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			machines, err = listMasterMachines(context.Background(), c)
+			require.NoError(t, err)
+			var names []string
+			for _, machine := range machines {
+				names = append(names, machine.Name)
+			}
+			assert.Equal(t, tt.expectMachines, names)
+
+			require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: cpmsNamespace, Name: cpmsName}, cpms))
+			assert.Equal(t, machinev1.RollingUpdate, cpms.Spec.Strategy.Type, "the original strategy is restored")
+			instanceType, err := providerSpecInstanceType("aws", cpms.Spec.Template.OpenShiftMachineV1Beta1Machine.Spec.ProviderSpec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectType, instanceType)
+		})
+	}
+}
@@ -10,16 +10,11 @@ import (
 	"strings"
 	"time"
 
-	awssdk "github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/ec2"
-	"github.com/aws/smithy-go"
-	sdk "github.com/openshift-online/ocm-sdk-go"
 	cmv1 "github.com/openshift-online/ocm-sdk-go/clustersmgmt/v1"
 	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
 	"github.com/openshift/osdctl/cmd/servicelog"
 	"github.com/openshift/osdctl/pkg/k8s"
-	"github.com/openshift/osdctl/pkg/osdCloud"
 	"github.com/openshift/osdctl/pkg/utils"
 	"github.com/spf13/cobra"
 	corev1 "k8s.io/api/core/v1"
@@ -73,6 +68,12 @@ func newCmdResizeInfra() *cobra.Command {
   This command automates most of the "machinepool dance" to safely resize infra nodes for production classic OSD/ROSA 
   clusters. This DOES NOT work in non-production due to environmental differences.
 
+  Instances of the original infra nodes which do not drain in time are terminated. On GCP they are deleted through the
+  compute API with the application default credentials, see "gcloud auth application-default login".
+
+  HCP clusters are not supported: they have no infra nodes, the hosted control plane runs on the management cluster.
+  Use "osdctl cluster resize request-serving-nodes" to resize the request-serving nodes of an HCP cluster instead.
+
   Remember to follow the SOP for preparation and follow up steps:
 
     https://github.com/openshift/ops-sop/blob/master/v4/howto/resize-infras-workers.md
@@ -132,6 +133,9 @@ func (r *Infra) New() error {
 	if err != nil {
 		return fmt.Errorf("failed to get OCM cluster info for %s: %s", r.clusterId, err)
 	}
+	if cluster.Hypershift().Enabled() {
+		return errors.New("this command should not be used for HCP clusters, which don't have infra nodes, use 'osdctl cluster resize request-serving-nodes' instead")
+	}
 	r.cluster = cluster
 	r.clusterId = cluster.ID()
 
@@ -575,85 +579,16 @@ func (r *Infra) terminateCloudInstances(ctx context.Context, nodeList *corev1.No
 		return nil
 	}
 
-	var instanceIDs []string
-
-	for _, node := range nodeList.Items {
-		instanceIDs = append(instanceIDs, convertProviderIDtoInstanceID(node.Spec.ProviderID))
+	resizer, err := newCloudResizer(r.cluster)
+	if err != nil {
+		return err
 	}
-
-	switch r.cluster.CloudProvider().ID() {
-	case "aws":
-		var ocmClient interface{}
-		if mOCM, ok := ctx.Value("ocm").(interface{ ClustersMgmt() interface{} }); ok {
-			ocmClient = mOCM
-		} else {
-			var err error
-			ocmClient, err = utils.CreateConnection()
-			if err != nil {
-				return err
-			}
-			defer ocmClient.(*sdk.Connection).Close()
+	defer func() {
+		if err := resizer.close(); err != nil {
+			log.Printf("failed to close the cloud provider client: %v", err)
 		}
-
-		if mBuilder, ok := ctx.Value("aws_builder").(interface {
-			CreateAWSV2Config(interface{}, *cmv1.Cluster) (awssdk.Config, error)
-		}); ok {
-			cfg, err := mBuilder.CreateAWSV2Config(ocmClient, r.cluster)
-			if err != nil {
-				return err
-			}
-
-			cfg.Region = r.cluster.Region().ID()
-			awsClient := ec2.NewFromConfig(cfg)
-			_, err = awsClient.TerminateInstances(ctx, &ec2.TerminateInstancesInput{
-				InstanceIds: instanceIDs,
-			})
-			if err != nil {
-				var apiErr smithy.APIError
-				if errors.As(err, &apiErr) {
-					code := apiErr.ErrorCode()
-					message := apiErr.ErrorMessage()
-					log.Printf("AWS ERROR: %v - %v\n", code, message)
-				} else {
-					log.Printf("ERROR: %v\n", err.Error())
-				}
-				return err
-			}
-		} else {
-			cfg, err := osdCloud.CreateAWSV2Config(ocmClient.(*sdk.Connection), r.cluster)
-			if err != nil {
-				return err
-			}
-			awsClient := ec2.NewFromConfig(cfg)
-			_, err = awsClient.TerminateInstances(ctx, &ec2.TerminateInstancesInput{
-				InstanceIds: instanceIDs,
-			})
-			if err != nil {
-				var apiErr smithy.APIError
-				if errors.As(err, &apiErr) {
-					code := apiErr.ErrorCode()
-					message := apiErr.ErrorMessage()
-					log.Printf("AWS ERROR: %v - %v\n", code, message)
-				} else {
-					log.Printf("ERROR: %v\n", err.Error())
-				}
-				return err
-			}
-		}
-
-	case "gcp":
-		// There isn't currently a way to programmatically retrieve backplane credentials for GCP
-		log.Printf("GCP support for manually terminating instances not yet supported. "+
-			"Please use backplane to login and terminate the instances manually: %v", strings.Join(instanceIDs, ", "))
-		return nil
-
-	default:
-		return fmt.Errorf("cloud provider not supported: %s, only AWS is supported", r.cluster.CloudProvider().ID())
-	}
-
-	log.Printf("requested termination of instances: %v", strings.Join(instanceIDs, ", "))
-
-	return nil
+	}()
+	return resizer.terminateInstances(ctx, nodeList.Items)
 }
 
 // convertProviderIDtoInstanceID converts a provider ID to an instance ID
@@ -683,8 +618,11 @@ func (r *Infra) nodesMatchExpectedCount(ctx context.Context, labelSelector label
 }
 
 // validateInstanceSize accepts a string for the requested new instance type and returns an error
-// if the instance type is invalid
+// if the instance type is invalid. GCP machine types are validated against the supported machine families.
 func validateInstanceSize(newInstanceSize string, nodeType string) error {
+	if machineType, ok := parseGCPMachineType(newInstanceSize); ok {
+		return validateGCPMachineType(machineType, nodeType)
+	}
 	if !slices.Contains(supportedInstanceTypes[nodeType], newInstanceSize) {
 		return fmt.Errorf("instance type %s not supported for %s nodes", newInstanceSize, nodeType)
 	}
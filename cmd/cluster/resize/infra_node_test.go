@@ -178,6 +178,56 @@ func TestValidateInstanceSize(t *testing.T) {
 			nodeType:     "controlplane",
 			expectErr:    true,
 		},
+		{
+			instanceSize: "n2-highmem-8",
+			nodeType:     "infra",
+			expectErr:    false,
+		},
+		{
+			instanceSize: "n2-highmem-64",
+			nodeType:     "infra",
+			expectErr:    false,
+		},
+		{
+			instanceSize: "n2-highmem-6",
+			nodeType:     "infra",
+			expectErr:    true,
+		},
+		{
+			instanceSize: "n2-standard-8",
+			nodeType:     "infra",
+			expectErr:    true,
+		},
+		{
+			instanceSize: "custom-32-262144-ext",
+			nodeType:     "infra",
+			expectErr:    false,
+		},
+		{
+			instanceSize: "custom-8-32768-ext",
+			nodeType:     "infra",
+			expectErr:    true,
+		},
+		{
+			instanceSize: "n2-standard-32",
+			nodeType:     "controlplane",
+			expectErr:    false,
+		},
+		{
+			instanceSize: "custom-16-65536",
+			nodeType:     "controlplane",
+			expectErr:    false,
+		},
+		{
+			instanceSize: "custom-16-131072-ext",
+			nodeType:     "controlplane",
+			expectErr:    true,
+		},
+		{
+			instanceSize: "e2-standard-8",
+			nodeType:     "controlplane",
+			expectErr:    true,
+		},
 	}
 
 	for _, test := range tests {
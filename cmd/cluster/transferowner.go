@@ -1,12 +1,11 @@
 package cluster
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
 	"math/rand"
-	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -15,13 +14,13 @@ import (
 	hiveinternalv1alpha1 "github.com/openshift/hive/apis/hiveinternal/v1alpha1"
 	hypershiftv1beta1 "github.com/openshift/hypershift/api/hypershift/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	workv1 "open-cluster-management.io/api/work/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
-	"github.com/openshift/osdctl/cmd/common"
 	"github.com/openshift/osdctl/cmd/servicelog"
 
 	"github.com/openshift-online/ocm-cli/pkg/arguments"
@@ -50,6 +49,9 @@ type transferOwnerOptions struct {
 	newOwnerName string
 	reason       string
 	dryrun       bool
+	resume       bool
+	fromStep     string
+	stateFile    string
 	hypershift   bool
 	cluster      *cmv1.Cluster
 
@@ -60,8 +62,22 @@ type transferOwnerOptions struct {
 func newCmdTransferOwner(streams genericclioptions.IOStreams, globalOpts *globalflags.GlobalOptions) *cobra.Command {
 	ops := newTransferOwnerOptions(streams, globalOpts)
 	transferOwnerCmd := &cobra.Command{
-		Use:               "transfer-owner",
-		Short:             "Transfer cluster ownership to a new user (to be done by Region Lead)",
+		Use:   "transfer-owner",
+		Short: "Transfer cluster ownership to a new user (to be done by Region Lead)",
+		Long: `Transfer cluster ownership to a new user (to be done by Region Lead).
+
+The transfer runs as a sequence of named steps. Every step checks whether its changes are already in
+place before running and verifies them afterwards. The progress is saved after every step, so a
+failed transfer can be continued with --resume once the cause is fixed, or with --from-step to
+re-run a step and every later step.`,
+		Example: `  # Transfer a cluster
+  osdctl cluster transfer-owner -C $CLUSTER_ID --old-owner old-user --new-owner new-user --reason OHSS-1234
+
+  # Continue the transfer after a failure
+  osdctl cluster transfer-owner -C $CLUSTER_ID --old-owner old-user --new-owner new-user --reason OHSS-1234 --resume
+
+  # Re-run the transfer from the role binding swap
+  osdctl cluster transfer-owner -C $CLUSTER_ID --old-owner old-user --new-owner new-user --reason OHSS-1234 --from-step swap-role-binding`,
 		Args:              cobra.NoArgs,
 		DisableAutoGenTag: true,
 		Run: func(cmd *cobra.Command, args []string) {
@@ -72,8 +88,11 @@ func newCmdTransferOwner(streams genericclioptions.IOStreams, globalOpts *global
 	transferOwnerCmd.Flags().StringVarP(&ops.clusterID, "cluster-id", "C", "", "The Internal Cluster ID/External Cluster ID/ Cluster Name")
 	transferOwnerCmd.Flags().StringVar(&ops.oldOwnerName, "old-owner", ops.oldOwnerName, "The old owner's username to transfer the cluster from")
 	transferOwnerCmd.Flags().StringVar(&ops.newOwnerName, "new-owner", ops.newOwnerName, "The new owner's username to transfer the cluster to")
-	transferOwnerCmd.Flags().BoolVarP(&ops.dryrun, "dry-run", "d", false, "Dry-run - show all changes but do not apply them")
+	transferOwnerCmd.Flags().BoolVarP(&ops.dryrun, "dry-run", "d", false, "Dry-run - run the pre-flight checks and show all changes but do not apply them, fails if a check fails")
 	transferOwnerCmd.Flags().StringVar(&ops.reason, "reason", "", "The reason for this command, which requires elevation, to be run (usualy an OHSS or PD ticket)")
+	transferOwnerCmd.Flags().BoolVar(&ops.resume, "resume", false, "Resume an interrupted transfer, skipping the steps it completed")
+	transferOwnerCmd.Flags().StringVar(&ops.fromStep, "from-step", "", "Resume an interrupted transfer from this step, re-running it and every later step. One of: "+strings.Join(transferStepNames(transferSteps()), ", "))
+	transferOwnerCmd.Flags().StringVar(&ops.stateFile, "state-file", "", "File recording the progress of the transfer, defaults to a file per cluster in the user cache directory")
 
 	_ = transferOwnerCmd.MarkFlagRequired("cluster-id")
 	_ = transferOwnerCmd.MarkFlagRequired("old-owner")
@@ -116,10 +135,9 @@ func generateServiceLog(params serviceLogParameters, template string) servicelog
 	}
 }
 
-func updatePullSecret(conn *sdk.Connection, kubeCli client.Client, clientset *kubernetes.Clientset, clusterID string, pullsecret []byte) error {
-	currentEnv := utils.GetCurrentOCMEnv(conn)
+// updatePullSecret replaces the pull secret of the cluster in its Hive namespace and syncs it to the cluster
+func updatePullSecret(kubeCli client.Client, clientset kubernetes.Interface, hiveNamespace string, pullsecret []byte) error {
 	secretName := "pull"
-	hiveNamespace := "uhc-" + currentEnv + "-" + clusterID
 
 	clusterDeployments := &hiveapiv1.ClusterDeploymentList{}
 	if err := kubeCli.List(context.TODO(), clusterDeployments, client.InNamespace(hiveNamespace)); err != nil {
@@ -131,9 +149,9 @@ func updatePullSecret(conn *sdk.Connection, kubeCli client.Client, clientset *ku
 	}
 	cdName := clusterDeployments.Items[0].ObjectMeta.Name
 
-	// Delete the secret
+	// Delete the secret, it is already gone when a previous run failed after deleting it
 	err := clientset.CoreV1().Secrets(hiveNamespace).Delete(context.TODO(), secretName, metav1.DeleteOptions{})
-	if err != nil {
+	if err != nil && !apierrors.IsNotFound(err) {
 		return fmt.Errorf("failed to delete secret %v in namespacd %v: %w", secretName, hiveNamespace, err)
 	}
 
@@ -193,8 +211,9 @@ func awaitPullSecretSyncSet(hiveNamespace string, cdName string, kubeCli client.
 		},
 	}
 
+	// The SyncSet is left behind when a previous run failed while waiting for it
 	err := kubeCli.Create(ctx, syncSet)
-	if err != nil {
+	if err != nil && !apierrors.IsAlreadyExists(err) {
 		return fmt.Errorf("failed to create SyncSet: %w", err)
 	}
 
@@ -249,7 +268,7 @@ func awaitPullSecretSyncSet(hiveNamespace string, cdName string, kubeCli client.
 	return nil
 }
 
-func rolloutPods(clientset *kubernetes.Clientset, namespace, selector string) error {
+func rolloutPods(clientset kubernetes.Interface, namespace, selector string) error {
 	// Delete pods with the specified label selector in the specified namespace.
 	pods, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
 		LabelSelector: selector,
@@ -270,45 +289,69 @@ func rolloutPods(clientset *kubernetes.Clientset, namespace, selector string) er
 	return nil
 }
 
-func verifyClusterPullSecret(clientset *kubernetes.Clientset, expectedPullSecret string) error {
-	// Retrieve the pull secret from the "openshift-config" namespace
+// getGlobalPullSecret returns the global pull secret of the cluster
+func getGlobalPullSecret(clientset kubernetes.Interface) ([]byte, error) {
 	pullSecret, err := clientset.CoreV1().Secrets("openshift-config").Get(context.TODO(), "pull-secret", metav1.GetOptions{})
 	if err != nil {
-		return fmt.Errorf("failed to get pull secret: %w", err)
+		return nil, fmt.Errorf("failed to get pull secret: %w", err)
 	}
 
-	// Print the actual pull secret data
 	pullSecretData, ok := pullSecret.Data[".dockerconfigjson"]
 	if !ok {
-		return fmt.Errorf("pull secret data not found in the secret")
+		return nil, fmt.Errorf("pull secret data not found in the secret")
 	}
+	return pullSecretData, nil
+}
 
-	fmt.Println("Actual Cluster Pull Secret:")
-	fmt.Println(string(pullSecretData))
+// pullSecretAuths returns the credentials of a pull secret by registry
+func pullSecretAuths(pullSecret []byte) map[string]string {
+	var parsed struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(pullSecret, &parsed); err != nil {
+		return nil
+	}
+	auths := map[string]string{}
+	for registry, auth := range parsed.Auths {
+		auths[registry] = auth.Auth
+	}
+	return auths
+}
 
-	// Print the expected pull secret
-	fmt.Println("\nExpected Cluster Pull Secret:")
-	fmt.Println(expectedPullSecret)
+// missingPullSecretAuths returns the registries whose credentials in expected are missing from actual
+func missingPullSecretAuths(actual, expected []byte) []string {
+	actualAuths := pullSecretAuths(actual)
+	var missing []string
+	for registry, auth := range pullSecretAuths(expected) {
+		if actualAuths[registry] != auth {
+			missing = append(missing, registry)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
 
-	// Ask the user to confirm if the actual pull secret matches their expectation
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Print("\nDoes the actual pull secret match your expectation? (yes/no): ")
-	response, err := reader.ReadString('\n')
+// verifyClusterPullSecret checks the pull secret of the cluster has every credential of the expected pull secret
+func verifyClusterPullSecret(clientset kubernetes.Interface, expectedPullSecret []byte) error {
+	actual, err := getGlobalPullSecret(clientset)
 	if err != nil {
-		return fmt.Errorf("failed to read user input: %w", err)
+		return err
 	}
 
-	response = strings.ToLower(strings.TrimSpace(response))
-	if response != "yes" {
-		return fmt.Errorf("operation aborted by the user")
+	if missing := missingPullSecretAuths(actual, expectedPullSecret); len(missing) > 0 {
+		return fmt.Errorf("the cluster pull secret lacks the credentials of the new owner for: %s", strings.Join(missing, ", "))
 	}
 
 	fmt.Println("Pull secret verification successful.")
-
 	return nil
 }
 
-func updateManifestWork(conn *sdk.Connection, kubeCli client.Client, clusterID, mgmtClusterName string, pullsecret []byte) error {
+// manifestWorkSyncWait is how long the pull secret of a ManifestWork takes to reach the guest cluster
+var manifestWorkSyncWait = 60 * time.Second
+
+func updateManifestWork(kubeCli client.Client, clusterID, mgmtClusterName, domainPrefix string, pullsecret []byte) error {
 
 	if err := workv1.AddToScheme(kubeCli.Scheme()); err != nil {
 		return fmt.Errorf("failed to add scheme: %w", err)
@@ -316,13 +359,9 @@ func updateManifestWork(conn *sdk.Connection, kubeCli client.Client, clusterID,
 
 	manifestWorkName := clusterID
 	manifestWorkNamespace := mgmtClusterName
-	hostedCluster, err := utils.GetClusterAnyStatus(conn, clusterID)
-	if err != nil {
-		return fmt.Errorf("failed to get cluster: %w", err)
-	}
 
 	// Use domain prefix here instead of hostedcluster.Name, since the pull secret will follow the domain prefix
-	secretNamePrefix := hostedCluster.DomainPrefix() + "-pull"
+	secretNamePrefix := domainPrefix + "-pull"
 
 	// Generate a random new secret name based on the existing pull secret name
 	randomSuffix := func(chars string, length int) string {
@@ -336,7 +375,7 @@ func updateManifestWork(conn *sdk.Connection, kubeCli client.Client, clusterID,
 	newSecretName := secretNamePrefix + "-" + randomSuffix("0123456789abcdef", 6)
 
 	manifestWork := &workv1.ManifestWork{}
-	err = kubeCli.Get(context.TODO(), types.NamespacedName{Name: manifestWorkName, Namespace: manifestWorkNamespace}, manifestWork)
+	err := kubeCli.Get(context.TODO(), types.NamespacedName{Name: manifestWorkName, Namespace: manifestWorkNamespace}, manifestWork)
 	if err != nil {
 		return fmt.Errorf("failed to get the target manifestwork for given cluster %v: %w", clusterID, err)
 	}
@@ -400,8 +439,8 @@ func updateManifestWork(conn *sdk.Connection, kubeCli client.Client, clusterID,
 	}
 
 	// The secret will be synced to the management cluster and guest cluster in a few seconds, wait here
-	fmt.Println("sleep 60 seconds here to make sure secret gets synced on guest cluster")
-	time.Sleep(time.Second * 60)
+	fmt.Printf("sleep %v here to make sure secret gets synced on guest cluster\n", manifestWorkSyncWait)
+	time.Sleep(manifestWorkSyncWait)
 
 	return nil
 }
@@ -441,7 +480,10 @@ func buildNewSecret(oldpullsecret, newpullsecret []byte) ([]byte, error) {
 }
 
 func (o *transferOwnerOptions) run() error {
-	// Initiate Connections First
+	steps := transferSteps()
+	if o.fromStep != "" && transferStepIndex(steps, o.fromStep) < 0 {
+		return fmt.Errorf("unknown step %q, the steps are: %s", o.fromStep, strings.Join(transferStepNames(steps), ", "))
+	}
 
 	// Create an OCM client to talk to the cluster API
 	// the user has to be logged in (e.g. 'ocm login')
@@ -455,382 +497,199 @@ func (o *transferOwnerOptions) run() error {
 		}
 	}()
 
-	// Gather all required data
 	cluster, err := utils.GetClusterAnyStatus(ocm, o.clusterID)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster %s: %w", o.clusterID, err)
+	}
 	o.cluster = cluster
 	o.clusterID = cluster.ID()
 
-	var mgmtCluster, svcCluster, hiveCluster, masterCluster *cmv1.Cluster
-
-	o.hypershift, err = utils.IsHostedCluster(o.clusterID)
+	statePath := o.stateFile
+	if statePath == "" {
+		statePath, err = defaultTransferStatePath(o.clusterID)
+		if err != nil {
+			return fmt.Errorf("failed to determine the transfer state file: %w", err)
+		}
+	}
+	state, err := loadTransferState(statePath)
 	if err != nil {
-		return fmt.Errorf("failed to check if the given cluster is HCP: %w", err)
+		return err
 	}
 
-	// Find and setup all resources that are needed
-	if o.hypershift {
-		fmt.Println("Given cluster is HCP, start to proceed the HCP owner transfer")
-		mgmtCluster, err = utils.GetManagementCluster(o.clusterID)
-		svcCluster, err = utils.GetServiceCluster(o.clusterID)
-		if err != nil {
-			return err
+	if o.resume || o.fromStep != "" {
+		if state == nil {
+			return fmt.Errorf("there is no transfer of cluster %s to resume in %s", o.clusterID, statePath)
+		}
+		if !state.OldOwner.matches(o.oldOwnerName) || !state.NewOwner.matches(o.newOwnerName) {
+			return fmt.Errorf("the transfer in %s is from '%s' to '%s', not from '%s' to '%s'",
+				statePath, state.OldOwner.Username, state.NewOwner.Username, o.oldOwnerName, o.newOwnerName)
 		}
-		masterCluster = svcCluster
+		fmt.Printf("Resuming the transfer started at %s\n", state.Started.Format(time.RFC3339))
 	} else {
-		fmt.Println("Given cluster is OSD/ROSA classic, start to proceed the classic owner transfer")
-		hiveCluster, err = utils.GetHiveCluster(o.clusterID)
+		if state != nil && state.Finished.IsZero() {
+			return fmt.Errorf("a transfer of cluster %s to '%s' did not finish, continue it with --resume or --from-step, or remove %s to start over",
+				o.clusterID, state.NewOwner.Username, statePath)
+		}
+		fmt.Println("Gathering all required information for the cluster transfer...")
+		state, err = o.gatherTransferState(ocm, cluster)
 		if err != nil {
 			return err
 		}
-		masterCluster = hiveCluster
-	}
-
-	elevationReasons := []string{
-		o.reason,
-		fmt.Sprintf("Updating pull secret using osdctl to tranfert owner to %s", o.newOwnerName),
-	}
-
-	// Gather all required information
-	fmt.Println("Gathering all required information for the cluster transfer...")
-	cluster, err = utils.GetCluster(ocm, o.clusterID)
-	if err != nil {
-		return fmt.Errorf("failed to get cluster information for cluster with ID %s: %w", o.clusterID, err)
-	}
-
-	externalClusterID, ok := cluster.GetExternalID()
-	if !ok {
-		return fmt.Errorf("cluster has no external id")
-	}
-
-	subscription, err := utils.GetSubscription(ocm, o.clusterID)
-	if err != nil {
-		return fmt.Errorf("could not get subscription: %w", err)
-	}
-
-	subscriptionID, ok := subscription.GetID()
-	if !ok {
-		return fmt.Errorf("Could not get subscription id")
-	}
-
-	oldOwnerAccount, err := utils.GetAccount(ocm, o.oldOwnerName)
-	if !ok {
-		return fmt.Errorf("could not get current owner's account, ask the user to log into http://console.redhat.com/ and try again: %w", err)
-	}
-
-	oldOwnerOrganization, ok := oldOwnerAccount.GetOrganization()
-	if !ok {
-		return fmt.Errorf("current owner has no organization")
-	}
-
-	oldOrganizationId, ok := oldOwnerOrganization.GetID()
-	if !ok {
-		return fmt.Errorf("current owner's organization has no ID")
-	}
-
-	newOwnerAccount, err := utils.GetAccount(ocm, o.newOwnerName)
-	if err != nil {
-		return fmt.Errorf("could not get new owner's account, ask the user to log into http://console.redhat.com/ and try again: %w", err)
-	}
-
-	newOwnerOrganization, ok := newOwnerAccount.GetOrganization()
-	if !ok {
-		return fmt.Errorf("new owner has no organization")
-	}
-
-	newOwnerOrganizationId, ok := newOwnerOrganization.GetID()
-	if !ok {
-		return fmt.Errorf("new owner's organization has no ID")
-	}
-
-	newOwnerAccountID, ok := newOwnerAccount.GetID()
-	if !ok {
-		return fmt.Errorf("new owner's account has no id")
+		state.path = statePath
 	}
+	o.hypershift = state.Hypershift
 
-	clusterConsole, ok := cluster.GetConsole()
-	if !ok {
-		return fmt.Errorf("cluster has no console url")
-	}
-
-	clusterURL, ok := clusterConsole.GetURL()
-	if !ok {
-		return fmt.Errorf("cluster has no console url")
-	}
-
-	displayName, ok := subscription.GetDisplayName()
-	if !ok {
-		return fmt.Errorf("subscription has no displayName")
-	}
-
-	oldOwnerAccountID, ok := oldOwnerAccount.GetID()
-	if !ok {
-		return fmt.Errorf("cannot get old owner account id")
-	}
-
-	oldOwnerAccount, err = utils.GetAccount(ocm, oldOwnerAccountID)
-	if err != nil {
-		return fmt.Errorf("cannot get old owner account")
-	}
-
-	oldOwnerUsername, ok := oldOwnerAccount.GetUsername()
-	if !ok {
-		return fmt.Errorf("cannot get old owner username")
-	}
-
-	oldOwnerOrganizationEbsAccountID, ok := oldOwnerOrganization.GetEbsAccountID()
-	if !ok {
-		return fmt.Errorf("cannot get old org ebs id")
-	}
-
-	newOwnerUsername, ok := newOwnerAccount.GetUsername()
-	if !ok {
-		return fmt.Errorf("cannot get new owner username")
+	// Confirm if the ownership transfer looks correct
+	fmt.Printf("Transfer cluster: \t\t'%v' (%v)\n", state.ExternalClusterID, state.ClusterName)
+	fmt.Printf("from user \t\t\t'%v' ('%v') to '%v ('%v')'\n", state.OldOwner.AccountID, state.OldOwner.Username, state.NewOwner.AccountID, state.NewOwner.Username)
+	if state.orgChanged() {
+		fmt.Printf("with organization change from \t'%v' to '%v'\n", state.OldOwner.OrganizationID, state.NewOwner.OrganizationID)
 	}
 
-	newOrganizationEbsAccountID, ok := newOwnerOrganization.GetEbsAccountID()
-	if !ok {
-		return fmt.Errorf("cannot get new org ebs id")
+	runner := newTransferRunner(state, ocm, o.Out)
+	if o.dryrun {
+		report := runner.preflight()
+		runner.printPreflight(o.Out, report, steps, o.fromStep)
+		fmt.Print("\nThis is a dry run, nothing changed.\n")
+		if failures := report.failures(); failures > 0 {
+			return fmt.Errorf("%d of %d pre-flight checks failed", failures, len(report.checks))
+		}
+		return nil
 	}
-
-	// Confirm if the ownership transfer looks correct
-	fmt.Printf("Transfer cluster: \t\t'%v' (%v)\n", externalClusterID, cluster.Name())
-	fmt.Printf("from user \t\t\t'%v' ('%v') to '%v ('%v')'\n", oldOwnerAccount.ID(), oldOwnerUsername, newOwnerAccountID, newOwnerUsername)
 	if !utils.ConfirmPrompt() {
 		return nil
 	}
 
-	orgChanged := oldOrganizationId != newOwnerOrganizationId
-
-	// build common SL parameters struct
-	slParams := serviceLogParameters{
-		ClusterID:             o.clusterID,
-		OldOwnerName:          oldOwnerUsername,
-		OldOwnerID:            oldOwnerOrganizationEbsAccountID,
-		NewOwnerName:          newOwnerUsername,
-		NewOwnerID:            newOrganizationEbsAccountID,
-		IsExternalOrgTransfer: orgChanged,
+	if err := state.save(); err != nil {
+		return err
 	}
+	fmt.Printf("The progress of the transfer is saved in %s\n", statePath)
 
-	// Send a SL saying we're about to start
-	fmt.Println("Notify the customer before ownership transfer commences. Sending service log.")
-	postCmd := generateServiceLog(slParams, SL_TRANSFER_INITIATED)
-	if err := postCmd.Run(); err != nil {
-		fmt.Println("Failed to POST customer service log. Please manually send a service log to notify the customer before ownership transfer commences:")
-		fmt.Printf("osdctl servicelog post %v -t %v -p %v\n",
-			o.clusterID, SL_TRANSFER_INITIATED, strings.Join(postCmd.TemplateParams, " -p "))
+	if err := runner.runSteps(steps, o.fromStep); err != nil {
+		return err
 	}
+	fmt.Print("Transfer complete\n")
 
-	// Send internal SL to cluster with additional details in case we
-	// need them later. This prevents leaking PII to customers.
-	postCmd = generateInternalServiceLog(slParams)
-	fmt.Println("Internal SL Being Sent")
-	if err := postCmd.Run(); err != nil {
-		fmt.Println("Failed to POST internal service log. Please manually send a service log to persist details of the customer transfer before proceeding:")
-		fmt.Println(fmt.Sprintf("osdctl servicelog post -i -p MESSAGE=\"From user '%s' in Red Hat account %s => user '%s' in Red Hat account %s.\" %s", slParams.OldOwnerName, slParams.OldOwnerID, slParams.NewOwnerName, slParams.NewOwnerID, slParams.ClusterID))
-	}
+	return nil
+}
 
-	masterKubeCli, _, masterKubeClientSet, err := common.GetKubeConfigAndClient(masterCluster.ID(), elevationReasons...)
-	if err != nil {
-		return fmt.Errorf("failed to retrieve Kubernetes configuration and client for Hive cluster ID %s: %w", masterCluster.ID(), err)
+// gatherTransferState looks up everything the transfer needs about the cluster and both owners
+func (o *transferOwnerOptions) gatherTransferState(ocm *sdk.Connection, cluster *cmv1.Cluster) (*transferState, error) {
+	state := &transferState{
+		ClusterID:    cluster.ID(),
+		ClusterName:  cluster.Name(),
+		DomainPrefix: cluster.DomainPrefix(),
+		Reason:       o.reason,
+		Started:      time.Now().UTC(),
+		Steps:        map[string]*transferStepResult{},
 	}
 
-	// Fetch the pull secret with the given new username
-	response, err := ocm.AccountsMgmt().V1().AccessToken().Post().Impersonate(newOwnerUsername).Parameter("body", nil).Send()
+	var err error
+	state.Hypershift, err = utils.IsHostedCluster(state.ClusterID)
 	if err != nil {
-		return fmt.Errorf("Can't send request: %w", err)
+		return nil, fmt.Errorf("failed to check if the given cluster is HCP: %w", err)
 	}
 
-	auths, ok := response.Body().GetAuths()
-	if !ok {
-		return fmt.Errorf("Error validating pull secret structure. This shouldn't happen, so you might need to contact SDB")
-	}
-	authsMap := map[string]map[string]string{}
-	for k, auth := range auths {
-		authsMap[k] = map[string]string{
-			"auth":  auth.Auth(),
-			"email": auth.Email(),
+	// Find all clusters that are needed
+	if state.Hypershift {
+		fmt.Println("Given cluster is HCP, start to proceed the HCP owner transfer")
+		mgmtCluster, err := utils.GetManagementCluster(state.ClusterID)
+		if err != nil {
+			return nil, err
 		}
-	}
-
-	pullSecret, err := json.Marshal(map[string]map[string]map[string]string{
-		"auths": authsMap,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to marshal pull secret data: %w", err)
-	}
-
-	// Print the pull secret
-	fmt.Println("Pull Secret:")
-	fmt.Println(string(pullSecret))
-
-	// Ask the user if they would like to continue
-	var continueConfirmation string
-	fmt.Print("Do you want to continue? (yes/no): ")
-	_, err = fmt.Scanln(&continueConfirmation)
-	if err != nil {
-		return fmt.Errorf("failed to read user input: %w", err)
-	}
-
-	// Check the user's response
-	if continueConfirmation != "yes" {
-		return fmt.Errorf("operation aborted by the user")
-	}
-
-	if o.hypershift {
-		err = updateManifestWork(ocm, masterKubeCli, o.clusterID, mgmtCluster.Name(), pullSecret)
+		svcCluster, err := utils.GetServiceCluster(state.ClusterID)
 		if err != nil {
-			return fmt.Errorf("failed to update pull secret for service cluster with ID %s: %w", o.clusterID, err)
+			return nil, err
 		}
+		state.ManagementClusterName = mgmtCluster.Name()
+		state.MasterClusterID = svcCluster.ID()
 	} else {
-		err = updatePullSecret(ocm, masterKubeCli, masterKubeClientSet, o.clusterID, pullSecret)
+		fmt.Println("Given cluster is OSD/ROSA classic, start to proceed the classic owner transfer")
+		hiveCluster, err := utils.GetHiveCluster(state.ClusterID)
 		if err != nil {
-			return fmt.Errorf("failed to update pull secret for Hive cluster with ID %s: %w", o.clusterID, err)
+			return nil, err
 		}
+		state.MasterClusterID = hiveCluster.ID()
 	}
 
-	_, _, targetClientSet, err := common.GetKubeConfigAndClient(o.clusterID, elevationReasons...)
-	if err != nil {
-		return fmt.Errorf("failed to retrieve Kubernetes configuration and client for cluster with ID %s: %w", o.clusterID, err)
+	var ok bool
+	state.ExternalClusterID, ok = cluster.GetExternalID()
+	if !ok {
+		return nil, fmt.Errorf("cluster has no external id")
 	}
 
-	// Rollout the telemeterClient pod for non HCP clusters
-	if !o.hypershift {
-		err = rolloutPods(targetClientSet, "openshift-monitoring", "app.kubernetes.io/name=telemeter-client")
-		if err != nil {
-			return fmt.Errorf("failed to roll out Telemeter Client pods in namespace 'openshift-monitoring' with label selector 'app.kubernetes.io/name=telemeter-client': %w", err)
-		}
+	clusterConsole, ok := cluster.GetConsole()
+	if !ok {
+		return nil, fmt.Errorf("cluster has no console url")
+	}
+	state.ConsoleURL, ok = clusterConsole.GetURL()
+	if !ok {
+		return nil, fmt.Errorf("cluster has no console url")
 	}
 
-	err = verifyClusterPullSecret(targetClientSet, string(pullSecret))
+	subscription, err := utils.GetSubscription(ocm, state.ClusterID)
 	if err != nil {
-		return fmt.Errorf("error verifying cluster pull secret: %w", err)
+		return nil, fmt.Errorf("could not get subscription: %w", err)
 	}
-
-	ok = validateOldOwner(oldOrganizationId, subscription, oldOwnerAccount)
+	state.SubscriptionID, ok = subscription.GetID()
 	if !ok {
-		fmt.Print("can't validate this is old owners cluster, this could be because of a previously failed run\n")
-		if !utils.ConfirmPrompt() {
-			return nil
-		}
+		return nil, fmt.Errorf("Could not get subscription id")
 	}
-
-	subscriptionOrgPatch, err := amv1.NewSubscription().OrganizationID(newOwnerOrganizationId).Build()
-
-	if err != nil {
-		return fmt.Errorf("can't create subscription organization patch: %w", err)
+	state.DisplayName, ok = subscription.GetDisplayName()
+	if !ok {
+		return nil, fmt.Errorf("subscription has no displayName")
 	}
 
-	subscriptionCreatorPatchRequest, err := createSubscriptionCreatorPatchRequest(ocm, subscriptionID, newOwnerAccountID)
-
+	oldOwnerAccount, err := utils.GetAccount(ocm, o.oldOwnerName)
 	if err != nil {
-		return fmt.Errorf("can't create subscription creator patch: %w", err)
+		return nil, fmt.Errorf("could not get current owner's account, ask the user to log into http://console.redhat.com/ and try again: %w", err)
 	}
-
-	newRoleBinding, err := amv1.
-		NewRoleBinding().
-		AccountID(newOwnerAccountID).
-		SubscriptionID(subscriptionID).
-		Type("Subscription").
-		RoleID("ClusterOwner").
-		Build()
-
+	state.OldOwner, err = transferOwnerOf(oldOwnerAccount, "current owner")
 	if err != nil {
-		return fmt.Errorf("can't create new owners rolebinding %w", err)
-	}
-
-	if orgChanged {
-		fmt.Printf("with organization change from \t'%v' to '%v'\n", oldOrganizationId, newOwnerOrganizationId)
-	}
-
-	if o.dryrun {
-		fmt.Print("This is a dry run, nothing changed.\n")
-		return nil
-	}
-
-	// Validation done, now update everything
-
-	// org has to be patched before creator
-	if orgChanged {
-		subscriptionClient := ocm.AccountsMgmt().V1().Subscriptions().Subscription(subscriptionID)
-		response, err := subscriptionClient.Update().Body(subscriptionOrgPatch).Send()
-
-		if err != nil || response.Status() != 200 {
-			return fmt.Errorf("request failed with status: %d, '%w'", response.Status(), err)
-		}
-		fmt.Printf("Patched organization on subscription\n")
-	}
-
-	// patch creator on subscription
-	patchRes, err := subscriptionCreatorPatchRequest.Send()
-
-	if err != nil || patchRes.Status() != 200 {
-		return fmt.Errorf("request failed with status: %d, '%w'", patchRes.Status(), err)
+		return nil, err
 	}
-	fmt.Printf("Patched creator on subscription\n")
-
-	// delete old rolebinding but do not exit on fail could be a rerun
-	err = deleteOldRoleBinding(ocm, subscriptionID)
 
+	newOwnerAccount, err := utils.GetAccount(ocm, o.newOwnerName)
 	if err != nil {
-		fmt.Printf("can't delete old rolebinding %v \n", err)
+		return nil, fmt.Errorf("could not get new owner's account, ask the user to log into http://console.redhat.com/ and try again: %w", err)
 	}
-
-	// create new rolebinding
-	newRoleBindingClient := ocm.AccountsMgmt().V1().RoleBindings()
-	postRes, err := newRoleBindingClient.Add().Body(newRoleBinding).Send()
-
-	// don't fail if the rolebinding already exists, could be rerun
+	state.NewOwner, err = transferOwnerOf(newOwnerAccount, "new owner")
 	if err != nil {
-		return fmt.Errorf("request failed '%w'", err)
-	} else if postRes.Status() == 201 {
-		fmt.Printf("Created new role binding.\n")
-	} else if postRes.Status() == 409 {
-		fmt.Printf("can't add new rolebinding, rolebinding already exists\n")
-	} else {
-		return fmt.Errorf("request failed with status: %d, '%w'", postRes.Status(), err)
+		return nil, err
 	}
 
-	// If the organization id has changed, re-register the cluster with CS with the new organization id
-	if orgChanged {
-
-		request, err := createNewRegisterClusterRequest(ocm, externalClusterID, subscriptionID, newOwnerOrganizationId, clusterURL, displayName)
-		if err != nil {
-			return fmt.Errorf("can't create RegisterClusterRequest with CS, '%w'", err)
-		}
+	return state, nil
+}
 
-		response, err := request.Send()
-		if err != nil || (response.Status() != 200 && response.Status() != 201) {
-			return fmt.Errorf("request failed with status: %d, '%w'", response.Status(), err)
-		}
-		fmt.Print("Re-registered cluster\n")
+// transferOwnerOf returns the details of an account needed for the transfer
+func transferOwnerOf(account *amv1.Account, role string) (transferOwner, error) {
+	owner := transferOwner{}
+	var ok bool
+	owner.AccountID, ok = account.GetID()
+	if !ok {
+		return owner, fmt.Errorf("%s's account has no id", role)
 	}
-
-	// Rollout the ocmAgent pods for non HCP clusters
-	if !o.hypershift {
-		err = rolloutPods(targetClientSet, "openshift-ocm-agent-operator", "app=ocm-agent")
-		if err != nil {
-			return fmt.Errorf("failed to roll out OCM Agent pods in namespace 'openshift-ocm-agent-operator' with label selector 'app=ocm-agent': %w", err)
-		}
+	owner.Username, ok = account.GetUsername()
+	if !ok {
+		return owner, fmt.Errorf("cannot get %s username", role)
 	}
-
-	err = validateTransfer(ocm, subscription.ClusterID(), newOwnerOrganizationId)
-	if err != nil {
-		return fmt.Errorf("error while validating transfer %w", err)
+	organization, ok := account.GetOrganization()
+	if !ok {
+		return owner, fmt.Errorf("%s has no organization", role)
 	}
-	fmt.Print("Transfer complete\n")
-
-	fmt.Println("Notify the customer the ownership transfer is completed. Sending service log.")
-	postCmd = generateServiceLog(slParams, SL_TRANSFER_COMPLETE)
-	if err := postCmd.Run(); err != nil {
-		fmt.Println("Failed to POST service log. Please manually send a service log to notify the customer the ownership transfer is completed:")
-		fmt.Printf("osdctl servicelog post %v -t %v -p %v\n",
-			o.clusterID, SL_TRANSFER_COMPLETE, strings.Join(postCmd.TemplateParams, " -p "))
+	owner.OrganizationID, ok = organization.GetID()
+	if !ok {
+		return owner, fmt.Errorf("%s's organization has no ID", role)
 	}
-
-	return nil
+	owner.EbsAccountID, ok = organization.GetEbsAccountID()
+	if !ok {
+		return owner, fmt.Errorf("cannot get %s org ebs id", role)
+	}
+	return owner, nil
 }
 
-func getRoleBinding(ocm *sdk.Connection, subscriptionID string) (*amv1.RoleBinding, error) {
+// getRoleBindings returns the ClusterOwner role bindings of the subscription
+func getRoleBindings(ocm *sdk.Connection, subscriptionID string) ([]*amv1.RoleBinding, error) {
 	roleBindingQuery := "subscription_id = '%s' and role_id = 'ClusterOwner'"
 	searchString := fmt.Sprintf(roleBindingQuery, subscriptionID)
 	response, err := ocm.AccountsMgmt().V1().RoleBindings().List().Parameter("search", searchString).
@@ -840,47 +699,38 @@ func getRoleBinding(ocm *sdk.Connection, subscriptionID string) (*amv1.RoleBindi
 		return nil, fmt.Errorf("can't send request: %v", err)
 	}
 
-	if response.Total() == 0 {
-		return nil, fmt.Errorf("no rolebinding found")
-	}
-
-	_, ok := response.Items().Get(0).GetID()
-
-	if !ok {
-		return nil, fmt.Errorf("rolebinding has no ID")
-	}
-
-	return response.Items().Get(0), nil
+	return response.Items().Slice(), nil
 }
 
-// deletes old rolebinding by subscription id
-func deleteOldRoleBinding(ocm *sdk.Connection, subscriptionID string) error {
-	oldRoleBinding, err := getRoleBinding(ocm, subscriptionID)
-
+// deletes every ClusterOwner rolebinding of the subscription except the one of the given account
+func deleteOldRoleBindings(ocm *sdk.Connection, subscriptionID string, keepAccountID string) error {
+	roleBindings, err := getRoleBindings(ocm, subscriptionID)
 	if err != nil {
 		return fmt.Errorf("can't get old owners rolebinding %w", err)
 	}
 
-	oldRoleBindingID, ok := oldRoleBinding.GetID()
-	if !ok {
-		return fmt.Errorf("old rolebinding has no id %w", err)
-	}
-	oldRoleBindingClient := ocm.AccountsMgmt().V1().RoleBindings().RoleBinding(oldRoleBindingID)
-
-	response, err := oldRoleBindingClient.Delete().Send()
+	for _, roleBinding := range roleBindings {
+		if roleBinding.Account().ID() == keepAccountID {
+			continue
+		}
+		oldRoleBindingID, ok := roleBinding.GetID()
+		if !ok {
+			return fmt.Errorf("old rolebinding has no id")
+		}
 
-	if err != nil {
-		return fmt.Errorf("request failed '%w'", err)
-	}
-	if response.Status() == 204 {
+		response, err := ocm.AccountsMgmt().V1().RoleBindings().RoleBinding(oldRoleBindingID).Delete().Send()
+		if response != nil && response.Status() == 404 {
+			fmt.Printf("can't find old rolebinding: %v\n", oldRoleBindingID)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("request failed '%w'", err)
+		}
+		if response.Status() != 204 {
+			return fmt.Errorf("request failed with status: %d", response.Status())
+		}
 		fmt.Printf("Deleted old rolebinding: %v\n", oldRoleBindingID)
-		return nil
-	}
-	if response.Status() == 404 {
-		fmt.Printf("can't find old rolebinding: %v\n", oldRoleBindingID)
-		return nil
 	}
-	fmt.Printf("request failed with status: %d\n", response.Status())
 	return nil
 }
 
@@ -951,8 +801,8 @@ func validateTransfer(ocm *sdk.Connection, clusterID string, newOrgID string) er
 	response, err := ocm.ClustersMgmt().V1().Clusters().List().Parameter("search", searchString).
 		Send()
 
-	if err != nil || response.Status() != 200 {
-		return fmt.Errorf("request failed with status: %d, '%w'", response.Status(), err)
+	if err != nil {
+		return fmt.Errorf("request failed '%w'", err)
 	}
 
 	if response.Total() == 0 {
@@ -0,0 +1,440 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	amv1 "github.com/openshift-online/ocm-sdk-go/accountsmgmt/v1"
+	cmv1 "github.com/openshift-online/ocm-sdk-go/clustersmgmt/v1"
+	hiveapiv1 "github.com/openshift/hive/apis/hive/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	workv1 "open-cluster-management.io/api/work/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/osdctl/pkg/printer"
+	"github.com/openshift/osdctl/pkg/utils"
+)
+
+const (
+	preflightPass = "PASS"
+	preflightWarn = "WARN"
+	preflightFail = "FAIL"
+
+	redactedAuth = "<redacted>"
+)
+
+// preflightCheck is the result of a check run before a transfer changes anything
+type preflightCheck struct {
+	Name    string
+	Status  string
+	Message string
+}
+
+func passed(name, format string, args ...interface{}) preflightCheck {
+	return preflightCheck{Name: name, Status: preflightPass, Message: fmt.Sprintf(format, args...)}
+}
+
+func warned(name, format string, args ...interface{}) preflightCheck {
+	return preflightCheck{Name: name, Status: preflightWarn, Message: fmt.Sprintf(format, args...)}
+}
+
+func failed(name, format string, args ...interface{}) preflightCheck {
+	return preflightCheck{Name: name, Status: preflightFail, Message: fmt.Sprintf(format, args...)}
+}
+
+// transferPreflight is the pre-flight report of a transfer
+type transferPreflight struct {
+	checks []preflightCheck
+	// newPullSecret is the pull secret the cluster will have after the transfer
+	newPullSecret []byte
+	// oldPullSecret is the current pull secret of the cluster
+	oldPullSecret []byte
+}
+
+func (p *transferPreflight) failures() int {
+	count := 0
+	for _, check := range p.checks {
+		if check.Status == preflightFail {
+			count++
+		}
+	}
+	return count
+}
+
+// preflight checks the transfer can run without changing anything
+func (r *transferRunner) preflight() *transferPreflight {
+	report := &transferPreflight{}
+	report.checks = append(report.checks,
+		r.checkClusterState(),
+		r.checkSubscriptionOwner(),
+		r.checkNewOwnerAccount(),
+	)
+	report.checks = append(report.checks, r.checkQuota()...)
+	report.checks = append(report.checks, r.checkMasterClusterAccess(), r.checkPullSecret(report))
+	return report
+}
+
+func (r *transferRunner) cluster() (*cmv1.Cluster, error) {
+	response, err := r.ocm.ClustersMgmt().V1().Clusters().Cluster(r.state.ClusterID).Get().Send()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster %s: %w", r.state.ClusterID, err)
+	}
+	return response.Body(), nil
+}
+
+func (r *transferRunner) checkClusterState() preflightCheck {
+	const name = "cluster-state"
+	cluster, err := r.cluster()
+	if err != nil {
+		return failed(name, "%v", err)
+	}
+	if cluster.State() != cmv1.ClusterStateReady {
+		return failed(name, "cluster is %s, the transfer needs a ready cluster", cluster.State())
+	}
+	return passed(name, "cluster is ready")
+}
+
+func (r *transferRunner) checkSubscriptionOwner() preflightCheck {
+	const name = "subscription-owner"
+	subscription, err := r.subscription()
+	if err != nil {
+		return failed(name, "%v", err)
+	}
+	if subscription.OrganizationID() == r.state.NewOwner.OrganizationID && subscription.Creator().ID() == r.state.NewOwner.AccountID {
+		return warned(name, "subscription %s already belongs to the new owner, a previous transfer may have been interrupted", r.state.SubscriptionID)
+	}
+	if subscription.OrganizationID() != r.state.OldOwner.OrganizationID {
+		return failed(name, "subscription %s is in organization %s, not in the old owner's organization %s",
+			r.state.SubscriptionID, subscription.OrganizationID(), r.state.OldOwner.OrganizationID)
+	}
+	if subscription.Creator().ID() != r.state.OldOwner.AccountID {
+		return failed(name, "subscription %s is owned by account %s, not by the old owner %s",
+			r.state.SubscriptionID, subscription.Creator().ID(), r.state.OldOwner.AccountID)
+	}
+	return passed(name, "subscription %s belongs to the old owner", r.state.SubscriptionID)
+}
+
+func (r *transferRunner) checkNewOwnerAccount() preflightCheck {
+	const name = "new-owner-account"
+	if r.state.OldOwner.AccountID == r.state.NewOwner.AccountID {
+		return failed(name, "the old and the new owner are the same account %s", r.state.NewOwner.AccountID)
+	}
+	response, err := r.ocm.AccountsMgmt().V1().Accounts().Account(r.state.NewOwner.AccountID).Get().Send()
+	if err != nil {
+		return failed(name, "failed to get account %s: %v", r.state.NewOwner.AccountID, err)
+	}
+	if response.Body().Banned() {
+		return failed(name, "account %s of '%s' is banned", r.state.NewOwner.AccountID, r.state.NewOwner.Username)
+	}
+	return passed(name, "account %s of '%s' in organization %s is active", r.state.NewOwner.AccountID, r.state.NewOwner.Username, r.state.NewOwner.OrganizationID)
+}
+
+// checkQuota checks the new organization is entitled to the product of the cluster and has quota left for it
+func (r *transferRunner) checkQuota() []preflightCheck {
+	const entitlementName = "entitlement"
+	const quotaName = "quota"
+	if !r.state.orgChanged() {
+		return []preflightCheck{
+			passed(entitlementName, "the cluster stays in organization %s", r.state.NewOwner.OrganizationID),
+			passed(quotaName, "the cluster stays in organization %s", r.state.NewOwner.OrganizationID),
+		}
+	}
+
+	cluster, err := r.cluster()
+	if err != nil {
+		return []preflightCheck{failed(entitlementName, "%v", err), failed(quotaName, "%v", err)}
+	}
+	quotaCosts, err := r.quotaCosts(r.state.NewOwner.OrganizationID)
+	if err != nil {
+		return []preflightCheck{failed(entitlementName, "%v", err), failed(quotaName, "%v", err)}
+	}
+
+	product := cluster.Product().ID()
+	billingModel := string(cluster.BillingModel())
+	byoc := "rhinfra"
+	if cluster.CCS().Enabled() {
+		byoc = "byoc"
+	}
+	required := fmt.Sprintf("%s clusters (billing model %s, %s)", product, billingModel, byoc)
+
+	entitled := false
+	for _, quotaCost := range quotaCosts {
+		for _, resource := range quotaCost.RelatedResources() {
+			if !clusterResourceMatches(resource, product, billingModel, byoc, cluster.CloudProvider().ID()) {
+				continue
+			}
+			entitled = true
+			if resource.Cost() == 0 || quotaCost.Allowed()-quotaCost.Consumed() >= resource.Cost() {
+				return []preflightCheck{
+					passed(entitlementName, "organization %s is entitled to %s", r.state.NewOwner.OrganizationID, required),
+					passed(quotaName, "quota %s has %d of %d left", quotaCost.QuotaID(), quotaCost.Allowed()-quotaCost.Consumed(), quotaCost.Allowed()),
+				}
+			}
+		}
+	}
+	if !entitled {
+		return []preflightCheck{
+			failed(entitlementName, "organization %s is not entitled to %s", r.state.NewOwner.OrganizationID, required),
+			failed(quotaName, "organization %s has no quota for %s", r.state.NewOwner.OrganizationID, required),
+		}
+	}
+	return []preflightCheck{
+		passed(entitlementName, "organization %s is entitled to %s", r.state.NewOwner.OrganizationID, required),
+		failed(quotaName, "organization %s has no quota left for %s", r.state.NewOwner.OrganizationID, required),
+	}
+}
+
+// clusterResourceMatches returns whether a quota applies to the cluster, "any" matching every value
+func clusterResourceMatches(resource *amv1.RelatedResource, product, billingModel, byoc, cloudProvider string) bool {
+	matches := func(quotaValue, clusterValue string) bool {
+		return quotaValue == "any" || strings.EqualFold(quotaValue, clusterValue)
+	}
+	return resource.ResourceType() == "cluster" &&
+		matches(resource.Product(), product) &&
+		matches(resource.BillingModel(), billingModel) &&
+		matches(resource.BYOC(), byoc) &&
+		(resource.CloudProvider() == "" || matches(resource.CloudProvider(), cloudProvider))
+}
+
+func (r *transferRunner) quotaCosts(organizationID string) ([]*amv1.QuotaCost, error) {
+	var quotaCosts []*amv1.QuotaCost
+	for page := 1; ; page++ {
+		response, err := r.ocm.AccountsMgmt().V1().Organizations().Organization(organizationID).QuotaCost().List().
+			Parameter("fetchRelatedResources", true).
+			Page(page).
+			Size(100).
+			Send()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the quota of organization %s: %w", organizationID, err)
+		}
+		quotaCosts = append(quotaCosts, response.Items().Slice()...)
+		if response.Size() < 100 {
+			return quotaCosts, nil
+		}
+	}
+}
+
+// checkMasterClusterAccess checks the resources holding the pull secret can be read on the Hive or service cluster
+func (r *transferRunner) checkMasterClusterAccess() preflightCheck {
+	const name = "master-cluster-access"
+	kubeCli, _, err := r.masterClients()
+	if err != nil {
+		return failed(name, "%v", err)
+	}
+
+	if r.state.Hypershift {
+		if err := workv1.AddToScheme(kubeCli.Scheme()); err != nil {
+			return failed(name, "failed to add scheme: %v", err)
+		}
+		manifestWork := &workv1.ManifestWork{}
+		key := types.NamespacedName{Name: r.state.ClusterID, Namespace: r.state.ManagementClusterName}
+		if err := kubeCli.Get(context.TODO(), key, manifestWork); err != nil {
+			return failed(name, "failed to get ManifestWork %s on service cluster %s: %v", key, r.state.MasterClusterID, err)
+		}
+		return passed(name, "ManifestWork %s found on service cluster %s", key, r.state.MasterClusterID)
+	}
+
+	hiveNamespace := r.hiveNamespace()
+	clusterDeployments := &hiveapiv1.ClusterDeploymentList{}
+	if err := kubeCli.List(context.TODO(), clusterDeployments, client.InNamespace(hiveNamespace)); err != nil {
+		return failed(name, "failed to list cluster deployments in namespace %s on Hive %s: %v", hiveNamespace, r.state.MasterClusterID, err)
+	}
+	if len(clusterDeployments.Items) == 0 {
+		return failed(name, "no ClusterDeployment in namespace %s on Hive %s", hiveNamespace, r.state.MasterClusterID)
+	}
+	return passed(name, "ClusterDeployment %s/%s found on Hive %s", hiveNamespace, clusterDeployments.Items[0].Name, r.state.MasterClusterID)
+}
+
+// checkPullSecret checks the pull secret of the new owner can be fetched and computes the pull secret
+// the cluster will have after the transfer
+func (r *transferRunner) checkPullSecret(report *transferPreflight) preflightCheck {
+	const name = "pull-secret"
+	newPullSecret, err := r.newPullSecret()
+	if err != nil {
+		return failed(name, "%v", err)
+	}
+	if len(pullSecretAuths(newPullSecret)) == 0 {
+		return failed(name, "the pull secret of '%s' has no registry credentials", r.state.NewOwner.Username)
+	}
+
+	targetClientSet, err := r.targetClient()
+	if err != nil {
+		return failed(name, "%v", err)
+	}
+	oldPullSecret, err := getGlobalPullSecret(targetClientSet)
+	if err != nil {
+		return failed(name, "%v", err)
+	}
+	report.oldPullSecret = oldPullSecret
+
+	// HCP clusters keep the credentials of the old pull secret the new one lacks, e.g. for ECR
+	report.newPullSecret = newPullSecret
+	if r.state.Hypershift {
+		report.newPullSecret, err = buildNewSecret(oldPullSecret, newPullSecret)
+		if err != nil {
+			return failed(name, "cannot build the new pull secret: %v", err)
+		}
+	}
+	return passed(name, "fetched the pull secret of '%s' with %d registries", r.state.NewOwner.Username, len(pullSecretAuths(newPullSecret)))
+}
+
+// redactPullSecret returns the pull secret with every credential replaced
+func redactPullSecret(pullSecret []byte) ([]byte, error) {
+	var parsed map[string]map[string]map[string]interface{}
+	if err := json.Unmarshal(pullSecret, &parsed); err != nil {
+		return nil, err
+	}
+	for _, auth := range parsed["auths"] {
+		for key := range auth {
+			if key == "auth" || key == "password" || key == "identitytoken" {
+				auth[key] = redactedAuth
+			}
+		}
+	}
+	// Keep the <redacted> marker readable instead of escaping it as HTML
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(parsed); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+func (r *transferRunner) hiveNamespace() string {
+	return "uhc-" + utils.GetCurrentOCMEnv(r.ocm) + "-" + r.state.ClusterID
+}
+
+// printPreflight prints the pre-flight checks, the new pull secret and every change of the transfer
+func (r *transferRunner) printPreflight(out io.Writer, report *transferPreflight, steps []transferStep, fromStep string) {
+	fmt.Fprintf(out, "\nOld owner: '%s' (account %s, organization %s, EBS account %s)\n",
+		r.state.OldOwner.Username, r.state.OldOwner.AccountID, r.state.OldOwner.OrganizationID, r.state.OldOwner.EbsAccountID)
+	fmt.Fprintf(out, "New owner: '%s' (account %s, organization %s, EBS account %s)\n",
+		r.state.NewOwner.Username, r.state.NewOwner.AccountID, r.state.NewOwner.OrganizationID, r.state.NewOwner.EbsAccountID)
+
+	fmt.Fprintln(out, "\nPre-flight checks:")
+	table := printer.NewTablePrinter(out, 20, 1, 3, ' ')
+	table.AddRow([]string{"CHECK", "STATUS", "DETAILS"})
+	for _, check := range report.checks {
+		table.AddRow([]string{check.Name, check.Status, check.Message})
+	}
+	if err := table.Flush(); err != nil {
+		fmt.Fprintln(out, "error while flushing table: ", err.Error())
+	}
+
+	if report.newPullSecret != nil {
+		fmt.Fprintln(out, "\nNew cluster pull secret (credentials redacted):")
+		redacted, err := redactPullSecret(report.newPullSecret)
+		if err != nil {
+			fmt.Fprintf(out, "cannot redact the pull secret: %v\n", err)
+		} else {
+			fmt.Fprintln(out, string(redacted))
+		}
+		if report.oldPullSecret != nil {
+			fmt.Fprintln(out, pullSecretChanges(report.oldPullSecret, report.newPullSecret))
+		}
+	}
+
+	fmt.Fprintln(out, "\nChange plan:")
+	started := fromStep == ""
+	for i, step := range steps {
+		started = started || step.name == fromStep
+		switch {
+		case !started:
+			fmt.Fprintf(out, "%d. %s: skipped, before --from-step\n", i+1, step.name)
+			continue
+		case step.applies != nil && !step.applies(r.state):
+			fmt.Fprintf(out, "%d. %s: not needed for this cluster\n", i+1, step.name)
+			continue
+		case fromStep == "" && r.state.completed(step.name):
+			fmt.Fprintf(out, "%d. %s: completed in a previous run\n", i+1, step.name)
+			continue
+		}
+
+		fmt.Fprintf(out, "%d. %s: %s\n", i+1, step.name, step.description)
+		if step.plan == nil {
+			continue
+		}
+		actions, err := step.plan(r)
+		if err != nil {
+			fmt.Fprintf(out, "   - cannot determine the changes: %v\n", err)
+		}
+		for _, action := range actions {
+			fmt.Fprintf(out, "   - %s\n", action)
+		}
+	}
+}
+
+// pullSecretChanges summarizes which registries the new pull secret adds, updates or keeps
+func pullSecretChanges(oldPullSecret, newPullSecret []byte) string {
+	oldAuths := pullSecretAuths(oldPullSecret)
+	var added, updated, kept []string
+	for registry, auth := range pullSecretAuths(newPullSecret) {
+		oldAuth, ok := oldAuths[registry]
+		switch {
+		case !ok:
+			added = append(added, registry)
+		case oldAuth != auth:
+			updated = append(updated, registry)
+		default:
+			kept = append(kept, registry)
+		}
+	}
+	var removed []string
+	newAuths := pullSecretAuths(newPullSecret)
+	for registry := range oldAuths {
+		if _, ok := newAuths[registry]; !ok {
+			removed = append(removed, registry)
+		}
+	}
+
+	format := func(registries []string) string {
+		if len(registries) == 0 {
+			return "-"
+		}
+		sort.Strings(registries)
+		return strings.Join(registries, ", ")
+	}
+	return fmt.Sprintf("Registries added: %s\nRegistries with new credentials: %s\nRegistries unchanged: %s\nRegistries removed: %s",
+		format(added), format(updated), format(kept), format(removed))
+}
+
+// planServiceLog describes a service log the transfer sends
+func planServiceLog(description, template string) string {
+	return fmt.Sprintf("POST service log %s (%s)", template, description)
+}
+
+// podNames lists the names of the pods a rollout deletes
+func (r *transferRunner) podNames(namespace, selector string) ([]string, error) {
+	targetClientSet, err := r.targetClient()
+	if err != nil {
+		return nil, err
+	}
+	pods, err := targetClientSet.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods in namespace '%s' with label selector '%s': %w", namespace, selector, err)
+	}
+	var names []string
+	for _, pod := range pods.Items {
+		names = append(names, pod.Name)
+	}
+	return names, nil
+}
+
+func (r *transferRunner) planRollout(namespace, selector string) ([]string, error) {
+	names, err := r.podNames(namespace, selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return []string{fmt.Sprintf("DELETE pods in namespace %s with label selector '%s' (none running)", namespace, selector)}, nil
+	}
+	return []string{fmt.Sprintf("DELETE pods in namespace %s with label selector '%s': %s", namespace, selector, strings.Join(names, ", "))}, nil
+}
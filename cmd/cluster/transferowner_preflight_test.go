@@ -0,0 +1,210 @@
+package cluster
+
+import (
+	"bytes"
+	"testing"
+
+	hiveapiv1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func clusterQuota(allowed, consumed, cost int, product, billingModel, byoc string) interface{} {
+	return map[string]interface{}{
+		"kind":     "QuotaCost",
+		"quota_id": "cluster|byoc|" + product,
+		"allowed":  allowed,
+		"consumed": consumed,
+		"related_resources": []interface{}{map[string]interface{}{
+			"resource_type":  "cluster",
+			"resource_name":  "any",
+			"product":        product,
+			"billing_model":  billingModel,
+			"byoc":           byoc,
+			"cloud_provider": "any",
+			"cost":           cost,
+		}},
+	}
+}
+
+// newPreflightRunner returns a runner for a classic cluster whose Hive and cluster are served by fakes
+func newPreflightRunner(t *testing.T, ocm *fakeOCM, withClusterDeployment bool) (*transferRunner, *bytes.Buffer) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, hiveapiv1.AddToScheme(scheme))
+	var objects []client.Object
+	if withClusterDeployment {
+		objects = append(objects, &hiveapiv1.ClusterDeployment{ObjectMeta: metav1.ObjectMeta{Name: "mycluster", Namespace: "uhc-production-cluster-1"}})
+	}
+
+	state := newTestTransferState(t)
+	runner, out := newTestTransferRunner(t, state, newFakeOCMConnection(t, ocm))
+	runner.masterKubeCli = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+	runner.masterClientSet = kubefake.NewSimpleClientset()
+	runner.targetClientSet = kubefake.NewSimpleClientset(
+		pullSecretObject("openshift-config", "pull-secret", `{"auths":{"cloud.openshift.com":{"auth":"b2xk","email":"old@example.com"},"registry.example.com":{"auth":"b3RoZXI="}}}`),
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "telemeter-client-1", Namespace: "openshift-monitoring", Labels: map[string]string{"app.kubernetes.io/name": "telemeter-client"}}},
+	)
+	return runner, out
+}
+
+func checkStatuses(report *transferPreflight) map[string]string {
+	statuses := map[string]string{}
+	for _, check := range report.checks {
+		statuses[check.Name] = check.Status
+	}
+	return statuses
+}
+
+func TestPreflight(t *testing.T) {
+	tests := []struct {
+		name                  string
+		setup                 func(ocm *fakeOCM)
+		withClusterDeployment bool
+		expected              map[string]string
+	}{
+		{
+			name: "every check passes",
+			setup: func(ocm *fakeOCM) {
+				ocm.quotaCosts = []interface{}{clusterQuota(5, 2, 1, "OSD", "standard", "byoc")}
+			},
+			withClusterDeployment: true,
+			expected: map[string]string{
+				"cluster-state": preflightPass, "subscription-owner": preflightPass, "new-owner-account": preflightPass,
+				"entitlement": preflightPass, "quota": preflightPass, "master-cluster-access": preflightPass, "pull-secret": preflightPass,
+			},
+		},
+		{
+			name: "quota of another product",
+			setup: func(ocm *fakeOCM) {
+				ocm.quotaCosts = []interface{}{clusterQuota(5, 0, 1, "ROSA", "standard", "byoc")}
+			},
+			withClusterDeployment: true,
+			expected:              map[string]string{"entitlement": preflightFail, "quota": preflightFail},
+		},
+		{
+			name: "quota exhausted",
+			setup: func(ocm *fakeOCM) {
+				ocm.quotaCosts = []interface{}{clusterQuota(2, 2, 1, "osd", "any", "byoc")}
+			},
+			withClusterDeployment: true,
+			expected:              map[string]string{"entitlement": preflightPass, "quota": preflightFail},
+		},
+		{
+			name: "marketplace quota without cost",
+			setup: func(ocm *fakeOCM) {
+				ocm.quotaCosts = []interface{}{clusterQuota(0, 0, 0, "any", "standard", "any")}
+			},
+			withClusterDeployment: true,
+			expected:              map[string]string{"entitlement": preflightPass, "quota": preflightPass},
+		},
+		{
+			name: "cluster not ready, banned owner and subscription of someone else",
+			setup: func(ocm *fakeOCM) {
+				ocm.clusterState = "hibernating"
+				ocm.newOwnerBanned = true
+				ocm.creatorID = "acc-someone-else"
+			},
+			withClusterDeployment: true,
+			expected: map[string]string{
+				"cluster-state": preflightFail, "subscription-owner": preflightFail, "new-owner-account": preflightFail,
+			},
+		},
+		{
+			name: "interrupted transfer",
+			setup: func(ocm *fakeOCM) {
+				ocm.subscriptionOrg = "org-new"
+				ocm.creatorID = "acc-new"
+			},
+			withClusterDeployment: true,
+			expected:              map[string]string{"subscription-owner": preflightWarn},
+		},
+		{
+			name:                  "no ClusterDeployment on Hive",
+			withClusterDeployment: false,
+			expected:              map[string]string{"master-cluster-access": preflightFail},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ocm := newFakeOCM()
+			if tt.setup != nil {
+				tt.setup(ocm)
+			}
+			runner, _ := newPreflightRunner(t, ocm, tt.withClusterDeployment)
+
+			statuses := checkStatuses(runner.preflight())
+			for name, status := range tt.expected {
+				assert.Equal(t, status, statuses[name], "status of check %s", name)
+			}
+		})
+	}
+}
+
+func TestPreflightWithinOrganization(t *testing.T) {
+	ocm := newFakeOCM()
+	runner, _ := newPreflightRunner(t, ocm, true)
+	runner.state.NewOwner.OrganizationID = runner.state.OldOwner.OrganizationID
+
+	statuses := checkStatuses(runner.preflight())
+	assert.Equal(t, preflightPass, statuses["entitlement"])
+	assert.Equal(t, preflightPass, statuses["quota"])
+	assert.Equal(t, 0, ocm.countRequests("GET /api/accounts_mgmt/v1/organizations/org-new/quota_cost"))
+}
+
+func TestPrintPreflight(t *testing.T) {
+	ocm := newFakeOCM()
+	ocm.quotaCosts = []interface{}{clusterQuota(5, 2, 1, "OSD", "standard", "byoc")}
+	runner, _ := newPreflightRunner(t, ocm, true)
+	runner.state.Steps[stepNotifyStart] = &transferStepResult{Completed: true}
+
+	report := runner.preflight()
+	require.Equal(t, 0, report.failures())
+	out := &bytes.Buffer{}
+	runner.printPreflight(out, report, transferSteps(), "")
+	output := out.String()
+
+	for _, expected := range []string{
+		"New owner: 'new-user' (account acc-new, organization org-new, EBS account 222)",
+		"1. notify-start: completed in a previous run",
+		"DELETE secret uhc-production-cluster-1/pull on Hive hive-1",
+		"CREATE SyncSet uhc-production-cluster-1/pull-secret-replacement",
+		"DELETE pods in namespace openshift-monitoring with label selector 'app.kubernetes.io/name=telemeter-client': telemeter-client-1",
+		"DELETE pods in namespace openshift-ocm-agent-operator with label selector 'app=ocm-agent' (none running)",
+		"PATCH /api/accounts_mgmt/v1/subscriptions/sub-1 organization_id: org-old => org-new",
+		"PATCH /api/accounts_mgmt/v1/subscriptions/sub-1 creator_id: acc-old => acc-new",
+		"POST /api/accounts_mgmt/v1/role_bindings ClusterOwner of subscription sub-1 for account acc-new",
+		"DELETE /api/accounts_mgmt/v1/role_bindings/rb-old (ClusterOwner of account acc-old)",
+		"POST /api/clusters_mgmt/v1/register_cluster external_id=external-1 subscription_id=sub-1 organization_id=org-new",
+		"POST service log " + SL_TRANSFER_COMPLETE,
+		"Registries added: quay.io",
+		"Registries with new credentials: cloud.openshift.com",
+		"Registries removed: registry.example.com",
+		redactedAuth,
+	} {
+		assert.Contains(t, output, expected)
+	}
+	for _, secret := range []string{"bmV3", "b2xk", "b3RoZXI="} {
+		assert.NotContains(t, output, secret, "credentials must be redacted")
+	}
+
+	// A dry-run changes nothing
+	for _, request := range ocm.requests {
+		assert.Regexp(t, `^(GET|POST /api/accounts_mgmt/v1/access_token$)`, request)
+	}
+}
+
+func TestRedactPullSecret(t *testing.T) {
+	redacted, err := redactPullSecret([]byte(`{"auths":{"quay.io":{"auth":"c2VjcmV0","email":"user@example.com"},"registry.example.com":{"username":"user","password":"secret","identitytoken":"token"}}}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"auths":{"quay.io":{"auth":"<redacted>","email":"user@example.com"},"registry.example.com":{"username":"user","password":"<redacted>","identitytoken":"<redacted>"}}}`, string(redacted))
+
+	_, err = redactPullSecret([]byte(`not json`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,123 @@
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// transferOwner describes the old or the new owner of a transferred cluster
+type transferOwner struct {
+	AccountID      string `json:"accountID"`
+	Username       string `json:"username"`
+	OrganizationID string `json:"organizationID"`
+	EbsAccountID   string `json:"ebsAccountID"`
+}
+
+// matches returns whether the owner is the one given by username or account ID
+func (o transferOwner) matches(name string) bool {
+	return name == o.Username || name == o.AccountID
+}
+
+// transferStepResult is the outcome of the last run of a transfer step
+type transferStepResult struct {
+	Completed bool      `json:"completed"`
+	Skipped   bool      `json:"skipped,omitempty"`
+	Finished  time.Time `json:"finished"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// transferState holds everything a cluster ownership transfer needs to know and the result of
+// every step. It is persisted after every step, so an interrupted transfer can be resumed with
+// --resume or --from-step. Pull secrets are never persisted.
+type transferState struct {
+	path string
+
+	ClusterID             string                         `json:"clusterID"`
+	ClusterName           string                         `json:"clusterName"`
+	ExternalClusterID     string                         `json:"externalClusterID"`
+	DomainPrefix          string                         `json:"domainPrefix"`
+	Hypershift            bool                           `json:"hypershift"`
+	MasterClusterID       string                         `json:"masterClusterID"`
+	ManagementClusterName string                         `json:"managementClusterName,omitempty"`
+	SubscriptionID        string                         `json:"subscriptionID"`
+	ConsoleURL            string                         `json:"consoleURL"`
+	DisplayName           string                         `json:"displayName"`
+	Reason                string                         `json:"reason"`
+	OldOwner              transferOwner                  `json:"oldOwner"`
+	NewOwner              transferOwner                  `json:"newOwner"`
+	Started               time.Time                      `json:"started"`
+	Finished              time.Time                      `json:"finished,omitempty"`
+	Steps                 map[string]*transferStepResult `json:"steps"`
+}
+
+// defaultTransferStatePath returns the state file of the transfer of a cluster
+func defaultTransferStatePath(clusterID string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "osdctl", "transfer-owner", clusterID+".json"), nil
+}
+
+// loadTransferState reads the state file at path. It returns nil without error when there is no state file.
+func loadTransferState(path string) (*transferState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transfer state %s: %w", path, err)
+	}
+
+	state := &transferState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse transfer state %s: %w", path, err)
+	}
+	state.path = path
+	if state.Steps == nil {
+		state.Steps = map[string]*transferStepResult{}
+	}
+	return state, nil
+}
+
+// orgChanged returns whether the cluster moves to another organization
+func (s *transferState) orgChanged() bool {
+	return s.OldOwner.OrganizationID != s.NewOwner.OrganizationID
+}
+
+// completed returns whether the step completed in a previous run
+func (s *transferState) completed(step string) bool {
+	result, ok := s.Steps[step]
+	return ok && result.Completed
+}
+
+// record stores the result of a step and persists the state
+func (s *transferState) record(step string, result *transferStepResult) error {
+	s.Steps[step] = result
+	return s.save()
+}
+
+func (s *transferState) save() error {
+	if s.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create transfer state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal transfer state: %w", err)
+	}
+
+	// Write to a temporary file first so an interrupted write never corrupts the state
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write transfer state: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,647 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	sdk "github.com/openshift-online/ocm-sdk-go"
+	amv1 "github.com/openshift-online/ocm-sdk-go/accountsmgmt/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/osdctl/cmd/common"
+	"github.com/openshift/osdctl/cmd/servicelog"
+	"github.com/openshift/osdctl/pkg/utils"
+)
+
+const (
+	stepNotifyStart       = "notify-start"
+	stepUpdatePullSecret  = "update-pull-secret"
+	stepRolloutTelemeter  = "rollout-telemeter"
+	stepVerifyPullSecret  = "verify-pull-secret"
+	stepPatchSubscription = "patch-subscription"
+	stepSwapRoleBinding   = "swap-role-binding"
+	stepReregisterCluster = "reregister-cluster"
+	stepRolloutOCMAgent   = "rollout-ocm-agent"
+	stepValidateTransfer  = "validate-transfer"
+	stepNotifyComplete    = "notify-complete"
+)
+
+// transferStep is a named step of a cluster ownership transfer. Every step must be safe to run
+// again, as an interrupted transfer is resumed by re-running the step which failed.
+type transferStep struct {
+	name        string
+	description string
+	// applies reports whether the step is needed for the cluster, every step is needed when nil
+	applies func(s *transferState) bool
+	// done reports whether the changes of the step are already in place, so the step can be skipped
+	done func(r *transferRunner) (bool, error)
+	// pre checks the preconditions of the step before it changes anything
+	pre func(r *transferRunner) error
+	run func(r *transferRunner) error
+	// post verifies the changes of the step
+	post func(r *transferRunner) error
+	// plan describes the changes the step would make, for dry-runs
+	plan func(r *transferRunner) ([]string, error)
+}
+
+// transferSteps returns the steps of a cluster ownership transfer in the order they run
+func transferSteps() []transferStep {
+	classicOnly := func(s *transferState) bool { return !s.Hypershift }
+
+	return []transferStep{
+		{
+			name:        stepNotifyStart,
+			description: "Send the service logs announcing the transfer to the customer and with the transfer details internally",
+			run:         notifyTransferStart,
+			plan: func(r *transferRunner) ([]string, error) {
+				return []string{
+					planServiceLog("to the customer", SL_TRANSFER_INITIATED),
+					fmt.Sprintf("POST internal service log \"From user '%s' in Red Hat account %s => user '%s' in Red Hat account %s.\"",
+						r.state.OldOwner.Username, r.state.OldOwner.EbsAccountID, r.state.NewOwner.Username, r.state.NewOwner.EbsAccountID),
+				}, nil
+			},
+		},
+		{
+			name:        stepUpdatePullSecret,
+			description: "Replace the pull secret of the cluster through a Hive SyncSet (classic) or the ManifestWork (HCP)",
+			done:        pullSecretApplied,
+			pre:         checkNewPullSecret,
+			run:         replacePullSecret,
+			plan:        planReplacePullSecret,
+		},
+		{
+			name:        stepRolloutTelemeter,
+			description: "Restart the telemeter-client pods so they use the new pull secret",
+			applies:     classicOnly,
+			run: func(r *transferRunner) error {
+				return r.rolloutPods("openshift-monitoring", "app.kubernetes.io/name=telemeter-client")
+			},
+			plan: func(r *transferRunner) ([]string, error) {
+				return r.planRollout("openshift-monitoring", "app.kubernetes.io/name=telemeter-client")
+			},
+		},
+		{
+			name:        stepVerifyPullSecret,
+			description: "Verify the pull secret of the cluster contains the credentials of the new owner",
+			run: func(r *transferRunner) error {
+				targetClientSet, err := r.targetClient()
+				if err != nil {
+					return err
+				}
+				pullSecret, err := r.newPullSecret()
+				if err != nil {
+					return err
+				}
+				return verifyClusterPullSecret(targetClientSet, pullSecret)
+			},
+			plan: func(r *transferRunner) ([]string, error) {
+				return []string{"GET secret openshift-config/pull-secret on the cluster and compare it with the pull secret of the new owner"}, nil
+			},
+		},
+		{
+			name:        stepPatchSubscription,
+			description: "Move the subscription to the organization of the new owner and make the new owner its creator",
+			done:        subscriptionTransferred,
+			pre:         checkOldOwner,
+			run:         patchSubscription,
+			post:        requireDone(subscriptionTransferred, "the subscription does not belong to the new owner"),
+			plan:        planPatchSubscription,
+		},
+		{
+			name:        stepSwapRoleBinding,
+			description: "Replace the ClusterOwner role binding of the old owner with one for the new owner",
+			done:        roleBindingSwapped,
+			run:         swapRoleBinding,
+			post:        requireDone(roleBindingSwapped, "the ClusterOwner role binding does not belong to the new owner"),
+			plan:        planSwapRoleBinding,
+		},
+		{
+			name:        stepReregisterCluster,
+			description: "Re-register the cluster in the organization of the new owner",
+			applies:     func(s *transferState) bool { return s.orgChanged() },
+			done:        clusterInNewOrganization,
+			run:         reregisterCluster,
+			post:        requireDone(clusterInNewOrganization, "the cluster is not registered in the organization of the new owner"),
+			plan: func(r *transferRunner) ([]string, error) {
+				return []string{fmt.Sprintf("POST /api/clusters_mgmt/v1/register_cluster external_id=%s subscription_id=%s organization_id=%s",
+					r.state.ExternalClusterID, r.state.SubscriptionID, r.state.NewOwner.OrganizationID)}, nil
+			},
+		},
+		{
+			name:        stepRolloutOCMAgent,
+			description: "Restart the ocm-agent pods so they use the new pull secret",
+			applies:     classicOnly,
+			run: func(r *transferRunner) error {
+				return r.rolloutPods("openshift-ocm-agent-operator", "app=ocm-agent")
+			},
+			plan: func(r *transferRunner) ([]string, error) {
+				return r.planRollout("openshift-ocm-agent-operator", "app=ocm-agent")
+			},
+		},
+		{
+			name:        stepValidateTransfer,
+			description: "Verify the cluster and subscription records agree on the new organization",
+			run: func(r *transferRunner) error {
+				return validateTransfer(r.ocm, r.state.ClusterID, r.state.NewOwner.OrganizationID)
+			},
+			plan: func(r *transferRunner) ([]string, error) {
+				return []string{fmt.Sprintf("GET /api/clusters_mgmt/v1/clusters with search \"organization.id = '%s' and id = '%s'\"",
+					r.state.NewOwner.OrganizationID, r.state.ClusterID)}, nil
+			},
+		},
+		{
+			name:        stepNotifyComplete,
+			description: "Send the service log announcing the completed transfer to the customer",
+			run:         notifyTransferComplete,
+			plan: func(r *transferRunner) ([]string, error) {
+				return []string{planServiceLog("to the customer", SL_TRANSFER_COMPLETE)}, nil
+			},
+		},
+	}
+}
+
+// transferStepNames returns the names of the steps for flag help and error messages
+func transferStepNames(steps []transferStep) []string {
+	names := make([]string, 0, len(steps))
+	for _, step := range steps {
+		names = append(names, step.name)
+	}
+	return names
+}
+
+// transferStepIndex returns the position of the named step, -1 when there is no such step
+func transferStepIndex(steps []transferStep, name string) int {
+	for i, step := range steps {
+		if step.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// requireDone turns the done check of a step into its post check
+func requireDone(done func(r *transferRunner) (bool, error), message string) func(r *transferRunner) error {
+	return func(r *transferRunner) error {
+		ok, err := done(r)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%s", message)
+		}
+		return nil
+	}
+}
+
+// transferRunner runs the steps of a transfer and holds the clients they share. The clients of the
+// clusters and the pull secret of the new owner are only fetched once a step needs them.
+type transferRunner struct {
+	state *transferState
+	ocm   *sdk.Connection
+	out   io.Writer
+
+	elevationReasons []string
+	masterKubeCli    client.Client
+	masterClientSet  kubernetes.Interface
+	targetClientSet  kubernetes.Interface
+	pullSecret       []byte
+
+	confirm        func() bool
+	postServiceLog func(options servicelog.PostCmdOptions) error
+	now            func() time.Time
+}
+
+func newTransferRunner(state *transferState, ocm *sdk.Connection, out io.Writer) *transferRunner {
+	return &transferRunner{
+		state: state,
+		ocm:   ocm,
+		out:   out,
+		elevationReasons: []string{
+			state.Reason,
+			fmt.Sprintf("Updating pull secret using osdctl to tranfert owner to %s", state.NewOwner.Username),
+		},
+		confirm: utils.ConfirmPrompt,
+		postServiceLog: func(options servicelog.PostCmdOptions) error {
+			return options.Run()
+		},
+		now: time.Now,
+	}
+}
+
+// runSteps runs the steps in order. Steps completed in a previous run are skipped, unless fromStep
+// is set: then every step before fromStep is skipped and every step from it on runs again.
+func (r *transferRunner) runSteps(steps []transferStep, fromStep string) error {
+	start := 0
+	if fromStep != "" {
+		start = transferStepIndex(steps, fromStep)
+		if start < 0 {
+			return fmt.Errorf("unknown step %q, the steps are: %s", fromStep, strings.Join(transferStepNames(steps), ", "))
+		}
+	}
+
+	for i, step := range steps[start:] {
+		fmt.Fprintf(r.out, "[%d/%d] %s: %s\n", start+i+1, len(steps), step.name, step.description)
+
+		if step.applies != nil && !step.applies(r.state) {
+			fmt.Fprintln(r.out, "Not needed for this cluster, skipping")
+			if err := r.state.record(step.name, &transferStepResult{Completed: true, Skipped: true, Finished: r.now().UTC()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if fromStep == "" && r.state.completed(step.name) {
+			fmt.Fprintln(r.out, "Completed in a previous run, skipping")
+			continue
+		}
+
+		if err := r.runStep(step); err != nil {
+			if recordErr := r.state.record(step.name, &transferStepResult{Finished: r.now().UTC(), Error: err.Error()}); recordErr != nil {
+				fmt.Fprintf(r.out, "Failed to record the failure of step %s: %v\n", step.name, recordErr)
+			}
+			return fmt.Errorf("step %s failed: %w\nOnce the cause is fixed, continue the transfer with --resume, or with --from-step to re-run earlier steps", step.name, err)
+		}
+	}
+
+	r.state.Finished = r.now().UTC()
+	return r.state.save()
+}
+
+// runStep runs a single step, skipping it when its changes are already in place
+func (r *transferRunner) runStep(step transferStep) error {
+	if step.done != nil {
+		done, err := step.done(r)
+		if err != nil {
+			return fmt.Errorf("failed to check the state of the step: %w", err)
+		}
+		if done {
+			fmt.Fprintln(r.out, "Changes are already in place, skipping")
+			return r.state.record(step.name, &transferStepResult{Completed: true, Finished: r.now().UTC()})
+		}
+	}
+	if step.pre != nil {
+		if err := step.pre(r); err != nil {
+			return fmt.Errorf("pre-check failed: %w", err)
+		}
+	}
+	if err := step.run(r); err != nil {
+		return err
+	}
+	if step.post != nil {
+		if err := step.post(r); err != nil {
+			return fmt.Errorf("post-check failed: %w", err)
+		}
+	}
+	return r.state.record(step.name, &transferStepResult{Completed: true, Finished: r.now().UTC()})
+}
+
+// masterClients returns the clients of the Hive cluster (classic) or the service cluster (HCP)
+func (r *transferRunner) masterClients() (client.Client, kubernetes.Interface, error) {
+	if r.masterKubeCli == nil {
+		kubeCli, _, clientSet, err := common.GetKubeConfigAndClient(r.state.MasterClusterID, r.elevationReasons...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to retrieve Kubernetes configuration and client for cluster ID %s: %w", r.state.MasterClusterID, err)
+		}
+		r.masterKubeCli, r.masterClientSet = kubeCli, clientSet
+	}
+	return r.masterKubeCli, r.masterClientSet, nil
+}
+
+// targetClient returns the client of the transferred cluster
+func (r *transferRunner) targetClient() (kubernetes.Interface, error) {
+	if r.targetClientSet == nil {
+		_, _, clientSet, err := common.GetKubeConfigAndClient(r.state.ClusterID, r.elevationReasons...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve Kubernetes configuration and client for cluster with ID %s: %w", r.state.ClusterID, err)
+		}
+		r.targetClientSet = clientSet
+	}
+	return r.targetClientSet, nil
+}
+
+// newPullSecret returns the pull secret of the new owner
+func (r *transferRunner) newPullSecret() ([]byte, error) {
+	if r.pullSecret != nil {
+		return r.pullSecret, nil
+	}
+
+	response, err := r.ocm.AccountsMgmt().V1().AccessToken().Post().Impersonate(r.state.NewOwner.Username).Parameter("body", nil).Send()
+	if err != nil {
+		return nil, fmt.Errorf("can't fetch the pull secret of %s: %w", r.state.NewOwner.Username, err)
+	}
+
+	auths, ok := response.Body().GetAuths()
+	if !ok {
+		return nil, fmt.Errorf("error validating pull secret structure. This shouldn't happen, so you might need to contact SDB")
+	}
+	authsMap := map[string]map[string]string{}
+	for k, auth := range auths {
+		authsMap[k] = map[string]string{
+			"auth":  auth.Auth(),
+			"email": auth.Email(),
+		}
+	}
+
+	pullSecret, err := json.Marshal(map[string]map[string]map[string]string{
+		"auths": authsMap,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal pull secret data: %w", err)
+	}
+	r.pullSecret = pullSecret
+	return pullSecret, nil
+}
+
+func (r *transferRunner) rolloutPods(namespace, selector string) error {
+	targetClientSet, err := r.targetClient()
+	if err != nil {
+		return err
+	}
+	if err := rolloutPods(targetClientSet, namespace, selector); err != nil {
+		return fmt.Errorf("failed to roll out pods in namespace '%s' with label selector '%s': %w", namespace, selector, err)
+	}
+	return nil
+}
+
+func (r *transferRunner) serviceLogParameters() serviceLogParameters {
+	return serviceLogParameters{
+		ClusterID:             r.state.ClusterID,
+		OldOwnerName:          r.state.OldOwner.Username,
+		OldOwnerID:            r.state.OldOwner.EbsAccountID,
+		NewOwnerName:          r.state.NewOwner.Username,
+		NewOwnerID:            r.state.NewOwner.EbsAccountID,
+		IsExternalOrgTransfer: r.state.orgChanged(),
+	}
+}
+
+// notifyTransferStart sends the service logs before the transfer. Failing to send them does not stop
+// the transfer, the commands to send them manually are printed instead.
+func notifyTransferStart(r *transferRunner) error {
+	slParams := r.serviceLogParameters()
+
+	fmt.Fprintln(r.out, "Notify the customer before ownership transfer commences. Sending service log.")
+	postCmd := generateServiceLog(slParams, SL_TRANSFER_INITIATED)
+	if err := r.postServiceLog(postCmd); err != nil {
+		fmt.Fprintln(r.out, "Failed to POST customer service log. Please manually send a service log to notify the customer before ownership transfer commences:")
+		fmt.Fprintf(r.out, "osdctl servicelog post %v -t %v -p %v\n",
+			slParams.ClusterID, SL_TRANSFER_INITIATED, strings.Join(postCmd.TemplateParams, " -p "))
+	}
+
+	// Send internal SL to cluster with additional details in case we
+	// need them later. This prevents leaking PII to customers.
+	postCmd = generateInternalServiceLog(slParams)
+	fmt.Fprintln(r.out, "Internal SL Being Sent")
+	if err := r.postServiceLog(postCmd); err != nil {
+		fmt.Fprintln(r.out, "Failed to POST internal service log. Please manually send a service log to persist details of the customer transfer before proceeding:")
+		fmt.Fprintf(r.out, "osdctl servicelog post -i -p MESSAGE=\"From user '%s' in Red Hat account %s => user '%s' in Red Hat account %s.\" %s\n", slParams.OldOwnerName, slParams.OldOwnerID, slParams.NewOwnerName, slParams.NewOwnerID, slParams.ClusterID)
+	}
+	return nil
+}
+
+func notifyTransferComplete(r *transferRunner) error {
+	slParams := r.serviceLogParameters()
+
+	fmt.Fprintln(r.out, "Notify the customer the ownership transfer is completed. Sending service log.")
+	postCmd := generateServiceLog(slParams, SL_TRANSFER_COMPLETE)
+	if err := r.postServiceLog(postCmd); err != nil {
+		fmt.Fprintln(r.out, "Failed to POST service log. Please manually send a service log to notify the customer the ownership transfer is completed:")
+		fmt.Fprintf(r.out, "osdctl servicelog post %v -t %v -p %v\n",
+			slParams.ClusterID, SL_TRANSFER_COMPLETE, strings.Join(postCmd.TemplateParams, " -p "))
+	}
+	return nil
+}
+
+// checkNewPullSecret makes sure the pull secret of the new owner can be fetched before the old one is replaced
+func checkNewPullSecret(r *transferRunner) error {
+	pullSecret, err := r.newPullSecret()
+	if err != nil {
+		return err
+	}
+	if len(pullSecretAuths(pullSecret)) == 0 {
+		return fmt.Errorf("the pull secret of %s has no registry credentials", r.state.NewOwner.Username)
+	}
+	if r.state.Hypershift && r.state.ManagementClusterName == "" {
+		return fmt.Errorf("the management cluster of the HCP cluster is unknown")
+	}
+	return nil
+}
+
+// pullSecretApplied reports whether the pull secret of the cluster already has the credentials of the new owner
+func pullSecretApplied(r *transferRunner) (bool, error) {
+	targetClientSet, err := r.targetClient()
+	if err != nil {
+		return false, err
+	}
+	pullSecret, err := r.newPullSecret()
+	if err != nil {
+		return false, err
+	}
+	actual, err := getGlobalPullSecret(targetClientSet)
+	if err != nil {
+		return false, err
+	}
+	return len(missingPullSecretAuths(actual, pullSecret)) == 0, nil
+}
+
+func replacePullSecret(r *transferRunner) error {
+	pullSecret, err := r.newPullSecret()
+	if err != nil {
+		return err
+	}
+	masterKubeCli, masterClientSet, err := r.masterClients()
+	if err != nil {
+		return err
+	}
+
+	if r.state.Hypershift {
+		if err := updateManifestWork(masterKubeCli, r.state.ClusterID, r.state.ManagementClusterName, r.state.DomainPrefix, pullSecret); err != nil {
+			return fmt.Errorf("failed to update pull secret for service cluster with ID %s: %w", r.state.MasterClusterID, err)
+		}
+		return nil
+	}
+	if err := updatePullSecret(masterKubeCli, masterClientSet, r.hiveNamespace(), pullSecret); err != nil {
+		return fmt.Errorf("failed to update pull secret for Hive cluster with ID %s: %w", r.state.MasterClusterID, err)
+	}
+	return nil
+}
+
+func (r *transferRunner) subscription() (*amv1.Subscription, error) {
+	response, err := r.ocm.AccountsMgmt().V1().Subscriptions().Subscription(r.state.SubscriptionID).Get().Send()
+	if err != nil {
+		return nil, fmt.Errorf("could not get subscription %s: %w", r.state.SubscriptionID, err)
+	}
+	return response.Body(), nil
+}
+
+// subscriptionTransferred reports whether the subscription already belongs to the new owner
+func subscriptionTransferred(r *transferRunner) (bool, error) {
+	subscription, err := r.subscription()
+	if err != nil {
+		return false, err
+	}
+	return subscription.OrganizationID() == r.state.NewOwner.OrganizationID &&
+		subscription.Creator().ID() == r.state.NewOwner.AccountID, nil
+}
+
+// checkOldOwner makes sure the subscription still belongs to the old owner, or lets the user
+// continue when it doesn't, e.g. after a previously failed run
+func checkOldOwner(r *transferRunner) error {
+	subscription, err := r.subscription()
+	if err != nil {
+		return err
+	}
+	oldOwner, err := amv1.NewAccount().ID(r.state.OldOwner.AccountID).Build()
+	if err != nil {
+		return err
+	}
+	if !validateOldOwner(r.state.OldOwner.OrganizationID, subscription, oldOwner) {
+		fmt.Fprintln(r.out, "can't validate this is old owners cluster, this could be because of a previously failed run")
+		if !r.confirm() {
+			return fmt.Errorf("operation aborted by the user")
+		}
+	}
+	return nil
+}
+
+func patchSubscription(r *transferRunner) error {
+	// org has to be patched before creator
+	if r.state.orgChanged() {
+		subscriptionOrgPatch, err := amv1.NewSubscription().OrganizationID(r.state.NewOwner.OrganizationID).Build()
+		if err != nil {
+			return fmt.Errorf("can't create subscription organization patch: %w", err)
+		}
+		response, err := r.ocm.AccountsMgmt().V1().Subscriptions().Subscription(r.state.SubscriptionID).Update().Body(subscriptionOrgPatch).Send()
+		if err != nil {
+			return fmt.Errorf("request failed '%w'", err)
+		}
+		if response.Status() != 200 {
+			return fmt.Errorf("request failed with status: %d", response.Status())
+		}
+		fmt.Fprintln(r.out, "Patched organization on subscription")
+	}
+
+	subscriptionCreatorPatchRequest, err := createSubscriptionCreatorPatchRequest(r.ocm, r.state.SubscriptionID, r.state.NewOwner.AccountID)
+	if err != nil {
+		return fmt.Errorf("can't create subscription creator patch: %w", err)
+	}
+	patchRes, err := subscriptionCreatorPatchRequest.Send()
+	if err != nil {
+		return fmt.Errorf("request failed '%w'", err)
+	}
+	if patchRes.Status() != 200 {
+		return fmt.Errorf("request failed with status: %d", patchRes.Status())
+	}
+	fmt.Fprintln(r.out, "Patched creator on subscription")
+	return nil
+}
+
+// roleBindingSwapped reports whether the new owner holds the only ClusterOwner role binding of the subscription
+func roleBindingSwapped(r *transferRunner) (bool, error) {
+	roleBindings, err := getRoleBindings(r.ocm, r.state.SubscriptionID)
+	if err != nil {
+		return false, err
+	}
+	return len(roleBindings) == 1 && roleBindings[0].Account().ID() == r.state.NewOwner.AccountID, nil
+}
+
+// swapRoleBinding creates the role binding of the new owner before deleting the others, so re-running
+// it after a failure never leaves the cluster without owner
+func swapRoleBinding(r *transferRunner) error {
+	newRoleBinding, err := amv1.
+		NewRoleBinding().
+		AccountID(r.state.NewOwner.AccountID).
+		SubscriptionID(r.state.SubscriptionID).
+		Type("Subscription").
+		RoleID("ClusterOwner").
+		Build()
+	if err != nil {
+		return fmt.Errorf("can't create new owners rolebinding %w", err)
+	}
+
+	postRes, err := r.ocm.AccountsMgmt().V1().RoleBindings().Add().Body(newRoleBinding).Send()
+	// don't fail if the rolebinding already exists, could be rerun
+	switch {
+	case postRes != nil && postRes.Status() == 409:
+		fmt.Fprintln(r.out, "can't add new rolebinding, rolebinding already exists")
+	case err != nil:
+		return fmt.Errorf("request failed '%w'", err)
+	case postRes.Status() == 201:
+		fmt.Fprintln(r.out, "Created new role binding.")
+	default:
+		return fmt.Errorf("request failed with status: %d", postRes.Status())
+	}
+
+	return deleteOldRoleBindings(r.ocm, r.state.SubscriptionID, r.state.NewOwner.AccountID)
+}
+
+// clusterInNewOrganization reports whether clusters service already lists the cluster in the new organization
+func clusterInNewOrganization(r *transferRunner) (bool, error) {
+	return validateTransfer(r.ocm, r.state.ClusterID, r.state.NewOwner.OrganizationID) == nil, nil
+}
+
+// reregisterCluster re-registers the cluster with CS with the new organization id
+func reregisterCluster(r *transferRunner) error {
+	request, err := createNewRegisterClusterRequest(r.ocm, r.state.ExternalClusterID, r.state.SubscriptionID, r.state.NewOwner.OrganizationID, r.state.ConsoleURL, r.state.DisplayName)
+	if err != nil {
+		return fmt.Errorf("can't create RegisterClusterRequest with CS, '%w'", err)
+	}
+
+	response, err := request.Send()
+	if err != nil {
+		return fmt.Errorf("request failed '%w'", err)
+	}
+	if response.Status() != 200 && response.Status() != 201 {
+		return fmt.Errorf("request failed with status: %d", response.Status())
+	}
+	fmt.Fprintln(r.out, "Re-registered cluster")
+	return nil
+}
+
+func planReplacePullSecret(r *transferRunner) ([]string, error) {
+	if r.state.Hypershift {
+		return []string{
+			fmt.Sprintf("UPDATE ManifestWork %s/%s on service cluster %s: copy secret %s-pull-* with the new pull secret to a new name",
+				r.state.ManagementClusterName, r.state.ClusterID, r.state.MasterClusterID, r.state.DomainPrefix),
+			fmt.Sprintf("UPDATE ManifestWork %s/%s on service cluster %s: point the HostedCluster pull secret at the new secret",
+				r.state.ManagementClusterName, r.state.ClusterID, r.state.MasterClusterID),
+			fmt.Sprintf("wait %v for the pull secret to reach the cluster", manifestWorkSyncWait),
+		}, nil
+	}
+	hiveNamespace := r.hiveNamespace()
+	return []string{
+		fmt.Sprintf("DELETE secret %s/pull on Hive %s", hiveNamespace, r.state.MasterClusterID),
+		fmt.Sprintf("CREATE secret %s/pull on Hive %s with the new pull secret", hiveNamespace, r.state.MasterClusterID),
+		fmt.Sprintf("CREATE SyncSet %s/pull-secret-replacement on Hive %s, syncing the secret to openshift-config/pull-secret", hiveNamespace, r.state.MasterClusterID),
+		fmt.Sprintf("wait up to %v for the ClusterSync, then DELETE SyncSet %s/pull-secret-replacement", CheckSyncMaxAttempts*5*time.Second, hiveNamespace),
+	}, nil
+}
+
+func planPatchSubscription(r *transferRunner) ([]string, error) {
+	path := "/api/accounts_mgmt/v1/subscriptions/" + r.state.SubscriptionID
+	var actions []string
+	if r.state.orgChanged() {
+		actions = append(actions, fmt.Sprintf("PATCH %s organization_id: %s => %s", path, r.state.OldOwner.OrganizationID, r.state.NewOwner.OrganizationID))
+	}
+	return append(actions, fmt.Sprintf("PATCH %s creator_id: %s => %s", path, r.state.OldOwner.AccountID, r.state.NewOwner.AccountID)), nil
+}
+
+func planSwapRoleBinding(r *transferRunner) ([]string, error) {
+	actions := []string{fmt.Sprintf("POST /api/accounts_mgmt/v1/role_bindings ClusterOwner of subscription %s for account %s",
+		r.state.SubscriptionID, r.state.NewOwner.AccountID)}
+
+	roleBindings, err := getRoleBindings(r.ocm, r.state.SubscriptionID)
+	if err != nil {
+		return actions, err
+	}
+	for _, roleBinding := range roleBindings {
+		if roleBinding.Account().ID() == r.state.NewOwner.AccountID {
+			continue
+		}
+		actions = append(actions, fmt.Sprintf("DELETE /api/accounts_mgmt/v1/role_bindings/%s (ClusterOwner of account %s)",
+			roleBinding.ID(), roleBinding.Account().ID()))
+	}
+	return actions, nil
+}
@@ -0,0 +1,654 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	sdk "github.com/openshift-online/ocm-sdk-go"
+	hiveapiv1 "github.com/openshift/hive/apis/hive/v1"
+	hiveinternalv1alpha1 "github.com/openshift/hive/apis/hiveinternal/v1alpha1"
+	hypershiftv1beta1 "github.com/openshift/hypershift/api/hypershift/v1beta1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	workv1 "open-cluster-management.io/api/work/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/osdctl/cmd/servicelog"
+)
+
+const (
+	testOldPullSecret = `{"auths":{"cloud.openshift.com":{"auth":"b2xk","email":"old@example.com"},"quay.io":{"auth":"b2xk","email":"old@example.com"}}}`
+	testNewPullSecret = `{"auths":{"cloud.openshift.com":{"auth":"bmV3","email":"new@example.com"},"quay.io":{"auth":"bmV3","email":"new@example.com"}}}`
+)
+
+// fakeOCM serves the part of the OCM API used by the transfer steps and keeps the records they change
+type fakeOCM struct {
+	mutex sync.Mutex
+
+	subscriptionOrg string
+	creatorID       string
+	clusterOrg      string
+	roleBindings    map[string]string // role binding ID to account ID
+	clusterState    string
+	newOwnerBanned  bool
+	quotaCosts      []interface{}
+	requests        []string
+	nextBindingID   int
+}
+
+func newFakeOCM() *fakeOCM {
+	return &fakeOCM{
+		subscriptionOrg: "org-old",
+		creatorID:       "acc-old",
+		clusterOrg:      "org-old",
+		roleBindings:    map[string]string{"rb-old": "acc-old"},
+		clusterState:    "ready",
+	}
+}
+
+func (f *fakeOCM) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	body, _ := io.ReadAll(r.Body)
+	if r.URL.Path != "/token" {
+		f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	}
+
+	respond := func(status int, v interface{}) {
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(v)
+	}
+
+	switch {
+	case r.URL.Path == "/token":
+		token, _ := jwt.New(jwt.SigningMethodHS256).SignedString([]byte("test-secret"))
+		respond(http.StatusOK, map[string]interface{}{"access_token": token, "token_type": "Bearer", "expires_in": 3600})
+
+	case r.Method == http.MethodPost && r.URL.Path == "/api/accounts_mgmt/v1/access_token":
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(testNewPullSecret))
+
+	case r.URL.Path == "/api/accounts_mgmt/v1/subscriptions/sub-1":
+		if r.Method == http.MethodPatch {
+			var patch map[string]interface{}
+			_ = json.Unmarshal(body, &patch)
+			if org, ok := patch["organization_id"].(string); ok {
+				f.subscriptionOrg = org
+			}
+			if creator, ok := patch["creator_id"].(string); ok {
+				f.creatorID = creator
+			}
+		}
+		respond(http.StatusOK, map[string]interface{}{
+			"kind":            "Subscription",
+			"id":              "sub-1",
+			"organization_id": f.subscriptionOrg,
+			"creator":         map[string]interface{}{"kind": "Account", "id": f.creatorID},
+		})
+
+	case r.Method == http.MethodGet && r.URL.Path == "/api/accounts_mgmt/v1/role_bindings":
+		items := []interface{}{}
+		for id, account := range f.roleBindings {
+			items = append(items, map[string]interface{}{"kind": "RoleBinding", "id": id, "account": map[string]interface{}{"kind": "Account", "id": account}})
+		}
+		respond(http.StatusOK, map[string]interface{}{"kind": "RoleBindingList", "page": 1, "size": len(items), "total": len(items), "items": items})
+
+	case r.Method == http.MethodPost && r.URL.Path == "/api/accounts_mgmt/v1/role_bindings":
+		var binding map[string]interface{}
+		_ = json.Unmarshal(body, &binding)
+		account, _ := binding["account_id"].(string)
+		for _, existing := range f.roleBindings {
+			if existing == account {
+				respond(http.StatusConflict, map[string]interface{}{"kind": "Error", "id": "409", "reason": "role binding already exists"})
+				return
+			}
+		}
+		f.nextBindingID++
+		id := fmt.Sprintf("rb-new-%d", f.nextBindingID)
+		f.roleBindings[id] = account
+		respond(http.StatusCreated, map[string]interface{}{"kind": "RoleBinding", "id": id})
+
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/api/accounts_mgmt/v1/role_bindings/"):
+		id := strings.TrimPrefix(r.URL.Path, "/api/accounts_mgmt/v1/role_bindings/")
+		if _, ok := f.roleBindings[id]; !ok {
+			respond(http.StatusNotFound, map[string]interface{}{"kind": "Error", "id": "404", "reason": "not found"})
+			return
+		}
+		delete(f.roleBindings, id)
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodGet && r.URL.Path == "/api/clusters_mgmt/v1/clusters":
+		items := []interface{}{}
+		if strings.Contains(r.URL.Query().Get("search"), "'"+f.clusterOrg+"'") {
+			items = append(items, map[string]interface{}{"kind": "Cluster", "id": "cluster-1"})
+		}
+		respond(http.StatusOK, map[string]interface{}{"kind": "ClusterList", "page": 1, "size": len(items), "total": len(items), "items": items})
+
+	case r.Method == http.MethodGet && r.URL.Path == "/api/clusters_mgmt/v1/clusters/cluster-1":
+		respond(http.StatusOK, map[string]interface{}{
+			"kind":           "Cluster",
+			"id":             "cluster-1",
+			"state":          f.clusterState,
+			"product":        map[string]interface{}{"id": "osd"},
+			"billing_model":  "standard",
+			"cloud_provider": map[string]interface{}{"id": "aws"},
+			"ccs":            map[string]interface{}{"enabled": true},
+		})
+
+	case r.Method == http.MethodGet && r.URL.Path == "/api/accounts_mgmt/v1/accounts/acc-new":
+		respond(http.StatusOK, map[string]interface{}{"kind": "Account", "id": "acc-new", "banned": f.newOwnerBanned})
+
+	case r.Method == http.MethodGet && r.URL.Path == "/api/accounts_mgmt/v1/organizations/org-new/quota_cost":
+		respond(http.StatusOK, map[string]interface{}{"kind": "QuotaCostList", "page": 1, "size": len(f.quotaCosts), "total": len(f.quotaCosts), "items": f.quotaCosts})
+
+	case r.Method == http.MethodPost && r.URL.Path == "/api/clusters_mgmt/v1/register_cluster":
+		var registration RegisterCluster
+		_ = json.Unmarshal(body, &registration)
+		f.clusterOrg = registration.Organization_id
+		respond(http.StatusOK, map[string]interface{}{"kind": "Cluster", "id": "cluster-1"})
+
+	default:
+		respond(http.StatusNotFound, map[string]interface{}{"kind": "Error", "id": "404", "reason": "unexpected request " + r.Method + " " + r.URL.Path})
+	}
+}
+
+func (f *fakeOCM) countRequests(request string) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	count := 0
+	for _, r := range f.requests {
+		if r == request {
+			count++
+		}
+	}
+	return count
+}
+
+func newFakeOCMConnection(t *testing.T, ocm *fakeOCM) *sdk.Connection {
+	server := httptest.NewServer(ocm)
+	t.Cleanup(server.Close)
+
+	conn, err := sdk.NewConnectionBuilder().
+		URL(server.URL).
+		TokenURL(server.URL+"/token").
+		Client("fake-id", "fake-secret").
+		Build()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func newTestTransferState(t *testing.T) *transferState {
+	return &transferState{
+		path:              filepath.Join(t.TempDir(), "cluster-1.json"),
+		ClusterID:         "cluster-1",
+		ExternalClusterID: "external-1",
+		DomainPrefix:      "mycluster",
+		MasterClusterID:   "hive-1",
+		SubscriptionID:    "sub-1",
+		ConsoleURL:        "https://console.example.com",
+		DisplayName:       "mycluster",
+		OldOwner:          transferOwner{AccountID: "acc-old", Username: "old-user", OrganizationID: "org-old", EbsAccountID: "111"},
+		NewOwner:          transferOwner{AccountID: "acc-new", Username: "new-user", OrganizationID: "org-new", EbsAccountID: "222"},
+		Steps:             map[string]*transferStepResult{},
+	}
+}
+
+func newTestTransferRunner(t *testing.T, state *transferState, ocm *sdk.Connection) (*transferRunner, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	runner := newTransferRunner(state, ocm, out)
+	runner.confirm = func() bool { return true }
+	runner.postServiceLog = func(servicelog.PostCmdOptions) error { return nil }
+	runner.now = func() time.Time { return time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC) }
+	return runner, out
+}
+
+func findTransferStep(t *testing.T, name string) transferStep {
+	steps := transferSteps()
+	i := transferStepIndex(steps, name)
+	require.GreaterOrEqual(t, i, 0, "no step %s", name)
+	return steps[i]
+}
+
+func pullSecretObject(namespace, name, data string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{".dockerconfigjson": []byte(data)},
+	}
+}
+
+func TestTransferStatePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transfer", "cluster-1.json")
+
+	state, err := loadTransferState(path)
+	require.NoError(t, err)
+	assert.Nil(t, state, "no state is expected before the first run")
+
+	state = newTestTransferState(t)
+	state.path = path
+	require.NoError(t, state.record(stepNotifyStart, &transferStepResult{Completed: true}))
+	require.NoError(t, state.record(stepUpdatePullSecret, &transferStepResult{Error: "sync failed"}))
+
+	loaded, err := loadTransferState(path)
+	require.NoError(t, err)
+	assert.Equal(t, state.NewOwner, loaded.NewOwner)
+	assert.True(t, loaded.completed(stepNotifyStart))
+	assert.False(t, loaded.completed(stepUpdatePullSecret))
+	assert.Equal(t, "sync failed", loaded.Steps[stepUpdatePullSecret].Error)
+	assert.True(t, loaded.NewOwner.matches("new-user"))
+	assert.True(t, loaded.NewOwner.matches("acc-new"))
+	assert.False(t, loaded.NewOwner.matches("old-user"))
+}
+
+func TestRunSteps(t *testing.T) {
+	var calls []string
+	failing := errors.New("boom")
+	var failStep string
+	record := func(name string) func(r *transferRunner) error {
+		return func(r *transferRunner) error {
+			calls = append(calls, name)
+			if name == failStep {
+				return failing
+			}
+			return nil
+		}
+	}
+	steps := []transferStep{
+		{name: "one", run: record("one")},
+		{name: "two", run: record("two")},
+		{name: "hcp-only", applies: func(s *transferState) bool { return s.Hypershift }, run: record("hcp-only")},
+		{name: "three", run: record("three")},
+	}
+
+	state := newTestTransferState(t)
+	runner, _ := newTestTransferRunner(t, state, nil)
+
+	// A failing step stops the transfer and is recorded
+	failStep = "two"
+	err := runner.runSteps(steps, "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "step two failed")
+	assert.Contains(t, err.Error(), "--resume")
+	assert.Equal(t, []string{"one", "two"}, calls)
+	loaded, err := loadTransferState(state.path)
+	require.NoError(t, err)
+	assert.True(t, loaded.completed("one"))
+	assert.Equal(t, "boom", loaded.Steps["two"].Error)
+	assert.True(t, loaded.Finished.IsZero())
+
+	// Resuming skips the completed steps and the steps that don't apply
+	calls = nil
+	failStep = ""
+	runner, _ = newTestTransferRunner(t, loaded, nil)
+	require.NoError(t, runner.runSteps(steps, ""))
+	assert.Equal(t, []string{"two", "three"}, calls)
+	assert.True(t, loaded.Steps["hcp-only"].Skipped)
+	assert.False(t, loaded.Finished.IsZero())
+
+	// --from-step re-runs the step and every later step
+	calls = nil
+	require.NoError(t, runner.runSteps(steps, "two"))
+	assert.Equal(t, []string{"two", "three"}, calls)
+
+	err = runner.runSteps(steps, "four")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown step "four"`)
+}
+
+func TestRunStepChecks(t *testing.T) {
+	tests := []struct {
+		name        string
+		step        transferStep
+		expectRun   bool
+		errContains string
+	}{
+		{
+			name:      "done steps are not run again",
+			step:      transferStep{done: func(*transferRunner) (bool, error) { return true, nil }},
+			expectRun: false,
+		},
+		{
+			name:        "a failing pre-check prevents the step",
+			step:        transferStep{pre: func(*transferRunner) error { return errors.New("not ready") }},
+			expectRun:   false,
+			errContains: "pre-check failed: not ready",
+		},
+		{
+			name:        "a failing post-check fails the step",
+			step:        transferStep{post: func(*transferRunner) error { return errors.New("not applied") }},
+			expectRun:   true,
+			errContains: "post-check failed: not applied",
+		},
+		{
+			name:        "a failing done check fails the step",
+			step:        transferStep{done: func(*transferRunner) (bool, error) { return false, errors.New("unreachable") }},
+			expectRun:   false,
+			errContains: "unreachable",
+		},
+		{
+			name:      "steps run when the checks pass",
+			step:      transferStep{done: func(*transferRunner) (bool, error) { return false, nil }, pre: func(*transferRunner) error { return nil }},
+			expectRun: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ran := false
+			step := tt.step
+			step.name = "step"
+			step.run = func(*transferRunner) error {
+				ran = true
+				return nil
+			}
+			state := newTestTransferState(t)
+			runner, _ := newTestTransferRunner(t, state, nil)
+
+			err := runner.runSteps([]transferStep{step}, "")
+			assert.Equal(t, tt.expectRun, ran)
+			if tt.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errContains)
+				assert.False(t, state.completed("step"))
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, state.completed("step"))
+		})
+	}
+}
+
+func TestNotifySteps(t *testing.T) {
+	state := newTestTransferState(t)
+	runner, out := newTestTransferRunner(t, state, nil)
+	var sent []servicelog.PostCmdOptions
+	runner.postServiceLog = func(options servicelog.PostCmdOptions) error {
+		sent = append(sent, options)
+		return errors.New("ocm unavailable")
+	}
+
+	require.NoError(t, findTransferStep(t, stepNotifyStart).run(runner))
+	require.Len(t, sent, 2)
+	assert.Equal(t, SL_TRANSFER_INITIATED, sent[0].Template)
+	assert.True(t, sent[1].InternalOnly)
+	assert.Contains(t, sent[1].TemplateParams[0], "'old-user' in Red Hat account 111 => user 'new-user' in Red Hat account 222")
+	assert.Contains(t, out.String(), "osdctl servicelog post cluster-1 -t "+SL_TRANSFER_INITIATED)
+
+	require.NoError(t, findTransferStep(t, stepNotifyComplete).run(runner))
+	assert.Equal(t, SL_TRANSFER_COMPLETE, sent[2].Template)
+}
+
+func TestUpdatePullSecretStep(t *testing.T) {
+	hiveNamespace := "uhc-production-cluster-1"
+	scheme := runtime.NewScheme()
+	require.NoError(t, hiveapiv1.AddToScheme(scheme))
+	require.NoError(t, hiveinternalv1alpha1.AddToScheme(scheme))
+
+	for _, leftover := range []bool{false, true} {
+		t.Run(fmt.Sprintf("leftovers of a failed run: %v", leftover), func(t *testing.T) {
+			objects := []client.Object{
+				&hiveapiv1.ClusterDeployment{ObjectMeta: metav1.ObjectMeta{Name: "mycluster", Namespace: hiveNamespace}},
+				&hiveinternalv1alpha1.ClusterSync{
+					ObjectMeta: metav1.ObjectMeta{Name: "mycluster", Namespace: hiveNamespace},
+					Status: hiveinternalv1alpha1.ClusterSyncStatus{SyncSets: []hiveinternalv1alpha1.SyncStatus{
+						{Name: "pull-secret-replacement", FirstSuccessTime: &metav1.Time{Time: time.Now()}},
+					}},
+				},
+			}
+			var hiveSecrets []runtime.Object
+			if leftover {
+				// A previous run failed after deleting the secret and creating the SyncSet
+				objects = append(objects, &hiveapiv1.SyncSet{ObjectMeta: metav1.ObjectMeta{Name: "pull-secret-replacement", Namespace: hiveNamespace}})
+			} else {
+				hiveSecrets = append(hiveSecrets, pullSecretObject(hiveNamespace, "pull", testOldPullSecret))
+			}
+
+			kubeCli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+			hiveClientSet := kubefake.NewSimpleClientset(hiveSecrets...)
+			targetClientSet := kubefake.NewSimpleClientset(pullSecretObject("openshift-config", "pull-secret", testOldPullSecret))
+
+			state := newTestTransferState(t)
+			runner, _ := newTestTransferRunner(t, state, newFakeOCMConnection(t, newFakeOCM()))
+			runner.masterKubeCli = kubeCli
+			runner.masterClientSet = hiveClientSet
+			runner.targetClientSet = targetClientSet
+
+			step := findTransferStep(t, stepUpdatePullSecret)
+			done, err := step.done(runner)
+			require.NoError(t, err)
+			assert.False(t, done, "the cluster still has the old pull secret")
+			require.NoError(t, step.pre(runner))
+			require.NoError(t, step.run(runner))
+
+			secret, err := hiveClientSet.CoreV1().Secrets(hiveNamespace).Get(context.TODO(), "pull", metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.JSONEq(t, testNewPullSecret, string(secret.Data[".dockerconfigjson"]))
+
+			err = kubeCli.Get(context.TODO(), types.NamespacedName{Name: "pull-secret-replacement", Namespace: hiveNamespace}, &hiveapiv1.SyncSet{})
+			assert.True(t, apierrors.IsNotFound(err), "the SyncSet is cleaned up")
+
+			// Once Hive synced the secret, the step is done
+			_, err = targetClientSet.CoreV1().Secrets("openshift-config").Update(context.TODO(),
+				pullSecretObject("openshift-config", "pull-secret", testNewPullSecret), metav1.UpdateOptions{})
+			require.NoError(t, err)
+			done, err = step.done(runner)
+			require.NoError(t, err)
+			assert.True(t, done)
+		})
+	}
+}
+
+func TestUpdateManifestWork(t *testing.T) {
+	defer func(wait time.Duration) { manifestWorkSyncWait = wait }(manifestWorkSyncWait)
+	manifestWorkSyncWait = 0
+
+	oldSecret, err := json.Marshal(pullSecretObject("ocm-production-cluster-1", "mycluster-pull", `{"auths":{"ecr.example.com":{"auth":"ZWNy","email":""},"quay.io":{"auth":"b2xk","email":"old@example.com"}}}`))
+	require.NoError(t, err)
+	hostedCluster := &hypershiftv1beta1.HostedCluster{
+		TypeMeta:   metav1.TypeMeta{Kind: "HostedCluster", APIVersion: "hypershift.openshift.io/v1beta1"},
+		ObjectMeta: metav1.ObjectMeta{Name: "mycluster", Namespace: "ocm-production-cluster-1"},
+		Spec:       hypershiftv1beta1.HostedClusterSpec{PullSecret: corev1.LocalObjectReference{Name: "mycluster-pull"}},
+	}
+	hcRaw, err := json.Marshal(hostedCluster)
+	require.NoError(t, err)
+	secretRaw := append([]byte(`{"kind":"Secret","apiVersion":"v1",`), oldSecret[1:]...)
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, workv1.AddToScheme(scheme))
+	kubeCli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&workv1.ManifestWork{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-1", Namespace: "mc-1"},
+		Spec: workv1.ManifestWorkSpec{Workload: workv1.ManifestsTemplate{Manifests: []workv1.Manifest{
+			{RawExtension: runtime.RawExtension{Raw: secretRaw}},
+			{RawExtension: runtime.RawExtension{Raw: hcRaw}},
+		}}},
+	}).Build()
+
+	require.NoError(t, updateManifestWork(kubeCli, "cluster-1", "mc-1", "mycluster", []byte(testNewPullSecret)))
+
+	manifestWork := &workv1.ManifestWork{}
+	require.NoError(t, kubeCli.Get(context.TODO(), types.NamespacedName{Name: "cluster-1", Namespace: "mc-1"}, manifestWork))
+	secret := &corev1.Secret{}
+	require.NoError(t, json.Unmarshal(manifestWork.Spec.Workload.Manifests[0].Raw, secret))
+	hc := &hypershiftv1beta1.HostedCluster{}
+	require.NoError(t, json.Unmarshal(manifestWork.Spec.Workload.Manifests[1].Raw, hc))
+
+	assert.Regexp(t, `^mycluster-pull-[0-9a-f]{6}$`, secret.Name)
+	assert.Equal(t, secret.Name, hc.Spec.PullSecret.Name)
+	auths := pullSecretAuths(secret.Data[".dockerconfigjson"])
+	assert.Equal(t, map[string]string{"ecr.example.com": "ZWNy", "quay.io": "bmV3", "cloud.openshift.com": "bmV3"}, auths)
+}
+
+func TestRolloutSteps(t *testing.T) {
+	pod := func(namespace, name string, labels map[string]string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels}}
+	}
+	targetClientSet := kubefake.NewSimpleClientset(
+		pod("openshift-monitoring", "telemeter-client-1", map[string]string{"app.kubernetes.io/name": "telemeter-client"}),
+		pod("openshift-monitoring", "prometheus-0", map[string]string{"app.kubernetes.io/name": "prometheus"}),
+		pod("openshift-ocm-agent-operator", "ocm-agent-1", map[string]string{"app": "ocm-agent"}),
+	)
+
+	state := newTestTransferState(t)
+	runner, _ := newTestTransferRunner(t, state, nil)
+	runner.targetClientSet = targetClientSet
+
+	telemeter := findTransferStep(t, stepRolloutTelemeter)
+	require.True(t, telemeter.applies(state))
+	require.NoError(t, telemeter.run(runner))
+	pods, err := targetClientSet.CoreV1().Pods("openshift-monitoring").List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, pods.Items, 1)
+	assert.Equal(t, "prometheus-0", pods.Items[0].Name)
+
+	// Re-running the rollout without pods left is harmless
+	require.NoError(t, telemeter.run(runner))
+
+	ocmAgent := findTransferStep(t, stepRolloutOCMAgent)
+	require.NoError(t, ocmAgent.run(runner))
+	pods, err = targetClientSet.CoreV1().Pods("openshift-ocm-agent-operator").List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, pods.Items)
+
+	state.Hypershift = true
+	assert.False(t, telemeter.applies(state))
+	assert.False(t, ocmAgent.applies(state))
+}
+
+func TestVerifyPullSecretStep(t *testing.T) {
+	mixed := `{"auths":{"cloud.openshift.com":{"auth":"bmV3","email":"new@example.com"},"quay.io":{"auth":"b2xk","email":"old@example.com"},"ecr.example.com":{"auth":"ZWNy"}}}`
+	tests := []struct {
+		name        string
+		actual      string
+		errContains string
+	}{
+		{name: "every credential of the new owner", actual: testNewPullSecret},
+		{name: "extra credentials are kept", actual: `{"auths":{"cloud.openshift.com":{"auth":"bmV3"},"quay.io":{"auth":"bmV3"},"ecr.example.com":{"auth":"ZWNy"}}}`},
+		{name: "credentials of the old owner", actual: mixed, errContains: "lacks the credentials of the new owner for: quay.io"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := newTestTransferState(t)
+			runner, _ := newTestTransferRunner(t, state, nil)
+			runner.pullSecret = []byte(testNewPullSecret)
+			runner.targetClientSet = kubefake.NewSimpleClientset(pullSecretObject("openshift-config", "pull-secret", tt.actual))
+
+			err := findTransferStep(t, stepVerifyPullSecret).run(runner)
+			if tt.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errContains)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestPatchSubscriptionStep(t *testing.T) {
+	for _, orgChanged := range []bool{true, false} {
+		t.Run(fmt.Sprintf("organization changed: %v", orgChanged), func(t *testing.T) {
+			ocm := newFakeOCM()
+			state := newTestTransferState(t)
+			if !orgChanged {
+				state.NewOwner.OrganizationID = state.OldOwner.OrganizationID
+			}
+			runner, _ := newTestTransferRunner(t, state, newFakeOCMConnection(t, ocm))
+			runner.confirm = func() bool {
+				t.Error("no confirmation is expected for the old owner's subscription")
+				return false
+			}
+
+			require.NoError(t, runner.runSteps([]transferStep{findTransferStep(t, stepPatchSubscription)}, ""))
+			assert.Equal(t, state.NewOwner.OrganizationID, ocm.subscriptionOrg)
+			assert.Equal(t, "acc-new", ocm.creatorID)
+			expectedPatches := 1
+			if orgChanged {
+				expectedPatches = 2
+			}
+			assert.Equal(t, expectedPatches, ocm.countRequests("PATCH /api/accounts_mgmt/v1/subscriptions/sub-1"))
+
+			// Re-running the step finds the subscription transferred
+			require.NoError(t, runner.runSteps([]transferStep{findTransferStep(t, stepPatchSubscription)}, stepPatchSubscription))
+			assert.Equal(t, expectedPatches, ocm.countRequests("PATCH /api/accounts_mgmt/v1/subscriptions/sub-1"))
+		})
+	}
+}
+
+func TestPatchSubscriptionStep_UnexpectedOwner(t *testing.T) {
+	ocm := newFakeOCM()
+	ocm.creatorID = "acc-someone-else"
+	state := newTestTransferState(t)
+	runner, _ := newTestTransferRunner(t, state, newFakeOCMConnection(t, ocm))
+	runner.confirm = func() bool { return false }
+
+	err := runner.runSteps([]transferStep{findTransferStep(t, stepPatchSubscription)}, "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "aborted by the user")
+	assert.Equal(t, 0, ocm.countRequests("PATCH /api/accounts_mgmt/v1/subscriptions/sub-1"))
+}
+
+func TestSwapRoleBindingStep(t *testing.T) {
+	ocm := newFakeOCM()
+	state := newTestTransferState(t)
+	runner, _ := newTestTransferRunner(t, state, newFakeOCMConnection(t, ocm))
+	step := findTransferStep(t, stepSwapRoleBinding)
+
+	require.NoError(t, runner.runSteps([]transferStep{step}, ""))
+	assert.Equal(t, map[string]string{"rb-new-1": "acc-new"}, ocm.roleBindings)
+
+	// A run interrupted after creating the new role binding is completed without a second binding
+	ocm.roleBindings["rb-old"] = "acc-old"
+	require.NoError(t, runner.runSteps([]transferStep{step}, stepSwapRoleBinding))
+	assert.Equal(t, map[string]string{"rb-new-1": "acc-new"}, ocm.roleBindings)
+	assert.Equal(t, 2, ocm.countRequests("POST /api/accounts_mgmt/v1/role_bindings"))
+
+	// Nothing changes once the role binding is swapped
+	require.NoError(t, runner.runSteps([]transferStep{step}, stepSwapRoleBinding))
+	assert.Equal(t, 2, ocm.countRequests("POST /api/accounts_mgmt/v1/role_bindings"))
+}
+
+func TestReregisterAndValidateSteps(t *testing.T) {
+	ocm := newFakeOCM()
+	state := newTestTransferState(t)
+	runner, _ := newTestTransferRunner(t, state, newFakeOCMConnection(t, ocm))
+	steps := []transferStep{findTransferStep(t, stepReregisterCluster), findTransferStep(t, stepValidateTransfer)}
+
+	err := steps[1].run(runner)
+	require.Error(t, err, "the cluster is still in the old organization")
+
+	require.NoError(t, runner.runSteps(steps, ""))
+	assert.Equal(t, "org-new", ocm.clusterOrg)
+	assert.Equal(t, 1, ocm.countRequests("POST /api/clusters_mgmt/v1/register_cluster"))
+
+	// Transfers within the organization don't re-register the cluster
+	state = newTestTransferState(t)
+	state.NewOwner.OrganizationID = state.OldOwner.OrganizationID
+	ocm = newFakeOCM()
+	runner, _ = newTestTransferRunner(t, state, newFakeOCMConnection(t, ocm))
+	require.NoError(t, runner.runSteps(steps, ""))
+	assert.True(t, state.Steps[stepReregisterCluster].Skipped)
+	assert.Equal(t, 0, ocm.countRequests("POST /api/clusters_mgmt/v1/register_cluster"))
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/openshift/osdctl/cmd/alerts"
 	"github.com/openshift/osdctl/cmd/cloudtrail"
 	"github.com/openshift/osdctl/cmd/cluster"
+	"github.com/openshift/osdctl/cmd/cluster/access"
 	"github.com/openshift/osdctl/cmd/cost"
 	"github.com/openshift/osdctl/cmd/dynatrace"
 	"github.com/openshift/osdctl/cmd/env"
@@ -78,6 +79,9 @@ func NewCmdRoot(streams genericclioptions.IOStreams) *cobra.Command {
 			if shouldRunVersionCheck(skipVersionCheck, cmd.Use) {
 				versionCheck()
 			}
+
+			// Drop the break-glass kubeconfigs which expired since the last invocation
+			access.RemoveExpiredKubeconfigs(os.Stderr)
 		},
 	}
 
@@ -0,0 +1,123 @@
+package cost
+
+import (
+	"fmt"
+	"io"
+
+	awsprovider "github.com/openshift/osdctl/pkg/provider/aws"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+// applyOptions defines the flags of the apply command
+type applyOptions struct {
+	spec  string
+	prune bool
+	yes   bool
+
+	genericclioptions.IOStreams
+}
+
+// applyCmd applies a cost category spec
+func newCmdApply(streams genericclioptions.IOStreams) *cobra.Command {
+	ops := &applyOptions{IOStreams: streams}
+	applyCmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Create, update and delete cost categories to match a YAML spec",
+		Long: `Creates, updates and, with --prune, deletes cost category definitions so they match the spec.
+Categories which already match are left untouched, so applying the same spec again is a no-op.
+The changes are printed and confirmed before they are applied, use 'osdctl cost reconcile --spec --dry-run'
+to only print them. The cost categories deleted by --prune are listed again and their deletion has to be
+confirmed by typing 'delete', even with --yes.
+
+The spec lists the cost categories and their rules, which are evaluated in order. A rule matches the
+accounts listed, the accounts under the OUs listed (recursively), resources with one of the tag values,
+or any Cost Explorer expression given as JSON:
+
+  costCategories:
+  - name: ou-abcd-12345678
+    defaultValue: other
+    rules:
+    - value: ou-abcd-12345678
+      ous: [ou-abcd-12345678]
+    - value: shared
+      accounts: ["111111111111"]
+    - value: cluster-a
+      tag:
+        key: cluster
+        values: [a]
+    - value: not-prod
+      expression: '{"Not": {"Dimensions": {"Key": "LINKED_ACCOUNT", "Values": ["222222222222"]}}}'`,
+		Example: `  # Apply a spec and delete all cost categories which aren't in it
+  osdctl cost apply --spec cost-categories.yaml --prune`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if ops.spec == "" {
+				cmdutil.CheckErr(cmdutil.UsageErrorf(cmd, "Please provide --spec"))
+			}
+			awsClient, err := opsCost.initAWSClients()
+			cmdutil.CheckErr(err)
+			cmdutil.CheckErr(ops.run(awsClient))
+		},
+	}
+	applyCmd.Flags().StringVar(&ops.spec, "spec", "", "YAML file with the desired cost categories")
+	applyCmd.Flags().BoolVar(&ops.prune, "prune", false, "delete existing cost categories which aren't in the spec")
+	applyCmd.Flags().BoolVarP(&ops.yes, "yes", "y", false, "apply the changes without asking for confirmation, deletions by --prune are still confirmed")
+
+	return applyCmd
+}
+
+func (o *applyOptions) run(awsClient awsprovider.Client) error {
+	spec, err := loadCostCategorySpec(o.spec)
+	if err != nil {
+		return err
+	}
+	if err := spec.resolve(awsClient); err != nil {
+		return err
+	}
+	changes, err := planCostCategories(spec, awsClient, o.prune)
+	if err != nil {
+		return err
+	}
+
+	if printCostCategoryDiff(o.Out, changes) == 0 {
+		return nil
+	}
+	if !o.yes && !confirm(o.In, o.Out) {
+		return fmt.Errorf("aborted")
+	}
+	// Deleting a cost category loses its history in Cost Explorer, so it is never confirmed by --yes alone
+	if deletions := costCategoryDeletions(changes); len(deletions) > 0 && !confirmDeletion(o.In, o.Out, deletions) {
+		return fmt.Errorf("aborted")
+	}
+	return applyCostCategories(o.Out, changes, awsClient)
+}
+
+// costCategoryDeletions returns the names of the cost categories the plan deletes
+func costCategoryDeletions(changes []costCategoryChange) []string {
+	var names []string
+	for _, change := range changes {
+		if change.Action == actionDelete {
+			names = append(names, change.Name)
+		}
+	}
+	return names
+}
+
+func confirmDeletion(in io.Reader, out io.Writer, names []string) bool {
+	fmt.Fprintf(out, "--prune deletes %d cost categories which aren't in the spec:\n", len(names))
+	for _, name := range names {
+		fmt.Fprintf(out, "  - %s\n", name)
+	}
+	fmt.Fprint(out, "Type 'delete' to delete them: ")
+	var answer string
+	_, _ = fmt.Fscanln(in, &answer)
+	return answer == "delete"
+}
+
+func confirm(in io.Reader, out io.Writer) bool {
+	fmt.Fprint(out, "Apply these changes? (y/N) ")
+	var answer string
+	_, _ = fmt.Fscanln(in, &answer)
+	return answer == "y" || answer == "Y" || answer == "yes"
+}
@@ -8,10 +8,12 @@ import (
 	"log"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	outputflag "github.com/openshift/osdctl/cmd/getoutput"
 	"github.com/openshift/osdctl/internal/utils/globalflags"
 	awsprovider "github.com/openshift/osdctl/pkg/provider/aws"
 	"github.com/spf13/cobra"
@@ -21,6 +23,13 @@ import (
 
 const (
 	expectedAccountName = "rh-control"
+
+	carbonBucketName = "rhcontrol-ccft-reports"
+	carbonBasePath   = "reports/carbon-emissions/data/carbon_model_version=v3.0.0/"
+
+	defaultEmissionsColumn = "total_mbm_emissions_value"
+
+	usagePeriodPrefix = "usage_period="
 )
 
 var (
@@ -33,8 +42,15 @@ var (
 
 // carbonReportOptions defines the struct for running carbon-report command
 type carbonReportOptions struct {
-	usagePeriod string
-	account     string
+	usagePeriod     string
+	startPeriod     string
+	endPeriod       string
+	account         string
+	accounts        []string
+	ou              string
+	trends          bool
+	emissionsColumn string
+	output          string
 
 	genericclioptions.IOStreams
 	GlobalOptions *globalflags.GlobalOptions
@@ -42,8 +58,9 @@ type carbonReportOptions struct {
 
 func newCarbonReportOptions(streams genericclioptions.IOStreams, globalOpts *globalflags.GlobalOptions) *carbonReportOptions {
 	return &carbonReportOptions{
-		IOStreams:     streams,
-		GlobalOptions: globalOpts,
+		emissionsColumn: defaultEmissionsColumn,
+		IOStreams:       streams,
+		GlobalOptions:   globalOpts,
 	}
 }
 
@@ -69,6 +86,82 @@ func (o *carbonReportOptions) validateUsagePeriod() error {
 	return fmt.Errorf("invalid usage period format '%s'. Expected format: YYYY or YYYY-MM", o.usagePeriod)
 }
 
+// validatePeriodRange validates the start and end periods are in YYYY-MM format, with start before end
+func (o *carbonReportOptions) validatePeriodRange() error {
+	if o.usagePeriod != "" {
+		return fmt.Errorf("--usage-period can't be combined with --start-period and --end-period")
+	}
+	if o.startPeriod == "" || o.endPeriod == "" {
+		return fmt.Errorf("both --start-period and --end-period are required for a period range")
+	}
+	yearMonthRegex := regexp.MustCompile(`^\d{4}-(0[1-9]|1[0-2])$`)
+	for _, period := range []string{o.startPeriod, o.endPeriod} {
+		if !yearMonthRegex.MatchString(period) {
+			return fmt.Errorf("invalid period format '%s'. Expected format: YYYY-MM", period)
+		}
+	}
+	if o.startPeriod > o.endPeriod {
+		return fmt.Errorf("start period %s is after end period %s", o.startPeriod, o.endPeriod)
+	}
+	return nil
+}
+
+// validatePeriods validates either the usage period or the period range
+func (o *carbonReportOptions) validatePeriods() error {
+	if o.startPeriod != "" || o.endPeriod != "" {
+		return o.validatePeriodRange()
+	}
+	return o.validateUsagePeriod()
+}
+
+// validateAccounts validates that exactly one of --account, --accounts and --ou is set
+func (o *carbonReportOptions) validateAccounts() error {
+	set := 0
+	for _, isSet := range []bool{o.account != "", len(o.accounts) > 0, o.ou != ""} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("exactly one of --account, --accounts or --ou is required")
+	}
+
+	if o.account != "" {
+		return o.validateAccount()
+	}
+	for _, account := range o.accounts {
+		if err := (&carbonReportOptions{account: account}).validateAccount(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// aggregate returns whether the emissions are aggregated into trends instead of exporting the raw rows,
+// which is the case for several accounts, --trends and structured output
+func (o *carbonReportOptions) aggregate() bool {
+	return o.trends || o.account == "" || o.output != ""
+}
+
+// accountIDs returns the accounts to report on, the accounts below --ou (recursively) if set
+func (o *carbonReportOptions) accountIDs(awsClient awsprovider.Client) ([]string, error) {
+	if o.account != "" {
+		return []string{o.account}, nil
+	}
+	if len(o.accounts) > 0 {
+		return uniqueStrings(o.accounts), nil
+	}
+
+	ids, err := awsprovider.GetAccountsRecursive(awsClient, o.ou)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts of OU %s: %w", o.ou, err)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no accounts found under OU %s", o.ou)
+	}
+	return uniqueStrings(ids), nil
+}
+
 // validateAccount validates that the account is a number with at least 12 digits
 func (o *carbonReportOptions) validateAccount() error {
 	if o.account == "" {
@@ -117,67 +210,79 @@ func createAWSClientWithOptions(opts *costOptions) (awsprovider.Client, error) {
 	return awsClient, nil
 }
 
+// listUsagePeriods returns the usage periods (YYYY-MM) of all S3 directories with carbon emissions data
+func listUsagePeriods(awsClient awsprovider.Client) ([]string, error) {
+	periodRegex := regexp.MustCompile(`usage_period=(\d{4}-\d{2})/`)
+	var periods []string
+	var continuationToken *string
+	for {
+		result, err := awsClient.ListObjectsV2(&s3.ListObjectsV2Input{
+			Bucket:            aws.String(carbonBucketName),
+			Prefix:            aws.String(carbonBasePath),
+			Delimiter:         aws.String("/"),
+			ContinuationToken: continuationToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list S3 objects: %w", err)
+		}
+
+		// Format: reports/carbon-emissions/data/carbon_model_version=v3.0.0/usage_period=YYYY-MM/
+		for _, prefix := range result.CommonPrefixes {
+			if prefix.Prefix == nil {
+				continue
+			}
+			if match := periodRegex.FindStringSubmatch(*prefix.Prefix); len(match) > 1 {
+				periods = append(periods, match[1])
+			}
+		}
+
+		if result.NextContinuationToken == nil {
+			return periods, nil
+		}
+		continuationToken = result.NextContinuationToken
+	}
+}
+
 // getUsagePeriodDirectories retrieves S3 directories matching the usage period pattern
 func getUsagePeriodDirectories(awsClient awsprovider.Client, usagePeriod string) ([]string, error) {
-	bucketName := "rhcontrol-ccft-reports"
-	basePath := "reports/carbon-emissions/data/carbon_model_version=v3.0.0/"
-	delimiter := "/"
-
-	result, err := awsClient.ListObjectsV2(&s3.ListObjectsV2Input{
-		Bucket:    aws.String(bucketName),
-		Prefix:    aws.String(basePath),
-		Delimiter: aws.String(delimiter),
-	})
+	periods, err := listUsagePeriods(awsClient)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list S3 objects: %w", err)
+		return nil, err
 	}
 
+	// YYYY matches all months of the year, YYYY-MM the month only
 	var matchingDirs []string
-	usagePeriodPrefix := "usage_period="
-
-	// Extract year and optional month from usage period
-	var yearFilter, monthFilter string
-	if len(usagePeriod) == 4 {
-		// YYYY format - match all months in this year
-		yearFilter = usagePeriod
-	} else {
-		// YYYY-MM format - match specific month
-		yearFilter = usagePeriod[:4]
-		monthFilter = usagePeriod[5:7]
+	for _, period := range periods {
+		if period == usagePeriod || period[:4] == usagePeriod {
+			matchingDirs = append(matchingDirs, usagePeriodPrefix+period)
+		}
 	}
 
-	// Check CommonPrefixes for matching directories
-	for _, prefix := range result.CommonPrefixes {
-		if prefix.Prefix == nil {
-			continue
-		}
+	return matchingDirs, nil
+}
 
-		// Extract the directory name from the full prefix
-		// Format: reports/carbon-emissions/data/carbon_model_version=v3.0.0/usage_period=YYYY-MM/
-		prefixStr := *prefix.Prefix
-
-		// Find the usage_period part
-		if idx := regexp.MustCompile(`usage_period=(\d{4}-\d{2})/`).FindStringSubmatch(prefixStr); len(idx) > 1 {
-			dirPeriod := idx[1] // e.g., "2024-03"
-			dirYear := dirPeriod[:4]
-			dirMonth := dirPeriod[5:7]
-
-			// Check if it matches our filter
-			if dirYear == yearFilter {
-				if monthFilter == "" || dirMonth == monthFilter {
-					matchingDirs = append(matchingDirs, usagePeriodPrefix+dirPeriod)
-				}
-			}
+// getUsagePeriodRangeDirectories retrieves the S3 directories of the usage periods from start to end (YYYY-MM, inclusive)
+func getUsagePeriodRangeDirectories(awsClient awsprovider.Client, start, end string) ([]string, error) {
+	periods, err := listUsagePeriods(awsClient)
+	if err != nil {
+		return nil, err
+	}
+
+	var matchingDirs []string
+	for _, period := range periods {
+		if period >= start && period <= end {
+			matchingDirs = append(matchingDirs, usagePeriodPrefix+period)
 		}
 	}
+	sort.Strings(matchingDirs)
 
 	return matchingDirs, nil
 }
 
-// processCarbonData downloads and processes carbon emissions data for a specific usage period directory
-func processCarbonData(awsClient awsprovider.Client, bucketName, usagePeriodDir, accountID string) ([][]string, []string, error) {
-	basePath := "reports/carbon-emissions/data/carbon_model_version=v3.0.0/"
-	prefix := basePath + usagePeriodDir + "/"
+// readCarbonData downloads the carbon emissions data of a usage period directory,
+// calls handleHeader with the CSV header and handleRow for every CSV row
+func readCarbonData(awsClient awsprovider.Client, bucketName, usagePeriodDir string, handleHeader func(header []string) error, handleRow func(row []string) error) error {
+	prefix := carbonBasePath + usagePeriodDir + "/"
 
 	// List objects in the directory to find the .gz file
 	result, err := awsClient.ListObjectsV2(&s3.ListObjectsV2Input{
@@ -185,7 +290,7 @@ func processCarbonData(awsClient awsprovider.Client, bucketName, usagePeriodDir,
 		Prefix: aws.String(prefix),
 	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to list objects in %s: %w", usagePeriodDir, err)
+		return fmt.Errorf("failed to list objects in %s: %w", usagePeriodDir, err)
 	}
 
 	// Find the .gz file
@@ -198,7 +303,7 @@ func processCarbonData(awsClient awsprovider.Client, bucketName, usagePeriodDir,
 	}
 
 	if gzFile == "" {
-		return nil, nil, fmt.Errorf("no .gz file found in %s", usagePeriodDir)
+		return fmt.Errorf("no .gz file found in %s", usagePeriodDir)
 	}
 
 	// Download the .gz file
@@ -207,14 +312,14 @@ func processCarbonData(awsClient awsprovider.Client, bucketName, usagePeriodDir,
 		Key:    aws.String(gzFile),
 	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to download %s: %w", gzFile, err)
+		return fmt.Errorf("failed to download %s: %w", gzFile, err)
 	}
 	defer getResult.Body.Close()
 
 	// Decompress the gzip file
 	gzReader, err := gzip.NewReader(getResult.Body)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to decompress %s: %w", gzFile, err)
+		return fmt.Errorf("failed to decompress %s: %w", gzFile, err)
 	}
 	defer gzReader.Close()
 
@@ -224,131 +329,203 @@ func processCarbonData(awsClient awsprovider.Client, bucketName, usagePeriodDir,
 	// Read the header
 	header, err := csvReader.Read()
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to read CSV header: %w", err)
+		return fmt.Errorf("failed to read CSV header: %w", err)
 	}
-
-	// Find the usage_account_id column index and identify columns to exclude
-	accountIDCol := -1
-	var excludeIndices []int
-	var filteredHeader []string
-
-	for i, col := range header {
-		if col == "usage_account_id" {
-			accountIDCol = i
-		}
-		// Check if this column should be excluded
-		if excludedColumns[col] {
-			excludeIndices = append(excludeIndices, i)
-		} else {
-			filteredHeader = append(filteredHeader, col)
-		}
+	if err := handleHeader(header); err != nil {
+		return err
 	}
 
-	if accountIDCol == -1 {
-		return nil, nil, fmt.Errorf("usage_account_id column not found in CSV")
-	}
-
-	// Create a map for quick lookup of excluded indices
-	excludeMap := make(map[int]bool)
-	for _, idx := range excludeIndices {
-		excludeMap[idx] = true
-	}
-
-	// Read and filter rows
-	var filteredRows [][]string
 	for {
 		row, err := csvReader.Read()
 		if err == io.EOF {
-			break
+			return nil
 		}
 		if err != nil {
-			return nil, nil, fmt.Errorf("failed to read CSV row: %w", err)
+			return fmt.Errorf("failed to read CSV row: %w", err)
+		}
+		if err := handleRow(row); err != nil {
+			return err
+		}
+	}
+}
+
+// columnIndex returns the index of a CSV column, -1 if it doesn't exist
+func columnIndex(header []string, column string) int {
+	for i, col := range header {
+		if col == column {
+			return i
 		}
+	}
+	return -1
+}
+
+// processCarbonData downloads and processes carbon emissions data for a specific usage period directory
+func processCarbonData(awsClient awsprovider.Client, bucketName, usagePeriodDir, accountID string) ([][]string, []string, error) {
+	var filteredRows [][]string
+	var header, filteredHeader []string
+	accountIDCol := -1
 
+	err := readCarbonData(awsClient, bucketName, usagePeriodDir, func(csvHeader []string) error {
+		// Find the usage_account_id column index and remove the excluded columns
+		header = csvHeader
+		accountIDCol = columnIndex(header, "usage_account_id")
+		if accountIDCol == -1 {
+			return fmt.Errorf("usage_account_id column not found in CSV")
+		}
+		filteredHeader = filterColumns(header, header)
+		return nil
+	}, func(row []string) error {
 		// Filter by account ID
 		if accountIDCol < len(row) && row[accountIDCol] == accountID {
-			// Filter out excluded columns
-			var filteredRow []string
-			for i, val := range row {
-				if !excludeMap[i] {
-					filteredRow = append(filteredRow, val)
-				}
-			}
-			filteredRows = append(filteredRows, filteredRow)
+			filteredRows = append(filteredRows, filterColumns(header, row))
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
 	return filteredRows, filteredHeader, nil
 }
 
+// filterColumns removes the excluded columns from a row
+func filterColumns(header, row []string) []string {
+	filtered := []string{}
+	for i, val := range row {
+		if i < len(header) && excludedColumns[header[i]] {
+			continue
+		}
+		filtered = append(filtered, val)
+	}
+	return filtered
+}
+
 // newCmdCarbonReport represents the carbon-report command
 func newCmdCarbonReport(streams genericclioptions.IOStreams, globalOpts *globalflags.GlobalOptions) *cobra.Command {
 	ops := newCarbonReportOptions(streams, globalOpts)
 	carbonReportCmd := &cobra.Command{
 		Use:   "carbon-report",
 		Short: "Generate carbon emissions report csv to stdout for a given AWS Account and Usage Period",
+		Long: `Generate carbon emissions report csv to stdout for a given AWS Account and Usage Period.
+
+For a single --account the rows of the carbon emissions export are printed as they are.
+With several --accounts, all accounts below an --ou, --trends or -o json/yaml the emissions are
+aggregated instead: the month-by-month emissions of the accounts in total, by account, by service
+and by region.`,
+		Example: `  # Export the carbon emissions data of an account for 2024
+  osdctl cost carbon-report --account 123456789012 --usage-period 2024
+
+  # Monthly emissions of all accounts below an OU from January to June 2024 as JSON
+  osdctl cost carbon-report --ou ou-abcd-12345678 --start-period 2024-01 --end-period 2024-06 -o json`,
 		Run: func(cmd *cobra.Command, args []string) {
 			// Validate usage period
-			cmdutil.CheckErr(ops.validateUsagePeriod())
+			cmdutil.CheckErr(ops.validatePeriods())
 
-			// Validate account
-			cmdutil.CheckErr(ops.validateAccount())
+			// Validate accounts
+			cmdutil.CheckErr(ops.validateAccounts())
 
 			awsClient, err := CreateAWSClient()
 			cmdutil.CheckErr(err)
 
-			// Get usage period directories from S3
-			directories, err := getUsagePeriodDirectories(awsClient, ops.usagePeriod)
-			cmdutil.CheckErr(err)
+			cmdutil.CheckErr(ops.run(awsClient))
+		},
+	}
 
-			if len(directories) == 0 {
-				log.Printf("No directories found for usage period: %s", ops.usagePeriod)
-				return
-			}
+	carbonReportCmd.Flags().StringVar(&ops.usagePeriod, "usage-period", "", "Usage period in YYYY or YYYY-MM format")
+	carbonReportCmd.Flags().StringVar(&ops.startPeriod, "start-period", "", "First usage period of a range in YYYY-MM format")
+	carbonReportCmd.Flags().StringVar(&ops.endPeriod, "end-period", "", "Last usage period of a range in YYYY-MM format")
+	carbonReportCmd.Flags().StringVar(&ops.account, "account", "", "AWS account number")
+	carbonReportCmd.Flags().StringSliceVar(&ops.accounts, "accounts", nil, "AWS account numbers to aggregate, comma separated")
+	carbonReportCmd.Flags().StringVar(&ops.ou, "ou", "", "aggregate the emissions of all accounts below this OU")
+	carbonReportCmd.Flags().BoolVar(&ops.trends, "trends", false, "aggregate the emissions of a single --account instead of exporting its rows")
+	carbonReportCmd.Flags().StringVar(&ops.emissionsColumn, "emissions-column", defaultEmissionsColumn, "column of the export with the emissions to aggregate")
 
-			bucketName := "rhcontrol-ccft-reports"
-			var allRows [][]string
-			var csvHeader []string
+	return carbonReportCmd
+}
 
-			// Process each directory
-			for _, dir := range directories {
-				log.Printf("Processing usage period: %s", dir)
+func (o *carbonReportOptions) run(awsClient awsprovider.Client) error {
+	if o.GlobalOptions != nil {
+		o.output = o.GlobalOptions.Output
+	}
 
-				rows, header, err := processCarbonData(awsClient, bucketName, dir, ops.account)
-				if err != nil {
-					cmdutil.CheckErr(fmt.Errorf("error processing %s: %w", dir, err))
-				}
+	// Get usage period directories from S3
+	var directories []string
+	var err error
+	period := o.usagePeriod
+	if o.startPeriod != "" {
+		period = o.startPeriod + " to " + o.endPeriod
+		directories, err = getUsagePeriodRangeDirectories(awsClient, o.startPeriod, o.endPeriod)
+	} else {
+		directories, err = getUsagePeriodDirectories(awsClient, o.usagePeriod)
+	}
+	if err != nil {
+		return err
+	}
 
-				if len(csvHeader) == 0 {
-					csvHeader = header
-				}
+	if len(directories) == 0 {
+		log.Printf("No directories found for usage period: %s", period)
+		return nil
+	}
 
-				allRows = append(allRows, rows...)
-				log.Printf("Found %d rows for account %s in %s", len(rows), ops.account, dir)
-			}
+	if !o.aggregate() {
+		return o.writeRows(awsClient, directories)
+	}
 
-			// Write CSV to stdout
-			csvWriter := csv.NewWriter(os.Stdout)
-			defer csvWriter.Flush()
+	accounts, err := o.accountIDs(awsClient)
+	if err != nil {
+		return err
+	}
 
-			// Write header
-			if err := csvWriter.Write(csvHeader); err != nil {
-				cmdutil.CheckErr(fmt.Errorf("failed to write CSV header: %w", err))
-			}
+	emissions := newCarbonEmissions(o.emissionsColumn)
+	for _, dir := range directories {
+		log.Printf("Processing usage period: %s", dir)
+		if err := emissions.add(awsClient, carbonBucketName, dir, accounts); err != nil {
+			return fmt.Errorf("error processing %s: %w", dir, err)
+		}
+	}
+	log.Printf("Aggregated emissions of %d accounts over %d usage periods", len(accounts), len(directories))
 
-			// Write all rows
-			for _, row := range allRows {
-				if err := csvWriter.Write(row); err != nil {
-					cmdutil.CheckErr(fmt.Errorf("failed to write CSV row: %w", err))
-				}
-			}
+	return outputflag.PrintResponse(o.output, emissions.report())
+}
 
-			log.Printf("Total rows exported: %d", len(allRows))
-		},
+// writeRows writes the rows of the account as CSV to stdout
+func (o *carbonReportOptions) writeRows(awsClient awsprovider.Client, directories []string) error {
+	var allRows [][]string
+	var csvHeader []string
+
+	// Process each directory
+	for _, dir := range directories {
+		log.Printf("Processing usage period: %s", dir)
+
+		rows, header, err := processCarbonData(awsClient, carbonBucketName, dir, o.account)
+		if err != nil {
+			return fmt.Errorf("error processing %s: %w", dir, err)
+		}
+
+		if len(csvHeader) == 0 {
+			csvHeader = header
+		}
+
+		allRows = append(allRows, rows...)
+		log.Printf("Found %d rows for account %s in %s", len(rows), o.account, dir)
 	}
 
-	carbonReportCmd.Flags().StringVar(&ops.usagePeriod, "usage-period", "", "Usage period in YYYY or YYYY-MM format")
-	carbonReportCmd.Flags().StringVar(&ops.account, "account", "", "AWS account number")
+	// Write CSV to stdout
+	csvWriter := csv.NewWriter(o.Out)
+	defer csvWriter.Flush()
 
-	return carbonReportCmd
+	// Write header
+	if err := csvWriter.Write(csvHeader); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	// Write all rows
+	for _, row := range allRows {
+		if err := csvWriter.Write(row); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+
+	log.Printf("Total rows exported: %d", len(allRows))
+	return nil
 }
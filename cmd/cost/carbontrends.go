@@ -0,0 +1,177 @@
+package cost
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	awsprovider "github.com/openshift/osdctl/pkg/provider/aws"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	carbonAccountColumn = "usage_account_id"
+	carbonServiceColumn = "product_code"
+	carbonRegionColumn  = "region_code"
+)
+
+// carbonEmissions aggregates the emissions of the carbon emissions export per usage period
+type carbonEmissions struct {
+	column  string
+	periods []string
+	unit    string
+	total   map[string]decimal.Decimal
+	// accounts, services and regions map a name to its emissions per period
+	accounts map[string]map[string]decimal.Decimal
+	services map[string]map[string]decimal.Decimal
+	regions  map[string]map[string]decimal.Decimal
+}
+
+func newCarbonEmissions(column string) *carbonEmissions {
+	return &carbonEmissions{
+		column:   column,
+		total:    map[string]decimal.Decimal{},
+		accounts: map[string]map[string]decimal.Decimal{},
+		services: map[string]map[string]decimal.Decimal{},
+		regions:  map[string]map[string]decimal.Decimal{},
+	}
+}
+
+// add aggregates the emissions of the accounts in a usage period directory
+func (e *carbonEmissions) add(awsClient awsprovider.Client, bucketName, usagePeriodDir string, accounts []string) error {
+	period := strings.TrimPrefix(usagePeriodDir, usagePeriodPrefix)
+	e.periods = append(e.periods, period)
+
+	included := map[string]bool{}
+	for _, account := range accounts {
+		included[account] = true
+	}
+
+	var accountCol, serviceCol, regionCol, emissionsCol, unitCol int
+	return readCarbonData(awsClient, bucketName, usagePeriodDir, func(header []string) error {
+		accountCol = columnIndex(header, carbonAccountColumn)
+		serviceCol = columnIndex(header, carbonServiceColumn)
+		regionCol = columnIndex(header, carbonRegionColumn)
+		emissionsCol = columnIndex(header, e.column)
+		unitCol = columnIndex(header, strings.TrimSuffix(e.column, "_value")+"_unit")
+		for column, index := range map[string]int{carbonAccountColumn: accountCol, carbonServiceColumn: serviceCol, carbonRegionColumn: regionCol, e.column: emissionsCol} {
+			if index == -1 {
+				return fmt.Errorf("%s column not found in CSV", column)
+			}
+		}
+		return nil
+	}, func(row []string) error {
+		if len(row) <= max(accountCol, serviceCol, regionCol, emissionsCol) || !included[row[accountCol]] {
+			return nil
+		}
+		if row[emissionsCol] == "" {
+			return nil
+		}
+		emissions, err := decimal.NewFromString(row[emissionsCol])
+		if err != nil {
+			return fmt.Errorf("invalid %s '%s' of account %s: %w", e.column, row[emissionsCol], row[accountCol], err)
+		}
+		if unitCol != -1 && unitCol < len(row) && e.unit == "" {
+			e.unit = row[unitCol]
+		}
+
+		e.total[period] = e.total[period].Add(emissions)
+		addEmissions(e.accounts, row[accountCol], period, emissions)
+		addEmissions(e.services, row[serviceCol], period, emissions)
+		addEmissions(e.regions, row[regionCol], period, emissions)
+		return nil
+	})
+}
+
+func addEmissions(emissions map[string]map[string]decimal.Decimal, name, period string, value decimal.Decimal) {
+	if name == "" {
+		name = "(none)"
+	}
+	if emissions[name] == nil {
+		emissions[name] = map[string]decimal.Decimal{}
+	}
+	emissions[name][period] = emissions[name][period].Add(value)
+}
+
+// carbonTrend is the emissions of an account, service or region per usage period
+type carbonTrend struct {
+	Name      string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Emissions []decimal.Decimal `json:"emissions" yaml:"emissions"`
+	Total     decimal.Decimal   `json:"total" yaml:"total"`
+}
+
+// carbonTrendsReport is the aggregated emissions, month by month
+type carbonTrendsReport struct {
+	Periods  []string      `json:"periods" yaml:"periods"`
+	Unit     string        `json:"unit,omitempty" yaml:"unit,omitempty"`
+	Total    carbonTrend   `json:"total" yaml:"total"`
+	Accounts []carbonTrend `json:"accounts" yaml:"accounts"`
+	Services []carbonTrend `json:"services" yaml:"services"`
+	Regions  []carbonTrend `json:"regions" yaml:"regions"`
+}
+
+func (e *carbonEmissions) trend(name string, emissions map[string]decimal.Decimal) carbonTrend {
+	trend := carbonTrend{Name: name, Emissions: []decimal.Decimal{}}
+	for _, period := range e.periods {
+		trend.Emissions = append(trend.Emissions, emissions[period])
+		trend.Total = trend.Total.Add(emissions[period])
+	}
+	return trend
+}
+
+// trends returns the trends of all names, by descending total emissions
+func (e *carbonEmissions) trends(emissions map[string]map[string]decimal.Decimal) []carbonTrend {
+	trends := []carbonTrend{}
+	for name, perPeriod := range emissions {
+		trends = append(trends, e.trend(name, perPeriod))
+	}
+	sort.Slice(trends, func(i, j int) bool {
+		if !trends[i].Total.Equal(trends[j].Total) {
+			return trends[i].Total.GreaterThan(trends[j].Total)
+		}
+		return trends[i].Name < trends[j].Name
+	})
+	return trends
+}
+
+func (e *carbonEmissions) report() *carbonTrendsReport {
+	sort.Strings(e.periods)
+	return &carbonTrendsReport{
+		Periods:  e.periods,
+		Unit:     e.unit,
+		Total:    e.trend("", e.total),
+		Accounts: e.trends(e.accounts),
+		Services: e.trends(e.services),
+		Regions:  e.trends(e.regions),
+	}
+}
+
+// String returns the report as CSV, one row per dimension and name with the emissions of each period
+func (r *carbonTrendsReport) String() string {
+	rows := [][]string{append(append([]string{"Dimension", "Name"}, r.Periods...), "Total", "Unit")}
+	addRow := func(dimension string, trend carbonTrend) {
+		row := []string{dimension, trend.Name}
+		for _, emissions := range trend.Emissions {
+			row = append(row, emissions.String())
+		}
+		rows = append(rows, append(row, trend.Total.String(), r.Unit))
+	}
+
+	total := r.Total
+	total.Name = totalGroup
+	addRow("total", total)
+	for _, trend := range r.Accounts {
+		addRow("account", trend)
+	}
+	for _, trend := range r.Services {
+		addRow("service", trend)
+	}
+	for _, trend := range r.Regions {
+		addRow("region", trend)
+	}
+
+	// Writing to a strings.Builder can't fail
+	var b strings.Builder
+	_ = writeCSV(&b, rows...)
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,187 @@
+package cost
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/openshift/osdctl/internal/utils/globalflags"
+	"github.com/openshift/osdctl/pkg/provider/aws/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+const carbonHeader = "usage_account_id,payer_account_id,product_code,region_code,total_mbm_emissions_value,total_mbm_emissions_unit\n"
+
+// expectCarbonData mocks the export of a usage period
+func expectCarbonData(r *mock.MockClientMockRecorder, period, csvData string) {
+	prefix := carbonBasePath + usagePeriodPrefix + period + "/"
+	r.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String(carbonBucketName), Prefix: aws.String(prefix)}).
+		Return(&s3.ListObjectsV2Output{Contents: []types.Object{{Key: aws.String(prefix + "data.csv.gz")}}}, nil)
+	r.GetObject(&s3.GetObjectInput{Bucket: aws.String(carbonBucketName), Key: aws.String(prefix + "data.csv.gz")}).
+		DoAndReturn(func(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+			var buf bytes.Buffer
+			gzWriter := gzip.NewWriter(&buf)
+			_, _ = gzWriter.Write([]byte(csvData))
+			_ = gzWriter.Close()
+			return &s3.GetObjectOutput{Body: io.NopCloser(&buf)}, nil
+		})
+}
+
+// expectUsagePeriods mocks the usage period directories, split over two pages
+func expectUsagePeriods(r *mock.MockClientMockRecorder, periods ...string) {
+	var prefixes []types.CommonPrefix
+	for _, period := range periods {
+		prefixes = append(prefixes, types.CommonPrefix{Prefix: aws.String(carbonBasePath + usagePeriodPrefix + period + "/")})
+	}
+	half := len(prefixes) / 2
+	r.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String(carbonBucketName), Prefix: aws.String(carbonBasePath), Delimiter: aws.String("/")}).
+		Return(&s3.ListObjectsV2Output{CommonPrefixes: prefixes[:half], NextContinuationToken: aws.String("page-2")}, nil)
+	r.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String(carbonBucketName), Prefix: aws.String(carbonBasePath), Delimiter: aws.String("/"), ContinuationToken: aws.String("page-2")}).
+		Return(&s3.ListObjectsV2Output{CommonPrefixes: prefixes[half:]}, nil)
+}
+
+func TestGetUsagePeriodRangeDirectories(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockAWS := mock.NewMockClient(mockCtrl)
+	expectUsagePeriods(mockAWS.EXPECT(), "2023-12", "2024-02", "2024-01", "2024-03")
+
+	dirs, err := getUsagePeriodRangeDirectories(mockAWS, "2024-01", "2024-02")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"usage_period=2024-01", "usage_period=2024-02"}, dirs)
+}
+
+func TestCarbonEmissions(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockAWS := mock.NewMockClient(mockCtrl)
+	expectCarbonData(mockAWS.EXPECT(), "2024-01", carbonHeader+
+		"111111111111,999,AmazonEC2,us-east-1,0.5,MTCO2e\n"+
+		"111111111111,999,AmazonS3,us-east-1,0.1,MTCO2e\n"+
+		"222222222222,999,AmazonEC2,eu-west-1,1.2,MTCO2e\n"+
+		"333333333333,999,AmazonEC2,eu-west-1,9,MTCO2e\n")
+	expectCarbonData(mockAWS.EXPECT(), "2024-02", carbonHeader+
+		"111111111111,999,AmazonEC2,us-east-1,0.7,MTCO2e\n"+
+		"222222222222,999,AmazonEC2,eu-west-1,,MTCO2e\n")
+
+	emissions := newCarbonEmissions(defaultEmissionsColumn)
+	accounts := []string{"111111111111", "222222222222"}
+	// Directories are processed in any order, the report is sorted by period
+	require.NoError(t, emissions.add(mockAWS, carbonBucketName, "usage_period=2024-02", accounts))
+	require.NoError(t, emissions.add(mockAWS, carbonBucketName, "usage_period=2024-01", accounts))
+
+	report := emissions.report()
+	assert.Equal(t, []string{"2024-01", "2024-02"}, report.Periods)
+	assert.Equal(t, "MTCO2e", report.Unit)
+	assert.Equal(t, "2.5", report.Total.Total.String())
+	require.Len(t, report.Accounts, 2)
+	assert.Equal(t, "111111111111", report.Accounts[0].Name)
+	assert.Equal(t, "1.3", report.Accounts[0].Total.String())
+	require.Len(t, report.Services, 2)
+	assert.Equal(t, "AmazonEC2", report.Services[0].Name)
+	require.Len(t, report.Regions, 2)
+	assert.Equal(t, "eu-west-1", report.Regions[1].Name)
+
+	assert.Equal(t, `Dimension,Name,2024-01,2024-02,Total,Unit
+total,TOTAL,1.8,0.7,2.5,MTCO2e
+account,111111111111,0.6,0.7,1.3,MTCO2e
+account,222222222222,1.2,0,1.2,MTCO2e
+service,AmazonEC2,1.7,0.7,2.4,MTCO2e
+service,AmazonS3,0.1,0,0.1,MTCO2e
+region,us-east-1,0.6,0.7,1.3,MTCO2e
+region,eu-west-1,1.2,0,1.2,MTCO2e`, report.String())
+
+	data, err := json.Marshal(report)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"total":{"emissions":["1.8","0.7"],"total":"2.5"}`)
+	assert.Contains(t, string(data), `{"name":"AmazonS3","emissions":["0.1","0"],"total":"0.1"}`)
+}
+
+func TestCarbonEmissions_Errors(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockAWS := mock.NewMockClient(mockCtrl)
+	expectCarbonData(mockAWS.EXPECT(), "2024-01", "usage_account_id,region_code,total_mbm_emissions_value\n")
+	expectCarbonData(mockAWS.EXPECT(), "2024-02", carbonHeader+"111111111111,999,AmazonEC2,us-east-1,lots,MTCO2e\n")
+
+	err := newCarbonEmissions(defaultEmissionsColumn).add(mockAWS, carbonBucketName, "usage_period=2024-01", []string{"111111111111"})
+	assert.ErrorContains(t, err, "product_code column not found")
+
+	err = newCarbonEmissions(defaultEmissionsColumn).add(mockAWS, carbonBucketName, "usage_period=2024-02", []string{"111111111111"})
+	assert.ErrorContains(t, err, "invalid total_mbm_emissions_value 'lots' of account 111111111111")
+}
+
+func TestCarbonReportValidateAccounts(t *testing.T) {
+	testCases := map[string]struct {
+		ops         carbonReportOptions
+		errContains string
+	}{
+		"single account":   {ops: carbonReportOptions{account: "123456789012"}},
+		"several accounts": {ops: carbonReportOptions{accounts: []string{"123456789012", "210987654321"}}},
+		"OU":               {ops: carbonReportOptions{ou: "ou-abcd-12345678"}},
+		"none":             {errContains: "exactly one of"},
+		"account and OU":   {ops: carbonReportOptions{account: "123456789012", ou: "ou-abcd-12345678"}, errContains: "exactly one of"},
+		"invalid account":  {ops: carbonReportOptions{accounts: []string{"123456789012", "1234"}}, errContains: "invalid account format '1234'"},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.ops.validateAccounts()
+			if tc.errContains == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.errContains)
+			}
+		})
+	}
+}
+
+func TestCarbonReportValidatePeriods(t *testing.T) {
+	testCases := map[string]struct {
+		ops         carbonReportOptions
+		errContains string
+	}{
+		"usage period":     {ops: carbonReportOptions{usagePeriod: "2024"}},
+		"range":            {ops: carbonReportOptions{startPeriod: "2023-11", endPeriod: "2024-02"}},
+		"single month":     {ops: carbonReportOptions{startPeriod: "2024-02", endPeriod: "2024-02"}},
+		"open range":       {ops: carbonReportOptions{startPeriod: "2024-02"}, errContains: "both --start-period and --end-period"},
+		"year range":       {ops: carbonReportOptions{startPeriod: "2023", endPeriod: "2024"}, errContains: "Expected format: YYYY-MM"},
+		"reversed range":   {ops: carbonReportOptions{startPeriod: "2024-03", endPeriod: "2024-02"}, errContains: "is after end period"},
+		"range and period": {ops: carbonReportOptions{usagePeriod: "2024", startPeriod: "2024-01", endPeriod: "2024-02"}, errContains: "can't be combined"},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.ops.validatePeriods()
+			if tc.errContains == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.errContains)
+			}
+		})
+	}
+}
+
+func TestCarbonReportRun_Rows(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockAWS := mock.NewMockClient(mockCtrl)
+	expectUsagePeriods(mockAWS.EXPECT(), "2024-01", "2024-02")
+	expectCarbonData(mockAWS.EXPECT(), "2024-01", carbonHeader+"111111111111,999,AmazonEC2,us-east-1,0.5,MTCO2e\n")
+	expectCarbonData(mockAWS.EXPECT(), "2024-02", carbonHeader+"222222222222,999,AmazonEC2,us-east-1,0.5,MTCO2e\n")
+
+	var out bytes.Buffer
+	ops := newCarbonReportOptions(genericclioptions.IOStreams{Out: &out}, &globalflags.GlobalOptions{})
+	ops.account = "111111111111"
+	ops.usagePeriod = "2024"
+	require.NoError(t, ops.run(mockAWS))
+	assert.Equal(t, "product_code,region_code,total_mbm_emissions_value,total_mbm_emissions_unit\nAmazonEC2,us-east-1,0.5,MTCO2e\n", out.String())
+	assert.False(t, ops.aggregate())
+
+	ops.trends = true
+	assert.True(t, ops.aggregate())
+	assert.True(t, (&carbonReportOptions{ou: "ou-abcd-12345678"}).aggregate())
+	assert.True(t, (&carbonReportOptions{account: "111111111111", output: "json"}).aggregate())
+}
@@ -33,8 +33,10 @@ platforms may be added in the future)`,
 	costCmd.AddCommand(newCmdGet(streams, globalOpts))
 	costCmd.AddCommand(newCmdReconcile(streams))
 	costCmd.AddCommand(newCmdCreate(streams))
+	costCmd.AddCommand(newCmdApply(streams))
 	costCmd.AddCommand(newCmdList(streams, globalOpts))
 	costCmd.AddCommand(newCmdCarbonReport(streams, globalOpts))
+	costCmd.AddCommand(newCmdTrends(streams))
 
 	return costCmd
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
@@ -37,6 +38,7 @@ func newCmdGet(streams genericclioptions.IOStreams, globalOpts *globalflags.Glob
 	getCmd.Flags().StringVar(&ops.end, "end", "", "set end date range")
 	getCmd.Flags().BoolVar(&ops.csv, "csv", false, "output result as csv")
 	getCmd.Flags().BoolVar(&ops.sum, "sum", true, "Hide sum rows")
+	getCmd.Flags().StringVar(&ops.groupBy, "group-by", "", "break the cost down by one of 'service', 'usage-type', 'region' or 'tag:<key>'")
 
 	return getCmd
 }
@@ -61,6 +63,9 @@ func (o *getOptions) checkArgs(cmd *cobra.Command, _ []string) error {
 	if o.ou == "" {
 		return cmdutil.UsageErrorf(cmd, "Please provide OU")
 	}
+	if _, err := parseGroupBy(o.groupBy); err != nil {
+		return cmdutil.UsageErrorf(cmd, "%v", err)
+	}
 
 	o.output = o.GlobalOptions.Output
 
@@ -77,20 +82,25 @@ type getOptions struct {
 	csv       bool
 	sum       bool
 	output    string
+	groupBy   string
+
+	// breakdown accumulates the cost per group when groupBy is set
+	breakdown map[string]decimal.Decimal
 
 	genericclioptions.IOStreams
 	GlobalOptions *globalflags.GlobalOptions
 }
 
 type getCostResponse struct {
-	OuId    string          `json:"ouid" yaml:"ouid"`
-	OuName  string          `json:"ouname" yaml:"ouname"`
-	CostUSD decimal.Decimal `json:"costUSD" yaml:"costUSD"`
+	OuId      string          `json:"ouid" yaml:"ouid"`
+	OuName    string          `json:"ouname" yaml:"ouname"`
+	CostUSD   decimal.Decimal `json:"costUSD" yaml:"costUSD"`
+	Breakdown []groupCost     `json:"breakdown,omitempty" yaml:"breakdown,omitempty"`
 }
 
 func (f getCostResponse) String() string {
 
-	return fmt.Sprintf("  OuId: %s\n  OuName: %s\n  Cost: %s\n", f.OuId, f.OuName, f.CostUSD)
+	return fmt.Sprintf("  OuId: %s\n  OuName: %s\n  Cost: %s\n", f.OuId, f.OuName, f.CostUSD) + breakdownString(f.Breakdown)
 
 }
 
@@ -163,26 +173,15 @@ func getAccounts(OU *organizationTypes.OrganizationalUnit, awsClient awsprovider
 
 // Get the account IDs of all (not only immediate) accounts under OU
 func getAccountsRecursive(OU *organizationTypes.OrganizationalUnit, awsClient awsprovider.Client) ([]*string, error) {
-	var accountsIDs []*string
-
-	//Populate OUs
-	OUs, err := getOUs(OU, awsClient)
+	accountIDs, err := awsprovider.GetAccountsRecursive(awsClient, *OU.Id)
 	if err != nil {
 		return nil, err
 	}
-
-	//Loop through all child OUs to get account IDs from the accounts that comprise the OU
-	for _, childOU := range OUs {
-		accountsIDsOU, _ := getAccountsRecursive(childOU, awsClient)
-		accountsIDs = append(accountsIDs, accountsIDsOU...)
-	}
-	//Get account
-	accountsIDsOU, err := getAccounts(OU, awsClient)
-	if err != nil {
-		return nil, err
+	accounts := make([]*string, len(accountIDs))
+	for i := range accountIDs {
+		accounts[i] = &accountIDs[i]
 	}
-
-	return append(accountsIDs, accountsIDsOU...), nil
+	return accounts, nil
 }
 
 // Get immediate OUs (child nodes) directly under given OU
@@ -254,8 +253,12 @@ func (o *getOptions) getAccountCost(accountID *string, unit *string, awsClient a
 		"NetUnblendedCost",
 	}
 
-	//Get cost information for chosen account
-	costs, err := awsClient.GetCostAndUsage(&costexplorer.GetCostAndUsageInput{
+	groupBy, err := parseGroupBy(o.groupBy)
+	if err != nil {
+		return err
+	}
+
+	input := &costexplorer.GetCostAndUsageInput{
 		Filter: &costExplorerTypes.Expression{
 			Dimensions: &costExplorerTypes.DimensionValues{
 				Key:    "LINKED_ACCOUNT",
@@ -268,7 +271,14 @@ func (o *getOptions) getAccountCost(accountID *string, unit *string, awsClient a
 		},
 		Granularity: costExplorerTypes.Granularity(granularity),
 		Metrics:     metrics,
-	})
+	}
+	if groupBy != nil {
+		input.GroupBy = []costExplorerTypes.GroupDefinition{*groupBy}
+		return o.getAccountCostByGroup(input, unit, awsClient, cost)
+	}
+
+	//Get cost information for chosen account
+	costs, err := awsClient.GetCostAndUsage(input)
 	if err != nil {
 		return err
 	}
@@ -288,6 +298,49 @@ func (o *getOptions) getAccountCost(accountID *string, unit *string, awsClient a
 	return nil
 }
 
+// Get cost of given account grouped by input.GroupBy, adding the cost of every group to o.breakdown.
+// The results are paged when there are many groups.
+func (o *getOptions) getAccountCostByGroup(input *costexplorer.GetCostAndUsageInput, unit *string, awsClient awsprovider.Client, cost *decimal.Decimal) error {
+	if o.breakdown == nil {
+		o.breakdown = map[string]decimal.Decimal{}
+	}
+	tagKey := ""
+	if input.GroupBy[0].Type == costExplorerTypes.GroupDefinitionTypeTag {
+		tagKey = *input.GroupBy[0].Key
+	}
+
+	for {
+		costs, err := awsClient.GetCostAndUsage(input)
+		if err != nil {
+			return err
+		}
+
+		for _, result := range costs.ResultsByTime {
+			for _, group := range result.Groups {
+				metric, ok := group.Metrics["NetUnblendedCost"]
+				if !ok || metric.Amount == nil {
+					continue
+				}
+				groupCost, err := decimal.NewFromString(*metric.Amount)
+				if err != nil {
+					return err
+				}
+				name := groupName(group.Keys, tagKey)
+				o.breakdown[name] = o.breakdown[name].Add(groupCost)
+				*cost = cost.Add(groupCost)
+				if metric.Unit != nil {
+					*unit = *metric.Unit
+				}
+			}
+		}
+
+		if costs.NextPageToken == nil {
+			return nil
+		}
+		input.NextPageToken = costs.NextPageToken
+	}
+}
+
 // Get cost of given OU by aggregating costs of only immediate accounts under given OU
 func (o *getOptions) getOUCost(cost *decimal.Decimal, unit *string, OU *organizationTypes.OrganizationalUnit, awsClient awsprovider.Client) error {
 	//Populate accounts
@@ -369,15 +422,22 @@ func getTimePeriod(timePtr *string) (string, string) {
 func (o *getOptions) getCostOutput(cost decimal.Decimal, unit string, ops *getOptions, OU *organizationTypes.OrganizationalUnit) (string, error) {
 
 	resp := getCostResponse{
-		OuId:    *OU.Id,
-		OuName:  *OU.Name,
-		CostUSD: cost,
+		OuId:      *OU.Id,
+		OuName:    *OU.Name,
+		CostUSD:   cost,
+		Breakdown: sortedBreakdown(ops.breakdown),
 	}
 	var response string
 	if ops.csv { //If csv option specified, print result in csv
-		response = fmt.Sprintf("\n%s,%s,%s\n\n", *OU.Name, cost.StringFixed(2), unit)
-		return response, nil
-
+		if ops.groupBy == "" {
+			response = fmt.Sprintf("\n%s,%s,%s\n\n", *OU.Name, cost.StringFixed(2), unit)
+			return response, nil
+		}
+		var b strings.Builder
+		if err := writeCSV(&b, breakdownRows(cost, unit, resp.Breakdown, *OU.Name)...); err != nil {
+			return "", err
+		}
+		return "\n" + b.String() + "\n", nil
 	}
 	if ops.recursive {
 		response = "Cost of all accounts under OU:"
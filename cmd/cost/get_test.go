@@ -7,10 +7,14 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	types2 "github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
 	"github.com/aws/aws-sdk-go-v2/service/organizations/types"
 	"github.com/onsi/gomega"
+	"github.com/openshift/osdctl/pkg/provider/aws/mock"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
 )
 
 func TestGetTimePeriod(t *testing.T) {
@@ -165,3 +169,47 @@ func Test_getCostOutput(t *testing.T) {
 		g.Expect(result).To(gomega.BeEmpty())
 	})
 }
+
+func Test_getAccountCost_GroupBy(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockAWS := mock.NewMockClient(mockCtrl)
+
+	group := func(key, amount string) types2.Group {
+		return types2.Group{
+			Keys:    []string{key},
+			Metrics: map[string]types2.MetricValue{"NetUnblendedCost": {Amount: aws.String(amount), Unit: aws.String("USD")}},
+		}
+	}
+	first := mockAWS.EXPECT().GetCostAndUsage(gomock.Any()).DoAndReturn(
+		func(input *costexplorer.GetCostAndUsageInput) (*costexplorer.GetCostAndUsageOutput, error) {
+			require.Len(t, input.GroupBy, 1)
+			require.Equal(t, types2.GroupDefinitionTypeTag, input.GroupBy[0].Type)
+			require.Equal(t, "cluster", *input.GroupBy[0].Key)
+			return &costexplorer.GetCostAndUsageOutput{
+				ResultsByTime: []types2.ResultByTime{
+					{Groups: []types2.Group{group("cluster$a", "10.50"), group("cluster$", "1")}},
+				},
+				NextPageToken: aws.String("next"),
+			}, nil
+		})
+	mockAWS.EXPECT().GetCostAndUsage(gomock.Any()).Return(&costexplorer.GetCostAndUsageOutput{
+		ResultsByTime: []types2.ResultByTime{
+			{Groups: []types2.Group{group("cluster$a", "4.50"), group("cluster$b", "20")}},
+		},
+	}, nil).After(first)
+
+	o := &getOptions{start: "2025-01-01", end: "2025-02-01", groupBy: "tag:cluster"}
+	cost := decimal.Zero
+	var unit string
+	require.NoError(t, o.getAccountCost(aws.String("111111111111"), &unit, mockAWS, &cost))
+
+	require.Equal(t, "36", cost.String())
+	require.Equal(t, "USD", unit)
+	require.Equal(t, "    b: 20.00\n    a: 15.00\n    (no cluster tag): 1.00\n", breakdownString(sortedBreakdown(o.breakdown)))
+
+	ou := &types.OrganizationalUnit{Id: aws.String("ou-1234"), Name: aws.String("Dev-Ou")}
+	o.csv = true
+	result, err := o.getCostOutput(cost, unit, o, ou)
+	require.NoError(t, err)
+	require.Equal(t, "\nDev-Ou,36.00,USD,TOTAL\nDev-Ou,20.00,USD,b\nDev-Ou,15.00,USD,a\nDev-Ou,1.00,USD,(no cluster tag)\n\n", result)
+}
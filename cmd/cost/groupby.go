@@ -0,0 +1,109 @@
+package cost
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	costExplorerTypes "github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/shopspring/decimal"
+)
+
+// totalGroup is the group column of the total rows in CSV output with --group-by
+const totalGroup = "TOTAL"
+
+// groupByDimensions maps the --group-by values to Cost Explorer dimensions
+var groupByDimensions = map[string]string{
+	"service":    "SERVICE",
+	"usage-type": "USAGE_TYPE",
+	"region":     "REGION",
+}
+
+// groupCost is the cost of one group of a --group-by breakdown
+type groupCost struct {
+	Group string          `json:"group" yaml:"group"`
+	Cost  decimal.Decimal `json:"cost" yaml:"cost"`
+}
+
+// parseGroupBy returns the Cost Explorer group definition of a --group-by value, nil when empty
+func parseGroupBy(groupBy string) (*costExplorerTypes.GroupDefinition, error) {
+	if groupBy == "" {
+		return nil, nil
+	}
+	if dimension, ok := groupByDimensions[groupBy]; ok {
+		return &costExplorerTypes.GroupDefinition{
+			Type: costExplorerTypes.GroupDefinitionTypeDimension,
+			Key:  aws.String(dimension),
+		}, nil
+	}
+	if key, ok := strings.CutPrefix(groupBy, "tag:"); ok && key != "" {
+		return &costExplorerTypes.GroupDefinition{
+			Type: costExplorerTypes.GroupDefinitionTypeTag,
+			Key:  aws.String(key),
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid group-by '%s', expecting one of 'service', 'usage-type', 'region' or 'tag:<key>'", groupBy)
+}
+
+// groupName returns the name of a group. Cost Explorer returns tag groups as "<key>$<value>".
+func groupName(keys []string, tagKey string) string {
+	name := strings.Join(keys, "/")
+	if tagKey == "" {
+		return name
+	}
+	name = strings.TrimPrefix(name, tagKey+"$")
+	if name == "" {
+		return fmt.Sprintf("(no %s tag)", tagKey)
+	}
+	return name
+}
+
+// sortedBreakdown returns the groups of a breakdown by descending cost
+func sortedBreakdown(breakdown map[string]decimal.Decimal) []groupCost {
+	var groups []groupCost
+	for group, cost := range breakdown {
+		groups = append(groups, groupCost{Group: group, Cost: cost})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if !groups[i].Cost.Equal(groups[j].Cost) {
+			return groups[i].Cost.GreaterThan(groups[j].Cost)
+		}
+		return groups[i].Group < groups[j].Group
+	})
+	return groups
+}
+
+func breakdownString(groups []groupCost) string {
+	var b strings.Builder
+	for _, group := range groups {
+		fmt.Fprintf(&b, "    %s: %s\n", group.Group, group.Cost.StringFixed(2))
+	}
+	return b.String()
+}
+
+// writeCSV writes the rows as CSV, quoting the values containing a comma or a quote
+func writeCSV(out io.Writer, rows ...[]string) error {
+	csvWriter := csv.NewWriter(out)
+	for _, row := range rows {
+		if err := csvWriter.Write(row); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// breakdownRows returns the CSV rows of the total and the breakdown of a cost, each prefixed with the given columns
+func breakdownRows(cost decimal.Decimal, unit string, breakdown []groupCost, prefix ...string) [][]string {
+	row := func(cost decimal.Decimal, group string) []string {
+		return append(append([]string{}, prefix...), cost.StringFixed(2), unit, group)
+	}
+	rows := [][]string{row(cost, totalGroup)}
+	for _, group := range breakdown {
+		rows = append(rows, row(group.Cost, group.Group))
+	}
+	return rows
+}
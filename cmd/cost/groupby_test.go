@@ -0,0 +1,74 @@
+package cost
+
+import (
+	"strings"
+	"testing"
+
+	costExplorerTypes "github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGroupBy(t *testing.T) {
+	tests := []struct {
+		groupBy      string
+		expectedType costExplorerTypes.GroupDefinitionType
+		expectedKey  string
+		expectErr    bool
+	}{
+		{groupBy: "service", expectedType: costExplorerTypes.GroupDefinitionTypeDimension, expectedKey: "SERVICE"},
+		{groupBy: "usage-type", expectedType: costExplorerTypes.GroupDefinitionTypeDimension, expectedKey: "USAGE_TYPE"},
+		{groupBy: "region", expectedType: costExplorerTypes.GroupDefinitionTypeDimension, expectedKey: "REGION"},
+		{groupBy: "tag:cluster-id", expectedType: costExplorerTypes.GroupDefinitionTypeTag, expectedKey: "cluster-id"},
+		{groupBy: "tag:", expectErr: true},
+		{groupBy: "account", expectErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.groupBy, func(t *testing.T) {
+			definition, err := parseGroupBy(tc.groupBy)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedType, definition.Type)
+			assert.Equal(t, tc.expectedKey, *definition.Key)
+		})
+	}
+
+	definition, err := parseGroupBy("")
+	assert.NoError(t, err)
+	assert.Nil(t, definition)
+}
+
+func TestGroupName(t *testing.T) {
+	assert.Equal(t, "Amazon Elastic Compute Cloud - Compute", groupName([]string{"Amazon Elastic Compute Cloud - Compute"}, ""))
+	assert.Equal(t, "abc123", groupName([]string{"cluster-id$abc123"}, "cluster-id"))
+	assert.Equal(t, "(no cluster-id tag)", groupName([]string{"cluster-id$"}, "cluster-id"))
+}
+
+func TestSortedBreakdown(t *testing.T) {
+	groups := sortedBreakdown(map[string]decimal.Decimal{
+		"EC2":        decimal.NewFromInt(10),
+		"NatGateway": decimal.NewFromInt(30),
+		"EBS":        decimal.NewFromInt(10),
+	})
+	assert.Equal(t, []groupCost{
+		{Group: "NatGateway", Cost: decimal.NewFromInt(30)},
+		{Group: "EBS", Cost: decimal.NewFromInt(10)},
+		{Group: "EC2", Cost: decimal.NewFromInt(10)},
+	}, groups)
+	assert.Nil(t, sortedBreakdown(nil))
+}
+
+func TestBreakdownRows(t *testing.T) {
+	var b strings.Builder
+	err := writeCSV(&b, breakdownRows(decimal.NewFromInt(40), "USD", []groupCost{
+		{Group: `a "b", c`, Cost: decimal.NewFromInt(30)},
+		{Group: "EC2", Cost: decimal.NewFromInt(10)},
+	}, "ou-1")...)
+	assert.NoError(t, err)
+	assert.Equal(t, "ou-1,40.00,USD,TOTAL\nou-1,30.00,USD,\"a \"\"b\"\", c\"\nou-1,10.00,USD,EC2\n", b.String())
+}
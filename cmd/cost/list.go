@@ -3,6 +3,7 @@ package cost
 import (
 	"fmt"
 	"log"
+	"os"
 	"sort"
 
 	"github.com/aws/aws-sdk-go-v2/service/organizations/types"
@@ -34,6 +35,7 @@ func newCmdList(streams genericclioptions.IOStreams, globalOpts *globalflags.Glo
 	listCmd.Flags().BoolVar(&ops.csv, "csv", false, "output result as csv")
 	listCmd.Flags().StringVar(&ops.level, "level", "ou", "Cost cummulation level: possible options: ou, account")
 	listCmd.Flags().BoolVar(&ops.sum, "sum", true, "Hide sum rows")
+	listCmd.Flags().StringVar(&ops.groupBy, "group-by", "", "break the cost of every OU/account down by one of 'service', 'usage-type', 'region' or 'tag:<key>'")
 
 	if err := listCmd.MarkFlagRequired("ou"); err != nil {
 		log.Fatalln("OU flag:", err)
@@ -59,6 +61,9 @@ func (o *listOptions) checkArgs(cmd *cobra.Command, _ []string) error {
 	if len(o.ou) == 0 {
 		return cmdutil.UsageErrorf(cmd, "Please provide OU")
 	}
+	if _, err := parseGroupBy(o.groupBy); err != nil {
+		return cmdutil.UsageErrorf(cmd, "%v", err)
+	}
 
 	o.output = o.GlobalOptions.Output
 
@@ -67,28 +72,30 @@ func (o *listOptions) checkArgs(cmd *cobra.Command, _ []string) error {
 
 // Store flag options for get command
 type listOptions struct {
-	ou     []string
-	time   string
-	start  string
-	end    string
-	level  string
-	csv    bool
-	sum    bool
-	output string
+	ou      []string
+	time    string
+	start   string
+	end     string
+	level   string
+	csv     bool
+	sum     bool
+	output  string
+	groupBy string
 
 	genericclioptions.IOStreams
 	GlobalOptions *globalflags.GlobalOptions
 }
 
 type listCostResponse struct {
-	OuId    string          `json:"ouid" yaml:"ouid"`
-	OuName  string          `json:"ouname" yaml:"ouname"`
-	CostUSD decimal.Decimal `json:"costUSD" yaml:"costUSD"`
+	OuId      string          `json:"ouid" yaml:"ouid"`
+	OuName    string          `json:"ouname" yaml:"ouname"`
+	CostUSD   decimal.Decimal `json:"costUSD" yaml:"costUSD"`
+	Breakdown []groupCost     `json:"breakdown,omitempty" yaml:"breakdown,omitempty"`
 }
 
 func (f listCostResponse) String() string {
 
-	return fmt.Sprintf("  OuId: %s\n  OuName: %s\n  Cost: %s\n", f.OuId, f.OuName, f.CostUSD)
+	return fmt.Sprintf("  OuId: %s\n  OuName: %s\n  Cost: %s\n", f.OuId, f.OuName, f.CostUSD) + breakdownString(f.Breakdown)
 
 }
 
@@ -97,10 +104,11 @@ type listAccountCostResponse struct {
 	AccountId string          `json:"accountid" yaml:"accountid"`
 	Unit      string          `json:"unit" yaml:"unit"`
 	Cost      decimal.Decimal `json:"cost" yaml:"cost"`
+	Breakdown []groupCost     `json:"breakdown,omitempty" yaml:"breakdown,omitempty"`
 }
 
 func (f listAccountCostResponse) String() string {
-	return fmt.Sprintf("  AccountId: %s\n  Unit: %s\n  Cost: %s\n", f.AccountId, f.Unit, f.Cost)
+	return fmt.Sprintf("  AccountId: %s\n  Unit: %s\n  Cost: %s\n", f.AccountId, f.Unit, f.Cost) + breakdownString(f.Breakdown)
 
 }
 
@@ -144,15 +152,20 @@ func (o *listOptions) runList() error {
 }
 
 func printHeader(ops *listOptions) {
+	groupColumn := ""
+	if ops.groupBy != "" {
+		groupColumn = ",Group"
+	}
+
 	switch ops.level {
 
 	case "account":
 		if ops.csv {
-			fmt.Println("OU, AccountID,Cost,Unit")
+			fmt.Println("OU, AccountID,Cost,Unit" + groupColumn)
 		}
 	case "ou":
 		if ops.csv {
-			fmt.Printf("OU,Name,Cost,Unit\n")
+			fmt.Printf("OU,Name,Cost,Unit%s\n", groupColumn)
 			break
 		}
 	}
@@ -170,27 +183,29 @@ func listCostsUnderOU(OU *types.OrganizationalUnit, awsClient awsprovider.Client
 	var isChildNode bool
 
 	o := &getOptions{
-		time:  ops.time,
-		start: ops.start,
-		end:   ops.end,
-		ou:    *OU.Id,
+		time:    ops.time,
+		start:   ops.start,
+		end:     ops.end,
+		ou:      *OU.Id,
+		groupBy: ops.groupBy,
 	}
 	if err := o.getOUCostRecursive(&cost, &unit, OU, awsClient); err != nil {
 		return err
 	}
 
 	//Print cost of given OU
-	printCostList(cost, unit, OU, ops, isChildNode)
+	printCostListWithBreakdown(cost, unit, sortedBreakdown(o.breakdown), OU, ops, isChildNode)
 
-	//Print costs of child OUs under given OU
+	//Print costs of child OUs under given OU, each with the breakdown of all accounts below it
 	for _, childOU := range OUs {
 		cost = decimal.Zero
+		o.breakdown = nil
 		isChildNode = true
 
 		if err := o.getOUCostRecursive(&cost, &unit, childOU, awsClient); err != nil {
 			return err
 		}
-		printCostList(cost, unit, childOU, ops, isChildNode)
+		printCostListWithBreakdown(cost, unit, sortedBreakdown(o.breakdown), childOU, ops, isChildNode)
 	}
 
 	return nil
@@ -200,6 +215,7 @@ type AccountCost struct {
 	AccountID string
 	Cost      decimal.Decimal
 	Unit      string
+	Breakdown []groupCost
 }
 
 type OUCost struct {
@@ -216,10 +232,11 @@ func (o *OUCost) getCost(awsClient awsprovider.Client) error {
 	}
 
 	ops := &getOptions{
-		time:  o.options.time,
-		start: o.options.start,
-		end:   o.options.end,
-		ou:    *o.OU.Id,
+		time:    o.options.time,
+		start:   o.options.start,
+		end:     o.options.end,
+		ou:      *o.OU.Id,
+		groupBy: o.options.groupBy,
 	}
 
 	for _, account := range accounts {
@@ -228,10 +245,12 @@ func (o *OUCost) getCost(awsClient awsprovider.Client) error {
 			Unit:      "",
 			Cost:      decimal.Zero,
 		}
+		ops.breakdown = nil
 		err = ops.getAccountCost(account, &accCost.Unit, awsClient, &accCost.Cost)
 		if err != nil {
 			return err
 		}
+		accCost.Breakdown = sortedBreakdown(ops.breakdown)
 		o.Costs = append(o.Costs, accCost)
 	}
 
@@ -271,9 +290,17 @@ func (o *OUCost) printCostPerAccount(awsClient awsprovider.Client) {
 			AccountId: accountCost.AccountID,
 			Cost:      accountCost.Cost,
 			Unit:      accountCost.Unit,
+			Breakdown: accountCost.Breakdown,
 		}
 		if o.options.csv {
-			fmt.Printf("%s,%s,%s,%s\n", *o.OU.Id, accountCost.AccountID, accountCost.Cost.StringFixed(2), accountCost.Unit)
+			if o.options.groupBy == "" {
+				fmt.Printf("%s,%s,%s,%s\n", *o.OU.Id, accountCost.AccountID, accountCost.Cost.StringFixed(2), accountCost.Unit)
+				continue
+			}
+			if err := writeCSV(os.Stdout, breakdownRows(accountCost.Cost, accountCost.Unit, accountCost.Breakdown, *o.OU.Id, accountCost.AccountID)...); err != nil {
+				fmt.Println("Error while printing response: ", err.Error())
+				return
+			}
 			continue
 		}
 		err := outputflag.PrintResponse(o.options.output, resp)
@@ -288,7 +315,11 @@ func (o *OUCost) printCostPerAccount(awsClient awsprovider.Client) {
 	}
 	if o.options.csv {
 		if o.options.sum {
-			fmt.Printf("%s,%s,%s,%s\n", *o.OU.Id, "SUM", sum.StringFixed(2), unit)
+			if o.options.groupBy != "" {
+				fmt.Printf("%s,%s,%s,%s,%s\n", *o.OU.Id, "SUM", sum.StringFixed(2), unit, totalGroup)
+			} else {
+				fmt.Printf("%s,%s,%s,%s\n", *o.OU.Id, "SUM", sum.StringFixed(2), unit)
+			}
 		}
 		return
 	}
@@ -296,11 +327,17 @@ func (o *OUCost) printCostPerAccount(awsClient awsprovider.Client) {
 }
 
 func printCostList(cost decimal.Decimal, unit string, OU *types.OrganizationalUnit, ops *listOptions, isChildNode bool) {
+	printCostListWithBreakdown(cost, unit, nil, OU, ops, isChildNode)
+}
+
+// printCostListWithBreakdown prints the cost of an OU followed by its --group-by breakdown
+func printCostListWithBreakdown(cost decimal.Decimal, unit string, breakdown []groupCost, OU *types.OrganizationalUnit, ops *listOptions, isChildNode bool) {
 
 	resp := listCostResponse{
-		OuId:    *OU.Id,
-		OuName:  *OU.Name,
-		CostUSD: cost,
+		OuId:      *OU.Id,
+		OuName:    *OU.Name,
+		CostUSD:   cost,
+		Breakdown: breakdown,
 	}
 
 	if !isChildNode {
@@ -308,7 +345,13 @@ func printCostList(cost decimal.Decimal, unit string, OU *types.OrganizationalUn
 	}
 
 	if ops.csv {
-		fmt.Printf("%v,%v,%s,%s\n", *OU.Id, *OU.Name, cost.StringFixed(2), unit)
+		if ops.groupBy == "" {
+			fmt.Printf("%v,%v,%s,%s\n", *OU.Id, *OU.Name, cost.StringFixed(2), unit)
+			return
+		}
+		if err := writeCSV(os.Stdout, breakdownRows(cost, unit, breakdown, *OU.Id, *OU.Name)...); err != nil {
+			fmt.Println("Error while printing response: ", err.Error())
+		}
 		return
 	}
 
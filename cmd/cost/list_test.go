@@ -314,3 +314,19 @@ func TestGetCost(t *testing.T) {
 		g.Expect(ouCost.Costs[0].Cost.InexactFloat64()).To(gomega.Equal(50.0))
 	})
 }
+
+func TestPrintCostListWithBreakdown_CSV(t *testing.T) {
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	printCostListWithBreakdown(decimal.NewFromInt(30), "USD", []groupCost{
+		{Group: "NatGateway", Cost: decimal.NewFromInt(20)},
+		{Group: "EBS, snapshots", Cost: decimal.NewFromInt(10)},
+	}, &types.OrganizationalUnit{Id: aws.String("ou-1"), Name: aws.String("Leak")}, &listOptions{csv: true, groupBy: "usage-type"}, true)
+	_ = w.Close()
+	os.Stdout = oldStdout
+	var buf bytes.Buffer
+	_, _ = buf.ReadFrom(r)
+
+	assert.Equal(t, "ou-1,Leak,30.00,USD,TOTAL\nou-1,Leak,20.00,USD,NatGateway\nou-1,Leak,10.00,USD,\"EBS, snapshots\"\n", buf.String())
+}
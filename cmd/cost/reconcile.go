@@ -2,6 +2,7 @@ package cost
 
 import (
 	"fmt"
+	"io"
 	"log"
 
 	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
@@ -18,15 +19,46 @@ func newCmdReconcile(streams genericclioptions.IOStreams) *cobra.Command {
 	reconcileCmd := &cobra.Command{
 		Use:   "reconcile",
 		Short: "Checks if there's a cost category for every OU. If an OU is missing a cost category, creates the cost category",
+		Long: `Checks if there's a cost category for every OU under --ou. If an OU is missing a cost category, creates the cost category.
+
+With --dry-run nothing is created. Instead the diff between the existing cost category definitions and the
+desired ones is printed: one category per OU under --ou, or the categories of the YAML file given with --spec.
+Use 'osdctl cost apply' to apply a spec.`,
+		Example: `  # Show which cost categories are missing or outdated for the OUs under an OU
+  osdctl cost reconcile --ou ou-abcd-12345678 --dry-run
+
+  # Show the diff between a spec and the existing cost categories, including the ones to delete
+  osdctl cost reconcile --spec cost-categories.yaml --dry-run --prune`,
 		Run: func(cmd *cobra.Command, args []string) {
+			OUid, err := cmd.Flags().GetString("ou")
+			cmdutil.CheckErr(err)
+			specFile, err := cmd.Flags().GetString("spec")
+			cmdutil.CheckErr(err)
+			dryRun, err := cmd.Flags().GetBool("dry-run")
+			cmdutil.CheckErr(err)
+			prune, err := cmd.Flags().GetBool("prune")
+			cmdutil.CheckErr(err)
+
+			if (OUid == "") == (specFile == "") {
+				cmdutil.CheckErr(cmdutil.UsageErrorf(cmd, "Please provide either --ou or --spec"))
+			}
+			if specFile != "" && !dryRun {
+				cmdutil.CheckErr(cmdutil.UsageErrorf(cmd, "--spec is only used with --dry-run, run 'osdctl cost apply --spec %s' to apply it", specFile))
+			}
 
 			awsClient, err := opsCost.initAWSClients()
 			cmdutil.CheckErr(err)
 
-			//Get flags
-			OUid, err := cmd.Flags().GetString("ou")
-			if err != nil {
-				log.Fatalln("OU flag:", err)
+			if dryRun {
+				var spec *costCategorySpecFile
+				if specFile != "" {
+					spec, err = loadCostCategorySpec(specFile)
+				} else {
+					spec, err = specForOU(getOU(awsClient, OUid), awsClient)
+				}
+				cmdutil.CheckErr(err)
+				cmdutil.CheckErr(diffCostCategories(streams.Out, spec, awsClient, prune))
+				return
 			}
 
 			//Get information regarding Organizational Unit
@@ -38,13 +70,35 @@ func newCmdReconcile(streams genericclioptions.IOStreams) *cobra.Command {
 		},
 	}
 	reconcileCmd.Flags().String("ou", "", "get OU ID")
-	if err := reconcileCmd.MarkFlagRequired("ou"); err != nil {
-		log.Fatalln("OU flag:", err)
-	}
+	reconcileCmd.Flags().String("spec", "", "YAML file with the desired cost categories, only used with --dry-run")
+	reconcileCmd.Flags().Bool("dry-run", false, "print the diff against the existing cost categories instead of creating them")
+	reconcileCmd.Flags().Bool("prune", false, "with --spec, also show the existing cost categories missing in the spec as deleted")
 
 	return reconcileCmd
 }
 
+// specForOU returns the cost categories 'reconcile' creates for the OUs under OU
+func specForOU(OU *organizationTypes.OrganizationalUnit, awsClient awsprovider.Client) (*costCategorySpecFile, error) {
+	OUs, err := getOUsRecursive(OU, awsClient)
+	if err != nil {
+		return nil, err
+	}
+	return specFromOUs(OUs), nil
+}
+
+// diffCostCategories prints the changes needed to get from the existing cost categories to the spec
+func diffCostCategories(out io.Writer, spec *costCategorySpecFile, awsClient awsprovider.Client, prune bool) error {
+	if err := spec.resolve(awsClient); err != nil {
+		return err
+	}
+	changes, err := planCostCategories(spec, awsClient, prune)
+	if err != nil {
+		return err
+	}
+	printCostCategoryDiff(out, changes)
+	return nil
+}
+
 // Checks if there's a cost category for every OU. If not, creates the missing cost category. This should be ran every 24 hours.
 func reconcileCostCategories(OU *organizationTypes.OrganizationalUnit, awsClient awsprovider.Client) error {
 	costCategoryCreated := false
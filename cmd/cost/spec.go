@@ -0,0 +1,499 @@
+package cost
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	costExplorerTypes "github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	organizationTypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	awsprovider "github.com/openshift/osdctl/pkg/provider/aws"
+	"sigs.k8s.io/yaml"
+)
+
+const costCategoryRuleVersion = "CostCategoryExpression.v1"
+
+// costCategorySpecFile is the declarative spec of the desired cost categories
+type costCategorySpecFile struct {
+	CostCategories []costCategorySpec `json:"costCategories"`
+}
+
+// costCategorySpec is a cost category definition and its rules
+type costCategorySpec struct {
+	Name         string     `json:"name"`
+	DefaultValue string     `json:"defaultValue,omitempty"`
+	Rules        []ruleSpec `json:"rules"`
+}
+
+// ruleSpec assigns Value to the cost of the accounts, the accounts of the OUs (recursively) or the tagged resources.
+// Rules which can't be expressed this way are kept as the JSON of the Cost Explorer expression in Expression.
+type ruleSpec struct {
+	Value      string   `json:"value"`
+	Accounts   []string `json:"accounts,omitempty"`
+	OUs        []string `json:"ous,omitempty"`
+	Tag        *tagRule `json:"tag,omitempty"`
+	Expression string   `json:"expression,omitempty"`
+}
+
+type tagRule struct {
+	Key    string   `json:"key"`
+	Values []string `json:"values"`
+}
+
+// loadCostCategorySpec reads and validates a spec file
+func loadCostCategorySpec(path string) (*costCategorySpecFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec: %v", err)
+	}
+
+	spec := &costCategorySpecFile{}
+	if err := yaml.UnmarshalStrict(data, spec); err != nil {
+		return nil, fmt.Errorf("failed to parse spec %s: %v", path, err)
+	}
+
+	names := map[string]bool{}
+	for _, category := range spec.CostCategories {
+		if category.Name == "" {
+			return nil, fmt.Errorf("invalid spec %s: cost category without name", path)
+		}
+		if names[category.Name] {
+			return nil, fmt.Errorf("invalid spec %s: cost category %s is defined twice", path, category.Name)
+		}
+		names[category.Name] = true
+		if len(category.Rules) == 0 {
+			return nil, fmt.Errorf("invalid spec %s: cost category %s has no rules", path, category.Name)
+		}
+		for _, rule := range category.Rules {
+			if err := rule.validate(); err != nil {
+				return nil, fmt.Errorf("invalid spec %s: cost category %s: %v", path, category.Name, err)
+			}
+		}
+	}
+
+	return spec, nil
+}
+
+func (r ruleSpec) validate() error {
+	if r.Value == "" {
+		return fmt.Errorf("rule without value")
+	}
+	kinds := 0
+	if len(r.Accounts) > 0 || len(r.OUs) > 0 {
+		kinds++
+	}
+	if r.Tag != nil {
+		kinds++
+		if r.Tag.Key == "" || len(r.Tag.Values) == 0 {
+			return fmt.Errorf("tag rule %s needs a key and values", r.Value)
+		}
+	}
+	if r.Expression != "" {
+		kinds++
+	}
+	if kinds != 1 {
+		return fmt.Errorf("rule %s needs exactly one of accounts/ous, tag or expression", r.Value)
+	}
+	return nil
+}
+
+// specFromOUs returns the cost categories created by 'cost create' and 'cost reconcile' for the OUs:
+// one category per OU named after its ID, with a rule matching all accounts under the OU
+func specFromOUs(OUs []*organizationTypes.OrganizationalUnit) *costCategorySpecFile {
+	spec := &costCategorySpecFile{}
+	for _, OU := range OUs {
+		spec.CostCategories = append(spec.CostCategories, costCategorySpec{
+			Name:  *OU.Id,
+			Rules: []ruleSpec{{Value: *OU.Id, OUs: []string{*OU.Id}}},
+		})
+	}
+	return spec
+}
+
+// resolve replaces the OUs of the rules with the accounts below them, sorting and deduplicating accounts
+func (s *costCategorySpecFile) resolve(awsClient awsprovider.Client) error {
+	cache := map[string][]string{}
+	for i := range s.CostCategories {
+		for j := range s.CostCategories[i].Rules {
+			rule := &s.CostCategories[i].Rules[j]
+			for _, ou := range rule.OUs {
+				if _, ok := cache[ou]; !ok {
+					accounts, err := awsprovider.GetAccountsRecursive(awsClient, ou)
+					if err != nil {
+						return fmt.Errorf("failed to get accounts of OU %s: %v", ou, err)
+					}
+					cache[ou] = accounts
+				}
+				rule.Accounts = append(rule.Accounts, cache[ou]...)
+			}
+			rule.OUs = nil
+			rule.Accounts = uniqueStrings(rule.Accounts)
+			if rule.Tag != nil {
+				rule.Tag.Values = uniqueStrings(rule.Tag.Values)
+			}
+			// Expressions are compared in the form they're read back from Cost Explorer
+			if rule.Expression != "" {
+				expression, err := rule.expression()
+				if err != nil {
+					return err
+				}
+				*rule = ruleFromAWS(costExplorerTypes.CostCategoryRule{Rule: expression, Value: &rule.Value})
+			}
+		}
+	}
+	return nil
+}
+
+func uniqueStrings(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	seen := map[string]bool{}
+	var unique []string
+	for _, v := range values {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+// expression returns the Cost Explorer expression of a resolved rule
+func (r ruleSpec) expression() (*costExplorerTypes.Expression, error) {
+	switch {
+	case r.Tag != nil:
+		return &costExplorerTypes.Expression{
+			Tags: &costExplorerTypes.TagValues{Key: &r.Tag.Key, Values: r.Tag.Values},
+		}, nil
+	case r.Expression != "":
+		expression := &costExplorerTypes.Expression{}
+		if err := json.Unmarshal([]byte(r.Expression), expression); err != nil {
+			return nil, fmt.Errorf("invalid expression of rule %s: %v", r.Value, err)
+		}
+		return expression, nil
+	default:
+		return &costExplorerTypes.Expression{
+			Dimensions: &costExplorerTypes.DimensionValues{Key: "LINKED_ACCOUNT", Values: r.Accounts},
+		}, nil
+	}
+}
+
+// ruleFromAWS converts a Cost Explorer rule to a ruleSpec comparable to a resolved ruleSpec
+func ruleFromAWS(rule costExplorerTypes.CostCategoryRule) ruleSpec {
+	spec := ruleSpec{}
+	if rule.Value != nil {
+		spec.Value = *rule.Value
+	}
+	expression := rule.Rule
+	switch {
+	case expression == nil:
+	case expression.Dimensions != nil && expression.Dimensions.Key == "LINKED_ACCOUNT" && len(expression.Dimensions.MatchOptions) == 0 &&
+		expression.Tags == nil && expression.And == nil && expression.Or == nil && expression.Not == nil && expression.CostCategories == nil:
+		spec.Accounts = uniqueStrings(expression.Dimensions.Values)
+	case expression.Tags != nil && expression.Tags.Key != nil && len(expression.Tags.MatchOptions) == 0 &&
+		expression.Dimensions == nil && expression.And == nil && expression.Or == nil && expression.Not == nil && expression.CostCategories == nil:
+		spec.Tag = &tagRule{Key: *expression.Tags.Key, Values: uniqueStrings(expression.Tags.Values)}
+	default:
+		spec.Expression = expressionJSON(expression)
+	}
+	return spec
+}
+
+// expressionJSON returns the JSON of an expression without the fields which aren't set
+func expressionJSON(expression *costExplorerTypes.Expression) string {
+	data, _ := json.Marshal(expression)
+	var value interface{}
+	_ = json.Unmarshal(data, &value)
+	data, _ = json.Marshal(dropNulls(value))
+	return string(data)
+}
+
+func dropNulls(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if field == nil {
+				delete(v, key)
+				continue
+			}
+			v[key] = dropNulls(field)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = dropNulls(v[i])
+		}
+	}
+	return value
+}
+
+func (r ruleSpec) String() string {
+	switch {
+	case r.Tag != nil:
+		return fmt.Sprintf("%s: tag %s in [%s]", r.Value, r.Tag.Key, strings.Join(r.Tag.Values, ", "))
+	case r.Expression != "":
+		return fmt.Sprintf("%s: expression %s", r.Value, r.Expression)
+	default:
+		return fmt.Sprintf("%s: accounts [%s]", r.Value, strings.Join(r.Accounts, ", "))
+	}
+}
+
+const (
+	actionCreate    = "create"
+	actionUpdate    = "update"
+	actionDelete    = "delete"
+	actionUnchanged = "unchanged"
+)
+
+// costCategoryChange is the change needed to get a cost category from Current to Desired
+type costCategoryChange struct {
+	Action  string
+	Name    string
+	Arn     string
+	Current *costCategorySpec
+	Desired *costCategorySpec
+}
+
+// listCostCategories returns the ARN of every existing cost category by name
+func listCostCategories(awsClient awsprovider.Client) (map[string]string, error) {
+	arns := map[string]string{}
+	var nextToken *string
+	for {
+		existing, err := awsClient.ListCostCategoryDefinitions(&costexplorer.ListCostCategoryDefinitionsInput{
+			NextToken: nextToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, reference := range existing.CostCategoryReferences {
+			if reference.Name != nil && reference.CostCategoryArn != nil {
+				arns[*reference.Name] = *reference.CostCategoryArn
+			}
+		}
+		if existing.NextToken == nil {
+			return arns, nil
+		}
+		nextToken = existing.NextToken
+	}
+}
+
+// describeCostCategory returns the current definition of a cost category in spec form
+func describeCostCategory(awsClient awsprovider.Client, arn string) (*costCategorySpec, error) {
+	output, err := awsClient.DescribeCostCategoryDefinition(&costexplorer.DescribeCostCategoryDefinitionInput{
+		CostCategoryArn: &arn,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe cost category %s: %v", arn, err)
+	}
+	if output.CostCategory == nil {
+		return nil, fmt.Errorf("cost category %s not found", arn)
+	}
+
+	current := &costCategorySpec{}
+	if output.CostCategory.Name != nil {
+		current.Name = *output.CostCategory.Name
+	}
+	if output.CostCategory.DefaultValue != nil {
+		current.DefaultValue = *output.CostCategory.DefaultValue
+	}
+	for _, rule := range output.CostCategory.Rules {
+		current.Rules = append(current.Rules, ruleFromAWS(rule))
+	}
+	return current, nil
+}
+
+// planCostCategories compares the resolved spec with the existing cost categories. Existing categories
+// missing in the spec are only deleted with prune, otherwise they are not part of the plan.
+func planCostCategories(spec *costCategorySpecFile, awsClient awsprovider.Client, prune bool) ([]costCategoryChange, error) {
+	arns, err := listCostCategories(awsClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cost categories: %v", err)
+	}
+
+	var changes []costCategoryChange
+	inSpec := map[string]bool{}
+	for i := range spec.CostCategories {
+		desired := &spec.CostCategories[i]
+		inSpec[desired.Name] = true
+
+		arn, exists := arns[desired.Name]
+		if !exists {
+			changes = append(changes, costCategoryChange{Action: actionCreate, Name: desired.Name, Desired: desired})
+			continue
+		}
+
+		current, err := describeCostCategory(awsClient, arn)
+		if err != nil {
+			return nil, err
+		}
+		action := actionUpdate
+		if specEqual(current, desired) {
+			action = actionUnchanged
+		}
+		changes = append(changes, costCategoryChange{Action: action, Name: desired.Name, Arn: arn, Current: current, Desired: desired})
+	}
+
+	if prune {
+		var names []string
+		for name := range arns {
+			if !inSpec[name] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			current, err := describeCostCategory(awsClient, arns[name])
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, costCategoryChange{Action: actionDelete, Name: name, Arn: arns[name], Current: current})
+		}
+	}
+
+	return changes, nil
+}
+
+func specEqual(a, b *costCategorySpec) bool {
+	if a.DefaultValue != b.DefaultValue || len(a.Rules) != len(b.Rules) {
+		return false
+	}
+	for i := range a.Rules {
+		if a.Rules[i].String() != b.Rules[i].String() {
+			return false
+		}
+	}
+	return true
+}
+
+// printCostCategoryDiff prints the plan as a diff and returns the number of changes
+func printCostCategoryDiff(out io.Writer, changes []costCategoryChange) int {
+	pending := 0
+	for _, change := range changes {
+		switch change.Action {
+		case actionUnchanged:
+			fmt.Fprintf(out, "  %s (unchanged)\n", change.Name)
+			continue
+		case actionCreate:
+			fmt.Fprintf(out, "+ %s\n", change.Name)
+		case actionDelete:
+			fmt.Fprintf(out, "- %s\n", change.Name)
+		case actionUpdate:
+			fmt.Fprintf(out, "~ %s\n", change.Name)
+		}
+		pending++
+
+		var current, desired costCategorySpec
+		if change.Current != nil {
+			current = *change.Current
+		}
+		if change.Desired != nil {
+			desired = *change.Desired
+		}
+		if current.DefaultValue != desired.DefaultValue {
+			if current.DefaultValue != "" {
+				fmt.Fprintf(out, "    - default: %s\n", current.DefaultValue)
+			}
+			if desired.DefaultValue != "" {
+				fmt.Fprintf(out, "    + default: %s\n", desired.DefaultValue)
+			}
+		}
+		// Rules are evaluated in order, so they are compared by position
+		for i := 0; i < len(current.Rules) || i < len(desired.Rules); i++ {
+			var before, after string
+			if i < len(current.Rules) {
+				before = current.Rules[i].String()
+			}
+			if i < len(desired.Rules) {
+				after = desired.Rules[i].String()
+			}
+			if before == after {
+				fmt.Fprintf(out, "      %s\n", before)
+				continue
+			}
+			if before != "" {
+				fmt.Fprintf(out, "    - %s\n", before)
+			}
+			if after != "" {
+				fmt.Fprintf(out, "    + %s\n", after)
+			}
+		}
+	}
+
+	if pending == 0 {
+		fmt.Fprintln(out, "Cost categories are up-to-date.")
+	}
+	return pending
+}
+
+func awsRules(spec *costCategorySpec) ([]costExplorerTypes.CostCategoryRule, error) {
+	var rules []costExplorerTypes.CostCategoryRule
+	for _, rule := range spec.Rules {
+		expression, err := rule.expression()
+		if err != nil {
+			return nil, err
+		}
+		value := rule.Value
+		rules = append(rules, costExplorerTypes.CostCategoryRule{Rule: expression, Value: &value})
+	}
+	return rules, nil
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// applyCostCategories creates, updates and deletes the cost categories of the plan.
+// Unchanged categories are skipped, so applying the same spec twice is a no-op.
+func applyCostCategories(out io.Writer, changes []costCategoryChange, awsClient awsprovider.Client) error {
+	for _, change := range changes {
+		switch change.Action {
+		case actionCreate:
+			rules, err := awsRules(change.Desired)
+			if err != nil {
+				return err
+			}
+			_, err = awsClient.CreateCostCategoryDefinition(&costexplorer.CreateCostCategoryDefinitionInput{
+				Name:         &change.Desired.Name,
+				RuleVersion:  costCategoryRuleVersion,
+				Rules:        rules,
+				DefaultValue: optionalString(change.Desired.DefaultValue),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create cost category %s: %v", change.Name, err)
+			}
+			fmt.Fprintf(out, "Created cost category %s\n", change.Name)
+		case actionUpdate:
+			rules, err := awsRules(change.Desired)
+			if err != nil {
+				return err
+			}
+			_, err = awsClient.UpdateCostCategoryDefinition(&costexplorer.UpdateCostCategoryDefinitionInput{
+				CostCategoryArn: &change.Arn,
+				RuleVersion:     costCategoryRuleVersion,
+				Rules:           rules,
+				DefaultValue:    optionalString(change.Desired.DefaultValue),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to update cost category %s: %v", change.Name, err)
+			}
+			fmt.Fprintf(out, "Updated cost category %s\n", change.Name)
+		case actionDelete:
+			_, err := awsClient.DeleteCostCategoryDefinition(&costexplorer.DeleteCostCategoryDefinitionInput{
+				CostCategoryArn: &change.Arn,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to delete cost category %s: %v", change.Name, err)
+			}
+			fmt.Fprintf(out, "Deleted cost category %s\n", change.Name)
+		}
+	}
+	return nil
+}
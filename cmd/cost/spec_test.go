@@ -0,0 +1,296 @@
+package cost
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	costExplorerTypes "github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	organizationTypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/openshift/osdctl/pkg/provider/aws/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+const testSpec = `costCategories:
+- name: ou-1
+  rules:
+  - value: ou-1
+    ous: [ou-1]
+- name: teams
+  defaultValue: other
+  rules:
+  - value: shared
+    accounts: ["333", "111", "333"]
+  - value: cluster-a
+    tag:
+      key: cluster
+      values: [a]
+  - value: not-prod
+    expression: '{"Not": {"Dimensions": {"Key": "LINKED_ACCOUNT", "Values": ["222"]}}}'
+`
+
+func writeSpec(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "spec.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadCostCategorySpec(t *testing.T) {
+	spec, err := loadCostCategorySpec(writeSpec(t, testSpec))
+	require.NoError(t, err)
+	require.Len(t, spec.CostCategories, 2)
+	assert.Equal(t, "other", spec.CostCategories[1].DefaultValue)
+	assert.Equal(t, "cluster", spec.CostCategories[1].Rules[1].Tag.Key)
+
+	testCases := map[string]string{
+		"unknown field":  "costCategories:\n- name: a\n  rulez: []\n",
+		"no name":        "costCategories:\n- rules:\n  - value: a\n    accounts: ['1']\n",
+		"duplicate name": "costCategories:\n- name: a\n  rules:\n  - value: a\n    accounts: ['1']\n- name: a\n  rules:\n  - value: a\n    accounts: ['1']\n",
+		"no rules":       "costCategories:\n- name: a\n",
+		"no value":       "costCategories:\n- name: a\n  rules:\n  - accounts: ['1']\n",
+		"two kinds":      "costCategories:\n- name: a\n  rules:\n  - value: a\n    accounts: ['1']\n    tag: {key: k, values: [v]}\n",
+		"empty tag":      "costCategories:\n- name: a\n  rules:\n  - value: a\n    tag: {key: k}\n",
+	}
+	for name, content := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := loadCostCategorySpec(writeSpec(t, content))
+			assert.Error(t, err)
+		})
+	}
+}
+
+// expectOU returns the accounts of an OU without child OUs
+func expectOU(r *mock.MockClientMockRecorder, ou string, accounts ...string) {
+	r.ListOrganizationalUnitsForParent(&organizations.ListOrganizationalUnitsForParentInput{ParentId: aws.String(ou)}).
+		Return(&organizations.ListOrganizationalUnitsForParentOutput{}, nil)
+	output := &organizations.ListAccountsForParentOutput{}
+	for _, account := range accounts {
+		output.Accounts = append(output.Accounts, organizationTypes.Account{Id: aws.String(account)})
+	}
+	r.ListAccountsForParent(&organizations.ListAccountsForParentInput{ParentId: aws.String(ou)}).Return(output, nil)
+}
+
+func loadTestSpec(t *testing.T, mockAWS *mock.MockClient) *costCategorySpecFile {
+	t.Helper()
+	expectOU(mockAWS.EXPECT(), "ou-1", "222", "111")
+	spec, err := loadCostCategorySpec(writeSpec(t, testSpec))
+	require.NoError(t, err)
+	require.NoError(t, spec.resolve(mockAWS))
+	return spec
+}
+
+func TestCostCategorySpecResolve(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockAWS := mock.NewMockClient(mockCtrl)
+	spec := loadTestSpec(t, mockAWS)
+
+	assert.Equal(t, ruleSpec{Value: "ou-1", Accounts: []string{"111", "222"}}, spec.CostCategories[0].Rules[0])
+	assert.Equal(t, []string{"111", "333"}, spec.CostCategories[1].Rules[0].Accounts)
+	assert.Equal(t, `not-prod: expression {"Not":{"Dimensions":{"Key":"LINKED_ACCOUNT","Values":["222"]}}}`, spec.CostCategories[1].Rules[2].String())
+}
+
+func TestRuleFromAWS(t *testing.T) {
+	rule := ruleFromAWS(costExplorerTypes.CostCategoryRule{
+		Value: aws.String("a"),
+		Rule: &costExplorerTypes.Expression{
+			Dimensions: &costExplorerTypes.DimensionValues{Key: "LINKED_ACCOUNT", Values: []string{"2", "1"}},
+		},
+	})
+	assert.Equal(t, ruleSpec{Value: "a", Accounts: []string{"1", "2"}}, rule)
+
+	rule = ruleFromAWS(costExplorerTypes.CostCategoryRule{
+		Value: aws.String("b"),
+		Rule:  &costExplorerTypes.Expression{Tags: &costExplorerTypes.TagValues{Key: aws.String("k"), Values: []string{"v"}}},
+	})
+	assert.Equal(t, ruleSpec{Value: "b", Tag: &tagRule{Key: "k", Values: []string{"v"}}}, rule)
+
+	rule = ruleFromAWS(costExplorerTypes.CostCategoryRule{
+		Value: aws.String("c"),
+		Rule: &costExplorerTypes.Expression{
+			Dimensions: &costExplorerTypes.DimensionValues{Key: "SERVICE", Values: []string{"AmazonEC2"}},
+		},
+	})
+	assert.Equal(t, `{"Dimensions":{"Key":"SERVICE","Values":["AmazonEC2"]}}`, rule.Expression)
+}
+
+// existingCategories mocks the cost categories ou-1 (matching the test spec), teams (with an outdated
+// account rule and default) and old (not in the spec)
+func existingCategories(r *mock.MockClientMockRecorder, teamsAccounts []string) {
+	r.ListCostCategoryDefinitions(&costexplorer.ListCostCategoryDefinitionsInput{}).Return(&costexplorer.ListCostCategoryDefinitionsOutput{
+		CostCategoryReferences: []costExplorerTypes.CostCategoryReference{
+			{Name: aws.String("ou-1"), CostCategoryArn: aws.String("arn:ou-1")},
+			{Name: aws.String("old"), CostCategoryArn: aws.String("arn:old")},
+		},
+		NextToken: aws.String("page-2"),
+	}, nil).AnyTimes()
+	r.ListCostCategoryDefinitions(&costexplorer.ListCostCategoryDefinitionsInput{NextToken: aws.String("page-2")}).
+		Return(&costexplorer.ListCostCategoryDefinitionsOutput{
+			CostCategoryReferences: []costExplorerTypes.CostCategoryReference{
+				{Name: aws.String("teams"), CostCategoryArn: aws.String("arn:teams")},
+			},
+		}, nil).AnyTimes()
+
+	describe := func(arn, name string, defaultValue *string, rules ...costExplorerTypes.CostCategoryRule) {
+		r.DescribeCostCategoryDefinition(&costexplorer.DescribeCostCategoryDefinitionInput{CostCategoryArn: aws.String(arn)}).
+			Return(&costexplorer.DescribeCostCategoryDefinitionOutput{
+				CostCategory: &costExplorerTypes.CostCategory{Name: aws.String(name), DefaultValue: defaultValue, Rules: rules},
+			}, nil).AnyTimes()
+	}
+	accounts := func(value string, ids ...string) costExplorerTypes.CostCategoryRule {
+		return costExplorerTypes.CostCategoryRule{
+			Value: aws.String(value),
+			Rule:  &costExplorerTypes.Expression{Dimensions: &costExplorerTypes.DimensionValues{Key: "LINKED_ACCOUNT", Values: ids}},
+		}
+	}
+	describe("arn:ou-1", "ou-1", nil, accounts("ou-1", "222", "111"))
+	describe("arn:old", "old", nil, accounts("old", "444"))
+	describe("arn:teams", "teams", aws.String("other"),
+		accounts("shared", teamsAccounts...),
+		costExplorerTypes.CostCategoryRule{
+			Value: aws.String("cluster-a"),
+			Rule:  &costExplorerTypes.Expression{Tags: &costExplorerTypes.TagValues{Key: aws.String("cluster"), Values: []string{"a"}}},
+		},
+		costExplorerTypes.CostCategoryRule{
+			Value: aws.String("not-prod"),
+			Rule: &costExplorerTypes.Expression{Not: &costExplorerTypes.Expression{
+				Dimensions: &costExplorerTypes.DimensionValues{Key: "LINKED_ACCOUNT", Values: []string{"222"}},
+			}},
+		},
+	)
+}
+
+func TestPlanCostCategories(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockAWS := mock.NewMockClient(mockCtrl)
+	spec := loadTestSpec(t, mockAWS)
+	existingCategories(mockAWS.EXPECT(), []string{"111"})
+
+	changes, err := planCostCategories(spec, mockAWS, false)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, actionUnchanged, changes[0].Action)
+	assert.Equal(t, actionUpdate, changes[1].Action)
+	assert.Equal(t, "arn:teams", changes[1].Arn)
+
+	var out bytes.Buffer
+	assert.Equal(t, 1, printCostCategoryDiff(&out, changes))
+	assert.Equal(t, `  ou-1 (unchanged)
+~ teams
+    - shared: accounts [111]
+    + shared: accounts [111, 333]
+      cluster-a: tag cluster in [a]
+      not-prod: expression {"Not":{"Dimensions":{"Key":"LINKED_ACCOUNT","Values":["222"]}}}
+`, out.String())
+
+	changes, err = planCostCategories(spec, mockAWS, true)
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+	assert.Equal(t, costCategoryChange{
+		Action: actionDelete, Name: "old", Arn: "arn:old",
+		Current: &costCategorySpec{Name: "old", Rules: []ruleSpec{{Value: "old", Accounts: []string{"444"}}}},
+	}, changes[2])
+
+	out.Reset()
+	assert.Equal(t, 2, printCostCategoryDiff(&out, changes))
+	assert.Contains(t, out.String(), "- old\n    - old: accounts [444]\n")
+}
+
+func TestApplyCostCategories(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockAWS := mock.NewMockClient(mockCtrl)
+	spec := loadTestSpec(t, mockAWS)
+	spec.CostCategories = append(spec.CostCategories, costCategorySpec{
+		Name:  "new",
+		Rules: []ruleSpec{{Value: "new", Accounts: []string{"555"}}},
+	})
+	existingCategories(mockAWS.EXPECT(), []string{"111"})
+
+	changes, err := planCostCategories(spec, mockAWS, true)
+	require.NoError(t, err)
+
+	mockAWS.EXPECT().UpdateCostCategoryDefinition(gomock.Any()).DoAndReturn(
+		func(input *costexplorer.UpdateCostCategoryDefinitionInput) (*costexplorer.UpdateCostCategoryDefinitionOutput, error) {
+			assert.Equal(t, "arn:teams", *input.CostCategoryArn)
+			assert.Equal(t, "other", *input.DefaultValue)
+			require.Len(t, input.Rules, 3)
+			assert.Equal(t, []string{"111", "333"}, input.Rules[0].Rule.Dimensions.Values)
+			assert.Equal(t, "cluster", *input.Rules[1].Rule.Tags.Key)
+			assert.Equal(t, []string{"222"}, input.Rules[2].Rule.Not.Dimensions.Values)
+			return &costexplorer.UpdateCostCategoryDefinitionOutput{}, nil
+		})
+	mockAWS.EXPECT().CreateCostCategoryDefinition(gomock.Any()).DoAndReturn(
+		func(input *costexplorer.CreateCostCategoryDefinitionInput) (*costexplorer.CreateCostCategoryDefinitionOutput, error) {
+			assert.Equal(t, "new", *input.Name)
+			assert.Nil(t, input.DefaultValue)
+			assert.Equal(t, []string{"555"}, input.Rules[0].Rule.Dimensions.Values)
+			return &costexplorer.CreateCostCategoryDefinitionOutput{}, nil
+		})
+	mockAWS.EXPECT().DeleteCostCategoryDefinition(&costexplorer.DeleteCostCategoryDefinitionInput{CostCategoryArn: aws.String("arn:old")}).
+		Return(&costexplorer.DeleteCostCategoryDefinitionOutput{}, nil)
+
+	var out bytes.Buffer
+	require.NoError(t, applyCostCategories(&out, changes, mockAWS))
+	assert.Equal(t, "Updated cost category teams\nCreated cost category new\nDeleted cost category old\n", out.String())
+}
+
+func TestApplyCostCategories_Idempotent(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockAWS := mock.NewMockClient(mockCtrl)
+	spec := loadTestSpec(t, mockAWS)
+	// The categories already match the spec, so no create, update or delete calls are expected
+	existingCategories(mockAWS.EXPECT(), []string{"333", "111"})
+
+	changes, err := planCostCategories(spec, mockAWS, false)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	assert.Equal(t, 0, printCostCategoryDiff(&out, changes))
+	assert.Contains(t, out.String(), "Cost categories are up-to-date.")
+	require.NoError(t, applyCostCategories(&out, changes, mockAWS))
+}
+
+func TestApplyOptions_Confirmation(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockAWS := mock.NewMockClient(mockCtrl)
+	expectOU(mockAWS.EXPECT(), "ou-1", "222", "111")
+	existingCategories(mockAWS.EXPECT(), []string{"111"})
+
+	var out bytes.Buffer
+	ops := &applyOptions{spec: writeSpec(t, testSpec)}
+	ops.In = bytes.NewBufferString("n\n")
+	ops.Out = &out
+	assert.ErrorContains(t, ops.run(mockAWS), "aborted")
+	assert.Contains(t, out.String(), "Apply these changes? (y/N)")
+}
+
+func TestApplyOptions_PruneConfirmation(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockAWS := mock.NewMockClient(mockCtrl)
+	expectOU(mockAWS.EXPECT(), "ou-1", "222", "111")
+	existingCategories(mockAWS.EXPECT(), []string{"111"})
+
+	// --yes doesn't confirm the deletions
+	var out bytes.Buffer
+	ops := &applyOptions{spec: writeSpec(t, testSpec), prune: true, yes: true}
+	ops.In = bytes.NewBufferString("y\n")
+	ops.Out = &out
+	assert.ErrorContains(t, ops.run(mockAWS), "aborted")
+	assert.Contains(t, out.String(), "--prune deletes 1 cost categories which aren't in the spec:\n  - old\nType 'delete' to delete them: ")
+
+	mockAWS.EXPECT().UpdateCostCategoryDefinition(gomock.Any()).Return(&costexplorer.UpdateCostCategoryDefinitionOutput{}, nil)
+	mockAWS.EXPECT().DeleteCostCategoryDefinition(&costexplorer.DeleteCostCategoryDefinitionInput{CostCategoryArn: aws.String("arn:old")}).
+		Return(&costexplorer.DeleteCostCategoryDefinitionOutput{}, nil)
+	expectOU(mockAWS.EXPECT(), "ou-1", "222", "111")
+	out.Reset()
+	ops.yes = false
+	ops.In = bytes.NewBufferString("y\ndelete\n")
+	require.NoError(t, ops.run(mockAWS))
+	assert.Contains(t, out.String(), "Apply these changes? (y/N) ")
+}
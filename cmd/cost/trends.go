@@ -0,0 +1,477 @@
+package cost
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	costExplorerTypes "github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/openshift/osdctl/pkg/printer"
+	awsprovider "github.com/openshift/osdctl/pkg/provider/aws"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+const (
+	trendsMetric = "NetUnblendedCost"
+
+	flagJump      = "JUMP"
+	flagDeviation = "DEVIATION"
+)
+
+// trendsOptions defines the flags of the trends command
+type trendsOptions struct {
+	ou          string
+	recursive   bool
+	granularity string
+	time        string
+	start       string
+	end         string
+	threshold   float64
+	deviation   float64
+	window      int
+	minCost     float64
+	csv         bool
+	report      string
+
+	genericclioptions.IOStreams
+}
+
+func newCmdTrends(streams genericclioptions.IOStreams) *cobra.Command {
+	ops := &trendsOptions{IOStreams: streams}
+	trendsCmd := &cobra.Command{
+		Use:   "trends",
+		Short: "Show cost trends and anomalies of the accounts under an OU",
+		Long: `Pulls the cost of every account under the OU grouped by account and service, and compares
+the latest period to the one before and to the trailing average of the preceding periods.
+
+Accounts are flagged with JUMP when their cost rose by more than --threshold percent compared to the
+previous period, and with DEVIATION when it is more than --deviation percent above the trailing average.
+Without a time range the last 6 complete months (monthly) or the last 30 days (daily) are used.`,
+		Example: `  # Month over month trends of all accounts under an OU and its child OUs
+  osdctl cost trends --ou ou-abcd-12345678 -r
+
+  # Daily trends of the last 30 days, written as CSV together with a Markdown report
+  osdctl cost trends --ou ou-abcd-12345678 --granularity daily --csv --report trends.md`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(ops.checkArgs(cmd))
+			cmdutil.CheckErr(ops.run())
+		},
+	}
+	trendsCmd.Flags().StringVar(&ops.ou, "ou", "", "set OU ID")
+	trendsCmd.Flags().BoolVarP(&ops.recursive, "recursive", "r", false, "include the accounts of child OUs")
+	trendsCmd.Flags().StringVar(&ops.granularity, "granularity", "monthly", "granularity of the periods: monthly or daily")
+	trendsCmd.Flags().StringVarP(&ops.time, "time", "t", "", "set time. One of 'LM', 'MTD', 'YTD', '3M', '6M', '1Y'")
+	trendsCmd.Flags().StringVar(&ops.start, "start", "", "set start date range")
+	trendsCmd.Flags().StringVar(&ops.end, "end", "", "set end date range")
+	trendsCmd.Flags().Float64Var(&ops.threshold, "threshold", 25, "flag accounts whose cost rose by more than this percentage compared to the previous period")
+	trendsCmd.Flags().Float64Var(&ops.deviation, "deviation", 50, "flag accounts whose cost is more than this percentage above their trailing average")
+	trendsCmd.Flags().IntVar(&ops.window, "window", 3, "number of preceding periods in the trailing average")
+	trendsCmd.Flags().Float64Var(&ops.minCost, "min-cost", 1, "don't flag accounts with a latest period cost below this amount")
+	trendsCmd.Flags().BoolVar(&ops.csv, "csv", false, "output result as csv")
+	trendsCmd.Flags().StringVar(&ops.report, "report", "", "also write a Markdown report to this file")
+
+	return trendsCmd
+}
+
+func (o *trendsOptions) checkArgs(cmd *cobra.Command) error {
+	if o.ou == "" {
+		return cmdutil.UsageErrorf(cmd, "Please provide OU")
+	}
+	if o.granularity != "monthly" && o.granularity != "daily" {
+		return cmdutil.UsageErrorf(cmd, "Granularity must be one of 'monthly' or 'daily'")
+	}
+	if o.start != "" && o.end != "" && o.time != "" {
+		return cmdutil.UsageErrorf(cmd, "Please provide either a date range or a predefined time")
+	}
+	if (o.start == "") != (o.end == "") {
+		return cmdutil.UsageErrorf(cmd, "Please provide both start and end of date range")
+	}
+	if o.window < 1 {
+		return cmdutil.UsageErrorf(cmd, "The trailing average window must be at least 1")
+	}
+	return nil
+}
+
+// timeRange returns the start and end date of the requested periods
+func (o *trendsOptions) timeRange(now time.Time) (string, string) {
+	switch {
+	case o.start != "":
+		return o.start, o.end
+	case o.time != "":
+		return getTimePeriod(&o.time)
+	case o.granularity == "daily":
+		return now.AddDate(0, 0, -30).Format("2006-01-02"), now.Format("2006-01-02")
+	default:
+		firstOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return firstOfMonth.AddDate(0, -6, 0).Format("2006-01-02"), firstOfMonth.Format("2006-01-02")
+	}
+}
+
+func (o *trendsOptions) run() error {
+	awsClient, err := opsCost.initAWSClients()
+	if err != nil {
+		return err
+	}
+
+	OU := getOU(awsClient, o.ou)
+	var accounts []*string
+	if o.recursive {
+		accounts, err = getAccountsRecursive(OU, awsClient)
+	} else {
+		accounts, err = getAccounts(OU, awsClient)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list accounts of OU %s: %v", o.ou, err)
+	}
+	if len(accounts) == 0 {
+		return fmt.Errorf("no accounts found under OU %s", o.ou)
+	}
+
+	start, end := o.timeRange(time.Now())
+	trends, err := getCostTrends(awsClient, accounts, start, end, strings.ToUpper(o.granularity))
+	if err != nil {
+		return err
+	}
+	if len(trends.Periods) < 2 {
+		return fmt.Errorf("at least 2 periods are needed to compute trends, got %d between %s and %s", len(trends.Periods), start, end)
+	}
+
+	results := trends.analyze(o.threshold, o.deviation, o.window, decimal.NewFromFloat(o.minCost))
+	if err := writeTrends(o.Out, trends, results, o.csv); err != nil {
+		return err
+	}
+
+	if o.report != "" {
+		f, err := os.Create(o.report)
+		if err != nil {
+			return fmt.Errorf("failed to create report: %v", err)
+		}
+		defer f.Close()
+		title := o.ou
+		if OU.Name != nil {
+			title = fmt.Sprintf("%s (%s)", *OU.Name, o.ou)
+		}
+		if err := writeTrendsReport(f, title, trends, results); err != nil {
+			return fmt.Errorf("failed to write report: %v", err)
+		}
+		fmt.Fprintf(o.ErrOut, "Report written to %s\n", o.report)
+	}
+
+	return nil
+}
+
+// costTrends holds the cost of every account and service per period
+type costTrends struct {
+	// Periods are the start dates of the periods in chronological order
+	Periods []string
+	Unit    string
+	// Costs maps account ID to service to the cost per period
+	Costs map[string]map[string][]decimal.Decimal
+}
+
+// getCostTrends gets the cost of the accounts grouped by account and service
+func getCostTrends(awsClient awsprovider.Client, accounts []*string, start, end, granularity string) (*costTrends, error) {
+	var accountIDs []string
+	for _, account := range accounts {
+		accountIDs = append(accountIDs, *account)
+	}
+
+	trends := &costTrends{Costs: map[string]map[string][]decimal.Decimal{}}
+	periodIndex := map[string]int{}
+	var nextPageToken *string
+	for {
+		costs, err := awsClient.GetCostAndUsage(&costexplorer.GetCostAndUsageInput{
+			Filter: &costExplorerTypes.Expression{
+				Dimensions: &costExplorerTypes.DimensionValues{
+					Key:    "LINKED_ACCOUNT",
+					Values: accountIDs,
+				},
+			},
+			TimePeriod: &costExplorerTypes.DateInterval{
+				Start: &start,
+				End:   &end,
+			},
+			Granularity: costExplorerTypes.Granularity(granularity),
+			Metrics:     []string{trendsMetric},
+			GroupBy: []costExplorerTypes.GroupDefinition{
+				{Type: costExplorerTypes.GroupDefinitionTypeDimension, Key: aws.String("LINKED_ACCOUNT")},
+				{Type: costExplorerTypes.GroupDefinitionTypeDimension, Key: aws.String("SERVICE")},
+			},
+			NextPageToken: nextPageToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get cost and usage: %v", err)
+		}
+
+		for _, result := range costs.ResultsByTime {
+			if result.TimePeriod == nil || result.TimePeriod.Start == nil {
+				continue
+			}
+			period := *result.TimePeriod.Start
+			if _, ok := periodIndex[period]; !ok {
+				periodIndex[period] = len(trends.Periods)
+				trends.Periods = append(trends.Periods, period)
+			}
+			for _, group := range result.Groups {
+				if len(group.Keys) != 2 {
+					continue
+				}
+				metric, ok := group.Metrics[trendsMetric]
+				if !ok || metric.Amount == nil {
+					continue
+				}
+				amount, err := decimal.NewFromString(*metric.Amount)
+				if err != nil {
+					return nil, err
+				}
+				if trends.Unit == "" && metric.Unit != nil {
+					trends.Unit = *metric.Unit
+				}
+				trends.add(group.Keys[0], group.Keys[1], periodIndex[period], amount)
+			}
+		}
+
+		if costs.NextPageToken == nil {
+			break
+		}
+		nextPageToken = costs.NextPageToken
+	}
+
+	// Periods of later pages might be missing in the series of earlier ones
+	for _, services := range trends.Costs {
+		for service, series := range services {
+			services[service] = padSeries(series, len(trends.Periods))
+		}
+	}
+	// Accounts without any cost still show up with zeros
+	for _, account := range accountIDs {
+		if _, ok := trends.Costs[account]; !ok {
+			trends.Costs[account] = map[string][]decimal.Decimal{}
+		}
+	}
+
+	return trends, nil
+}
+
+func (t *costTrends) add(account, service string, period int, amount decimal.Decimal) {
+	if _, ok := t.Costs[account]; !ok {
+		t.Costs[account] = map[string][]decimal.Decimal{}
+	}
+	series := padSeries(t.Costs[account][service], period+1)
+	series[period] = series[period].Add(amount)
+	t.Costs[account][service] = series
+}
+
+func padSeries(series []decimal.Decimal, length int) []decimal.Decimal {
+	for len(series) < length {
+		series = append(series, decimal.Zero)
+	}
+	return series
+}
+
+// accountTotals returns the cost of all services of an account per period
+func (t *costTrends) accountTotals(account string) []decimal.Decimal {
+	totals := padSeries(nil, len(t.Periods))
+	for _, series := range t.Costs[account] {
+		for i, cost := range series {
+			totals[i] = totals[i].Add(cost)
+		}
+	}
+	return totals
+}
+
+// serviceDelta is the change of cost of a service between the previous and the latest period
+type serviceDelta struct {
+	Service string
+	Delta   decimal.Decimal
+}
+
+// accountTrend is the analysis of the latest period of an account
+type accountTrend struct {
+	AccountID string
+	Previous  decimal.Decimal
+	Current   decimal.Decimal
+	Delta     decimal.Decimal
+	// DeltaPercent is nil when the previous period had no cost
+	DeltaPercent *decimal.Decimal
+	TrailingAvg  decimal.Decimal
+	Services     []serviceDelta
+	Flags        []string
+}
+
+// analyze compares the latest period of every account to the previous one and to the trailing average
+// of up to window periods before it. The result is sorted by delta, largest increase first.
+func (t *costTrends) analyze(threshold, deviation float64, window int, minCost decimal.Decimal) []accountTrend {
+	last := len(t.Periods) - 1
+	hundred := decimal.NewFromInt(100)
+
+	var results []accountTrend
+	for account, services := range t.Costs {
+		totals := t.accountTotals(account)
+		trend := accountTrend{
+			AccountID: account,
+			Previous:  totals[last-1],
+			Current:   totals[last],
+		}
+		trend.Delta = trend.Current.Sub(trend.Previous)
+		if !trend.Previous.IsZero() {
+			percent := trend.Delta.Div(trend.Previous).Mul(hundred)
+			trend.DeltaPercent = &percent
+		}
+
+		first := last - window
+		if first < 0 {
+			first = 0
+		}
+		sum := decimal.Zero
+		for _, cost := range totals[first:last] {
+			sum = sum.Add(cost)
+		}
+		trend.TrailingAvg = sum.Div(decimal.NewFromInt(int64(last - first)))
+
+		for service, series := range services {
+			if delta := series[last].Sub(series[last-1]); !delta.IsZero() {
+				trend.Services = append(trend.Services, serviceDelta{Service: service, Delta: delta})
+			}
+		}
+		sort.Slice(trend.Services, func(i, j int) bool {
+			return trend.Services[i].Delta.Abs().GreaterThan(trend.Services[j].Delta.Abs())
+		})
+
+		if trend.Current.GreaterThanOrEqual(minCost) {
+			// A cost appearing out of nothing is always a jump
+			if trend.Delta.IsPositive() && (trend.DeltaPercent == nil || trend.DeltaPercent.GreaterThan(decimal.NewFromFloat(threshold))) {
+				trend.Flags = append(trend.Flags, flagJump)
+			}
+			limit := trend.TrailingAvg.Mul(decimal.NewFromFloat(1 + deviation/100))
+			if trend.Current.GreaterThan(limit) {
+				trend.Flags = append(trend.Flags, flagDeviation)
+			}
+		}
+
+		results = append(results, trend)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if !results[i].Delta.Equal(results[j].Delta) {
+			return results[i].Delta.GreaterThan(results[j].Delta)
+		}
+		return results[i].AccountID < results[j].AccountID
+	})
+	return results
+}
+
+func formatPercent(percent *decimal.Decimal) string {
+	if percent == nil {
+		return "new"
+	}
+	return percent.StringFixed(1) + "%"
+}
+
+func formatSigned(d decimal.Decimal) string {
+	if d.IsPositive() {
+		return "+" + d.StringFixed(2)
+	}
+	return d.StringFixed(2)
+}
+
+// topService describes the service contributing the most to the change of an account
+func (a accountTrend) topService() string {
+	if len(a.Services) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s (%s)", a.Services[0].Service, formatSigned(a.Services[0].Delta))
+}
+
+func writeTrends(out io.Writer, trends *costTrends, results []accountTrend, asCSV bool) error {
+	last := len(trends.Periods) - 1
+	if asCSV {
+		csvWriter := csv.NewWriter(out)
+		if err := csvWriter.Write([]string{"AccountID", trends.Periods[last-1], trends.Periods[last], "Delta", "Delta%", "TrailingAvg", "Unit", "TopService", "Flags"}); err != nil {
+			return fmt.Errorf("failed to write CSV header: %w", err)
+		}
+		for _, r := range results {
+			if err := csvWriter.Write([]string{r.AccountID, r.Previous.StringFixed(2), r.Current.StringFixed(2), r.Delta.StringFixed(2),
+				formatPercent(r.DeltaPercent), r.TrailingAvg.StringFixed(2), trends.Unit, r.topService(), strings.Join(r.Flags, "|")}); err != nil {
+				return fmt.Errorf("failed to write CSV row: %w", err)
+			}
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+
+	table := printer.NewTablePrinter(out, 20, 1, 3, ' ')
+	table.AddRow([]string{"ACCOUNT", trends.Periods[last-1], trends.Periods[last], "DELTA", "DELTA %", "TRAILING AVG", "TOP SERVICE CHANGE", "FLAGS"})
+	for _, r := range results {
+		table.AddRow([]string{r.AccountID, r.Previous.StringFixed(2), r.Current.StringFixed(2), formatSigned(r.Delta),
+			formatPercent(r.DeltaPercent), r.TrailingAvg.StringFixed(2), r.topService(), strings.Join(r.Flags, ",")})
+	}
+	return table.Flush()
+}
+
+// writeTrendsReport writes a Markdown report of the OU cost per period and the flagged accounts
+func writeTrendsReport(out io.Writer, title string, trends *costTrends, results []accountTrend) error {
+	var b strings.Builder
+	last := len(trends.Periods) - 1
+
+	fmt.Fprintf(&b, "# Cost trends of %s\n\n", title)
+	fmt.Fprintf(&b, "Periods %s to %s, costs in %s (%s).\n\n", trends.Periods[0], trends.Periods[last], trends.Unit, trendsMetric)
+
+	b.WriteString("## Total cost per period\n\n| Period | Cost | Change |\n|---|---:|---:|\n")
+	var previous decimal.Decimal
+	for i, period := range trends.Periods {
+		total := decimal.Zero
+		for account := range trends.Costs {
+			total = total.Add(trends.accountTotals(account)[i])
+		}
+		change := ""
+		if i > 0 {
+			change = formatSigned(total.Sub(previous))
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s |\n", period, total.StringFixed(2), change)
+		previous = total
+	}
+
+	b.WriteString("\n## Flagged accounts\n\n")
+	flagged := 0
+	for _, r := range results {
+		if len(r.Flags) == 0 {
+			continue
+		}
+		flagged++
+		fmt.Fprintf(&b, "### %s (%s)\n\n", r.AccountID, strings.Join(r.Flags, ", "))
+		fmt.Fprintf(&b, "%s → %s (%s, %s), trailing average %s.\n\n", r.Previous.StringFixed(2), r.Current.StringFixed(2),
+			formatSigned(r.Delta), formatPercent(r.DeltaPercent), r.TrailingAvg.StringFixed(2))
+		if len(r.Services) > 0 {
+			b.WriteString("| Service | Change |\n|---|---:|\n")
+			for i, s := range r.Services {
+				if i == 5 {
+					break
+				}
+				fmt.Fprintf(&b, "| %s | %s |\n", s.Service, formatSigned(s.Delta))
+			}
+			b.WriteString("\n")
+		}
+	}
+	if flagged == 0 {
+		b.WriteString("No anomalies found.\n\n")
+	}
+
+	fmt.Fprintf(&b, "## All accounts\n\n| Account | %s | %s | Delta | Delta %% | Flags |\n|---|---:|---:|---:|---:|---|\n", trends.Periods[last-1], trends.Periods[last])
+	for _, r := range results {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n", r.AccountID, r.Previous.StringFixed(2), r.Current.StringFixed(2),
+			formatSigned(r.Delta), formatPercent(r.DeltaPercent), strings.Join(r.Flags, ", "))
+	}
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
@@ -0,0 +1,167 @@
+package cost
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	types2 "github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/openshift/osdctl/pkg/provider/aws/mock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func costGroup(account, service, amount string) types2.Group {
+	return types2.Group{
+		Keys: []string{account, service},
+		Metrics: map[string]types2.MetricValue{
+			trendsMetric: {Amount: aws.String(amount), Unit: aws.String("USD")},
+		},
+	}
+}
+
+func costResult(start string, groups ...types2.Group) types2.ResultByTime {
+	return types2.ResultByTime{
+		TimePeriod: &types2.DateInterval{Start: aws.String(start)},
+		Groups:     groups,
+	}
+}
+
+// mockTrends returns four months of cost, split over two pages:
+// 111 is stable, 222 doubles its EC2 cost in the last month and 333 starts spending in the last month
+func mockTrends(t *testing.T) *costTrends {
+	mockCtrl := gomock.NewController(t)
+	mockAWS := mock.NewMockClient(mockCtrl)
+
+	first := mockAWS.EXPECT().GetCostAndUsage(gomock.Any()).DoAndReturn(
+		func(input *costexplorer.GetCostAndUsageInput) (*costexplorer.GetCostAndUsageOutput, error) {
+			assert.Nil(t, input.NextPageToken)
+			assert.Equal(t, []string{"111", "222", "333"}, input.Filter.Dimensions.Values)
+			assert.Equal(t, types2.GranularityMonthly, input.Granularity)
+			assert.Len(t, input.GroupBy, 2)
+			return &costexplorer.GetCostAndUsageOutput{
+				ResultsByTime: []types2.ResultByTime{
+					costResult("2025-01-01", costGroup("111", "AmazonEC2", "100"), costGroup("222", "AmazonEC2", "100"), costGroup("222", "Amazon S3", "10")),
+					costResult("2025-02-01", costGroup("111", "AmazonEC2", "100"), costGroup("222", "AmazonEC2", "100"), costGroup("222", "Amazon S3", "10")),
+				},
+				NextPageToken: aws.String("page-2"),
+			}, nil
+		})
+	mockAWS.EXPECT().GetCostAndUsage(gomock.Any()).DoAndReturn(
+		func(input *costexplorer.GetCostAndUsageInput) (*costexplorer.GetCostAndUsageOutput, error) {
+			assert.Equal(t, "page-2", *input.NextPageToken)
+			return &costexplorer.GetCostAndUsageOutput{
+				ResultsByTime: []types2.ResultByTime{
+					costResult("2025-03-01", costGroup("111", "AmazonEC2", "100"), costGroup("222", "AmazonEC2", "100"), costGroup("222", "Amazon S3", "10")),
+					costResult("2025-04-01", costGroup("111", "AmazonEC2", "105"), costGroup("222", "AmazonEC2", "200"), costGroup("222", "Amazon S3", "10"), costGroup("333", "AWS Lambda", "50")),
+				},
+			}, nil
+		}).After(first)
+
+	trends, err := getCostTrends(mockAWS, []*string{aws.String("111"), aws.String("222"), aws.String("333")}, "2025-01-01", "2025-05-01", "MONTHLY")
+	require.NoError(t, err)
+	return trends
+}
+
+func TestGetCostTrends(t *testing.T) {
+	trends := mockTrends(t)
+
+	assert.Equal(t, []string{"2025-01-01", "2025-02-01", "2025-03-01", "2025-04-01"}, trends.Periods)
+	assert.Equal(t, "USD", trends.Unit)
+	assert.Len(t, trends.Costs["333"]["AWS Lambda"], 4)
+	assert.True(t, trends.Costs["333"]["AWS Lambda"][0].IsZero())
+	assert.Equal(t, "210", trends.accountTotals("222")[3].String())
+}
+
+func TestGetCostTrends_Error(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockAWS := mock.NewMockClient(mockCtrl)
+	mockAWS.EXPECT().GetCostAndUsage(gomock.Any()).Return(nil, errors.New("throttled"))
+
+	_, err := getCostTrends(mockAWS, []*string{aws.String("111")}, "2025-01-01", "2025-05-01", "MONTHLY")
+	assert.ErrorContains(t, err, "throttled")
+}
+
+func TestAnalyzeTrends(t *testing.T) {
+	trends := mockTrends(t)
+
+	results := trends.analyze(25, 50, 3, decimal.NewFromInt(1))
+	require.Len(t, results, 3)
+
+	// Sorted by delta
+	assert.Equal(t, "222", results[0].AccountID)
+	assert.Equal(t, "100", results[0].Delta.String())
+	assert.Equal(t, "90.9", results[0].DeltaPercent.StringFixed(1))
+	assert.Equal(t, "110", results[0].TrailingAvg.String())
+	assert.Equal(t, []string{flagJump, flagDeviation}, results[0].Flags)
+	assert.Equal(t, "AmazonEC2 (+100.00)", results[0].topService())
+
+	assert.Equal(t, "333", results[1].AccountID)
+	assert.Nil(t, results[1].DeltaPercent)
+	assert.Equal(t, []string{flagJump, flagDeviation}, results[1].Flags)
+
+	assert.Equal(t, "111", results[2].AccountID)
+	assert.Empty(t, results[2].Flags)
+
+	// A new account below the minimum cost isn't flagged
+	results = trends.analyze(25, 50, 3, decimal.NewFromInt(100))
+	assert.Equal(t, "333", results[1].AccountID)
+	assert.Empty(t, results[1].Flags)
+}
+
+func TestWriteTrends(t *testing.T) {
+	trends := mockTrends(t)
+	results := trends.analyze(25, 50, 3, decimal.NewFromInt(1))
+
+	var out bytes.Buffer
+	require.NoError(t, writeTrends(&out, trends, results, true))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, "AccountID,2025-03-01,2025-04-01,Delta,Delta%,TrailingAvg,Unit,TopService,Flags", lines[0])
+	assert.Equal(t, `222,110.00,210.00,100.00,90.9%,110.00,USD,AmazonEC2 (+100.00),JUMP|DEVIATION`, lines[1])
+	assert.Equal(t, `333,0.00,50.00,50.00,new,0.00,USD,AWS Lambda (+50.00),JUMP|DEVIATION`, lines[2])
+
+	// Fields with a separator or a quote are quoted the CSV way
+	quoted := results[0]
+	quoted.Services = append([]serviceDelta{}, results[0].Services...)
+	quoted.Services[0].Service = `Savings Plans for "Compute", EC2`
+	out.Reset()
+	require.NoError(t, writeTrends(&out, trends, []accountTrend{quoted}, true))
+	assert.Equal(t, `222,110.00,210.00,100.00,90.9%,110.00,USD,"Savings Plans for ""Compute"", EC2 (+100.00)",JUMP|DEVIATION`, strings.Split(out.String(), "\n")[1])
+
+	out.Reset()
+	require.NoError(t, writeTrends(&out, trends, results, false))
+	assert.Contains(t, out.String(), "TOP SERVICE CHANGE")
+	assert.Contains(t, out.String(), "JUMP,DEVIATION")
+
+	out.Reset()
+	require.NoError(t, writeTrendsReport(&out, "Test (ou-1)", trends, results))
+	report := out.String()
+	assert.Contains(t, report, "# Cost trends of Test (ou-1)")
+	assert.Contains(t, report, "| 2025-04-01 | 365.00 | +155.00 |")
+	assert.Contains(t, report, "### 222 (JUMP, DEVIATION)")
+	assert.Contains(t, report, "| AmazonEC2 | +100.00 |")
+	assert.NotContains(t, report, "### 111")
+}
+
+func TestTrendsTimeRange(t *testing.T) {
+	now := time.Date(2025, 6, 15, 0, 0, 0, 0, time.UTC)
+
+	start, end := (&trendsOptions{granularity: "monthly"}).timeRange(now)
+	assert.Equal(t, "2024-12-01", start)
+	assert.Equal(t, "2025-06-01", end)
+
+	start, end = (&trendsOptions{granularity: "daily"}).timeRange(now)
+	assert.Equal(t, "2025-05-16", start)
+	assert.Equal(t, "2025-06-15", end)
+
+	start, end = (&trendsOptions{start: "2025-01-01", end: "2025-02-01"}).timeRange(now)
+	assert.Equal(t, "2025-01-01", start)
+	assert.Equal(t, "2025-02-01", end)
+}
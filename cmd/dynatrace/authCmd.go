@@ -0,0 +1,81 @@
+package dynatrace
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/openshift/osdctl/pkg/printer"
+	"github.com/spf13/cobra"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+func newCmdAuth() *cobra.Command {
+	authCmd := &cobra.Command{
+		Use:   "auth",
+		Short: "Manage the cached Dynatrace access tokens",
+		Long: `Dynatrace access tokens are cached until they expire and shared by all osdctl dynatrace commands.
+
+  By default the tokens are stored in the OS keyring. Set 'dt_token_store: file' in the osdctl
+  config to use an encrypted file instead (the password is read from $OSDCTL_DT_TOKEN_PASSWORD
+  or prompted for), or 'dt_token_store: none' to not cache tokens across invocations.`,
+		Args:              cobra.NoArgs,
+		DisableAutoGenTag: true,
+	}
+
+	authCmd.AddCommand(newCmdAuthStatus())
+	authCmd.AddCommand(newCmdAuthLogout())
+
+	return authCmd
+}
+
+func newCmdAuthStatus() *cobra.Command {
+	return &cobra.Command{
+		Use:               "status",
+		Short:             "Show the cached Dynatrace access tokens and when they expire",
+		Args:              cobra.NoArgs,
+		DisableAutoGenTag: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(authStatus(os.Stdout, defaultTokenManager()))
+		},
+	}
+}
+
+func newCmdAuthLogout() *cobra.Command {
+	return &cobra.Command{
+		Use:               "logout",
+		Short:             "Remove all cached Dynatrace access tokens",
+		Args:              cobra.NoArgs,
+		DisableAutoGenTag: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			removed, err := defaultTokenManager().clear()
+			cmdutil.CheckErr(err)
+			fmt.Printf("Removed %d cached access token(s)\n", removed)
+		},
+	}
+}
+
+func authStatus(out io.Writer, m *tokenManager) error {
+	tokens, err := m.tokens()
+	if err != nil {
+		return fmt.Errorf("failed to read cached tokens: %v", err)
+	}
+	if len(tokens) == 0 {
+		fmt.Fprintln(out, "No cached access tokens")
+		return nil
+	}
+
+	now := m.now()
+	table := printer.NewTablePrinter(out, 20, 1, 3, ' ')
+	table.AddRow([]string{"VAULT PATH", "SCOPES", "EXPIRES", "STATUS"})
+	for _, t := range tokens {
+		status := "valid"
+		if !t.validAt(now) {
+			status = "expired"
+		}
+		table.AddRow([]string{t.VaultPath, t.Scopes, t.ExpiresAt.Local().Format(time.RFC3339), status})
+	}
+
+	return table.Flush()
+}
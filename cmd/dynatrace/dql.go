@@ -0,0 +1,371 @@
+package dynatrace
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// This file contains a small typed representation of the subset of DQL osdctl
+// generates. Every value that ends up in a query goes through dqlString or
+// dqlField, so user supplied input can never break out of a literal.
+
+var dqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// dqlString renders s as a double quoted DQL string literal
+func dqlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// dqlField renders a field name, backtick quoting it when it isn't a plain identifier
+func dqlField(name string) string {
+	if dqlIdentifier.MatchString(name) {
+		return name
+	}
+	return "`" + name + "`"
+}
+
+// Expr is a DQL expression that can be used in a filter command
+type Expr interface {
+	dql() string
+}
+
+// Value is a literal used on the right hand side of a comparison
+type Value struct {
+	str string
+	// raw holds the already rendered form of non-string literals
+	raw string
+}
+
+// StringValue returns a string literal value
+func StringValue(s string) Value {
+	return Value{str: s}
+}
+
+// NumberValue returns a numeric literal value
+func NumberValue(n float64) Value {
+	return Value{raw: strconv.FormatFloat(n, 'f', -1, 64)}
+}
+
+// BoolValue returns a boolean literal value
+func BoolValue(b bool) Value {
+	return Value{raw: strconv.FormatBool(b)}
+}
+
+func (v Value) dql() string {
+	if v.raw != "" {
+		return v.raw
+	}
+	return dqlString(v.str)
+}
+
+type andExpr []Expr
+type orExpr []Expr
+type notExpr struct{ e Expr }
+
+// And joins the expressions with "and". Nil expressions are skipped.
+func And(exprs ...Expr) Expr { return andExpr(compact(exprs)) }
+
+// Or joins the expressions with "or" and wraps them in parentheses. Nil expressions are skipped.
+func Or(exprs ...Expr) Expr { return orExpr(compact(exprs)) }
+
+// Not negates the expression
+func Not(e Expr) Expr { return notExpr{e: e} }
+
+func compact(exprs []Expr) []Expr {
+	out := make([]Expr, 0, len(exprs))
+	for _, e := range exprs {
+		if e != nil {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (a andExpr) dql() string {
+	parts := make([]string, len(a))
+	for i, e := range a {
+		parts[i] = e.dql()
+	}
+	return strings.Join(parts, " and ")
+}
+
+func (o orExpr) dql() string {
+	parts := make([]string, len(o))
+	for i, e := range o {
+		parts[i] = e.dql()
+	}
+	return "(" + strings.Join(parts, " or ") + ")"
+}
+
+func (n notExpr) dql() string {
+	switch n.e.(type) {
+	case orExpr, funcExpr:
+		return "not " + n.e.dql()
+	}
+	return "not (" + n.e.dql() + ")"
+}
+
+type funcExpr struct {
+	name  string
+	field string
+	value Value
+	opts  string
+}
+
+func (f funcExpr) dql() string {
+	return fmt.Sprintf("%s(%s, %s%s)", f.name, dqlField(f.field), f.value.dql(), f.opts)
+}
+
+// MatchesValue renders matchesValue(field, "value")
+func MatchesValue(field string, value string) Expr {
+	return funcExpr{name: "matchesValue", field: field, value: StringValue(value)}
+}
+
+// MatchesPhrase renders matchesPhrase(field, "value")
+func MatchesPhrase(field string, value string) Expr {
+	return funcExpr{name: "matchesPhrase", field: field, value: StringValue(value)}
+}
+
+// Contains renders contains(field, "value", caseSensitive:<bool>)
+func Contains(field string, value string, caseSensitive bool) Expr {
+	return funcExpr{name: "contains", field: field, value: StringValue(value), opts: fmt.Sprintf(", caseSensitive:%t", caseSensitive)}
+}
+
+// AnyValue matches any of the given values for the field. It returns nil for an empty list.
+func AnyValue(field string, values []string) Expr {
+	if len(values) == 0 {
+		return nil
+	}
+	exprs := make([]Expr, len(values))
+	for i, v := range values {
+		exprs[i] = MatchesValue(field, v)
+	}
+	return Or(exprs...)
+}
+
+// Operator is a comparison operator for a field filter
+type Operator string
+
+const (
+	OpEqual          Operator = "=="
+	OpNotEqual       Operator = "!="
+	OpLess           Operator = "<"
+	OpLessOrEqual    Operator = "<="
+	OpGreater        Operator = ">"
+	OpGreaterOrEqual Operator = ">="
+	OpContains       Operator = "~"
+	OpNotContains    Operator = "!~"
+)
+
+// operators is ordered so that longer operators are matched first when parsing
+var operators = []Operator{OpEqual, OpNotEqual, OpLessOrEqual, OpGreaterOrEqual, OpNotContains, OpLess, OpGreater, OpContains}
+
+type compareExpr struct {
+	field string
+	op    Operator
+	value Value
+}
+
+// Compare renders a comparison between a field and a literal
+func Compare(field string, op Operator, value Value) Expr {
+	return compareExpr{field: field, op: op, value: value}
+}
+
+func (c compareExpr) dql() string {
+	switch c.op {
+	case OpContains:
+		return Contains(c.field, c.value.str, false).dql()
+	case OpNotContains:
+		return Not(Contains(c.field, c.value.str, false)).dql()
+	}
+	return fmt.Sprintf("%s %s %s", dqlField(c.field), c.op, c.value.dql())
+}
+
+// ParseFilter parses a filter of the form "field op value", e.g.
+// `loglevel == "ERROR"`, `content ~ timeout` or `http.status >= 500`.
+// Unquoted numeric values are rendered as numbers, everything else as a string literal.
+func ParseFilter(filter string) (Expr, error) {
+	idx, op := -1, Operator("")
+	for _, candidate := range operators {
+		i := strings.Index(filter, string(candidate))
+		if i < 0 {
+			continue
+		}
+		// Prefer the leftmost operator, and the longest one at that position
+		if idx < 0 || i < idx || (i == idx && len(candidate) > len(op)) {
+			idx, op = i, candidate
+		}
+	}
+	if idx < 0 {
+		return nil, fmt.Errorf("invalid filter %q: expected 'field op value' with op one of %s", filter, operatorList())
+	}
+
+	field := strings.TrimSpace(filter[:idx])
+	raw := strings.TrimSpace(filter[idx+len(op):])
+	if field == "" || raw == "" {
+		return nil, fmt.Errorf("invalid filter %q: field and value are required", filter)
+	}
+	if strings.ContainsAny(field, "`\"' \t\n") {
+		return nil, fmt.Errorf("invalid filter %q: '%s' is not a valid field name", filter, field)
+	}
+
+	var value Value
+	if unquoted, err := strconv.Unquote(raw); err == nil && strings.HasPrefix(raw, `"`) {
+		value = StringValue(unquoted)
+	} else if n, err := strconv.ParseFloat(raw, 64); err == nil && op != OpContains && op != OpNotContains {
+		value = NumberValue(n)
+	} else {
+		value = StringValue(raw)
+	}
+
+	return Compare(field, op, value), nil
+}
+
+func operatorList() string {
+	ops := make([]string, len(operators))
+	for i, op := range operators {
+		ops[i] = string(op)
+	}
+	return strings.Join(ops, ", ")
+}
+
+// command is a single pipeline stage of a DQL query
+type command interface {
+	dql() string
+}
+
+type fetchCmd struct {
+	source string
+	from   string
+	to     string
+}
+
+func (f fetchCmd) dql() string {
+	s := "fetch " + f.source
+	if f.from != "" {
+		s += ", from:" + f.from
+	}
+	if f.to != "" {
+		s += ", to:" + f.to
+	}
+	return s
+}
+
+type timeseriesCmd struct {
+	aggregation Aggregation
+	by          []string
+	filter      Expr
+	from        string
+	interval    string
+}
+
+func (t timeseriesCmd) dql() string {
+	s := "timeseries " + t.aggregation.dql()
+	if len(t.by) > 0 {
+		s += ", by:{" + fieldList(t.by) + "}"
+	}
+	if t.filter != nil {
+		s += ", filter:{" + t.filter.dql() + "}"
+	}
+	if t.from != "" {
+		s += ", from:" + t.from
+	}
+	if t.interval != "" {
+		s += ", interval:" + t.interval
+	}
+	return s
+}
+
+type filterCmd struct {
+	expr Expr
+}
+
+func (f filterCmd) dql() string {
+	return "filter " + f.expr.dql()
+}
+
+type fieldsCmd []string
+
+func (f fieldsCmd) dql() string {
+	return "fields " + fieldList(f)
+}
+
+func fieldList(names []string) string {
+	fields := make([]string, len(names))
+	for i, name := range names {
+		fields[i] = dqlField(name)
+	}
+	return strings.Join(fields, ", ")
+}
+
+// Aggregation is a single aggregation of a summarize command, e.g. count() or avg(duration)
+type Aggregation struct {
+	Alias    string
+	Function string
+	Field    string
+}
+
+func (a Aggregation) dql() string {
+	arg := ""
+	if a.Field != "" {
+		arg = dqlField(a.Field)
+	}
+	s := fmt.Sprintf("%s(%s)", a.Function, arg)
+	if a.Alias != "" {
+		s = dqlField(a.Alias) + " = " + s
+	}
+	return s
+}
+
+type summarizeCmd struct {
+	aggregations []Aggregation
+	by           []string
+}
+
+func (s summarizeCmd) dql() string {
+	aggs := make([]string, len(s.aggregations))
+	for i, a := range s.aggregations {
+		aggs[i] = a.dql()
+	}
+	out := "summarize " + strings.Join(aggs, ", ")
+	if len(s.by) > 0 {
+		out += ", by:{" + fieldList(s.by) + "}"
+	}
+	return out
+}
+
+type sortCmd struct {
+	field string
+	order string
+}
+
+func (s sortCmd) dql() string {
+	return fmt.Sprintf("sort %s %s", dqlField(s.field), s.order)
+}
+
+type limitCmd int
+
+func (l limitCmd) dql() string {
+	return fmt.Sprintf("limit %d", int(l))
+}
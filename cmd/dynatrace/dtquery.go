@@ -8,134 +8,156 @@ import (
 
 const timeFormat = "2006-01-02T15:04:05Z"
 
+// DTQuery builds a DQL query out of a fetch command, a single filter command
+// that ANDs every added filter expression, and any following pipeline commands.
 type DTQuery struct {
-	fragments  []string
+	fetch      command
+	filters    []Expr
+	pipeline   []command
+	raw        string
 	finalQuery string
 }
 
-func (q *DTQuery) InitLogs(hours int) *DTQuery {
-	q.fragments = []string{}
-
-	q.fragments = append(q.fragments, fmt.Sprintf("fetch logs, from:now()-%dh \n| filter matchesValue(event.type, \"LOG\") and ", hours))
+func (q *DTQuery) reset(fetch command) *DTQuery {
+	q.fetch = fetch
+	q.filters = []Expr{}
+	q.pipeline = []command{}
+	q.raw = ""
 
 	return q
 }
 
-func (q *DTQuery) InitLogsWithTimeRange(from time.Time, to time.Time) *DTQuery {
-	q.fragments = []string{}
+func (q *DTQuery) InitLogs(hours int) *DTQuery {
+	q.reset(fetchCmd{source: "logs", from: fmt.Sprintf("now()-%dh", hours)})
+	q.filters = append(q.filters, MatchesValue("event.type", "LOG"))
 
-	fromStr := from.Format(timeFormat)
-	toStr := to.Format(timeFormat)
+	return q
+}
 
-	q.fragments = append(q.fragments, fmt.Sprintf("fetch logs, from:\"%s\", to:\"%s\" \n| filter matchesValue(event.type, \"LOG\") and ", fromStr, toStr))
+func (q *DTQuery) InitLogsWithTimeRange(from time.Time, to time.Time) *DTQuery {
+	q.reset(fetchCmd{source: "logs", from: dqlString(from.UTC().Format(timeFormat)), to: dqlString(to.UTC().Format(timeFormat))})
+	q.filters = append(q.filters, MatchesValue("event.type", "LOG"))
 
 	return q
 }
 
 func (q *DTQuery) InitEvents(hours int) *DTQuery {
-	q.fragments = []string{}
-
-	q.fragments = append(q.fragments, fmt.Sprintf("fetch events, from:now()-%dh \n| filter ", hours))
+	q.reset(fetchCmd{source: "events", from: fmt.Sprintf("now()-%dh", hours)})
 
 	return q
 }
 
-func (q *DTQuery) Cluster(mgmtClusterName string) *DTQuery {
-	q.fragments = append(q.fragments, fmt.Sprintf("matchesPhrase(dt.kubernetes.cluster.name, \"%s\")", mgmtClusterName))
+func (q *DTQuery) InitEventsWithTimeRange(from time.Time, to time.Time) *DTQuery {
+	q.reset(fetchCmd{source: "events", from: dqlString(from.UTC().Format(timeFormat)), to: dqlString(to.UTC().Format(timeFormat))})
 
 	return q
 }
 
-func (q *DTQuery) Namespaces(namespaceList []string) *DTQuery {
-	var nsQuery string
-	finalQuery := ""
-	nsQuery = " and ("
-
-	for i, ns := range namespaceList {
-		nsQuery += fmt.Sprintf("matchesValue(k8s.namespace.name, \"%s\")", ns)
-		if i < len(namespaceList)-1 {
-			nsQuery += " or "
-		}
-	}
-	nsQuery += ")"
-	finalQuery += nsQuery
+// InitProblems fetches Davis problems
+func (q *DTQuery) InitProblems(hours int) *DTQuery {
+	q.reset(fetchCmd{source: "dt.davis.problems", from: fmt.Sprintf("now()-%dh", hours)})
+
+	return q
+}
 
-	q.fragments = append(q.fragments, finalQuery)
+// InitTimeseries starts a timeseries query for a single aggregated metric.
+// Filters added to the query are applied to the metric dimensions.
+func (q *DTQuery) InitTimeseries(aggregation Aggregation, hours int, interval string, by ...string) *DTQuery {
+	q.reset(timeseriesCmd{aggregation: aggregation, by: by, from: fmt.Sprintf("now()-%dh", hours), interval: interval})
 
 	return q
 }
 
-func (q *DTQuery) Nodes(nodeList []string) *DTQuery {
-	var nodeQuery string
+// Raw replaces the whole query with a user supplied DQL statement
+func (q *DTQuery) Raw(dql string) *DTQuery {
+	q.reset(fetchCmd{})
+	q.raw = strings.TrimSpace(dql)
 
-	nodeQuery = " and ("
-	for i, node := range nodeList {
-		nodeQuery += fmt.Sprintf("matchesValue(k8s.node.name, \"%s\")", node)
-		if i < len(nodeList)-1 {
-			nodeQuery += " or "
-		}
+	return q
+}
+
+// Filter adds an arbitrary expression to the filter command
+func (q *DTQuery) Filter(expr Expr) *DTQuery {
+	if expr != nil {
+		q.filters = append(q.filters, expr)
 	}
-	nodeQuery += ")"
-	q.fragments = append(q.fragments, nodeQuery)
 
 	return q
 }
 
-func (q *DTQuery) Pods(podList []string) *DTQuery {
-	var podQuery string
-
-	podQuery = " and ("
-	for i, pod := range podList {
-		podQuery += fmt.Sprintf("matchesValue(k8s.pod.name, \"%s\")", pod)
-		if i < len(podList)-1 {
-			podQuery += " or "
+// Filters parses and adds a list of "field op value" filters, see ParseFilter
+func (q *DTQuery) Filters(filters []string) (*DTQuery, error) {
+	for _, f := range filters {
+		expr, err := ParseFilter(f)
+		if err != nil {
+			return q, err
 		}
+		q.Filter(expr)
 	}
-	podQuery += ")"
-	q.fragments = append(q.fragments, podQuery)
 
-	return q
+	return q, nil
 }
 
-func (q *DTQuery) Containers(containerList []string) *DTQuery {
-	var containerQuery string
+func (q *DTQuery) Cluster(mgmtClusterName string) *DTQuery {
+	return q.Filter(MatchesPhrase("dt.kubernetes.cluster.name", mgmtClusterName))
+}
 
-	containerQuery = " and ("
-	for i, container := range containerList {
-		containerQuery += fmt.Sprintf("matchesValue(k8s.container.name, \"%s\")", container)
-		if i < len(containerList)-1 {
-			containerQuery += " or "
-		}
+func (q *DTQuery) Namespaces(namespaceList []string) *DTQuery {
+	return q.Filter(AnyValue("k8s.namespace.name", namespaceList))
+}
+
+func (q *DTQuery) Nodes(nodeList []string) *DTQuery {
+	return q.Filter(AnyValue("k8s.node.name", nodeList))
+}
+
+func (q *DTQuery) Pods(podList []string) *DTQuery {
+	return q.Filter(AnyValue("k8s.pod.name", podList))
+}
+
+// ExcludePods filters out logs of any of the given pods
+func (q *DTQuery) ExcludePods(podList []string) *DTQuery {
+	if len(podList) == 0 {
+		return q
 	}
-	containerQuery += ")"
-	q.fragments = append(q.fragments, containerQuery)
+	return q.Filter(Not(AnyValue("k8s.pod.name", podList)))
+}
 
-	return q
+func (q *DTQuery) Containers(containerList []string) *DTQuery {
+	return q.Filter(AnyValue("k8s.container.name", containerList))
 }
 
 func (q *DTQuery) Status(statusList []string) *DTQuery {
-	var statusQuery string
+	return q.Filter(AnyValue("status", statusList))
+}
 
-	statusQuery = " and ("
-	for i, status := range statusList {
-		statusQuery += fmt.Sprintf("matchesValue(status, \"%s\")", status)
-		if i < len(statusList)-1 {
-			statusQuery += " or "
-		}
-	}
-	statusQuery += ")"
-	q.fragments = append(q.fragments, statusQuery)
+func (q *DTQuery) ContainsPhrase(phrase string) *DTQuery {
+	return q.Filter(Contains("content", phrase, false))
+}
+
+func (q *DTQuery) Deployments(workloads []string) *DTQuery {
+	return q.Filter(AnyValue("dt.kubernetes.workload.name", workloads))
+}
+
+// Fields restricts the output to the given fields
+func (q *DTQuery) Fields(fields ...string) *DTQuery {
+	q.pipeline = append(q.pipeline, fieldsCmd(fields))
 
 	return q
 }
 
-func (q *DTQuery) ContainsPhrase(phrase string) *DTQuery {
-	q.fragments = append(q.fragments, " and contains(content,\""+phrase+"\", caseSensitive:false)")
+// Summarize aggregates the records, optionally grouped by the given fields
+func (q *DTQuery) Summarize(aggregations []Aggregation, by ...string) *DTQuery {
+	q.pipeline = append(q.pipeline, summarizeCmd{aggregations: aggregations, by: by})
 
 	return q
 }
 
 func (q *DTQuery) Sort(order string) (query *DTQuery, error error) {
+	return q.SortBy("timestamp", order)
+}
+
+// SortBy sorts the records by the given field
+func (q *DTQuery) SortBy(field string, order string) (query *DTQuery, error error) {
 	validOrders := []string{
 		"asc",
 		"desc",
@@ -143,7 +165,7 @@ func (q *DTQuery) Sort(order string) (query *DTQuery, error error) {
 
 	for _, or := range validOrders {
 		if or == order {
-			q.fragments = append(q.fragments, fmt.Sprintf("\n| sort timestamp %s", order))
+			q.pipeline = append(q.pipeline, sortCmd{field: field, order: order})
 			return q, nil
 		}
 	}
@@ -151,30 +173,35 @@ func (q *DTQuery) Sort(order string) (query *DTQuery, error error) {
 	return q, fmt.Errorf("no valid sorting order specified. valid order are %s. given %v", strings.Join(validOrders, ", "), order)
 }
 
-func (q *DTQuery) Deployments(workloads []string) *DTQuery {
-	var deploymentQuery string
-
-	deploymentQuery = " and ("
-	for i, deploy := range workloads {
-		deploymentQuery += fmt.Sprintf("matchesValue(dt.kubernetes.workload.name, \"%s\")", deploy)
-		if i < len(workloads)-1 {
-			deploymentQuery += " or "
-		}
-	}
-	deploymentQuery += ")"
-	q.fragments = append(q.fragments, deploymentQuery)
-
-	return q
-}
-
 func (q *DTQuery) Limit(limit int) *DTQuery {
-	q.fragments = append(q.fragments, "\n| limit "+fmt.Sprint(limit))
+	q.pipeline = append(q.pipeline, limitCmd(limit))
 
 	return q
 }
 
 func (q *DTQuery) Build() string {
-	q.finalQuery = strings.Join(q.fragments[:], "")
+	if q.raw != "" {
+		q.finalQuery = q.raw
+		return q.finalQuery
+	}
+
+	commands := []command{q.fetch}
+	if ts, ok := q.fetch.(timeseriesCmd); ok {
+		// timeseries takes its filter as a parameter instead of a separate command
+		if len(q.filters) > 0 {
+			ts.filter = And(q.filters...)
+		}
+		commands[0] = ts
+	} else if len(q.filters) > 0 {
+		commands = append(commands, filterCmd{expr: And(q.filters...)})
+	}
+	commands = append(commands, q.pipeline...)
+
+	lines := make([]string, len(commands))
+	for i, c := range commands {
+		lines[i] = c.dql()
+	}
+	q.finalQuery = strings.Join(lines, "\n| ")
 
 	return q.finalQuery
 }
@@ -1,64 +1,97 @@
 package dynatrace
 
 import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
 
-func TestDTQuery_InitLogs(t *testing.T) {
-	q := new(DTQuery).InitLogs(2)
-	expected := `fetch logs, from:now()-2h 
-| filter matchesValue(event.type, "LOG") and `
-	if q.fragments[0] != expected {
-		t.Errorf("expected: %s\ngot: %s", expected, q.fragments[0])
-	}
-}
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata/dql")
 
-func TestDTQuery_InitEvents(t *testing.T) {
-	q := new(DTQuery).InitEvents(4)
-	expected := `fetch events, from:now()-4h 
-| filter `
-	if q.fragments[0] != expected {
-		t.Errorf("expected: %s\ngot: %s", expected, q.fragments[0])
+// assertGolden compares the query against testdata/dql/<name>.dql.
+// Run `go test ./cmd/dynatrace/ -update` to regenerate the files.
+func assertGolden(t *testing.T, name string, actual string) {
+	t.Helper()
+	path := filepath.Join("testdata", "dql", name+".dql")
+
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatalf("failed to create golden dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(actual+"\n"), 0600); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+		return
 	}
-}
 
-func TestDTQuery_Cluster(t *testing.T) {
-	q := new(DTQuery).InitLogs(1).Cluster("test-cluster")
-	expected := `matchesPhrase(dt.kubernetes.cluster.name, "test-cluster")`
-	if q.fragments[1] != expected {
-		t.Errorf("expected: %s\ngot: %s", expected, q.fragments[1])
+	expected, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		t.Fatalf("failed to read golden file %s: %v", path, err)
+	}
+	if strings.TrimSuffix(string(expected), "\n") != actual {
+		t.Errorf("query does not match %s\nexpected:\n%s\ngot:\n%s", path, expected, actual)
 	}
 }
 
-func TestDTQuery_Namespaces(t *testing.T) {
+func TestDTQuery_Golden(t *testing.T) {
 	tests := []struct {
-		name     string
-		input    []string
-		expected string
+		name  string
+		query func() *DTQuery
 	}{
-		{"Single namespace", []string{"ns1"}, ` and (matchesValue(k8s.namespace.name, "ns1"))`},
-		{"Two namespaces", []string{"ns1", "ns2"}, ` and (matchesValue(k8s.namespace.name, "ns1") or matchesValue(k8s.namespace.name, "ns2"))`},
+		{"init_logs", func() *DTQuery { return new(DTQuery).InitLogs(2) }},
+		{"init_events", func() *DTQuery { return new(DTQuery).InitEvents(4) }},
+		{"init_logs_time_range", func() *DTQuery {
+			return new(DTQuery).InitLogsWithTimeRange(time.Date(2025, 6, 12, 5, 0, 0, 0, time.UTC), time.Date(2025, 6, 17, 15, 0, 0, 0, time.UTC))
+		}},
+		{"init_events_time_range", func() *DTQuery {
+			return new(DTQuery).InitEventsWithTimeRange(time.Date(2025, 6, 12, 5, 0, 0, 0, time.UTC), time.Date(2025, 6, 17, 15, 0, 0, 0, time.UTC))
+		}},
+		{"cluster", func() *DTQuery { return new(DTQuery).InitLogs(1).Cluster("test-cluster") }},
+		{"namespaces_single", func() *DTQuery { return new(DTQuery).InitLogs(1).Namespaces([]string{"ns1"}) }},
+		{"namespaces_multiple", func() *DTQuery { return new(DTQuery).InitLogs(1).Namespaces([]string{"ns1", "ns2"}) }},
+		{"nodes", func() *DTQuery { return new(DTQuery).InitLogs(1).Nodes([]string{"node1", "node2"}) }},
+		{"containers", func() *DTQuery { return new(DTQuery).InitLogs(1).Containers([]string{"container1", "container2"}) }},
+		{"status", func() *DTQuery { return new(DTQuery).InitLogs(1).Status([]string{"ERROR", "INFO"}) }},
+		{"deployments", func() *DTQuery { return new(DTQuery).InitEvents(1).Deployments([]string{"api-deploy", "web-deploy"}) }},
+		{"exclude_pods", func() *DTQuery { return new(DTQuery).InitLogs(1).ExcludePods([]string{"pod-a", "pod-b"}) }},
+		{"contains_phrase", func() *DTQuery { return new(DTQuery).InitLogs(1).ContainsPhrase("error") }},
+		{"limit", func() *DTQuery { return new(DTQuery).InitLogs(1).Limit(50) }},
+		{"escaping", func() *DTQuery {
+			return new(DTQuery).InitLogs(1).
+				Namespaces([]string{`ns") or true or ("`}).
+				ContainsPhrase("back\\slash \"quoted\"\nnewline")
+		}},
+		{"fields_summarize", func() *DTQuery {
+			return new(DTQuery).InitLogs(3).
+				Cluster("mc-1").
+				Fields("timestamp", "k8s.pod.name", "log level").
+				Summarize([]Aggregation{{Alias: "count", Function: "count"}, {Function: "max", Field: "timestamp"}}, "k8s.pod.name")
+		}},
+		{"full", func() *DTQuery {
+			q := new(DTQuery).
+				InitLogs(1).
+				Cluster("prod-cluster").
+				Namespaces([]string{"ns1"}).
+				ContainsPhrase("fail")
+			_, _ = q.Filters([]string{`loglevel == "ERROR"`, "http.status >= 500", "content !~ healthz"})
+			_, _ = q.Sort("desc")
+			return q.Limit(5)
+		}},
+		{"raw", func() *DTQuery {
+			return new(DTQuery).InitLogs(1).Cluster("ignored").Raw("  fetch logs\n| limit 1\n")
+		}},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			q := new(DTQuery).InitLogs(1).Namespaces(tt.input)
-			if q.fragments[1] != tt.expected {
-				t.Errorf("expected: %s\ngot: %s", tt.expected, q.fragments[1])
-			}
+			assertGolden(t, tt.name, tt.query().Build())
 		})
 	}
 }
 
-func TestDTQuery_ContainsPhrase(t *testing.T) {
-	q := new(DTQuery).InitLogs(1).ContainsPhrase("error")
-	expected := ` and contains(content,"error", caseSensitive:false)`
-	if q.fragments[1] != expected {
-		t.Errorf("expected: %s\ngot: %s", expected, q.fragments[1])
-	}
-}
-
 func TestDTQuery_Sort(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -81,140 +114,68 @@ func TestDTQuery_Sort(t *testing.T) {
 			if !tt.expectError && err != nil {
 				t.Errorf("did not expect error but got: %v", err)
 			}
-			if !tt.expectError && q.fragments[1] != tt.expected {
-				t.Errorf("expected: %s\ngot: %s", tt.expected, q.fragments[1])
+			if !tt.expectError && !strings.HasSuffix(q.Build(), tt.expected) {
+				t.Errorf("expected suffix: %s\ngot: %s", tt.expected, q.Build())
 			}
 		})
 	}
 }
 
-func TestDTQuery_Limit(t *testing.T) {
-	q := new(DTQuery).InitLogs(1).Limit(50)
-	expected := "\n| limit 50"
-	if q.fragments[1] != expected {
-		t.Errorf("expected: %s\ngot: %s", expected, q.fragments[1])
-	}
-}
-
-func TestDTQuery_Build(t *testing.T) {
-	q := new(DTQuery).
-		InitLogs(1).
-		Cluster("prod-cluster").
-		Namespaces([]string{"ns1"}).
-		ContainsPhrase("fail").
-		Limit(5)
-
-	expected := `fetch logs, from:now()-1h 
-| filter matchesValue(event.type, "LOG") and matchesPhrase(dt.kubernetes.cluster.name, "prod-cluster") and (matchesValue(k8s.namespace.name, "ns1")) and contains(content,"fail", caseSensitive:false)` +
-		"\n| limit 5"
-
-	actual := q.Build()
-	if actual != expected {
-		t.Errorf("expected: %s\ngot: %s", expected, actual)
-	}
-}
-
-func TestDTQuery_Nodes(t *testing.T) {
+func TestDQLString(t *testing.T) {
 	tests := []struct {
-		name     string
-		input    []string
+		input    string
 		expected string
 	}{
-		{"Single node", []string{"node1"}, ` and (matchesValue(k8s.node.name, "node1"))`},
-		{"Multiple nodes", []string{"node1", "node2"}, ` and (matchesValue(k8s.node.name, "node1") or matchesValue(k8s.node.name, "node2"))`},
+		{"plain", `"plain"`},
+		{`with "quotes"`, `"with \"quotes\""`},
+		{`back\slash`, `"back\\slash"`},
+		{"multi\nline\ttab\r", `"multi\nline\ttab\r"`},
 	}
 
 	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			q := new(DTQuery).InitLogs(1).Nodes(tt.input)
-			if q.fragments[1] != tt.expected {
-				t.Errorf("expected: %s\ngot: %s", tt.expected, q.fragments[1])
+		t.Run(tt.input, func(t *testing.T) {
+			if actual := dqlString(tt.input); actual != tt.expected {
+				t.Errorf("expected: %s\ngot: %s", tt.expected, actual)
 			}
 		})
 	}
 }
 
-func TestDTQuery_Containers(t *testing.T) {
+func TestParseFilter(t *testing.T) {
 	tests := []struct {
-		name     string
-		input    []string
-		expected string
-	}{
-		{"Single container", []string{"container1"}, ` and (matchesValue(k8s.container.name, "container1"))`},
-		{"Multiple containers", []string{"container1", "container2"}, ` and (matchesValue(k8s.container.name, "container1") or matchesValue(k8s.container.name, "container2"))`},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			q := new(DTQuery).InitLogs(1).Containers(tt.input)
-			if q.fragments[1] != tt.expected {
-				t.Errorf("expected: %s\ngot: %s", tt.expected, q.fragments[1])
-			}
-		})
-	}
-}
-
-func TestDTQuery_Status(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    []string
-		expected string
+		filter      string
+		expected    string
+		expectError bool
 	}{
-		{"Single status", []string{"ERROR"}, ` and (matchesValue(status, "ERROR"))`},
-		{"Multiple statuses", []string{"ERROR", "INFO"}, ` and (matchesValue(status, "ERROR") or matchesValue(status, "INFO"))`},
+		{filter: `loglevel == "ERROR"`, expected: `loglevel == "ERROR"`},
+		{filter: "loglevel==ERROR", expected: `loglevel == "ERROR"`},
+		{filter: "http.status >= 500", expected: "http.status >= 500"},
+		{filter: "http.status<400", expected: "http.status < 400"},
+		{filter: `http.status != "500"`, expected: `http.status != "500"`},
+		{filter: "content ~ connection refused", expected: `contains(content, "connection refused", caseSensitive:false)`},
+		{filter: "content !~ 200", expected: `not contains(content, "200", caseSensitive:false)`},
+		{filter: `content ~ a == b`, expected: `contains(content, "a == b", caseSensitive:false)`},
+		{filter: `k8s.pod.name == x" or true`, expected: `k8s.pod.name == "x\" or true"`},
+		{filter: "no operator", expectError: true},
+		{filter: "== value", expectError: true},
+		{filter: "field ==", expectError: true},
+		{filter: `bad"field == x`, expectError: true},
 	}
 
 	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			q := new(DTQuery).InitLogs(1).Status(tt.input)
-			if q.fragments[1] != tt.expected {
-				t.Errorf("expected: %s\ngot: %s", tt.expected, q.fragments[1])
+		t.Run(tt.filter, func(t *testing.T) {
+			expr, err := ParseFilter(tt.filter)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				}
+				return
 			}
-		})
-	}
-}
-
-func TestDTQuery_Deployments(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    []string
-		expected string
-	}{
-		{"Single deployment", []string{"api-deploy"}, ` and (matchesValue(dt.kubernetes.workload.name, "api-deploy"))`},
-		{"Multiple deployments", []string{"api-deploy", "web-deploy"}, ` and (matchesValue(dt.kubernetes.workload.name, "api-deploy") or matchesValue(dt.kubernetes.workload.name, "web-deploy"))`},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			q := new(DTQuery).InitLogs(1).Deployments(tt.input)
-			if q.fragments[1] != tt.expected {
-				t.Errorf("expected: %s\ngot: %s", tt.expected, q.fragments[1])
+			if err != nil {
+				t.Fatalf("did not expect error but got: %v", err)
 			}
-		})
-	}
-}
-
-func TestDTQuery_InitLogsWithTimeRange(t *testing.T) {
-	tests := []struct {
-		name     string
-		from     time.Time
-		to       time.Time
-		expected string
-	}{
-		{
-			name: "Standard time range",
-			from: time.Date(2025, 6, 12, 5, 0, 0, 0, time.UTC),
-			to:   time.Date(2025, 6, 17, 15, 0, 0, 0, time.UTC),
-			expected: `fetch logs, from:"2025-06-12T05:00:00Z", to:"2025-06-17T15:00:00Z" 
-| filter matchesValue(event.type, "LOG") and `,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			q := new(DTQuery).InitLogsWithTimeRange(tt.from, tt.to)
-			if q.fragments[0] != tt.expected {
-				t.Errorf("expected: %s\ngot: %s", tt.expected, q.fragments[0])
+			if actual := expr.dql(); actual != tt.expected {
+				t.Errorf("expected: %s\ngot: %s", tt.expected, actual)
 			}
 		})
 	}
@@ -0,0 +1,170 @@
+package dynatrace
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	// defaultFollowInterval is how often Dynatrace is polled for new logs in follow mode
+	defaultFollowInterval = 10 * time.Second
+
+	// defaultFollowOverlap is how far back each poll reaches before the end of the
+	// previous window. Dynatrace ingestion is not instant, so records can show up
+	// with a timestamp that lies in a window we already queried.
+	defaultFollowOverlap = 2 * time.Minute
+
+	// defaultFollowMaxFailures is how many polls in a row may fail before follow mode gives up
+	defaultFollowMaxFailures = 5
+)
+
+// logFollower streams log records by repeatedly querying a moving time window.
+// Records already written are remembered by their cursor key until they fall
+// out of the window, so the overlap between windows doesn't print duplicates.
+// A failed poll is retried on the next tick over the same window.
+type logFollower struct {
+	interval    time.Duration
+	overlap     time.Duration
+	maxFailures int
+	prefix      bool
+	out         io.Writer
+	errOut      io.Writer
+
+	// initialQuery returns the query of the first poll, which behaves like --tail and --since
+	initialQuery func() (DTQuery, error)
+	// windowQuery returns the query for every following poll
+	windowQuery func(from time.Time, to time.Time) (DTQuery, error)
+	// fetch runs a query and returns the log records
+	fetch func(ctx context.Context, query string) ([]LogContent, error)
+	now   func() time.Time
+
+	windowEnd time.Time
+	seen      map[string]time.Time
+}
+
+func newLogFollower(out io.Writer, interval time.Duration, prefix bool) *logFollower {
+	return &logFollower{
+		interval:    interval,
+		overlap:     defaultFollowOverlap,
+		maxFailures: defaultFollowMaxFailures,
+		prefix:      prefix,
+		out:         out,
+		errOut:      os.Stderr,
+		now:         time.Now,
+		seen:        map[string]time.Time{},
+	}
+}
+
+// run polls until the context is cancelled or maxFailures polls in a row failed
+func (f *logFollower) run(ctx context.Context) error {
+	if err := f.pollInitial(ctx); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			err := f.poll(ctx)
+			if err == nil {
+				failures = 0
+				continue
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+			failures++
+			if failures >= f.maxFailures {
+				return fmt.Errorf("giving up after %d failed polls in a row: %v", failures, err)
+			}
+			fmt.Fprintf(f.errOut, "Warning: failed to poll Dynatrace (%d/%d), retrying in %s: %v\n", failures, f.maxFailures, f.interval, err)
+		}
+	}
+}
+
+func (f *logFollower) pollInitial(ctx context.Context) error {
+	f.windowEnd = f.now()
+
+	q, err := f.initialQuery()
+	if err != nil {
+		return err
+	}
+	records, err := f.fetch(ctx, q.Build())
+	if err != nil {
+		return err
+	}
+
+	f.emit(records)
+	return nil
+}
+
+// poll queries the window since the end of the last successful poll, the window
+// is left unchanged when the poll fails so the next one covers it again
+func (f *logFollower) poll(ctx context.Context) error {
+	to := f.now()
+	from := f.windowEnd.Add(-f.overlap)
+
+	q, err := f.windowQuery(from, to)
+	if err != nil {
+		return err
+	}
+	records, err := f.fetch(ctx, q.Build())
+	if err != nil {
+		return err
+	}
+
+	f.emit(records)
+	f.windowEnd = to
+
+	// Anything older than the start of the next window can't be returned again
+	nextFrom := to.Add(-f.overlap)
+	for key, ts := range f.seen {
+		if ts.Before(nextFrom) {
+			delete(f.seen, key)
+		}
+	}
+
+	return nil
+}
+
+// emit writes every record not seen before, oldest first
+func (f *logFollower) emit(records []LogContent) {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].time().Before(records[j].time())
+	})
+
+	for _, r := range records {
+		key := r.cursorKey()
+		if _, ok := f.seen[key]; ok {
+			continue
+		}
+		f.seen[key] = r.time()
+
+		if f.prefix && r.PodName != "" {
+			fmt.Fprintf(f.out, "[%s/%s] %s\n", r.PodName, r.Container, r.Content)
+		} else {
+			fmt.Fprintln(f.out, r.Content)
+		}
+	}
+}
+
+func (l LogContent) time() time.Time {
+	ts, err := time.Parse(time.RFC3339Nano, l.Timestamp)
+	if err != nil {
+		return time.Time{}
+	}
+	return ts
+}
+
+func (l LogContent) cursorKey() string {
+	return l.Timestamp + "|" + l.PodName + "|" + l.Container + "|" + l.Content
+}
@@ -0,0 +1,151 @@
+package dynatrace
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogFollower(t *testing.T) {
+	start := time.Date(2025, 6, 12, 5, 0, 0, 0, time.UTC)
+	now := start
+
+	record := func(offset time.Duration, pod string, content string) LogContent {
+		return LogContent{
+			Timestamp: start.Add(offset).Format(time.RFC3339Nano),
+			PodName:   pod,
+			Container: "c",
+			Content:   content,
+		}
+	}
+
+	// Every poll returns the records of the whole window, including ones already printed
+	polls := [][]LogContent{
+		{record(-time.Minute, "kas-0", "two"), record(-2*time.Minute, "kas-0", "one")},
+		{record(-time.Minute, "kas-0", "two"), record(5*time.Second, "etcd-0", "three")},
+		{record(5*time.Second, "etcd-0", "three"), record(12*time.Second, "kas-0", "four"), record(12*time.Second, "kas-1", "four")},
+		{},
+	}
+
+	var windows []string
+	out := &bytes.Buffer{}
+	f := newLogFollower(out, time.Second, true)
+	f.now = func() time.Time { return now }
+	f.initialQuery = func() (DTQuery, error) {
+		return *new(DTQuery).InitLogs(1), nil
+	}
+	f.windowQuery = func(from time.Time, to time.Time) (DTQuery, error) {
+		windows = append(windows, fmt.Sprintf("%s-%s", from.Sub(start), to.Sub(start)))
+		return *new(DTQuery).InitLogsWithTimeRange(from, to), nil
+	}
+	call := 0
+	f.fetch = func(_ context.Context, query string) ([]LogContent, error) {
+		records := polls[call]
+		call++
+		return records, nil
+	}
+
+	if err := f.pollInitial(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 1; i < len(polls); i++ {
+		now = now.Add(10 * time.Second)
+		if err := f.poll(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	expected := "[kas-0/c] one\n[kas-0/c] two\n[etcd-0/c] three\n[kas-0/c] four\n[kas-1/c] four\n"
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+
+	expectedWindows := []string{"-2m0s-10s", "-1m50s-20s", "-1m40s-30s"}
+	if fmt.Sprint(windows) != fmt.Sprint(expectedWindows) {
+		t.Errorf("expected windows %v, got %v", expectedWindows, windows)
+	}
+
+	// Records before the start of the next window are dropped from the cursor
+	for key, ts := range f.seen {
+		if ts.Before(now.Add(-f.overlap)) {
+			t.Errorf("expected %s to be pruned from the cursor", key)
+		}
+	}
+}
+
+func TestLogFollowerRetry(t *testing.T) {
+	start := time.Date(2025, 6, 12, 5, 0, 0, 0, time.UTC)
+	now := start
+
+	// The initial poll succeeds, the next two fail, the fourth succeeds and the remaining ones fail
+	results := []error{nil, errors.New("503 Service Unavailable"), errors.New("503 Service Unavailable"), nil}
+
+	var windows []string
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	f := newLogFollower(out, time.Millisecond, false)
+	f.errOut = errOut
+	f.maxFailures = 3
+	f.now = func() time.Time {
+		now = now.Add(10 * time.Second)
+		return now
+	}
+	f.initialQuery = func() (DTQuery, error) {
+		return *new(DTQuery).InitLogs(1), nil
+	}
+	f.windowQuery = func(from time.Time, to time.Time) (DTQuery, error) {
+		windows = append(windows, fmt.Sprintf("%s-%s", from.Sub(start), to.Sub(start)))
+		return *new(DTQuery).InitLogsWithTimeRange(from, to), nil
+	}
+	call := 0
+	f.fetch = func(_ context.Context, query string) ([]LogContent, error) {
+		defer func() { call++ }()
+		if call < len(results) {
+			return nil, results[call]
+		}
+		return nil, errors.New("403 Forbidden")
+	}
+
+	err := f.run(context.Background())
+	if err == nil || err.Error() != "giving up after 3 failed polls in a row: 403 Forbidden" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A failed poll leaves the window end unchanged, so the next poll queries the same records again
+	expectedWindows := []string{"-1m50s-20s", "-1m50s-30s", "-1m50s-40s", "-1m20s-50s", "-1m20s-1m0s", "-1m20s-1m10s"}
+	if fmt.Sprint(windows) != fmt.Sprint(expectedWindows) {
+		t.Errorf("expected windows %v, got %v", expectedWindows, windows)
+	}
+	if warnings := strings.Count(errOut.String(), "Warning: failed to poll Dynatrace"); warnings != 4 {
+		t.Errorf("expected 4 warnings, got %d:\n%s", warnings, errOut.String())
+	}
+}
+
+func TestLogFollowerCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	f := newLogFollower(&bytes.Buffer{}, time.Millisecond, false)
+	f.errOut = &bytes.Buffer{}
+	f.initialQuery = func() (DTQuery, error) {
+		return *new(DTQuery).InitLogs(1), nil
+	}
+	f.windowQuery = func(from time.Time, to time.Time) (DTQuery, error) {
+		return *new(DTQuery).InitLogsWithTimeRange(from, to), nil
+	}
+	polls := 0
+	f.fetch = func(ctx context.Context, query string) ([]LogContent, error) {
+		polls++
+		if polls == 2 {
+			cancel()
+		}
+		return nil, ctx.Err()
+	}
+
+	// A poll failing because follow mode was interrupted ends it without an error
+	if err := f.run(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
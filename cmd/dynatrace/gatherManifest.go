@@ -0,0 +1,156 @@
+package dynatrace
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const gatherManifestFileName = "manifest.json"
+
+// gatherManifest describes the content of a gather-logs bundle. It is rewritten
+// after every finished query, so an interrupted gather can be resumed by
+// skipping every task that already completed.
+type gatherManifest struct {
+	mutex sync.Mutex
+	path  string
+
+	ClusterID             string                       `json:"clusterID"`
+	ManagementClusterName string                       `json:"managementClusterName"`
+	HCPNamespace          string                       `json:"hcpNamespace"`
+	SinceHours            int                          `json:"sinceHours"`
+	From                  time.Time                    `json:"from"`
+	To                    time.Time                    `json:"to"`
+	Started               time.Time                    `json:"started"`
+	Finished              time.Time                    `json:"finished,omitempty"`
+	MaxPodLogBytes        int64                        `json:"maxPodLogBytes,omitempty"`
+	Tasks                 map[string]*gatherTaskResult `json:"tasks"`
+}
+
+type gatherTaskResult struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name,omitempty"`
+	Query     string `json:"query"`
+	Records   int    `json:"records"`
+	Bytes     int64  `json:"bytes"`
+	Truncated bool   `json:"truncated,omitempty"`
+	Completed bool   `json:"completed"`
+	Error     string `json:"error,omitempty"`
+}
+
+// loadGatherManifest reads the manifest in gatherDir. When resume is false or
+// there is no manifest yet, an empty manifest is returned.
+func loadGatherManifest(gatherDir string, resume bool) (*gatherManifest, error) {
+	m := &gatherManifest{
+		path:  filepath.Join(gatherDir, gatherManifestFileName),
+		Tasks: map[string]*gatherTaskResult{},
+	}
+	if !resume {
+		return m, nil
+	}
+
+	data, err := os.ReadFile(m.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", m.path, err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", m.path, err)
+	}
+	if m.Tasks == nil {
+		m.Tasks = map[string]*gatherTaskResult{}
+	}
+
+	return m, nil
+}
+
+// completed returns whether the task stored under key finished without errors in a previous run
+func (m *gatherManifest) completed(key string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	result, ok := m.Tasks[key]
+	return ok && result.Completed
+}
+
+// record stores the result of a task and persists the manifest
+func (m *gatherManifest) record(key string, result *gatherTaskResult) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.Tasks[key] = result
+	return m.saveLocked()
+}
+
+func (m *gatherManifest) save() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.saveLocked()
+}
+
+func (m *gatherManifest) saveLocked() error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	// Write to a temporary file first so an interrupted write never corrupts the manifest
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return os.Rename(tmp, m.path)
+}
+
+// counts returns the number of completed and failed tasks
+func (m *gatherManifest) counts() (completed int, failed int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, result := range m.Tasks {
+		if result.Completed {
+			completed++
+		} else {
+			failed++
+		}
+	}
+	return completed, failed
+}
+
+// cappedWriter writes up to limit bytes and silently drops the rest.
+// A limit of zero or less means no limit.
+type cappedWriter struct {
+	w         io.Writer
+	limit     int64
+	written   int64
+	truncated bool
+}
+
+func (c *cappedWriter) Write(p []byte) (int, error) {
+	if c.limit > 0 && c.written+int64(len(p)) > c.limit {
+		c.truncated = true
+		remaining := c.limit - c.written
+		if remaining <= 0 {
+			return len(p), nil
+		}
+		n, err := c.w.Write(p[:remaining])
+		c.written += int64(n)
+		if err != nil {
+			return n, err
+		}
+		return len(p), nil
+	}
+
+	n, err := c.w.Write(p)
+	c.written += int64(n)
+	return n, err
+}
@@ -0,0 +1,135 @@
+package dynatrace
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCappedWriter(t *testing.T) {
+	tests := []struct {
+		name          string
+		limit         int64
+		writes        []string
+		expected      string
+		expectTrimmed bool
+	}{
+		{"no limit", 0, []string{"abc", "def"}, "abcdef", false},
+		{"under limit", 10, []string{"abc", "def"}, "abcdef", false},
+		{"cut in the middle of a write", 4, []string{"abc", "def"}, "abcd", true},
+		{"drops writes after the limit", 3, []string{"abc", "def", "ghi"}, "abc", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			w := &cappedWriter{w: buf, limit: tt.limit}
+			for _, s := range tt.writes {
+				n, err := w.Write([]byte(s))
+				if err != nil || n != len(s) {
+					t.Fatalf("expected full write without error, got %d, %v", n, err)
+				}
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, buf.String())
+			}
+			if w.truncated != tt.expectTrimmed {
+				t.Errorf("expected truncated %v, got %v", tt.expectTrimmed, w.truncated)
+			}
+		})
+	}
+}
+
+// newFakeDynatrace serves the query execute and poll endpoints, returning one log record per query
+func newFakeDynatrace(t *testing.T, executions *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "query:execute"):
+			executions.Add(1)
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = w.Write([]byte(`{"state":"RUNNING","requestToken":"token"}`))
+		case strings.HasSuffix(r.URL.Path, "query:poll"):
+			_, _ = w.Write([]byte(`{"state":"SUCCEEDED","result":{"records":[{"content":"a log line that is long"}]}}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestGatherRunTasksResume(t *testing.T) {
+	var executions atomic.Int32
+	server := newFakeDynatrace(t, &executions)
+	defer server.Close()
+
+	dir := t.TempDir()
+	tasks := []gatherTask{
+		{key: "ns/pods/a/pod.log", kind: gatherKindPodLogs, namespace: "ns", name: "a", path: filepath.Join(dir, "a.log"), query: *new(DTQuery).InitLogs(1)},
+		{key: "ns/pods/b/pod.log", kind: gatherKindPodLogs, namespace: "ns", name: "b", path: filepath.Join(dir, "b.log"), query: *new(DTQuery).InitLogs(1)},
+		{key: "ns/events/d/events.log", kind: gatherKindEvents, namespace: "ns", name: "d", path: filepath.Join(dir, "events.log"), query: *new(DTQuery).InitEvents(1)},
+	}
+
+	manifest, err := loadGatherManifest(dir, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	manifest.MaxPodLogBytes = 10
+	// Pretend pod a was already gathered by a previous run
+	if err := manifest.record("ns/pods/a/pod.log", &gatherTaskResult{Kind: gatherKindPodLogs, Completed: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	g := &GatherLogsOpts{Concurrency: 2}
+	if err := g.runTasks(tasks, manifest, server.URL+"/", func() (string, error) { return "access-token", nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if executions.Load() != 2 {
+		t.Errorf("expected 2 queries to run, got %d", executions.Load())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.log")); !os.IsNotExist(err) {
+		t.Errorf("expected the completed task to be skipped")
+	}
+
+	podLog, err := os.ReadFile(filepath.Join(dir, "b.log"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(podLog) != "a log line" {
+		t.Errorf("expected the pod log to be capped, got %q", podLog)
+	}
+
+	// The manifest on disk has every task
+	reloaded, err := loadGatherManifest(dir, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b := reloaded.Tasks["ns/pods/b/pod.log"]
+	if b == nil || !b.Completed || b.Records != 1 || b.Bytes != 10 || !b.Truncated {
+		out, _ := json.Marshal(b)
+		t.Errorf("unexpected manifest entry for pod b: %s", out)
+	}
+	events := reloaded.Tasks["ns/events/d/events.log"]
+	if events == nil || !events.Completed || events.Truncated {
+		out, _ := json.Marshal(events)
+		t.Errorf("unexpected manifest entry for events: %s", out)
+	}
+	if completed, failed := reloaded.counts(); completed != 3 || failed != 0 {
+		t.Errorf("expected 3 completed and 0 failed tasks, got %d and %d", completed, failed)
+	}
+
+	// Without --resume an existing manifest is ignored
+	fresh, err := loadGatherManifest(dir, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fresh.Tasks) != 0 {
+		t.Errorf("expected an empty manifest, got %d tasks", len(fresh.Tasks))
+	}
+}
@@ -6,10 +6,13 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/openshift/osdctl/cmd/common"
+	"github.com/openshift/osdctl/internal/utils"
 	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
 	"gopkg.in/yaml.v2"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -19,11 +22,15 @@ import (
 )
 
 type GatherLogsOpts struct {
-	Since     int
-	Tail      int
-	SortOrder string
-	DestDir   string
-	ClusterID string
+	Since        int
+	Tail         int
+	SortOrder    string
+	DestDir      string
+	ClusterID    string
+	Concurrency  int
+	Compress     bool
+	Resume       bool
+	MaxPodLogMiB int
 }
 
 func NewCmdHCPMustGather() *cobra.Command {
@@ -36,11 +43,20 @@ func NewCmdHCPMustGather() *cobra.Command {
 		Long: `Gathers pods logs and evnets of a given HCP from Dynatrace.
 
   This command fetches the logs from the HCP namespace, the hypershift namespace and cert-manager related namespaces.
-  Logs will be dumped to a directory with prefix hcp-must-gather.
+  Logs will be dumped to a directory with prefix hcp-logs-dump, which is then compressed into a .tar.gz bundle.
+  The bundle contains a manifest.json listing every query, the time range, record counts and errors.
+
+  Queries run in parallel, bounded by --concurrency. An interrupted gather can be continued with --resume,
+  which skips every query already recorded as completed in the manifest of the destination directory and queries
+  the time range recorded in the manifest, so --since must match the resumed gather. Without --resume the logs
+  directory must not exist yet.
 		`,
 		Example: `
   # Gather logs for a HCP cluster with cluster id hcp-cluster-id-123
-  osdctl dt gather-logs --cluster-id hcp-cluster-id-123`,
+  osdctl dt gather-logs --cluster-id hcp-cluster-id-123
+
+  # Resume an interrupted gather, running 8 queries at a time and capping each pod log at 50MiB
+  osdctl dt gather-logs --cluster-id hcp-cluster-id-123 --resume --concurrency 8 --max-pod-log-size 50`,
 		DisableAutoGenTag: true,
 		Run: func(cmd *cobra.Command, args []string) {
 
@@ -56,6 +72,10 @@ func NewCmdHCPMustGather() *cobra.Command {
 	hcpMgCmd.Flags().StringVar(&g.SortOrder, "sort", "asc", "Sort the results by timestamp in either ascending or descending order. Accepted values are 'asc' and 'desc'")
 	hcpMgCmd.Flags().StringVar(&g.DestDir, "dest-dir", "", "Destination directory for the logs dump, defaults to the local directory.")
 	hcpMgCmd.Flags().StringVarP(&g.ClusterID, "cluster-id", "C", "", "Internal ID of the HCP cluster to gather logs from (required)")
+	hcpMgCmd.Flags().IntVar(&g.Concurrency, "concurrency", defaultGatherConcurrency, "Number of Dynatrace queries to run in parallel")
+	hcpMgCmd.Flags().BoolVar(&g.Compress, "compress", true, "Create a .tar.gz bundle of the logs dump")
+	hcpMgCmd.Flags().BoolVar(&g.Resume, "resume", false, "Resume a previous gather in the destination directory, skipping completed queries")
+	hcpMgCmd.Flags().IntVar(&g.MaxPodLogMiB, "max-pod-log-size", 0, "Maximum size of the logs written per pod in MiB, 0 means no limit")
 
 	_ = hcpMgCmd.MarkFlagRequired("cluster-id")
 
@@ -63,8 +83,9 @@ func NewCmdHCPMustGather() *cobra.Command {
 }
 
 func (g *GatherLogsOpts) GatherLogs(clusterID string, elevationReasons ...string) (error error) {
-	accessToken, err := getStorageAccessToken()
-	if err != nil {
+	// Fail early when there are no credentials, the gather can take longer than a token is valid
+	// so every query asks for the (cached) token again
+	if _, err := getStorageAccessToken(); err != nil {
 		return fmt.Errorf("failed to acquire access token %v", err)
 	}
 
@@ -82,203 +103,314 @@ func (g *GatherLogsOpts) GatherLogs(clusterID string, elevationReasons ...string
 
 	gatherNamespaces := []string{hcpCluster.hcpNamespace, hcpCluster.klusterletNS, hcpCluster.hostedNS, "hypershift", "cert-manager", "redhat-cert-manager-operator", "open-cluster-management-agent", "open-cluster-management-agent-addon"}
 
-	gatherDir, err := setupGatherDir(g.DestDir, hcpCluster.hcpNamespace)
+	gatherDir, err := setupGatherDir(g.DestDir, hcpCluster.hcpNamespace, g.Resume)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := loadGatherManifest(gatherDir, g.Resume)
 	if err != nil {
 		return err
 	}
+	if manifest.Started.IsZero() {
+		now := time.Now().UTC()
+		manifest.ClusterID = clusterID
+		manifest.ManagementClusterName = hcpCluster.managementClusterName
+		manifest.HCPNamespace = hcpCluster.hcpNamespace
+		manifest.SinceHours = g.Since
+		manifest.From = now.Add(-time.Duration(g.Since) * time.Hour)
+		manifest.To = now
+		manifest.Started = now
+	} else if manifest.SinceHours != g.Since {
+		return fmt.Errorf("the gather to resume covers %d hours, run it again with --since %d", manifest.SinceHours, manifest.SinceHours)
+	}
+	manifest.MaxPodLogBytes = int64(g.MaxPodLogMiB) * 1024 * 1024
 
+	var tasks []gatherTask
 	for _, gatherNS := range gatherNamespaces {
-		fmt.Printf("Gathering for %s\n", gatherNS)
+		fmt.Printf("Listing workloads in %s\n", gatherNS)
 
 		pods, err := getPodsForNamespace(clientset, gatherNS)
 		if err != nil {
 			return err
 		}
 
+		deployments, err := getDeploymentsForNamespace(clientset, gatherNS)
+		if err != nil {
+			return err
+		}
+
 		nsDir, err := addDir([]string{gatherDir, gatherNS}, []string{})
 		if err != nil {
 			return err
 		}
 
-		err = g.dumpPodLogs(pods, nsDir, gatherNS, hcpCluster.managementClusterName, hcpCluster.DynatraceURL, accessToken, g.Since, g.Tail, g.SortOrder)
+		nsTasks, err := g.podLogTasks(pods, nsDir, gatherNS, hcpCluster.managementClusterName, manifest.From, manifest.To)
 		if err != nil {
 			return err
 		}
+		tasks = append(tasks, nsTasks...)
 
-		deployments, err := getDeploymentsForNamespace(clientset, gatherNS)
+		nsTasks, err = g.eventTasks(deployments, nsDir, gatherNS, hcpCluster.managementClusterName, manifest.From, manifest.To)
 		if err != nil {
 			return err
 		}
+		tasks = append(tasks, nsTasks...)
 
-		err = g.dumpEvents(deployments, nsDir, gatherNS, hcpCluster.managementClusterName, hcpCluster.DynatraceURL, accessToken, g.Since, g.Tail, g.SortOrder)
+		restartedTask, err := g.restartedPodLogsTask(pods, nsDir, gatherNS, hcpCluster.managementClusterName, manifest.From, manifest.To)
 		if err != nil {
 			return err
 		}
+		tasks = append(tasks, restartedTask)
+	}
 
-		err = g.dumpRestartedPodLogs(pods, nsDir, gatherNS, hcpCluster.managementClusterName, hcpCluster.DynatraceURL, accessToken)
+	// Make paths in the manifest relative to the bundle
+	for i := range tasks {
+		tasks[i].key, err = filepath.Rel(gatherDir, tasks[i].path)
 		if err != nil {
 			return err
 		}
+	}
+
+	err = g.runTasks(tasks, manifest, hcpCluster.DynatraceURL, getStorageAccessToken)
+	if err != nil {
+		return err
+	}
+
+	manifest.Finished = time.Now().UTC()
+	if err := manifest.save(); err != nil {
+		return err
+	}
 
+	completed, failed := manifest.counts()
+	fmt.Printf("Gathered %d queries into %s, %d failed (see %s)\n", completed, gatherDir, failed, gatherManifestFileName)
+
+	if g.Compress {
+		bundlePath := gatherDir + ".tar.gz"
+		if err := utils.CreateTarball(gatherDir, bundlePath); err != nil {
+			return fmt.Errorf("failed to create bundle: %w", err)
+		}
+		fmt.Printf("Compressed bundle has been created at %s\n", bundlePath)
 	}
 
 	return nil
 }
 
-func (g *GatherLogsOpts) dumpEvents(deploys *appsv1.DeploymentList, parentDir string, targetNS string, managementClusterName string, DTURL string, accessToken string, since int, tail int, sortOrder string) error {
-	totalDeployments := len(deploys.Items)
-	for k, d := range deploys.Items {
-		fmt.Printf("[%d/%d] Deployment events for %s\n", k+1, totalDeployments, d.Name)
+const (
+	gatherKindPodLogs          = "pod-logs"
+	gatherKindEvents           = "events"
+	gatherKindRestartedPodLogs = "restarted-pod-logs"
 
-		eventQuery, err := getEventQuery(d.Name, targetNS, g.Since, g.Tail, g.SortOrder, managementClusterName)
-		if err != nil {
-			return err
-		}
-		eventQuery.Build()
+	defaultGatherConcurrency = 4
+)
 
-		deploymentYamlFileName := "deployment.yaml"
-		eventsFileName := "events.log"
-		eventsDirPath, err := addDir([]string{parentDir, "events", d.Name}, []string{deploymentYamlFileName, eventsFileName})
-		if err != nil {
-			return err
+// gatherTask is a single Dynatrace query whose result is written to path
+type gatherTask struct {
+	key       string
+	kind      string
+	namespace string
+	name      string
+	path      string
+	query     DTQuery
+}
+
+// runTasks executes the tasks with at most g.Concurrency queries in flight.
+// Failed queries are recorded in the manifest, only failures to write the manifest abort the gather.
+func (g *GatherLogsOpts) runTasks(tasks []gatherTask, manifest *gatherManifest, DTURL string, accessToken func() (string, error)) error {
+	concurrency := g.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultGatherConcurrency
+	}
+
+	var pending []gatherTask
+	for _, t := range tasks {
+		if manifest.completed(t.key) {
+			continue
 		}
+		pending = append(pending, t)
+	}
+	if skipped := len(tasks) - len(pending); skipped > 0 {
+		fmt.Printf("Skipping %d already gathered queries\n", skipped)
+	}
 
-		deploymentYamlPath := filepath.Join(eventsDirPath, deploymentYamlFileName)
-		deploymentYaml, err := yaml.Marshal(d)
+	var done atomic.Int32
+	eg := errgroup.Group{}
+	eg.SetLimit(concurrency)
+	for _, t := range pending {
+		eg.Go(func() error {
+			result := g.runTask(t, DTURL, accessToken, manifest.MaxPodLogBytes)
+			if result.Error != "" {
+				log.Printf("failed to gather %s for %s/%s, continuing: %s. Query: %v", t.kind, t.namespace, t.name, result.Error, result.Query)
+			}
+			fmt.Printf("[%d/%d] %s %s/%s\n", done.Add(1), len(pending), t.kind, t.namespace, t.name)
+
+			return manifest.record(t.key, result)
+		})
+	}
+
+	return eg.Wait()
+}
+
+func (g *GatherLogsOpts) runTask(t gatherTask, DTURL string, getAccessToken func() (string, error), maxBytes int64) *gatherTaskResult {
+	result := &gatherTaskResult{
+		Kind:      t.kind,
+		Namespace: t.namespace,
+		Name:      t.name,
+		Query:     t.query.Build(),
+	}
+
+	f, err := os.Create(t.path)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer f.Close()
+
+	// Events aren't per pod, so they are never capped
+	w := &cappedWriter{w: f}
+	if t.kind != gatherKindEvents {
+		w.limit = maxBytes
+	}
+
+	accessToken, err := getAccessToken()
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to acquire access token: %v", err)
+		return result
+	}
+
+	requestToken, err := getDTQueryExecution(context.Background(), DTURL, accessToken, result.Query)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to get request token: %v", err)
+		return result
+	}
+
+	if t.kind == gatherKindEvents {
+		records, err := getRecords(DTURL, accessToken, requestToken)
 		if err != nil {
-			return fmt.Errorf("failed to marshal YAML: %v", err)
+			result.Error = err.Error()
+			return result
 		}
-		f, err := os.OpenFile(deploymentYamlPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
-		if err != nil {
-			return err
+		for _, record := range records {
+			fmt.Fprintf(w, "%s\n", record)
 		}
-		_, err = f.Write(deploymentYaml)
+		result.Records = len(records)
+	} else {
+		records, err := getLogRecords(context.Background(), DTURL, accessToken, requestToken)
 		if err != nil {
-			return err
+			result.Error = err.Error()
+			return result
+		}
+		for _, record := range records {
+			fmt.Fprintf(w, "%s\n", record.Content)
 		}
-		err = f.Close()
+		result.Records = len(records)
+	}
+
+	result.Bytes = w.written
+	result.Truncated = w.truncated
+	result.Completed = true
+
+	return result
+}
+
+func (g *GatherLogsOpts) eventTasks(deploys *appsv1.DeploymentList, parentDir string, targetNS string, managementClusterName string, from, to time.Time) ([]gatherTask, error) {
+	var tasks []gatherTask
+	for _, d := range deploys.Items {
+		eventQuery, err := getEventQuery(d.Name, targetNS, from, to, g.Tail, g.SortOrder, managementClusterName)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		eventsFilePath := filepath.Join(eventsDirPath, eventsFileName)
-		f, err = os.OpenFile(eventsFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		eventsDirPath, err := addDir([]string{parentDir, "events", d.Name}, []string{})
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		eventsRequestToken, err := getDTQueryExecution(DTURL, accessToken, eventQuery.finalQuery)
+		deploymentYaml, err := yaml.Marshal(d)
 		if err != nil {
-			log.Print("failed to get request token", err)
-			continue
+			return nil, fmt.Errorf("failed to marshal YAML: %v", err)
 		}
-		err = getEvents(DTURL, accessToken, eventsRequestToken, f)
-		_ = f.Close()
+		err = os.WriteFile(filepath.Join(eventsDirPath, "deployment.yaml"), deploymentYaml, 0600)
 		if err != nil {
-			log.Printf("failed to get logs, continuing: %v. Query: %v", err, eventQuery.finalQuery)
-			continue
+			return nil, err
 		}
 
+		tasks = append(tasks, gatherTask{
+			kind:      gatherKindEvents,
+			namespace: targetNS,
+			name:      d.Name,
+			path:      filepath.Join(eventsDirPath, "events.log"),
+			query:     eventQuery,
+		})
 	}
-	return nil
-}
 
-func (g *GatherLogsOpts) dumpPodLogs(pods *corev1.PodList, parentDir string, targetNS string, managementClusterName string, DTURL string, accessToken string, since int, tail int, sortOrder string) error {
-	totalPods := len(pods.Items)
-	for k, p := range pods.Items {
-		fmt.Printf("[%d/%d] Pod logs for %s\n", k+1, totalPods, p.Name)
+	return tasks, nil
+}
 
-		podLogsQuery, err := getPodQuery(p.Name, targetNS, g.Since, g.Tail, g.SortOrder, managementClusterName)
+func (g *GatherLogsOpts) podLogTasks(pods *corev1.PodList, parentDir string, targetNS string, managementClusterName string, from, to time.Time) ([]gatherTask, error) {
+	var tasks []gatherTask
+	for _, p := range pods.Items {
+		podLogsQuery, err := getPodQuery(p.Name, targetNS, from, to, g.Tail, g.SortOrder, managementClusterName)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		podLogsQuery.Build()
 
-		podYamlFileName := "pod.yaml"
-		podLogFileName := "pod.log"
-		podDirPath, err := addDir([]string{parentDir, "pods", p.Name}, []string{podLogFileName, podYamlFileName})
+		podDirPath, err := addDir([]string{parentDir, "pods", p.Name}, []string{})
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		podYamlFilePath := filepath.Join(podDirPath, podYamlFileName)
 		podYaml, err := yaml.Marshal(p)
 		if err != nil {
-			return fmt.Errorf("failed to marshal YAML: %v", err)
-		}
-		f, err := os.OpenFile(podYamlFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
-		if err != nil {
-			return err
-		}
-		_, err = f.Write(podYaml)
-		if err != nil {
-			return err
+			return nil, fmt.Errorf("failed to marshal YAML: %v", err)
 		}
-		_ = f.Close()
-
-		podLogsFilePath := filepath.Join(podDirPath, podLogFileName)
-		f, err = os.OpenFile(podLogsFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		err = os.WriteFile(filepath.Join(podDirPath, "pod.yaml"), podYaml, 0600)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		podLogsRequestToken, err := getDTQueryExecution(DTURL, accessToken, podLogsQuery.finalQuery)
-		if err != nil {
-			log.Print("failed to get request token", err)
-			continue
-		}
-		err = getLogs(DTURL, accessToken, podLogsRequestToken, f)
-		_ = f.Close()
-		if err != nil {
-			log.Printf("failed to get logs, continuing: %v. Query: %v", err, podLogsQuery.finalQuery)
-			continue
-		}
+		tasks = append(tasks, gatherTask{
+			kind:      gatherKindPodLogs,
+			namespace: targetNS,
+			name:      p.Name,
+			path:      filepath.Join(podDirPath, "pod.log"),
+			query:     podLogsQuery,
+		})
 	}
 
-	return nil
+	return tasks, nil
 }
 
-func (g *GatherLogsOpts) dumpRestartedPodLogs(pods *corev1.PodList, parentDir string, targetNS string, managementClusterName string, DTURL string, accessToken string) error {
+func (g *GatherLogsOpts) restartedPodLogsTask(pods *corev1.PodList, parentDir string, targetNS string, managementClusterName string, from, to time.Time) (gatherTask, error) {
 	var podList []string
 	for _, p := range pods.Items {
 		podList = append(podList, p.Name)
 	}
-	fmt.Printf("Collecting Restarted Pod logs for %s\n", targetNS)
 
-	restartedPodLogsQuery, err := getRestartedPodQuery(podList, targetNS, g.Since, g.Tail, g.SortOrder, managementClusterName)
+	restartedPodLogsQuery, err := getRestartedPodQuery(podList, targetNS, from, to, g.Tail, g.SortOrder, managementClusterName)
 	if err != nil {
-		return err
+		return gatherTask{}, err
 	}
-	restartedPodLogsQuery.Build()
 
-	restartedPodLogFileName := "pods.log"
-	podDirPath, err := addDir([]string{parentDir, "restarted-pods"}, []string{restartedPodLogFileName})
+	podDirPath, err := addDir([]string{parentDir, "restarted-pods"}, []string{})
 	if err != nil {
-		return err
+		return gatherTask{}, err
 	}
 
-	restartedPodLogsFilePath := filepath.Join(podDirPath, restartedPodLogFileName)
-	f, err := os.OpenFile(restartedPodLogsFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0655)
-	if err != nil {
-		return err
-	}
-
-	podLogsRequestToken, err := getDTQueryExecution(DTURL, accessToken, restartedPodLogsQuery.finalQuery)
-	if err != nil {
-		log.Print("failed to get request token", err)
-
-	}
-	err = getLogs(DTURL, accessToken, podLogsRequestToken, f)
-	f.Close()
-	if err != nil {
-		log.Printf("failed to get restarted pod logs: %v. Query: %v", err, restartedPodLogsQuery.finalQuery)
-	}
-
-	return nil
+	return gatherTask{
+		kind:      gatherKindRestartedPodLogs,
+		namespace: targetNS,
+		path:      filepath.Join(podDirPath, "pods.log"),
+		query:     restartedPodLogsQuery,
+	}, nil
 }
 
-func setupGatherDir(destBaseDir string, dirName string) (logsDir string, error error) {
+// setupGatherDir creates the logs directory, which must not exist yet unless a previous gather is resumed
+func setupGatherDir(destBaseDir string, dirName string, resume bool) (logsDir string, error error) {
 	dirPath := filepath.Join(destBaseDir, fmt.Sprintf("hcp-logs-dump-%s", dirName))
+	if _, err := os.Stat(dirPath); err == nil && !resume {
+		return "", fmt.Errorf("logs directory %s already exists, use --resume to continue its gather or remove it", dirPath)
+	}
 	err := os.MkdirAll(dirPath, 0750)
 	if err != nil {
 		return "", fmt.Errorf("failed to setup logs directory %v", err)
@@ -304,9 +436,9 @@ func addDir(dirs []string, filePaths []string) (path string, error error) {
 	return dirPath, nil
 }
 
-func getPodQuery(pod string, namespace string, since int, tail int, sortOrder string, srcCluster string) (query DTQuery, error error) {
+func getPodQuery(pod string, namespace string, from, to time.Time, tail int, sortOrder string, srcCluster string) (query DTQuery, error error) {
 	q := DTQuery{}
-	q.InitLogs(since).Cluster(srcCluster)
+	q.InitLogsWithTimeRange(from, to).Cluster(srcCluster)
 
 	if namespace != "" {
 		q.Namespaces([]string{namespace})
@@ -330,22 +462,16 @@ func getPodQuery(pod string, namespace string, since int, tail int, sortOrder st
 	return q, nil
 }
 
-func getRestartedPodQuery(pods []string, namespace string, since int, tail int, sortOrder string, srcCluster string) (query DTQuery, error error) {
+func getRestartedPodQuery(pods []string, namespace string, from, to time.Time, tail int, sortOrder string, srcCluster string) (query DTQuery, error error) {
 	q := DTQuery{}
-	q.InitLogs(since).Cluster(srcCluster)
+	q.InitLogsWithTimeRange(from, to).Cluster(srcCluster)
 
 	if namespace != "" {
 		q.Namespaces([]string{namespace})
 	}
 
 	if len(pods) > 0 {
-		q.Pods(pods)
-		for i := 0; i < len(q.fragments); i++ {
-			if strings.Contains(q.fragments[i], "k8s.pod.name") {
-				q.fragments[i] = strings.Replace(q.fragments[i], "and (", "and not (", 1)
-				break
-			}
-		}
+		q.ExcludePods(pods)
 	}
 
 	if sortOrder != "" {
@@ -362,9 +488,9 @@ func getRestartedPodQuery(pods []string, namespace string, since int, tail int,
 	return q, nil
 }
 
-func getEventQuery(deploy string, namespace string, since int, tail int, sortOrder string, srcCluster string) (query DTQuery, error error) {
+func getEventQuery(deploy string, namespace string, from, to time.Time, tail int, sortOrder string, srcCluster string) (query DTQuery, error error) {
 	q := DTQuery{}
-	q.InitEvents(since).Cluster(srcCluster)
+	q.InitEventsWithTimeRange(from, to).Cluster(srcCluster)
 
 	if namespace != "" {
 		q.Namespaces([]string{namespace})
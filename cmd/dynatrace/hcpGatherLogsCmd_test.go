@@ -6,13 +6,20 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestSetupGatherDir(t *testing.T) {
+	existingBaseDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(existingBaseDir, "hcp-logs-dump-test-logs"), 0750); err != nil {
+		t.Fatal(err)
+	}
+
 	tests := []struct {
 		name        string
 		destBaseDir string
 		dirName     string
+		resume      bool
 		expectError bool
 	}{
 		{
@@ -21,6 +28,19 @@ func TestSetupGatherDir(t *testing.T) {
 			dirName:     "test-logs",
 			expectError: false,
 		},
+		{
+			name:        "existing_directory",
+			destBaseDir: existingBaseDir,
+			dirName:     "test-logs",
+			expectError: true,
+		},
+		{
+			name:        "existing_directory_resumed",
+			destBaseDir: existingBaseDir,
+			dirName:     "test-logs",
+			resume:      true,
+			expectError: false,
+		},
 		{
 			name:        "invalid_base_directory",
 			destBaseDir: "/invalid/path",
@@ -31,7 +51,7 @@ func TestSetupGatherDir(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			logsDir, err := setupGatherDir(tt.destBaseDir, tt.dirName)
+			logsDir, err := setupGatherDir(tt.destBaseDir, tt.dirName, tt.resume)
 
 			if tt.expectError {
 				if err == nil {
@@ -121,6 +141,8 @@ func TestAddDir(t *testing.T) {
 }
 
 func TestGetPodQuery(t *testing.T) {
+	from := time.Date(2025, 6, 12, 5, 0, 0, 0, time.UTC)
+
 	tests := []struct {
 		pod         string
 		namespace   string
@@ -170,7 +192,7 @@ func TestGetPodQuery(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(fmt.Sprintf("%s-%s", tt.pod, tt.namespace), func(t *testing.T) {
-			query, err := getPodQuery(tt.pod, tt.namespace, tt.since, tt.tail, tt.sortOrder, tt.srcCluster)
+			query, err := getPodQuery(tt.pod, tt.namespace, from, from.Add(time.Duration(tt.since)*time.Hour), tt.tail, tt.sortOrder, tt.srcCluster)
 
 			if tt.expectError && err == nil {
 				t.Errorf("expected error but got none for test: %s-%s", tt.pod, tt.namespace)
@@ -192,6 +214,10 @@ func TestGetPodQuery(t *testing.T) {
 				t.Errorf("expected query to contain namespace %s but it does not for test: %s-%s", tt.namespace, tt.pod, tt.namespace)
 			}
 
+			if !strings.Contains(finalQuery, `from:"2025-06-12T05:00:00Z"`) {
+				t.Errorf("expected query to start at the gather time range but got: %s", finalQuery)
+			}
+
 			if finalQuery == "" {
 				t.Errorf("expected non-empty query but got an empty query for test: %s-%s", tt.pod, tt.namespace)
 			}
@@ -200,6 +226,8 @@ func TestGetPodQuery(t *testing.T) {
 }
 
 func TestGetEventQuery(t *testing.T) {
+	from := time.Date(2025, 6, 12, 5, 0, 0, 0, time.UTC)
+
 	tests := []struct {
 		event       string
 		namespace   string
@@ -249,7 +277,7 @@ func TestGetEventQuery(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(fmt.Sprintf("%s-%s", tt.event, tt.namespace), func(t *testing.T) {
-			query, err := getEventQuery(tt.event, tt.namespace, tt.since, tt.tail, tt.sortOrder, tt.srcCluster)
+			query, err := getEventQuery(tt.event, tt.namespace, from, from.Add(time.Duration(tt.since)*time.Hour), tt.tail, tt.sortOrder, tt.srcCluster)
 
 			if tt.expectError && err == nil {
 				t.Errorf("expected error but got none for test: %s-%s", tt.event, tt.namespace)
@@ -271,6 +299,10 @@ func TestGetEventQuery(t *testing.T) {
 				t.Errorf("expected query to contain namespace %s but it does not for test: %s-%s", tt.namespace, tt.event, tt.namespace)
 			}
 
+			if !strings.Contains(finalQuery, `from:"2025-06-12T05:00:00Z"`) {
+				t.Errorf("expected query to start at the gather time range but got: %s", finalQuery)
+			}
+
 			if finalQuery == "" {
 				t.Errorf("expected non-empty query but got an empty query for test: %s-%s", tt.event, tt.namespace)
 			}
@@ -1,9 +1,13 @@
 package dynatrace
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	k8s "github.com/openshift/osdctl/pkg/k8s"
@@ -12,20 +16,24 @@ import (
 )
 
 var (
-	dryRun        bool
-	tail          int
-	since         int
-	fromVar       time.Time
-	toVar         time.Time
-	contains      string
-	sortOrder     string
-	clusterID     string
-	pod           string
-	namespaceList []string
-	nodeList      []string
-	containerList []string
-	statusList    []string
-	console       bool
+	dryRun         bool
+	tail           int
+	since          int
+	fromVar        time.Time
+	toVar          time.Time
+	contains       string
+	sortOrder      string
+	clusterID      string
+	pod            string
+	namespaceList  []string
+	nodeList       []string
+	containerList  []string
+	statusList     []string
+	filterList     []string
+	rawDQL         string
+	console        bool
+	follow         bool
+	followInterval time.Duration
 )
 
 const (
@@ -60,6 +68,15 @@ const (
 
   # Restrict return of logs to those that contain a specific phrase
   $ osdctl dt logs alertmanager-main-0 -n openshift-monitoring --contains <phrase>
+
+  # Filter on arbitrary log record fields (operators: ==, !=, <, <=, >, >=, ~ (contains), !~ (does not contain))
+  $ osdctl dt logs -n openshift-monitoring --filter 'loglevel == "ERROR"' --filter 'content ~ timeout'
+
+  # Stream new logs of all pods in the HCP namespace as they arrive, like oc logs -f
+  $ osdctl dt logs --cluster-id <cluster-id> --follow
+
+  # Run a raw DQL query against the Dynatrace tenant of the cluster
+  $ osdctl dt logs --dql 'fetch logs | filter k8s.namespace.name == "openshift-monitoring" | limit 10'
 `
 )
 
@@ -106,7 +123,18 @@ func NewCmdLogs() *cobra.Command {
 	logsCmd.Flags().StringSliceVar(&statusList, "status", []string{}, "Status(Info/Warn/Error) (comma-separated)")
 	logsCmd.Flags().StringSliceVar(&containerList, "container", []string{}, "Container name(s) (comma-separated)")
 	logsCmd.Flags().StringSliceVarP(&namespaceList, "namespace", "n", []string{}, "Namespace(s) (comma-separated)")
+	logsCmd.Flags().StringArrayVar(&filterList, "filter", []string{}, "Filter on a log record field in the form 'field op value', e.g. 'loglevel == \"ERROR\"' (can be repeated)")
+	logsCmd.Flags().StringVar(&rawDQL, "dql", "", "Raw DQL query to run instead of building one from the other flags")
 	logsCmd.Flags().BoolVar(&console, "console", false, "Print the url to the dynatrace web console instead of outputting the logs")
+	logsCmd.Flags().BoolVarP(&follow, "follow", "f", false, "Keep polling Dynatrace and stream new logs as they arrive")
+	logsCmd.Flags().DurationVar(&followInterval, "follow-interval", defaultFollowInterval, "How often to poll Dynatrace for new logs with --follow")
+	logsCmd.MarkFlagsMutuallyExclusive("dql", "filter")
+	logsCmd.MarkFlagsMutuallyExclusive("follow", "from")
+	logsCmd.MarkFlagsMutuallyExclusive("follow", "to")
+	logsCmd.MarkFlagsMutuallyExclusive("follow", "console")
+	logsCmd.MarkFlagsMutuallyExclusive("follow", "dql")
+	logsCmd.MarkFlagsMutuallyExclusive("follow", "sort")
+	logsCmd.MarkFlagsMutuallyExclusive("dql", "contains")
 
 	return logsCmd
 }
@@ -157,9 +185,18 @@ func main(clusterID string) error {
 		return fmt.Errorf("invalid sort order, expecting 'asc' or 'desc'")
 	}
 
-	query, err := GetQuery(hcpCluster, fromVar, toVar, since)
-	if err != nil {
-		return fmt.Errorf("failed to build query for Dynatrace %v", err)
+	if follow {
+		return followLogs(hcpCluster)
+	}
+
+	var query DTQuery
+	if rawDQL != "" {
+		query.Raw(rawDQL)
+	} else {
+		query, err = GetQuery(hcpCluster, fromVar, toVar, since)
+		if err != nil {
+			return fmt.Errorf("failed to build query for Dynatrace %v", err)
+		}
 	}
 
 	fmt.Println(query.Build())
@@ -191,7 +228,7 @@ func main(clusterID string) error {
 		return fmt.Errorf("failed to acquire access token %v", err)
 	}
 
-	requestToken, err := getDTQueryExecution(hcpCluster.DynatraceURL, accessToken, query.finalQuery)
+	requestToken, err := getDTQueryExecution(context.Background(), hcpCluster.DynatraceURL, accessToken, query.finalQuery)
 	if err != nil {
 		return fmt.Errorf("failed to get  vault token %v", err)
 	}
@@ -204,6 +241,10 @@ func main(clusterID string) error {
 }
 
 func GetQuery(hcpCluster HCPCluster, fromVar time.Time, toVar time.Time, since int) (query DTQuery, error error) {
+	return buildLogsQuery(hcpCluster, fromVar, toVar, since, sortOrder, tail)
+}
+
+func buildLogsQuery(hcpCluster HCPCluster, fromVar time.Time, toVar time.Time, since int, order string, limit int) (query DTQuery, error error) {
 	q := DTQuery{}
 
 	if !fromVar.IsZero() && !toVar.IsZero() {
@@ -212,12 +253,13 @@ func GetQuery(hcpCluster HCPCluster, fromVar time.Time, toVar time.Time, since i
 		q.InitLogs(since).Cluster(hcpCluster.managementClusterName)
 	}
 
+	namespaces := append([]string{}, namespaceList...)
 	if hcpCluster.hcpNamespace != "" {
-		namespaceList = append(namespaceList, hcpCluster.hcpNamespace)
+		namespaces = append(namespaces, hcpCluster.hcpNamespace)
 	}
 
-	if len(namespaceList) > 0 {
-		q.Namespaces(namespaceList)
+	if len(namespaces) > 0 {
+		q.Namespaces(namespaces)
 	}
 
 	if len(nodeList) > 0 {
@@ -240,16 +282,56 @@ func GetQuery(hcpCluster HCPCluster, fromVar time.Time, toVar time.Time, since i
 		q.ContainsPhrase(contains)
 	}
 
-	if sortOrder != "" {
-		q, err := q.Sort(sortOrder)
+	if len(filterList) > 0 {
+		if _, err := q.Filters(filterList); err != nil {
+			return q, err
+		}
+	}
+
+	if order != "" {
+		q, err := q.Sort(order)
 		if err != nil {
 			return *q, err
 		}
 	}
 
-	if tail > 0 {
-		q.Limit(tail)
+	if limit > 0 {
+		q.Limit(limit)
 	}
 
 	return q, nil
 }
+
+// followLogs streams the logs matching the flags until interrupted, polling Dynatrace every followInterval
+func followLogs(hcpCluster HCPCluster) error {
+	if followInterval <= 0 {
+		return fmt.Errorf("--follow-interval must be greater than zero")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	f := newLogFollower(os.Stdout, followInterval, pod == "")
+	f.initialQuery = func() (DTQuery, error) {
+		// Fetch the newest --tail records, they are put back in chronological order when printed
+		return buildLogsQuery(hcpCluster, time.Time{}, time.Time{}, since, "desc", tail)
+	}
+	f.windowQuery = func(from time.Time, to time.Time) (DTQuery, error) {
+		return buildLogsQuery(hcpCluster, from, to, since, "asc", 0)
+	}
+	f.fetch = func(ctx context.Context, query string) ([]LogContent, error) {
+		// Dynatrace access tokens are short lived, the cached token is refreshed once it runs out
+		accessToken, err := getStorageAccessToken()
+		if err != nil {
+			return nil, fmt.Errorf("failed to acquire access token %v", err)
+		}
+
+		requestToken, err := getDTQueryExecution(ctx, hcpCluster.DynatraceURL, accessToken, query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute query %v", err)
+		}
+		return getLogRecords(ctx, hcpCluster.DynatraceURL, accessToken, requestToken)
+	}
+
+	return f.run(ctx)
+}
@@ -0,0 +1,349 @@
+package dynatrace
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	k8s "github.com/openshift/osdctl/pkg/k8s"
+	"github.com/openshift/osdctl/pkg/printer"
+	"github.com/spf13/cobra"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+// metricValueField is the alias of the aggregation in every timeseries query
+const metricValueField = "value"
+
+type metricPreset struct {
+	description string
+	aggregation Aggregation
+	workload    string
+}
+
+// metricPresets are commonly used timeseries for HCP control plane components
+var metricPresets = map[string]metricPreset{
+	"apiserver-latency": {
+		description: "Average kube-apiserver request duration in seconds",
+		aggregation: Aggregation{Function: "avg", Field: "apiserver_request_duration_seconds"},
+		workload:    "kube-apiserver",
+	},
+	"apiserver-cpu": {
+		description: "kube-apiserver CPU usage in millicores",
+		aggregation: Aggregation{Function: "sum", Field: "dt.kubernetes.container.cpu_usage"},
+		workload:    "kube-apiserver",
+	},
+	"apiserver-memory": {
+		description: "kube-apiserver working set memory in bytes",
+		aggregation: Aggregation{Function: "sum", Field: "dt.kubernetes.container.memory_working_set"},
+		workload:    "kube-apiserver",
+	},
+	"etcd-fsync": {
+		description: "Average etcd WAL fsync duration in seconds",
+		aggregation: Aggregation{Function: "avg", Field: "etcd_disk_wal_fsync_duration_seconds"},
+		workload:    "etcd",
+	},
+	"etcd-commit": {
+		description: "Average etcd backend commit duration in seconds",
+		aggregation: Aggregation{Function: "avg", Field: "etcd_disk_backend_commit_duration_seconds"},
+		workload:    "etcd",
+	},
+	"etcd-cpu": {
+		description: "etcd CPU usage in millicores",
+		aggregation: Aggregation{Function: "sum", Field: "dt.kubernetes.container.cpu_usage"},
+		workload:    "etcd",
+	},
+	"ovn-cpu": {
+		description: "ovnkube-control-plane CPU usage in millicores",
+		aggregation: Aggregation{Function: "sum", Field: "dt.kubernetes.container.cpu_usage"},
+		workload:    "ovnkube-control-plane",
+	},
+}
+
+type metricsOptions struct {
+	clusterID   string
+	metric      string
+	aggregation string
+	workload    string
+	since       int
+	interval    string
+	by          []string
+	output      string
+	dryRun      bool
+}
+
+func newCmdMetrics() *cobra.Command {
+	opts := &metricsOptions{}
+
+	metricsCmd := &cobra.Command{
+		Use:   "metrics --cluster-id <cluster-identifier> [--metric <preset> | --aggregation <func(metric)>]",
+		Short: "Fetch timeseries metrics of HCP components from Dynatrace",
+		Long: `Runs a DQL timeseries query for the HCP namespace of a cluster and renders the result.
+
+  Use one of the presets with --metric, or any metric with --aggregation. Available presets:
+` + presetHelp(),
+		Example: `
+  # Show kube-apiserver request latency per pod over the last 6 hours
+  $ osdctl dt metrics --cluster-id <cluster-id> --metric apiserver-latency --since 6
+
+  # Show etcd fsync latency in 1 minute buckets as CSV
+  $ osdctl dt metrics --cluster-id <cluster-id> --metric etcd-fsync --interval 1m -o csv
+
+  # Use any metric and aggregation
+  $ osdctl dt metrics --cluster-id <cluster-id> --aggregation "max(dt.kubernetes.container.memory_working_set)" --workload konnectivity-agent`,
+		Args:              cobra.NoArgs,
+		DisableAutoGenTag: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(opts.run())
+		},
+	}
+
+	metricsCmd.Flags().StringVarP(&opts.clusterID, "cluster-id", "C", "", "Name or Internal ID of the cluster (defaults to current cluster context)")
+	metricsCmd.Flags().StringVarP(&opts.metric, "metric", "m", "", "Name of a metric preset")
+	metricsCmd.Flags().StringVar(&opts.aggregation, "aggregation", "", "Aggregation of a metric key to query, e.g. 'avg(dt.kubernetes.container.cpu_usage)'")
+	metricsCmd.Flags().StringVar(&opts.workload, "workload", "", "Only include this workload (overrides the workload of the preset)")
+	metricsCmd.Flags().IntVar(&opts.since, "since", 1, "Number of hours (integer) since which to query")
+	metricsCmd.Flags().StringVar(&opts.interval, "interval", "5m", "Resolution of the timeseries, e.g. 1m, 5m, 1h")
+	metricsCmd.Flags().StringSliceVar(&opts.by, "by", []string{"k8s.pod.name"}, "Dimensions to split the timeseries by (comma-separated)")
+	metricsCmd.Flags().StringVarP(&opts.output, "output", "o", "table", "Output format: table or csv")
+	metricsCmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Only print the query without running it")
+	metricsCmd.MarkFlagsMutuallyExclusive("metric", "aggregation")
+	metricsCmd.MarkFlagsOneRequired("metric", "aggregation")
+
+	return metricsCmd
+}
+
+func presetHelp() string {
+	names := make([]string, 0, len(metricPresets))
+	for name := range metricPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "    %-20s %s\n", name, metricPresets[name].description)
+	}
+	return b.String()
+}
+
+var intervalPattern = regexp.MustCompile(`^[1-9][0-9]*(s|m|h|d)$`)
+
+var aggregationPattern = regexp.MustCompile(`^\s*([A-Za-z]+)\(\s*([^()\s]+)\s*\)\s*$`)
+
+func parseAggregation(aggregation string) (Aggregation, error) {
+	match := aggregationPattern.FindStringSubmatch(aggregation)
+	if match == nil {
+		return Aggregation{}, fmt.Errorf("invalid aggregation %q, expected the form 'func(metric.key)'", aggregation)
+	}
+	return Aggregation{Function: match[1], Field: match[2]}, nil
+}
+
+func (o *metricsOptions) validate() error {
+	if o.since <= 0 {
+		return fmt.Errorf("invalid time duration")
+	}
+	if !intervalPattern.MatchString(o.interval) {
+		return fmt.Errorf("invalid interval %q, expecting a duration like 30s, 5m, 1h or 1d", o.interval)
+	}
+	if o.output != "table" && o.output != "csv" {
+		return fmt.Errorf("invalid output format %q, expecting 'table' or 'csv'", o.output)
+	}
+	return nil
+}
+
+func (o *metricsOptions) run() error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+
+	var err error
+	if o.clusterID == "" {
+		o.clusterID, err = k8s.GetCurrentCluster()
+		if err != nil {
+			return err
+		}
+	}
+
+	hcpCluster, err := FetchClusterDetails(o.clusterID)
+	if err != nil {
+		return fmt.Errorf("failed to acquire cluster details %v", err)
+	}
+
+	query, err := o.query(hcpCluster)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, query.Build())
+	if o.dryRun {
+		return nil
+	}
+
+	accessToken, err := getMetricsAccessToken()
+	if err != nil {
+		return fmt.Errorf("failed to acquire access token %v", err)
+	}
+
+	requestToken, err := getDTQueryExecution(context.Background(), hcpCluster.DynatraceURL, accessToken, query.finalQuery)
+	if err != nil {
+		return fmt.Errorf("failed to execute query %v", err)
+	}
+
+	series, err := getTimeseries(hcpCluster.DynatraceURL, accessToken, requestToken, metricValueField)
+	if err != nil {
+		return fmt.Errorf("failed to get metrics %v", err)
+	}
+
+	if o.output == "csv" {
+		return writeTimeseriesCSV(os.Stdout, series, o.by)
+	}
+	return writeTimeseriesTable(os.Stdout, series, o.by)
+}
+
+func (o *metricsOptions) query(hcpCluster HCPCluster) (DTQuery, error) {
+	var aggregation Aggregation
+	workload := o.workload
+	if o.metric != "" {
+		preset, ok := metricPresets[o.metric]
+		if !ok {
+			return DTQuery{}, fmt.Errorf("unknown metric preset %q, available presets:\n%s", o.metric, presetHelp())
+		}
+		aggregation = preset.aggregation
+		if workload == "" {
+			workload = preset.workload
+		}
+	} else {
+		var err error
+		aggregation, err = parseAggregation(o.aggregation)
+		if err != nil {
+			return DTQuery{}, err
+		}
+	}
+	aggregation.Alias = metricValueField
+
+	q := DTQuery{}
+	q.InitTimeseries(aggregation, o.since, o.interval, o.by...).
+		Filter(MatchesValue("dt.kubernetes.cluster.name", hcpCluster.managementClusterName))
+	if hcpCluster.hcpNamespace != "" {
+		q.Namespaces([]string{hcpCluster.hcpNamespace})
+	}
+	if workload != "" {
+		q.Filter(MatchesValue("k8s.workload.name", workload))
+	}
+
+	return q, nil
+}
+
+func seriesName(ts DTTimeseries, by []string) []string {
+	name := make([]string, len(by))
+	for i, dim := range by {
+		name[i] = ts.Dimensions[dim]
+	}
+	return name
+}
+
+func writeTimeseriesTable(out io.Writer, series []DTTimeseries, by []string) error {
+	if len(series) == 0 {
+		fmt.Fprintln(out, "No datapoints found")
+		return nil
+	}
+
+	table := printer.NewTablePrinter(out, 0, 1, 3, ' ')
+	header := append(append([]string{}, by...), "MIN", "AVG", "MAX", "LAST", "TREND")
+	table.AddRow(header)
+	for _, ts := range series {
+		lo, avg, hi, last := summarizeValues(ts.Values)
+		row := append(seriesName(ts, by), formatValue(lo), formatValue(avg), formatValue(hi), formatValue(last), sparkline(ts.Values))
+		table.AddRow(row)
+	}
+
+	return table.Flush()
+}
+
+func writeTimeseriesCSV(out io.Writer, series []DTTimeseries, by []string) error {
+	w := csv.NewWriter(out)
+	header := append(append([]string{}, by...), "timestamp", "value")
+	if err := w.Write(header); err != nil {
+		return err
+	}
+
+	for _, ts := range series {
+		name := seriesName(ts, by)
+		for i, v := range ts.Values {
+			value := ""
+			if v != nil {
+				value = strconv.FormatFloat(*v, 'f', -1, 64)
+			}
+			timestamp := ts.Timeframe.Start.Add(time.Duration(i) * ts.Interval).Format(time.RFC3339)
+			if err := w.Write(append(append([]string{}, name...), timestamp, value)); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// summarizeValues returns the min, avg, max and last value of the non-null datapoints
+func summarizeValues(values []*float64) (lo, avg, hi, last *float64) {
+	var sum float64
+	var count int
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		if lo == nil || *v < *lo {
+			lo = v
+		}
+		if hi == nil || *v > *hi {
+			hi = v
+		}
+		last = v
+		sum += *v
+		count++
+	}
+	if count > 0 {
+		a := sum / float64(count)
+		avg = &a
+	}
+	return lo, avg, hi, last
+}
+
+func formatValue(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return strconv.FormatFloat(*v, 'g', 4, 64)
+}
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline renders the values as a line of block characters, gaps are rendered as spaces
+func sparkline(values []*float64) string {
+	lo, _, hi, _ := summarizeValues(values)
+	if lo == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, v := range values {
+		if v == nil {
+			b.WriteRune(' ')
+			continue
+		}
+		idx := 0
+		if *hi > *lo {
+			idx = int(math.Round((*v - *lo) / (*hi - *lo) * float64(len(sparkBlocks)-1)))
+		}
+		b.WriteRune(sparkBlocks[idx])
+	}
+	return b.String()
+}
@@ -0,0 +1,113 @@
+package dynatrace
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseTimeseries(t *testing.T) {
+	records := []json.RawMessage{
+		json.RawMessage(`{"timeframe":{"start":"2025-06-12T05:00:00.000000000Z","end":"2025-06-12T05:15:00.000000000Z"},"interval":"300000000000","k8s.pod.name":"etcd-0","value":[1.5,null,3]}`),
+	}
+
+	series, err := parseTimeseries(records, metricValueField)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(series) != 1 {
+		t.Fatalf("expected 1 series, got %d", len(series))
+	}
+
+	ts := series[0]
+	if ts.Dimensions["k8s.pod.name"] != "etcd-0" {
+		t.Errorf("expected pod dimension etcd-0, got %v", ts.Dimensions)
+	}
+	if ts.Interval != 5*time.Minute {
+		t.Errorf("expected 5m interval, got %s", ts.Interval)
+	}
+	if len(ts.Values) != 3 || ts.Values[1] != nil || *ts.Values[2] != 3 {
+		t.Errorf("unexpected values %v", ts.Values)
+	}
+
+	out := &bytes.Buffer{}
+	if err := writeTimeseriesCSV(out, series, []string{"k8s.pod.name"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "k8s.pod.name,timestamp,value\n" +
+		"etcd-0,2025-06-12T05:00:00Z,1.5\n" +
+		"etcd-0,2025-06-12T05:05:00Z,\n" +
+		"etcd-0,2025-06-12T05:10:00Z,3\n"
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name     string
+		values   []*float64
+		expected string
+	}{
+		{"empty", []*float64{}, ""},
+		{"only gaps", []*float64{nil, nil}, ""},
+		{"flat", []*float64{f(2), f(2)}, "▁▁"},
+		{"rising with gap", []*float64{f(0), nil, f(7), f(14)}, "▁ ▅█"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := sparkline(tt.values); actual != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMetricsAndProblemsQueries(t *testing.T) {
+	hcp := HCPCluster{managementClusterName: "hs-mc-1", hcpNamespace: "ocm-production-abc-my-cluster"}
+
+	opts := metricsOptions{metric: "etcd-fsync", since: 6, interval: "1m", by: []string{"k8s.pod.name"}}
+	q, err := opts.query(hcp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertGolden(t, "metrics_preset", q.Build())
+
+	opts = metricsOptions{aggregation: "max(dt.kubernetes.container.memory_working_set)", workload: "konnectivity-agent", since: 1, interval: "5m", by: []string{"k8s.pod.name", "k8s.container.name"}}
+	q, err = opts.query(hcp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertGolden(t, "metrics_aggregation", q.Build())
+
+	opts = metricsOptions{metric: "does-not-exist"}
+	if _, err := opts.query(hcp); err == nil {
+		t.Errorf("expected an error for an unknown preset")
+	}
+
+	q, err = getProblemsQuery(hcp, 24, "active")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertGolden(t, "problems", q.Build())
+}
+
+func TestParseAggregation(t *testing.T) {
+	agg, err := parseAggregation(" avg( dt.kubernetes.container.cpu_usage ) ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if agg.Function != "avg" || agg.Field != "dt.kubernetes.container.cpu_usage" {
+		t.Errorf("unexpected aggregation %+v", agg)
+	}
+
+	for _, invalid := range []string{"", "avg", "avg()", "avg(a) or true", "avg(a, b)"} {
+		if _, err := parseAggregation(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
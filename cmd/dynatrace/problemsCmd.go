@@ -0,0 +1,175 @@
+package dynatrace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	k8s "github.com/openshift/osdctl/pkg/k8s"
+	"github.com/openshift/osdctl/pkg/printer"
+	"github.com/spf13/cobra"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+type problemsOptions struct {
+	clusterID string
+	since     int
+	status    string
+	output    string
+	dryRun    bool
+}
+
+func newCmdProblems() *cobra.Command {
+	opts := &problemsOptions{}
+
+	problemsCmd := &cobra.Command{
+		Use:   "problems --cluster-id <cluster-identifier>",
+		Short: "List Davis problems affecting a cluster",
+		Long: `Lists the Davis problems raised by Dynatrace for a cluster.
+
+  For an HCP cluster the problems are restricted to its namespace on the management cluster.
+  For a management cluster all problems of the cluster are listed.`,
+		Example: `
+  # List the open problems of an HCP cluster from the last 24 hours
+  $ osdctl dt problems --cluster-id <cluster-id>
+
+  # List all problems of the last week as JSON
+  $ osdctl dt problems --cluster-id <cluster-id> --since 168 --status all -o json`,
+		Args:              cobra.NoArgs,
+		DisableAutoGenTag: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(opts.run())
+		},
+	}
+
+	problemsCmd.Flags().StringVarP(&opts.clusterID, "cluster-id", "C", "", "Name or Internal ID of the cluster (defaults to current cluster context)")
+	problemsCmd.Flags().IntVar(&opts.since, "since", 24, "Number of hours (integer) since which to list problems")
+	problemsCmd.Flags().StringVar(&opts.status, "status", "active", "Problem status to list: active, closed or all")
+	problemsCmd.Flags().StringVarP(&opts.output, "output", "o", "table", "Output format: table or json")
+	problemsCmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Only print the query without running it")
+
+	return problemsCmd
+}
+
+func (o *problemsOptions) validate() error {
+	if o.since <= 0 {
+		return fmt.Errorf("invalid time duration")
+	}
+	if o.status != "active" && o.status != "closed" && o.status != "all" {
+		return fmt.Errorf("invalid status %q, expecting 'active', 'closed' or 'all'", o.status)
+	}
+	if o.output != "table" && o.output != "json" {
+		return fmt.Errorf("invalid output format %q, expecting 'table' or 'json'", o.output)
+	}
+	return nil
+}
+
+func (o *problemsOptions) run() error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+
+	var err error
+	if o.clusterID == "" {
+		o.clusterID, err = k8s.GetCurrentCluster()
+		if err != nil {
+			return err
+		}
+	}
+
+	hcpCluster, err := FetchClusterDetails(o.clusterID)
+	if err != nil {
+		return fmt.Errorf("failed to acquire cluster details %v", err)
+	}
+
+	query, err := getProblemsQuery(hcpCluster, o.since, o.status)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, query.Build())
+	if o.dryRun {
+		return nil
+	}
+
+	accessToken, err := getStorageAccessToken()
+	if err != nil {
+		return fmt.Errorf("failed to acquire access token %v", err)
+	}
+
+	requestToken, err := getDTQueryExecution(context.Background(), hcpCluster.DynatraceURL, accessToken, query.finalQuery)
+	if err != nil {
+		return fmt.Errorf("failed to execute query %v", err)
+	}
+
+	problems, err := getProblems(hcpCluster.DynatraceURL, accessToken, requestToken)
+	if err != nil {
+		return fmt.Errorf("failed to get problems %v", err)
+	}
+
+	if o.output == "json" {
+		out, err := json.MarshalIndent(problems, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal problems: %w", err)
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	return writeProblemsTable(os.Stdout, problems)
+}
+
+func getProblemsQuery(hcpCluster HCPCluster, since int, status string) (DTQuery, error) {
+	q := DTQuery{}
+	q.InitProblems(since).Filter(MatchesValue("dt.kubernetes.cluster.name", hcpCluster.managementClusterName))
+
+	if hcpCluster.hcpNamespace != "" {
+		q.Namespaces([]string{hcpCluster.hcpNamespace})
+	}
+
+	switch status {
+	case "active":
+		q.Filter(MatchesValue("event.status", "ACTIVE"))
+	case "closed":
+		q.Filter(MatchesValue("event.status", "CLOSED"))
+	}
+
+	q.Fields("display_id", "event.name", "event.status", "event.category", "event.start", "event.end", "affected_entity_ids", "root_cause_entity_name")
+	if _, err := q.SortBy("event.start", "desc"); err != nil {
+		return q, err
+	}
+
+	return q, nil
+}
+
+func writeProblemsTable(out io.Writer, problems []DTProblem) error {
+	if len(problems) == 0 {
+		fmt.Fprintln(out, "No problems found")
+		return nil
+	}
+
+	table := printer.NewTablePrinter(out, 0, 1, 3, ' ')
+	table.AddRow([]string{"ID", "Status", "Category", "Started", "Ended", "Root Cause", "Name"})
+	for _, p := range problems {
+		table.AddRow([]string{
+			p.DisplayID,
+			p.Status,
+			p.Category,
+			p.Start,
+			valueOrDash(p.End),
+			valueOrDash(p.RootCause),
+			strings.TrimSpace(p.Name),
+		})
+	}
+
+	return table.Flush()
+}
+
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
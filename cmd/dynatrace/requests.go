@@ -2,6 +2,7 @@ package dynatrace
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -17,10 +18,16 @@ const (
 
 	authURL string = "https://sso.dynatrace.com/sso/oauth2/token"
 
+	// DTTokenStore selects where access tokens are cached: keyring (default), file or none
+	DTTokenStore string = "dt_token_store"
+
 	// Logs
 	DTStorageVaultPath string = "dt_vault_path"
 	DTStorageScopes    string = "storage:logs:read storage:events:read storage:buckets:read"
 
+	// Metrics, only requested by the metrics command as not every OAuth client has the scope
+	DTMetricsScopes string = "storage:metrics:read"
+
 	// Dashboards
 	DTDocumentVaultPath string = "dt_document_vault_path"
 	DTDocumentScopes    string = "document:documents:read"
@@ -32,6 +39,8 @@ type DTRequestError struct {
 }
 
 type Requester struct {
+	// ctx cancels the request, it defaults to context.Background()
+	ctx         context.Context
 	method      string
 	url         string
 	data        string
@@ -44,12 +53,17 @@ func (rh *Requester) send() (string, error) {
 		Timeout: time.Second * 600,
 	}
 
+	ctx := rh.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
 	var req *http.Request
 	var err error
 	if rh.data != "" {
-		req, err = http.NewRequest(rh.method, rh.url, bytes.NewBuffer([]byte(rh.data)))
+		req, err = http.NewRequestWithContext(ctx, rh.method, rh.url, bytes.NewBuffer([]byte(rh.data)))
 	} else {
-		req, err = http.NewRequest(rh.method, rh.url, nil)
+		req, err = http.NewRequestWithContext(ctx, rh.method, rh.url, nil)
 	}
 
 	if err != nil {
@@ -99,68 +113,16 @@ func getVaultPath(vaultPathKey string) (addr, path string, error error) {
 	return vaultAddr, vaultPath, nil
 }
 
-// getScopedAccessToken gets an access token using the vault path in the configuration key specified
-// It will request any scopes listed in the scopes string
-func getScopedAccessToken(configKey string, scopes string) (string, error) {
-	vaultAddr, vaultPath, err := getVaultPath(configKey)
-	if err != nil {
-		return "", err
-	}
-
-	err = setupVaultToken(vaultAddr)
-	if err != nil {
-		return "", nil
-	}
-
-	clientId, clientSecret, err := getSecretFromVault(vaultAddr, vaultPath)
-	if err != nil {
-		return "", nil
-	}
-
-	reqData := url.Values{
-		"grant_type":    {"client_credentials"},
-		"scope":         {scopes},
-		"client_id":     {clientId},
-		"client_secret": {clientSecret},
-	}.Encode()
-
-	requester := Requester{
-		method: http.MethodPost,
-		url:    authURL,
-		data:   string(reqData),
-		headers: map[string]string{
-			"Content-Type": "application/x-www-form-urlencoded",
-		},
-		successCode: http.StatusOK,
-	}
-
-	resp, err := requester.send()
-	if err != nil {
-		return "", err
-	}
-
-	var respObj map[string]interface{}
-	err = json.Unmarshal([]byte(resp), &respObj)
-	if err != nil {
-		return "", err
-	}
-
-	token, ok := respObj["access_token"].(string)
-	if !ok {
-		return "", fmt.Errorf("access token not present in response")
-	}
-
-	fmt.Println("Successfully authenticated with DynaTrace")
-
-	return token, nil
-}
-
 func getDocumentAccessToken() (string, error) {
-	return getScopedAccessToken(DTDocumentVaultPath, DTDocumentScopes)
+	return defaultTokenManager().token(DTDocumentVaultPath, DTDocumentScopes)
 }
 
 func getStorageAccessToken() (string, error) {
-	return getScopedAccessToken(DTStorageVaultPath, DTStorageScopes)
+	return defaultTokenManager().token(DTStorageVaultPath, DTStorageScopes)
+}
+
+func getMetricsAccessToken() (string, error) {
+	return defaultTokenManager().token(DTStorageVaultPath, DTMetricsScopes)
 }
 
 type DTQueryPayload struct {
@@ -183,7 +145,10 @@ type LogResult struct {
 }
 
 type LogContent struct {
-	Content string `json:"content"`
+	Content   string `json:"content"`
+	Timestamp string `json:"timestamp"`
+	PodName   string `json:"k8s.pod.name"`
+	Container string `json:"k8s.container.name"`
 }
 
 type DTEventsPollResult struct {
@@ -219,7 +184,7 @@ type DTDocument struct {
 	Type string `json:"type"`
 }
 
-func getDTQueryExecution(dtURL string, accessToken string, query string) (reqToken string, error error) {
+func getDTQueryExecution(ctx context.Context, dtURL string, accessToken string, query string) (reqToken string, error error) {
 	// Note: Currently we are setting a limit of 20,000 lines to pull from Dynatrace
 	// due to a limitation in dynatrace to pull all logs. This limitation can be revoked
 	// once https://community.dynatrace.com/t5/Product-ideas/Pagination-in-DQL-results/idi-p/248282#M45818
@@ -235,6 +200,7 @@ func getDTQueryExecution(dtURL string, accessToken string, query string) (reqTok
 	}
 
 	requester := Requester{
+		ctx:    ctx,
 		method: http.MethodPost,
 		url:    dtURL + "platform/storage/query/v1/query:execute",
 		data:   string(payloadJSON),
@@ -284,13 +250,14 @@ func getDTQueryExecution(dtURL string, accessToken string, query string) (reqTok
 	return token.RequestToken, err
 }
 
-func getDTPollResults(dtURL string, requestToken string, accessToken string) (respBody string, error error) {
+func getDTPollResults(ctx context.Context, dtURL string, requestToken string, accessToken string) (respBody string, error error) {
 	var dtPollRes DTLogsPollResult
 	reqData := url.Values{
 		"request-token": {requestToken},
 	}.Encode()
 
 	requester := Requester{
+		ctx:    ctx,
 		method: http.MethodGet,
 		url:    dtURL + "platform/storage/query/v1/query:poll?" + reqData,
 		headers: map[string]string{
@@ -368,19 +335,29 @@ func getDocumentIDByNameAndType(dtURL string, accessToken string, docName string
 	return dtDashboard.Id, nil
 }
 
-func getLogs(dtURL string, accessToken string, requestToken string, dumpWriter io.Writer) error {
-	resp, err := getDTPollResults(dtURL, requestToken, accessToken)
+// getLogRecords polls for the results of a log query and returns the records
+func getLogRecords(ctx context.Context, dtURL string, accessToken string, requestToken string) ([]LogContent, error) {
+	resp, err := getDTPollResults(ctx, dtURL, requestToken, accessToken)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var dtPollRes DTLogsPollResult
 	err = json.Unmarshal([]byte(resp), &dtPollRes)
+	if err != nil {
+		return nil, err
+	}
+
+	return dtPollRes.Result.Records, nil
+}
+
+func getLogs(dtURL string, accessToken string, requestToken string, dumpWriter io.Writer) error {
+	records, err := getLogRecords(context.Background(), dtURL, accessToken, requestToken)
 	if err != nil {
 		return err
 	}
 
-	for _, result := range dtPollRes.Result.Records {
+	for _, result := range records {
 		content := result.Content
 		if dumpWriter != nil {
 			dumpWriter.Write([]byte(fmt.Sprintf("%s\n", content)))
@@ -392,25 +369,115 @@ func getLogs(dtURL string, accessToken string, requestToken string, dumpWriter i
 	return nil
 }
 
-func getEvents(dtURL string, accessToken string, requestToken string, dumpWriter io.Writer) error {
-	resp, err := getDTPollResults(dtURL, requestToken, accessToken)
+// getRecords polls for the results of a query and returns the raw records
+func getRecords(dtURL string, accessToken string, requestToken string) ([]json.RawMessage, error) {
+	resp, err := getDTPollResults(context.Background(), dtURL, requestToken, accessToken)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var dtPollRes DTEventsPollResult
 	err = json.Unmarshal([]byte(resp), &dtPollRes)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	for _, result := range dtPollRes.Result.Records {
-		if dumpWriter != nil {
-			dumpWriter.Write([]byte(fmt.Sprintf("%s\n", result)))
-		} else {
-			fmt.Println(result)
+	return dtPollRes.Result.Records, nil
+}
+
+type DTTimeframe struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// DTTimeseries is a single series returned by a timeseries query
+type DTTimeseries struct {
+	Dimensions map[string]string
+	Timeframe  DTTimeframe
+	Interval   time.Duration
+	Values     []*float64
+}
+
+// getTimeseries polls for the results of a timeseries query. The aggregation
+// must be aliased to valueField, every other string field is treated as a dimension.
+func getTimeseries(dtURL string, accessToken string, requestToken string, valueField string) ([]DTTimeseries, error) {
+	records, err := getRecords(dtURL, accessToken, requestToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTimeseries(records, valueField)
+}
+
+func parseTimeseries(records []json.RawMessage, valueField string) ([]DTTimeseries, error) {
+	series := []DTTimeseries{}
+	for _, record := range records {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(record, &fields); err != nil {
+			return nil, fmt.Errorf("failed to parse timeseries record: %w", err)
 		}
+
+		ts := DTTimeseries{Dimensions: map[string]string{}}
+		for name, raw := range fields {
+			switch name {
+			case valueField:
+				if err := json.Unmarshal(raw, &ts.Values); err != nil {
+					return nil, fmt.Errorf("failed to parse timeseries values: %w", err)
+				}
+			case "timeframe":
+				if err := json.Unmarshal(raw, &ts.Timeframe); err != nil {
+					return nil, fmt.Errorf("failed to parse timeseries timeframe: %w", err)
+				}
+			case "interval":
+				// The interval is returned as a string of nanoseconds
+				var interval string
+				if err := json.Unmarshal(raw, &interval); err != nil {
+					return nil, fmt.Errorf("failed to parse timeseries interval: %w", err)
+				}
+				ns, err := time.ParseDuration(interval + "ns")
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse timeseries interval: %w", err)
+				}
+				ts.Interval = ns
+			default:
+				var dimension string
+				if err := json.Unmarshal(raw, &dimension); err == nil {
+					ts.Dimensions[name] = dimension
+				}
+			}
+		}
+		series = append(series, ts)
 	}
 
-	return nil
+	return series, nil
+}
+
+// DTProblem is a Davis problem as returned by fetch dt.davis.problems
+type DTProblem struct {
+	DisplayID        string   `json:"display_id"`
+	Name             string   `json:"event.name"`
+	Status           string   `json:"event.status"`
+	Category         string   `json:"event.category"`
+	Start            string   `json:"event.start"`
+	End              string   `json:"event.end"`
+	AffectedEntities []string `json:"affected_entity_ids"`
+	RootCause        string   `json:"root_cause_entity_name"`
+}
+
+func getProblems(dtURL string, accessToken string, requestToken string) ([]DTProblem, error) {
+	records, err := getRecords(dtURL, accessToken, requestToken)
+	if err != nil {
+		return nil, err
+	}
+
+	problems := make([]DTProblem, 0, len(records))
+	for _, record := range records {
+		var problem DTProblem
+		if err := json.Unmarshal(record, &problem); err != nil {
+			return nil, fmt.Errorf("failed to parse problem record: %w", err)
+		}
+		problems = append(problems, problem)
+	}
+
+	return problems, nil
 }
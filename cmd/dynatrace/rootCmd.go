@@ -17,6 +17,9 @@ func NewCmdDynatrace() *cobra.Command {
 	dtCmd.AddCommand(newCmdURL())
 	dtCmd.AddCommand(newCmdDashboard())
 	dtCmd.AddCommand(NewCmdHCPMustGather())
+	dtCmd.AddCommand(newCmdMetrics())
+	dtCmd.AddCommand(newCmdProblems())
+	dtCmd.AddCommand(newCmdAuth())
 
 	return dtCmd
 }
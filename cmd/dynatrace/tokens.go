@@ -0,0 +1,346 @@
+package dynatrace
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/99designs/keyring"
+	"github.com/spf13/viper"
+)
+
+const (
+	tokenKeyringService = "osdctl-dynatrace"
+
+	// tokenPasswordEnv holds the password of the encrypted token file, the user is prompted when it is not set
+	tokenPasswordEnv = "OSDCTL_DT_TOKEN_PASSWORD" //#nosec G101 -- This is the name of an environment variable, not a credential
+
+	// tokenExpiryMargin is how long a cached token must still be valid to be reused,
+	// so it doesn't expire in the middle of a query
+	tokenExpiryMargin = 30 * time.Second
+
+	// defaultTokenLifetime is used when the token response doesn't say when it expires
+	defaultTokenLifetime = 5 * time.Minute
+
+	tokenStoreKeyring = "keyring"
+	tokenStoreFile    = "file"
+	tokenStoreNone    = "none"
+)
+
+// cachedToken is a scoped access token as stored in the token store
+type cachedToken struct {
+	VaultPath   string    `json:"vaultPath"`
+	Scopes      string    `json:"scopes"`
+	AccessToken string    `json:"accessToken"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+func (t cachedToken) validAt(now time.Time) bool {
+	return t.AccessToken != "" && now.Add(tokenExpiryMargin).Before(t.ExpiresAt)
+}
+
+// tokenManager hands out Dynatrace access tokens. Tokens are cached per vault path
+// and scopes until shortly before they expire, so every osdctl dynatrace command
+// (and every poll of a long running one) reuses the same token, and vault and the
+// SSO endpoint are only contacted again once it has to be refreshed.
+type tokenManager struct {
+	mutex sync.Mutex
+	store keyring.Keyring
+
+	authURL string
+	// vaultPath returns the vault address and secret path configured in configKey
+	vaultPath func(configKey string) (addr string, path string, err error)
+	// credentials returns the OAuth client credentials stored in vault
+	credentials func(vaultAddr string, vaultPath string) (clientID string, clientSecret string, err error)
+	now         func() time.Time
+
+	// memory avoids reading the store again for tokens that were already used in this process
+	memory map[string]cachedToken
+}
+
+func newTokenManager(store keyring.Keyring) *tokenManager {
+	return &tokenManager{
+		store:     store,
+		authURL:   authURL,
+		vaultPath: getVaultPath,
+		credentials: func(vaultAddr string, vaultPath string) (string, string, error) {
+			if err := setupVaultToken(vaultAddr); err != nil {
+				return "", "", err
+			}
+			return getSecretFromVault(vaultAddr, vaultPath)
+		},
+		now:    time.Now,
+		memory: map[string]cachedToken{},
+	}
+}
+
+var (
+	defaultTokens     *tokenManager
+	defaultTokensOnce sync.Once
+)
+
+// defaultTokenManager returns the token manager backed by the store configured in dt_token_store
+func defaultTokenManager() *tokenManager {
+	defaultTokensOnce.Do(func() {
+		defaultTokens = newTokenManager(openTokenStore(viper.GetString(DTTokenStore)))
+	})
+	return defaultTokens
+}
+
+// openTokenStore opens the token cache. When no usable keyring is found, tokens
+// are only kept in memory for the lifetime of the process.
+func openTokenStore(kind string) keyring.Keyring {
+	cfg := keyring.Config{
+		ServiceName:              tokenKeyringService,
+		KeychainTrustApplication: true,
+	}
+
+	switch kind {
+	case "", tokenStoreKeyring:
+		cfg.AllowedBackends = []keyring.BackendType{
+			keyring.KeychainBackend,
+			keyring.SecretServiceBackend,
+			keyring.KWalletBackend,
+			keyring.WinCredBackend,
+		}
+	case tokenStoreFile:
+		dir, err := tokenFileDir()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: not caching Dynatrace tokens: %v\n", err)
+			return keyring.NewArrayKeyring(nil)
+		}
+		cfg.AllowedBackends = []keyring.BackendType{keyring.FileBackend}
+		cfg.FileDir = dir
+		cfg.FilePasswordFunc = tokenFilePassword
+	case tokenStoreNone:
+		return keyring.NewArrayKeyring(nil)
+	default:
+		fmt.Fprintf(os.Stderr, "Warning: unknown %s '%s', expected %s, %s or %s. Not caching Dynatrace tokens\n",
+			DTTokenStore, kind, tokenStoreKeyring, tokenStoreFile, tokenStoreNone)
+		return keyring.NewArrayKeyring(nil)
+	}
+
+	store, err := keyring.Open(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: not caching Dynatrace tokens, %v. Set '%s: %s' in the osdctl config to use an encrypted file instead\n",
+			err, DTTokenStore, tokenStoreFile)
+		return keyring.NewArrayKeyring(nil)
+	}
+	return store
+}
+
+func tokenFileDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, tokenKeyringService), nil
+}
+
+func tokenFilePassword(prompt string) (string, error) {
+	if password := os.Getenv(tokenPasswordEnv); password != "" {
+		return password, nil
+	}
+	return keyring.TerminalPrompt(prompt)
+}
+
+func tokenKey(vaultPath string, scopes string) string {
+	return vaultPath + "|" + scopes
+}
+
+// token returns a valid access token for the client credentials in the vault path
+// configured in configKey, fetching a new one when there is no cached token left
+func (m *tokenManager) token(configKey string, scopes string) (string, error) {
+	vaultAddr, vaultPath, err := m.vaultPath(configKey)
+	if err != nil {
+		return "", err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key := tokenKey(vaultPath, scopes)
+	if cached, ok := m.cached(key); ok {
+		return cached.AccessToken, nil
+	}
+
+	clientID, clientSecret, err := m.credentials(vaultAddr, vaultPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read client credentials from vault: %v", err)
+	}
+
+	fetched, err := m.requestToken(clientID, clientSecret, scopes)
+	if err != nil {
+		return "", err
+	}
+	fetched.VaultPath = vaultPath
+
+	m.memory[key] = fetched
+	if err := m.save(key, fetched); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to cache Dynatrace access token: %v\n", err)
+	}
+
+	fmt.Fprintln(os.Stderr, "Successfully authenticated with DynaTrace")
+
+	return fetched.AccessToken, nil
+}
+
+// cached returns the token stored under key if it is still valid
+func (m *tokenManager) cached(key string) (cachedToken, bool) {
+	now := m.now()
+	if t, ok := m.memory[key]; ok && t.validAt(now) {
+		return t, true
+	}
+
+	t, err := m.load(key)
+	if err != nil {
+		if !errors.Is(err, keyring.ErrKeyNotFound) {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring cached Dynatrace access token: %v\n", err)
+		}
+		return cachedToken{}, false
+	}
+	if !t.validAt(now) {
+		return cachedToken{}, false
+	}
+
+	m.memory[key] = t
+	return t, true
+}
+
+func (m *tokenManager) load(key string) (cachedToken, error) {
+	item, err := m.store.Get(key)
+	if err != nil {
+		return cachedToken{}, err
+	}
+
+	var t cachedToken
+	if err := json.Unmarshal(item.Data, &t); err != nil {
+		return cachedToken{}, err
+	}
+	return t, nil
+}
+
+func (m *tokenManager) save(key string, t cachedToken) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	return m.store.Set(keyring.Item{
+		Key:         key,
+		Data:        data,
+		Label:       "osdctl Dynatrace access token",
+		Description: t.Scopes,
+	})
+}
+
+// requestToken exchanges the client credentials for an access token with the requested scopes
+func (m *tokenManager) requestToken(clientID string, clientSecret string, scopes string) (cachedToken, error) {
+	reqData := url.Values{
+		"grant_type":    {"client_credentials"},
+		"scope":         {scopes},
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+	}.Encode()
+
+	requester := Requester{
+		method: http.MethodPost,
+		url:    m.authURL,
+		data:   reqData,
+		headers: map[string]string{
+			"Content-Type": "application/x-www-form-urlencoded",
+		},
+		successCode: http.StatusOK,
+	}
+
+	resp, err := requester.send()
+	if err != nil {
+		return cachedToken{}, err
+	}
+
+	var respObj struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+		Scope       string `json:"scope"`
+	}
+	if err := json.Unmarshal([]byte(resp), &respObj); err != nil {
+		return cachedToken{}, err
+	}
+	if respObj.AccessToken == "" {
+		return cachedToken{}, fmt.Errorf("access token not present in response")
+	}
+
+	lifetime := defaultTokenLifetime
+	if respObj.ExpiresIn > 0 {
+		lifetime = time.Duration(respObj.ExpiresIn) * time.Second
+	}
+
+	return cachedToken{
+		Scopes:      scopes,
+		AccessToken: respObj.AccessToken,
+		ExpiresAt:   m.now().Add(lifetime),
+	}, nil
+}
+
+// tokens returns every token in the store, sorted by vault path and scopes
+func (m *tokenManager) tokens() ([]cachedToken, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	keys, err := m.store.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens []cachedToken
+	for _, key := range keys {
+		t, err := m.load(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read token %s: %v", key, err)
+		}
+		tokens = append(tokens, t)
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		if tokens[i].VaultPath != tokens[j].VaultPath {
+			return tokens[i].VaultPath < tokens[j].VaultPath
+		}
+		return tokens[i].Scopes < tokens[j].Scopes
+	})
+	return tokens, nil
+}
+
+// clear removes every token from the store and returns how many were removed
+func (m *tokenManager) clear() (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.memory = map[string]cachedToken{}
+
+	keys, err := m.store.Keys()
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []string
+	removed := 0
+	for _, key := range keys {
+		if err := m.store.Remove(key); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+		removed++
+	}
+	if len(errs) > 0 {
+		return removed, fmt.Errorf("failed to remove tokens: %s", strings.Join(errs, ", "))
+	}
+	return removed, nil
+}
@@ -0,0 +1,262 @@
+package dynatrace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/99designs/keyring"
+)
+
+// fakeVaultCredentials returns the OAuth client credentials stored at osd-sre/dynatrace/logs
+func fakeVaultCredentials(vaultAddr string, vaultPath string) (string, string, error) {
+	if vaultPath != "osd-sre/dynatrace/logs" {
+		return "", "", fmt.Errorf("error running 'vault kv get': no value found at %s", vaultPath)
+	}
+	return "client", "secret", nil
+}
+
+// fakeOAuth issues a new access token for every client credentials request
+func fakeOAuth(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse token request: %v", err)
+		}
+		if r.PostForm.Get("client_id") != "client" || r.PostForm.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		n := requests.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "token-" + string(rune('0'+n)),
+			"expires_in":   300,
+			"scope":        r.PostForm.Get("scope"),
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func testTokenManager(t *testing.T, store keyring.Keyring, requests *atomic.Int32, now *time.Time) *tokenManager {
+	t.Helper()
+
+	m := newTokenManager(store)
+	m.authURL = fakeOAuth(t, requests).URL
+	m.vaultPath = func(configKey string) (string, string, error) {
+		return "https://vault.example.com", "osd-sre/dynatrace/logs", nil
+	}
+	m.credentials = fakeVaultCredentials
+	m.now = func() time.Time { return *now }
+	return m
+}
+
+func TestTokenManager_CachesUntilExpiry(t *testing.T) {
+	var requests atomic.Int32
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := keyring.NewArrayKeyring(nil)
+	m := testTokenManager(t, store, &requests, &now)
+
+	first, err := m.token(DTStorageVaultPath, DTStorageScopes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now = now.Add(4 * time.Minute)
+	second, err := m.token(DTStorageVaultPath, DTStorageScopes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first != second || requests.Load() != 1 {
+		t.Errorf("expected the cached token to be reused, got %s and %s after %d requests", first, second, requests.Load())
+	}
+
+	// Within the expiry margin the token is refreshed
+	now = now.Add(time.Minute)
+	third, err := m.token(DTStorageVaultPath, DTStorageScopes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if third == second || requests.Load() != 2 {
+		t.Errorf("expected a new token, got %s after %d requests", third, requests.Load())
+	}
+}
+
+func TestTokenManager_SharedThroughStore(t *testing.T) {
+	var requests atomic.Int32
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := keyring.NewArrayKeyring(nil)
+
+	first, err := testTokenManager(t, store, &requests, &now).token(DTStorageVaultPath, DTStorageScopes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A new process reads the token from the store
+	second, err := testTokenManager(t, store, &requests, &now).token(DTStorageVaultPath, DTStorageScopes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first != second || requests.Load() != 1 {
+		t.Errorf("expected the stored token to be reused, got %s and %s after %d requests", first, second, requests.Load())
+	}
+
+	// Other scopes get their own token
+	if _, err := testTokenManager(t, store, &requests, &now).token(DTDocumentVaultPath, DTDocumentScopes); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("expected a token request for the document scopes, got %d requests", requests.Load())
+	}
+}
+
+func TestTokenManager_StatusAndLogout(t *testing.T) {
+	var requests atomic.Int32
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := keyring.NewArrayKeyring(nil)
+	m := testTokenManager(t, store, &requests, &now)
+
+	if _, err := m.token(DTStorageVaultPath, DTStorageScopes); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := authStatus(&out, m); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "osd-sre/dynatrace/logs") || !strings.Contains(out.String(), "valid") {
+		t.Errorf("expected the token in the status, got:\n%s", out.String())
+	}
+
+	removed, err := m.clear()
+	if err != nil || removed != 1 {
+		t.Fatalf("expected 1 removed token, got %d: %v", removed, err)
+	}
+
+	if _, err := m.token(DTStorageVaultPath, DTStorageScopes); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("expected a new token after logout, got %d requests", requests.Load())
+	}
+}
+
+func TestTokenManager_Errors(t *testing.T) {
+	var requests atomic.Int32
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	m := testTokenManager(t, keyring.NewArrayKeyring(nil), &requests, &now)
+	m.vaultPath = func(configKey string) (string, string, error) {
+		return "https://vault.example.com", "osd-sre/dynatrace/missing", nil
+	}
+
+	if token, err := m.token(DTStorageVaultPath, DTStorageScopes); err == nil {
+		t.Errorf("expected an error for a missing secret, got token %q", token)
+	}
+}
+
+// fakeVaultCLI puts a vault CLI on the PATH which forwards the commands run by setupVaultToken and
+// getSecretFromVault to the vault API at VAULT_ADDR
+const fakeVaultCLI = `#!/bin/sh
+case "$1" in
+version) echo "Vault v1.15.0" ;;
+token) curl -sf "$VAULT_ADDR/v1/auth/token/lookup-self" >/dev/null ;;
+login) curl -sf -X POST "$VAULT_ADDR/v1/auth/oidc/login" >/dev/null ;;
+kv) curl -sf "$VAULT_ADDR/v1/secret/data/$4" ;;
+*) exit 1 ;;
+esac
+`
+
+// fakeVault serves the client credentials at osd-sre/dynatrace/logs, logins fail while the token is invalid
+func fakeVault(t *testing.T, clientSecret string, validToken bool) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/token/lookup-self", "/v1/auth/oidc/login":
+			if !validToken {
+				w.WriteHeader(http.StatusForbidden)
+			}
+		case "/v1/secret/data/osd-sre/dynatrace/logs":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{
+					"data": map[string]string{"client_id": "client", "client_secret": clientSecret},
+				},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestTokenManager_VaultAndOAuth(t *testing.T) {
+	if _, err := exec.LookPath("curl"); err != nil || runtime.GOOS == "windows" {
+		t.Skip("the fake vault CLI needs sh and curl")
+	}
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "vault"), []byte(fakeVaultCLI), 0o700); err != nil { //#nosec G306 -- The fake CLI must be executable
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	// setupVaultToken and getSecretFromVault set VAULT_ADDR, it's restored after the test
+	t.Setenv("VAULT_ADDR", "")
+
+	tests := []struct {
+		name         string
+		vaultPath    string
+		clientSecret string
+		validToken   bool
+		expectErr    string
+	}{
+		{name: "valid credentials", vaultPath: "osd-sre/dynatrace/logs", clientSecret: "secret", validToken: true},
+		{name: "missing secret", vaultPath: "osd-sre/dynatrace/missing", clientSecret: "secret", validToken: true, expectErr: "error running 'vault kv get'"},
+		{name: "failed vault login", vaultPath: "osd-sre/dynatrace/logs", clientSecret: "secret", expectErr: "error running 'vault login'"},
+		{name: "rejected client credentials", vaultPath: "osd-sre/dynatrace/logs", clientSecret: "wrong", validToken: true, expectErr: "401"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests atomic.Int32
+			vault := fakeVault(t, test.clientSecret, test.validToken)
+			m := newTokenManager(keyring.NewArrayKeyring(nil))
+			m.authURL = fakeOAuth(t, &requests).URL
+			m.vaultPath = func(configKey string) (string, string, error) {
+				return vault.URL, test.vaultPath, nil
+			}
+
+			token, err := m.token(DTStorageVaultPath, DTStorageScopes)
+			if test.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectErr) {
+					t.Fatalf("expected an error containing %q, got %v", test.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if token != "token-1" {
+				t.Errorf("expected token-1, got %q", token)
+			}
+
+			// The cached token is used without going to vault or the token endpoint again
+			vault.Close()
+			if token, err := m.token(DTStorageVaultPath, DTStorageScopes); err != nil || token != "token-1" {
+				t.Errorf("expected the cached token-1, got %q, %v", token, err)
+			}
+			if requests.Load() != 1 {
+				t.Errorf("expected 1 token request, got %d", requests.Load())
+			}
+		})
+	}
+}
@@ -57,8 +57,7 @@ func getSecretFromVault(vaultAddr, vaultPath string) (id string, secret string,
 	kvGetCommand := exec.Command("vault", "kv", "get", "-format=json", vaultPath)
 	output, err := kvGetCommand.Output()
 	if err != nil {
-		fmt.Println("Error running 'vault kv get':", err)
-		return "", "", nil
+		return "", "", fmt.Errorf("error running 'vault kv get': %v", err)
 	}
 
 	var resp response
@@ -1,18 +1,14 @@
 package mustgather
 
 import (
-	"archive/tar"
 	"bytes"
-	"compress/gzip"
 	"context"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
-	"path/filepath"
 	"strings"
 	"sync"
 	"syscall"
@@ -20,8 +16,9 @@ import (
 
 	"github.com/openshift/osdctl/cmd/common"
 	"github.com/openshift/osdctl/cmd/dynatrace"
+	"github.com/openshift/osdctl/internal/utils"
 	"github.com/openshift/osdctl/pkg/osdctlConfig"
-	"github.com/openshift/osdctl/pkg/utils"
+	ocmutils "github.com/openshift/osdctl/pkg/utils"
 	"github.com/spf13/cobra"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -63,23 +60,23 @@ func NewCmdMustGather() *cobra.Command {
 }
 
 func (mg *mustGather) Run() error {
-	ocmClient, err := utils.CreateConnection()
+	ocmClient, err := ocmutils.CreateConnection()
 	if err != nil {
 		return err
 	}
 	defer ocmClient.Close()
 
-	cluster, err := utils.GetClusterAnyStatus(ocmClient, mg.clusterId)
+	cluster, err := ocmutils.GetClusterAnyStatus(ocmClient, mg.clusterId)
 	if err != nil {
 		return fmt.Errorf("failed to get OCM cluster info for %s: %s", mg.clusterId, err)
 	}
 
-	mc, err := utils.GetManagementCluster(cluster.ID())
+	mc, err := ocmutils.GetManagementCluster(cluster.ID())
 	if err != nil {
 		return err
 	}
 
-	sc, err := utils.GetServiceCluster(cluster.ID())
+	sc, err := ocmutils.GetServiceCluster(cluster.ID())
 	if err != nil {
 		return err
 	}
@@ -212,7 +209,7 @@ func (mg *mustGather) Run() error {
 	fmt.Println("All must-gather tasks completed. Creating tarball.")
 
 	// Create a tarball with all collected data
-	if err := createTarball(outputDir, outputTarballTmp); err != nil {
+	if err := utils.CreateTarball(outputDir, outputTarballTmp); err != nil {
 		return fmt.Errorf("failed to create tarball: %w", err)
 	}
 
@@ -318,71 +315,3 @@ func createKubeconfigFileForRestConfig(restConfig *rest.Config) string {
 	_ = clientcmd.WriteToFile(clientConfig, kubeConfigFile.Name())
 	return kubeConfigFile.Name()
 }
-
-func createTarball(sourceDir, tarballName string) error {
-	tarballFile, err := os.Create(tarballName)
-	if err != nil {
-		return fmt.Errorf("failed to create tarball file: %v", err)
-	}
-	defer tarballFile.Close()
-
-	gzipWriter := gzip.NewWriter(tarballFile)
-	defer gzipWriter.Close()
-
-	tarWriter := tar.NewWriter(gzipWriter)
-	defer tarWriter.Close()
-
-	// Walk through the directory and add files to the tarball
-	err = filepath.Walk(sourceDir, func(file string, info os.FileInfo, err error) error {
-		if err != nil {
-			return fmt.Errorf("error walking the path %v: %v", file, err)
-		}
-
-		// Skip the root directory itself
-		if file == sourceDir {
-			return nil
-		}
-
-		// Create the header for the file entry
-		header, err := tar.FileInfoHeader(info, "")
-		if err != nil {
-			return fmt.Errorf("failed to create tar header for file %v: %v", file, err)
-		}
-
-		// Set the relative name for the file in the tarball (strip the sourceDir prefix)
-		relPath, err := filepath.Rel(sourceDir, file)
-		if err != nil {
-			return fmt.Errorf("failed to get relative path: %v", err)
-		}
-		header.Name = relPath
-
-		// Write the header for the file into the tarball
-		err = tarWriter.WriteHeader(header)
-		if err != nil {
-			return fmt.Errorf("failed to write header for file %v: %v", file, err)
-		}
-
-		// If it's a regular file, add its content to the tarball
-		if !info.IsDir() {
-			fileToArchive, err := os.Open(file)
-			if err != nil {
-				return fmt.Errorf("failed to open file %v: %v", file, err)
-			}
-			defer fileToArchive.Close()
-
-			// Copy the file content into the tarball
-			_, err = io.Copy(tarWriter, fileToArchive)
-			if err != nil {
-				return fmt.Errorf("failed to write file content for file %v: %v", file, err)
-			}
-		}
-
-		return nil
-	})
-
-	if err != nil {
-		return fmt.Errorf("error walking source directory: %v", err)
-	}
-
-	return nil
-}
@@ -1,14 +1,12 @@
 package mustgather
 
 import (
-	"fmt"
 	"net/http"
 	"net/url"
 	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/require"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
@@ -62,35 +60,3 @@ func TestCreateKubeconfigFileForRestConfig_Success(t *testing.T) {
 	assert.Equal(t, "testuser", authInfo.Impersonate)
 	assert.Equal(t, map[string][]string{"reason": {"test"}}, authInfo.ImpersonateUserExtra)
 }
-
-// Test creating a tarball with actual files in a directory
-func TestCreateTarball_Success(t *testing.T) {
-	dir := "/tmp/osdctl-targz-test"
-	err := os.MkdirAll(dir, 0750)
-	require.NoError(t, err)
-	defer os.RemoveAll(dir)
-
-	// Create a test file
-	fileName := fmt.Sprintf("%s/testfile.txt", dir)
-	file, err := os.Create(fileName)
-	require.NoError(t, err)
-	defer file.Close()
-	_, err = file.WriteString("Hello, world!")
-	require.NoError(t, err)
-
-	// Create the tarball
-	tarballName := fmt.Sprintf("%s/osdctl-targz-test-output-testdata.tar.gz", "/tmp")
-	err = createTarball(dir, tarballName)
-	require.NoError(t, err)
-
-	// Check tarball creation
-	_, err = os.Stat(tarballName)
-	assert.NoError(t, err)
-
-}
-
-// Test tarball creation failure when directory doesn't exist
-func TestCreateTarball_FileError(t *testing.T) {
-	err := createTarball("/nonexistent/path", "/tmp/testdata.tar.gz")
-	assert.Error(t, err)
-}
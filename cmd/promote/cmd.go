@@ -5,6 +5,7 @@ import (
 
 	"github.com/openshift/osdctl/cmd/promote/dynatrace"
 	"github.com/openshift/osdctl/cmd/promote/pko"
+	"github.com/openshift/osdctl/cmd/promote/rollback"
 	"github.com/openshift/osdctl/cmd/promote/saas"
 	"github.com/spf13/cobra"
 )
@@ -12,15 +13,24 @@ import (
 // NewCmdPromote implements the promote command to promote services/operators
 func NewCmdPromote() *cobra.Command {
 	promoteCmd := &cobra.Command{
-		Use:               "promote",
-		Short:             "Utilities to promote services/operators",
+		Use:   "promote",
+		Short: "Utilities to promote services/operators",
+		Long: `Utilities to promote services/operators.
+
+  Git operations on the app-interface and dynatrace-config checkouts and on the clones of
+  service repos run the git binary. Set OSDCTL_PROMOTE_GIT_BACKEND=go-git to use a built-in
+  git implementation instead, which doesn't run git hooks, doesn't sign commits, refuses to
+  switch branches while the checkout has uncommitted changes and clones service repos with
+  their file contents.`,
 		Args:              cobra.NoArgs,
 		DisableAutoGenTag: true,
 	}
 
 	promoteCmd.AddCommand(saas.NewCmdSaas())
+	promoteCmd.AddCommand(saas.NewCmdStatus())
 	promoteCmd.AddCommand(pko.NewCmdPKO())
 	promoteCmd.AddCommand(dynatrace.NewCmdDynatrace())
+	promoteCmd.AddCommand(rollback.NewCmdRollback())
 
 	return promoteCmd
 }
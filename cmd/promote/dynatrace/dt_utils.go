@@ -10,7 +10,6 @@ import (
 	"strings"
 
 	"github.com/openshift/osdctl/cmd/promote/git"
-	"github.com/openshift/osdctl/cmd/promote/iexec"
 	"gopkg.in/yaml.v3"
 )
 
@@ -133,7 +132,7 @@ func servicePromotion(appInterface git.AppInterface, component, gitHash string)
 
 	fmt.Printf("Current Git Hash: %v\nGit Repo: %v\nComponent path: %v\n", currentGitHash, serviceRepo, serviceFullPath)
 
-	promotionGitHash, commitLog, err := git.CheckoutAndCompareGitHash(appInterface.GitExecutor, serviceRepo, gitHash, currentGitHash, strings.TrimPrefix(serviceFullPath, "/"))
+	promotionGitHash, commitLog, err := git.CheckoutAndCompareGitHash(git.DefaultGitBackend(appInterface.GitExecutor), serviceRepo, gitHash, currentGitHash, strings.TrimPrefix(serviceFullPath, "/"))
 	if err != nil {
 		return fmt.Errorf("failed to checkout and compare git hash: %v", err)
 	} else if promotionGitHash == "" {
@@ -151,7 +150,7 @@ func servicePromotion(appInterface git.AppInterface, component, gitHash string)
 		fmt.Printf("FAILURE: %v\n", err)
 	}
 
-	commitMessage := fmt.Sprintf("Promote %s to %s\n\nSee %s/compare/%s...%s for contents of the promotion.\n clog:%s", component, promotionGitHash, serviceRepo, currentGitHash, promotionGitHash, commitLog)
+	commitMessage := fmt.Sprintf("Promote %s to %s\n\nSee %s/compare/%s...%s for contents of the promotion.\n\n### Changelog\n\n%s", component, promotionGitHash, serviceRepo, currentGitHash, promotionGitHash, commitLog)
 
 	fmt.Printf("commitMessage: %s\n", commitMessage)
 
@@ -323,14 +322,16 @@ func GetProductionDir(baseDir string) string {
 	return dirGlob
 }
 
-func getLatestGitHash(basedir, module string) (string, error) {
-	exec := iexec.Exec{}
-	moduleFilePath := filepath.Join(basedir, moduleDir, module)
-	output, err := exec.Output("", "git", "rev-list", "-n", "1", "HEAD", "--", moduleFilePath)
+func getLatestGitHash(dynatraceConfig DynatraceConfig, module string) (string, error) {
+	moduleFilePath := filepath.Join(dynatraceConfig.GitDirectory, moduleDir, module)
+	hashes, err := dynatraceConfig.Backend().Log(dynatraceConfig.GitDirectory, "HEAD", moduleFilePath, 1)
 	if err != nil {
 		return "", fmt.Errorf("failed to get git hash: %v", err)
 	}
-	gitHash := strings.TrimSpace(string(output))
+	if len(hashes) == 0 {
+		return "", fmt.Errorf("failed to get git hash: no commit changes module %s", module)
+	}
+	gitHash := hashes[0]
 	fmt.Printf("The head githash for module %s is %s\n", module, gitHash)
 
 	return gitHash, nil
@@ -353,7 +354,7 @@ func modulePromotion(dynatraceConfig DynatraceConfig, module string) error {
 
 	prodtenantDir := GetProductionDir(baseDir)
 
-	promotionGitHash, err := getLatestGitHash(baseDir, module)
+	promotionGitHash, err := getLatestGitHash(dynatraceConfig, module)
 
 	if err != nil {
 		return fmt.Errorf("failed to checkout and compare git hash: %v", err)
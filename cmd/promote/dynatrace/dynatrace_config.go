@@ -15,6 +15,16 @@ import (
 type DynatraceConfig struct {
 	GitDirectory string
 	GitExecutor  iexec.IExec
+	// Git runs the git operations on GitDirectory, running GitExecutor when not set
+	Git git.GitBackend
+}
+
+// Backend returns the GitBackend of the dynatrace-config checkout
+func (a DynatraceConfig) Backend() git.GitBackend {
+	if a.Git != nil {
+		return a.Git
+	}
+	return git.ExecBackend{GitExecutor: a.GitExecutor}
 }
 
 func DynatraceConfigPromotion(dynatraceConfigCheckoutDir string) DynatraceConfig {
@@ -26,6 +36,7 @@ func DynatraceConfigPromotion(dynatraceConfigCheckoutDir string) DynatraceConfig
 		if err != nil {
 			log.Fatalf("Provided directory %s is not an dynatrace-config directory: %v", a.GitDirectory, err)
 		}
+		a.Git = git.NewGitBackend(a.GitDirectory, a.GitExecutor)
 		return a
 	}
 
@@ -34,6 +45,7 @@ func DynatraceConfigPromotion(dynatraceConfigCheckoutDir string) DynatraceConfig
 		a.GitDirectory = dir
 		err = a.checkDynatraceConfigCheckout()
 		if err == nil {
+			a.Git = git.NewGitBackend(a.GitDirectory, a.GitExecutor)
 			return a
 		}
 	}
@@ -46,6 +58,7 @@ func DynatraceConfigPromotion(dynatraceConfigCheckoutDir string) DynatraceConfig
 	}
 
 	log.Printf("Found Dynatrace Config in %s.\n", a.GitDirectory)
+	a.Git = git.NewGitBackend(a.GitDirectory, a.GitExecutor)
 	return a
 }
 
@@ -54,12 +67,12 @@ func DefaultDynatraceconfigDir() string {
 }
 
 func (a DynatraceConfig) checkDynatraceConfigCheckout() error {
-	output, err := a.GitExecutor.CombinedOutput(a.GitDirectory, "git", "remote", "-v")
+	remotes, err := a.Backend().Remotes(a.GitDirectory)
 	if err != nil {
 		return fmt.Errorf("error executing 'git remote -v': %v", err)
 	}
 
-	outputString := string(output)
+	outputString := strings.Join(remotes, "\n")
 
 	// Check if the output contains the dynatrace-config repository URL
 	if !strings.Contains(outputString, "gitlab.cee.redhat.com") && !strings.Contains(outputString, "dynatrace-config") {
@@ -71,17 +84,18 @@ func (a DynatraceConfig) checkDynatraceConfigCheckout() error {
 }
 
 func (a DynatraceConfig) UpdateDynatraceConfig(component, promotionGitHash, branchName string) error {
-	err := a.GitExecutor.Run(a.GitDirectory, "git", "checkout", "main")
+	backend := a.Backend()
+	err := backend.Checkout(a.GitDirectory, "main")
 	if err != nil {
 		return fmt.Errorf("failed to checkout master branch: %v", err)
 	}
 
-	err = a.GitExecutor.Run(a.GitDirectory, "git", "branch", "-D", branchName)
+	err = backend.DeleteBranch(a.GitDirectory, branchName)
 	if err != nil {
 		fmt.Printf("failed to cleanup branch %s: %v, continuing to create it.\n", branchName, err)
 	}
 
-	err = a.GitExecutor.Run(a.GitDirectory, "git", "checkout", "-b", branchName, "main")
+	err = backend.CreateBranch(a.GitDirectory, branchName, "main")
 	if err != nil {
 		return fmt.Errorf("failed to create branch %s: %v, does it already exist? If so, please delete it with `git branch -D %s` first", branchName, err, branchName)
 	}
@@ -90,13 +104,19 @@ func (a DynatraceConfig) UpdateDynatraceConfig(component, promotionGitHash, bran
 }
 
 func (a DynatraceConfig) commitFiles(commitMessage string) error {
-	// Commit the change
-	err := a.GitExecutor.Run(a.GitDirectory, "git", "add", ".")
+	// Commit every change of the checkout
+	backend := a.Backend()
+	paths, err := backend.Status(a.GitDirectory)
 	if err != nil {
-		return fmt.Errorf("failed to add file : %v", err)
+		return fmt.Errorf("failed to read the status of %s: %v", a.GitDirectory, err)
+	}
+	for _, path := range paths {
+		if err := backend.Add(a.GitDirectory, path); err != nil {
+			return fmt.Errorf("failed to add file %s: %v", path, err)
+		}
 	}
 
-	err = a.GitExecutor.Run(a.GitDirectory, "git", "commit", "-m", commitMessage)
+	err = backend.Commit(a.GitDirectory, commitMessage)
 	if err != nil {
 		return fmt.Errorf("failed to commit changes: %v", err)
 	}
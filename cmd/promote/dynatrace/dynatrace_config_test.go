@@ -61,7 +61,7 @@ func TestCheckDynatraceConfigCheckout(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			mockExec := new(testMockExec)
-			mockExec.On("CombinedOutput", "/fake/dir", "git", []string{"remote", "-v"}).
+			mockExec.On("Output", "/fake/dir", "git", []string{"remote", "-v"}).
 				Return(tc.mockOutput, tc.mockErr)
 
 			cfg := DynatraceConfig{
@@ -89,26 +89,40 @@ func TestCommitFiles(t *testing.T) {
 		"successfully_commits_file": {
 			setup: func() DynatraceConfig {
 				mockExec := new(testMockExec)
-				mockExec.On("Run", "some-dir", "git", []string{"add", "."}).Return(nil)
+				mockExec.On("Output", "some-dir", "git", []string{"status", "--porcelain", "-z", "--untracked-files=all"}).Return(" M modules/foo.tf\x00?? modules/bar.tf\x00", nil)
+				mockExec.On("Run", "some-dir", "git", []string{"add", "modules/bar.tf"}).Return(nil)
+				mockExec.On("Run", "some-dir", "git", []string{"add", "modules/foo.tf"}).Return(nil)
 				mockExec.On("Run", "some-dir", "git", []string{"commit", "-m", "initial commit"}).Return(nil)
 				return DynatraceConfig{GitDirectory: "some-dir", GitExecutor: mockExec}
 			},
 			commitMsg:   "initial commit",
 			expectError: false,
 		},
-		"fails_when_git_add_fails": {
+		"fails_when_git_status_fails": {
 			setup: func() DynatraceConfig {
 				mockExec := new(testMockExec)
-				mockExec.On("Run", "invalid-dir", "git", []string{"add", "."}).Return(fmt.Errorf("git not initialized"))
+				mockExec.On("Output", "invalid-dir", "git", []string{"status", "--porcelain", "-z", "--untracked-files=all"}).Return("", fmt.Errorf("git not initialized"))
 				return DynatraceConfig{GitDirectory: "invalid-dir", GitExecutor: mockExec}
 			},
 			commitMsg:   "should fail",
 			expectError: true,
 		},
+		"fails_when_git_add_fails": {
+			setup: func() DynatraceConfig {
+				mockExec := new(testMockExec)
+				mockExec.On("Output", "some-dir", "git", []string{"status", "--porcelain", "-z", "--untracked-files=all"}).Return(" M modules/foo.tf\x00?? modules/bar.tf\x00", nil)
+				mockExec.On("Run", "some-dir", "git", []string{"add", "modules/bar.tf"}).Return(fmt.Errorf("permission denied"))
+				return DynatraceConfig{GitDirectory: "some-dir", GitExecutor: mockExec}
+			},
+			commitMsg:   "should fail",
+			expectError: true,
+		},
 		"fails_when_git_commit_fails": {
 			setup: func() DynatraceConfig {
 				mockExec := new(testMockExec)
-				mockExec.On("Run", "some-dir", "git", []string{"add", "."}).Return(nil)
+				mockExec.On("Output", "some-dir", "git", []string{"status", "--porcelain", "-z", "--untracked-files=all"}).Return(" M modules/foo.tf\x00?? modules/bar.tf\x00", nil)
+				mockExec.On("Run", "some-dir", "git", []string{"add", "modules/bar.tf"}).Return(nil)
+				mockExec.On("Run", "some-dir", "git", []string{"add", "modules/foo.tf"}).Return(nil)
 				mockExec.On("Run", "some-dir", "git", []string{"commit", "-m", "initial commit"}).Return(fmt.Errorf("git commit failed"))
 				return DynatraceConfig{GitDirectory: "some-dir", GitExecutor: mockExec}
 			},
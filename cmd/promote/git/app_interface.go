@@ -39,6 +39,16 @@ type Service struct {
 type AppInterface struct {
 	GitDirectory string
 	GitExecutor  iexec.IExec
+	// Git runs the git operations on GitDirectory, running GitExecutor when not set
+	Git GitBackend
+}
+
+// Backend returns the GitBackend of the app-interface checkout
+func (a *AppInterface) Backend() GitBackend {
+	if a.Git != nil {
+		return a.Git
+	}
+	return ExecBackend{GitExecutor: a.GitExecutor}
 }
 
 // replaceTargetSha replaces sha for targets in file whose name matches a given substring
@@ -75,6 +85,95 @@ func replaceTargetSha(fileContent string, targetSuffix string, promotionGitHash
 	return node.MustString(), err, targetFound
 }
 
+// SaasTarget is a deployment target of a resource template in a SaaS file
+type SaasTarget struct {
+	ResourceTemplate string
+	URL              string
+	Name             string
+	Namespace        string
+	Ref              string
+	// PackageTag is the PACKAGE_TAG parameter of the targets of package templates
+	PackageTag string
+
+	templateIndex int
+	targetIndex   int
+}
+
+// GetSaasTargets returns the targets of every resource template deploying code from the service repo,
+// package templates are skipped as they are promoted by tag
+func GetSaasTargets(saasYamlFile []byte) ([]SaasTarget, error) {
+	return getSaasTargets(saasYamlFile, false)
+}
+
+// GetSaasPackageTargets returns the targets of the package resource templates, which are promoted by tag
+func GetSaasPackageTargets(saasYamlFile []byte) ([]SaasTarget, error) {
+	return getSaasTargets(saasYamlFile, true)
+}
+
+func getSaasTargets(saasYamlFile []byte, packages bool) ([]SaasTarget, error) {
+	var service Service
+	if err := yaml.Unmarshal(saasYamlFile, &service); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal saas yaml: %v", err)
+	}
+
+	var targets []SaasTarget
+	for i, resourceTemplate := range service.ResourceTemplates {
+		if strings.Contains(resourceTemplate.Name, "package") != packages {
+			continue
+		}
+		for j, target := range resourceTemplate.Targets {
+			packageTag, _ := target.Parameters["PACKAGE_TAG"].(string)
+			targets = append(targets, SaasTarget{
+				ResourceTemplate: resourceTemplate.Name,
+				URL:              resourceTemplate.URL,
+				Name:             target.Name,
+				Namespace:        target.Namespace["$ref"],
+				Ref:              target.Ref,
+				PackageTag:       packageTag,
+				templateIndex:    i,
+				targetIndex:      j,
+			})
+		}
+	}
+
+	return targets, nil
+}
+
+// SetSaasTargetRefs sets the ref of the given targets, which must have been read with GetSaasTargets from the same file
+func SetSaasTargetRefs(fileContent string, targets []SaasTarget, ref string) (string, error) {
+	return setSaasTargetField(fileContent, targets, ref, "ref")
+}
+
+// SetSaasTargetPackageTags sets the PACKAGE_TAG parameter of the given targets, which must have been read with
+// GetSaasPackageTargets from the same file
+func SetSaasTargetPackageTags(fileContent string, targets []SaasTarget, tag string) (string, error) {
+	return setSaasTargetField(fileContent, targets, tag, "parameters", "PACKAGE_TAG")
+}
+
+// setSaasTargetField sets the field at path of every target to value
+func setSaasTargetField(fileContent string, targets []SaasTarget, value string, path ...string) (string, error) {
+	node, err := kyaml.Parse(fileContent)
+	if err != nil {
+		return "", fmt.Errorf("error parsing saas YAML: %v", err)
+	}
+
+	for _, target := range targets {
+		element, err := kyaml.Lookup("resourceTemplates", strconv.Itoa(target.templateIndex), "targets", strconv.Itoa(target.targetIndex)).Filter(node)
+		if err != nil {
+			return "", fmt.Errorf("error querying saas YAML: %v", err)
+		}
+		if element == nil {
+			return "", fmt.Errorf("target %s of resource template %s not found", target.Name, target.ResourceTemplate)
+		}
+		field := path[len(path)-1]
+		if _, err := element.Pipe(kyaml.LookupCreate(kyaml.MappingNode, path[:len(path)-1]...), kyaml.SetField(field, kyaml.NewStringRNode(value))); err != nil {
+			return "", fmt.Errorf("error setting %s: %v", strings.Join(path, "."), err)
+		}
+	}
+
+	return node.MustString(), nil
+}
+
 func DefaultAppInterfaceDirectory() string {
 	return filepath.Join(os.Getenv("HOME"), "git", "app-interface")
 }
@@ -88,6 +187,7 @@ func BootstrapOsdCtlForAppInterfaceAndServicePromotions(appInterfaceCheckoutDir
 		if err != nil {
 			log.Fatalf("Provided directory %s is not an AppInterface directory: %v", a.GitDirectory, err)
 		}
+		a.Git = NewGitBackend(a.GitDirectory, gitExecutor)
 		return a
 	}
 
@@ -96,6 +196,7 @@ func BootstrapOsdCtlForAppInterfaceAndServicePromotions(appInterfaceCheckoutDir
 		a.GitDirectory = dir
 		err = a.checkAppInterfaceCheckout()
 		if err == nil {
+			a.Git = NewGitBackend(a.GitDirectory, gitExecutor)
 			return a
 		}
 	}
@@ -108,17 +209,18 @@ func BootstrapOsdCtlForAppInterfaceAndServicePromotions(appInterfaceCheckoutDir
 	}
 
 	log.Printf("Found AppInterface in %s.\n", a.GitDirectory)
+	a.Git = NewGitBackend(a.GitDirectory, gitExecutor)
 	return a
 }
 
 // checkAppInterfaceCheckout checks if the script is running in the checkout of app-interface
 func (a *AppInterface) checkAppInterfaceCheckout() error {
-	output, err := a.GitExecutor.Output(a.GitDirectory, "git", "remote", "-v")
+	remotes, err := a.Backend().Remotes(a.GitDirectory)
 	if err != nil {
 		return fmt.Errorf("error executing 'git remote -v': %v", err)
 	}
 
-	outputString := output
+	outputString := strings.Join(remotes, "\n")
 
 	// Check if the output contains the app-interface repository URL
 	if !strings.Contains(outputString, "gitlab.cee.redhat.com") && !strings.Contains(outputString, "app-interface") {
@@ -239,8 +341,13 @@ func GetCurrentPackageTagFromAppInterface(saasFile string) (string, error) {
 		return "", fmt.Errorf("failed to read file '%s': %w", saasFile, err)
 	}
 
+	return GetCurrentPackageTag(saasData)
+}
+
+// GetCurrentPackageTag returns the PACKAGE_TAG of the production target of the package resource template
+func GetCurrentPackageTag(saasData []byte) (string, error) {
 	service := Service{}
-	err = yaml.Unmarshal(saasData, &service)
+	err := yaml.Unmarshal(saasData, &service)
 	if err != nil {
 		return "", fmt.Errorf("failed to unmarshal service definition: %w", err)
 	}
@@ -264,19 +371,29 @@ func GetCurrentPackageTagFromAppInterface(saasFile string) (string, error) {
 	return currentPackageTag, nil
 }
 
-func (a *AppInterface) UpdateAppInterface(_, saasFile, currentGitHash, promotionGitHash, branchName string, hotfix bool) error {
-
-	if err := a.GitExecutor.Run(a.GitDirectory, "git", "checkout", "master"); err != nil {
+// CreatePromotionBranch (re)creates branchName from master and checks it out
+func (a *AppInterface) CreatePromotionBranch(branchName string) error {
+	backend := a.Backend()
+	if err := backend.Checkout(a.GitDirectory, "master"); err != nil {
 		return fmt.Errorf("failed to checkout master: branch %v", err)
 	}
 
-	if err := a.GitExecutor.Run(a.GitDirectory, "git", "branch", "-D", branchName); err != nil {
+	if err := backend.DeleteBranch(a.GitDirectory, branchName); err != nil {
 		fmt.Printf("failed to cleanup branch %s: %v, continuing to create it.\n", branchName, err)
 	}
 
-	if err := a.GitExecutor.Run(a.GitDirectory, "git", "checkout", "-b", branchName, "master"); err != nil {
+	if err := backend.CreateBranch(a.GitDirectory, branchName, "master"); err != nil {
 		return fmt.Errorf("failed to create branch %s: %v, does it already exist? If so, please delete it with `git branch -D %s` first", branchName, err, branchName)
 	}
+
+	return nil
+}
+
+func (a *AppInterface) UpdateAppInterface(_, saasFile, currentGitHash, promotionGitHash, branchName string, hotfix bool) error {
+	if err := a.CreatePromotionBranch(branchName); err != nil {
+		return err
+	}
+
 	// Update the hash in the SAAS file
 	fileContent, err := os.ReadFile(saasFile)
 	if err != nil {
@@ -312,11 +429,12 @@ func (a *AppInterface) UpdateAppInterface(_, saasFile, currentGitHash, promotion
 
 func (a *AppInterface) UpdatePackageTag(saasFile, oldTag, promotionTag, branchName string) error {
 
-	if err := a.GitExecutor.Run(a.GitDirectory, "git", "checkout", "master"); err != nil {
+	backend := a.Backend()
+	if err := backend.Checkout(a.GitDirectory, "master"); err != nil {
 		return fmt.Errorf("failed to checkout master branch: %v", err)
 	}
 
-	if err := a.GitExecutor.Run(a.GitDirectory, "git", "branch", "-D", branchName); err != nil {
+	if err := backend.DeleteBranch(a.GitDirectory, branchName); err != nil {
 		fmt.Printf("failed to cleanup branch %s: %v, continuing to create it.\n", branchName, err)
 	}
 
@@ -338,10 +456,11 @@ func (a *AppInterface) UpdatePackageTag(saasFile, oldTag, promotionTag, branchNa
 
 func (a *AppInterface) CommitSaasFile(saasFile, commitMessage string) error {
 	// Commit the change
-	if err := a.GitExecutor.Run(a.GitDirectory, "git", "add", saasFile); err != nil {
+	backend := a.Backend()
+	if err := backend.Add(a.GitDirectory, saasFile); err != nil {
 		return fmt.Errorf("failed to add file %s: %v", saasFile, err)
 	}
-	if err := a.GitExecutor.Run(a.GitDirectory, "git", "commit", "-m", commitMessage); err != nil {
+	if err := backend.Commit(a.GitDirectory, commitMessage); err != nil {
 		return fmt.Errorf("failed to commit changes: %v", err)
 	}
 
@@ -349,7 +468,8 @@ func (a *AppInterface) CommitSaasFile(saasFile, commitMessage string) error {
 }
 
 func (a *AppInterface) CommitSaasAndAppYmlFile(saasFile, serviceName, commitMessage string) error {
-	if err := a.GitExecutor.Run(a.GitDirectory, "git", "add", saasFile); err != nil {
+	backend := a.Backend()
+	if err := backend.Add(a.GitDirectory, saasFile); err != nil {
 		return fmt.Errorf("failed to add file %s: %v", saasFile, err)
 	}
 
@@ -360,11 +480,11 @@ func (a *AppInterface) CommitSaasAndAppYmlFile(saasFile, serviceName, commitMess
 		return fmt.Errorf("failed to derive app.yml path: %v", err)
 	}
 
-	if err := a.GitExecutor.Run(a.GitDirectory, "git", "add", appYmlPath); err != nil {
+	if err := backend.Add(a.GitDirectory, appYmlPath); err != nil {
 		return fmt.Errorf("failed to add file %s: %v", appYmlPath, err)
 	}
 
-	if err := a.GitExecutor.Run(a.GitDirectory, "git", "commit", "-m", commitMessage); err != nil {
+	if err := backend.Commit(a.GitDirectory, commitMessage); err != nil {
 		return fmt.Errorf("failed to commit changes: %v", err)
 	}
 
@@ -781,3 +781,57 @@ func TestCheckAppInterfaceCheckout(t *testing.T) {
 		})
 	}
 }
+
+func TestGetAndSetSaasTargets(t *testing.T) {
+	saasYaml := `name: saas-test-operator
+resourceTemplates:
+- name: test-operator
+  url: https://github.com/test-org/test-operator
+  targets:
+  - name: test-operator-stage
+    namespace:
+      $ref: /services/osd-operators/namespaces/hivestage/test-operator.yml
+    ref: aaaaaaa
+  - name: test-operator-prod-canary
+    namespace:
+      $ref: /services/osd-operators/namespaces/hivep01/test-operator.yml
+    ref: bbbbbbb
+- name: test-operator-package
+  url: https://github.com/test-org/test-operator
+  targets:
+  - name: package-prod
+    namespace:
+      $ref: /services/osd-operators/namespaces/hivep01/test-operator.yml
+    ref: ccccccc
+`
+
+	targets, err := GetSaasTargets([]byte(saasYaml))
+	require.NoError(t, err)
+	require.Len(t, targets, 2)
+	assert.Equal(t, "test-operator-stage", targets[0].Name)
+	assert.Equal(t, "/services/osd-operators/namespaces/hivestage/test-operator.yml", targets[0].Namespace)
+	assert.Equal(t, "https://github.com/test-org/test-operator", targets[0].URL)
+	assert.Equal(t, "bbbbbbb", targets[1].Ref)
+
+	updated, err := SetSaasTargetRefs(saasYaml, targets[1:], "ddddddd")
+	require.NoError(t, err)
+
+	updatedTargets, err := GetSaasTargets([]byte(updated))
+	require.NoError(t, err)
+	assert.Equal(t, "aaaaaaa", updatedTargets[0].Ref)
+	assert.Equal(t, "ddddddd", updatedTargets[1].Ref)
+	assert.Contains(t, updated, "ref: ccccccc")
+
+	packageTargets, err := GetSaasPackageTargets([]byte(updated))
+	require.NoError(t, err)
+	require.Len(t, packageTargets, 1)
+	assert.Equal(t, "package-prod", packageTargets[0].Name)
+	assert.Equal(t, "ccccccc", packageTargets[0].Ref)
+
+	updated, err = SetSaasTargetPackageTags(updated, packageTargets, "v1.2.3")
+	require.NoError(t, err)
+	packageTargets, err = GetSaasPackageTargets([]byte(updated))
+	require.NoError(t, err)
+	assert.Equal(t, "v1.2.3", packageTargets[0].PackageTag)
+	assert.Equal(t, "ccccccc", packageTargets[0].Ref)
+}
@@ -0,0 +1,690 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/openshift/osdctl/cmd/promote/iexec"
+)
+
+const (
+	// GitBackendEnv selects the git implementation used for app-interface, the git binary runs unless it's set to GoGitBackendName
+	GitBackendEnv = "OSDCTL_PROMOTE_GIT_BACKEND"
+	// GoGitBackendName opts in to the go-git backend
+	GoGitBackendName = "go-git"
+)
+
+// emptyTree is the hash of the tree without any file, which git diffs root commits against
+const emptyTree = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// GitBackend abstracts the git operations performed on a local repository.
+// Paths are relative to the top level of the repository in dir, Add and Log also accept absolute paths.
+// The history operations also work on bare repositories.
+type GitBackend interface {
+	// TopLevel returns the top level directory of the repository containing dir
+	TopLevel(dir string) (string, error)
+	// Remotes returns the URLs of all remotes
+	Remotes(dir string) ([]string, error)
+	// Clone clones url into dir, a bare clone only has the history
+	Clone(url, dir string, bare bool) error
+	// Status returns the sorted paths with uncommitted changes, including untracked files
+	Status(dir string) ([]string, error)
+	// Checkout checks out an existing branch
+	Checkout(dir, branch string) error
+	// CreateBranch creates branch at start and checks it out
+	CreateBranch(dir, branch, start string) error
+	// DeleteBranch deletes a branch which isn't checked out
+	DeleteBranch(dir, branch string) error
+	// Add stages a file
+	Add(dir, path string) error
+	// Commit commits the staged changes
+	Commit(dir, message string) error
+	// Log returns up to max hashes of the commits reachable from rev touching path, newest first
+	Log(dir, rev, path string, max int) ([]string, error)
+	// Show returns the content of path at rev
+	Show(dir, rev, path string) ([]byte, error)
+	// Diff returns the sorted paths changed between the commits from and to, limited to path when it isn't empty.
+	// An empty from diffs against the empty tree.
+	Diff(dir, from, to, path string) ([]string, error)
+	// ResolveRevision returns the hash of the commit rev points to
+	ResolveRevision(dir, rev string) (string, error)
+	// LookupCommit returns the commit rev points to
+	LookupCommit(dir, rev string) (CommitInfo, error)
+	// Commits returns the commits reachable from to but not from from, newest first
+	Commits(dir, from, to string) ([]CommitInfo, error)
+	// CountCommits returns the number of commits reachable from to but not from from
+	CountCommits(dir, from, to string) (int, error)
+}
+
+// CommitInfo describes a commit of the history
+type CommitInfo struct {
+	Hash       string
+	Parents    []string
+	Author     string
+	AuthorDate time.Time
+	CommitDate time.Time
+	// Subject is the first paragraph of the message on a single line, Body the rest of the message
+	Subject string
+	Body    string
+}
+
+// DefaultGitBackend returns the go-git backend if GitBackendEnv is set to GoGitBackendName, otherwise the exec backend.
+// It is used for repositories which don't exist yet, like the clones of service repos.
+func DefaultGitBackend(gitExecutor iexec.IExec) GitBackend {
+	if os.Getenv(GitBackendEnv) == GoGitBackendName {
+		return GoGitBackend{}
+	}
+	return ExecBackend{GitExecutor: gitExecutor}
+}
+
+// NewGitBackend returns the DefaultGitBackend for the repository in dir, falling back to the exec backend
+// when go-git can't open the repository
+func NewGitBackend(dir string, gitExecutor iexec.IExec) GitBackend {
+	backend := DefaultGitBackend(gitExecutor)
+	if _, ok := backend.(GoGitBackend); !ok {
+		return backend
+	}
+	if _, err := openRepository(dir); err != nil {
+		fmt.Printf("Falling back to the git binary, go-git can't open %s: %v\n", dir, err)
+		return ExecBackend{GitExecutor: gitExecutor}
+	}
+	return backend
+}
+
+// ExecBackend implements GitBackend by running the git binary
+type ExecBackend struct {
+	GitExecutor iexec.IExec
+}
+
+func (b ExecBackend) TopLevel(dir string) (string, error) {
+	output, err := b.GitExecutor.Output(dir, "git", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
+
+func (b ExecBackend) Remotes(dir string) ([]string, error) {
+	output, err := b.GitExecutor.Output(dir, "git", "remote", "-v")
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && !contains(urls, fields[1]) {
+			urls = append(urls, fields[1])
+		}
+	}
+	return urls, nil
+}
+
+func (b ExecBackend) Clone(url, dir string, bare bool) error {
+	args := []string{"clone", "--quiet"}
+	if bare {
+		// The blobs aren't needed to walk the history
+		args = append(args, "--bare", "--filter=blob:none")
+	}
+	return b.GitExecutor.Run("", "git", append(args, url, dir)...)
+}
+
+func (b ExecBackend) Status(dir string) ([]string, error) {
+	output, err := b.GitExecutor.Output(dir, "git", "status", "--porcelain", "-z", "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	entries := strings.Split(output, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		paths = append(paths, entry[3:])
+		// Renames and copies are followed by their original path
+		if entry[0] == 'R' || entry[0] == 'C' {
+			i++
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func (b ExecBackend) Checkout(dir, branch string) error {
+	return b.GitExecutor.Run(dir, "git", "checkout", branch)
+}
+
+func (b ExecBackend) CreateBranch(dir, branch, start string) error {
+	return b.GitExecutor.Run(dir, "git", "checkout", "-b", branch, start)
+}
+
+func (b ExecBackend) DeleteBranch(dir, branch string) error {
+	return b.GitExecutor.Run(dir, "git", "branch", "-D", branch)
+}
+
+func (b ExecBackend) Add(dir, path string) error {
+	return b.GitExecutor.Run(dir, "git", "add", path)
+}
+
+func (b ExecBackend) Commit(dir, message string) error {
+	return b.GitExecutor.Run(dir, "git", "commit", "-m", message)
+}
+
+func (b ExecBackend) Log(dir, rev, path string, max int) ([]string, error) {
+	output, err := b.GitExecutor.Output(dir, "git", "log", "--format=%H", "-n", strconv.Itoa(max), rev, "--", path)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(output), nil
+}
+
+func (b ExecBackend) Show(dir, rev, path string) ([]byte, error) {
+	output, err := b.GitExecutor.Output(dir, "git", "show", fmt.Sprintf("%s:%s", rev, path))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(output), nil
+}
+
+func (b ExecBackend) Diff(dir, from, to, path string) ([]string, error) {
+	if from == "" {
+		from = emptyTree
+	}
+	args := []string{"diff", "--name-only", "-z", "--no-renames", from, to}
+	if path != "" {
+		args = append(args, "--", path)
+	}
+	output, err := b.GitExecutor.Output(dir, "git", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, path := range strings.Split(output, "\x00") {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func (b ExecBackend) ResolveRevision(dir, rev string) (string, error) {
+	output, err := b.GitExecutor.Output(dir, "git", "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %v", rev, err)
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// commitFormat prints the fields of CommitInfo separated by logFieldSep, every commit starts with logRecordSep
+const commitFormat = "--format=" + logRecordSep + "%H" + logFieldSep + "%P" + logFieldSep + "%an" + logFieldSep + "%aI" + logFieldSep + "%cI" + logFieldSep + "%s" + logFieldSep + "%b"
+
+func (b ExecBackend) LookupCommit(dir, rev string) (CommitInfo, error) {
+	output, err := b.GitExecutor.Output(dir, "git", "log", "-1", commitFormat, rev+"^{commit}", "--")
+	if err != nil {
+		return CommitInfo{}, fmt.Errorf("failed to find commit %s: %v", rev, err)
+	}
+	commits, err := parseCommits(output)
+	if err != nil {
+		return CommitInfo{}, err
+	}
+	if len(commits) != 1 {
+		return CommitInfo{}, fmt.Errorf("failed to find commit %s", rev)
+	}
+	return commits[0], nil
+}
+
+func (b ExecBackend) Commits(dir, from, to string) ([]CommitInfo, error) {
+	output, err := b.GitExecutor.Output(dir, "git", "log", commitFormat, fmt.Sprintf("%s..%s", from, to), "--")
+	if err != nil {
+		return nil, err
+	}
+	return parseCommits(output)
+}
+
+func (b ExecBackend) CountCommits(dir, from, to string) (int, error) {
+	output, err := b.GitExecutor.Output(dir, "git", "rev-list", "--count", fmt.Sprintf("%s..%s", from, to), "--")
+	if err != nil {
+		return 0, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(output))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected output of git rev-list: %v", err)
+	}
+	return count, nil
+}
+
+// parseCommits parses the output of git log with commitFormat
+func parseCommits(output string) ([]CommitInfo, error) {
+	var commits []CommitInfo
+	for _, record := range strings.Split(output, logRecordSep) {
+		fields := strings.SplitN(record, logFieldSep, 7)
+		if len(fields) < 7 {
+			continue
+		}
+		authorDate, err := time.Parse(time.RFC3339, fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected author date of commit %s: %v", fields[0], err)
+		}
+		commitDate, err := time.Parse(time.RFC3339, fields[4])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected commit date of commit %s: %v", fields[0], err)
+		}
+		commits = append(commits, CommitInfo{
+			Hash:       fields[0],
+			Parents:    strings.Fields(fields[1]),
+			Author:     fields[2],
+			AuthorDate: authorDate,
+			CommitDate: commitDate,
+			Subject:    fields[5],
+			Body:       strings.TrimSpace(fields[6]),
+		})
+	}
+	return commits, nil
+}
+
+// GoGitBackend implements GitBackend with go-git, without needing the git binary. Unlike the git binary it doesn't
+// run hooks, doesn't sign commits and refuses to check out a branch while the worktree has uncommitted changes.
+type GoGitBackend struct{}
+
+func openRepository(dir string) (*gogit.Repository, error) {
+	return gogit.PlainOpenWithOptions(dir, &gogit.PlainOpenOptions{DetectDotGit: true})
+}
+
+// openHistory opens the repository in dir, which unlike openRepository can also be a bare repository
+func openHistory(dir string) (*gogit.Repository, error) {
+	if repo, err := gogit.PlainOpen(dir); err == nil {
+		return repo, nil
+	}
+	return openRepository(dir)
+}
+
+func openWorktree(dir string) (*gogit.Repository, *gogit.Worktree, error) {
+	repo, err := openRepository(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, nil, err
+	}
+	return repo, worktree, nil
+}
+
+// resolveCommit returns the commit rev points to
+func resolveCommit(repo *gogit.Repository, rev string) (*object.Commit, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %v", rev, err)
+	}
+	return repo.CommitObject(*hash)
+}
+
+// repoPath returns path relative to the top level of the worktree, using forward slashes
+func repoPath(worktree *gogit.Worktree, dir, path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	rel, err := filepath.Rel(worktree.Filesystem.Root(), path)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("%s is outside of the repository", path)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+func (GoGitBackend) TopLevel(dir string) (string, error) {
+	_, worktree, err := openWorktree(dir)
+	if err != nil {
+		return "", err
+	}
+	return worktree.Filesystem.Root(), nil
+}
+
+func (GoGitBackend) Remotes(dir string) ([]string, error) {
+	repo, err := openRepository(dir)
+	if err != nil {
+		return nil, err
+	}
+	remotes, err := repo.Remotes()
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	for _, remote := range remotes {
+		for _, url := range remote.Config().URLs {
+			if !contains(urls, url) {
+				urls = append(urls, url)
+			}
+		}
+	}
+	return urls, nil
+}
+
+func (GoGitBackend) Clone(url, dir string, bare bool) error {
+	_, err := gogit.PlainClone(dir, bare, &gogit.CloneOptions{URL: url})
+	return err
+}
+
+func (GoGitBackend) Status(dir string) ([]string, error) {
+	_, worktree, err := openWorktree(dir)
+	if err != nil {
+		return nil, err
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for path, fileStatus := range status {
+		if fileStatus.Staging != gogit.Unmodified || fileStatus.Worktree != gogit.Unmodified {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func (GoGitBackend) Checkout(dir, branch string) error {
+	_, worktree, err := openWorktree(dir)
+	if err != nil {
+		return err
+	}
+	return worktree.Checkout(&gogit.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(branch)})
+}
+
+func (GoGitBackend) CreateBranch(dir, branch, start string) error {
+	repo, worktree, err := openWorktree(dir)
+	if err != nil {
+		return err
+	}
+	commit, err := resolveCommit(repo, start)
+	if err != nil {
+		return err
+	}
+	return worktree.Checkout(&gogit.CheckoutOptions{
+		Hash:   commit.Hash,
+		Branch: plumbing.NewBranchReferenceName(branch),
+		Create: true,
+	})
+}
+
+func (GoGitBackend) DeleteBranch(dir, branch string) error {
+	repo, err := openRepository(dir)
+	if err != nil {
+		return err
+	}
+
+	name := plumbing.NewBranchReferenceName(branch)
+	if _, err := repo.Reference(name, false); err != nil {
+		return fmt.Errorf("branch '%s' not found", branch)
+	}
+	if head, err := repo.Head(); err == nil && head.Name() == name {
+		return fmt.Errorf("cannot delete branch '%s' which is checked out", branch)
+	}
+
+	if err := repo.Storer.RemoveReference(name); err != nil {
+		return err
+	}
+	// The branch only has a config section when it tracks a remote branch
+	if err := repo.DeleteBranch(branch); err != nil && !errors.Is(err, gogit.ErrBranchNotFound) {
+		return err
+	}
+	return nil
+}
+
+func (GoGitBackend) Add(dir, path string) error {
+	_, worktree, err := openWorktree(dir)
+	if err != nil {
+		return err
+	}
+	rel, err := repoPath(worktree, dir, path)
+	if err != nil {
+		return err
+	}
+	_, err = worktree.Add(rel)
+	return err
+}
+
+func (GoGitBackend) Commit(dir, message string) error {
+	_, worktree, err := openWorktree(dir)
+	if err != nil {
+		return err
+	}
+	_, err = worktree.Commit(message, &gogit.CommitOptions{})
+	return err
+}
+
+func (GoGitBackend) Log(dir, rev, path string, max int) ([]string, error) {
+	repo, worktree, err := openWorktree(dir)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := resolveCommit(repo, rev)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := repoPath(worktree, dir, path)
+	if err != nil {
+		return nil, err
+	}
+
+	commits, err := repo.Log(&gogit.LogOptions{
+		From:       commit.Hash,
+		Order:      gogit.LogOrderCommitterTime,
+		PathFilter: func(p string) bool { return inPath(p, rel) },
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer commits.Close()
+
+	var hashes []string
+	err = commits.ForEach(func(c *object.Commit) error {
+		if len(hashes) >= max {
+			return storer.ErrStop
+		}
+		hashes = append(hashes, c.Hash.String())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+func (GoGitBackend) Show(dir, rev, path string) ([]byte, error) {
+	repo, worktree, err := openWorktree(dir)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := resolveCommit(repo, rev)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := repoPath(worktree, dir, path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := commit.File(rel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find %s in %s: %v", rel, rev, err)
+	}
+	content, err := file.Contents()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(content), nil
+}
+
+func (GoGitBackend) Diff(dir, from, to, path string) ([]string, error) {
+	repo, err := openHistory(dir)
+	if err != nil {
+		return nil, err
+	}
+	toCommit, err := resolveCommit(repo, to)
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := toCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	var fromTree *object.Tree
+	if from != "" {
+		fromCommit, err := resolveCommit(repo, from)
+		if err != nil {
+			return nil, err
+		}
+		if fromTree, err = fromCommit.Tree(); err != nil {
+			return nil, err
+		}
+	}
+
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.Trim(filepath.ToSlash(path), "/")
+	var paths []string
+	for _, change := range changes {
+		for _, name := range []string{change.From.Name, change.To.Name} {
+			if name != "" && inPath(name, prefix) && !contains(paths, name) {
+				paths = append(paths, name)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func (GoGitBackend) ResolveRevision(dir, rev string) (string, error) {
+	repo, err := openHistory(dir)
+	if err != nil {
+		return "", err
+	}
+	commit, err := resolveCommit(repo, rev)
+	if err != nil {
+		return "", err
+	}
+	return commit.Hash.String(), nil
+}
+
+func (GoGitBackend) LookupCommit(dir, rev string) (CommitInfo, error) {
+	repo, err := openHistory(dir)
+	if err != nil {
+		return CommitInfo{}, err
+	}
+	commit, err := resolveCommit(repo, rev)
+	if err != nil {
+		return CommitInfo{}, err
+	}
+	return newCommitInfo(commit), nil
+}
+
+func (GoGitBackend) Commits(dir, from, to string) ([]CommitInfo, error) {
+	commits, err := goGitCommits(dir, from, to)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]CommitInfo, len(commits))
+	for i, commit := range commits {
+		infos[i] = newCommitInfo(commit)
+	}
+	return infos, nil
+}
+
+func (GoGitBackend) CountCommits(dir, from, to string) (int, error) {
+	commits, err := goGitCommits(dir, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return len(commits), nil
+}
+
+// goGitCommits returns the commits reachable from to but not from from, sorted by commit date like git log
+func goGitCommits(dir, from, to string) ([]*object.Commit, error) {
+	repo, err := openHistory(dir)
+	if err != nil {
+		return nil, err
+	}
+	fromCommit, err := resolveCommit(repo, from)
+	if err != nil {
+		return nil, err
+	}
+	toCommit, err := resolveCommit(repo, to)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := map[plumbing.Hash]bool{}
+	err = object.NewCommitPreorderIter(fromCommit, nil, nil).ForEach(func(c *object.Commit) error {
+		excluded[c.Hash] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []*object.Commit
+	err = object.NewCommitPreorderIter(toCommit, excluded, nil).ForEach(func(c *object.Commit) error {
+		commits = append(commits, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(commits, func(i, j int) bool {
+		return commits[i].Committer.When.After(commits[j].Committer.When)
+	})
+	return commits, nil
+}
+
+func newCommitInfo(commit *object.Commit) CommitInfo {
+	info := CommitInfo{
+		Hash:       commit.Hash.String(),
+		Author:     commit.Author.Name,
+		AuthorDate: commit.Author.When,
+		CommitDate: commit.Committer.When,
+	}
+	for _, parent := range commit.ParentHashes {
+		info.Parents = append(info.Parents, parent.String())
+	}
+	// Like git, the subject is the first paragraph joined on a single line
+	subject, body, _ := strings.Cut(strings.TrimSpace(commit.Message), "\n\n")
+	info.Subject = strings.ReplaceAll(subject, "\n", " ")
+	info.Body = strings.TrimSpace(body)
+	return info
+}
+
+// inPath returns whether the slash separated path p is prefix or inside it, every path is inside an empty prefix
+func inPath(p, prefix string) bool {
+	return prefix == "" || prefix == "." || p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
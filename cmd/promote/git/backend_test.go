@@ -0,0 +1,227 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openshift/osdctl/cmd/promote/iexec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withGitIdentity points the global git config of both backends to a file with a user identity
+func withGitIdentity(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	config := filepath.Join(home, ".gitconfig")
+	require.NoError(t, os.WriteFile(config, []byte("[user]\n\tname = Test\n\temail = test@example.com\n[commit]\n\tgpgsign = false\n"), 0o600))
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("GIT_CONFIG_GLOBAL", config)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+}
+
+func testBackends() map[string]GitBackend {
+	return map[string]GitBackend{
+		"exec":   ExecBackend{GitExecutor: iexec.Exec{}},
+		"go-git": GoGitBackend{},
+	}
+}
+
+func TestGitBackend_BranchAndCommit(t *testing.T) {
+	for name, backend := range testBackends() {
+		t.Run(name, func(t *testing.T) {
+			withGitIdentity(t)
+			repo := newTestRepo(t)
+			repo.git("branch", "-m", "master")
+			repo.git("remote", "add", "origin", "https://gitlab.cee.redhat.com/service/app-interface.git")
+			repo.commit("Initial commit", "data/saas.yaml")
+
+			topLevel, err := backend.TopLevel(filepath.Join(repo.dir, "data"))
+			require.NoError(t, err)
+			assert.Equal(t, repo.dir, topLevel)
+
+			remotes, err := backend.Remotes(repo.dir)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"https://gitlab.cee.redhat.com/service/app-interface.git"}, remotes)
+
+			require.NoError(t, backend.CreateBranch(repo.dir, "promote-foo", "master"))
+			assert.Equal(t, "promote-foo", repo.git("rev-parse", "--abbrev-ref", "HEAD"))
+
+			file := filepath.Join(repo.dir, "data", "saas.yaml")
+			require.NoError(t, os.WriteFile(file, []byte("ref: abcdef0\n"), 0o600))
+			require.NoError(t, os.WriteFile(filepath.Join(repo.dir, "untracked.txt"), []byte("x"), 0o600))
+
+			require.NoError(t, backend.Add(repo.dir, file))
+			require.NoError(t, backend.Commit(repo.dir, "Promote foo to abcdef0"))
+			assert.Equal(t, "Promote foo to abcdef0", repo.git("log", "-1", "--format=%s"))
+			assert.Equal(t, "Test", repo.git("log", "-1", "--format=%an"))
+			assert.Equal(t, "?? untracked.txt", repo.git("status", "--porcelain"))
+
+			assert.Error(t, backend.DeleteBranch(repo.dir, "promote-foo"), "the checked out branch can't be deleted")
+			require.NoError(t, backend.Checkout(repo.dir, "master"))
+			require.NoError(t, backend.DeleteBranch(repo.dir, "promote-foo"))
+			assert.Error(t, backend.DeleteBranch(repo.dir, "promote-foo"))
+			assert.Error(t, backend.Checkout(repo.dir, "promote-foo"))
+
+			content, err := os.ReadFile(file)
+			require.NoError(t, err)
+			assert.Equal(t, "Initial commit\n", string(content))
+		})
+	}
+}
+
+func TestGitBackend_LogAndShow(t *testing.T) {
+	for name, backend := range testBackends() {
+		t.Run(name, func(t *testing.T) {
+			withGitIdentity(t)
+			repo := newTestRepo(t)
+			first := repo.commit("v1", "data/saas.yaml")
+			repo.commit("other", "data/other.yaml")
+			second := repo.commit("v2", "data/saas.yaml")
+			third := repo.commit("v3", "data/saas.yaml")
+
+			commits, err := backend.Log(repo.dir, "main", "data/saas.yaml", 10)
+			require.NoError(t, err)
+			assert.Equal(t, []string{third, second, first}, commits)
+
+			commits, err = backend.Log(repo.dir, "main", filepath.Join(repo.dir, "data", "saas.yaml"), 2)
+			require.NoError(t, err)
+			assert.Equal(t, []string{third, second}, commits)
+
+			content, err := backend.Show(repo.dir, second, "data/saas.yaml")
+			require.NoError(t, err)
+			assert.Equal(t, "v2\n", string(content))
+
+			_, err = backend.Show(repo.dir, first, "data/other.yaml")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestGitBackend_StatusAndDiff(t *testing.T) {
+	for name, backend := range testBackends() {
+		t.Run(name, func(t *testing.T) {
+			withGitIdentity(t)
+			repo := newTestRepo(t)
+			first := repo.commit("v1", "data/saas.yaml", "data/other.yaml", "README.md")
+
+			paths, err := backend.Status(repo.dir)
+			require.NoError(t, err)
+			assert.Empty(t, paths)
+
+			require.NoError(t, os.WriteFile(filepath.Join(repo.dir, "data", "saas.yaml"), []byte("v2\n"), 0o600))
+			require.NoError(t, os.Remove(filepath.Join(repo.dir, "README.md")))
+			require.NoError(t, os.MkdirAll(filepath.Join(repo.dir, "data", "new"), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(repo.dir, "data", "new", "file with spaces.yaml"), []byte("new\n"), 0o600))
+
+			paths, err = backend.Status(repo.dir)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"README.md", "data/new/file with spaces.yaml", "data/saas.yaml"}, paths)
+
+			for _, path := range paths {
+				require.NoError(t, backend.Add(repo.dir, path))
+			}
+			require.NoError(t, backend.Commit(repo.dir, "v2"))
+			second := repo.git("rev-parse", "HEAD")
+			assert.Empty(t, repo.git("status", "--porcelain"))
+
+			files, err := backend.Diff(repo.dir, first, second, "")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"README.md", "data/new/file with spaces.yaml", "data/saas.yaml"}, files)
+
+			files, err = backend.Diff(repo.dir, first, second, "data")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"data/new/file with spaces.yaml", "data/saas.yaml"}, files)
+
+			files, err = backend.Diff(repo.dir, "", first, "data")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"data/other.yaml", "data/saas.yaml"}, files)
+		})
+	}
+}
+
+func TestGitBackend_History(t *testing.T) {
+	for name, backend := range testBackends() {
+		t.Run(name, func(t *testing.T) {
+			withGitIdentity(t)
+			repo := newTestRepo(t)
+			first := repo.commit("v1", "data/saas.yaml")
+			repo.git("checkout", "-q", "-b", "feature")
+			feature := repo.commit("Feature for OSD-1\nwith a long subject\n\nThe body", "data/feature.yaml")
+			repo.git("checkout", "-q", "main")
+			repo.git("merge", "-q", "--no-ff", "feature", "-m", "Merge pull request #1 from org/feature")
+			merge := repo.git("rev-parse", "HEAD")
+
+			// The history is also read from bare clones
+			clone := filepath.Join(t.TempDir(), "clone")
+			require.NoError(t, backend.Clone(repo.dir, clone, true))
+
+			for _, dir := range []string{repo.dir, clone} {
+				hash, err := backend.ResolveRevision(dir, "HEAD")
+				require.NoError(t, err)
+				assert.Equal(t, merge, hash)
+				_, err = backend.ResolveRevision(dir, "bbbbbbb")
+				assert.Error(t, err)
+
+				commits, err := backend.Commits(dir, first, "HEAD")
+				require.NoError(t, err)
+				require.Len(t, commits, 2)
+				assert.Equal(t, merge, commits[0].Hash)
+				assert.Equal(t, []string{first, feature}, commits[0].Parents)
+				assert.Equal(t, "Merge pull request #1 from org/feature", commits[0].Subject)
+				assert.Equal(t, feature, commits[1].Hash)
+				assert.Equal(t, "Feature for OSD-1 with a long subject", commits[1].Subject)
+				assert.Equal(t, "The body", commits[1].Body)
+				assert.Equal(t, "Test", commits[1].Author)
+
+				count, err := backend.CountCommits(dir, first, "HEAD")
+				require.NoError(t, err)
+				assert.Equal(t, 2, count)
+				count, err = backend.CountCommits(dir, "HEAD", first)
+				require.NoError(t, err)
+				assert.Equal(t, 0, count)
+
+				commit, err := backend.LookupCommit(dir, first)
+				require.NoError(t, err)
+				assert.Equal(t, "v1", commit.Subject)
+				assert.Empty(t, commit.Parents)
+				assert.False(t, commit.CommitDate.IsZero())
+			}
+		})
+	}
+}
+
+func TestAppInterface_GoGitBackend(t *testing.T) {
+	withGitIdentity(t)
+	repo := newTestRepo(t)
+	repo.git("branch", "-m", "master")
+	repo.git("remote", "add", "origin", "git@gitlab.cee.redhat.com:service/app-interface.git")
+	repo.commit("Initial commit", "data/saas.yaml")
+
+	a := AppInterface{GitDirectory: repo.dir, Git: GoGitBackend{}}
+	require.NoError(t, a.checkAppInterfaceCheckout())
+
+	// An existing branch of a previous run is recreated from master
+	repo.git("branch", "promote-foo")
+	require.NoError(t, a.CreatePromotionBranch("promote-foo"))
+	saasFile := filepath.Join(repo.dir, "data", "saas.yaml")
+	require.NoError(t, os.WriteFile(saasFile, []byte("ref: abcdef0\n"), 0o600))
+	require.NoError(t, a.CommitSaasFile(saasFile, "Promote foo"))
+
+	assert.Equal(t, "promote-foo", repo.git("rev-parse", "--abbrev-ref", "HEAD"))
+	assert.Equal(t, "Promote foo", repo.git("log", "-1", "--format=%s"))
+	assert.Empty(t, repo.git("status", "--porcelain"))
+}
+
+func TestNewGitBackend(t *testing.T) {
+	repo := newTestRepo(t)
+
+	t.Setenv(GitBackendEnv, "")
+	assert.IsType(t, ExecBackend{}, NewGitBackend(repo.dir, iexec.Exec{}))
+
+	t.Setenv(GitBackendEnv, GoGitBackendName)
+	assert.IsType(t, GoGitBackend{}, NewGitBackend(repo.dir, iexec.Exec{}))
+	assert.IsType(t, ExecBackend{}, NewGitBackend(t.TempDir(), iexec.Exec{}))
+}
@@ -0,0 +1,311 @@
+package git
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Risk categories of changed files that deserve a closer look during review
+const (
+	RiskCRD          = "crd"
+	RiskRBAC         = "rbac"
+	RiskDeploy       = "deploy-manifests"
+	RiskDependencies = "dependencies"
+)
+
+const (
+	// separators used in the git log format, they can't appear in commit messages
+	logRecordSep = "\x1e"
+	logFieldSep  = "\x1f"
+)
+
+var (
+	jiraKeyPattern     = regexp.MustCompile(`\b[A-Z][A-Z0-9]{1,9}-[1-9][0-9]*\b`)
+	mergePRPattern     = regexp.MustCompile(`^Merge pull request #([0-9]+)`)
+	squashPRPattern    = regexp.MustCompile(`\(#([0-9]+)\)\s*$`)
+	notJiraKeyPrefixes = map[string]bool{"UTF": true, "SHA": true, "RFC": true, "ISO": true, "TLS": true, "HTTP": true, "CVE": true}
+)
+
+// Changelog describes the commits between two hashes of a service repo
+type Changelog struct {
+	From         string            `json:"from"`
+	To           string            `json:"to"`
+	Path         string            `json:"path,omitempty"`
+	PullRequests []PullRequest     `json:"pullRequests"`
+	Commits      []ChangelogCommit `json:"commits"`
+	JiraKeys     []string          `json:"jiraKeys"`
+	Risks        []RiskFlag        `json:"risks"`
+}
+
+// PullRequest groups the commits that were merged with the same pull request
+type PullRequest struct {
+	Number   int               `json:"number"`
+	Title    string            `json:"title"`
+	Commits  []ChangelogCommit `json:"commits"`
+	JiraKeys []string          `json:"jiraKeys"`
+}
+
+type ChangelogCommit struct {
+	Hash     string   `json:"hash"`
+	Author   string   `json:"author"`
+	Date     string   `json:"date"`
+	Subject  string   `json:"subject"`
+	JiraKeys []string `json:"jiraKeys,omitempty"`
+	Files    []string `json:"files,omitempty"`
+}
+
+// RiskFlag lists the changed files of one risk category
+type RiskFlag struct {
+	Category string   `json:"category"`
+	Files    []string `json:"files"`
+}
+
+// BuildChangelog reads the commits between from and to of the repo checked out in dir,
+// optionally limited to the commits touching servicePath. Only the local checkout is used.
+func BuildChangelog(backend GitBackend, dir, from, to, servicePath string) (*Changelog, error) {
+	history, err := backend.Commits(dir, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git log: %v", err)
+	}
+
+	var commits []ChangelogCommit
+	var merges []CommitInfo
+	for _, commit := range history {
+		if len(commit.Parents) > 1 {
+			merges = append(merges, commit)
+			continue
+		}
+		parent := ""
+		if len(commit.Parents) == 1 {
+			parent = commit.Parents[0]
+		}
+		files, err := backend.Diff(dir, parent, commit.Hash, servicePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the files changed by %s: %v", commit.Hash, err)
+		}
+		if servicePath != "" && len(files) == 0 {
+			continue
+		}
+		commits = append(commits, ChangelogCommit{
+			Hash:     commit.Hash,
+			Author:   commit.Author,
+			Date:     commit.AuthorDate.Format(time.DateOnly),
+			Subject:  commit.Subject,
+			JiraKeys: jiraKeys(commit.Subject + "\n" + commit.Body),
+			Files:    files,
+		})
+	}
+
+	// Merge commits tell which commits belong to a pull request
+	prOfCommit := map[string]int{}
+	prTitles := map[int]string{}
+	for _, merge := range merges {
+		match := mergePRPattern.FindStringSubmatch(merge.Subject)
+		if match == nil {
+			continue
+		}
+		number, _ := strconv.Atoi(match[1])
+		if servicePath != "" {
+			// Pull requests which didn't change the path aren't part of the changelog
+			files, err := backend.Diff(dir, merge.Parents[0], merge.Hash, servicePath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the files changed by pull request #%d: %v", number, err)
+			}
+			if len(files) == 0 {
+				continue
+			}
+		}
+		if merge.Body != "" {
+			prTitles[number] = strings.TrimSpace(strings.SplitN(merge.Body, "\n", 2)[0])
+		}
+
+		// The commits of the PR are reachable from the second parent but not the first
+		prCommits, err := backend.Commits(dir, merge.Parents[0], merge.Parents[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the commits of pull request #%d: %v", number, err)
+		}
+		for _, commit := range prCommits {
+			if _, ok := prOfCommit[commit.Hash]; !ok {
+				prOfCommit[commit.Hash] = number
+			}
+		}
+	}
+
+	return newChangelog(from, to, servicePath, commits, prOfCommit, prTitles), nil
+}
+
+func newChangelog(from, to, servicePath string, commits []ChangelogCommit, prOfCommit map[string]int, prTitles map[int]string) *Changelog {
+	c := &Changelog{
+		From:         from,
+		To:           to,
+		Path:         servicePath,
+		PullRequests: []PullRequest{},
+		Commits:      []ChangelogCommit{},
+		JiraKeys:     []string{},
+		Risks:        []RiskFlag{},
+	}
+
+	prIndex := map[int]int{}
+	riskFiles := map[string][]string{}
+	var allKeys []string
+	for _, commit := range commits {
+		allKeys = append(allKeys, commit.JiraKeys...)
+		for _, file := range commit.Files {
+			if category := fileRisk(file); category != "" {
+				riskFiles[category] = append(riskFiles[category], file)
+			}
+		}
+
+		number, ok := prOfCommit[commit.Hash]
+		if !ok {
+			if match := squashPRPattern.FindStringSubmatch(commit.Subject); match != nil {
+				number, _ = strconv.Atoi(match[1])
+				ok = true
+			}
+		}
+		if !ok {
+			c.Commits = append(c.Commits, commit)
+			continue
+		}
+
+		i, exists := prIndex[number]
+		if !exists {
+			title := prTitles[number]
+			if title == "" {
+				title = strings.TrimSpace(squashPRPattern.ReplaceAllString(commit.Subject, ""))
+			}
+			c.PullRequests = append(c.PullRequests, PullRequest{Number: number, Title: title})
+			i = len(c.PullRequests) - 1
+			prIndex[number] = i
+		}
+		pr := &c.PullRequests[i]
+		pr.Commits = append(pr.Commits, commit)
+		pr.JiraKeys = uniqueSorted(append(pr.JiraKeys, commit.JiraKeys...))
+	}
+
+	c.JiraKeys = uniqueSorted(allKeys)
+	for _, category := range []string{RiskCRD, RiskRBAC, RiskDeploy, RiskDependencies} {
+		if files, ok := riskFiles[category]; ok {
+			c.Risks = append(c.Risks, RiskFlag{Category: category, Files: uniqueSorted(files)})
+		}
+	}
+
+	return c
+}
+
+func jiraKeys(message string) []string {
+	var keys []string
+	for _, key := range jiraKeyPattern.FindAllString(message, -1) {
+		prefix, _, _ := strings.Cut(key, "-")
+		if !notJiraKeyPrefixes[prefix] {
+			keys = append(keys, key)
+		}
+	}
+	return uniqueSorted(keys)
+}
+
+// fileRisk returns the risk category of a changed file, or "" if it isn't risky
+func fileRisk(file string) string {
+	lower := strings.ToLower(file)
+	base := path.Base(lower)
+	isManifest := strings.HasSuffix(base, ".yaml") || strings.HasSuffix(base, ".yml") || strings.HasSuffix(base, ".json")
+
+	switch {
+	case base == "go.mod" || base == "go.sum":
+		return RiskDependencies
+	case isManifest && (strings.Contains(lower, "crd") || strings.Contains(lower, "customresourcedefinition")):
+		return RiskCRD
+	case isManifest && (strings.Contains(lower, "rbac") || strings.Contains(base, "role") || strings.Contains(base, "serviceaccount")):
+		return RiskRBAC
+	case isManifest && (strings.HasPrefix(lower, "deploy/") || strings.Contains(lower, "/deploy/") ||
+		strings.HasPrefix(lower, "manifests/") || strings.Contains(lower, "/manifests/") ||
+		strings.Contains(lower, "olm-registry") || strings.Contains(base, "deployment")):
+		return RiskDeploy
+	}
+	return ""
+}
+
+func uniqueSorted(values []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+func shortSha(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+// Markdown renders the changelog for a merge request description
+func (c *Changelog) Markdown() string {
+	var b strings.Builder
+
+	if len(c.Risks) > 0 {
+		b.WriteString("### ⚠️ Review carefully\n\n")
+		for _, risk := range c.Risks {
+			fmt.Fprintf(&b, "- **%s**: %s\n", risk.Category, strings.Join(quoted(risk.Files), ", "))
+		}
+		b.WriteString("\n")
+	}
+
+	if len(c.JiraKeys) > 0 {
+		fmt.Fprintf(&b, "**Jira:** %s\n\n", strings.Join(c.JiraKeys, ", "))
+	}
+
+	if len(c.PullRequests) > 0 {
+		b.WriteString("### Pull Requests\n\n")
+		for _, pr := range c.PullRequests {
+			fmt.Fprintf(&b, "- #%d %s", pr.Number, pr.Title)
+			if len(pr.JiraKeys) > 0 {
+				fmt.Fprintf(&b, " (%s)", strings.Join(pr.JiraKeys, ", "))
+			}
+			b.WriteString("\n")
+			for _, commit := range pr.Commits {
+				fmt.Fprintf(&b, "  - %s %s (%s)\n", shortSha(commit.Hash), commit.Subject, commit.Author)
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	if len(c.Commits) > 0 {
+		b.WriteString("### Commits without Pull Request\n\n")
+		for _, commit := range c.Commits {
+			fmt.Fprintf(&b, "- %s %s (%s)\n", shortSha(commit.Hash), commit.Subject, commit.Author)
+		}
+		b.WriteString("\n")
+	}
+
+	if len(c.PullRequests) == 0 && len(c.Commits) == 0 {
+		b.WriteString("No changes\n")
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// JSON renders the changelog for tooling
+func (c *Changelog) JSON() ([]byte, error) {
+	return json.MarshalIndent(c, "", "  ")
+}
+
+func quoted(values []string) []string {
+	q := make([]string, len(values))
+	for i, v := range values {
+		q[i] = "`" + v + "`"
+	}
+	return q
+}
@@ -0,0 +1,197 @@
+package git
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRepo is a git repository in a temporary directory
+type testRepo struct {
+	t   *testing.T
+	dir string
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	r := &testRepo{t: t, dir: t.TempDir()}
+	r.git("init", "-q", "-b", "main")
+	return r
+}
+
+func (r *testRepo) git(args ...string) string {
+	r.t.Helper()
+	args = append([]string{"-c", "user.name=Test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(r.t, err, "git %v: %s", args, out)
+	return strings.TrimSpace(string(out))
+}
+
+// commit writes the files and commits them, returning the hash
+func (r *testRepo) commit(message string, files ...string) string {
+	r.t.Helper()
+	for _, file := range files {
+		path := filepath.Join(r.dir, file)
+		require.NoError(r.t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(r.t, os.WriteFile(path, []byte(message+"\n"), 0o600))
+		r.git("add", file)
+	}
+	r.git("commit", "-q", "--allow-empty", "-m", message)
+	return r.git("rev-parse", "HEAD")
+}
+
+func TestBuildChangelog(t *testing.T) {
+	for name, backend := range testBackends() {
+		t.Run(name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			base := repo.commit("Initial commit", "README.md")
+
+			repo.commit("Add foo CRD (#12)\n\nFixes OSD-1234, UTF-8 is not a Jira key", "deploy/crds/foo_crd.yaml")
+
+			repo.git("checkout", "-q", "-b", "feature")
+			repo.commit("Grant access to secrets", "deploy/rbac/role.yaml")
+			repo.commit("Bump dependencies for SREP-99", "go.mod", "go.sum")
+			repo.git("checkout", "-q", "main")
+			repo.git("merge", "-q", "--no-ff", "feature", "-m", "Merge pull request #15 from org/feature\n\nImprove secret handling")
+
+			repo.commit("Fix typo", "README.md")
+			head := repo.git("rev-parse", "HEAD")
+
+			changelog, err := BuildChangelog(backend, repo.dir, base, head, "")
+			require.NoError(t, err)
+
+			require.Len(t, changelog.PullRequests, 2)
+			assert.Equal(t, 12, changelog.PullRequests[0].Number)
+			assert.Equal(t, "Add foo CRD", changelog.PullRequests[0].Title)
+			assert.Equal(t, []string{"OSD-1234"}, changelog.PullRequests[0].JiraKeys)
+			assert.Equal(t, 15, changelog.PullRequests[1].Number)
+			assert.Equal(t, "Improve secret handling", changelog.PullRequests[1].Title)
+			assert.Len(t, changelog.PullRequests[1].Commits, 2)
+
+			require.Len(t, changelog.Commits, 1)
+			assert.Equal(t, "Fix typo", changelog.Commits[0].Subject)
+
+			assert.Equal(t, []string{"OSD-1234", "SREP-99"}, changelog.JiraKeys)
+			assert.Equal(t, []RiskFlag{
+				{Category: RiskCRD, Files: []string{"deploy/crds/foo_crd.yaml"}},
+				{Category: RiskRBAC, Files: []string{"deploy/rbac/role.yaml"}},
+				{Category: RiskDependencies, Files: []string{"go.mod", "go.sum"}},
+			}, changelog.Risks)
+
+			markdown := changelog.Markdown()
+			assert.Contains(t, markdown, "- **crd**: `deploy/crds/foo_crd.yaml`")
+			assert.Contains(t, markdown, "- #15 Improve secret handling (SREP-99)")
+			assert.Contains(t, markdown, "### Commits without Pull Request")
+
+			data, err := changelog.JSON()
+			require.NoError(t, err)
+			var decoded Changelog
+			require.NoError(t, json.Unmarshal(data, &decoded))
+			assert.Equal(t, changelog.JiraKeys, decoded.JiraKeys)
+		})
+	}
+}
+
+func TestBuildChangelog_Path(t *testing.T) {
+	for name, backend := range testBackends() {
+		t.Run(name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			base := repo.commit("Initial commit", "README.md")
+			repo.commit("Change the operator", "operators/dynatrace/deploy/deployment.yaml")
+			repo.commit("Change something else", "other/main.go")
+			head := repo.git("rev-parse", "HEAD")
+
+			changelog, err := BuildChangelog(backend, repo.dir, base, head, "operators/dynatrace")
+			require.NoError(t, err)
+
+			require.Len(t, changelog.Commits, 1)
+			assert.Equal(t, "Change the operator", changelog.Commits[0].Subject)
+			assert.Equal(t, []RiskFlag{{Category: RiskDeploy, Files: []string{"operators/dynatrace/deploy/deployment.yaml"}}}, changelog.Risks)
+		})
+	}
+}
+
+// commitsRecorder records the ranges the commits are listed for
+type commitsRecorder struct {
+	GitBackend
+	ranges [][2]string
+}
+
+func (r *commitsRecorder) Commits(dir, from, to string) ([]CommitInfo, error) {
+	r.ranges = append(r.ranges, [2]string{from, to})
+	return r.GitBackend.Commits(dir, from, to)
+}
+
+// Should skip the pull requests whose merge doesn't change the path
+func TestBuildChangelog_PathMerges(t *testing.T) {
+	for name, backend := range testBackends() {
+		t.Run(name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			base := repo.commit("Initial commit", "README.md")
+
+			repo.git("checkout", "-q", "-b", "operator")
+			repo.commit("Change the operator", "operators/dynatrace/main.go")
+			repo.git("checkout", "-q", "main")
+			repo.git("merge", "-q", "--no-ff", "operator", "-m", "Merge pull request #15 from org/operator\n\nImprove the operator")
+
+			repo.git("checkout", "-q", "-b", "other")
+			other := repo.commit("Change something else", "other/main.go")
+			repo.git("checkout", "-q", "main")
+			repo.git("merge", "-q", "--no-ff", "other", "-m", "Merge pull request #20 from org/other\n\nImprove something else")
+			head := repo.git("rev-parse", "HEAD")
+
+			recorder := &commitsRecorder{GitBackend: backend}
+			changelog, err := BuildChangelog(recorder, repo.dir, base, head, "operators/dynatrace")
+			require.NoError(t, err)
+
+			require.Len(t, changelog.PullRequests, 1)
+			assert.Equal(t, 15, changelog.PullRequests[0].Number)
+			assert.Equal(t, "Improve the operator", changelog.PullRequests[0].Title)
+			assert.Empty(t, changelog.Commits)
+			for _, r := range recorder.ranges {
+				assert.NotEqual(t, other, r[1], "the commits of pull request #20 were listed")
+			}
+		})
+	}
+}
+
+func TestFileRisk(t *testing.T) {
+	tests := map[string]string{
+		"go.mod":                                  RiskDependencies,
+		"tools/go.sum":                            RiskDependencies,
+		"deploy/crds/foo.yaml":                    RiskCRD,
+		"config/crd/bases/x.yaml":                 RiskCRD,
+		"deploy/05_role.yaml":                     RiskRBAC,
+		"config/rbac/leader_election.yaml":        RiskRBAC,
+		"deploy/operator.yaml":                    RiskDeploy,
+		"hack/olm-registry/olm-artifacts.yaml":    RiskDeploy,
+		"resources/deployment.yml":                RiskDeploy,
+		"pkg/controller/role.go":                  "",
+		"docs/README.md":                          "",
+		"deploy/README.md":                        "",
+		"config/manager/kustomization_patch.yaml": "",
+	}
+
+	for file, expected := range tests {
+		t.Run(file, func(t *testing.T) {
+			assert.Equal(t, expected, fileRisk(file))
+		})
+	}
+}
+
+func TestJiraKeys(t *testing.T) {
+	assert.Equal(t, []string{"OSD-1", "SREP-1234"}, jiraKeys("SREP-1234: fix OSD-1, see SREP-1234 and RFC-7231 in UTF-8"))
+	assert.Empty(t, jiraKeys("no keys here, not even A-1 or OSD-0"))
+}
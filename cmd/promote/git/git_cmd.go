@@ -1,7 +1,6 @@
 package git
 
 import (
-	"strings"
 	"sync"
 
 	"github.com/openshift/osdctl/cmd/promote/iexec"
@@ -13,16 +12,11 @@ var (
 	baseDirErr  error
 )
 
-// GetBaseDir returns the base directory of the git repository, this can only be called once per process
+// GetBaseDir returns the base directory of the git repository in the current directory, found with the backend
+// selected by GitBackendEnv, this can only be called once per process
 func GetBaseDir(exec iexec.IExec) (string, error) {
 	baseDirOnce.Do(func() {
-		baseDirOutput, err := exec.Output("", "git", "rev-parse", "--show-toplevel")
-		if err != nil {
-			baseDirErr = err
-			return
-		}
-
-		BaseDir = strings.TrimSpace(baseDirOutput)
+		BaseDir, baseDirErr = NewGitBackend("", exec).TopLevel("")
 	})
 
 	return BaseDir, baseDirErr
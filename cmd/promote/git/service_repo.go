@@ -3,62 +3,103 @@ package git
 import (
 	"fmt"
 	"os"
-	"strings"
-
-	"github.com/openshift/osdctl/cmd/promote/iexec"
+	"path/filepath"
 )
 
-func CheckoutAndCompareGitHash(gitExecutor iexec.IExec, gitURL, gitHash, currentGitHash string, serviceFullPath string) (string, string, error) {
-	tempDir, err := os.MkdirTemp("", "")
+// CheckoutAndCompareGitHash clones the service repo and returns the hash to promote (HEAD when gitHash is empty)
+// and the changelog since currentGitHash rendered as Markdown
+func CheckoutAndCompareGitHash(backend GitBackend, gitURL, gitHash, currentGitHash string, serviceFullPath string) (string, string, error) {
+	gitHash, changelog, err := CheckoutAndBuildChangelog(backend, gitURL, gitHash, currentGitHash, serviceFullPath)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to create temporary directory: %v", err)
+		return "", "", err
 	}
-	defer os.RemoveAll(tempDir)
+	return gitHash, changelog.Markdown(), nil
+}
 
-	err = os.Chdir(tempDir)
+// CheckoutAndBuildChangelog is like CheckoutAndCompareGitHash, but returns the structured changelog
+func CheckoutAndBuildChangelog(backend GitBackend, gitURL, gitHash, currentGitHash string, serviceFullPath string) (string, *Changelog, error) {
+	tempDir, err := os.MkdirTemp("", "")
 	if err != nil {
-		return "", "", fmt.Errorf("failed to change directory to temporary directory: %v", err)
+		return "", nil, fmt.Errorf("failed to create temporary directory: %v", err)
 	}
+	defer os.RemoveAll(tempDir)
 
-	err = gitExecutor.Run(tempDir, "git", "clone", gitURL, "source-dir")
-	if err != nil {
-		return "", "", fmt.Errorf("failed to clone git repository: %v", err)
-	}
-	err = os.Chdir("source-dir")
+	sourceDir := filepath.Join(tempDir, "source-dir")
+	err = backend.Clone(gitURL, sourceDir, false)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to change directory to source-dir: %v", err)
+		return "", nil, fmt.Errorf("failed to clone git repository: %v", err)
 	}
 
 	if gitHash == "" {
 		fmt.Printf("No git hash provided. Using HEAD.\n")
-		var output string
 		// If serviceFullPath is provided, get the latest commit for that specific path
 		if serviceFullPath != "" {
-			output, err = gitExecutor.Output("", "git", "rev-list", "-n", "1", "HEAD", "--", serviceFullPath)
+			hashes, err := backend.Log(sourceDir, "HEAD", serviceFullPath, 1)
+			if err != nil {
+				return "", nil, fmt.Errorf("failed to get git hash: %v", err)
+			}
+			if len(hashes) == 0 {
+				return "", nil, fmt.Errorf("failed to get git hash: no commit changes %s", serviceFullPath)
+			}
+			gitHash = hashes[0]
 		} else {
-			output, err = gitExecutor.Output("", "git", "rev-parse", "HEAD")
+			gitHash, err = backend.ResolveRevision(sourceDir, "HEAD")
+			if err != nil {
+				return "", nil, fmt.Errorf("failed to get git hash: %v", err)
+			}
 		}
-		if err != nil {
-			return "", "", fmt.Errorf("failed to get git hash: %v", err)
-		}
-		gitHash = strings.TrimSpace(output)
 		fmt.Printf("The head githash is %s\n", gitHash)
 	}
 
 	if currentGitHash == gitHash {
-		return "", "", fmt.Errorf("git hash %s is already at HEAD", gitHash)
-	} else {
-		var commitLog string
-		var err error
-		// If serviceFullPath is provided, filter logs to only show changes in that path
-		if serviceFullPath != "" {
-			commitLog, err = gitExecutor.Output("", "git", "log", "--no-merges", fmt.Sprintf("%s..%s", currentGitHash, gitHash), "--", serviceFullPath)
-		} else {
-			commitLog, err = gitExecutor.Output("", "git", "log", "--no-merges", fmt.Sprintf("%s..%s", currentGitHash, gitHash))
+		return "", nil, fmt.Errorf("git hash %s is already at HEAD", gitHash)
+	}
+
+	// If serviceFullPath is provided, only changes in that path are included
+	changelog, err := BuildChangelog(backend, sourceDir, currentGitHash, gitHash, serviceFullPath)
+	if err != nil {
+		return "", nil, err
+	}
+	return gitHash, changelog, nil
+}
+
+// CountCommitsBehind clones the service repo and returns the commit that gitHash resolves to (HEAD when empty),
+// and for every current hash the number of commits it is behind that commit, or -1 when they can't be compared
+func CountCommitsBehind(backend GitBackend, gitURL, gitHash string, currentGitHashes []string) (string, map[string]int, error) {
+	tempDir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	sourceDir := filepath.Join(tempDir, "source-dir")
+	err = backend.Clone(gitURL, sourceDir, false)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to clone git repository: %v", err)
+	}
+
+	rev := "HEAD"
+	if gitHash != "" {
+		rev = gitHash
+	}
+	gitHash, err = backend.ResolveRevision(sourceDir, rev)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to resolve git hash %s: %v", rev, err)
+	}
+
+	behind := map[string]int{}
+	for _, current := range currentGitHashes {
+		if _, ok := behind[current]; ok {
+			continue
 		}
+		behind[current] = -1
+		count, err := backend.CountCommits(sourceDir, current, gitHash)
 		if err != nil {
-			return "", "", err
+			fmt.Printf("Unable to compare %s with %s: %v\n", current, gitHash, err)
+			continue
 		}
-		return gitHash, commitLog, nil
+		behind[current] = count
 	}
+
+	return gitHash, behind, nil
 }
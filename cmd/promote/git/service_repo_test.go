@@ -1,182 +1,101 @@
 package git
 
 import (
-	"fmt"
-	"os"
 	"path/filepath"
 	"testing"
 
-	"github.com/openshift/osdctl/cmd/promote/iexec"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
-// MockExec is a mock for the executor used in the git package
-type MockExecService struct {
-	mock.Mock
-	iexec.IExec
+// newServiceRepo returns a repository with a commit outside and inside operators/dynatrace after the base commit
+func newServiceRepo(t *testing.T) (repo *testRepo, base, operator, other string) {
+	repo = newTestRepo(t)
+	base = repo.commit("Initial commit", "README.md")
+	operator = repo.commit("Change the operator", "operators/dynatrace/main.go")
+	other = repo.commit("Change something else", "main.go")
+	return repo, base, operator, other
 }
 
-func (m *MockExecService) Run(dir string, command string, args ...string) error {
-	argsList := m.Called(dir, command, args)
-	return argsList.Error(0)
+func TestCheckoutAndCompareGitHash_GitCloneError(t *testing.T) {
+	for name, backend := range testBackends() {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := CheckoutAndCompareGitHash(
+				backend,
+				filepath.Join(t.TempDir(), "missing"),
+				"abcdef1234567890",
+				"abcdef1234567890",
+				"service/path",
+			)
+
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "failed to clone git repository")
+		})
+	}
 }
 
-func (m *MockExecService) Output(dir string, command string, args ...string) (string, error) {
-	argsList := m.Called(dir, command, args)
-	return argsList.String(0), argsList.Error(1)
-}
-
-func (m *MockExecService) CombinedOutput(dir string, command string, args ...string) (string, error) {
-	argsList := m.Called(dir, command, args)
-	return argsList.String(0), argsList.Error(1)
-}
-
-func TestCheckoutAndCompareGitHash_ChdirAndGitCloneError(t *testing.T) {
-	mockExec := new(MockExecService)
-
-	mockExec.On("Run", mock.Anything, "git", mock.MatchedBy(func(args []string) bool {
-		return len(args) == 3 && args[0] == "clone" && args[1] == "https://github.com/some/repo.git"
-	})).Return(fmt.Errorf("simulated clone failure")).Once()
-
-	_, _, err := CheckoutAndCompareGitHash(
-		mockExec,
-		"https://github.com/some/repo.git",
-		"abcdef1234567890",
-		"abcdef1234567890",
-		"service/path",
-	)
-
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "failed to clone git repository")
+// Should use the last commit of the path, or HEAD without a path, when no hash is provided
+func TestCheckoutAndCompareGitHash_Head(t *testing.T) {
+	for name, backend := range testBackends() {
+		t.Run(name, func(t *testing.T) {
+			repo, base, operator, other := newServiceRepo(t)
 
-	mockExec.AssertExpectations(t)
-}
-
-func TestCheckoutAndCompareGitHash_ChdirSourceDirError(t *testing.T) {
-	mockExec := new(MockExecService)
-
-	tempDir, err := os.MkdirTemp("", "")
-	require.NoError(t, err)
-	defer os.RemoveAll(tempDir)
-
-	err = os.Chdir(tempDir)
-	require.NoError(t, err)
+			gitHash, _, err := CheckoutAndCompareGitHash(backend, repo.dir, "", base, "operators/dynatrace")
+			require.NoError(t, err)
+			assert.Equal(t, operator, gitHash)
 
-	sourceDir := filepath.Join(tempDir, "source-dir")
-	err = os.Mkdir(sourceDir, 0o755)
-	require.NoError(t, err)
+			gitHash, _, err = CheckoutAndCompareGitHash(backend, repo.dir, "", base, "")
+			require.NoError(t, err)
+			assert.Equal(t, other, gitHash)
 
-	mockExec.On("Run", mock.Anything, "git", mock.MatchedBy(func(args []string) bool {
-		return len(args) == 3 && args[0] == "clone" && args[1] == "https://github.com/some/repo.git"
-	})).Return(nil).Once()
-
-	_, _, err = CheckoutAndCompareGitHash(mockExec, "https://github.com/some/repo.git", "", "", "service/path")
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "failed to change directory to source-dir")
+			_, _, err = CheckoutAndCompareGitHash(backend, repo.dir, "", other, "")
+			assert.ErrorContains(t, err, "is already at HEAD")
+		})
+	}
 }
 
-// Should include path filter in git log when serviceFullPath is provided
+// Should only include the commits of the path when serviceFullPath is provided
 func TestCheckoutAndCompareGitHash_WithPathFilter(t *testing.T) {
-	mockExec := new(MockExecService)
-
-	mockExec.On("Run", mock.Anything, "git", mock.MatchedBy(func(args []string) bool {
-		if len(args) == 3 && args[0] == "clone" {
-			cwd, _ := os.Getwd()
-			_ = os.Mkdir(filepath.Join(cwd, "source-dir"), 0o755)
-			return true
-		}
-		return false
-	})).Return(nil).Once()
-
-	expectedCommitLog := "commit abc123\nAuthor: Test\n\n    Test commit"
-	mockExec.On("Output", "", "git", []string{
-		"log",
-		"--no-merges",
-		"old123..new456",
-		"--",
-		"operators/dynatrace",
-	}).Return(expectedCommitLog, nil).Once()
-
-	gitHash, commitLog, err := CheckoutAndCompareGitHash(
-		mockExec,
-		"https://github.com/some/repo.git",
-		"new456",
-		"old123",
-		"operators/dynatrace",
-	)
-
-	assert.NoError(t, err)
-	assert.Equal(t, "new456", gitHash)
-	assert.Equal(t, expectedCommitLog, commitLog)
-	mockExec.AssertExpectations(t)
+	for name, backend := range testBackends() {
+		t.Run(name, func(t *testing.T) {
+			repo, base, operator, other := newServiceRepo(t)
+
+			gitHash, commitLog, err := CheckoutAndCompareGitHash(backend, repo.dir, other, base, "operators/dynatrace")
+
+			require.NoError(t, err)
+			assert.Equal(t, other, gitHash)
+			assert.Contains(t, commitLog, shortSha(operator)+" Change the operator (Test)")
+			assert.NotContains(t, commitLog, "Change something else")
+		})
+	}
 }
 
-// Should NOT include path filter in git log when serviceFullPath is not provided
+// Should include every commit when serviceFullPath is not provided
 func TestCheckoutAndCompareGitHash_WithoutPathFilter(t *testing.T) {
-	mockExec := new(MockExecService)
-
-	mockExec.On("Run", mock.Anything, "git", mock.MatchedBy(func(args []string) bool {
-		if len(args) == 3 && args[0] == "clone" {
-			cwd, _ := os.Getwd()
-			_ = os.Mkdir(filepath.Join(cwd, "source-dir"), 0o755)
-			return true
-		}
-		return false
-	})).Return(nil).Once()
-
-	expectedCommitLog := "commit xyz789\nAuthor: Test\n\n    All commits"
-	mockExec.On("Output", "", "git", []string{
-		"log",
-		"--no-merges",
-		"old789..new999",
-	}).Return(expectedCommitLog, nil).Once()
-
-	gitHash, commitLog, err := CheckoutAndCompareGitHash(
-		mockExec,
-		"https://github.com/some/repo.git",
-		"new999",
-		"old789",
-		"",
-	)
-
-	assert.NoError(t, err)
-	assert.Equal(t, "new999", gitHash)
-	assert.Equal(t, expectedCommitLog, commitLog)
-	mockExec.AssertExpectations(t)
+	for name, backend := range testBackends() {
+		t.Run(name, func(t *testing.T) {
+			repo, base, operator, other := newServiceRepo(t)
+
+			gitHash, commitLog, err := CheckoutAndCompareGitHash(backend, repo.dir, other, base, "")
+
+			require.NoError(t, err)
+			assert.Equal(t, other, gitHash)
+			assert.Contains(t, commitLog, shortSha(operator)+" Change the operator (Test)")
+			assert.Contains(t, commitLog, shortSha(other)+" Change something else (Test)")
+		})
+	}
 }
 
-// Should NOT include path filter in git log when serviceFullPath is empty string
-func TestCheckoutAndCompareGitHash_WithEmptyPathFilter(t *testing.T) {
-	mockExec := new(MockExecService)
-
-	mockExec.On("Run", mock.Anything, "git", mock.MatchedBy(func(args []string) bool {
-		if len(args) == 3 && args[0] == "clone" {
-			cwd, _ := os.Getwd()
-			_ = os.Mkdir(filepath.Join(cwd, "source-dir"), 0o755)
-			return true
-		}
-		return false
-	})).Return(nil).Once()
-
-	expectedCommitLog := "commit def456\nAuthor: Test\n\n    Empty path test"
-	mockExec.On("Output", "", "git", []string{
-		"log",
-		"--no-merges",
-		"oldabc..newabc",
-	}).Return(expectedCommitLog, nil).Once()
-
-	gitHash, commitLog, err := CheckoutAndCompareGitHash(
-		mockExec,
-		"https://github.com/some/repo.git",
-		"newabc",
-		"oldabc",
-		"",
-	)
-
-	assert.NoError(t, err)
-	assert.Equal(t, "newabc", gitHash)
-	assert.Equal(t, expectedCommitLog, commitLog)
-	mockExec.AssertExpectations(t)
+func TestCountCommitsBehind(t *testing.T) {
+	for name, backend := range testBackends() {
+		t.Run(name, func(t *testing.T) {
+			repo, base, operator, other := newServiceRepo(t)
+
+			hash, behind, err := CountCommitsBehind(backend, repo.dir, "", []string{base, operator, "bbbbbbb", base})
+
+			require.NoError(t, err)
+			assert.Equal(t, other, hash)
+			assert.Equal(t, map[string]int{base: 2, operator: 1, "bbbbbbb": -1}, behind)
+		})
+	}
 }
@@ -0,0 +1,346 @@
+package rollback
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/openshift/osdctl/cmd/promote/git"
+	"github.com/openshift/osdctl/cmd/promote/iexec"
+	"github.com/openshift/osdctl/cmd/promote/pathutil"
+	"github.com/openshift/osdctl/cmd/promote/saas"
+	"github.com/spf13/cobra"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// maxHistory is the number of commits of the SaaS file that are searched for a previous version
+const maxHistory = 100
+
+// rollbackOptions defines the options provided by this command
+type rollbackOptions struct {
+	serviceName             string
+	to                      string
+	appInterfaceCheckoutDir string
+	hcp                     bool
+	pkg                     bool
+}
+
+func NewCmdRollback() *cobra.Command {
+	ops := &rollbackOptions{}
+
+	rollbackCmd := &cobra.Command{
+		Use:               "rollback",
+		Short:             "Roll back a SaaS or package-operator service to its previous version",
+		Args:              cobra.NoArgs,
+		DisableAutoGenTag: true,
+		Long: `Prepares an app-interface branch which reverts the last promotion of a service.
+
+  Without --to, the previous git hash (or package tag with --package) is taken from the git history
+  of the SaaS file in app-interface. If the rolled back hash was set in hotfixVersions of the app.yml
+  of the service, it is removed from there as well.`,
+		Example: `
+ # Roll back a SaaS service to the version before the last promotion
+ osdctl promote rollback --serviceName <serviceName>
+
+ # Roll back a SaaS service to a given git hash
+ osdctl promote rollback --serviceName <serviceName> --to <git-hash>
+
+ # Roll back a package-operator service to the previous package tag
+ osdctl promote rollback --serviceName <serviceName> --package`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(ops.validate())
+			appInterface := git.BootstrapOsdCtlForAppInterfaceAndServicePromotions(ops.appInterfaceCheckoutDir, iexec.Exec{})
+			cmdutil.CheckErr(ops.run(appInterface))
+		},
+	}
+
+	rollbackCmd.Flags().StringVarP(&ops.serviceName, "serviceName", "", "", "Service getting rolled back")
+	rollbackCmd.Flags().StringVarP(&ops.to, "to", "", "", "Git hash or package tag to roll back to, defaults to the previous version in app-interface")
+	rollbackCmd.Flags().StringVarP(&ops.appInterfaceCheckoutDir, "appInterfaceDir", "", "", "location of app-interface checkout. Falls back to current working directory")
+	rollbackCmd.Flags().BoolVar(&ops.hcp, "hcp", false, "The service conforms to the HyperShift progressive delivery definition")
+	rollbackCmd.Flags().BoolVar(&ops.pkg, "package", false, "Roll back the package tag of a package-operator service instead of the git hash")
+
+	return rollbackCmd
+}
+
+func (o *rollbackOptions) validate() error {
+	if o.serviceName == "" {
+		return fmt.Errorf("the service name must be specified with --serviceName")
+	}
+	return nil
+}
+
+func (o *rollbackOptions) run(appInterface git.AppInterface) error {
+	services, err := saas.GetServiceNames(appInterface, saas.OSDSaasDir, saas.BPSaasDir, saas.CADSaasDir)
+	if err != nil {
+		return err
+	}
+	serviceName, err := saas.ValidateServiceName(services, o.serviceName)
+	if err != nil {
+		return err
+	}
+	saasFile, err := saas.GetSaasDir(serviceName, !o.hcp, o.hcp)
+	if err != nil {
+		return err
+	}
+
+	return rollbackService(appInterface, serviceName, saasFile, o.to, o.pkg)
+}
+
+// versionReader returns the deployed version in the content of a SaaS file
+type versionReader func(serviceName string, saasData []byte) (string, error)
+
+func currentGitHash(serviceName string, saasData []byte) (string, error) {
+	// GetCurrentGitHashFromAppInterface exits on invalid YAML, old revisions might not parse
+	if _, err := git.GetSaasTargets(saasData); err != nil {
+		return "", err
+	}
+	hash, _, err := git.GetCurrentGitHashFromAppInterface(saasData, serviceName, "")
+	return hash, err
+}
+
+func currentPackageTag(_ string, saasData []byte) (string, error) {
+	return git.GetCurrentPackageTag(saasData)
+}
+
+// findPreviousVersion walks the history of the SaaS file on master and returns the first
+// version that differs from current, together with the app-interface commit that set it
+func findPreviousVersion(appInterface git.AppInterface, serviceName, saasFile, current string, read versionReader) (string, string, error) {
+	relPath, err := filepath.Rel(appInterface.GitDirectory, saasFile)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get path of %s in app-interface: %v", saasFile, err)
+	}
+
+	backend := appInterface.Backend()
+	commits, err := backend.Log(appInterface.GitDirectory, "master", relPath, maxHistory)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read history of %s: %v", relPath, err)
+	}
+
+	for _, commit := range commits {
+		content, err := backend.Show(appInterface.GitDirectory, commit, relPath)
+		if err != nil {
+			// the file was moved or deleted in this commit
+			continue
+		}
+		version, err := read(serviceName, content)
+		if err != nil || version == "" {
+			continue
+		}
+		if version != current {
+			return version, commit, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("no previous version found in the last %d commits of %s", maxHistory, relPath)
+}
+
+func rollbackService(appInterface git.AppInterface, serviceName, saasFile, to string, pkg bool) error {
+	saasData, err := os.ReadFile(saasFile)
+	if err != nil {
+		return fmt.Errorf("failed to read SAAS file: %v", err)
+	}
+
+	read := versionReader(currentGitHash)
+	kind := "git hash"
+	if pkg {
+		read = currentPackageTag
+		kind = "package tag"
+	}
+
+	current, err := read(serviceName, saasData)
+	if err != nil {
+		return fmt.Errorf("failed to get current %s: %v", kind, err)
+	}
+	if current == "" {
+		return fmt.Errorf("no current %s found for service %s", kind, serviceName)
+	}
+	fmt.Printf("Current %s: %s\n", kind, current)
+
+	target := to
+	if target == "" {
+		var commit string
+		target, commit, err = findPreviousVersion(appInterface, serviceName, saasFile, current, read)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Previous %s: %s (set in app-interface commit %s)\n", kind, target, commit)
+	}
+	if target == current {
+		return fmt.Errorf("current %s is already at '%s'. Nothing to do", kind, target)
+	}
+
+	branchName := fmt.Sprintf("rollback-%s-%s", serviceName, target)
+	if err := appInterface.CreatePromotionBranch(branchName); err != nil {
+		return err
+	}
+
+	// The branch starts from master, which might differ from the checkout we started in
+	saasData, err = os.ReadFile(saasFile)
+	if err != nil {
+		return fmt.Errorf("failed to read SAAS file: %v", err)
+	}
+
+	newContent, err := rollBackSaasTargets(saasData, current, target, pkg)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(saasFile, []byte(newContent), 0600); err != nil {
+		return fmt.Errorf("failed to write to file %s: %v", saasFile, err)
+	}
+
+	commitMessage := fmt.Sprintf("Rollback %s from %s to %s\n\n", serviceName, current, target)
+	if !pkg {
+		if targets, err := git.GetSaasTargets(saasData); err == nil && len(targets) > 0 && targets[0].URL != "" {
+			commitMessage += fmt.Sprintf("See %s/compare/%s...%s for the reverted changes.\n", targets[0].URL, target, current)
+		}
+	}
+
+	hotfixRemoved := false
+	if !pkg {
+		hotfixRemoved, err = removeHotfixFromAppYml(appInterface, serviceName, saasFile, current)
+		if err != nil {
+			return err
+		}
+	}
+
+	if hotfixRemoved {
+		commitMessage += fmt.Sprintf("Removes %s from hotfixVersions in app.yml.\n", current)
+		err = appInterface.CommitSaasAndAppYmlFile(saasFile, serviceName, commitMessage)
+	} else {
+		err = appInterface.CommitSaasFile(saasFile, commitMessage)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to commit changes to app-interface; manual commit may still succeed: %w", err)
+	}
+
+	fmt.Printf("The branch %s is ready to be pushed\n", branchName)
+	fmt.Println("")
+	fmt.Println("service:", serviceName)
+	fmt.Println("from:", current)
+	fmt.Println("to:", target)
+	return nil
+}
+
+// rollBackSaasTargets sets every target still at the current version back to target, through the ref of the
+// targets deploying code or the PACKAGE_TAG of the package targets. Targets which were not promoted yet
+// (e.g. during progressive delivery) are left untouched.
+func rollBackSaasTargets(saasData []byte, current, target string, pkg bool) (string, error) {
+	getTargets, setTargets := git.GetSaasTargets, git.SetSaasTargetRefs
+	version := func(t git.SaasTarget) string { return t.Ref }
+	if pkg {
+		getTargets, setTargets = git.GetSaasPackageTargets, git.SetSaasTargetPackageTags
+		version = func(t git.SaasTarget) string { return t.PackageTag }
+	}
+
+	targets, err := getTargets(saasData)
+	if err != nil {
+		return "", err
+	}
+	var atCurrent []git.SaasTarget
+	for _, t := range targets {
+		if version(t) == current {
+			atCurrent = append(atCurrent, t)
+		}
+	}
+	if len(atCurrent) == 0 {
+		return "", fmt.Errorf("no target is at '%s'", current)
+	}
+	return setTargets(string(saasData), atCurrent, target)
+}
+
+// removeHotfixFromAppYml removes gitHash from hotfixVersions of the service in app.yml.
+// It returns false if app.yml doesn't exist or gitHash isn't a hotfix version.
+func removeHotfixFromAppYml(appInterface git.AppInterface, serviceName, saasFile, gitHash string) (bool, error) {
+	componentName := strings.TrimPrefix(serviceName, "saas-")
+
+	appYmlPath, err := pathutil.DeriveAppYmlPath(appInterface.GitDirectory, saasFile, componentName)
+	if err != nil {
+		return false, nil
+	}
+	fileContent, err := os.ReadFile(appYmlPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read app.yml file: %v", err)
+	}
+
+	newContent, removed, err := removeHotfixVersion(string(fileContent), componentName, gitHash)
+	if err != nil {
+		return false, fmt.Errorf("error modifying app.yml: %v", err)
+	}
+	if !removed {
+		return false, nil
+	}
+
+	if err := os.WriteFile(appYmlPath, []byte(newContent), 0600); err != nil {
+		return false, fmt.Errorf("failed to write updated app.yml: %v", err)
+	}
+	fmt.Printf("Removed %s from hotfixVersions of %s\n", gitHash, componentName)
+	return true, nil
+}
+
+// removeHotfixVersion removes gitHash from hotfixVersions of the code component, and the
+// hotfixVersions field itself when it becomes empty. It returns whether anything was removed.
+func removeHotfixVersion(fileContent, componentName, gitHash string) (string, bool, error) {
+	node, err := kyaml.Parse(fileContent)
+	if err != nil {
+		return "", false, fmt.Errorf("error parsing app.yml: %v", err)
+	}
+
+	codeComponents, err := kyaml.Lookup("codeComponents").Filter(node)
+	if err != nil {
+		return "", false, fmt.Errorf("error querying codeComponents: %v", err)
+	}
+	if codeComponents == nil {
+		return fileContent, false, nil
+	}
+
+	removed := false
+	for i := range len(codeComponents.Content()) {
+		component, err := kyaml.Lookup("codeComponents", strconv.Itoa(i)).Filter(node)
+		if err != nil {
+			return "", false, fmt.Errorf("error querying component %d: %v", i, err)
+		}
+		if name, _ := component.GetString("name"); name != componentName {
+			continue
+		}
+
+		hotfixVersions := component.Field("hotfixVersions")
+		if hotfixVersions == nil {
+			break
+		}
+
+		var kept []string
+		for _, element := range hotfixVersions.Value.YNode().Content {
+			v := element.Value
+			if v == gitHash {
+				removed = true
+				continue
+			}
+			kept = append(kept, v)
+		}
+		if !removed {
+			break
+		}
+
+		if len(kept) == 0 {
+			_, err = component.Pipe(kyaml.Clear("hotfixVersions"))
+		} else {
+			_, err = component.Pipe(kyaml.SetField("hotfixVersions", kyaml.NewListRNode(kept...)))
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("error updating hotfixVersions: %v", err)
+		}
+		break
+	}
+
+	if !removed {
+		return fileContent, false, nil
+	}
+	return node.MustString(), true, nil
+}
@@ -0,0 +1,260 @@
+package rollback
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openshift/osdctl/cmd/promote/git"
+	"github.com/openshift/osdctl/cmd/promote/iexec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const saasTemplate = `name: saas-foo-operator
+resourceTemplates:
+- name: foo-operator
+  url: https://github.com/openshift/foo-operator
+  targets:
+  - name: foo-operator-stage
+    namespace:
+      $ref: /services/osd-operators/namespaces/hivestage/foo.yml
+    ref: %[1]s
+  - name: foo-operator-prod-canary
+    namespace:
+      $ref: /services/osd-operators/namespaces/hivep01/foo.yml
+    ref: %[1]s
+- name: foo-operator-package
+  url: https://github.com/openshift/foo-operator
+  targets:
+  - name: package-prod
+    namespace:
+      $ref: /services/osd-operators/namespaces/hivep01/foo.yml
+    ref: master
+    parameters:
+      PACKAGE_TAG: %[2]s
+`
+
+const appYml = `name: foo-operator
+codeComponents:
+- name: foo-operator
+  url: https://github.com/openshift/foo-operator
+  hotfixVersions:
+  - %s
+`
+
+type testAppInterface struct {
+	t        *testing.T
+	dir      string
+	saasFile string
+	appYml   string
+}
+
+func newTestAppInterface(t *testing.T) *testAppInterface {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	a := &testAppInterface{t: t, dir: t.TempDir()}
+	a.saasFile = filepath.Join(a.dir, "data/services/osd-operators/cicd/saas/saas-foo-operator/deploy.yaml")
+	a.appYml = filepath.Join(a.dir, "data/services/osd-operators/foo-operator/app.yml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(a.saasFile), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Dir(a.appYml), 0o755))
+	a.git("init", "-q", "-b", "master")
+	return a
+}
+
+func (a *testAppInterface) git(args ...string) string {
+	a.t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = a.dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(a.t, err, "git %v: %s", args, out)
+	return strings.TrimSpace(string(out))
+}
+
+// promote commits the SaaS file with the given hash and package tag
+func (a *testAppInterface) promote(hash, tag string) {
+	a.t.Helper()
+	require.NoError(a.t, os.WriteFile(a.saasFile, []byte(fmt.Sprintf(saasTemplate, hash, tag)), 0o600))
+	a.git("add", ".")
+	a.git("commit", "-q", "-m", "promote "+hash)
+}
+
+func (a *testAppInterface) appInterface() git.AppInterface {
+	return git.AppInterface{GitDirectory: a.dir, GitExecutor: iexec.Exec{}}
+}
+
+func TestRollbackService_PreviousHash(t *testing.T) {
+	a := newTestAppInterface(t)
+	a.promote("aaaaaaa", "v1")
+	a.promote("bbbbbbb", "v1")
+	a.promote("ccccccc", "v2")
+	require.NoError(t, os.WriteFile(a.appYml, []byte(fmt.Sprintf(appYml, "ccccccc")), 0o600))
+	a.git("add", ".")
+	a.git("commit", "-q", "-m", "hotfix ccccccc")
+
+	err := rollbackService(a.appInterface(), "saas-foo-operator", a.saasFile, "", false)
+	require.NoError(t, err)
+
+	assert.Equal(t, "rollback-saas-foo-operator-bbbbbbb", a.git("rev-parse", "--abbrev-ref", "HEAD"))
+	content, err := os.ReadFile(a.saasFile)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "ccccccc")
+	assert.Equal(t, 2, strings.Count(string(content), "ref: bbbbbbb"))
+
+	app, err := os.ReadFile(a.appYml)
+	require.NoError(t, err)
+	assert.NotContains(t, string(app), "hotfixVersions")
+
+	assert.Contains(t, a.git("log", "-1", "--format=%B"), "Rollback saas-foo-operator from ccccccc to bbbbbbb")
+	assert.Empty(t, a.git("status", "--porcelain"))
+}
+
+func TestRollbackService_PackageTag(t *testing.T) {
+	a := newTestAppInterface(t)
+	a.promote("aaaaaaa", "v1")
+	a.promote("bbbbbbb", "v1")
+	a.promote("bbbbbbb", "v2")
+
+	err := rollbackService(a.appInterface(), "saas-foo-operator", a.saasFile, "", true)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(a.saasFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "PACKAGE_TAG: v1")
+	assert.Contains(t, string(content), "ref: bbbbbbb")
+}
+
+func TestRollbackService_To(t *testing.T) {
+	a := newTestAppInterface(t)
+	a.promote("aaaaaaa", "v1")
+	a.promote("bbbbbbb", "v1")
+
+	err := rollbackService(a.appInterface(), "saas-foo-operator", a.saasFile, "0123456", false)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(a.saasFile)
+	require.NoError(t, err)
+	// The ref is quoted, as it would otherwise be read as a number
+	assert.Contains(t, string(content), `ref: "0123456"`)
+
+	err = rollbackService(a.appInterface(), "saas-foo-operator", a.saasFile, "0123456", false)
+	assert.ErrorContains(t, err, "Nothing to do")
+}
+
+func TestRollbackService_NoHistory(t *testing.T) {
+	a := newTestAppInterface(t)
+	a.promote("aaaaaaa", "v1")
+
+	err := rollbackService(a.appInterface(), "saas-foo-operator", a.saasFile, "", false)
+	assert.ErrorContains(t, err, "no previous version found")
+}
+
+func TestRemoveHotfixVersion(t *testing.T) {
+	tests := map[string]struct {
+		content     string
+		removed     bool
+		contains    []string
+		notContains []string
+	}{
+		"removes_field_when_empty": {
+			content:     fmt.Sprintf(appYml, "ccccccc"),
+			removed:     true,
+			notContains: []string{"hotfixVersions", "ccccccc"},
+		},
+		"keeps_other_versions": {
+			content:     "codeComponents:\n- name: foo-operator\n  hotfixVersions:\n  - aaaaaaa\n  - ccccccc\n",
+			removed:     true,
+			contains:    []string{"hotfixVersions", "aaaaaaa"},
+			notContains: []string{"ccccccc"},
+		},
+		"other_component": {
+			content:  "codeComponents:\n- name: bar-operator\n  hotfixVersions:\n  - ccccccc\n",
+			removed:  false,
+			contains: []string{"ccccccc"},
+		},
+		"no_code_components": {
+			content: "name: foo-operator\n",
+			removed: false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, removed, err := removeHotfixVersion(tc.content, "foo-operator", "ccccccc")
+			require.NoError(t, err)
+			assert.Equal(t, tc.removed, removed)
+			for _, s := range tc.contains {
+				assert.Contains(t, result, s)
+			}
+			for _, s := range tc.notContains {
+				assert.NotContains(t, result, s)
+			}
+		})
+	}
+}
+
+func TestRollBackSaasTargets(t *testing.T) {
+	saas := `name: saas-foo-operator
+resourceTemplates:
+- name: foo-operator
+  url: https://github.com/openshift/foo-operator
+  parameters:
+    IMAGE_TAG: bbbbbbb
+  targets:
+  - name: foo-operator-stage
+    namespace:
+      $ref: /services/osd-operators/namespaces/hivestage/foo.yml
+    ref: bbbbbbb
+  - name: foo-operator-prod
+    namespace:
+      $ref: /services/osd-operators/namespaces/hivep01/foo.yml
+    ref: 9999999
+- name: foo-operator-package
+  url: https://github.com/openshift/foo-operator
+  targets:
+  - name: package-prod
+    namespace:
+      $ref: /services/osd-operators/namespaces/hivep01/foo.yml
+    ref: bbbbbbb
+    parameters:
+      PACKAGE_TAG: bbbbbbb
+`
+
+	// Only the refs of the targets at the current hash are set back
+	content, err := rollBackSaasTargets([]byte(saas), "bbbbbbb", "aaaaaaa", false)
+	require.NoError(t, err)
+	targets, err := git.GetSaasTargets([]byte(content))
+	require.NoError(t, err)
+	assert.Equal(t, "aaaaaaa", targets[0].Ref)
+	assert.Equal(t, "9999999", targets[1].Ref, "targets not promoted yet are left untouched")
+	assert.Contains(t, content, "IMAGE_TAG: bbbbbbb")
+	packageTargets, err := git.GetSaasPackageTargets([]byte(content))
+	require.NoError(t, err)
+	assert.Equal(t, "bbbbbbb", packageTargets[0].Ref)
+	assert.Equal(t, "bbbbbbb", packageTargets[0].PackageTag)
+
+	// Only the PACKAGE_TAG of the package targets is set back
+	content, err = rollBackSaasTargets([]byte(saas), "bbbbbbb", "v1", true)
+	require.NoError(t, err)
+	packageTargets, err = git.GetSaasPackageTargets([]byte(content))
+	require.NoError(t, err)
+	assert.Equal(t, "v1", packageTargets[0].PackageTag)
+	assert.Equal(t, "bbbbbbb", packageTargets[0].Ref)
+	targets, err = git.GetSaasTargets([]byte(content))
+	require.NoError(t, err)
+	assert.Equal(t, "bbbbbbb", targets[0].Ref)
+
+	_, err = rollBackSaasTargets([]byte(saas), "ccccccc", "aaaaaaa", false)
+	assert.EqualError(t, err, "no target is at 'ccccccc'")
+}
@@ -0,0 +1,257 @@
+package saas
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/openshift/osdctl/cmd/promote/git"
+	"github.com/openshift/osdctl/pkg/printer"
+)
+
+// Promotion waves, in the order they are rolled out
+const (
+	waveStage      = "stage"
+	waveProdCanary = "prod-canary"
+	waveProd       = "prod"
+)
+
+var planWaves = []string{waveStage, waveProdCanary, waveProd}
+
+// targets pinned to a commit, anything else (e.g. master) follows a branch and is not promoted
+var shaPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+type planTarget struct {
+	git.SaasTarget
+	wave string
+	// behind is the number of commits the current ref is behind the promoted hash, -1 if unknown
+	behind int
+}
+
+type promotionWave struct {
+	name    string
+	targets []planTarget
+}
+
+type promotionPlan struct {
+	serviceName string
+	serviceRepo string
+	gitHash     string
+	targets     []planTarget
+	// waves only contains the targets that need to be updated
+	waves []promotionWave
+}
+
+// targetWave returns the wave a target belongs to, or "" when it can't be determined
+func targetWave(target git.SaasTarget) string {
+	name := strings.ToLower(target.Name)
+	namespace := strings.ToLower(target.Namespace)
+
+	switch {
+	case strings.Contains(name, "canary"):
+		return waveProdCanary
+	case strings.Contains(name, "stage") || strings.Contains(namespace, "stage") ||
+		strings.Contains(name, "integration") || strings.Contains(namespace, "integration"):
+		return waveStage
+	case strings.Contains(name, "prod") || strings.Contains(namespace, "prod") || strings.Contains(namespace, "hivep"):
+		return waveProd
+	}
+	return ""
+}
+
+func buildPromotionPlan(serviceName string, serviceRepo string, gitHash string, targets []git.SaasTarget, behind map[string]int) promotionPlan {
+	plan := promotionPlan{
+		serviceName: serviceName,
+		serviceRepo: serviceRepo,
+		gitHash:     gitHash,
+	}
+
+	byWave := map[string][]planTarget{}
+	for _, target := range targets {
+		t := planTarget{SaasTarget: target, wave: targetWave(target), behind: -1}
+		if count, ok := behind[target.Ref]; ok {
+			t.behind = count
+		}
+		if target.Ref == gitHash {
+			t.behind = 0
+		}
+		plan.targets = append(plan.targets, t)
+
+		if t.wave != "" && t.Ref != gitHash && shaPattern.MatchString(t.Ref) {
+			byWave[t.wave] = append(byWave[t.wave], t)
+		}
+	}
+
+	for _, wave := range planWaves {
+		if len(byWave[wave]) > 0 {
+			plan.waves = append(plan.waves, promotionWave{name: wave, targets: byWave[wave]})
+		}
+	}
+
+	return plan
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 12 && shaPattern.MatchString(hash) {
+		return hash[:12]
+	}
+	return hash
+}
+
+func (p promotionPlan) print(out io.Writer) error {
+	fmt.Fprintf(out, "Promotion plan for %s to %s\n\n", p.serviceName, p.gitHash)
+
+	table := printer.NewTablePrinter(out, 20, 1, 3, ' ')
+	table.AddRow([]string{"WAVE", "TARGET", "NAMESPACE", "CURRENT", "BEHIND"})
+	for _, t := range p.targets {
+		wave := t.wave
+		if wave == "" {
+			wave = "-"
+		}
+		behind := "unknown"
+		switch {
+		case !shaPattern.MatchString(t.Ref):
+			behind = "tracks branch"
+		case t.behind >= 0:
+			behind = strconv.Itoa(t.behind)
+		}
+		table.AddRow([]string{wave, t.Name, strings.TrimSuffix(filepath.Base(t.Namespace), ".yml"), shortHash(t.Ref), behind})
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out)
+	if len(p.waves) == 0 {
+		fmt.Fprintf(out, "All targets are already at %s\n", p.gitHash)
+		return nil
+	}
+	for i, wave := range p.waves {
+		fmt.Fprintf(out, "Wave %d/%d (%s): %d target(s)\n", i+1, len(p.waves), wave.name, len(wave.targets))
+	}
+	return nil
+}
+
+// commitMessage describes the commit promoting the targets of the wave with index i
+func (p promotionPlan) commitMessage(i int) string {
+	wave := p.waves[i]
+
+	msg := fmt.Sprintf("Promote %s to %s (%s, wave %d/%d)\n\n", p.serviceName, p.gitHash, wave.name, i+1, len(p.waves))
+	msg += "## Targets\n\n"
+
+	var from []string
+	for _, t := range wave.targets {
+		behind := "unknown number of commits"
+		if t.behind >= 0 {
+			behind = fmt.Sprintf("%d commits", t.behind)
+		}
+		msg += fmt.Sprintf("- %s (%s): from %s, %s\n", t.Name, t.Namespace, t.Ref, behind)
+
+		if !contains(from, t.Ref) {
+			from = append(from, t.Ref)
+		}
+	}
+
+	msg += "\n## Changes\n\n"
+	for _, ref := range from {
+		msg += fmt.Sprintf("- [Compare %s...%s on GitHub](%s/compare/%s...%s)\n", shortHash(ref), shortHash(p.gitHash), p.serviceRepo, ref, p.gitHash)
+	}
+
+	return msg
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// planServicePromotion shows the current ref of every target of the service and prepares
+// a branch with one commit per wave promoting the targets to gitHash
+func planServicePromotion(appInterface git.AppInterface, serviceName, gitHash string, osd, hcp bool) error {
+	_, err := GetServiceNames(appInterface, OSDSaasDir, BPSaasDir, CADSaasDir)
+	if err != nil {
+		return err
+	}
+
+	serviceName, err = ValidateServiceName(ServicesSlice, serviceName)
+	if err != nil {
+		return err
+	}
+
+	saasDir, err := GetSaasDir(serviceName, osd, hcp)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("SAAS Directory: %v\n", saasDir)
+
+	serviceData, err := os.ReadFile(saasDir)
+	if err != nil {
+		return fmt.Errorf("failed to read SAAS file: %v", err)
+	}
+
+	targets, err := git.GetSaasTargets(serviceData)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 || targets[0].URL == "" {
+		return fmt.Errorf("no targets with a service repo found for service %s", serviceName)
+	}
+	serviceRepo := targets[0].URL
+
+	var currentRefs []string
+	for _, t := range targets {
+		if shaPattern.MatchString(t.Ref) {
+			currentRefs = append(currentRefs, t.Ref)
+		}
+	}
+
+	promotionGitHash, behind, err := git.CountCommitsBehind(git.DefaultGitBackend(appInterface.GitExecutor), serviceRepo, gitHash, currentRefs)
+	if err != nil {
+		return fmt.Errorf("failed to compare git hashes: %v", err)
+	}
+
+	plan := buildPromotionPlan(serviceName, serviceRepo, promotionGitHash, targets, behind)
+	if err := plan.print(os.Stdout); err != nil {
+		return err
+	}
+	if len(plan.waves) == 0 {
+		return nil
+	}
+
+	branchName := fmt.Sprintf("promote-%s-%s", serviceName, promotionGitHash)
+	if err := appInterface.CreatePromotionBranch(branchName); err != nil {
+		return err
+	}
+
+	content := string(serviceData)
+	for i, wave := range plan.waves {
+		waveTargets := make([]git.SaasTarget, 0, len(wave.targets))
+		for _, t := range wave.targets {
+			waveTargets = append(waveTargets, t.SaasTarget)
+		}
+
+		content, err = git.SetSaasTargetRefs(content, waveTargets, promotionGitHash)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(saasDir, []byte(content), 0600); err != nil {
+			return fmt.Errorf("failed to write to file %s: %v", saasDir, err)
+		}
+
+		if err := appInterface.CommitSaasFile(saasDir, plan.commitMessage(i)); err != nil {
+			return fmt.Errorf("failed to commit wave %s: %w", wave.name, err)
+		}
+		fmt.Printf("Committed wave %d/%d (%s)\n", i+1, len(plan.waves), wave.name)
+	}
+
+	fmt.Printf("The branch %s is ready to be pushed, it contains one commit per wave\n", branchName)
+	return nil
+}
@@ -0,0 +1,79 @@
+package saas
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/openshift/osdctl/cmd/promote/git"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargetWave(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   git.SaasTarget
+		expected string
+	}{
+		{"canary", git.SaasTarget{Name: "operator-prod-canary", Namespace: "/hivep01/ns.yml"}, waveProdCanary},
+		{"stage_name", git.SaasTarget{Name: "operator-stage"}, waveStage},
+		{"stage_namespace", git.SaasTarget{Name: "hives02ue1", Namespace: "/services/hivestage/ns.yml"}, waveStage},
+		{"integration", git.SaasTarget{Namespace: "/services/app-sre-observability-integration.yml"}, waveStage},
+		{"prod_hive", git.SaasTarget{Name: "hivep05ue1", Namespace: "/services/hivep05ue1/ns.yml"}, waveProd},
+		{"prod_name", git.SaasTarget{Name: "operator-production"}, waveProd},
+		{"unknown", git.SaasTarget{Name: "operator-dev"}, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, targetWave(tc.target))
+		})
+	}
+}
+
+func TestBuildPromotionPlan(t *testing.T) {
+	targets := []git.SaasTarget{
+		{Name: "operator-prod", Namespace: "/hivep01/ns.yml", Ref: "1111111"},
+		{Name: "operator-integration", Namespace: "/integration/ns.yml", Ref: "master"},
+		{Name: "operator-stage", Namespace: "/hivestage/ns.yml", Ref: "2222222"},
+		{Name: "operator-prod-canary", Namespace: "/hivep02/ns.yml", Ref: "1111111"},
+		{Name: "operator-prod-2", Namespace: "/hivep03/ns.yml", Ref: "3333333"},
+		{Name: "operator-dev", Namespace: "/dev/ns.yml", Ref: "1111111"},
+	}
+
+	plan := buildPromotionPlan("saas-operator", "https://github.com/org/operator", "3333333", targets, map[string]int{"1111111": 5, "2222222": 2})
+
+	require.Len(t, plan.waves, 3)
+	assert.Equal(t, waveStage, plan.waves[0].name)
+	assert.Equal(t, "operator-stage", plan.waves[0].targets[0].Name)
+	assert.Equal(t, waveProdCanary, plan.waves[1].name)
+	assert.Equal(t, waveProd, plan.waves[2].name)
+	// the target already at the hash is not promoted again
+	require.Len(t, plan.waves[2].targets, 1)
+	assert.Equal(t, "operator-prod", plan.waves[2].targets[0].Name)
+	assert.Equal(t, 5, plan.waves[2].targets[0].behind)
+
+	msg := plan.commitMessage(1)
+	assert.Contains(t, msg, "Promote saas-operator to 3333333 (prod-canary, wave 2/3)")
+	assert.Contains(t, msg, "- operator-prod-canary (/hivep02/ns.yml): from 1111111, 5 commits")
+	assert.Contains(t, msg, "https://github.com/org/operator/compare/1111111...3333333")
+
+	var out bytes.Buffer
+	require.NoError(t, plan.print(&out))
+	assert.Contains(t, out.String(), "tracks branch")
+	assert.Contains(t, out.String(), "Wave 3/3 (prod): 1 target(s)")
+}
+
+func TestBuildPromotionPlan_UpToDate(t *testing.T) {
+	targets := []git.SaasTarget{
+		{Name: "operator-stage", Ref: "3333333"},
+		{Name: "operator-prod", Ref: "3333333"},
+	}
+
+	plan := buildPromotionPlan("saas-operator", "https://github.com/org/operator", "3333333", targets, map[string]int{})
+
+	assert.Empty(t, plan.waves)
+	var out bytes.Buffer
+	require.NoError(t, plan.print(&out))
+	assert.Contains(t, out.String(), "All targets are already at 3333333")
+}
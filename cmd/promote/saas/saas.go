@@ -17,6 +17,8 @@ type saasOptions struct {
 	gitHash                 string
 	namespaceRef            string
 	hotfix                  bool
+	plan                    bool
+	changelogJSON           string
 }
 
 // newCmdSaas implementes the saas command to interact with promoting SaaS services/operators
@@ -34,7 +36,10 @@ func NewCmdSaas() *cobra.Command {
 		# Promote a SaaS service/operator
 		osdctl promote saas --serviceName <service-name> --gitHash <git-hash> --osd
 		or
-		osdctl promote saas --serviceName <service-name> --gitHash <git-hash> --hcp`,
+		osdctl promote saas --serviceName <service-name> --gitHash <git-hash> --hcp
+
+		# Show the current git hash of every target and prepare one commit per wave (stage, prod canary, prod)
+		osdctl promote saas --serviceName <service-name> --gitHash <git-hash> --osd --plan`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ops.validateSaasFlow()
 			appInterface := git.BootstrapOsdCtlForAppInterfaceAndServicePromotions(ops.appInterfaceCheckoutDir, iexec.Exec{})
@@ -59,7 +64,22 @@ func NewCmdSaas() *cobra.Command {
 				return cmd.Help()
 			}
 
-			err := servicePromotion(appInterface, ops.serviceName, ops.gitHash, ops.namespaceRef, ops.osd, ops.hcp, ops.hotfix)
+			if ops.plan {
+				if ops.serviceName == "" {
+					fmt.Printf("Error: --plan requires --serviceName\n\n")
+
+					return cmd.Help()
+				}
+
+				err := planServicePromotion(appInterface, ops.serviceName, ops.gitHash, ops.osd, ops.hcp)
+				if err != nil {
+					fmt.Printf("Error while planning promotion: %v\n", err)
+				}
+
+				return nil
+			}
+
+			err := servicePromotion(appInterface, ops.serviceName, ops.gitHash, ops.namespaceRef, ops.osd, ops.hcp, ops.hotfix, ops.changelogJSON)
 			if err != nil {
 				fmt.Printf("Error while promoting service: %v\n", err)
 			}
@@ -77,6 +97,12 @@ func NewCmdSaas() *cobra.Command {
 	saasCmd.Flags().StringVarP(&ops.appInterfaceCheckoutDir, "appInterfaceDir", "", "", "location of app-interface checkout. Falls back to current working directory")
 	saasCmd.Flags().BoolVarP(&ops.hotfix, "hotfix", "", false, "Add gitHash to hotfixVersions in app.yml to bypass progressive delivery (requires --gitHash)")
 
+	saasCmd.Flags().BoolVarP(&ops.plan, "plan", "", false, "Show the git hash of every target and create a branch with one commit per promotion wave")
+	saasCmd.Flags().StringVarP(&ops.changelogJSON, "changelogJSON", "", "", "Write the changelog of the promotion as JSON to this file")
+	saasCmd.MarkFlagsMutuallyExclusive("plan", "hotfix")
+	saasCmd.MarkFlagsMutuallyExclusive("plan", "changelogJSON")
+	saasCmd.MarkFlagsMutuallyExclusive("plan", "namespaceRef")
+
 	return saasCmd
 }
 
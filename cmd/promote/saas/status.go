@@ -0,0 +1,326 @@
+package saas
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openshift/osdctl/cmd/promote/git"
+	"github.com/openshift/osdctl/cmd/promote/iexec"
+	"github.com/openshift/osdctl/pkg/printer"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+// saasFileNames are the SaaS files of services which have a directory instead of a single file
+var saasFileNames = []string{"deploy.yaml", "hypershift-deploy.yaml"}
+
+const defaultStatusConcurrency = 4
+
+type statusOptions struct {
+	services                []string
+	output                  string
+	offline                 bool
+	concurrency             int
+	appInterfaceCheckoutDir string
+}
+
+// targetStatus is the deployed version of a single SaaS target
+type targetStatus struct {
+	Service     string     `json:"service"`
+	SaasFile    string     `json:"saasFile"`
+	Environment string     `json:"environment"`
+	Target      string     `json:"target"`
+	Namespace   string     `json:"namespace"`
+	Ref         string     `json:"ref"`
+	CommitDate  *time.Time `json:"commitDate,omitempty"`
+	Behind      *int       `json:"behind,omitempty"`
+
+	repo string
+}
+
+// NewCmdStatus implements the status command showing which version of every service is deployed where
+func NewCmdStatus() *cobra.Command {
+	ops := &statusOptions{}
+	statusCmd := &cobra.Command{
+		Use:               "status",
+		Short:             "Show the deployed git hash of SaaS services/operators per environment",
+		Args:              cobra.NoArgs,
+		DisableAutoGenTag: true,
+		Long: `Reads every SaaS file in the app-interface checkout and shows the git hash deployed to stage,
+  prod canary and prod, how old the commit is and how many commits it is behind the default branch
+  of the service repo. When the targets of an environment are at different hashes, the one furthest
+  behind is shown and marked with '*'.`,
+		Example: `
+		# Show the status of all services
+		osdctl promote status
+
+		# Show the status of some services as JSON
+		osdctl promote status --service saas-foo-operator --service saas-bar-operator -o json
+
+		# Only show the deployed hashes, without cloning the service repos
+		osdctl promote status --offline`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(ops.validate())
+			appInterface := git.BootstrapOsdCtlForAppInterfaceAndServicePromotions(ops.appInterfaceCheckoutDir, iexec.Exec{})
+			cmdutil.CheckErr(ops.run(appInterface, os.Stdout))
+		},
+	}
+
+	statusCmd.Flags().StringSliceVarP(&ops.services, "service", "", nil, "Only show these services (can be repeated)")
+	statusCmd.Flags().StringVarP(&ops.output, "output", "o", "table", "Output format: table or json")
+	statusCmd.Flags().BoolVarP(&ops.offline, "offline", "", false, "Don't clone the service repos, only show the deployed hashes")
+	statusCmd.Flags().IntVarP(&ops.concurrency, "concurrency", "", defaultStatusConcurrency, "Number of service repos cloned in parallel")
+	statusCmd.Flags().StringVarP(&ops.appInterfaceCheckoutDir, "appInterfaceDir", "", "", "location of app-interface checkout. Falls back to current working directory")
+
+	return statusCmd
+}
+
+func (o *statusOptions) validate() error {
+	if o.output != "table" && o.output != "json" {
+		return fmt.Errorf("invalid output format %q, expecting 'table' or 'json'", o.output)
+	}
+	return nil
+}
+
+func (o *statusOptions) run(appInterface git.AppInterface, out io.Writer) error {
+	statuses, err := collectTargetStatuses(appInterface, o.services)
+	if err != nil {
+		return err
+	}
+	if len(statuses) == 0 {
+		return fmt.Errorf("no SaaS targets found")
+	}
+
+	if !o.offline {
+		if err := addRepoStatus(git.DefaultGitBackend(appInterface.GitExecutor), statuses, o.concurrency); err != nil {
+			return err
+		}
+	}
+
+	if o.output == "json" {
+		data, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	}
+	return writeStatusTable(out, statuses, time.Now())
+}
+
+// serviceSaasFiles returns the SaaS files of a service found by GetServiceNames
+func serviceSaasFiles(path string) []string {
+	if strings.HasSuffix(path, ".yaml") {
+		return []string{path}
+	}
+
+	var files []string
+	for _, name := range saasFileNames {
+		file := filepath.Join(path, name)
+		if _, err := os.Stat(file); err == nil {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// collectTargetStatuses reads the targets of the services, or of every service when services is empty
+func collectTargetStatuses(appInterface git.AppInterface, services []string) ([]*targetStatus, error) {
+	_, err := GetServiceNames(appInterface, OSDSaasDir, BPSaasDir, CADSaasDir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := services
+	if len(names) == 0 {
+		names = append([]string{}, ServicesSlice...)
+	} else {
+		for i, name := range names {
+			names[i], err = ValidateServiceName(ServicesSlice, name)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Strings(names)
+
+	var statuses []*targetStatus
+	for _, name := range names {
+		for _, file := range serviceSaasFiles(ServicesFilesMap[name]) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read SAAS file: %v", err)
+			}
+			targets, err := git.GetSaasTargets(data)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Skipping %s: %v\n", file, err)
+				continue
+			}
+
+			relPath, _ := filepath.Rel(appInterface.GitDirectory, file)
+			for _, target := range targets {
+				wave := targetWave(target)
+				if wave == "" {
+					continue
+				}
+				statuses = append(statuses, &targetStatus{
+					Service:     name,
+					SaasFile:    relPath,
+					Environment: wave,
+					Target:      target.Name,
+					Namespace:   target.Namespace,
+					Ref:         target.Ref,
+					repo:        target.URL,
+				})
+			}
+		}
+	}
+
+	return statuses, nil
+}
+
+// addRepoStatus clones every service repo once and sets the commit date and number of commits behind
+// the default branch for every target pinned to a commit
+func addRepoStatus(backend git.GitBackend, statuses []*targetStatus, concurrency int) error {
+	tempDir, err := os.MkdirTemp("", "osdctl-promote-status-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	byRepo := map[string][]*targetStatus{}
+	for _, s := range statuses {
+		if s.repo != "" && shaPattern.MatchString(s.Ref) {
+			byRepo[s.repo] = append(byRepo[s.repo], s)
+		}
+	}
+
+	if concurrency <= 0 {
+		concurrency = defaultStatusConcurrency
+	}
+	eg := errgroup.Group{}
+	eg.SetLimit(concurrency)
+	i := 0
+	for repo, repoStatuses := range byRepo {
+		dir := filepath.Join(tempDir, strconv.Itoa(i))
+		i++
+		eg.Go(func() error {
+			// A bare clone is enough to walk the history
+			if err := backend.Clone(repo, dir, true); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to clone %s: %v\n", repo, err)
+				return nil
+			}
+
+			for _, s := range repoStatuses {
+				behind, date, err := commitStatus(backend, dir, s.Ref)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to find %s in %s: %v\n", s.Ref, repo, err)
+					continue
+				}
+				s.Behind = &behind
+				s.CommitDate = &date
+			}
+			return nil
+		})
+	}
+
+	return eg.Wait()
+}
+
+// commitStatus returns how many commits hash is behind HEAD of the repo in dir, and when it was committed
+func commitStatus(backend git.GitBackend, dir, hash string) (int, time.Time, error) {
+	behind, err := backend.CountCommits(dir, hash, "HEAD")
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	commit, err := backend.LookupCommit(dir, hash)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return behind, commit.CommitDate, nil
+}
+
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
+
+// statusCell summarizes the targets of a service in one environment
+func statusCell(statuses []*targetStatus, now time.Time) string {
+	if len(statuses) == 0 {
+		return "-"
+	}
+
+	// Show the target furthest behind, unknown counts as furthest
+	oldest := statuses[0]
+	mixed := false
+	for _, s := range statuses[1:] {
+		if s.Ref != oldest.Ref {
+			mixed = true
+		}
+		if s.Behind == nil || (oldest.Behind != nil && *s.Behind > *oldest.Behind) {
+			oldest = s
+		}
+	}
+
+	cell := shortHash(oldest.Ref)
+	if mixed {
+		cell += "*"
+	}
+	var details []string
+	if oldest.CommitDate != nil {
+		details = append(details, formatAge(now.Sub(*oldest.CommitDate)))
+	}
+	if oldest.Behind != nil {
+		details = append(details, fmt.Sprintf("%d behind", *oldest.Behind))
+	}
+	if len(details) > 0 {
+		cell += " (" + strings.Join(details, ", ") + ")"
+	}
+	return cell
+}
+
+func writeStatusTable(out io.Writer, statuses []*targetStatus, now time.Time) error {
+	type row struct {
+		service  string
+		saasFile string
+	}
+	var rows []row
+	cells := map[row]map[string][]*targetStatus{}
+	for _, s := range statuses {
+		r := row{service: s.Service, saasFile: s.SaasFile}
+		if _, ok := cells[r]; !ok {
+			rows = append(rows, r)
+			cells[r] = map[string][]*targetStatus{}
+		}
+		cells[r][s.Environment] = append(cells[r][s.Environment], s)
+	}
+
+	table := printer.NewTablePrinter(out, 20, 1, 3, ' ')
+	table.AddRow([]string{"SERVICE", "SAAS FILE", "STAGE", "PROD-CANARY", "PROD"})
+	for _, r := range rows {
+		line := []string{r.service, filepath.Base(r.saasFile)}
+		for _, wave := range planWaves {
+			line = append(line, statusCell(cells[r][wave], now))
+		}
+		table.AddRow(line)
+	}
+
+	return table.Flush()
+}
@@ -0,0 +1,117 @@
+package saas
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/osdctl/cmd/promote/git"
+	"github.com/openshift/osdctl/cmd/promote/iexec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "git %v: %s", args, out)
+	return strings.TrimSpace(string(out))
+}
+
+func TestPromoteStatus(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	// Service repo with three commits
+	serviceRepo := t.TempDir()
+	runGit(t, serviceRepo, "init", "-q", "-b", "main")
+	var hashes []string
+	for i := 0; i < 3; i++ {
+		runGit(t, serviceRepo, "commit", "-q", "--allow-empty", "-m", fmt.Sprintf("commit %d", i))
+		hashes = append(hashes, runGit(t, serviceRepo, "rev-parse", "HEAD"))
+	}
+
+	appInterfaceDir := t.TempDir()
+	saasDir := filepath.Join(appInterfaceDir, OSDSaasDir, "saas-foo-operator")
+	require.NoError(t, os.MkdirAll(saasDir, 0o755))
+	saasFile := fmt.Sprintf(`name: saas-foo-operator
+resourceTemplates:
+- name: foo-operator
+  url: %s
+  targets:
+  - name: foo-operator-stage
+    namespace:
+      $ref: /services/osd-operators/namespaces/hivestage/foo.yml
+    ref: %s
+  - name: foo-operator-prod-canary
+    namespace:
+      $ref: /services/osd-operators/namespaces/hivep01/foo.yml
+    ref: %s
+  - name: foo-operator-production-hivep02
+    namespace:
+      $ref: /services/osd-operators/namespaces/hivep02/foo.yml
+    ref: %s
+  - name: foo-operator-production-hivep03
+    namespace:
+      $ref: /services/osd-operators/namespaces/hivep03/foo.yml
+    ref: %s
+`, serviceRepo, hashes[2], hashes[1], hashes[1], hashes[0])
+	require.NoError(t, os.WriteFile(filepath.Join(saasDir, "deploy.yaml"), []byte(saasFile), 0o600))
+
+	ServicesSlice = nil
+	ServicesFilesMap = map[string]string{}
+	appInterface := git.AppInterface{GitDirectory: appInterfaceDir, GitExecutor: iexec.Exec{}}
+
+	var out bytes.Buffer
+	ops := &statusOptions{output: "json", services: []string{"foo-operator"}}
+	require.NoError(t, ops.run(appInterface, &out))
+
+	var statuses []targetStatus
+	require.NoError(t, json.Unmarshal(out.Bytes(), &statuses))
+	require.Len(t, statuses, 4)
+	assert.Equal(t, "saas-foo-operator", statuses[0].Service)
+	assert.Equal(t, waveStage, statuses[0].Environment)
+	require.NotNil(t, statuses[0].Behind)
+	assert.Equal(t, 0, *statuses[0].Behind)
+	assert.Equal(t, waveProdCanary, statuses[1].Environment)
+	assert.Equal(t, 1, *statuses[1].Behind)
+	assert.Equal(t, 2, *statuses[3].Behind)
+	assert.NotNil(t, statuses[3].CommitDate)
+
+	// The prod cell shows the target furthest behind, marked as mixed
+	out.Reset()
+	ServicesSlice = nil
+	ops = &statusOptions{output: "table"}
+	require.NoError(t, ops.run(appInterface, &out))
+	assert.Contains(t, out.String(), "saas-foo-operator")
+	assert.Contains(t, out.String(), shortHash(hashes[0])+"* (")
+	assert.Contains(t, out.String(), "2 behind)")
+}
+
+func TestStatusCell(t *testing.T) {
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	date := now.Add(-72 * time.Hour)
+	one, three := 1, 3
+
+	assert.Equal(t, "-", statusCell(nil, now))
+	assert.Equal(t, "aaaaaaa", statusCell([]*targetStatus{{Ref: "aaaaaaa"}}, now))
+	assert.Equal(t, "master", statusCell([]*targetStatus{{Ref: "master"}}, now))
+	assert.Equal(t, "bbbbbbb* (3d, 3 behind)", statusCell([]*targetStatus{
+		{Ref: "aaaaaaa", Behind: &one, CommitDate: &date},
+		{Ref: "bbbbbbb", Behind: &three, CommitDate: &date},
+	}, now))
+}
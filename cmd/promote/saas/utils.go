@@ -134,7 +134,7 @@ func generateTestLogsURL(appInterface git.AppInterface, operatorName, gitHash st
 	return url
 }
 
-func servicePromotion(appInterface git.AppInterface, serviceName, gitHash string, namespaceRef string, osd, hcp, hotfix bool) error {
+func servicePromotion(appInterface git.AppInterface, serviceName, gitHash string, namespaceRef string, osd, hcp, hotfix bool, changelogJSON string) error {
 	_, err := GetServiceNames(appInterface, OSDSaasDir, BPSaasDir, CADSaasDir)
 	if err != nil {
 		return err
@@ -162,13 +162,24 @@ func servicePromotion(appInterface git.AppInterface, serviceName, gitHash string
 	}
 	fmt.Printf("Current Git Hash: %v\nGit Repo: %v\n\n", currentGitHash, serviceRepo)
 
-	promotionGitHash, commitLog, err := git.CheckoutAndCompareGitHash(appInterface.GitExecutor, serviceRepo, gitHash, currentGitHash, "")
+	promotionGitHash, changelog, err := git.CheckoutAndBuildChangelog(git.DefaultGitBackend(appInterface.GitExecutor), serviceRepo, gitHash, currentGitHash, "")
 	if err != nil {
 		return fmt.Errorf("failed to checkout and compare git hash: %v", err)
 	} else if promotionGitHash == "" {
 		fmt.Printf("Unable to find a git hash to promote. Exiting.\n")
 		os.Exit(6)
 	}
+
+	if changelogJSON != "" {
+		data, err := changelog.JSON()
+		if err != nil {
+			return fmt.Errorf("failed to render changelog: %v", err)
+		}
+		if err := os.WriteFile(changelogJSON, data, 0600); err != nil {
+			return fmt.Errorf("failed to write changelog: %v", err)
+		}
+		fmt.Printf("Changelog written to %s\n", changelogJSON)
+	}
 	fmt.Printf("Service: %s will be promoted to %s\n", serviceName, promotionGitHash)
 
 	branchName := fmt.Sprintf("promote-%s-%s", serviceName, promotionGitHash)
@@ -210,10 +221,9 @@ func servicePromotion(appInterface git.AppInterface, serviceName, gitHash string
 	commitMessage += "## Changes\n\n"
 	commitMessage += fmt.Sprintf("[Compare changes on GitHub](%s/compare/%s...%s)\n\n", serviceRepo, currentGitHash, promotionGitHash)
 
-	// Add commit log in code block for better formatting
-	commitMessage += "### Commit Log\n\n```\n"
-	commitMessage += commitLog
-	commitMessage += "\n```"
+	// Add the changelog grouped by pull request, with the changes that need a closer review first
+	commitMessage += "### Changelog\n\n"
+	commitMessage += changelog.Markdown()
 
 	fmt.Printf("commitMessage: %s\n", commitMessage)
 
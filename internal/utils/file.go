@@ -1,7 +1,10 @@
 package utils
 
 import (
+	"archive/tar"
+	"compress/gzip"
 	"fmt"
+	"io"
 	"os"
 
 	osFile "path/filepath"
@@ -64,3 +67,73 @@ func CreateFile(filepath string) error {
 
 	return nil
 }
+
+// CreateTarball writes the contents of sourceDir to a gzip compressed tarball.
+// Entries are stored relative to sourceDir.
+func CreateTarball(sourceDir, tarballName string) error {
+	tarballFile, err := os.Create(tarballName)
+	if err != nil {
+		return fmt.Errorf("failed to create tarball file: %v", err)
+	}
+	defer tarballFile.Close()
+
+	gzipWriter := gzip.NewWriter(tarballFile)
+	defer gzipWriter.Close()
+
+	tarWriter := tar.NewWriter(gzipWriter)
+	defer tarWriter.Close()
+
+	// Walk through the directory and add files to the tarball
+	err = osFile.Walk(sourceDir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("error walking the path %v: %v", file, err)
+		}
+
+		// Skip the root directory itself
+		if file == sourceDir {
+			return nil
+		}
+
+		// Create the header for the file entry
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return fmt.Errorf("failed to create tar header for file %v: %v", file, err)
+		}
+
+		// Set the relative name for the file in the tarball (strip the sourceDir prefix)
+		relPath, err := osFile.Rel(sourceDir, file)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %v", err)
+		}
+		header.Name = relPath
+
+		// Write the header for the file into the tarball
+		err = tarWriter.WriteHeader(header)
+		if err != nil {
+			return fmt.Errorf("failed to write header for file %v: %v", file, err)
+		}
+
+		// If it's a regular file, add its content to the tarball
+		if !info.IsDir() {
+			fileToArchive, err := os.Open(file)
+			if err != nil {
+				return fmt.Errorf("failed to open file %v: %v", file, err)
+			}
+			defer fileToArchive.Close()
+
+			// Copy the file content into the tarball
+			_, err = io.Copy(tarWriter, fileToArchive)
+			if err != nil {
+				return fmt.Errorf("failed to write file content for file %v: %v", file, err)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("error walking source directory: %v", err)
+	}
+
+	return nil
+}
@@ -198,3 +198,22 @@ func skipWindowsNonWritableDirScenario(t *testing.T, file string, scenarioName s
 		t.Skipf("Skip %q test in windows", scenarioName)
 	}
 }
+
+func TestCreateTarball(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "testfile.txt"), []byte("Hello, world!"), 0600); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	tarballName := filepath.Join(t.TempDir(), "output.tar.gz")
+	if err := CreateTarball(dir, tarballName); err != nil {
+		t.Fatalf("CreateTarball() error = %v", err)
+	}
+	if _, err := os.Stat(tarballName); err != nil {
+		t.Errorf("expected tarball to be created: %v", err)
+	}
+
+	if err := CreateTarball(filepath.Join(dir, "nonexistent"), filepath.Join(t.TempDir(), "fail.tar.gz")); err == nil {
+		t.Errorf("expected an error for a nonexistent source directory")
+	}
+}
@@ -128,6 +128,11 @@ func (s *LazyClient) Impersonate(userName string, elevationReasons ...string) {
 	s.elevationReasons = elevationReasons
 }
 
+// Clone returns a new client with the same configuration which isn't initialized yet, so it can still impersonate
+func (s *LazyClient) Clone() *LazyClient {
+	return &LazyClient{s.lazyClientInitializer, nil, s.flags, "", nil}
+}
+
 func NewClient(flags *genericclioptions.ConfigFlags) *LazyClient {
 	return &LazyClient{&lazyClientInitializer{}, nil, flags, "", nil}
 }
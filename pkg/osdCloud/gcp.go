@@ -44,6 +44,17 @@ func ListInstances(client *compute.InstancesClient, projectID, zone string) *com
 	return client.List(ctx, request)
 }
 
+// DeleteInstance requests the deletion of an instance without waiting for it to complete
+func DeleteInstance(ctx context.Context, client *compute.InstancesClient, projectID, zone, name string) error {
+	request := &computepb.DeleteInstanceRequest{
+		Project:  projectID,
+		Zone:     zone,
+		Instance: name,
+	}
+	_, err := client.Delete(ctx, request)
+	return err
+}
+
 // Concrete struct with fields required only for interacting with the GCP cloud.
 type GcpCluster struct {
 	*BaseClient
@@ -62,6 +62,7 @@ type Client interface {
 	CreateAccessKey(*iam.CreateAccessKeyInput) (*iam.CreateAccessKeyOutput, error)
 	DeleteAccessKey(*iam.DeleteAccessKeyInput) (*iam.DeleteAccessKeyOutput, error)
 	ListAccessKeys(*iam.ListAccessKeysInput) (*iam.ListAccessKeysOutput, error)
+	GetAccessKeyLastUsed(*iam.GetAccessKeyLastUsedInput) (*iam.GetAccessKeyLastUsedOutput, error)
 	GetUser(*iam.GetUserInput) (*iam.GetUserOutput, error)
 	CreateUser(*iam.CreateUserInput) (*iam.CreateUserOutput, error)
 	ListUsers(*iam.ListUsersInput) (*iam.ListUsersOutput, error)
@@ -97,6 +98,11 @@ type Client interface {
 	// Service Quotas
 	ListServiceQuotas(*servicequotas.ListServiceQuotasInput) (*servicequotas.ListServiceQuotasOutput, error)
 	RequestServiceQuotaIncrease(*servicequotas.RequestServiceQuotaIncreaseInput) (*servicequotas.RequestServiceQuotaIncreaseOutput, error)
+	GetServiceQuota(*servicequotas.GetServiceQuotaInput) (*servicequotas.GetServiceQuotaOutput, error)
+	GetAWSDefaultServiceQuota(*servicequotas.GetAWSDefaultServiceQuotaInput) (*servicequotas.GetAWSDefaultServiceQuotaOutput, error)
+	GetRequestedServiceQuotaChange(*servicequotas.GetRequestedServiceQuotaChangeInput) (*servicequotas.GetRequestedServiceQuotaChangeOutput, error)
+	ListRequestedServiceQuotaChangeHistory(*servicequotas.ListRequestedServiceQuotaChangeHistoryInput) (*servicequotas.ListRequestedServiceQuotaChangeHistoryOutput, error)
+	ListRequestedServiceQuotaChangeHistoryByQuota(*servicequotas.ListRequestedServiceQuotaChangeHistoryByQuotaInput) (*servicequotas.ListRequestedServiceQuotaChangeHistoryByQuotaOutput, error)
 
 	// Organizations
 	CreateAccount(input *organizations.CreateAccountInput) (*organizations.CreateAccountOutput, error)
@@ -121,6 +127,9 @@ type Client interface {
 	GetCostAndUsage(input *costexplorer.GetCostAndUsageInput) (*costexplorer.GetCostAndUsageOutput, error)
 	CreateCostCategoryDefinition(input *costexplorer.CreateCostCategoryDefinitionInput) (*costexplorer.CreateCostCategoryDefinitionOutput, error)
 	ListCostCategoryDefinitions(input *costexplorer.ListCostCategoryDefinitionsInput) (*costexplorer.ListCostCategoryDefinitionsOutput, error)
+	DescribeCostCategoryDefinition(input *costexplorer.DescribeCostCategoryDefinitionInput) (*costexplorer.DescribeCostCategoryDefinitionOutput, error)
+	UpdateCostCategoryDefinition(input *costexplorer.UpdateCostCategoryDefinitionInput) (*costexplorer.UpdateCostCategoryDefinitionOutput, error)
+	DeleteCostCategoryDefinition(input *costexplorer.DeleteCostCategoryDefinitionInput) (*costexplorer.DeleteCostCategoryDefinitionOutput, error)
 
 	// Cloudtrail
 	LookupEvents(input *cloudtrail.LookupEventsInput) (*cloudtrail.LookupEventsOutput, error)
@@ -311,6 +320,10 @@ func (c *AwsClient) ListAccessKeys(input *iam.ListAccessKeysInput) (*iam.ListAcc
 	return c.iamClient.ListAccessKeys(context.TODO(), input)
 }
 
+func (c *AwsClient) GetAccessKeyLastUsed(input *iam.GetAccessKeyLastUsedInput) (*iam.GetAccessKeyLastUsedOutput, error) {
+	return c.iamClient.GetAccessKeyLastUsed(context.TODO(), input)
+}
+
 func (c *AwsClient) GetUser(input *iam.GetUserInput) (*iam.GetUserOutput, error) {
 	return c.iamClient.GetUser(context.TODO(), input)
 }
@@ -425,6 +438,26 @@ func (c *AwsClient) RequestServiceQuotaIncrease(input *servicequotas.RequestServ
 	return c.servicequotasClient.RequestServiceQuotaIncrease(context.TODO(), input)
 }
 
+func (c *AwsClient) GetServiceQuota(input *servicequotas.GetServiceQuotaInput) (*servicequotas.GetServiceQuotaOutput, error) {
+	return c.servicequotasClient.GetServiceQuota(context.TODO(), input)
+}
+
+func (c *AwsClient) GetAWSDefaultServiceQuota(input *servicequotas.GetAWSDefaultServiceQuotaInput) (*servicequotas.GetAWSDefaultServiceQuotaOutput, error) {
+	return c.servicequotasClient.GetAWSDefaultServiceQuota(context.TODO(), input)
+}
+
+func (c *AwsClient) GetRequestedServiceQuotaChange(input *servicequotas.GetRequestedServiceQuotaChangeInput) (*servicequotas.GetRequestedServiceQuotaChangeOutput, error) {
+	return c.servicequotasClient.GetRequestedServiceQuotaChange(context.TODO(), input)
+}
+
+func (c *AwsClient) ListRequestedServiceQuotaChangeHistory(input *servicequotas.ListRequestedServiceQuotaChangeHistoryInput) (*servicequotas.ListRequestedServiceQuotaChangeHistoryOutput, error) {
+	return c.servicequotasClient.ListRequestedServiceQuotaChangeHistory(context.TODO(), input)
+}
+
+func (c *AwsClient) ListRequestedServiceQuotaChangeHistoryByQuota(input *servicequotas.ListRequestedServiceQuotaChangeHistoryByQuotaInput) (*servicequotas.ListRequestedServiceQuotaChangeHistoryByQuotaOutput, error) {
+	return c.servicequotasClient.ListRequestedServiceQuotaChangeHistoryByQuota(context.TODO(), input)
+}
+
 func (c *AwsClient) CreateAccount(input *organizations.CreateAccountInput) (*organizations.CreateAccountOutput, error) {
 	return c.orgClient.CreateAccount(context.TODO(), input)
 }
@@ -477,6 +510,18 @@ func (c *AwsClient) ListCostCategoryDefinitions(input *costexplorer.ListCostCate
 	return c.ceClient.ListCostCategoryDefinitions(context.TODO(), input)
 }
 
+func (c *AwsClient) DescribeCostCategoryDefinition(input *costexplorer.DescribeCostCategoryDefinitionInput) (*costexplorer.DescribeCostCategoryDefinitionOutput, error) {
+	return c.ceClient.DescribeCostCategoryDefinition(context.TODO(), input)
+}
+
+func (c *AwsClient) UpdateCostCategoryDefinition(input *costexplorer.UpdateCostCategoryDefinitionInput) (*costexplorer.UpdateCostCategoryDefinitionOutput, error) {
+	return c.ceClient.UpdateCostCategoryDefinition(context.TODO(), input)
+}
+
+func (c *AwsClient) DeleteCostCategoryDefinition(input *costexplorer.DeleteCostCategoryDefinitionInput) (*costexplorer.DeleteCostCategoryDefinitionOutput, error) {
+	return c.ceClient.DeleteCostCategoryDefinition(context.TODO(), input)
+}
+
 func (c *AwsClient) DescribeInstances(input *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
 	return c.ec2Client.DescribeInstances(context.TODO(), input)
 }
@@ -0,0 +1,53 @@
+package aws
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+)
+
+// GetAccountsRecursive returns the IDs of the accounts of all child OUs of the OU, followed by the accounts directly under it
+func GetAccountsRecursive(awsClient Client, ouID string) ([]string, error) {
+	var accountIDs []string
+	var nextToken *string
+	for {
+		OUs, err := awsClient.ListOrganizationalUnitsForParent(&organizations.ListOrganizationalUnitsForParentInput{
+			ParentId:  &ouID,
+			NextToken: nextToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list OUs of %s: %w", ouID, err)
+		}
+		for _, childOU := range OUs.OrganizationalUnits {
+			childAccounts, err := GetAccountsRecursive(awsClient, *childOU.Id)
+			if err != nil {
+				return nil, err
+			}
+			accountIDs = append(accountIDs, childAccounts...)
+		}
+		if OUs.NextToken == nil {
+			break
+		}
+		nextToken = OUs.NextToken
+	}
+
+	nextToken = nil
+	for {
+		accounts, err := awsClient.ListAccountsForParent(&organizations.ListAccountsForParentInput{
+			ParentId:  &ouID,
+			NextToken: nextToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list accounts of %s: %w", ouID, err)
+		}
+		for _, account := range accounts.Accounts {
+			accountIDs = append(accountIDs, *account.Id)
+		}
+		if accounts.NextToken == nil {
+			break
+		}
+		nextToken = accounts.NextToken
+	}
+
+	return accountIDs, nil
+}
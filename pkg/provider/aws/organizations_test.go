@@ -0,0 +1,68 @@
+package aws
+
+import (
+	"errors"
+	"testing"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	. "github.com/onsi/gomega"
+	"github.com/openshift/osdctl/pkg/provider/aws/mock"
+	"go.uber.org/mock/gomock"
+)
+
+func TestGetAccountsRecursive(t *testing.T) {
+	g := NewGomegaWithT(t)
+	testCases := []struct {
+		title        string
+		setupAWSMock func(r *mock.MockClientMockRecorder)
+		expected     []string
+		errExpected  bool
+	}{
+		{
+			title: "Accounts of the OU and its child OUs across pages",
+			setupAWSMock: func(r *mock.MockClientMockRecorder) {
+				r.ListAccountsForParent(&organizations.ListAccountsForParentInput{ParentId: awsSdk.String("ou-root")}).
+					Return(&organizations.ListAccountsForParentOutput{Accounts: []types.Account{{Id: awsSdk.String("111111111111")}}, NextToken: awsSdk.String("page-2")}, nil)
+				r.ListAccountsForParent(&organizations.ListAccountsForParentInput{ParentId: awsSdk.String("ou-root"), NextToken: awsSdk.String("page-2")}).
+					Return(&organizations.ListAccountsForParentOutput{Accounts: []types.Account{{Id: awsSdk.String("222222222222")}}}, nil)
+				r.ListOrganizationalUnitsForParent(&organizations.ListOrganizationalUnitsForParentInput{ParentId: awsSdk.String("ou-root")}).
+					Return(&organizations.ListOrganizationalUnitsForParentOutput{OrganizationalUnits: []types.OrganizationalUnit{{Id: awsSdk.String("ou-child")}}}, nil)
+				r.ListAccountsForParent(&organizations.ListAccountsForParentInput{ParentId: awsSdk.String("ou-child")}).
+					Return(&organizations.ListAccountsForParentOutput{Accounts: []types.Account{{Id: awsSdk.String("333333333333")}}}, nil)
+				r.ListOrganizationalUnitsForParent(&organizations.ListOrganizationalUnitsForParentInput{ParentId: awsSdk.String("ou-child")}).
+					Return(&organizations.ListOrganizationalUnitsForParentOutput{}, nil)
+			},
+			expected: []string{"333333333333", "111111111111", "222222222222"},
+		},
+		{
+			title: "Listing the accounts of a child OU fails",
+			setupAWSMock: func(r *mock.MockClientMockRecorder) {
+				r.ListOrganizationalUnitsForParent(&organizations.ListOrganizationalUnitsForParentInput{ParentId: awsSdk.String("ou-root")}).
+					Return(&organizations.ListOrganizationalUnitsForParentOutput{OrganizationalUnits: []types.OrganizationalUnit{{Id: awsSdk.String("ou-child")}}}, nil)
+				r.ListOrganizationalUnitsForParent(&organizations.ListOrganizationalUnitsForParentInput{ParentId: awsSdk.String("ou-child")}).
+					Return(&organizations.ListOrganizationalUnitsForParentOutput{}, nil)
+				r.ListAccountsForParent(&organizations.ListAccountsForParentInput{ParentId: awsSdk.String("ou-child")}).
+					Return(nil, errors.New("FakeError"))
+			},
+			errExpected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			mocks := gomock.NewController(t)
+			awsClient := mock.NewMockClient(mocks)
+			tc.setupAWSMock(awsClient.EXPECT())
+
+			accounts, err := GetAccountsRecursive(awsClient, "ou-root")
+			if tc.errExpected {
+				g.Expect(err).Should(HaveOccurred())
+				return
+			}
+			g.Expect(err).ShouldNot(HaveOccurred())
+			g.Expect(accounts).Should(Equal(tc.expected))
+		})
+	}
+}